@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// startAdminServer starts the embedded HTTP server used for operator tooling,
+// over TLS and behind authentication when they are configured
+func (is *IngestionService) startAdminServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	is.registerDashboard(mux)
+	is.registerHealth(mux)
+	is.registerTagAPI(mux)
+	is.registerWatchlistAPI(mux)
+	is.registerGasOracleAPI(mux)
+	is.registerTxIndexAPI(mux)
+	is.registerWebSocketFanout(mux)
+	is.registerSSE(mux)
+	is.registerGraphQL(mux)
+	is.registerShardAPI(mux)
+	is.registerChaosAPI(mux)
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           is.auth.Middleware(mux),
+		ReadHeaderTimeout: 5 * time.Second,
+		TLSConfig:         is.tls,
+	}
+
+	go func() {
+		slog.Info("admin server listening", "addr", addr, "tls", is.tls != nil, "auth", is.auth != nil)
+		var err error
+		if is.tls != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			slog.Error("admin server failed", "error", err)
+		}
+	}()
+
+	return server
+}
+
+// writeJSON encodes v as the JSON response body
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("failed to encode admin response", "error", err)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Alert severities
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+var severityRank = map[string]int{
+	SeverityInfo:     0,
+	SeverityWarning:  1,
+	SeverityCritical: 2,
+}
+
+var alertsDeduplicated = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_alerts_deduplicated_total",
+		Help: "Alerts withheld from transports as repeats within the cooldown, by category",
+	},
+	[]string{"category"},
+)
+
+// alertStatePrune is how many dedup entries the alerter keeps before it
+// drops those past their cooldown
+const alertStatePrune = 1024
+
+// Alert is an operational or detection event raised by the service
+type Alert struct {
+	Time     time.Time `json:"time"`
+	Chain    string    `json:"chain"`
+	Category string    `json:"category"`
+	Severity string    `json:"severity"`
+	Message  string    `json:"message"`
+	// Key identifies a condition whose alerts repeat with varying messages,
+	// so they dedup together; without it the message identifies the alert
+	Key string `json:"key,omitempty"`
+	// Resolved marks the condition identified by Key as cleared
+	Resolved bool `json:"resolved,omitempty"`
+}
+
+// DedupKey identifies the condition an alert reports, for cooldowns and
+// for transports that group alerts into incidents
+func (a Alert) DedupKey() string {
+	if a.Key != "" {
+		return a.Chain + "/" + a.Category + "/" + a.Key
+	}
+	return a.Chain + "/" + a.Category + "/" + a.Message
+}
+
+// alertState is the last delivery of one condition
+type alertState struct {
+	sent       time.Time
+	severity   string
+	resolved   bool
+	suppressed int
+}
+
+// alertRoute forwards matching alerts to a transport
+type alertRoute struct {
+	transport   AlertTransport
+	categories  map[string]bool
+	minSeverity int
+}
+
+// matches reports whether the alert passes the route's category and severity filters
+func (r alertRoute) matches(alert Alert) bool {
+	if severityRank[alert.Severity] < r.minSeverity {
+		return false
+	}
+	return len(r.categories) == 0 || r.categories[alert.Category]
+}
+
+// Alerter collects alerts raised by chain monitors, keeps the most recent ones
+// and fans them out to the configured transports. A condition that keeps
+// firing is delivered again only once its cooldown passes or its severity
+// rises; resolving it is delivered at once.
+type Alerter struct {
+	mu       sync.RWMutex
+	recent   []Alert
+	next     int
+	full     bool
+	routes   []alertRoute
+	cooldown time.Duration
+	states   map[string]*alertState
+	dispatch chan Alert
+	done     chan struct{}
+	closed   bool
+}
+
+// NewAlerter creates an alerter that retains the last size alerts and
+// delivers repeats of a condition at most once per cooldown
+func NewAlerter(size int, cooldown time.Duration) *Alerter {
+	if size <= 0 {
+		size = 100
+	}
+
+	a := &Alerter{
+		recent:   make([]Alert, size),
+		cooldown: cooldown,
+		states:   make(map[string]*alertState),
+		dispatch: make(chan Alert, 256),
+		done:     make(chan struct{}),
+	}
+	go a.dispatchLoop()
+	return a
+}
+
+// AddTransport routes alerts in the given categories (all when empty) at or above minSeverity to t
+func (a *Alerter) AddTransport(t AlertTransport, categories []string, minSeverity string) {
+	route := alertRoute{
+		transport:   t,
+		categories:  make(map[string]bool),
+		minSeverity: severityRank[minSeverity],
+	}
+	for _, category := range categories {
+		route.categories[category] = true
+	}
+
+	a.mu.Lock()
+	a.routes = append(a.routes, route)
+	a.mu.Unlock()
+}
+
+// Raise records an alert and queues it for delivery
+func (a *Alerter) Raise(alert Alert) {
+	if alert.Time.IsZero() {
+		alert.Time = time.Now()
+	}
+	alert.Message = redact(alert.Message)
+
+	slog.Info("alert", "chain", alert.Chain, "category", alert.Category, "severity", alert.Severity, "message", alert.Message)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.recent[a.next] = alert
+	a.next = (a.next + 1) % len(a.recent)
+	if a.next == 0 {
+		a.full = true
+	}
+
+	if a.closed || len(a.routes) == 0 {
+		return
+	}
+	alert, deliver := a.dedup(alert)
+	if !deliver {
+		alertsDeduplicated.WithLabelValues(alert.Category).Inc()
+		return
+	}
+
+	select {
+	case a.dispatch <- alert:
+	default:
+		slog.Warn("alert dispatch queue full, dropping alert", "chain", alert.Chain, "category", alert.Category)
+	}
+}
+
+// dedup decides whether an alert is delivered, noting in the message how
+// many repeats the cooldown held back. The caller holds a.mu.
+func (a *Alerter) dedup(alert Alert) (Alert, bool) {
+	key := alert.DedupKey()
+	state, seen := a.states[key]
+	now := time.Now()
+
+	if alert.Resolved {
+		// Only a condition that was delivered as firing needs resolving. The
+		// resolution takes its severity so it follows the same routes.
+		if !seen || state.resolved {
+			return alert, false
+		}
+		alert.Severity = state.severity
+		state.sent, state.resolved, state.suppressed = now, true, 0
+		return alert, true
+	}
+
+	if seen && !state.resolved && now.Sub(state.sent) < a.cooldown && severityRank[alert.Severity] <= severityRank[state.severity] {
+		state.suppressed++
+		return alert, false
+	}
+	if seen && state.suppressed > 0 {
+		alert.Message = fmt.Sprintf("%s (%d similar alerts suppressed)", alert.Message, state.suppressed)
+	}
+
+	if !seen {
+		if len(a.states) >= alertStatePrune {
+			for k, s := range a.states {
+				if now.Sub(s.sent) >= a.cooldown {
+					delete(a.states, k)
+				}
+			}
+		}
+		state = &alertState{}
+		a.states[key] = state
+	}
+	state.sent, state.severity, state.resolved, state.suppressed = now, alert.Severity, false, 0
+	return alert, true
+}
+
+// dispatchLoop delivers queued alerts to matching transports
+func (a *Alerter) dispatchLoop() {
+	defer close(a.done)
+
+	for alert := range a.dispatch {
+		a.mu.RLock()
+		routes := a.routes
+		a.mu.RUnlock()
+
+		for _, route := range routes {
+			if !route.matches(alert) {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := route.transport.Send(ctx, alert); err != nil {
+				slog.Error("failed to send alert", "transport", route.transport.Name(), "error", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Close stops accepting alerts and waits for queued deliveries to finish
+func (a *Alerter) Close() {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.dispatch)
+	}
+	a.mu.Unlock()
+
+	<-a.done
+}
+
+// Recent returns retained alerts, newest first
+func (a *Alerter) Recent() []Alert {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	count := a.next
+	if a.full {
+		count = len(a.recent)
+	}
+
+	alerts := make([]Alert, 0, count)
+	for i := 1; i <= count; i++ {
+		alerts = append(alerts, a.recent[(a.next-i+len(a.recent))%len(a.recent)])
+	}
+	return alerts
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"log/slog"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var archivedTransactions = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_archived_transactions_total",
+		Help: "Transactions written to archive stores by outcome",
+	},
+	[]string{"archiver", "result"},
+)
+
+// Archiver persists published transactions for historical queries.
+// Archive must not block the ingest path.
+type Archiver interface {
+	Name() string
+	Archive(tx Transaction)
+	Close()
+}
+
+// archiveBatcher buffers transactions and hands them to flush in batches,
+// by size or on an interval. When the buffer is full new transactions are
+// dropped rather than slowing ingestion.
+type archiveBatcher struct {
+	name     string
+	queue    chan Transaction
+	size     int
+	interval time.Duration
+	flush    func([]Transaction) error
+	done     chan struct{}
+	mu       sync.RWMutex
+	closed   bool
+}
+
+func newArchiveBatcher(name string, size int, interval time.Duration, flush func([]Transaction) error) *archiveBatcher {
+	if size <= 0 {
+		size = 10000
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	b := &archiveBatcher{
+		name:     name,
+		queue:    make(chan Transaction, size*4),
+		size:     size,
+		interval: interval,
+		flush:    flush,
+		done:     make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Add queues a transaction for the next batch
+func (b *archiveBatcher) Add(tx Transaction) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return
+	}
+
+	select {
+	case b.queue <- tx:
+	default:
+		archivedTransactions.WithLabelValues(b.name, "dropped").Inc()
+	}
+}
+
+func (b *archiveBatcher) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	batch := make([]Transaction, 0, b.size)
+	write := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := b.flush(batch); err != nil {
+			archivedTransactions.WithLabelValues(b.name, "failed").Add(float64(len(batch)))
+			slog.Error("failed to archive transactions", "archiver", b.name, "count", len(batch), "error", err)
+		} else {
+			archivedTransactions.WithLabelValues(b.name, "success").Add(float64(len(batch)))
+		}
+		batch = make([]Transaction, 0, b.size)
+	}
+
+	for {
+		select {
+		case tx, ok := <-b.queue:
+			if !ok {
+				write()
+				return
+			}
+			batch = append(batch, tx)
+			if len(batch) >= b.size {
+				write()
+			}
+		case <-ticker.C:
+			write()
+		}
+	}
+}
+
+// Close writes the remaining buffered transactions
+func (b *archiveBatcher) Close() {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.queue)
+	}
+	b.mu.Unlock()
+
+	<-b.done
+}
+
+// hexToBig parses a 0x-prefixed quantity, returning zero when empty or invalid
+func hexToBig(value string) *big.Int {
+	n, ok := new(big.Int).SetString(strings.TrimPrefix(value, "0x"), 16)
+	if !ok {
+		return new(big.Int)
+	}
+	return n
+}
+
+// hexToUint64 parses a 0x-prefixed quantity, returning zero when empty or invalid
+func hexToUint64(value string) uint64 {
+	n, err := strconv.ParseUint(strings.TrimPrefix(value, "0x"), 16, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Roles granted to API clients. Admins may also do everything readers can.
+const (
+	RoleRead  = "read"
+	RoleAdmin = "admin"
+)
+
+// Client certificate policies
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+var authRequests = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_auth_requests_total",
+		Help: "Admin, streaming and gRPC API requests by surface, credential method and result (ok, unauthenticated, forbidden)",
+	},
+	[]string{"surface", "method", "result"},
+)
+
+// AuthConfig configures TLS and client authentication for the admin HTTP
+// server, its WebSocket and SSE streams, and the gRPC server
+type AuthConfig struct {
+	TLSCert      string
+	TLSKey       string
+	ClientCA     string
+	ClientAuth   string
+	APIKeys      map[string]string
+	AdminKeys    map[string]string
+	JWTSecret    string
+	JWTPublicKey string
+	JWTIssuer    string
+	JWTAudience  string
+	JWTRoleClaim string
+	JWTTenant    string
+	CertRole     string
+	CertAdmins   []string
+	PublicPaths  []string
+}
+
+// loadAuthConfig reads TLS_* and AUTH_* settings. AUTH_API_KEYS and
+// AUTH_ADMIN_KEYS are name=key lists; keys may be ${secret:...} references.
+func loadAuthConfig() AuthConfig {
+	clientCA := getEnv("TLS_CLIENT_CA_FILE")
+	clientAuth := ClientAuthNone
+	if clientCA != "" {
+		clientAuth = ClientAuthRequire
+	}
+
+	config := AuthConfig{
+		TLSCert:      getEnv("TLS_CERT_FILE"),
+		TLSKey:       getEnv("TLS_KEY_FILE"),
+		ClientCA:     clientCA,
+		ClientAuth:   getEnvOrDefault("TLS_CLIENT_AUTH", clientAuth),
+		APIKeys:      parseKeyValues(getEnv("AUTH_API_KEYS")),
+		AdminKeys:    parseKeyValues(getEnv("AUTH_ADMIN_KEYS")),
+		JWTSecret:    getEnv("AUTH_JWT_SECRET"),
+		JWTPublicKey: getEnv("AUTH_JWT_PUBLIC_KEY_FILE"),
+		JWTIssuer:    getEnv("AUTH_JWT_ISSUER"),
+		JWTAudience:  getEnv("AUTH_JWT_AUDIENCE"),
+		JWTRoleClaim: getEnvOrDefault("AUTH_JWT_ROLE_CLAIM", "role"),
+		JWTTenant:    getEnvOrDefault("AUTH_JWT_TENANT_CLAIM", "tenant"),
+		CertRole:     getEnvOrDefault("AUTH_CERT_ROLE", RoleRead),
+		CertAdmins:   splitNonEmpty(getEnv("AUTH_CERT_ADMINS")),
+		PublicPaths:  splitNonEmpty(getEnvOrDefault("AUTH_PUBLIC_PATHS", "/healthz,/readyz")),
+	}
+	for _, keys := range []map[string]string{config.APIKeys, config.AdminKeys} {
+		for _, key := range keys {
+			registerSecrets(key)
+		}
+	}
+	registerSecrets(config.JWTSecret)
+	return config
+}
+
+// TLS reports whether the servers are served over TLS
+func (c AuthConfig) TLS() bool {
+	return c.TLSCert != ""
+}
+
+// Enabled reports whether clients must authenticate: any API key, JWT key or
+// client certificate verification turns authentication on
+func (c AuthConfig) Enabled() bool {
+	return len(c.APIKeys) > 0 || len(c.AdminKeys) > 0 || c.JWTSecret != "" || c.JWTPublicKey != "" ||
+		(c.ClientCA != "" && c.ClientAuth != ClientAuthNone)
+}
+
+// serverTLSConfig builds the TLS configuration shared by the admin and gRPC
+// servers, or nil without a certificate. The certificate is re-read when its
+// files change, so rotated certificates are served without a restart.
+func serverTLSConfig(config AuthConfig) (*tls.Config, error) {
+	if !config.TLS() {
+		return nil, nil
+	}
+
+	loader := &certificateLoader{certFile: config.TLSCert, keyFile: config.TLSKey}
+	if _, err := loader.GetCertificate(nil); err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: loader.GetCertificate,
+	}
+
+	if config.ClientCA != "" {
+		pem, err := os.ReadFile(config.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA %s", config.ClientCA)
+		}
+		tlsConfig.ClientCAs = pool
+	}
+	switch config.ClientAuth {
+	case ClientAuthOptional:
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// certificateLoader serves a key pair from disk, reloading it when either
+// file's modification time changes
+type certificateLoader struct {
+	certFile string
+	keyFile  string
+	mu       sync.Mutex
+	modified time.Time
+	cert     *tls.Certificate
+}
+
+func (l *certificateLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	modified := time.Time{}
+	for _, path := range []string{l.certFile, l.keyFile} {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cert != nil && !modified.After(l.modified) {
+		return l.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		if l.cert != nil {
+			slog.Warn("failed to reload TLS certificate, serving the previous one", "cert", l.certFile, "error", err)
+			return l.cert, nil
+		}
+		return nil, fmt.Errorf("failed to load TLS certificate: %v", err)
+	}
+	if l.cert != nil {
+		slog.Info("reloaded TLS certificate", "cert", l.certFile)
+	}
+	l.cert, l.modified = &cert, modified
+	return l.cert, nil
+}
+
+// Principal is an authenticated API client. Tenants' streams are held to
+// their tenant's quota.
+type Principal struct {
+	Name   string
+	Role   string
+	Method string
+	Tenant string
+}
+
+// Allows reports whether the principal may perform actions needing role
+func (p Principal) Allows(role string) bool {
+	return p.Role == RoleAdmin || p.Role == role
+}
+
+type principalKey struct{}
+
+// principalFrom returns the client authenticated for a request, if any
+func principalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// apiKey is a static credential, the role it grants and the tenant, if
+// any, it belongs to
+type apiKey struct {
+	name   string
+	key    string
+	role   string
+	tenant string
+}
+
+// authenticator checks API keys, JWTs and client certificates. A nil
+// authenticator admits every request.
+type authenticator struct {
+	config     AuthConfig
+	tenants    map[string]TenantConfig
+	keys       []apiKey
+	jwtKey     interface{}
+	jwtMethods []string
+}
+
+// newAuthenticator returns the authenticator for config, or nil when
+// authentication is off. Stream tokens are accepted as read-only keys so
+// existing streaming clients keep working once authentication is on, and
+// tenants' keys as read-only keys of their tenant, so configuring tenants
+// turns authentication on.
+func newAuthenticator(config AuthConfig, streamTokens map[string]string, tenants map[string]TenantConfig) (*authenticator, error) {
+	if !config.Enabled() && len(tenants) == 0 {
+		return nil, nil
+	}
+
+	a := &authenticator{config: config, tenants: tenants}
+	for name, tenant := range tenants {
+		for _, key := range tenant.Keys {
+			a.keys = append(a.keys, apiKey{name: name, key: key, role: RoleRead, tenant: name})
+		}
+	}
+	for name, key := range streamTokens {
+		a.keys = append(a.keys, apiKey{name: name, key: key, role: RoleRead})
+	}
+	for name, key := range config.APIKeys {
+		a.keys = append(a.keys, apiKey{name: name, key: key, role: RoleRead})
+	}
+	for name, key := range config.AdminKeys {
+		a.keys = append(a.keys, apiKey{name: name, key: key, role: RoleAdmin})
+	}
+
+	switch {
+	case config.JWTSecret != "":
+		a.jwtKey = []byte(config.JWTSecret)
+		a.jwtMethods = []string{"HS256", "HS384", "HS512"}
+	case config.JWTPublicKey != "":
+		pem, err := os.ReadFile(config.JWTPublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT public key: %v", err)
+		}
+		if a.jwtKey, err = jwt.ParseRSAPublicKeyFromPEM(pem); err == nil {
+			a.jwtMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+		} else if a.jwtKey, err = jwt.ParseECPublicKeyFromPEM(pem); err == nil {
+			a.jwtMethods = []string{"ES256", "ES384", "ES512"}
+		} else if a.jwtKey, err = jwt.ParseEdPublicKeyFromPEM(pem); err == nil {
+			a.jwtMethods = []string{"EdDSA"}
+		} else {
+			return nil, fmt.Errorf("JWT public key %s is not an RSA, ECDSA or Ed25519 public key", config.JWTPublicKey)
+		}
+	}
+
+	if !config.TLS() {
+		slog.Warn("API authentication is enabled without TLS; credentials are sent in clear text")
+	}
+	slog.Info("API authentication enabled", "keys", len(a.keys), "tenants", len(tenants), "jwt", a.jwtKey != nil, "client_certs", config.ClientAuth)
+	return a, nil
+}
+
+// authenticate identifies the client from a bearer token or, without one,
+// a verified client certificate
+func (a *authenticator) authenticate(token string, chains [][]*x509.Certificate) (Principal, error) {
+	if token != "" {
+		for _, key := range a.keys {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(key.key)) == 1 {
+				return Principal{Name: key.name, Role: key.role, Method: "api-key", Tenant: key.tenant}, nil
+			}
+		}
+		if a.jwtKey != nil && strings.Count(token, ".") == 2 {
+			return a.verifyJWT(token)
+		}
+		return Principal{}, fmt.Errorf("invalid credentials")
+	}
+
+	if len(chains) > 0 && len(chains[0]) > 0 {
+		name := chains[0][0].Subject.CommonName
+		role := a.config.CertRole
+		if containsString(a.config.CertAdmins, name) {
+			role = RoleAdmin
+		}
+		return Principal{Name: name, Role: role, Method: "cert"}, nil
+	}
+	return Principal{}, fmt.Errorf("missing credentials")
+}
+
+// verifyJWT checks a token's signature, expiry, issuer and audience and
+// takes the client's role from the role claim, a string or a list, and its
+// tenant from the tenant claim when it names a configured tenant
+func (a *authenticator) verifyJWT(token string) (Principal, error) {
+	options := []jwt.ParserOption{jwt.WithValidMethods(a.jwtMethods), jwt.WithExpirationRequired()}
+	if a.config.JWTIssuer != "" {
+		options = append(options, jwt.WithIssuer(a.config.JWTIssuer))
+	}
+	if a.config.JWTAudience != "" {
+		options = append(options, jwt.WithAudience(a.config.JWTAudience))
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := jwt.NewParser(options...).ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return a.jwtKey, nil
+	}); err != nil {
+		return Principal{}, fmt.Errorf("invalid token: %v", err)
+	}
+
+	var roles []string
+	switch value := claims[a.config.JWTRoleClaim].(type) {
+	case string:
+		roles = strings.Fields(value)
+	case []interface{}:
+		for _, role := range value {
+			if s, ok := role.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+	}
+	principal := Principal{Method: "jwt"}
+	principal.Name, _ = claims.GetSubject()
+	if tenant, ok := claims[a.config.JWTTenant].(string); ok {
+		if _, configured := a.tenants[tenant]; !configured {
+			return Principal{}, fmt.Errorf("token names unknown tenant %q", tenant)
+		}
+		principal.Tenant = tenant
+	}
+	switch {
+	case containsString(roles, RoleAdmin):
+		principal.Role = RoleAdmin
+		return principal, nil
+	case containsString(roles, RoleRead):
+		principal.Role = RoleRead
+		return principal, nil
+	}
+	return Principal{}, fmt.Errorf("token grants no %s or %s role in claim %q", RoleRead, RoleAdmin, a.config.JWTRoleClaim)
+}
+
+// requiredRole is the role an HTTP request needs: reads, including GraphQL
+// queries, need read and anything that changes state needs admin
+func requiredRole(r *http.Request) string {
+	switch {
+	case r.Method == http.MethodGet, r.Method == http.MethodHead, r.Method == http.MethodOptions:
+		return RoleRead
+	case r.URL.Path == "/graphql":
+		return RoleRead
+	}
+	return RoleAdmin
+}
+
+// bearerToken returns the credential presented with a request: an
+// Authorization bearer token, an X-API-Key header or, for browsers opening
+// streams, a token query parameter
+func bearerToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("token")
+}
+
+// Middleware authenticates admin server requests and checks the client's
+// role, leaving AUTH_PUBLIC_PATHS open for probes
+func (a *authenticator) Middleware(next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if containsString(a.config.PublicPaths, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var chains [][]*x509.Certificate
+		if r.TLS != nil {
+			chains = r.TLS.VerifiedChains
+		}
+		principal, err := a.authenticate(bearerToken(r), chains)
+		if err != nil {
+			authRequests.WithLabelValues("http", "none", "unauthenticated").Inc()
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scorpius"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+			return
+		}
+		if role := requiredRole(r); !principal.Allows(role) {
+			authRequests.WithLabelValues("http", principal.Method, "forbidden").Inc()
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": fmt.Sprintf("%s needs the %s role", principal.Name, role)})
+			return
+		}
+		authRequests.WithLabelValues("http", principal.Method, "ok").Inc()
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+	})
+}
+
+// authorizeGRPC authenticates a gRPC call from its authorization or
+// x-api-key metadata or the peer's client certificate. Every gRPC method
+// only reads, so the read role is enough.
+func (a *authenticator) authorizeGRPC(ctx context.Context) (context.Context, error) {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 && strings.HasPrefix(values[0], "Bearer ") {
+			token = strings.TrimPrefix(values[0], "Bearer ")
+		} else if values := md.Get("x-api-key"); len(values) > 0 {
+			token = values[0]
+		}
+	}
+	var chains [][]*x509.Certificate
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			chains = info.State.VerifiedChains
+		}
+	}
+
+	principal, err := a.authenticate(token, chains)
+	if err != nil {
+		authRequests.WithLabelValues("grpc", "none", "unauthenticated").Inc()
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if !principal.Allows(RoleRead) {
+		authRequests.WithLabelValues("grpc", principal.Method, "forbidden").Inc()
+		return nil, status.Errorf(codes.PermissionDenied, "%s needs the %s role", principal.Name, RoleRead)
+	}
+	authRequests.WithLabelValues("grpc", principal.Method, "ok").Inc()
+	return context.WithValue(ctx, principalKey{}, principal), nil
+}
+
+// grpcOptions returns the gRPC server options enforcing TLS and
+// authentication
+func (a *authenticator) grpcOptions(tlsConfig *tls.Config) []grpc.ServerOption {
+	var options []grpc.ServerOption
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	if a == nil {
+		return options
+	}
+	return append(options,
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			ctx, err := a.authorizeGRPC(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := a.authorizeGRPC(stream.Context())
+			if err != nil {
+				return err
+			}
+			return handler(srv, &authorizedStream{ServerStream: stream, ctx: ctx})
+		}),
+	)
+}
+
+// authorizedStream carries the authenticated principal to stream handlers
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testJWTSecret = "0123456789abcdef0123456789abcdef"
+
+func newTestAuthenticator(t *testing.T) *authenticator {
+	t.Helper()
+	config := AuthConfig{
+		APIKeys:      map[string]string{"dashboard": "read-key"},
+		AdminKeys:    map[string]string{"ops": "admin-key"},
+		JWTSecret:    testJWTSecret,
+		JWTRoleClaim: "role",
+		JWTTenant:    "tenant",
+		CertRole:     RoleRead,
+		CertAdmins:   []string{"ops.internal"},
+		PublicPaths:  []string{"/healthz"},
+	}
+	tenants := map[string]TenantConfig{"acme": {Keys: []string{"acme-key"}}}
+	a, err := newAuthenticator(config, map[string]string{"legacy": "stream-token"}, tenants)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// signJWT returns an HS256 token with claims that expires in a minute
+func signJWT(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	claims["exp"] = time.Now().Add(time.Minute).Unix()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// certChain returns a verified chain whose leaf has commonName
+func certChain(commonName string) [][]*x509.Certificate {
+	return [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: commonName}}}}
+}
+
+func TestAuthenticateRoles(t *testing.T) {
+	a := newTestAuthenticator(t)
+	tests := []struct {
+		name   string
+		token  string
+		chains [][]*x509.Certificate
+		role   string
+		tenant string
+		method string
+	}{
+		{name: "api key", token: "read-key", role: RoleRead, method: "api-key"},
+		{name: "admin key", token: "admin-key", role: RoleAdmin, method: "api-key"},
+		{name: "stream token", token: "stream-token", role: RoleRead, method: "api-key"},
+		{name: "tenant key", token: "acme-key", role: RoleRead, tenant: "acme", method: "api-key"},
+		{name: "jwt role", token: signJWT(t, jwt.MapClaims{"sub": "svc", "role": "read"}), role: RoleRead, method: "jwt"},
+		{name: "jwt role list", token: signJWT(t, jwt.MapClaims{"sub": "svc", "role": []string{"viewer", "admin"}}), role: RoleAdmin, method: "jwt"},
+		{name: "jwt space-separated roles", token: signJWT(t, jwt.MapClaims{"sub": "svc", "role": "viewer read"}), role: RoleRead, method: "jwt"},
+		{name: "jwt tenant", token: signJWT(t, jwt.MapClaims{"sub": "svc", "role": "read", "tenant": "acme"}), role: RoleRead, tenant: "acme", method: "jwt"},
+		{name: "client certificate", chains: certChain("dashboard.internal"), role: RoleRead, method: "cert"},
+		{name: "admin certificate", chains: certChain("ops.internal"), role: RoleAdmin, method: "cert"},
+		{name: "token over certificate", token: "admin-key", chains: certChain("dashboard.internal"), role: RoleAdmin, method: "api-key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := a.authenticate(tt.token, tt.chains)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if principal.Role != tt.role || principal.Tenant != tt.tenant || principal.Method != tt.method {
+				t.Errorf("authenticate() = %+v, want role %q, tenant %q, method %q", principal, tt.role, tt.tenant, tt.method)
+			}
+		})
+	}
+}
+
+func TestAuthenticateRejects(t *testing.T) {
+	a := newTestAuthenticator(t)
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"role": "admin", "exp": time.Now().Add(-time.Minute).Unix()}).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"role": "admin", "exp": time.Now().Add(time.Minute).Unix()}).SignedString([]byte("another secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"unknown key":    "not-a-key",
+		"expired jwt":    expired,
+		"forged jwt":     forged,
+		"no role":        signJWT(t, jwt.MapClaims{"sub": "svc", "role": "viewer"}),
+		"unknown tenant": signJWT(t, jwt.MapClaims{"sub": "svc", "role": "read", "tenant": "globex"}),
+		"no credentials": "",
+	}
+	for name, token := range tests {
+		if principal, err := a.authenticate(token, nil); err == nil {
+			t.Errorf("%s: authenticate() = %+v, want an error", name, principal)
+		}
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	a := newTestAuthenticator(t)
+	handler := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		method string
+		path   string
+		key    string
+		want   int
+	}{
+		{http.MethodGet, "/healthz", "", http.StatusNoContent},
+		{http.MethodGet, "/metrics", "", http.StatusUnauthorized},
+		{http.MethodGet, "/metrics", "read-key", http.StatusNoContent},
+		{http.MethodPost, "/graphql", "read-key", http.StatusNoContent},
+		{http.MethodPost, "/admin/reload", "read-key", http.StatusForbidden},
+		{http.MethodPost, "/admin/reload", "admin-key", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.key != "" {
+			req.Header.Set("X-API-Key", tt.key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s %s with %q: status %d, want %d", tt.method, tt.path, tt.key, rec.Code, tt.want)
+		}
+	}
+}
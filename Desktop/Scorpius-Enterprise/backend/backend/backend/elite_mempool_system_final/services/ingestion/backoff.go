@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	reconnectBackoff = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_reconnect_backoff_seconds",
+			Help: "Current reconnect delay for an endpoint, zero after a successful connection",
+		},
+		[]string{"chain", "endpoint"},
+	)
+
+	reconnectAttempts = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_reconnect_attempts_total",
+			Help: "Failed connection attempts that scheduled a backoff",
+		},
+		[]string{"chain", "endpoint"},
+	)
+)
+
+// endpointBackoff schedules reconnects per endpoint with exponential backoff
+// and equal jitter, so a dead endpoint is retried less and less often while
+// a flapping one recovers as soon as it connects again
+type endpointBackoff struct {
+	chain    string
+	base     time.Duration
+	max      time.Duration
+	mu       sync.Mutex
+	failures map[string]int
+	retryAt  map[string]time.Time
+}
+
+func newEndpointBackoff(chain string, base, max time.Duration) *endpointBackoff {
+	if base <= 0 {
+		base = time.Second
+	}
+	if max < base {
+		max = base
+	}
+	return &endpointBackoff{
+		chain:    chain,
+		base:     base,
+		max:      max,
+		failures: make(map[string]int),
+		retryAt:  make(map[string]time.Time),
+	}
+}
+
+// Failure records a failed attempt against endpoint and returns the delay before it is retried
+func (b *endpointBackoff) Failure(endpoint string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delay := b.base
+	for i := 0; i < b.failures[endpoint] && delay < b.max; i++ {
+		delay *= 2
+	}
+	if delay > b.max {
+		delay = b.max
+	}
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+
+	b.failures[endpoint]++
+	b.retryAt[endpoint] = time.Now().Add(delay)
+
+	reconnectAttempts.WithLabelValues(b.chain, endpointLabel(endpoint)).Inc()
+	reconnectBackoff.WithLabelValues(b.chain, endpointLabel(endpoint)).Set(delay.Seconds())
+	return delay
+}
+
+// Reset clears the backoff for endpoint after a successful connection
+func (b *endpointBackoff) Reset(endpoint string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures[endpoint] == 0 {
+		return
+	}
+	delete(b.failures, endpoint)
+	delete(b.retryAt, endpoint)
+	reconnectBackoff.WithLabelValues(b.chain, endpointLabel(endpoint)).Set(0)
+}
+
+// Wait returns how long to hold off before endpoint may be dialed again
+func (b *endpointBackoff) Wait(endpoint string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return time.Until(b.retryAt[endpoint])
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errBeaconNotFound is returned for resources the beacon node does not
+// have, such as the sidecars of a missed slot
+var errBeaconNotFound = fmt.Errorf("not found")
+
+// beaconClient reads the standard beacon node API (/eth/v1/...) of a
+// consensus client
+type beaconClient struct {
+	url    string
+	client *http.Client
+
+	mu     sync.Mutex
+	timing *beaconTiming
+}
+
+// beaconTiming is a beacon chain's slot schedule
+type beaconTiming struct {
+	genesisTime    int64
+	secondsPerSlot int64
+	slotsPerEpoch  uint64
+}
+
+// slotStart returns when a slot begins
+func (t *beaconTiming) slotStart(slot uint64) time.Time {
+	return time.Unix(t.genesisTime+int64(slot)*t.secondsPerSlot, 0)
+}
+
+func newBeaconClient(baseURL string, timeout time.Duration) *beaconClient {
+	registerSecrets(baseURL)
+	return &beaconClient{url: strings.TrimSuffix(baseURL, "/"), client: &http.Client{Timeout: timeout}}
+}
+
+// get decodes the data field of the response to a GET of path
+func (b *beaconClient) get(ctx context.Context, path string, data interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url+path, nil)
+	if err != nil {
+		return fmt.Errorf("invalid beacon endpoint")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		// The url.Error would repeat the endpoint, API key included
+		if urlErr, ok := err.(*url.Error); ok {
+			return fmt.Errorf("%s: %v", path, urlErr.Err)
+		}
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return errBeaconNotFound
+	default:
+		return fmt.Errorf("%s: unexpected status %s", path, resp.Status)
+	}
+
+	envelope := struct {
+		Data interface{} `json:"data"`
+	}{Data: data}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("%s: failed to decode response: %v", path, err)
+	}
+	return nil
+}
+
+// Timing returns the chain's slot schedule, read from the node once
+func (b *beaconClient) Timing(ctx context.Context) (*beaconTiming, error) {
+	b.mu.Lock()
+	timing := b.timing
+	b.mu.Unlock()
+	if timing != nil {
+		return timing, nil
+	}
+
+	var genesis struct {
+		GenesisTime string `json:"genesis_time"`
+	}
+	if err := b.get(ctx, "/eth/v1/beacon/genesis", &genesis); err != nil {
+		return nil, err
+	}
+	var spec struct {
+		SecondsPerSlot string `json:"SECONDS_PER_SLOT"`
+		SlotsPerEpoch  string `json:"SLOTS_PER_EPOCH"`
+	}
+	if err := b.get(ctx, "/eth/v1/config/spec", &spec); err != nil {
+		return nil, err
+	}
+	timing = &beaconTiming{}
+	timing.genesisTime, _ = strconv.ParseInt(genesis.GenesisTime, 10, 64)
+	timing.secondsPerSlot, _ = strconv.ParseInt(spec.SecondsPerSlot, 10, 64)
+	timing.slotsPerEpoch, _ = strconv.ParseUint(spec.SlotsPerEpoch, 10, 64)
+	if timing.genesisTime == 0 || timing.secondsPerSlot <= 0 || timing.slotsPerEpoch == 0 {
+		return nil, fmt.Errorf("beacon node returned no genesis time or slot schedule")
+	}
+
+	b.mu.Lock()
+	b.timing = timing
+	b.mu.Unlock()
+	return timing, nil
+}
+
+// Slot returns the slot whose block carries an execution payload with the
+// given timestamp
+func (b *beaconClient) Slot(ctx context.Context, timestamp int64) (uint64, error) {
+	timing, err := b.Timing(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if timestamp < timing.genesisTime {
+		return 0, fmt.Errorf("timestamp %d is before genesis", timestamp)
+	}
+	return uint64((timestamp - timing.genesisTime) / timing.secondsPerSlot), nil
+}
+
+// ProposerDuties returns the validators scheduled to propose in an epoch,
+// by slot. Nodes serve the current epoch and, on recent clients, the next.
+func (b *beaconClient) ProposerDuties(ctx context.Context, epoch uint64) ([]ProposerDuty, error) {
+	var duties []struct {
+		Pubkey         string `json:"pubkey"`
+		ValidatorIndex uint64 `json:"validator_index,string"`
+		Slot           uint64 `json:"slot,string"`
+	}
+	if err := b.get(ctx, fmt.Sprintf("/eth/v1/validator/duties/proposer/%d", epoch), &duties); err != nil {
+		return nil, err
+	}
+	converted := make([]ProposerDuty, len(duties))
+	for i, duty := range duties {
+		converted[i] = ProposerDuty{Slot: duty.Slot, ValidatorIndex: duty.ValidatorIndex, Pubkey: strings.ToLower(duty.Pubkey)}
+	}
+	return converted, nil
+}
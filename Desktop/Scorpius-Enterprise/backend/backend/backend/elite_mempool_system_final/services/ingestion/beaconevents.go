@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Beacon event kinds
+const (
+	BeaconEventHead           = "head"
+	BeaconEventFinalized      = "finalized_checkpoint"
+	BeaconEventProposerDuties = "proposer_duties"
+)
+
+var (
+	beaconEvents = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_beacon_events_total",
+			Help: "Consensus layer events published, by kind and status",
+		},
+		[]string{"chain", "kind", "status"},
+	)
+
+	beaconHeadDelay = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "scorpius_beacon_head_delay_seconds",
+			Help:    "Time from the start of a slot to its head event",
+			Buckets: []float64{0.5, 1, 2, 3, 4, 6, 8, 12, 24},
+		},
+		[]string{"chain"},
+	)
+)
+
+// BeaconEventConfig configures consensus layer event ingestion
+type BeaconEventConfig struct {
+	Enabled bool
+	Topic   string
+}
+
+// loadBeaconEventConfig reads BEACON_EVENTS_* settings
+func loadBeaconEventConfig() BeaconEventConfig {
+	return BeaconEventConfig{
+		Enabled: getEnvBool("BEACON_EVENTS", false),
+		Topic:   getEnvOrDefault("BEACON_EVENTS_TOPIC", "beacon_events"),
+	}
+}
+
+// ProposerDuty is a validator's turn to propose the block of a slot
+type ProposerDuty struct {
+	Slot           uint64 `json:"slot"`
+	ValidatorIndex uint64 `json:"validator_index"`
+	Pubkey         string `json:"pubkey"`
+}
+
+// BeaconEvent is published to the beacon events topic. Slot boundaries are
+// carried as SlotStart so mempool timestamps can be placed within a slot.
+type BeaconEvent struct {
+	Chain               string    `json:"chain"`
+	ChainID             int64     `json:"chain_id"`
+	Kind                string    `json:"kind"`
+	Slot                uint64    `json:"slot"`
+	Epoch               uint64    `json:"epoch"`
+	SlotStart           time.Time `json:"slot_start"`
+	BlockRoot           string    `json:"block_root,omitempty"`
+	StateRoot           string    `json:"state_root,omitempty"`
+	EpochTransition     bool      `json:"epoch_transition,omitempty"`
+	ExecutionOptimistic bool      `json:"execution_optimistic,omitempty"`
+	// Lookahead marks proposer duties for the next epoch, which clients
+	// before Fulu may still reshuffle
+	Lookahead  bool           `json:"lookahead,omitempty"`
+	Duties     []ProposerDuty `json:"duties,omitempty"`
+	ReceivedAt time.Time      `json:"received_at"`
+}
+
+// beaconEventSource follows a beacon node's event stream
+// (/eth/v1/events) for new heads and finalized checkpoints, and fetches
+// the proposer duties of each epoch as it begins, publishing all of them
+// to the beacon events topic. It runs alongside a chain's monitor, against
+// <CHAIN>_BEACON_URL.
+type beaconEventSource struct {
+	monitor *ChainMonitor
+	beacon  *beaconClient
+	topic   string
+	// stream has no overall timeout: the event stream is long-lived
+	stream *http.Client
+	// dutiesEpoch is the last epoch whose proposer duties were published
+	dutiesEpoch uint64
+}
+
+func newBeaconEventSource(monitor *ChainMonitor, topic string) *beaconEventSource {
+	return &beaconEventSource{
+		monitor: monitor,
+		beacon:  newBeaconClient(monitor.options.BeaconURL, 10*time.Second),
+		topic:   topic,
+		stream:  &http.Client{},
+	}
+}
+
+// startBeaconEvents follows the beacon event stream when BEACON_EVENTS is set
+func (cm *ChainMonitor) startBeaconEvents() {
+	if cm.beaconEvents != nil {
+		go cm.beaconEvents.run()
+	}
+}
+
+// run consumes the event stream until the monitor stops, reconnecting on errors
+func (s *beaconEventSource) run() {
+	cm := s.monitor
+	for {
+		if err := s.consume(cm.ctx); err != nil && cm.ctx.Err() == nil {
+			cm.logger.Warn("beacon event stream failed", "endpoint", displayEndpoint(s.beacon.url), "error", err)
+		}
+		if !cm.sleep(5 * time.Second) {
+			return
+		}
+	}
+}
+
+// consume reads events from a single stream connection
+func (s *beaconEventSource) consume(ctx context.Context) error {
+	timing, err := s.beacon.Timing(ctx)
+	if err != nil {
+		return err
+	}
+
+	events := s.beacon.url + "/eth/v1/events?topics=" + BeaconEventHead + "," + BeaconEventFinalized
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, events, nil)
+	if err != nil {
+		return fmt.Errorf("invalid beacon endpoint")
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := s.stream.Do(req)
+	if err != nil {
+		// The url.Error would repeat the endpoint, API key included
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	// Duties of the epoch in progress are published on connect, as its
+	// transition may have been missed
+	if now := time.Now().Unix(); now > timing.genesisTime {
+		epoch := uint64(now-timing.genesisTime) / uint64(timing.secondsPerSlot) / timing.slotsPerEpoch
+		if epoch > s.dutiesEpoch {
+			s.publishDuties(ctx, timing, epoch)
+		}
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	var kind string
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case line == "":
+			// A blank line terminates the event
+			if data.Len() > 0 {
+				s.handleEvent(ctx, timing, kind, data.String())
+			}
+			kind = ""
+			data.Reset()
+		case strings.HasPrefix(line, "event:"):
+			kind = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("stream read failed: %v", err)
+	}
+	return fmt.Errorf("stream closed by server")
+}
+
+// handleEvent publishes a head or finalized checkpoint event, and the
+// proposer duties of the epoch a head begins
+func (s *beaconEventSource) handleEvent(ctx context.Context, timing *beaconTiming, kind, payload string) {
+	cm := s.monitor
+	var raw struct {
+		Slot                string `json:"slot"`
+		Epoch               string `json:"epoch"`
+		Block               string `json:"block"`
+		State               string `json:"state"`
+		EpochTransition     bool   `json:"epoch_transition"`
+		ExecutionOptimistic bool   `json:"execution_optimistic"`
+	}
+	if err := json.Unmarshal([]byte(payload), &raw); err != nil {
+		beaconEvents.WithLabelValues(cm.chainName, kind, "invalid").Inc()
+		cm.logger.Warn("failed to decode beacon event", "kind", kind, "error", err)
+		return
+	}
+
+	event := BeaconEvent{
+		Chain:               cm.chainName,
+		ChainID:             cm.chainID,
+		Kind:                kind,
+		BlockRoot:           raw.Block,
+		StateRoot:           raw.State,
+		EpochTransition:     raw.EpochTransition,
+		ExecutionOptimistic: raw.ExecutionOptimistic,
+		ReceivedAt:          time.Now(),
+	}
+	switch kind {
+	case BeaconEventHead:
+		event.Slot, _ = strconv.ParseUint(raw.Slot, 10, 64)
+		event.Epoch = event.Slot / timing.slotsPerEpoch
+	case BeaconEventFinalized:
+		// A checkpoint is the first slot of its epoch
+		event.Epoch, _ = strconv.ParseUint(raw.Epoch, 10, 64)
+		event.Slot = event.Epoch * timing.slotsPerEpoch
+	default:
+		return
+	}
+	event.SlotStart = timing.slotStart(event.Slot)
+	if kind == BeaconEventHead {
+		beaconHeadDelay.WithLabelValues(cm.chainName).Observe(event.ReceivedAt.Sub(event.SlotStart).Seconds())
+	}
+	s.publish(event, raw.Block)
+
+	if kind == BeaconEventHead && event.Epoch > s.dutiesEpoch {
+		s.publishDuties(ctx, timing, event.Epoch)
+	}
+}
+
+// publishDuties publishes the proposer duties of an epoch and, where the
+// node serves them, the lookahead for the next
+func (s *beaconEventSource) publishDuties(ctx context.Context, timing *beaconTiming, epoch uint64) {
+	cm := s.monitor
+	for _, next := range []uint64{epoch, epoch + 1} {
+		duties, err := s.beacon.ProposerDuties(ctx, next)
+		if err != nil {
+			if next == epoch {
+				beaconEvents.WithLabelValues(cm.chainName, BeaconEventProposerDuties, "error").Inc()
+				cm.logger.Warn("failed to fetch proposer duties", "epoch", next, "error", err)
+			}
+			return
+		}
+
+		slot := next * timing.slotsPerEpoch
+		s.publish(BeaconEvent{
+			Chain:      cm.chainName,
+			ChainID:    cm.chainID,
+			Kind:       BeaconEventProposerDuties,
+			Slot:       slot,
+			Epoch:      next,
+			SlotStart:  timing.slotStart(slot),
+			Lookahead:  next > epoch,
+			Duties:     duties,
+			ReceivedAt: time.Now(),
+		}, fmt.Sprintf("%d", next))
+	}
+	s.dutiesEpoch = epoch
+}
+
+// publish produces an event to the beacon events topic
+func (s *beaconEventSource) publish(event BeaconEvent, key string) {
+	cm := s.monitor
+	data, err := json.Marshal(event)
+	if err != nil {
+		cm.logger.Error("failed to marshal beacon event", "kind", event.Kind, "error", err)
+		return
+	}
+
+	topic := expandTopic(s.topic, event.Chain, event.ChainID, cm.family)
+	headers := map[string]string{
+		"chain_id":   fmt.Sprintf("%d", event.ChainID),
+		"chain_name": event.Chain,
+		"kind":       event.Kind,
+		"format":     FormatJSON,
+	}
+	if err := cm.sink.Publish(cm.ctx, topic, []byte(key), data, headers); err != nil {
+		beaconEvents.WithLabelValues(cm.chainName, event.Kind, "failed").Inc()
+		cm.logger.Warn("failed to publish beacon event", "kind", event.Kind, "slot", event.Slot, "error", err)
+		return
+	}
+	beaconEvents.WithLabelValues(cm.chainName, event.Kind, "success").Inc()
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/go-zeromq/zmq4"
+)
+
+// UTXOInput is a transaction input in the UTXO schema variant
+type UTXOInput struct {
+	PrevTxID  string   `json:"prev_txid"`
+	PrevIndex uint32   `json:"prev_index"`
+	ScriptSig string   `json:"script_sig,omitempty"`
+	Sequence  uint32   `json:"sequence"`
+	Witness   []string `json:"witness,omitempty"`
+}
+
+// UTXOOutput is a transaction output in the UTXO schema variant
+type UTXOOutput struct {
+	Index        uint32 `json:"index"`
+	Value        int64  `json:"value"`
+	ScriptPubKey string `json:"script_pubkey"`
+	ScriptType   string `json:"script_type"`
+	Address      string `json:"address,omitempty"`
+}
+
+// BitcoinMonitor ingests mempool transactions from bitcoind's ZMQ publisher
+// (-zmqpubrawtx / -zmqpubhashtx). Endpoints are ZMQ addresses such as tcp://127.0.0.1:28332.
+type BitcoinMonitor struct {
+	*ChainMonitor
+}
+
+// NewBitcoinMonitor creates a Bitcoin monitor sharing the chain monitor's endpoint management
+func NewBitcoinMonitor(chain ChainInfo, endpoints []string, options ChainOptions, sink Sink, cache Cache, alerter *Alerter) *BitcoinMonitor {
+	bm := &BitcoinMonitor{
+		ChainMonitor: NewChainMonitor(chain.Name, chain.ChainID, endpoints, options, sink, cache, alerter),
+	}
+	bm.family = chain.Family
+	bm.streamer = bm
+	return bm
+}
+
+// stream subscribes to the configured ZMQ topics and publishes every transaction received
+func (bm *BitcoinMonitor) stream(endpoint string) error {
+	sub := zmq4.NewSub(bm.ctx)
+	defer sub.Close()
+
+	start := time.Now()
+	if err := sub.Dial(endpoint); err != nil {
+		bm.updateHealthScore(endpoint, 0.0)
+		return fmt.Errorf("failed to connect to %s: %v", endpoint, err)
+	}
+	latency := time.Since(start)
+	connectionLatency.WithLabelValues(bm.chainName, endpointLabel(endpoint)).Observe(latency.Seconds())
+	bm.recordLatency(endpoint, latency)
+
+	topics := bm.options.ZMQTopics
+	if len(topics) == 0 {
+		topics = []string{"rawtx"}
+	}
+	for _, topic := range topics {
+		if err := sub.SetOption(zmq4.OptionSubscribe, topic); err != nil {
+			return fmt.Errorf("failed to subscribe to %s: %v", topic, err)
+		}
+	}
+
+	bm.backoff.Reset(endpoint)
+	bm.breaker.Success(endpoint)
+	bm.beginWarmup()
+
+	for {
+		msg, err := sub.Recv()
+		if err != nil {
+			if bm.ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("error reading message: %v", err)
+		}
+
+		if len(msg.Frames) < 2 {
+			continue
+		}
+
+		if err := bm.handleZMQ(string(msg.Frames[0]), msg.Frames[1]); err != nil {
+			bm.logger.Error("failed to handle message", "error", err)
+		}
+
+		bm.recordActivity(endpoint)
+	}
+}
+
+// handleZMQ decodes a single ZMQ notification
+func (bm *BitcoinMonitor) handleZMQ(topic string, body []byte) error {
+	tx := Transaction{
+		ChainID:     bm.chainID,
+		Chain:       bm.chainName,
+		ChainFamily: bm.family,
+		Status:      "pending",
+		Timestamp:   time.Now().Unix(),
+	}
+
+	switch topic {
+	case "rawtx":
+		decoded, err := decodeBitcoinTx(body, bm.options.Network)
+		if err != nil {
+			return err
+		}
+		tx.Hash = decoded.txid
+		tx.Nonce = strconv.FormatUint(uint64(decoded.lockTime), 10)
+		tx.Type = strconv.FormatInt(int64(decoded.version), 10)
+		tx.Inputs = decoded.inputs
+		tx.Outputs = decoded.outputs
+
+		var total int64
+		for _, output := range decoded.outputs {
+			total += output.Value
+		}
+		tx.Value = strconv.FormatInt(total, 10)
+		tx.Raw, _ = json.Marshal(map[string]string{"hex": hex.EncodeToString(body)})
+	case "hashtx":
+		if len(body) != 32 {
+			return fmt.Errorf("invalid hashtx length %d", len(body))
+		}
+		tx.Hash = hex.EncodeToString(body)
+	default:
+		return nil
+	}
+
+	return bm.publishTransaction(tx)
+}
+
+// bitcoinTx is a decoded Bitcoin transaction
+type bitcoinTx struct {
+	txid     string
+	version  int32
+	lockTime uint32
+	inputs   []UTXOInput
+	outputs  []UTXOOutput
+}
+
+var errShortTx = errors.New("truncated bitcoin transaction")
+
+// Smallest serialized input (outpoint, empty script, sequence) and output
+// (value, empty script), bounding counts read from untrusted payloads
+const (
+	minTxInputSize  = 36 + 1 + 4
+	minTxOutputSize = 8 + 1
+)
+
+// decodeBitcoinTx parses a serialized transaction, including BIP144 witness data
+func decodeBitcoinTx(raw []byte, network string) (*bitcoinTx, error) {
+	r := bytes.NewReader(raw)
+	tx := &bitcoinTx{}
+
+	var version int32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, errShortTx
+	}
+	tx.version = version
+
+	inputCount, err := readVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+
+	segwit := false
+	if inputCount == 0 {
+		flag, err := r.ReadByte()
+		if err != nil || flag != 0x01 {
+			return nil, fmt.Errorf("invalid segwit marker")
+		}
+		segwit = true
+		if inputCount, err = readVarInt(r); err != nil {
+			return nil, err
+		}
+	}
+
+	// The txid covers the legacy serialization only, so rebuild it alongside parsing
+	var legacy bytes.Buffer
+	legacy.Write(raw[:4])
+	writeVarInt(&legacy, inputCount)
+
+	if inputCount > uint64(r.Len()/minTxInputSize) {
+		return nil, errShortTx
+	}
+	tx.inputs = make([]UTXOInput, 0, inputCount)
+	for i := uint64(0); i < inputCount; i++ {
+		start := len(raw) - r.Len()
+
+		prev := make([]byte, 36)
+		if _, err := io.ReadFull(r, prev); err != nil {
+			return nil, errShortTx
+		}
+		script, err := readVarBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		var sequence uint32
+		if err := binary.Read(r, binary.LittleEndian, &sequence); err != nil {
+			return nil, errShortTx
+		}
+
+		legacy.Write(raw[start : len(raw)-r.Len()])
+		tx.inputs = append(tx.inputs, UTXOInput{
+			PrevTxID:  hex.EncodeToString(reverseBytes(prev[:32])),
+			PrevIndex: binary.LittleEndian.Uint32(prev[32:]),
+			ScriptSig: hex.EncodeToString(script),
+			Sequence:  sequence,
+		})
+	}
+
+	outputsStart := len(raw) - r.Len()
+	outputCount, err := readVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if outputCount > uint64(r.Len()/minTxOutputSize) {
+		return nil, errShortTx
+	}
+	tx.outputs = make([]UTXOOutput, 0, outputCount)
+	for i := uint64(0); i < outputCount; i++ {
+		var value int64
+		if err := binary.Read(r, binary.LittleEndian, &value); err != nil {
+			return nil, errShortTx
+		}
+		script, err := readVarBytes(r)
+		if err != nil {
+			return nil, err
+		}
+
+		scriptType, address := classifyScript(script, network)
+		tx.outputs = append(tx.outputs, UTXOOutput{
+			Index:        uint32(i),
+			Value:        value,
+			ScriptPubKey: hex.EncodeToString(script),
+			ScriptType:   scriptType,
+			Address:      address,
+		})
+	}
+	legacy.Write(raw[outputsStart : len(raw)-r.Len()])
+
+	if segwit {
+		for i := range tx.inputs {
+			items, err := readVarInt(r)
+			if err != nil {
+				return nil, err
+			}
+			for j := uint64(0); j < items; j++ {
+				item, err := readVarBytes(r)
+				if err != nil {
+					return nil, err
+				}
+				tx.inputs[i].Witness = append(tx.inputs[i].Witness, hex.EncodeToString(item))
+			}
+		}
+	}
+
+	if err := binary.Read(r, binary.LittleEndian, &tx.lockTime); err != nil {
+		return nil, errShortTx
+	}
+	legacy.Write(raw[len(raw)-4:])
+
+	first := sha256.Sum256(legacy.Bytes())
+	second := sha256.Sum256(first[:])
+	tx.txid = hex.EncodeToString(reverseBytes(second[:]))
+
+	return tx, nil
+}
+
+func readVarInt(r *bytes.Reader) (uint64, error) {
+	prefix, err := r.ReadByte()
+	if err != nil {
+		return 0, errShortTx
+	}
+
+	var size int
+	switch prefix {
+	case 0xfd:
+		size = 2
+	case 0xfe:
+		size = 4
+	case 0xff:
+		size = 8
+	default:
+		return uint64(prefix), nil
+	}
+
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(r, buf[:size]); err != nil {
+		return 0, errShortTx
+	}
+	return binary.LittleEndian.Uint64(buf), nil
+}
+
+func writeVarInt(w *bytes.Buffer, n uint64) {
+	buf := make([]byte, 9)
+	switch {
+	case n < 0xfd:
+		w.WriteByte(byte(n))
+	case n <= 0xffff:
+		buf[0] = 0xfd
+		binary.LittleEndian.PutUint16(buf[1:], uint16(n))
+		w.Write(buf[:3])
+	case n <= 0xffffffff:
+		buf[0] = 0xfe
+		binary.LittleEndian.PutUint32(buf[1:], uint32(n))
+		w.Write(buf[:5])
+	default:
+		buf[0] = 0xff
+		binary.LittleEndian.PutUint64(buf[1:], n)
+		w.Write(buf)
+	}
+}
+
+func readVarBytes(r *bytes.Reader) ([]byte, error) {
+	n, err := readVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(r.Len()) {
+		return nil, errShortTx
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, errShortTx
+	}
+	return buf, nil
+}
+
+func reverseBytes(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[i] = b[len(b)-1-i]
+	}
+	return out
+}
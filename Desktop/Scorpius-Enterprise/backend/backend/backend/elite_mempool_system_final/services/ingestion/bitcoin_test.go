@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// genesisCoinbase is the coinbase transaction of the Bitcoin genesis block
+const genesisCoinbase = "01000000010000000000000000000000000000000000000000000000000000000000000000ffffffff4d04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73ffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000"
+
+// segwitSpend pays a P2WPKH output with a two-item witness; segwitLegacy is
+// the same transaction serialized without it
+const (
+	segwitLegacy = "02000000" +
+		"01" + "1111111111111111111111111111111111111111111111111111111111111111" + "01000000" + "00" + "ffffffff" +
+		"01" + "50c3000000000000" + "16" + "0014751e76e8199196d454941c45d1b3a323f1433bd6" +
+		"00000000"
+	segwitSpend = "02000000" + "0001" +
+		"01" + "1111111111111111111111111111111111111111111111111111111111111111" + "01000000" + "00" + "ffffffff" +
+		"01" + "50c3000000000000" + "16" + "0014751e76e8199196d454941c45d1b3a323f1433bd6" +
+		"02" + "03" + "aabbcc" + "02" + "ddee" +
+		"00000000"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDecodeBitcoinTx(t *testing.T) {
+	tests := []struct {
+		name       string
+		raw        string
+		txid       string
+		inputs     int
+		witness    []string
+		value      int64
+		scriptType string
+		address    string
+	}{
+		{
+			name:       "genesis coinbase",
+			raw:        genesisCoinbase,
+			txid:       "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+			inputs:     1,
+			value:      5000000000,
+			scriptType: "nonstandard",
+		},
+		{
+			name:       "segwit spend",
+			raw:        segwitSpend,
+			inputs:     1,
+			witness:    []string{"aabbcc", "ddee"},
+			value:      50000,
+			scriptType: "p2wpkh",
+			address:    "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := decodeBitcoinTx(mustHex(t, tt.raw), "mainnet")
+			if err != nil {
+				t.Fatalf("decodeBitcoinTx() error = %v", err)
+			}
+			if tt.txid != "" && tx.txid != tt.txid {
+				t.Errorf("txid = %s, want %s", tx.txid, tt.txid)
+			}
+			if len(tx.inputs) != tt.inputs || len(tx.outputs) != 1 {
+				t.Fatalf("got %d inputs and %d outputs", len(tx.inputs), len(tx.outputs))
+			}
+			if len(tt.witness) > 0 && !reflect.DeepEqual(tx.inputs[0].Witness, tt.witness) {
+				t.Errorf("witness = %v, want %v", tx.inputs[0].Witness, tt.witness)
+			}
+			output := tx.outputs[0]
+			if output.Value != tt.value || output.ScriptType != tt.scriptType || output.Address != tt.address {
+				t.Errorf("output = %+v", output)
+			}
+		})
+	}
+}
+
+func TestDecodeBitcoinTxSegwitTxid(t *testing.T) {
+	// The txid excludes the witness, so both serializations share it
+	segwit, err := decodeBitcoinTx(mustHex(t, segwitSpend), "mainnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := decodeBitcoinTx(mustHex(t, segwitLegacy), "mainnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if segwit.txid != legacy.txid {
+		t.Errorf("segwit txid %s, legacy txid %s", segwit.txid, legacy.txid)
+	}
+}
+
+func TestDecodeBitcoinTxMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"empty", ""},
+		{"bad segwit flag", "02000000" + "0002"},
+		{"input count past the end", "01000000" + "ff" + "ffffffffffffffff"},
+		{"output count past the end", genesisCoinbase[:8+2+72+2+154+8] + "fdffff"},
+		{"script length past the end", "01000000" + "01" + strings.Repeat("00", 36) + "feffffff7f"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeBitcoinTx(mustHex(t, tt.raw), "mainnet"); err == nil {
+				t.Error("decodeBitcoinTx() succeeded")
+			}
+		})
+	}
+
+	// Every truncation of a valid transaction fails cleanly
+	for _, raw := range []string{genesisCoinbase, segwitSpend} {
+		full := mustHex(t, raw)
+		for n := 0; n < len(full); n++ {
+			if _, err := decodeBitcoinTx(full[:n], "mainnet"); err == nil {
+				t.Errorf("decodeBitcoinTx() of %d/%d bytes succeeded", n, len(full))
+			}
+		}
+	}
+}
+
+func TestClassifyScript(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     string
+		network    string
+		scriptType string
+		address    string
+	}{
+		{"p2pkh", "76a91462e907b15cbf27d5425399ebf6f0fb50ebb88f1888ac", "mainnet", "p2pkh", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"},
+		{"p2wpkh", "0014751e76e8199196d454941c45d1b3a323f1433bd6", "mainnet", "p2wpkh", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{"p2wpkh testnet", "0014751e76e8199196d454941c45d1b3a323f1433bd6", "testnet", "p2wpkh", "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"},
+		{"p2tr", "5120a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c", "mainnet", "p2tr", "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"},
+		{"nulldata", "6a0b68656c6c6f20776f726c64", "mainnet", "nulldata", ""},
+		{"empty", "", "mainnet", "nonstandard", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scriptType, address := classifyScript(mustHex(t, tt.script), tt.network)
+			if scriptType != tt.scriptType || address != tt.address {
+				t.Errorf("classifyScript() = %s, %s, want %s, %s", scriptType, address, tt.scriptType, tt.address)
+			}
+		})
+	}
+}
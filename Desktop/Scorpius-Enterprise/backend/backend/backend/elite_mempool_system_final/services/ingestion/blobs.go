@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// blobGasPerBlob is the blob gas every blob consumes (EIP-4844 GAS_PER_BLOB)
+const blobGasPerBlob = 1 << 17
+
+// blobCommitmentVersionKZG prefixes versioned hashes of KZG commitments
+const blobCommitmentVersionKZG = 0x01
+
+var (
+	blobTransactions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_blob_transactions_total",
+			Help: "Blob-carrying transactions published to the blobs topic, by rollup and status",
+		},
+		[]string{"chain", "rollup", "status"},
+	)
+
+	blobSidecarFetches = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_blob_sidecar_fetches_total",
+			Help: "Blob sidecar fetches from the beacon API, by result",
+		},
+		[]string{"chain", "result"},
+	)
+)
+
+// knownBlobRollups maps the batch inboxes and rollup contracts that blob
+// transactions are sent to, on Ethereum mainnet, to rollup names
+var knownBlobRollups = map[string]string{
+	"0xff00000000000000000000000000000000000010": "optimism",
+	"0xff00000000000000000000000000000000008453": "base",
+	"0x6f54ca6f6ede96662024ffd61bfd18f3f4e34dff": "zora",
+	"0x1c479675ad559dc151f6ec7ed3fbf8cee79582b6": "arbitrum",
+	"0xc662c410c0ecf747543f5ba90660f6abebd9c8c4": "starknet",
+	"0x32400084c286cf3e17e7b677ea9583e60a000324": "zksync",
+	"0xa13baf47339d63b743e7da8741db5456dac1e556": "scroll",
+	"0xd19d4b5d358258f05d7b411e21a1460d11b0876f": "linea",
+}
+
+// BlobConfig configures blob transaction tracking
+type BlobConfig struct {
+	Enabled bool
+	Topic   string
+	// Rollups maps a rollup name to an address its blob transactions are
+	// sent to or from, extending knownBlobRollups
+	Rollups map[string]string
+}
+
+// loadBlobConfig reads BLOB_* settings
+func loadBlobConfig() BlobConfig {
+	return BlobConfig{
+		Enabled: getEnvBool("BLOB_TRACKING", false),
+		Topic:   getEnvOrDefault("BLOB_TOPIC", "blobs"),
+		Rollups: parseKeyValues(getEnv("BLOB_ROLLUPS")),
+	}
+}
+
+// BlobSidecar is the consensus layer half of a blob: its KZG commitment and
+// proof, and how much of the 128 KiB blob the rollup filled
+type BlobSidecar struct {
+	Index         uint64 `json:"index"`
+	VersionedHash string `json:"versioned_hash"`
+	KZGCommitment string `json:"kzg_commitment"`
+	KZGProof      string `json:"kzg_proof"`
+	UsedBytes     int    `json:"used_bytes"`
+}
+
+// BlobTransaction describes an EIP-4844 transaction and the blobs it carries
+type BlobTransaction struct {
+	Chain                string        `json:"chain"`
+	ChainID              int64         `json:"chain_id"`
+	Hash                 string        `json:"hash"`
+	From                 string        `json:"from"`
+	To                   string        `json:"to"`
+	Rollup               string        `json:"rollup,omitempty"`
+	Status               string        `json:"status"`
+	BlobCount            int           `json:"blob_count"`
+	BlobGas              uint64        `json:"blob_gas"`
+	MaxFeePerBlobGas     string        `json:"max_fee_per_blob_gas"`
+	BlobGasPrice         string        `json:"blob_gas_price,omitempty"`
+	MaxFeePerGas         string        `json:"max_fee_per_gas,omitempty"`
+	MaxPriorityFeePerGas string        `json:"max_priority_fee_per_gas,omitempty"`
+	VersionedHashes      []string      `json:"versioned_hashes"`
+	BlockNumber          uint64        `json:"block_number,omitempty"`
+	BlockHash            string        `json:"block_hash,omitempty"`
+	Slot                 uint64        `json:"slot,omitempty"`
+	Sidecars             []BlobSidecar `json:"sidecars,omitempty"`
+	DetectedAt           time.Time     `json:"detected_at"`
+}
+
+// BlobMonitor publishes a BlobTransaction for each pending type-3
+// transaction, named after the rollup posting it when the sender or
+// recipient is a known batch poster or inbox. Chains with <CHAIN>_BEACON_URL
+// also publish each blob transaction again once confirmed, with the
+// sidecars fetched from the beacon API (see blobSidecarFetcher).
+type BlobMonitor struct {
+	sinks   *chainSinks
+	topic   string
+	rollups map[string]string
+}
+
+// NewBlobMonitor creates a monitor publishing to sinks
+func NewBlobMonitor(sinks *chainSinks, config BlobConfig) *BlobMonitor {
+	rollups := make(map[string]string, len(knownBlobRollups)+len(config.Rollups))
+	for address, name := range knownBlobRollups {
+		rollups[address] = name
+	}
+	for name, address := range config.Rollups {
+		rollups[strings.ToLower(address)] = name
+	}
+	return &BlobMonitor{sinks: sinks, topic: config.Topic, rollups: rollups}
+}
+
+// Name returns the enricher name
+func (b *BlobMonitor) Name() string {
+	return "blobs"
+}
+
+// Enrich publishes pending blob transactions
+func (b *BlobMonitor) Enrich(tx *Transaction) {
+	if tx.ChainFamily != FamilyEVM || tx.Canary || tx.Status != "pending" || tx.Type != TxTypeBlob || len(tx.BlobVersionedHashes) == 0 {
+		return
+	}
+
+	event := BlobTransaction{
+		Chain:                tx.Chain,
+		ChainID:              tx.ChainID,
+		Hash:                 tx.Hash,
+		From:                 strings.ToLower(tx.From),
+		To:                   strings.ToLower(tx.To),
+		Status:               tx.Status,
+		BlobCount:            len(tx.BlobVersionedHashes),
+		BlobGas:              uint64(len(tx.BlobVersionedHashes)) * blobGasPerBlob,
+		MaxFeePerBlobGas:     tx.MaxFeePerBlobGas,
+		MaxFeePerGas:         tx.MaxFeePerGas,
+		MaxPriorityFeePerGas: tx.MaxPriorityFeePerGas,
+		VersionedHashes:      lowerAll(tx.BlobVersionedHashes),
+		DetectedAt:           time.Now(),
+	}
+	event.Rollup = b.rollup(event.From, event.To)
+	b.publish(event, tx.ChainFamily)
+}
+
+// rollup names the rollup a blob transaction belongs to, by recipient and then sender
+func (b *BlobMonitor) rollup(from, to string) string {
+	if name, ok := b.rollups[to]; ok {
+		return name
+	}
+	return b.rollups[from]
+}
+
+// publish produces a blob transaction to the blobs topic
+func (b *BlobMonitor) publish(event BlobTransaction, family string) {
+	rollup := event.Rollup
+	if rollup == "" {
+		rollup = "unknown"
+	}
+	blobTransactions.WithLabelValues(event.Chain, rollup, event.Status).Inc()
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("failed to marshal blob transaction", "chain", event.Chain, "tx_hash", event.Hash, "error", err)
+		return
+	}
+
+	topic := expandTopic(b.topic, event.Chain, event.ChainID, family)
+	headers := map[string]string{
+		"chain_id":   fmt.Sprintf("%d", event.ChainID),
+		"chain_name": event.Chain,
+		"format":     FormatJSON,
+	}
+	if err := b.sinks.Publish(context.Background(), event.Chain, topic, []byte(event.Hash), data, headers); err != nil {
+		slog.Warn("failed to publish blob transaction", "chain", event.Chain, "tx_hash", event.Hash, "error", err)
+	}
+}
+
+// beaconBlobSidecar is a blob sidecar as the beacon API returns it
+type beaconBlobSidecar struct {
+	Index         uint64 `json:"index,string"`
+	Blob          string `json:"blob"`
+	KZGCommitment string `json:"kzg_commitment"`
+	KZGProof      string `json:"kzg_proof"`
+}
+
+// blobSidecarFetcher is a blockHandler that fetches the sidecars of the
+// blob transactions in each confirmed block from the beacon node at
+// <CHAIN>_BEACON_URL and publishes the confirmed transactions with them.
+// Beacon nodes prune sidecars after about 18 days, so only blocks within
+// that window can be handled.
+type blobSidecarFetcher struct {
+	monitor *ChainMonitor
+	blobs   *BlobMonitor
+	beacon  *beaconClient
+}
+
+func newBlobSidecarFetcher(monitor *ChainMonitor, blobs *BlobMonitor) *blobSidecarFetcher {
+	return &blobSidecarFetcher{
+		monitor: monitor,
+		blobs:   blobs,
+		beacon:  newBeaconClient(monitor.options.BeaconURL, 30*time.Second),
+	}
+}
+
+// HandleBlock is the fetcher's blockHandler
+func (f *blobSidecarFetcher) HandleBlock(block *confirmedBlock) {
+	cm := f.monitor
+	var txs []*blockTx
+	for _, tx := range block.Transactions {
+		if tx.Type == TxTypeBlob && len(tx.BlobVersionedHashes) > 0 {
+			txs = append(txs, tx)
+		}
+	}
+	if len(txs) == 0 {
+		return
+	}
+
+	slot, err := f.beacon.Slot(cm.ctx, block.Timestamp)
+	if err != nil {
+		blobSidecarFetches.WithLabelValues(cm.chainName, "error").Inc()
+		cm.logger.Warn("failed to find the slot of a block with blobs", "block", block.Number, "error", err)
+		return
+	}
+	var sidecars []beaconBlobSidecar
+	err = f.beacon.get(cm.ctx, fmt.Sprintf("/eth/v1/beacon/blob_sidecars/%d", slot), &sidecars)
+	switch {
+	case err == errBeaconNotFound:
+		blobSidecarFetches.WithLabelValues(cm.chainName, "missing").Inc()
+		cm.logger.Warn("beacon node has no blob sidecars for block", "block", block.Number, "slot", slot)
+	case err != nil:
+		blobSidecarFetches.WithLabelValues(cm.chainName, "error").Inc()
+		cm.logger.Warn("failed to fetch blob sidecars", "block", block.Number, "slot", slot, "error", err)
+	default:
+		blobSidecarFetches.WithLabelValues(cm.chainName, "success").Inc()
+	}
+
+	// Sidecars are matched to transactions by the versioned hash of their commitment
+	byHash := make(map[string]BlobSidecar, len(sidecars))
+	for _, sidecar := range sidecars {
+		converted, err := convertBlobSidecar(sidecar)
+		if err != nil {
+			cm.logger.Warn("invalid blob sidecar", "slot", slot, "index", sidecar.Index, "error", err)
+			continue
+		}
+		byHash[converted.VersionedHash] = converted
+	}
+
+	for _, tx := range txs {
+		event := BlobTransaction{
+			Chain:            cm.chainName,
+			ChainID:          cm.chainID,
+			Hash:             tx.Hash,
+			From:             tx.From,
+			To:               tx.To,
+			Status:           "confirmed",
+			BlobCount:        len(tx.BlobVersionedHashes),
+			BlobGas:          uint64(len(tx.BlobVersionedHashes)) * blobGasPerBlob,
+			MaxFeePerBlobGas: tx.MaxFeePerBlobGas,
+			BlobGasPrice:     tx.BlobGasPrice,
+			VersionedHashes:  lowerAll(tx.BlobVersionedHashes),
+			BlockNumber:      block.Number,
+			BlockHash:        block.Hash,
+			Slot:             slot,
+			DetectedAt:       time.Now(),
+		}
+		event.Rollup = f.blobs.rollup(tx.From, tx.To)
+		for _, hash := range event.VersionedHashes {
+			if sidecar, ok := byHash[hash]; ok {
+				event.Sidecars = append(event.Sidecars, sidecar)
+			}
+		}
+		f.blobs.publish(event, cm.family)
+	}
+}
+
+// convertBlobSidecar derives a sidecar's versioned hash (EIP-4844
+// kzg_to_versioned_hash) and the length of its blob without trailing zero
+// padding
+func convertBlobSidecar(sidecar beaconBlobSidecar) (BlobSidecar, error) {
+	commitment, err := hex.DecodeString(strings.TrimPrefix(sidecar.KZGCommitment, "0x"))
+	if err != nil || len(commitment) != 48 {
+		return BlobSidecar{}, fmt.Errorf("invalid kzg commitment")
+	}
+	versionedHash := sha256.Sum256(commitment)
+	versionedHash[0] = blobCommitmentVersionKZG
+
+	return BlobSidecar{
+		Index:         sidecar.Index,
+		VersionedHash: "0x" + hex.EncodeToString(versionedHash[:]),
+		KZGCommitment: strings.ToLower(sidecar.KZGCommitment),
+		KZGProof:      strings.ToLower(sidecar.KZGProof),
+		UsedBytes:     len(bytes.TrimRight(common.FromHex(sidecar.Blob), "\x00")),
+	}, nil
+}
+
+// lowerAll returns values in lower case
+func lowerAll(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, value := range values {
+		lowered[i] = strings.ToLower(value)
+	}
+	return lowered
+}
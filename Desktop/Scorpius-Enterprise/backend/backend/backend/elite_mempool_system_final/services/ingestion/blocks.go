@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// maxBlockCatchUp bounds how many blocks one poll processes; a tracker that
+// falls further behind skips ahead to the newest confirmed block
+const maxBlockCatchUp = 16
+
+// reorgWindow is how many recent head blocks the tracker remembers to
+// measure reorgs against
+const reorgWindow = 64
+
+var (
+	blocksTracked = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_blocks_tracked_total",
+			Help: "Confirmed blocks fetched by the block tracker, by result",
+		},
+		[]string{"chain", "result"},
+	)
+
+	blockTrackerHeight = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_block_tracker_height",
+			Help: "Number of the last confirmed block processed by the block tracker",
+		},
+		[]string{"chain"},
+	)
+
+	reorgDepth = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "scorpius_reorg_depth",
+			Help:    "Blocks replaced by each chain reorganization seen by the block tracker",
+			Buckets: []float64{1, 2, 3, 5, 8, 13, 21, 34, 64},
+		},
+		[]string{"chain"},
+	)
+)
+
+// blockHeader identifies a block and its parent
+type blockHeader struct {
+	Number     string `json:"number"`
+	Hash       string `json:"hash"`
+	ParentHash string `json:"parentHash"`
+}
+
+// txLog is an event log emitted by a confirmed transaction
+type txLog struct {
+	Address  string   `json:"address"`
+	Topics   []string `json:"topics"`
+	Data     string   `json:"data"`
+	LogIndex string   `json:"logIndex"`
+}
+
+// blockTx is a transaction in a confirmed block, joined with its receipt
+type blockTx struct {
+	Hash                string   `json:"hash"`
+	From                string   `json:"from"`
+	To                  string   `json:"to"`
+	Nonce               string   `json:"nonce"`
+	Input               string   `json:"input"`
+	TransactionIndex    string   `json:"transactionIndex"`
+	GasPrice            string   `json:"gasPrice"`
+	Type                string   `json:"type"`
+	MaxFeePerBlobGas    string   `json:"maxFeePerBlobGas"`
+	BlobVersionedHashes []string `json:"blobVersionedHashes"`
+	Status              string   `json:"-"`
+	EffectiveGasPrice   string   `json:"-"`
+	BlobGasPrice        string   `json:"-"`
+	Logs                []txLog  `json:"-"`
+}
+
+// Index returns the transaction's position in its block
+func (t *blockTx) Index() int {
+	return int(hexToUint64(t.TransactionIndex))
+}
+
+// Succeeded reports whether the receipt status is success
+func (t *blockTx) Succeeded() bool {
+	return t.Status == "0x1"
+}
+
+// confirmedBlock is a block that is BlockConfirms deep, with its transactions
+// in block order
+type confirmedBlock struct {
+	Number       uint64
+	Hash         string
+	Timestamp    int64
+	FeeRecipient string
+	ExtraData    string
+	Transactions []*blockTx
+}
+
+// blockHandler consumes confirmed blocks; handlers run sequentially in block order
+type blockHandler func(block *confirmedBlock)
+
+// blockTracker polls an EVM chain's HTTP RPC for blocks once they are
+// BlockConfirms deep and hands each one, with receipts, to the chain's block
+// handlers. Waiting for confirmations keeps handlers from acting on blocks
+// that are later reorged out.
+type blockTracker struct {
+	monitor  *ChainMonitor
+	handlers []blockHandler
+	mu       sync.RWMutex
+	client   *rpcClient
+	next     uint64
+	// heads maps recent block numbers to the hashes last seen on the
+	// canonical chain
+	heads map[uint64]string
+}
+
+func newBlockTracker(monitor *ChainMonitor, handlers []blockHandler) *blockTracker {
+	t := &blockTracker{monitor: monitor, handlers: handlers, heads: make(map[uint64]string)}
+	if url := monitor.options.BlockURL; url != "" {
+		t.client = newRPCClient(url, 10*time.Second)
+	}
+	return t
+}
+
+// setEndpoint points the tracker at the HTTP endpoint paired with the active
+// websocket, unless <CHAIN>_BLOCK_URL pins it
+func (t *blockTracker) setEndpoint(wsEndpoint string) {
+	if t.monitor.options.BlockURL != "" {
+		return
+	}
+
+	t.mu.Lock()
+	t.client = newRPCClient(httpURLFor(wsEndpoint), 10*time.Second)
+	t.mu.Unlock()
+}
+
+// run polls for new confirmed blocks until the monitor stops
+func (t *blockTracker) run() {
+	ticker := time.NewTicker(t.monitor.options.BlockPoll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.monitor.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.poll(t.monitor.ctx); err != nil {
+				t.monitor.logger.Warn("failed to track blocks", "error", err)
+			}
+		}
+	}
+}
+
+// poll processes every block between the last one handled and the newest
+// confirmed block
+func (t *blockTracker) poll(ctx context.Context) error {
+	t.mu.RLock()
+	client := t.client
+	t.mu.RUnlock()
+	if client == nil {
+		return nil
+	}
+
+	var head blockHeader
+	if err := client.Call(ctx, "eth_getBlockByNumber", []interface{}{"latest", false}, &head); err != nil {
+		return err
+	}
+	if head.Hash == "" {
+		return fmt.Errorf("latest block not found")
+	}
+	if err := t.checkReorg(ctx, client, head); err != nil {
+		return fmt.Errorf("reorg check: %v", err)
+	}
+
+	confirms := uint64(t.monitor.options.BlockConfirms)
+	if hexToUint64(head.Number) < confirms {
+		return nil
+	}
+	target := hexToUint64(head.Number) - confirms
+
+	switch {
+	case t.next == 0:
+		t.next = target
+	case target >= t.next+maxBlockCatchUp:
+		t.monitor.logger.Warn("block tracker fell behind, skipping ahead", "from", t.next, "to", target)
+		t.next = target
+	}
+
+	for ; t.next <= target; t.next++ {
+		block, err := fetchConfirmedBlock(ctx, client, t.next)
+		if err != nil {
+			blocksTracked.WithLabelValues(t.monitor.chainName, "error").Inc()
+			return fmt.Errorf("block %d: %v", t.next, err)
+		}
+		blocksTracked.WithLabelValues(t.monitor.chainName, "success").Inc()
+		blockTrackerHeight.WithLabelValues(t.monitor.chainName).Set(float64(block.Number))
+
+		for _, handle := range t.handlers {
+			handle(block)
+		}
+	}
+	return nil
+}
+
+// checkReorg follows the new head's parents back to a block seen before and
+// counts the remembered blocks it replaced. A reorg deeper than the chain's
+// ALERT_REORG_DEPTH raises an alert, critical once it reaches confirmed blocks.
+func (t *blockTracker) checkReorg(ctx context.Context, client *rpcClient, head blockHeader) error {
+	number := hexToUint64(head.Number)
+
+	// Blocks above the new head were dropped by a reorg to a shorter chain
+	depth := 0
+	lowest, remembered := number, false
+	for n := range t.heads {
+		switch {
+		case n > number:
+			delete(t.heads, n)
+			depth++
+		case n+reorgWindow <= number:
+			delete(t.heads, n)
+		case !remembered || n < lowest:
+			lowest, remembered = n, true
+		}
+	}
+
+	hash, parent := head.Hash, head.ParentHash
+	for n := number; ; n-- {
+		stored, seen := t.heads[n]
+		if seen && stored == hash {
+			break
+		}
+		if seen {
+			depth++
+		}
+		t.heads[n] = hash
+		if !remembered || n <= lowest {
+			break
+		}
+
+		if parent == "" {
+			var header blockHeader
+			if err := client.Call(ctx, "eth_getBlockByHash", []interface{}{hash, false}, &header); err != nil {
+				return err
+			}
+			if header.ParentHash == "" {
+				return fmt.Errorf("block %s not found", hash)
+			}
+			parent = header.ParentHash
+		}
+		hash, parent = parent, ""
+	}
+	if depth == 0 {
+		return nil
+	}
+
+	chain := t.monitor.chainName
+	reorgDepth.WithLabelValues(chain).Observe(float64(depth))
+	t.monitor.logger.Warn("chain reorganized", "depth", depth, "head", number, "hash", head.Hash)
+	if depth <= t.monitor.options.ReorgAlertDepth {
+		return nil
+	}
+
+	severity := SeverityWarning
+	if depth > t.monitor.options.BlockConfirms {
+		severity = SeverityCritical
+	}
+	t.monitor.alerter.Raise(Alert{
+		Chain:    chain,
+		Category: "reorg",
+		Severity: severity,
+		Key:      "depth",
+		Message: fmt.Sprintf("reorg replaced %d blocks up to head %d (alerting above %d, %d confirmations)",
+			depth, number, t.monitor.options.ReorgAlertDepth, t.monitor.options.BlockConfirms),
+	})
+	return nil
+}
+
+// fetchConfirmedBlock loads a block with full transactions and joins in
+// their receipts from eth_getBlockReceipts
+func fetchConfirmedBlock(ctx context.Context, client *rpcClient, number uint64) (*confirmedBlock, error) {
+	tag := "0x" + strconv.FormatUint(number, 16)
+
+	var raw struct {
+		Hash         string     `json:"hash"`
+		Timestamp    string     `json:"timestamp"`
+		Miner        string     `json:"miner"`
+		ExtraData    string     `json:"extraData"`
+		Transactions []*blockTx `json:"transactions"`
+	}
+	if err := client.Call(ctx, "eth_getBlockByNumber", []interface{}{tag, true}, &raw); err != nil {
+		return nil, err
+	}
+	if raw.Hash == "" {
+		return nil, fmt.Errorf("block not found")
+	}
+
+	var receipts []struct {
+		TransactionHash   string  `json:"transactionHash"`
+		Status            string  `json:"status"`
+		EffectiveGasPrice string  `json:"effectiveGasPrice"`
+		BlobGasPrice      string  `json:"blobGasPrice"`
+		Logs              []txLog `json:"logs"`
+	}
+	if err := client.Call(ctx, "eth_getBlockReceipts", []interface{}{tag}, &receipts); err != nil {
+		return nil, err
+	}
+
+	byHash := make(map[string]*blockTx, len(raw.Transactions))
+	for _, tx := range raw.Transactions {
+		tx.From = strings.ToLower(tx.From)
+		tx.To = strings.ToLower(tx.To)
+		byHash[tx.Hash] = tx
+	}
+	for _, receipt := range receipts {
+		if tx, ok := byHash[receipt.TransactionHash]; ok {
+			tx.Status = receipt.Status
+			tx.EffectiveGasPrice = receipt.EffectiveGasPrice
+			tx.BlobGasPrice = receipt.BlobGasPrice
+			tx.Logs = receipt.Logs
+		}
+	}
+
+	return &confirmedBlock{
+		Number:       number,
+		Hash:         raw.Hash,
+		Timestamp:    int64(hexToUint64(raw.Timestamp)),
+		FeeRecipient: strings.ToLower(raw.Miner),
+		ExtraData:    raw.ExtraData,
+		Transactions: raw.Transactions,
+	}, nil
+}
+
+// startBlockTracker runs the block tracker when <CHAIN>_BLOCK_TRACKING is set
+func (cm *ChainMonitor) startBlockTracker() {
+	if !cm.options.BlockTracking || cm.family != FamilyEVM {
+		return
+	}
+	cm.blocks = newBlockTracker(cm, cm.blockHandlers)
+	go cm.blocks.run()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// bloxrouteProtocol consumes a bloXroute BDN newTxs/pendingTxs stream.
+// The BDN forwards transactions from its relay network well before they
+// propagate through public RPC mempools.
+type bloxrouteProtocol struct {
+	cm *ChainMonitor
+}
+
+// bloxrouteHeader returns the authorization header for BDN websocket connections
+func (cm *ChainMonitor) bloxrouteHeader() http.Header {
+	return http.Header{"Authorization": []string{cm.options.BloxrouteAuth}}
+}
+
+// subscribeRequests subscribes to the configured BDN stream with full transaction contents
+func (p *bloxrouteProtocol) subscribeRequests() []interface{} {
+	stream := p.cm.options.BloxrouteStream
+	if stream == "" {
+		stream = "newTxs"
+	}
+
+	return []interface{}{map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "subscribe",
+		"params": []interface{}{stream, map[string]interface{}{
+			"include": []string{"tx_hash", "tx_contents"},
+		}},
+	}}
+}
+
+// handleMessage normalizes BDN notifications into the standard transaction pipeline.
+// txContents uses JSON-RPC field names, but the hash is carried separately as txHash.
+func (p *bloxrouteProtocol) handleMessage(msg *rpcMessage) error {
+	if msg.Params == nil || isJSONNull(msg.Params.Result) {
+		return nil
+	}
+
+	var result bloxrouteResult
+	if err := wireJSON.Unmarshal(msg.Params.Result, &result); err != nil {
+		return fmt.Errorf("failed to decode BDN notification: %v", err)
+	}
+	if isJSONNull(result.TxContents) {
+		return nil
+	}
+
+	tx, err := p.cm.decodeTransaction(result.TxContents)
+	if err != nil {
+		return err
+	}
+	if result.TxHash != "" {
+		tx.Hash = result.TxHash
+	}
+
+	return p.cm.publishTransaction(tx)
+}
+
+// bloxrouteResult is the payload of a newTxs/pendingTxs notification
+type bloxrouteResult struct {
+	TxHash     string          `json:"txHash"`
+	TxContents json.RawMessage `json:"txContents"`
+}
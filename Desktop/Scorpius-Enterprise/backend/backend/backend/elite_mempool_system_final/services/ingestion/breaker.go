@@ -0,0 +1,202 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitHalfOpen = "half_open"
+	CircuitOpen     = "open"
+)
+
+var circuitStateValue = map[string]float64{
+	CircuitClosed:   0,
+	CircuitHalfOpen: 1,
+	CircuitOpen:     2,
+}
+
+var (
+	circuitState = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_circuit_state",
+			Help: "Endpoint circuit breaker state (0 closed, 1 half-open, 2 open)",
+		},
+		[]string{"chain", "endpoint"},
+	)
+
+	circuitTransitions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_circuit_transitions_total",
+			Help: "Endpoint circuit breaker state transitions",
+		},
+		[]string{"chain", "endpoint", "from", "to"},
+	)
+)
+
+// circuit is one endpoint's breaker state
+type circuit struct {
+	state     string
+	failures  int
+	openUntil time.Time
+}
+
+// circuitBreaker takes endpoints out of rotation after repeated consecutive
+// failures. Open circuits are re-probed with a test connection once their
+// cool-down expires and close again on the first success.
+type circuitBreaker struct {
+	chain     string
+	threshold int
+	cooldown  time.Duration
+	mu        sync.Mutex
+	circuits  map[string]*circuit
+	logger    *slog.Logger
+}
+
+func newCircuitBreaker(chain string, threshold int, cooldown time.Duration, logger *slog.Logger) *circuitBreaker {
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+	return &circuitBreaker{
+		chain:     chain,
+		threshold: threshold,
+		cooldown:  cooldown,
+		circuits:  make(map[string]*circuit),
+		logger:    logger,
+	}
+}
+
+// get returns the circuit for endpoint; callers hold b.mu
+func (b *circuitBreaker) get(endpoint string) *circuit {
+	c, ok := b.circuits[endpoint]
+	if !ok {
+		c = &circuit{state: CircuitClosed}
+		b.circuits[endpoint] = c
+	}
+	return c
+}
+
+// transition moves c to state, recording metrics and a log line; callers hold b.mu
+func (b *circuitBreaker) transition(endpoint string, c *circuit, state string) {
+	if c.state == state {
+		return
+	}
+
+	b.logger.Info("circuit_breaker", "endpoint", endpoint, "from", c.state, "to", state, "failures", c.failures)
+	circuitTransitions.WithLabelValues(b.chain, endpointLabel(endpoint), c.state, state).Inc()
+	circuitState.WithLabelValues(b.chain, endpointLabel(endpoint)).Set(circuitStateValue[state])
+	c.state = state
+}
+
+// Allowed reports whether endpoint may be selected (closed or half-open)
+func (b *circuitBreaker) Allowed(endpoint string) bool {
+	if b.threshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.get(endpoint).state != CircuitOpen
+}
+
+// Success closes the endpoint's circuit
+func (b *circuitBreaker) Success(endpoint string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.get(endpoint)
+	c.failures = 0
+	b.transition(endpoint, c, CircuitClosed)
+}
+
+// Failure counts a consecutive failure, opening the circuit at the threshold.
+// A failed half-open trial reopens it immediately.
+func (b *circuitBreaker) Failure(endpoint string) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.get(endpoint)
+	c.failures++
+	if c.state == CircuitHalfOpen || c.failures >= b.threshold {
+		c.openUntil = time.Now().Add(b.cooldown)
+		b.transition(endpoint, c, CircuitOpen)
+	}
+}
+
+// expired half-opens circuits whose cool-down has elapsed and returns their endpoints
+func (b *circuitBreaker) expired() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var endpoints []string
+	now := time.Now()
+	for endpoint, c := range b.circuits {
+		if c.state == CircuitOpen && now.After(c.openUntil) {
+			b.transition(endpoint, c, CircuitHalfOpen)
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+// circuitLoop re-probes open circuits once their cool-down expires
+func (cm *ChainMonitor) circuitLoop() {
+	ticker := time.NewTicker(cm.breaker.cooldown / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-cm.ctx.Done():
+			return
+		case <-ticker.C:
+			for _, endpoint := range cm.breaker.expired() {
+				go cm.testEndpoint(endpoint)
+			}
+		}
+	}
+}
+
+// testEndpoint dials a half-open websocket endpoint and immediately closes the
+// connection. Streamed sources have no cheap test, so their next real
+// connection attempt serves as the trial instead.
+func (cm *ChainMonitor) testEndpoint(endpoint string) {
+	if cm.streamer != nil {
+		return
+	}
+
+	endpointType, dialURL := splitEndpointType(endpoint)
+	var header http.Header
+	switch endpointType {
+	case EndpointMock:
+		cm.breaker.Success(endpoint)
+		return
+	case EndpointBloxroute:
+		header = cm.bloxrouteHeader()
+	}
+
+	dialURL, key := rpcLimits.Resolve(dialURL)
+	if err := rpcLimits.For(dialURL).Wait(cm.ctx); err != nil {
+		return
+	}
+	dialer := websocket.Dialer{HandshakeTimeout: 10 * time.Second}
+	conn, resp, err := dialer.DialContext(cm.ctx, dialURL, header)
+	key.Observe(err != nil && resp != nil && resp.StatusCode == http.StatusTooManyRequests, err)
+	if err != nil {
+		cm.breaker.Failure(endpoint)
+		return
+	}
+	conn.Close()
+	cm.breaker.Success(endpoint)
+}
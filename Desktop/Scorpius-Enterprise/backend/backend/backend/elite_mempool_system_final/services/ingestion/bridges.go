@@ -0,0 +1,523 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Bridge event kinds
+const (
+	// BridgeDeposit locks or burns funds on the source chain
+	BridgeDeposit = "deposit"
+	// BridgeFill releases or mints them on the destination chain
+	BridgeFill = "fill"
+	// BridgeCorrelated links a deposit to its fill
+	BridgeCorrelated = "correlated"
+)
+
+// Bridge protocols with decoders
+const (
+	BridgeAcross = "across"
+	BridgeCCTP   = "cctp"
+)
+
+var (
+	bridgeTransfers = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_bridge_transfers_total",
+			Help: "Bridge deposits and fills decoded, by protocol and kind",
+		},
+		[]string{"chain", "protocol", "kind"},
+	)
+
+	bridgeCorrelations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_bridge_correlations_total",
+			Help: "Bridge deposits linked to their fill on the destination chain",
+		},
+		[]string{"protocol", "source_chain", "destination_chain"},
+	)
+
+	bridgeFillLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "scorpius_bridge_fill_seconds",
+			Help:    "Time from seeing a bridge deposit to seeing its fill",
+			Buckets: []float64{1, 2, 5, 10, 30, 60, 120, 300, 600, 1200, 1800, 3600},
+		},
+		[]string{"protocol"},
+	)
+)
+
+// defaultBridges are the bridge contracts followed on chains without
+// <CHAIN>_BRIDGES, by chain ID: Across spoke pools and CCTP token messengers
+// and message transmitters
+var defaultBridges = map[int64]map[string]string{
+	1: {
+		"0x5c7bcd6e7de5423a257d81b442095a1a6ced35c5": BridgeAcross,
+		"0xbd3fa81b58ba92a82136038b25adec7066af3155": BridgeCCTP,
+		"0x0a992d191deec32afe36203ad87d7d289a738f81": BridgeCCTP,
+	},
+	10: {
+		"0x6f26bf09b1c792e3228e5467807a900a503c0281": BridgeAcross,
+		"0x2b4069517957735be00cee0fadae88a26365528f": BridgeCCTP,
+		"0x4d41f22c5a0e5c74090899e5a8fb597a8842b3e8": BridgeCCTP,
+	},
+	137: {
+		"0x9295ee1d8c5b022be115a2ad3c30c72e34e7f096": BridgeAcross,
+		"0x9daf8c91aefae50b9c0e69629d3f6ca40ca3b3fe": BridgeCCTP,
+		"0xf3be9355363857f3e001be68856a2f96b4c39ba9": BridgeCCTP,
+	},
+	8453: {
+		"0x09aea4b2242abc8bb4bb78d537a67a245a7bec64": BridgeAcross,
+		"0x1682ae6375c4e4a97e4b583bc394c861a46d8962": BridgeCCTP,
+		"0xad09780d193884d503182ad4588450c416d6f9d4": BridgeCCTP,
+	},
+	42161: {
+		"0xe35e9842fceaca96570b734083f4a58e8f7c5f2a": BridgeAcross,
+		"0x19330d10d9cc8751218eaf51e8885d058642e08a": BridgeCCTP,
+		"0xc30362313fbba5cf9163f0bb16a0e01f01a896ca": BridgeCCTP,
+	},
+	43114: {
+		"0x6b25532e1060ce10cc3b0a99e5683b91bfde6982": BridgeCCTP,
+		"0x8186359af5f57fbb40c6b14a588d2a59c0c29880": BridgeCCTP,
+	},
+}
+
+// cctpDomains maps CCTP domains to EVM chain IDs
+var cctpDomains = map[uint32]int64{
+	0: 1,
+	1: 43114,
+	2: 10,
+	3: 42161,
+	6: 8453,
+	7: 137,
+}
+
+// cctpDomain returns the CCTP domain of a chain
+func cctpDomain(chainID int64) (uint32, bool) {
+	for domain, id := range cctpDomains {
+		if id == chainID {
+			return domain, true
+		}
+	}
+	return 0, false
+}
+
+// bridgeDecoder decodes one bridge method into a transfer and the key that
+// both of its sides share
+type bridgeDecoder struct {
+	method string
+	decode func(args abiData, chainID int64) (BridgeTransfer, string, bool)
+}
+
+var bridgeDecoders = map[string]map[string]bridgeDecoder{
+	BridgeAcross: {
+		"7b939232": {method: "depositV3", decode: acrossDeposit},
+		"2e378115": {method: "fillV3Relay", decode: acrossFill},
+	},
+	BridgeCCTP: {
+		"6fd3504e": {method: "depositForBurn", decode: cctpDeposit},
+		"f856ddb6": {method: "depositForBurnWithCaller", decode: cctpDeposit},
+		"57ecfd28": {method: "receiveMessage", decode: cctpReceive},
+	},
+}
+
+// BridgeConfig configures bridge transfer correlation
+type BridgeConfig struct {
+	Enabled bool
+	Topic   string
+	// Window is how long a deposit or fill waits for its other side
+	Window time.Duration
+}
+
+// loadBridgeConfig reads BRIDGE_* settings
+func loadBridgeConfig() BridgeConfig {
+	return BridgeConfig{
+		Enabled: getEnvBool("BRIDGE_TRACKING", false),
+		Topic:   getEnvOrDefault("BRIDGE_TOPIC", "bridge_transfers"),
+		Window:  getEnvDuration("BRIDGE_CORRELATION_WINDOW", 2*time.Hour),
+	}
+}
+
+// BridgeTransfer is one side of a bridge transfer. Token and Amount are what
+// a deposit sends or a fill pays out, on the transfer's own chain except for
+// CCTP mints, whose message names the burned token on the source chain.
+// Sender sent the transaction, the depositor's wallet or the relayer.
+type BridgeTransfer struct {
+	Kind               string    `json:"kind"`
+	Protocol           string    `json:"protocol"`
+	Chain              string    `json:"chain"`
+	ChainID            int64     `json:"chain_id"`
+	Hash               string    `json:"hash"`
+	Bridge             string    `json:"bridge"`
+	Method             string    `json:"method"`
+	SourceChain        string    `json:"source_chain,omitempty"`
+	SourceChainID      int64     `json:"source_chain_id"`
+	DestinationChain   string    `json:"destination_chain,omitempty"`
+	DestinationChainID int64     `json:"destination_chain_id"`
+	Sender             string    `json:"sender"`
+	Depositor          string    `json:"depositor"`
+	Recipient          string    `json:"recipient"`
+	Token              string    `json:"token"`
+	Amount             string    `json:"amount"`
+	Status             string    `json:"status"`
+	DetectedAt         time.Time `json:"detected_at"`
+}
+
+// BridgeCorrelation links a deposit to the fill that completes it on the
+// destination chain. Latency is the time between seeing each side, negative
+// when the fill was seen first.
+type BridgeCorrelation struct {
+	Kind        string         `json:"kind"`
+	Protocol    string         `json:"protocol"`
+	Source      BridgeTransfer `json:"source"`
+	Destination BridgeTransfer `json:"destination"`
+	Latency     float64        `json:"latency_seconds"`
+	DetectedAt  time.Time      `json:"detected_at"`
+}
+
+// BridgeMonitor decodes deposits and fills on bridge contracts, pending and,
+// with BLOCK_TRACKING, confirmed, and publishes each to the bridge topic.
+// When both sides of a transfer are seen within the correlation window, on
+// chains ingested by this instance, a BridgeCorrelation linking them follows.
+//
+// The contracts followed are each chain's <CHAIN>_BRIDGES
+// (address=protocol), or the Across and CCTP deployments on chains without
+// it. Across deposits are matched to fills by the relay data both carry;
+// CCTP burns to mints by domains, recipient, burn token and amount.
+type BridgeMonitor struct {
+	sinks  *chainSinks
+	topic  string
+	window time.Duration
+	// bridges maps a chain name to its followed contracts' protocols by address
+	bridges    map[string]map[string]string
+	chainNames map[int64]string
+
+	mu       sync.Mutex
+	deposits map[string]BridgeTransfer
+	fills    map[string]BridgeTransfer
+	seen     map[string]time.Time
+	swept    time.Time
+}
+
+// NewBridgeMonitor creates a monitor publishing to sinks
+func NewBridgeMonitor(sinks *chainSinks, config BridgeConfig, chains map[string]ChainOptions) (*BridgeMonitor, error) {
+	bridges := make(map[string]map[string]string)
+	chainNames := make(map[int64]string)
+	for chainName, options := range chains {
+		chain := chainRegistry[chainName]
+		if chain.Family != FamilyEVM {
+			continue
+		}
+		chainNames[chain.ChainID] = chainName
+		if len(options.Bridges) == 0 {
+			if defaults, ok := defaultBridges[chain.ChainID]; ok {
+				bridges[chainName] = defaults
+			}
+			continue
+		}
+		followed := make(map[string]string, len(options.Bridges))
+		for address, protocol := range options.Bridges {
+			if err := validateBridge(address, protocol, chain.ChainID); err != nil {
+				return nil, fmt.Errorf("%s: %v", chainName, err)
+			}
+			followed[strings.ToLower(address)] = strings.ToLower(protocol)
+		}
+		bridges[chainName] = followed
+	}
+	return &BridgeMonitor{
+		sinks:      sinks,
+		topic:      config.Topic,
+		window:     config.Window,
+		bridges:    bridges,
+		chainNames: chainNames,
+		deposits:   make(map[string]BridgeTransfer),
+		fills:      make(map[string]BridgeTransfer),
+		seen:       make(map[string]time.Time),
+	}, nil
+}
+
+// validateBridge checks a <CHAIN>_BRIDGES entry
+func validateBridge(address, protocol string, chainID int64) error {
+	if !common.IsHexAddress(address) {
+		return fmt.Errorf("invalid bridge address %q", address)
+	}
+	if _, ok := bridgeDecoders[strings.ToLower(protocol)]; !ok {
+		return fmt.Errorf("unknown bridge protocol %q for %s, expected %s or %s", protocol, address, BridgeAcross, BridgeCCTP)
+	}
+	if _, ok := cctpDomain(chainID); strings.ToLower(protocol) == BridgeCCTP && !ok {
+		return fmt.Errorf("chain ID %d has no CCTP domain", chainID)
+	}
+	return nil
+}
+
+// Name returns the enricher name
+func (m *BridgeMonitor) Name() string {
+	return "bridges"
+}
+
+// Enrich decodes pending bridge calls
+func (m *BridgeMonitor) Enrich(tx *Transaction) {
+	if tx.ChainFamily != FamilyEVM || tx.Canary {
+		return
+	}
+	m.observe(tx.Chain, tx.ChainID, tx.ChainFamily, tx.Hash, tx.From, tx.To, tx.Data, tx.Status)
+}
+
+// blockHandler decodes a chain's confirmed bridge calls, catching fills
+// relayers send privately
+func (m *BridgeMonitor) blockHandler(monitor *ChainMonitor) blockHandler {
+	return func(block *confirmedBlock) {
+		for _, tx := range block.Transactions {
+			if tx.Succeeded() {
+				m.observe(monitor.chainName, monitor.chainID, monitor.family, tx.Hash, tx.From, tx.To, tx.Input, "confirmed")
+			}
+		}
+	}
+}
+
+// observe publishes a bridge call once, the first time it is seen, and
+// correlates it with its other side
+func (m *BridgeMonitor) observe(chain string, chainID int64, family, hash, from, to, input, status string) {
+	bridge := strings.ToLower(to)
+	protocol, ok := m.bridges[chain][bridge]
+	if !ok {
+		return
+	}
+	data := common.FromHex(input)
+	if len(data) < 4 {
+		return
+	}
+	decoder, ok := bridgeDecoders[protocol][hex.EncodeToString(data[:4])]
+	if !ok {
+		return
+	}
+	transfer, key, ok := decoder.decode(abiData(data[4:]), chainID)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	transfer.Protocol = protocol
+	transfer.Chain = chain
+	transfer.ChainID = chainID
+	transfer.Hash = hash
+	transfer.Bridge = bridge
+	transfer.Method = decoder.method
+	transfer.SourceChain = m.chainNames[transfer.SourceChainID]
+	transfer.DestinationChain = m.chainNames[transfer.DestinationChainID]
+	transfer.Sender = strings.ToLower(from)
+	if transfer.Depositor == "" {
+		transfer.Depositor = transfer.Sender
+	}
+	transfer.Status = status
+	transfer.DetectedAt = now
+
+	m.mu.Lock()
+	m.sweep(now)
+	seenKey := chain + ":" + hash
+	if _, ok := m.seen[seenKey]; ok {
+		m.mu.Unlock()
+		return
+	}
+	m.seen[seenKey] = now
+
+	key = protocol + ":" + key
+	var correlation *BridgeCorrelation
+	if transfer.Kind == BridgeDeposit {
+		if fill, ok := m.fills[key]; ok {
+			delete(m.fills, key)
+			correlation = &BridgeCorrelation{Source: transfer, Destination: fill}
+		} else {
+			m.deposits[key] = transfer
+		}
+	} else {
+		if deposit, ok := m.deposits[key]; ok {
+			delete(m.deposits, key)
+			correlation = &BridgeCorrelation{Source: deposit, Destination: transfer}
+		} else {
+			m.fills[key] = transfer
+		}
+	}
+	m.mu.Unlock()
+
+	bridgeTransfers.WithLabelValues(chain, protocol, transfer.Kind).Inc()
+	m.publish(transfer.Chain, transfer.ChainID, family, transfer.Hash, transfer.Kind, transfer)
+
+	if correlation != nil {
+		correlation.Kind = BridgeCorrelated
+		correlation.Protocol = protocol
+		correlation.Latency = correlation.Destination.DetectedAt.Sub(correlation.Source.DetectedAt).Seconds()
+		correlation.DetectedAt = now
+		bridgeCorrelations.WithLabelValues(protocol, correlation.Source.Chain, correlation.Destination.Chain).Inc()
+		if correlation.Latency >= 0 {
+			bridgeFillLatency.WithLabelValues(protocol).Observe(correlation.Latency)
+		}
+		source := correlation.Source
+		m.publish(source.Chain, source.ChainID, family, source.Hash, BridgeCorrelated, correlation)
+	}
+}
+
+// sweep drops entries older than the correlation window, at most once a
+// minute. The caller holds m.mu.
+func (m *BridgeMonitor) sweep(now time.Time) {
+	if now.Sub(m.swept) < time.Minute {
+		return
+	}
+	m.swept = now
+	cutoff := now.Add(-m.window)
+	for key, transfer := range m.deposits {
+		if transfer.DetectedAt.Before(cutoff) {
+			delete(m.deposits, key)
+		}
+	}
+	for key, transfer := range m.fills {
+		if transfer.DetectedAt.Before(cutoff) {
+			delete(m.fills, key)
+		}
+	}
+	for key, seenAt := range m.seen {
+		if seenAt.Before(cutoff) {
+			delete(m.seen, key)
+		}
+	}
+}
+
+// publish produces a bridge event to the bridge topic
+func (m *BridgeMonitor) publish(chain string, chainID int64, family, hash, kind string, event interface{}) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("failed to marshal bridge event", "chain", chain, "tx_hash", hash, "error", err)
+		return
+	}
+
+	topic := expandTopic(m.topic, chain, chainID, family)
+	headers := map[string]string{
+		"chain_id":   fmt.Sprintf("%d", chainID),
+		"chain_name": chain,
+		"kind":       kind,
+		"format":     FormatJSON,
+	}
+	if err := m.sinks.Publish(context.Background(), chain, topic, []byte(hash), data, headers); err != nil {
+		slog.Warn("failed to publish bridge event", "chain", chain, "tx_hash", hash, "kind", kind, "error", err)
+	}
+}
+
+// acrossDeposit decodes depositV3(depositor, recipient, inputToken,
+// outputToken, inputAmount, outputAmount, destinationChainId,
+// exclusiveRelayer, quoteTimestamp, fillDeadline, exclusivityDeadline, message)
+func acrossDeposit(args abiData, chainID int64) (BridgeTransfer, string, bool) {
+	if args.word(11) == nil {
+		return BridgeTransfer{}, "", false
+	}
+	transfer := BridgeTransfer{
+		Kind:               BridgeDeposit,
+		SourceChainID:      chainID,
+		DestinationChainID: args.int64(6),
+		Depositor:          args.address(0),
+		Recipient:          args.address(1),
+		Token:              args.address(2),
+		Amount:             args.uint(4).String(),
+	}
+	return transfer, acrossKey(transfer.SourceChainID, transfer.DestinationChainID, transfer.Depositor, transfer.Recipient, transfer.Token, args.uint(4), args.uint(5), args.int64(9)), true
+}
+
+// acrossFill decodes fillV3Relay(V3RelayData, repaymentChainId). The relay
+// data repeats the deposit: depositor, recipient, exclusiveRelayer,
+// inputToken, outputToken, inputAmount, outputAmount, originChainId,
+// depositId, fillDeadline, exclusivityDeadline, message.
+func acrossFill(args abiData, chainID int64) (BridgeTransfer, string, bool) {
+	relay := args.tuple(0)
+	if relay.word(11) == nil {
+		return BridgeTransfer{}, "", false
+	}
+	transfer := BridgeTransfer{
+		Kind:               BridgeFill,
+		SourceChainID:      relay.int64(7),
+		DestinationChainID: chainID,
+		Depositor:          relay.address(0),
+		Recipient:          relay.address(1),
+		Token:              relay.address(4),
+		Amount:             relay.uint(6).String(),
+	}
+	return transfer, acrossKey(transfer.SourceChainID, transfer.DestinationChainID, transfer.Depositor, transfer.Recipient, relay.address(3), relay.uint(5), relay.uint(6), relay.int64(9)), true
+}
+
+// acrossKey identifies an Across relay by the deposit fields its fill repeats
+func acrossKey(origin, destination int64, depositor, recipient, inputToken string, inputAmount, outputAmount *big.Int, fillDeadline int64) string {
+	return fmt.Sprintf("%d:%d:%s:%s:%s:%s:%s:%d", origin, destination, depositor, recipient, inputToken, inputAmount, outputAmount, fillDeadline)
+}
+
+// cctpDeposit decodes depositForBurn(amount, destinationDomain, mintRecipient,
+// burnToken) and depositForBurnWithCaller, which adds the destination caller
+func cctpDeposit(args abiData, chainID int64) (BridgeTransfer, string, bool) {
+	if args.word(3) == nil {
+		return BridgeTransfer{}, "", false
+	}
+	source, ok := cctpDomain(chainID)
+	if !ok {
+		return BridgeTransfer{}, "", false
+	}
+	destination := uint32(args.int64(1))
+	transfer := BridgeTransfer{
+		Kind:               BridgeDeposit,
+		SourceChainID:      chainID,
+		DestinationChainID: cctpDomains[destination],
+		Recipient:          bytes32Recipient(args.word(2)),
+		Token:              args.address(3),
+		Amount:             args.uint(0).String(),
+	}
+	return transfer, cctpKey(source, destination, args.word(2), transfer.Token, args.uint(0)), true
+}
+
+// cctpReceive decodes receiveMessage(message, attestation). The message is
+// version, sourceDomain, destinationDomain (4 bytes each), nonce (8),
+// sender, recipient, destinationCaller (32 each) and a body which, for token
+// messages, is version (4), burnToken, mintRecipient, amount, messageSender
+// (32 each). The burn token is the source chain's.
+func cctpReceive(args abiData, chainID int64) (BridgeTransfer, string, bool) {
+	message := args.bytes(0)
+	if len(message) < 116+132 {
+		return BridgeTransfer{}, "", false
+	}
+	source := binary.BigEndian.Uint32(message[4:8])
+	destination := binary.BigEndian.Uint32(message[8:12])
+	body := abiData(message[120:])
+	transfer := BridgeTransfer{
+		Kind:               BridgeFill,
+		SourceChainID:      cctpDomains[source],
+		DestinationChainID: chainID,
+		Depositor:          body.address(3),
+		Recipient:          bytes32Recipient(body.word(1)),
+		Token:              body.address(0),
+		Amount:             body.uint(2).String(),
+	}
+	return transfer, cctpKey(source, destination, body.word(1), transfer.Token, body.uint(2)), true
+}
+
+// cctpKey identifies a CCTP burn by the fields its mint message repeats
+func cctpKey(source, destination uint32, mintRecipient []byte, burnToken string, amount *big.Int) string {
+	return fmt.Sprintf("%d:%d:%x:%s:%s", source, destination, mintRecipient, burnToken, amount)
+}
+
+// bytes32Recipient formats a bytes32 recipient as an address when it holds
+// one, or as hex for non-EVM destinations
+func bytes32Recipient(word []byte) string {
+	if len(word) == 32 && bytes.Equal(word[:12], make([]byte, 12)) {
+		return strings.ToLower(common.BytesToAddress(word[12:]).Hex())
+	}
+	return "0x" + hex.EncodeToString(word)
+}
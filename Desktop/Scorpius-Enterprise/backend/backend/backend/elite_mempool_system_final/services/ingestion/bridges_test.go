@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	arbitrumUSDC = "0xaf88d065e77c8cc2239327c5edb3a432268e5831"
+	relayer      = "0x1111111111111111111111111111111111111111"
+	depositor    = "0x2222222222222222222222222222222222222222"
+)
+
+// cctpMessage builds a CCTP token message burning amount of burnToken on
+// the source domain for mintRecipient on the destination domain
+func cctpMessage(source, destination uint32, burnToken, mintRecipient string, amount int, sender string) []byte {
+	header := make([]byte, 116)
+	binary.BigEndian.PutUint32(header[4:], source)
+	binary.BigEndian.PutUint32(header[8:], destination)
+	binary.BigEndian.PutUint64(header[12:], 42)
+	body := make([]byte, 4)
+	return append(append(header, body...), abiWords(burnToken, mintRecipient, amount, sender)...)
+}
+
+func TestBridgeDecoders(t *testing.T) {
+	acrossDepositArgs := abiWords(depositor, recipient, usdc, arbitrumUSDC, 1000000, 990000, 42161, relayer, 1700000000, 1700003600, 0, 0x180, 0)
+	acrossFillArgs := append(abiWords(0x40, 1),
+		abiWords(depositor, recipient, relayer, usdc, arbitrumUSDC, 1000000, 990000, 1, 7, 1700003600, 0, 0x180, 0)...)
+
+	tests := []struct {
+		name     string
+		protocol string
+		data     []byte
+		chainID  int64
+		want     BridgeTransfer
+		ok       bool
+	}{
+		{
+			name:     "across deposit",
+			protocol: BridgeAcross,
+			data:     abiCall("7b939232", acrossDepositArgs),
+			chainID:  1,
+			want: BridgeTransfer{
+				Kind: BridgeDeposit, SourceChainID: 1, DestinationChainID: 42161,
+				Depositor: depositor, Recipient: recipient, Token: usdc, Amount: "1000000",
+			},
+			ok: true,
+		},
+		{
+			name:     "across fill",
+			protocol: BridgeAcross,
+			data:     abiCall("2e378115", acrossFillArgs),
+			chainID:  42161,
+			want: BridgeTransfer{
+				Kind: BridgeFill, SourceChainID: 1, DestinationChainID: 42161,
+				Depositor: depositor, Recipient: recipient, Token: arbitrumUSDC, Amount: "990000",
+			},
+			ok: true,
+		},
+		{
+			name:     "across deposit without its message",
+			protocol: BridgeAcross,
+			data:     abiCall("7b939232", acrossDepositArgs[:11*32]),
+			chainID:  1,
+		},
+		{
+			name:     "across fill with the relay data past the end",
+			protocol: BridgeAcross,
+			data:     abiCall("2e378115", abiWords(0x1000, 1)),
+			chainID:  42161,
+		},
+		{
+			name:     "cctp deposit for burn",
+			protocol: BridgeCCTP,
+			data:     abiCall("6fd3504e", abiWords(1000000, 3, recipient, usdc)),
+			chainID:  1,
+			want: BridgeTransfer{
+				Kind: BridgeDeposit, SourceChainID: 1, DestinationChainID: 42161,
+				Recipient: recipient, Token: usdc, Amount: "1000000",
+			},
+			ok: true,
+		},
+		{
+			name:     "cctp deposit from a chain without a domain",
+			protocol: BridgeCCTP,
+			data:     abiCall("6fd3504e", abiWords(1000000, 3, recipient, usdc)),
+			chainID:  56,
+		},
+		{
+			name:     "cctp receive message",
+			protocol: BridgeCCTP,
+			data: abiCall("57ecfd28",
+				abiWords(0x40, 0x160),
+				abiBytes(cctpMessage(0, 3, usdc, recipient, 1000000, depositor)),
+				abiBytes(make([]byte, 65))),
+			chainID: 42161,
+			want: BridgeTransfer{
+				Kind: BridgeFill, SourceChainID: 1, DestinationChainID: 42161,
+				Depositor: depositor, Recipient: recipient, Token: usdc, Amount: "1000000",
+			},
+			ok: true,
+		},
+		{
+			name:     "cctp receive of a short message",
+			protocol: BridgeCCTP,
+			data:     abiCall("57ecfd28", abiWords(0x40, 0x80), abiBytes(make([]byte, 32)), abiBytes(nil)),
+			chainID:  42161,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := bridgeDecoders[tt.protocol][common.Bytes2Hex(tt.data[:4])]
+			got, _, ok := decoder.decode(abiData(tt.data[4:]), tt.chainID)
+			if ok != tt.ok {
+				t.Fatalf("decode() ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && got != tt.want {
+				t.Errorf("decode() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBridgeKeysMatchAcrossChains(t *testing.T) {
+	tests := []struct {
+		name                string
+		protocol            string
+		deposit, fill       []byte
+		source, destination int64
+	}{
+		{
+			name:     "across",
+			protocol: BridgeAcross,
+			deposit: abiCall("7b939232",
+				abiWords(depositor, recipient, usdc, arbitrumUSDC, 1000000, 990000, 42161, relayer, 1700000000, 1700003600, 0, 0x180, 0)),
+			fill: abiCall("2e378115", abiWords(0x40, 1),
+				abiWords(depositor, recipient, relayer, usdc, arbitrumUSDC, 1000000, 990000, 1, 7, 1700003600, 0, 0x180, 0)),
+			source:      1,
+			destination: 42161,
+		},
+		{
+			name:     "cctp",
+			protocol: BridgeCCTP,
+			deposit:  abiCall("f856ddb6", abiWords(1000000, 3, recipient, usdc, relayer)),
+			fill: abiCall("57ecfd28",
+				abiWords(0x40, 0x160),
+				abiBytes(cctpMessage(0, 3, usdc, recipient, 1000000, depositor)),
+				abiBytes(make([]byte, 65))),
+			source:      1,
+			destination: 42161,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoders := bridgeDecoders[tt.protocol]
+			_, depositKey, ok1 := decoders[common.Bytes2Hex(tt.deposit[:4])].decode(abiData(tt.deposit[4:]), tt.source)
+			_, fillKey, ok2 := decoders[common.Bytes2Hex(tt.fill[:4])].decode(abiData(tt.fill[4:]), tt.destination)
+			if !ok1 || !ok2 {
+				t.Fatalf("decode() ok = %v, %v", ok1, ok2)
+			}
+			if depositKey != fillKey {
+				t.Errorf("deposit key %q does not match fill key %q", depositKey, fillKey)
+			}
+		})
+	}
+}
+
+func TestBytes32Recipient(t *testing.T) {
+	tests := []struct {
+		name string
+		word []byte
+		want string
+	}{
+		{"evm address", encodeWord(recipient), recipient},
+		{"non-evm recipient", common.FromHex("0x0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20"), "0x0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bytes32Recipient(tt.word); got != tt.want {
+				t.Errorf("bytes32Recipient() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/sha256"
+	"math/big"
+	"strings"
+)
+
+// Bitcoin network parameters for address encoding
+type bitcoinNetwork struct {
+	p2pkh byte
+	p2sh  byte
+	hrp   string
+}
+
+var bitcoinNetworks = map[string]bitcoinNetwork{
+	"mainnet": {p2pkh: 0x00, p2sh: 0x05, hrp: "bc"},
+	"testnet": {p2pkh: 0x6f, p2sh: 0xc4, hrp: "tb"},
+	"regtest": {p2pkh: 0x6f, p2sh: 0xc4, hrp: "bcrt"},
+}
+
+// classifyScript identifies standard output scripts and derives their address
+func classifyScript(script []byte, network string) (string, string) {
+	params, ok := bitcoinNetworks[network]
+	if !ok {
+		params = bitcoinNetworks["mainnet"]
+	}
+
+	switch {
+	case len(script) == 25 && script[0] == 0x76 && script[1] == 0xa9 && script[2] == 0x14 && script[23] == 0x88 && script[24] == 0xac:
+		return "p2pkh", base58Check(params.p2pkh, script[3:23])
+	case len(script) == 23 && script[0] == 0xa9 && script[1] == 0x14 && script[22] == 0x87:
+		return "p2sh", base58Check(params.p2sh, script[2:22])
+	case len(script) == 22 && script[0] == 0x00 && script[1] == 0x14:
+		return "p2wpkh", segwitAddress(params.hrp, 0, script[2:])
+	case len(script) == 34 && script[0] == 0x00 && script[1] == 0x20:
+		return "p2wsh", segwitAddress(params.hrp, 0, script[2:])
+	case len(script) == 34 && script[0] == 0x51 && script[1] == 0x20:
+		return "p2tr", segwitAddress(params.hrp, 1, script[2:])
+	case len(script) > 0 && script[0] == 0x6a:
+		return "nulldata", ""
+	default:
+		return "nonstandard", ""
+	}
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+func base58Check(version byte, payload []byte) string {
+	data := append([]byte{version}, payload...)
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	data = append(data, second[:4]...)
+
+	n := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var encoded []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, '1')
+	}
+
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// segwitAddress encodes a witness program as bech32 (v0) or bech32m (v1+)
+func segwitAddress(hrp string, version byte, program []byte) string {
+	data := append([]byte{version}, convertBits(program, 8, 5)...)
+
+	constant := uint32(1)
+	if version > 0 {
+		constant = 0x2bc830a3
+	}
+	return bech32Encode(hrp, data, constant)
+}
+
+// bech32Encode encodes 5-bit data with its checksum; constant selects
+// bech32 (1) or bech32m
+func bech32Encode(hrp string, data []byte, constant uint32) string {
+	values := append(bech32HRPExpand(hrp), data...)
+	polymod := bech32Polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ constant
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, d := range data {
+		sb.WriteByte(bech32Charset[d])
+	}
+	for i := 0; i < 6; i++ {
+		sb.WriteByte(bech32Charset[(polymod>>uint(5*(5-i)))&31])
+	}
+	return sb.String()
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	out := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]>>5)
+	}
+	out = append(out, 0)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]&31)
+	}
+	return out
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func convertBits(data []byte, from, to uint) []byte {
+	var acc, bits uint
+	maxv := uint(1)<<to - 1
+	out := make([]byte, 0, len(data)*int(from)/int(to)+1)
+	for _, b := range data {
+		acc = acc<<from | uint(b)
+		bits += from
+		for bits >= to {
+			bits -= to
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+	if bits > 0 {
+		out = append(out, byte(acc<<(to-bits)&maxv))
+	}
+	return out
+}
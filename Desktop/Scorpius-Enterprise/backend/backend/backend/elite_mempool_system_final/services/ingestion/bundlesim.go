@@ -0,0 +1,627 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// maxPermutedBundle is the largest bundle tried in every order by default
+const maxPermutedBundle = 4
+
+var (
+	bundleSimulations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_bundle_simulations_total",
+			Help: "Bundle simulation requests, by result (ok or error)",
+		},
+		[]string{"chain", "result"},
+	)
+
+	bundleSimulationDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "scorpius_bundle_simulation_duration_seconds",
+			Help:    "Time spent simulating a bundle in every requested ordering",
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 10),
+		},
+		[]string{"chain"},
+	)
+)
+
+// BundleSimConfig configures fork-based bundle simulation
+type BundleSimConfig struct {
+	Forks    map[string]string
+	Spawn    []string
+	Anvil    string
+	PortBase int
+	Reset    bool
+	Timeout  time.Duration
+	MaxTxs   int
+}
+
+// loadBundleSimConfig reads BUNDLE_SIM_* settings
+func loadBundleSimConfig() BundleSimConfig {
+	return BundleSimConfig{
+		Forks:    parseKeyValues(getEnv("BUNDLE_SIM_FORKS")),
+		Spawn:    splitNonEmpty(getEnv("BUNDLE_SIM_SPAWN")),
+		Anvil:    getEnvOrDefault("BUNDLE_SIM_ANVIL", "anvil"),
+		PortBase: getEnvInt("BUNDLE_SIM_PORT_BASE", 8600),
+		Reset:    getEnvBool("BUNDLE_SIM_RESET", true),
+		Timeout:  getEnvDuration("BUNDLE_SIM_TIMEOUT", 30*time.Second),
+		MaxTxs:   getEnvInt("BUNDLE_SIM_MAX_TXS", 8),
+	}
+}
+
+// Enabled reports whether any chain has a fork to simulate on
+func (c BundleSimConfig) Enabled() bool {
+	return len(c.Forks) > 0 || len(c.Spawn) > 0
+}
+
+// forkUpstream is the node a chain's fork is taken from: its simulation or
+// hydration endpoint, or failing those its first mempool endpoint
+func forkUpstream(config Config, chain string) string {
+	options := config.ChainOptions[chain]
+	switch {
+	case options.SimulationURL != "":
+		return httpURLFor(options.SimulationURL)
+	case options.HydrationURL != "":
+		return httpURLFor(options.HydrationURL)
+	case len(config.ChainEndpoints[chain]) > 0:
+		return httpURLFor(config.ChainEndpoints[chain][0])
+	}
+	return ""
+}
+
+// forkNode is an Anvil or Hardhat node forked from a chain. Scenarios change
+// its state, so it serves one request at a time.
+type forkNode struct {
+	chain    string
+	url      string
+	upstream string
+	port     int
+	client   *rpcClient
+	mu       sync.Mutex
+}
+
+// BundleSimulator answers "what happens if these transactions land in this
+// order" by replaying bundles on forked nodes. Each ordering runs from an
+// evm_snapshot that is reverted afterwards, with one block mined per
+// transaction so the requested order is kept exactly; with BUNDLE_SIM_RESET
+// the fork is first moved to the upstream's latest block.
+//
+// Forks are either existing nodes (BUNDLE_SIM_FORKS=chain=url) or Anvil
+// processes started and restarted by the service (BUNDLE_SIM_SPAWN).
+type BundleSimulator struct {
+	cache  Cache
+	config BundleSimConfig
+	forks  map[string]*forkNode
+}
+
+// NewBundleSimulator creates the configured forks; call Run to start spawned ones
+func NewBundleSimulator(cache Cache, config Config) *BundleSimulator {
+	s := &BundleSimulator{
+		cache:  cache,
+		config: config.BundleSim,
+		forks:  make(map[string]*forkNode),
+	}
+	for chain, url := range config.BundleSim.Forks {
+		s.forks[chain] = &forkNode{chain: chain, url: url, upstream: forkUpstream(config, chain)}
+	}
+	for i, chain := range config.BundleSim.Spawn {
+		port := config.BundleSim.PortBase + i
+		s.forks[chain] = &forkNode{
+			chain:    chain,
+			url:      fmt.Sprintf("http://127.0.0.1:%d", port),
+			upstream: forkUpstream(config, chain),
+			port:     port,
+		}
+	}
+	for _, fork := range s.forks {
+		fork.client = newRPCClient(fork.url, config.BundleSim.Timeout)
+	}
+	return s
+}
+
+// Run keeps the spawned Anvil forks running until ctx is cancelled
+func (s *BundleSimulator) Run(ctx context.Context) {
+	for _, fork := range s.forks {
+		if fork.port != 0 {
+			go s.supervise(ctx, fork)
+		}
+	}
+}
+
+// supervise runs an Anvil fork, restarting it whenever it exits
+func (s *BundleSimulator) supervise(ctx context.Context, fork *forkNode) {
+	for {
+		slog.Info("starting anvil fork", "chain", fork.chain, "port", fork.port)
+		cmd := exec.CommandContext(ctx, s.config.Anvil, "--fork-url", fork.upstream, "--port", strconv.Itoa(fork.port), "--silent")
+		err := cmd.Run()
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("anvil fork exited, restarting", "chain", fork.chain, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// bundleStep is a resolved bundle transaction: raw, or a call to impersonate
+type bundleStep struct {
+	raw  string
+	call map[string]interface{}
+}
+
+// SimulateBundle is the BundleSimulator gRPC method
+func (s *BundleSimulator) SimulateBundle(ctx context.Context, req *SimulateBundleRequest) (*SimulateBundleResponse, error) {
+	fork, ok := s.forks[req.Chain]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no fork configured for chain %q", req.Chain)
+	}
+	if len(req.Transactions) == 0 || len(req.Transactions) > s.config.MaxTxs {
+		return nil, status.Errorf(codes.InvalidArgument, "bundles must have 1 to %d transactions, got %d", s.config.MaxTxs, len(req.Transactions))
+	}
+	if req.ProfitAddress == "" {
+		return nil, status.Error(codes.InvalidArgument, "profit_address is required")
+	}
+	orderings, err := bundleOrderings(req.Orderings, len(req.Transactions))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+
+	steps, err := s.resolve(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := s.simulate(ctx, fork, req, steps, orderings)
+	bundleSimulationDuration.WithLabelValues(req.Chain).Observe(time.Since(start).Seconds())
+	if err != nil {
+		bundleSimulations.WithLabelValues(req.Chain, "error").Inc()
+		slog.Warn("failed to simulate bundle", "chain", req.Chain, "error", err)
+		return nil, status.Errorf(codes.Unavailable, "fork for %s failed: %v", req.Chain, err)
+	}
+	bundleSimulations.WithLabelValues(req.Chain, "ok").Inc()
+	return resp, nil
+}
+
+// bundleOrderings validates the requested orderings, or builds the default ones
+func bundleOrderings(orderings [][]uint32, n int) ([][]uint32, error) {
+	if len(orderings) == 0 {
+		order := make([]uint32, n)
+		for i := range order {
+			order[i] = uint32(i)
+		}
+		if n > maxPermutedBundle {
+			return [][]uint32{order}, nil
+		}
+		return permutations(order), nil
+	}
+
+	for _, order := range orderings {
+		if len(order) == 0 {
+			return nil, fmt.Errorf("orderings must not be empty")
+		}
+		seen := make(map[uint32]bool)
+		for _, index := range order {
+			if int(index) >= n || seen[index] {
+				return nil, fmt.Errorf("ordering %v must use each transaction index below %d at most once", order, n)
+			}
+			seen[index] = true
+		}
+	}
+	return orderings, nil
+}
+
+// permutations returns every order of values
+func permutations(values []uint32) [][]uint32 {
+	if len(values) <= 1 {
+		return [][]uint32{append([]uint32(nil), values...)}
+	}
+	var result [][]uint32
+	for i, first := range values {
+		rest := make([]uint32, 0, len(values)-1)
+		rest = append(rest, values[:i]...)
+		rest = append(rest, values[i+1:]...)
+		for _, tail := range permutations(rest) {
+			result = append(result, append([]uint32{first}, tail...))
+		}
+	}
+	return result
+}
+
+// resolve turns the request's transactions into steps, looking pending
+// transactions up in the Redis transaction cache
+func (s *BundleSimulator) resolve(ctx context.Context, req *SimulateBundleRequest) ([]bundleStep, error) {
+	steps := make([]bundleStep, len(req.Transactions))
+	for i, btx := range req.Transactions {
+		switch {
+		case btx.Raw != "":
+			steps[i].raw = btx.Raw
+		case btx.Hash != "":
+			data, err := s.cache.Get(ctx, cacheKey(req.Chain, btx.Hash))
+			if err == errCacheMiss {
+				return nil, status.Errorf(codes.NotFound, "transaction %s is not in the pending transaction cache", btx.Hash)
+			}
+			if err != nil {
+				return nil, status.Errorf(codes.Unavailable, "failed to look up transaction %s: %v", btx.Hash, err)
+			}
+			var tx Transaction
+			if err := json.Unmarshal(data, &tx); err != nil {
+				return nil, status.Errorf(codes.Internal, "failed to decode cached transaction %s: %v", btx.Hash, err)
+			}
+			steps[i].call = callObject(&tx)
+		case btx.From != "":
+			steps[i].call = callObject(&Transaction{From: btx.From, To: btx.To, Data: btx.Data, Value: btx.Value, Gas: btx.Gas})
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "transaction %d needs raw, hash or from", i)
+		}
+	}
+	return steps, nil
+}
+
+// simulate runs every ordering on the fork
+func (s *BundleSimulator) simulate(ctx context.Context, fork *forkNode, req *SimulateBundleRequest, steps []bundleStep, orderings [][]uint32) (*SimulateBundleResponse, error) {
+	fork.mu.Lock()
+	defer fork.mu.Unlock()
+
+	if s.config.Reset && fork.upstream != "" {
+		forking := map[string]interface{}{"forking": map[string]interface{}{"jsonRpcUrl": fork.upstream}}
+		if err := fork.client.Call(ctx, "hardhat_reset", []interface{}{forking}, nil); err != nil {
+			return nil, fmt.Errorf("failed to reset fork: %v", err)
+		}
+	}
+
+	var blockNumber string
+	if err := fork.client.Call(ctx, "eth_blockNumber", nil, &blockNumber); err != nil {
+		return nil, err
+	}
+
+	resp := &SimulateBundleResponse{Chain: req.Chain, ForkBlock: hexToUint64(blockNumber), Best: -1}
+	var bestProfit *big.Int
+	for _, order := range orderings {
+		scenario, profit, err := s.runScenario(ctx, fork, req, steps, order)
+		if err != nil {
+			return nil, err
+		}
+		if scenario.Success && (bestProfit == nil || profit.Cmp(bestProfit) > 0) {
+			resp.Best = int32(len(resp.Scenarios))
+			bestProfit = profit
+		}
+		resp.Scenarios = append(resp.Scenarios, scenario)
+	}
+	return resp, nil
+}
+
+// runScenario applies one ordering from a snapshot and reverts it afterwards
+func (s *BundleSimulator) runScenario(ctx context.Context, fork *forkNode, req *SimulateBundleRequest, steps []bundleStep, order []uint32) (BundleScenario, *big.Int, error) {
+	scenario := BundleScenario{Order: order, Success: true}
+
+	var snapshot string
+	if err := fork.client.Call(ctx, "evm_snapshot", nil, &snapshot); err != nil {
+		return scenario, nil, err
+	}
+	defer func() {
+		// Revert even when the request has timed out, or the next request starts dirty
+		revertCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := fork.client.Call(revertCtx, "evm_revert", []interface{}{snapshot}, nil); err != nil {
+			slog.Warn("failed to revert fork snapshot", "chain", fork.chain, "error", err)
+		}
+	}()
+
+	before, err := s.balances(ctx, fork, req)
+	if err != nil {
+		return scenario, nil, err
+	}
+
+	for _, index := range order {
+		outcome, err := s.send(ctx, fork, steps[index])
+		if err != nil {
+			return scenario, nil, err
+		}
+		outcome.Index = index
+		scenario.Success = scenario.Success && outcome.Success
+		scenario.GasUsed += outcome.GasUsed
+		scenario.Transactions = append(scenario.Transactions, outcome)
+	}
+
+	after, err := s.balances(ctx, fork, req)
+	if err != nil {
+		return scenario, nil, err
+	}
+
+	profit := new(big.Int).Sub(after[0], before[0])
+	scenario.Profit = profit.String()
+	for i, token := range req.Tokens {
+		scenario.TokenProfits = append(scenario.TokenProfits, TokenProfit{
+			Token:  token,
+			Profit: new(big.Int).Sub(after[i+1], before[i+1]).String(),
+		})
+	}
+	return scenario, profit, nil
+}
+
+// balances reads the profit address's native balance followed by its token balances
+func (s *BundleSimulator) balances(ctx context.Context, fork *forkNode, req *SimulateBundleRequest) ([]*big.Int, error) {
+	var native string
+	if err := fork.client.Call(ctx, "eth_getBalance", []interface{}{req.ProfitAddress, "latest"}, &native); err != nil {
+		return nil, err
+	}
+	balances := []*big.Int{hexToBig(native)}
+
+	// balanceOf(address)
+	data := "0x70a08231" + strings.Repeat("0", 24) + strings.TrimPrefix(strings.ToLower(req.ProfitAddress), "0x")
+	for _, token := range req.Tokens {
+		var balance string
+		call := map[string]interface{}{"to": token, "data": data}
+		if err := fork.client.Call(ctx, "eth_call", []interface{}{call, "latest"}, &balance); err != nil {
+			return nil, fmt.Errorf("failed to read %s balance: %v", token, err)
+		}
+		balances = append(balances, hexToBig(balance))
+	}
+	return balances, nil
+}
+
+// send submits one step and reads its receipt. A transaction the node
+// rejects is a failed outcome; an unreachable node is an error.
+func (s *BundleSimulator) send(ctx context.Context, fork *forkNode, step bundleStep) (BundleTxOutcome, error) {
+	var outcome BundleTxOutcome
+	var rpcErr *rpcError
+
+	var err error
+	if step.raw != "" {
+		err = fork.client.Call(ctx, "eth_sendRawTransaction", []interface{}{step.raw}, &outcome.Hash)
+	} else {
+		err = fork.client.Call(ctx, "hardhat_impersonateAccount", []interface{}{step.call["from"]}, nil)
+		if err == nil {
+			err = fork.client.Call(ctx, "eth_sendTransaction", []interface{}{step.call}, &outcome.Hash)
+		}
+	}
+	if errors.As(err, &rpcErr) {
+		outcome.Error = rpcErr.Message
+		return outcome, nil
+	}
+	if err != nil {
+		return outcome, err
+	}
+
+	var receipt *struct {
+		Status  string `json:"status"`
+		GasUsed string `json:"gasUsed"`
+	}
+	if err := fork.client.Call(ctx, "eth_getTransactionReceipt", []interface{}{outcome.Hash}, &receipt); err != nil {
+		return outcome, err
+	}
+	if receipt == nil {
+		outcome.Error = "transaction was not mined"
+		return outcome, nil
+	}
+	outcome.GasUsed = hexToUint64(receipt.GasUsed)
+	outcome.Success = receipt.Status == "0x1"
+	if !outcome.Success {
+		outcome.Error = "execution reverted"
+	}
+	return outcome, nil
+}
+
+// bundleSimulatorService is the server interface of scorpius.ingestion.v1.BundleSimulator
+type bundleSimulatorService interface {
+	SimulateBundle(context.Context, *SimulateBundleRequest) (*SimulateBundleResponse, error)
+}
+
+var bundleSimulatorServiceDesc = grpc.ServiceDesc{
+	ServiceName: "scorpius.ingestion.v1.BundleSimulator",
+	HandlerType: (*bundleSimulatorService)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "SimulateBundle",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := new(SimulateBundleRequest)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return srv.(bundleSimulatorService).SimulateBundle(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/scorpius.ingestion.v1.BundleSimulator/SimulateBundle"}
+			return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return srv.(bundleSimulatorService).SimulateBundle(ctx, req.(*SimulateBundleRequest))
+			})
+		},
+	}},
+	Metadata: "proto/bundle.proto",
+}
+
+// SimulateBundleRequest is scorpius.ingestion.v1.SimulateBundleRequest
+type SimulateBundleRequest struct {
+	Chain         string
+	Transactions  []BundleTx
+	Orderings     [][]uint32
+	ProfitAddress string
+	Tokens        []string
+}
+
+// BundleTx is scorpius.ingestion.v1.BundleTx
+type BundleTx struct {
+	Raw   string
+	Hash  string
+	From  string
+	To    string
+	Data  string
+	Value string
+	Gas   string
+}
+
+// SimulateBundleResponse is scorpius.ingestion.v1.SimulateBundleResponse
+type SimulateBundleResponse struct {
+	Chain     string
+	ForkBlock uint64
+	Scenarios []BundleScenario
+	Best      int32
+}
+
+// BundleScenario is scorpius.ingestion.v1.Scenario
+type BundleScenario struct {
+	Order        []uint32
+	Success      bool
+	Profit       string
+	TokenProfits []TokenProfit
+	GasUsed      uint64
+	Transactions []BundleTxOutcome
+}
+
+// TokenProfit is scorpius.ingestion.v1.TokenProfit
+type TokenProfit struct {
+	Token  string
+	Profit string
+}
+
+// BundleTxOutcome is scorpius.ingestion.v1.TxOutcome
+type BundleTxOutcome struct {
+	Index   uint32
+	Hash    string
+	Success bool
+	GasUsed uint64
+	Error   string
+}
+
+func (r *SimulateBundleRequest) marshalWire() []byte {
+	var b []byte
+	b = appendString(b, 1, r.Chain)
+	for _, tx := range r.Transactions {
+		b = appendMessage(b, 2, func(m []byte) []byte {
+			m = appendString(m, 1, tx.Raw)
+			m = appendString(m, 2, tx.Hash)
+			m = appendString(m, 3, tx.From)
+			m = appendString(m, 4, tx.To)
+			m = appendString(m, 5, tx.Data)
+			m = appendString(m, 6, tx.Value)
+			return appendString(m, 7, tx.Gas)
+		})
+	}
+	for _, order := range r.Orderings {
+		b = appendMessage(b, 3, func(m []byte) []byte {
+			return appendPackedVarints(m, 1, widenUint32s(order))
+		})
+	}
+	b = appendString(b, 4, r.ProfitAddress)
+	for _, token := range r.Tokens {
+		b = appendRepeatedString(b, 5, token)
+	}
+	return b
+}
+
+func (r *SimulateBundleRequest) unmarshalWire(b []byte) error {
+	return decodeFields(b, func(f wireField) error {
+		switch f.num {
+		case 1:
+			r.Chain = string(f.bytes)
+		case 2:
+			var tx BundleTx
+			err := decodeFields(f.bytes, func(f wireField) error {
+				fields := []*string{&tx.Raw, &tx.Hash, &tx.From, &tx.To, &tx.Data, &tx.Value, &tx.Gas}
+				if f.num >= 1 && int(f.num) <= len(fields) {
+					*fields[f.num-1] = string(f.bytes)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			r.Transactions = append(r.Transactions, tx)
+		case 3:
+			var order []uint32
+			err := decodeFields(f.bytes, func(f wireField) error {
+				if f.num != 1 {
+					return nil
+				}
+				values, err := consumeVarints(f)
+				for _, v := range values {
+					order = append(order, uint32(v))
+				}
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			r.Orderings = append(r.Orderings, order)
+		case 4:
+			r.ProfitAddress = string(f.bytes)
+		case 5:
+			r.Tokens = append(r.Tokens, string(f.bytes))
+		}
+		return nil
+	})
+}
+
+func (r *SimulateBundleResponse) marshalWire() []byte {
+	var b []byte
+	b = appendString(b, 1, r.Chain)
+	b = appendInt64(b, 2, int64(r.ForkBlock))
+	for _, scenario := range r.Scenarios {
+		b = appendMessage(b, 3, func(m []byte) []byte {
+			m = appendPackedVarints(m, 1, widenUint32s(scenario.Order))
+			m = appendBool(m, 2, scenario.Success)
+			m = appendString(m, 3, scenario.Profit)
+			for _, token := range scenario.TokenProfits {
+				m = appendMessage(m, 4, func(t []byte) []byte {
+					t = appendString(t, 1, token.Token)
+					return appendString(t, 2, token.Profit)
+				})
+			}
+			m = appendInt64(m, 5, int64(scenario.GasUsed))
+			for _, outcome := range scenario.Transactions {
+				m = appendMessage(m, 6, func(o []byte) []byte {
+					o = appendInt64(o, 1, int64(outcome.Index))
+					o = appendString(o, 2, outcome.Hash)
+					o = appendBool(o, 3, outcome.Success)
+					o = appendInt64(o, 4, int64(outcome.GasUsed))
+					return appendString(o, 5, outcome.Error)
+				})
+			}
+			return m
+		})
+	}
+	// int32 fields sign-extend negative values to ten bytes
+	if r.Best != 0 {
+		b = protowire.AppendTag(b, 4, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(int64(r.Best)))
+	}
+	return b
+}
+
+// unmarshalWire is unused by the server, which only sends responses
+func (r *SimulateBundleResponse) unmarshalWire(b []byte) error {
+	return fmt.Errorf("decoding SimulateBundleResponse is not supported")
+}
+
+func widenUint32s(values []uint32) []uint64 {
+	wide := make([]uint64, len(values))
+	for i, v := range values {
+		wide[i] = uint64(v)
+	}
+	return wide
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache backends
+const (
+	CacheRedis  = "redis"
+	CacheMemory = "memory"
+	CacheNone   = "none"
+)
+
+// errCacheMiss is returned by Cache.Get for a missing or expired key
+var errCacheMiss = errors.New("cache miss")
+
+// cacheEntry is one key written by Cache.SetMany
+type cacheEntry struct {
+	key   string
+	value []byte
+}
+
+// Cache is the key-value store behind deduplication, the transaction cache
+// and cached lookups. Redis shares state between instances and survives
+// restarts; the in-memory LRU serves a single instance; none keeps nothing,
+// which disables deduplication.
+//
+// Tags, watchlists, sanctions lists, the Redis Streams sink and transaction
+// indexes use Redis directly and need the redis backend.
+type Cache interface {
+	Name() string
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	SetMany(ctx context.Context, entries []cacheEntry, ttl time.Duration) error
+	// SetNX sets key only if it is absent and reports whether it did
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// Exists reports, for each key, whether it is present
+	Exists(ctx context.Context, keys ...string) ([]bool, error)
+	Delete(ctx context.Context, key string) error
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// newCache creates the configured cache backend. The Redis client is nil
+// for other backends.
+func newCache(config Config) (Cache, *redis.Client, error) {
+	switch config.CacheBackend {
+	case CacheRedis:
+		client := redis.NewClient(&redis.Options{
+			Addr: config.RedisURL,
+		})
+		if err := client.Ping(context.Background()).Err(); err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to Redis: %v", err)
+		}
+		return &redisCache{client: client}, client, nil
+	case CacheMemory:
+		return newMemoryCache(config.CacheSize), nil, nil
+	case CacheNone:
+		return noopCache{}, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown cache backend %q", config.CacheBackend)
+	}
+}
+
+// redisCache stores entries in Redis
+type redisCache struct {
+	client *redis.Client
+}
+
+func (c *redisCache) Name() string {
+	return CacheRedis
+}
+
+func (c *redisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, errCacheMiss
+	}
+	return value, err
+}
+
+func (c *redisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, key, value, ttl).Err()
+}
+
+// SetMany writes every entry in one pipeline
+func (c *redisCache) SetMany(ctx context.Context, entries []cacheEntry, ttl time.Duration) error {
+	pipe := c.client.Pipeline()
+	for _, entry := range entries {
+		pipe.Set(ctx, entry.key, entry.value, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *redisCache) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, key, value, ttl).Result()
+}
+
+// Exists checks every key in one pipeline
+func (c *redisCache) Exists(ctx context.Context, keys ...string) ([]bool, error) {
+	pipe := c.client.Pipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Exists(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	found := make([]bool, len(keys))
+	for i, cmd := range cmds {
+		found[i] = cmd.Val() > 0
+	}
+	return found, nil
+}
+
+func (c *redisCache) Delete(ctx context.Context, key string) error {
+	return c.client.Del(ctx, key).Err()
+}
+
+func (c *redisCache) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
+
+func (c *redisCache) Close() error {
+	return c.client.Close()
+}
+
+// memoryEntry is an in-memory cache value with its expiry
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// memoryCache is an in-process LRU cache holding up to size entries.
+// Expired entries are dropped when read or evicted.
+type memoryCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+func newMemoryCache(size int) *memoryCache {
+	return &memoryCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (c *memoryCache) Name() string {
+	return CacheMemory
+}
+
+// lookup returns key's live entry, marking it recently used; the caller holds c.mu
+func (c *memoryCache) lookup(key string) (*memoryEntry, bool) {
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*memoryEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	return entry, true
+}
+
+// store writes key, evicting the least recently used entry when full; the
+// caller holds c.mu
+func (c *memoryCache) store(key string, value []byte, ttl time.Duration) {
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*memoryEntry)
+		entry.value, entry.expires = value, expires
+		c.lru.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&memoryEntry{key: key, value: value, expires: expires})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryEntry).key)
+	}
+}
+
+func (c *memoryCache) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.lookup(key)
+	if !ok {
+		return nil, errCacheMiss
+	}
+	return entry.value, nil
+}
+
+func (c *memoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.store(key, value, ttl)
+	return nil
+}
+
+func (c *memoryCache) SetMany(ctx context.Context, entries []cacheEntry, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, entry := range entries {
+		c.store(entry.key, entry.value, ttl)
+	}
+	return nil
+}
+
+func (c *memoryCache) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.lookup(key); ok {
+		return false, nil
+	}
+	c.store(key, value, ttl)
+	return true, nil
+}
+
+func (c *memoryCache) Exists(ctx context.Context, keys ...string) ([]bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	found := make([]bool, len(keys))
+	for i, key := range keys {
+		_, found[i] = c.lookup(key)
+	}
+	return found, nil
+}
+
+func (c *memoryCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+	}
+	return nil
+}
+
+func (c *memoryCache) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (c *memoryCache) Close() error {
+	return nil
+}
+
+// noopCache stores nothing: every key is missing and every SetNX succeeds
+type noopCache struct{}
+
+func (noopCache) Name() string {
+	return CacheNone
+}
+
+func (noopCache) Get(ctx context.Context, key string) ([]byte, error) {
+	return nil, errCacheMiss
+}
+
+func (noopCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return nil
+}
+
+func (noopCache) SetMany(ctx context.Context, entries []cacheEntry, ttl time.Duration) error {
+	return nil
+}
+
+func (noopCache) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (noopCache) Exists(ctx context.Context, keys ...string) ([]bool, error) {
+	return make([]bool, len(keys)), nil
+}
+
+func (noopCache) Delete(ctx context.Context, key string) error {
+	return nil
+}
+
+func (noopCache) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (noopCache) Close() error {
+	return nil
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Canary sinks verified for every injected canary
+const (
+	canarySinkKafka = "kafka"
+	canarySinkRedis = "redis"
+)
+
+// canaryMarker is the raw field that labels a synthetic transaction as a
+// canary for anyone reading the raw payload. It is not trusted on ingest:
+// only transactions injected by the canary monitor are canaries.
+const canaryMarker = "scorpiusCanary"
+
+var (
+	canaryLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "scorpius_canary_latency_seconds",
+			Help:    "Time from canary injection to arrival at each sink",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+		},
+		[]string{"chain", "sink"},
+	)
+
+	canaryMissing = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_canary_missing_total",
+			Help: "Canaries that did not reach a sink within the SLO",
+		},
+		[]string{"chain", "sink"},
+	)
+)
+
+// pendingCanary tracks one injected canary until it is verified or expires
+type pendingCanary struct {
+	chain   string
+	sentAt  time.Time
+	arrived map[string]bool
+}
+
+// CanaryMonitor periodically injects synthetic canary transactions at the
+// source boundary of every chain monitor and checks that each one reaches
+// every sink within the SLO. Canaries carry "canary": true in the payload and
+// a canary header so downstream consumers can discard them.
+type CanaryMonitor struct {
+	monitors map[string]*ChainMonitor
+	brokers  string
+	cache    Cache
+	alerter  *Alerter
+	interval time.Duration
+	slo      time.Duration
+	mu       sync.Mutex
+	pending  map[string]*pendingCanary
+}
+
+// NewCanaryMonitor creates a canary monitor for the given chain monitors.
+// Kafka delivery is verified only when brokers is set, and only for chains
+// publishing to Kafka.
+func NewCanaryMonitor(monitors map[string]*ChainMonitor, brokers string, cache Cache, alerter *Alerter, interval, slo time.Duration) *CanaryMonitor {
+	return &CanaryMonitor{
+		monitors: monitors,
+		brokers:  brokers,
+		cache:    cache,
+		alerter:  alerter,
+		interval: interval,
+		slo:      slo,
+		pending:  make(map[string]*pendingCanary),
+	}
+}
+
+// Run injects and verifies canaries until ctx is cancelled
+func (c *CanaryMonitor) Run(ctx context.Context) error {
+	if c.brokers != "" {
+		consumer, err := c.newConsumer()
+		if err != nil {
+			return err
+		}
+		go c.consumeKafka(ctx, consumer)
+	}
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	slog.Info("canary verification enabled", "interval", c.interval, "slo", c.slo)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			c.expire(ctx)
+			c.injectAll()
+		}
+	}
+}
+
+// newConsumer creates a consumer that reads only new messages in its own group
+func (c *CanaryMonitor) newConsumer() (*kafka.Consumer, error) {
+	groupID := "scorpius-canary-" + instanceID()
+	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":  c.brokers,
+		"group.id":           groupID,
+		"auto.offset.reset":  "latest",
+		"enable.auto.commit": false,
+		"isolation.level":    "read_committed",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create canary consumer: %v", err)
+	}
+
+	// Every canary reaches its chain's base topic
+	var topics []string
+	for _, monitor := range c.monitors {
+		if topic := monitor.baseTopic(); !containsString(topics, topic) {
+			topics = append(topics, topic)
+		}
+	}
+
+	if err := consumer.SubscribeTopics(topics, nil); err != nil {
+		consumer.Close()
+		return nil, fmt.Errorf("failed to subscribe canary consumer: %v", err)
+	}
+	return consumer, nil
+}
+
+// injectAll sends one canary through each chain monitor's ingest path.
+// Standbys do not publish, so their canaries would never arrive.
+func (c *CanaryMonitor) injectAll() {
+	for chain, monitor := range c.monitors {
+		if monitor.standby() {
+			continue
+		}
+		hash := newCanaryHash()
+
+		c.mu.Lock()
+		c.pending[hash] = &pendingCanary{chain: chain, sentAt: time.Now(), arrived: make(map[string]bool)}
+		c.mu.Unlock()
+
+		if err := monitor.processCanary(canaryPayload(hash)); err != nil {
+			slog.Error("failed to inject canary", "chain", chain, "error", err)
+		}
+	}
+}
+
+// consumeKafka marks canaries as arrived when they are read back from the output topic
+func (c *CanaryMonitor) consumeKafka(ctx context.Context, consumer *kafka.Consumer) {
+	defer consumer.Close()
+
+	for ctx.Err() == nil {
+		msg, err := consumer.ReadMessage(time.Second)
+		if err != nil {
+			continue
+		}
+		if !isCanaryMessage(msg) {
+			continue
+		}
+		c.markArrived(string(msg.Key), canarySinkKafka)
+	}
+}
+
+func isCanaryMessage(msg *kafka.Message) bool {
+	for _, header := range msg.Headers {
+		if header.Key == "canary" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CanaryMonitor) markArrived(hash, sink string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	canary, ok := c.pending[hash]
+	if !ok || canary.arrived[sink] {
+		return
+	}
+	canary.arrived[sink] = true
+	canaryLatency.WithLabelValues(canary.chain, sink).Observe(time.Since(canary.sentAt).Seconds())
+}
+
+// expire checks the cache sink for outstanding canaries and alerts on any past the SLO
+func (c *CanaryMonitor) expire(ctx context.Context) {
+	c.mu.Lock()
+	var hashes []string
+	for hash := range c.pending {
+		hashes = append(hashes, hash)
+	}
+	c.mu.Unlock()
+	sort.Strings(hashes)
+
+	for _, hash := range hashes {
+		c.mu.Lock()
+		canary := c.pending[hash]
+		chain, redisDone := canary.chain, canary.arrived[canarySinkRedis]
+		c.mu.Unlock()
+
+		if !redisDone {
+			found, err := c.cache.Exists(ctx, cacheKey(chain, hash))
+			if err == nil && found[0] {
+				c.markArrived(hash, canarySinkRedis)
+			}
+		}
+
+		c.mu.Lock()
+		if time.Since(canary.sentAt) < c.slo {
+			c.mu.Unlock()
+			continue
+		}
+
+		var missing []string
+		for _, sink := range c.expectedSinks(chain) {
+			if !canary.arrived[sink] {
+				missing = append(missing, sink)
+				canaryMissing.WithLabelValues(chain, sink).Inc()
+			}
+		}
+		delete(c.pending, hash)
+		c.mu.Unlock()
+
+		if len(missing) > 0 {
+			c.alerter.Raise(Alert{
+				Chain:    chain,
+				Category: "canary_missing",
+				Severity: SeverityCritical,
+				Message:  fmt.Sprintf("canary %s did not reach %v within %s", hash, missing, c.slo),
+			})
+		}
+	}
+}
+
+// expectedSinks lists the sinks a chain's canaries must reach. Nothing is
+// cached with the none backend, so the cache is not checked, and a standby
+// publishes nothing, so a canary injected before stepping down is not lost.
+func (c *CanaryMonitor) expectedSinks(chain string) []string {
+	if c.monitors[chain].standby() {
+		return nil
+	}
+	var sinks []string
+	if c.brokers != "" && isKafkaSink(c.monitors[chain].sink) {
+		sinks = append(sinks, canarySinkKafka)
+	}
+	if c.cache.Name() != CacheNone {
+		sinks = append(sinks, canarySinkRedis)
+	}
+	return sinks
+}
+
+// newCanaryHash returns a random 32-byte hash with a recognizable prefix
+func newCanaryHash() string {
+	buf := make([]byte, 29)
+	rand.Read(buf)
+	return "0xca0a71" + hex.EncodeToString(buf)
+}
+
+// canaryPayload builds a raw pending transaction as a node would deliver it
+func canaryPayload(hash string) json.RawMessage {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"hash":       hash,
+		"from":       "0x000000000000000000000000000000000000ca0a",
+		"to":         "0x000000000000000000000000000000000000ca0a",
+		"value":      "0x0",
+		"gas":        "0x5208",
+		"gasPrice":   "0x0",
+		"input":      "0x",
+		"nonce":      "0x0",
+		"type":       "0x0",
+		canaryMarker: true,
+	})
+	return payload
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var endpointDisabled = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "scorpius_endpoint_disabled",
+		Help: "Whether an endpoint has been disabled due to misconfiguration (1) or not (0)",
+	},
+	[]string{"chain", "endpoint"},
+)
+
+// verifyChainID calls eth_chainId on a freshly dialed connection and checks it
+// against the configured chain. A mismatch usually means an RPC URL was pasted
+// under the wrong chain; the endpoint is disabled so transactions are never
+// published with the wrong chain label.
+func (cm *ChainMonitor) verifyChainID(conn *websocket.Conn, endpoint string) error {
+	const requestID = "chain_id_check"
+
+	if err := conn.WriteJSON(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      requestID,
+		"method":  "eth_chainId",
+		"params":  []interface{}{},
+	}); err != nil {
+		return fmt.Errorf("failed to request chain id: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+
+	for {
+		var msg map[string]interface{}
+		if err := conn.ReadJSON(&msg); err != nil {
+			return fmt.Errorf("failed to read chain id: %v", err)
+		}
+		if id, _ := msg["id"].(string); id != requestID {
+			continue
+		}
+
+		result, ok := msg["result"].(string)
+		if !ok {
+			return fmt.Errorf("eth_chainId returned no result")
+		}
+
+		chainID, err := strconv.ParseInt(strings.TrimPrefix(result, "0x"), 16, 64)
+		if err != nil {
+			return fmt.Errorf("invalid chain id %q: %v", result, err)
+		}
+
+		if chainID != cm.chainID {
+			cm.disableEndpoint(endpoint, fmt.Sprintf("chain id mismatch: endpoint reports %d, expected %d", chainID, cm.chainID))
+			return fmt.Errorf("endpoint %s reports chain id %d, expected %d", displayEndpoint(endpoint), chainID, cm.chainID)
+		}
+		return nil
+	}
+}
+
+// disableEndpoint permanently removes an endpoint from selection and raises a critical alert
+func (cm *ChainMonitor) disableEndpoint(endpoint, reason string) {
+	cm.mu.Lock()
+	cm.disabled[endpoint] = reason
+	cm.mu.Unlock()
+
+	endpointDisabled.WithLabelValues(cm.chainName, endpointLabel(endpoint)).Set(1)
+	cm.alerter.Raise(Alert{
+		Chain:    cm.chainName,
+		Category: "endpoint_misconfigured",
+		Severity: SeverityCritical,
+		Message:  fmt.Sprintf("disabled %s: %s", displayEndpoint(endpoint), reason),
+	})
+}
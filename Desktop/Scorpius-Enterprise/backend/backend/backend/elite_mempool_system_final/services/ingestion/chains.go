@@ -0,0 +1,178 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Ingest modes
+const (
+	IngestModeRPC       = "rpc"
+	IngestModeP2P       = "p2p"
+	IngestModeSequencer = "sequencer"
+)
+
+// Endpoint types, selected with a "<type>+" prefix on the endpoint URL
+// (e.g. bloxroute+wss://api.blxrbdn.com/ws). Plain URLs are standard RPC.
+// mock:// endpoints generate synthetic transactions for load and
+// integration tests.
+const (
+	EndpointRPC       = "rpc"
+	EndpointBloxroute = "bloxroute"
+	EndpointMock      = "mock"
+)
+
+// splitEndpointType separates an endpoint's type prefix from the URL to dial
+func splitEndpointType(endpoint string) (string, string) {
+	if strings.HasPrefix(endpoint, EndpointMock+"://") {
+		return EndpointMock, endpoint
+	}
+	if i := strings.Index(endpoint, "+"); i > 0 && !strings.Contains(endpoint[:i], "://") {
+		return endpoint[:i], endpoint[i+1:]
+	}
+	return EndpointRPC, endpoint
+}
+
+// Chain families
+const (
+	FamilyEVM      = "evm"
+	FamilySolana   = "solana"
+	FamilyUTXO     = "utxo"
+	FamilyStarknet = "starknet"
+	FamilyCosmos   = "cosmos"
+	FamilyTron     = "tron"
+	FamilyTON      = "ton"
+)
+
+// minPresetBlockPoll is the fastest a chain preset makes the block tracker poll
+const minPresetBlockPoll = 500 * time.Millisecond
+
+// ChainInfo describes a chain the service knows how to ingest. The preset
+// fields stand in for service-wide defaults the operator leaves unset;
+// <CHAIN>_ settings override both.
+type ChainInfo struct {
+	Name    string
+	ChainID int64
+	Family  string
+	// FinalityDepth is the default for <CHAIN>_BLOCK_CONFIRMATIONS
+	FinalityDepth int
+	// BlockTime is the typical block interval
+	BlockTime time.Duration
+	// SubscriptionMode is the suggested pending transaction subscription:
+	// hashes where common clients and providers do not stream full bodies
+	SubscriptionMode string
+	// Bech32Prefix is the account address prefix of Cosmos SDK chains
+	Bech32Prefix string
+	// CAIP2 is the CAIP-2 chain identifier of non-EVM chains; EVM chains
+	// use eip155:<chain ID>
+	CAIP2 string
+}
+
+// chainRegistry lists supported chains by configured name; a chain is
+// enabled by setting <CHAIN>_RPC_URLS (<CHAIN>_ZMQ_URLS for UTXO chains,
+// <CHAIN>_GRPC_URLS for Tron).
+// Chains without a numeric chain ID use 0; Starknet's is the felt encoding
+// of "SN_MAIN". zkSync Era speaks Ethereum JSON-RPC, and its extra fields
+// (l1BatchNumber, EIP-712 and L1 priority transaction types) pass through
+// in Raw. Cosmos SDK chains have instant finality and are reached through
+// Tendermint RPC: a websocket for committed transactions, or HTTP when
+// <CHAIN>_COSMOS_SOURCE=mempool. TON's chain ID is its global ID, -239.
+var chainRegistry = map[string]ChainInfo{
+	"ethereum":  {Name: "ethereum", ChainID: 1, Family: FamilyEVM, FinalityDepth: 2, BlockTime: 12 * time.Second, SubscriptionMode: SubscriptionFull},
+	"arbitrum":  {Name: "arbitrum", ChainID: 42161, Family: FamilyEVM, FinalityDepth: 2, BlockTime: 250 * time.Millisecond, SubscriptionMode: SubscriptionFull},
+	"optimism":  {Name: "optimism", ChainID: 10, Family: FamilyEVM, FinalityDepth: 2, BlockTime: 2 * time.Second, SubscriptionMode: SubscriptionFull},
+	"base":      {Name: "base", ChainID: 8453, Family: FamilyEVM, FinalityDepth: 2, BlockTime: 2 * time.Second, SubscriptionMode: SubscriptionFull},
+	"polygon":   {Name: "polygon", ChainID: 137, Family: FamilyEVM, FinalityDepth: 32, BlockTime: 2 * time.Second, SubscriptionMode: SubscriptionFull},
+	"bsc":       {Name: "bsc", ChainID: 56, Family: FamilyEVM, FinalityDepth: 15, BlockTime: 750 * time.Millisecond, SubscriptionMode: SubscriptionHashes},
+	"avalanche": {Name: "avalanche", ChainID: 43114, Family: FamilyEVM, FinalityDepth: 1, BlockTime: 2 * time.Second, SubscriptionMode: SubscriptionHashes},
+	"fantom":    {Name: "fantom", ChainID: 250, Family: FamilyEVM, FinalityDepth: 1, BlockTime: time.Second, SubscriptionMode: SubscriptionHashes},
+	"gnosis":    {Name: "gnosis", ChainID: 100, Family: FamilyEVM, FinalityDepth: 12, BlockTime: 5 * time.Second, SubscriptionMode: SubscriptionHashes},
+	"zksync":    {Name: "zksync", ChainID: 324, Family: FamilyEVM, FinalityDepth: 2, BlockTime: time.Second, SubscriptionMode: SubscriptionHashes},
+	"solana":    {Name: "solana", Family: FamilySolana, CAIP2: "solana:5eykt4UsFv8P8NJdTREpY1vzqKqZKvdp"},
+	"bitcoin":   {Name: "bitcoin", Family: FamilyUTXO, CAIP2: "bip122:000000000019d6689c085ae165831e93"},
+	"starknet":  {Name: "starknet", ChainID: 0x534e5f4d41494e, Family: FamilyStarknet, BlockTime: 6 * time.Second, CAIP2: "starknet:SN_MAIN"},
+	"cosmoshub": {Name: "cosmoshub", Family: FamilyCosmos, BlockTime: 6 * time.Second, Bech32Prefix: "cosmos", CAIP2: "cosmos:cosmoshub-4"},
+	"osmosis":   {Name: "osmosis", Family: FamilyCosmos, BlockTime: 1500 * time.Millisecond, Bech32Prefix: "osmo", CAIP2: "cosmos:osmosis-1"},
+	"celestia":  {Name: "celestia", Family: FamilyCosmos, BlockTime: 6 * time.Second, Bech32Prefix: "celestia", CAIP2: "cosmos:celestia"},
+	"tron":      {Name: "tron", ChainID: 728126428, Family: FamilyTron, FinalityDepth: 19, BlockTime: 3 * time.Second, CAIP2: "tron:0x2b6653dc"},
+	"ton":       {Name: "ton", ChainID: -239, Family: FamilyTON, BlockTime: 5 * time.Second, CAIP2: "ton:-239"},
+}
+
+// endpointsSetting names the setting listing a chain's endpoints
+func (c ChainInfo) endpointsSetting() string {
+	switch c.Family {
+	case FamilyUTXO:
+		return strings.ToUpper(c.Name) + "_ZMQ_URLS"
+	case FamilyTron:
+		return strings.ToUpper(c.Name) + "_GRPC_URLS"
+	}
+	return strings.ToUpper(c.Name) + "_RPC_URLS"
+}
+
+// withPresets applies the chain's presets to the service-wide subscription
+// mode, block poll interval and confirmations, except where the operator set
+// them explicitly. The block tracker polls at half the block time, never
+// slower than the service-wide interval.
+func (c ChainInfo) withPresets(mode string, poll time.Duration, confirms int) (string, time.Duration, int) {
+	if c.SubscriptionMode != "" && getEnv("SUBSCRIPTION_MODE") == "" {
+		mode = c.SubscriptionMode
+	}
+	if c.BlockTime > 0 && getEnv("BLOCK_POLL_INTERVAL") == "" {
+		poll = min(max(c.BlockTime/2, minPresetBlockPoll), poll)
+	}
+	if c.FinalityDepth > 0 && getEnv("BLOCK_CONFIRMATIONS") == "" {
+		confirms = c.FinalityDepth
+	}
+	return mode, poll, confirms
+}
+
+// Monitor is a running ingestion source for one chain
+type Monitor interface {
+	Start() error
+	Drain(reason string) DrainResult
+	Status() ChainStatus
+	base() *ChainMonitor
+}
+
+// base returns the shared chain monitor; family-specific monitors embed it
+func (cm *ChainMonitor) base() *ChainMonitor {
+	return cm
+}
+
+// wsProtocol adapts a websocket subscription dialect to the chain monitor.
+// The monitor owns dialing, reconnects and health scoring; the protocol only
+// supplies subscription requests and decodes notifications.
+type wsProtocol interface {
+	subscribeRequests() []interface{}
+	handleMessage(msg *rpcMessage) error
+}
+
+// streamer replaces the websocket transport for sources that are not
+// JSON-RPC over websocket. stream runs until the connection fails or the
+// monitor context is cancelled.
+type streamer interface {
+	stream(endpoint string) error
+}
+
+// streamEndpoint runs the monitor's streamer against endpoint, tracking it as the active connection
+func (cm *ChainMonitor) streamEndpoint(endpoint string) error {
+	cm.mu.Lock()
+	cm.activeEndpoint = endpoint
+	cm.mu.Unlock()
+	defer cm.clearActiveConn()
+
+	if err := cm.streamer.stream(endpoint); err != nil {
+		cm.updateHealthScore(endpoint, 0.5)
+		return err
+	}
+	return nil
+}
+
+// recordActivity marks endpoint as healthy after receiving data from it
+func (cm *ChainMonitor) recordActivity(endpoint string) {
+	cm.updateHealthScore(endpoint, 1.0)
+
+	cm.mu.Lock()
+	cm.lastSeen[endpoint] = time.Now()
+	cm.mu.Unlock()
+}
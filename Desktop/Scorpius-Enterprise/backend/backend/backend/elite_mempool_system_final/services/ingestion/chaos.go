@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var chaosInjected = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_chaos_faults_total",
+		Help: "Faults injected for resilience testing by chain and fault (drop, corrupt, delay, kill)",
+	},
+	[]string{"chain", "fault"},
+)
+
+// ChaosFaults are the faults injected while fault injection is enabled.
+// Percentages are of the messages read from each endpoint; durations use
+// Go syntax (e.g. "250ms"). Without chains every chain is affected.
+type ChaosFaults struct {
+	DropPercent    float64  `json:"drop_percent"`
+	CorruptPercent float64  `json:"corrupt_percent"`
+	ProduceDelay   string   `json:"produce_delay,omitempty"`
+	KillInterval   string   `json:"kill_interval,omitempty"`
+	Chains         []string `json:"chains,omitempty"`
+
+	produceDelay time.Duration
+	killInterval time.Duration
+}
+
+// parse validates the faults and resolves their durations
+func (f *ChaosFaults) parse() error {
+	for name, percent := range map[string]float64{"drop_percent": f.DropPercent, "corrupt_percent": f.CorruptPercent} {
+		if percent < 0 || percent > 100 {
+			return fmt.Errorf("%s must be between 0 and 100, got %g", name, percent)
+		}
+	}
+	for _, d := range []struct {
+		name   string
+		raw    string
+		target *time.Duration
+	}{
+		{"produce_delay", f.ProduceDelay, &f.produceDelay},
+		{"kill_interval", f.KillInterval, &f.killInterval},
+	} {
+		if d.raw == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.raw)
+		if err != nil || v < 0 {
+			return fmt.Errorf("%s must be a non-negative duration, got %q", d.name, d.raw)
+		}
+		*d.target = v
+	}
+	return nil
+}
+
+// chaosInjector injects the configured faults into the websocket read
+// path, sink produces and connections so reconnects, dedup and
+// backpressure can be exercised under controlled failure. It exists only
+// when CHAOS_ENABLED is set; a nil injector injects nothing.
+type chaosInjector struct {
+	mu     sync.RWMutex
+	faults ChaosFaults
+	// kills counts the connections killed per chain, so sources without a
+	// websocket notice they were killed
+	kills   map[string]uint64
+	changed chan struct{}
+}
+
+func newChaosInjector() *chaosInjector {
+	slog.Warn("fault injection is enabled; faults set through /api/chaos disrupt ingestion")
+	return &chaosInjector{kills: make(map[string]uint64), changed: make(chan struct{}, 1)}
+}
+
+// Faults returns the faults in effect
+func (c *chaosInjector) Faults() ChaosFaults {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.faults
+}
+
+// Set replaces the faults in effect; the zero value clears them
+func (c *chaosInjector) Set(faults ChaosFaults) error {
+	if err := faults.parse(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.faults = faults
+	c.mu.Unlock()
+
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+	slog.Warn("fault injection updated", "drop_percent", faults.DropPercent, "corrupt_percent", faults.CorruptPercent,
+		"produce_delay", faults.produceDelay, "kill_interval", faults.killInterval, "chains", faults.Chains)
+	return nil
+}
+
+// active returns the faults applying to chain
+func (c *chaosInjector) active(chain string) (ChaosFaults, bool) {
+	if c == nil {
+		return ChaosFaults{}, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.faults.Chains) > 0 && !containsString(c.faults.Chains, chain) {
+		return ChaosFaults{}, false
+	}
+	return c.faults, true
+}
+
+// chaosStrikes reports whether a fault with the given percentage strikes
+func chaosStrikes(chain, fault string, percent float64) bool {
+	if percent <= 0 || rand.Float64()*100 >= percent {
+		return false
+	}
+	chaosInjected.WithLabelValues(chain, fault).Inc()
+	return true
+}
+
+// Drop reports whether a message read from the chain's endpoint is lost
+func (c *chaosInjector) Drop(chain string) bool {
+	faults, ok := c.active(chain)
+	return ok && chaosStrikes(chain, "drop", faults.DropPercent)
+}
+
+// Corrupt returns the message, or a copy with overwritten bytes or cut
+// short, as a faulty connection might deliver it
+func (c *chaosInjector) Corrupt(chain string, data []byte) []byte {
+	faults, ok := c.active(chain)
+	if !ok || len(data) == 0 || !chaosStrikes(chain, "corrupt", faults.CorruptPercent) {
+		return data
+	}
+	if rand.Intn(2) == 0 {
+		return data[:rand.Intn(len(data))]
+	}
+	corrupted := append([]byte(nil), data...)
+	for i := 1 + rand.Intn(8); i > 0; i-- {
+		corrupted[rand.Intn(len(corrupted))] = byte(rand.Intn(256))
+	}
+	return corrupted
+}
+
+// Delay holds a produce back for the configured delay
+func (c *chaosInjector) Delay(ctx context.Context, chain string) error {
+	faults, ok := c.active(chain)
+	if !ok || faults.produceDelay <= 0 {
+		return nil
+	}
+	chaosInjected.WithLabelValues(chain, "delay").Inc()
+	timer := time.NewTimer(faults.produceDelay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Kills returns how many times the chain's connection has been killed
+func (c *chaosInjector) Kills(chain string) uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.kills[chain]
+}
+
+// Run kills the targeted chains' connections every kill interval until ctx
+// is done
+func (c *chaosInjector) Run(ctx context.Context, monitors func() []*ChainMonitor) {
+	for {
+		// Without a kill interval only a change of faults wakes the loop
+		var timer *time.Timer
+		var tick <-chan time.Time
+		if interval := c.Faults().killInterval; interval > 0 {
+			timer = time.NewTimer(interval)
+			tick = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-c.changed:
+		case <-tick:
+			for _, cm := range monitors() {
+				if _, ok := c.active(cm.chainName); ok {
+					c.kill(cm)
+				}
+			}
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// kill closes the monitor's connection so it reconnects
+func (c *chaosInjector) kill(cm *ChainMonitor) {
+	c.mu.Lock()
+	c.kills[cm.chainName]++
+	c.mu.Unlock()
+
+	cm.mu.Lock()
+	if cm.activeConn != nil {
+		cm.activeConn.Close()
+	}
+	cm.mu.Unlock()
+	chaosInjected.WithLabelValues(cm.chainName, "kill").Inc()
+	cm.logger.Warn("fault injection killed the connection")
+}
+
+// chaosSink delays produces for fault injection
+type chaosSink struct {
+	Sink
+	chaos *chaosInjector
+	chain string
+}
+
+func (s *chaosSink) Publish(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
+	if err := s.chaos.Delay(ctx, s.chain); err != nil {
+		return err
+	}
+	return s.Sink.Publish(ctx, topic, key, value, headers)
+}
+
+// chaosMonitors returns the chain monitors fault injection can kill
+func (is *IngestionService) chaosMonitors() []*ChainMonitor {
+	is.mu.RLock()
+	defer is.mu.RUnlock()
+	monitors := make([]*ChainMonitor, 0, len(is.monitors))
+	for _, monitor := range is.monitors {
+		monitors = append(monitors, monitor.base())
+	}
+	return monitors
+}
+
+// registerChaosAPI mounts /api/chaos: GET returns the injected faults, PUT
+// replaces them and DELETE clears them
+func (is *IngestionService) registerChaosAPI(mux *http.ServeMux) {
+	mux.HandleFunc("/api/chaos", func(w http.ResponseWriter, r *http.Request) {
+		if is.chaos == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "fault injection is not enabled"})
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var faults ChaosFaults
+			if err := json.NewDecoder(r.Body).Decode(&faults); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid faults: %v", err)})
+				return
+			}
+			if err := is.chaos.Set(faults); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+		case http.MethodDelete:
+			is.chaos.Set(ChaosFaults{})
+		default:
+			w.Header().Set("Allow", "GET, PUT, DELETE")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		writeJSON(w, http.StatusOK, is.chaos.Faults())
+	})
+}
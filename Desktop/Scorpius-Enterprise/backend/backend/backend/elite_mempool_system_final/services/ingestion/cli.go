@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Build metadata, set with -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=..."
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// cliCommand is one scorpius-ingestion subcommand
+type cliCommand struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+var cliCommands = []cliCommand{
+	{"run", "run the ingestion service (default)", cmdRun},
+	{"validate-config", "load and validate configuration, then exit", cmdValidateConfig},
+	{"probe-endpoints", "check connectivity and head height of configured endpoints", cmdProbeEndpoints},
+	{"replay", "re-publish archived transactions from Kafka or Parquet files", cmdReplay},
+	{"chains", "list the built-in chain presets", cmdChains},
+	{"version", "print build version and commit", cmdVersion},
+}
+
+// runCLI dispatches to a subcommand and returns the process exit code
+func runCLI(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return cmdRun(args)
+	}
+
+	for _, command := range cliCommands {
+		if command.name == args[0] {
+			return command.run(args[1:])
+		}
+	}
+
+	if args[0] == "help" {
+		usage()
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+	usage()
+	return 2
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: scorpius-ingestion <command> [flags]\n\nCommands:\n")
+	for _, command := range cliCommands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", command.name, command.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun 'scorpius-ingestion <command> -h' for command flags.\n")
+}
+
+// newFlagSet creates a subcommand flag set with the shared --config flag
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	configPath := flags.String("config", getEnv("CONFIG_FILE"), "config file (dotenv, YAML or TOML); environment variables override it")
+	return flags, configPath
+}
+
+// loadCLIConfig applies the config file, if any, and loads validated configuration
+func loadCLIConfig(configPath string) (Config, error) {
+	if configPath != "" {
+		// Later reloads read CONFIG_FILE
+		os.Setenv("CONFIG_FILE", configPath)
+		if err := applyConfigFile(configPath); err != nil {
+			return Config{}, err
+		}
+	}
+	return loadValidatedConfig()
+}
+
+// cmdRun runs the service until SIGINT or SIGTERM; SIGHUP reloads configuration
+func cmdRun(args []string) int {
+	flags, configPath := newFlagSet("run")
+	dryRun := flags.Bool("dry-run", getEnvBool("DRY_RUN", false), "process transactions without publishing or storing them")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *dryRun {
+		// Reloads read DRY_RUN
+		os.Setenv("DRY_RUN", "true")
+	}
+
+	config, err := loadCLIConfig(*configPath)
+	if err != nil {
+		slog.Error("failed to load configuration", "error", err)
+		return 1
+	}
+	setupLogging(config.LogLevel, config.LogFormat)
+	shutdownTracing, err := setupTracing(config.Tracing)
+	if err != nil {
+		slog.Error("failed to set up tracing", "error", err)
+		return 1
+	}
+	slog.Info("scorpius-ingestion", "version", versionString())
+
+	// Create ingestion service
+	service, err := NewIngestionService(config)
+	if err != nil {
+		slog.Error("failed to create ingestion service", "error", err)
+		return 1
+	}
+
+	// Start service
+	if err := service.Start(); err != nil {
+		slog.Error("failed to start service", "error", err)
+		return 1
+	}
+
+	// Wait for shutdown signal; SIGHUP reloads configuration
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		slog.Info("received SIGHUP, reloading configuration")
+		if err := service.Reload(); err != nil {
+			slog.Error("failed to reload configuration", "error", err)
+		}
+	}
+
+	// Graceful shutdown
+	service.Stop()
+
+	// Flush spans still buffered in the batch exporter
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("failed to flush traces", "error", err)
+	}
+	return 0
+}
+
+// cmdValidateConfig reports every configuration problem and exits non-zero if there are any
+func cmdValidateConfig(args []string) int {
+	flags, configPath := newFlagSet("validate-config")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// Problems are reported below; the per-value warnings would only repeat them
+	log.SetOutput(io.Discard)
+	config, err := loadCLIConfig(*configPath)
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	chains := make([]string, 0, len(config.ChainEndpoints))
+	for chainName, endpoints := range config.ChainEndpoints {
+		chains = append(chains, fmt.Sprintf("%s (%d endpoints)", chainName, len(endpoints)))
+	}
+	sort.Strings(chains)
+	fmt.Printf("Configuration OK: sink %s, %d chains: %s\n", config.Sink, len(chains), strings.Join(chains, ", "))
+	return 0
+}
+
+// endpointProbe is the outcome of a one-shot connectivity check
+type endpointProbe struct {
+	chain    string
+	endpoint string
+	status   string
+	latency  time.Duration
+	detail   string
+}
+
+// cmdProbeEndpoints dials every configured endpoint once and prints the results
+func cmdProbeEndpoints(args []string) int {
+	flags, configPath := newFlagSet("probe-endpoints")
+	chainFilter := flags.String("chain", "", "only probe this chain")
+	timeout := flags.Duration("timeout", 10*time.Second, "per-endpoint timeout")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	config, err := loadCLIConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var probes []endpointProbe
+	for chainName, endpoints := range config.ChainEndpoints {
+		if *chainFilter != "" && chainName != *chainFilter {
+			continue
+		}
+		chain := chainRegistry[chainName]
+		options := config.ChainOptions[chainName]
+		for _, endpoint := range endpoints {
+			probes = append(probes, probeOnce(chain, options, endpoint, *timeout))
+		}
+	}
+	sort.Slice(probes, func(i, j int) bool {
+		if probes[i].chain != probes[j].chain {
+			return probes[i].chain < probes[j].chain
+		}
+		return probes[i].endpoint < probes[j].endpoint
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CHAIN\tENDPOINT\tSTATUS\tLATENCY\tDETAIL")
+	failed := 0
+	for _, p := range probes {
+		if p.status != "ok" {
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.chain, p.endpoint, p.status, p.latency.Round(time.Millisecond), p.detail)
+	}
+	w.Flush()
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// probeOnce checks a single endpoint the way its monitor would connect to it
+func probeOnce(chain ChainInfo, options ChainOptions, endpoint string, timeout time.Duration) endpointProbe {
+	probe := endpointProbe{chain: chain.Name, endpoint: endpoint, status: "ok"}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	switch {
+	case options.IngestMode == IngestModeP2P:
+		probe.status = "skipped"
+		probe.detail = "p2p peers are not probed"
+		return probe
+
+	case options.IngestMode == IngestModeSequencer && sequencerFeeds[chain.ChainID].kind == SequencerOPStack:
+		head, err := unsafeHead(ctx, newRPCClient(endpoint, timeout))
+		if err != nil {
+			probe.status, probe.detail = "error", err.Error()
+			return probe
+		}
+		probe.detail = fmt.Sprintf("unsafe head %d", head)
+
+	case strings.HasPrefix(endpoint, EndpointMock+"://"):
+		source, err := parseMockEndpoint(endpoint)
+		if err != nil {
+			probe.status, probe.detail = "error", err.Error()
+			return probe
+		}
+		probe.detail = fmt.Sprintf("synthetic, %g tx/s", source.rate)
+
+	case chain.Family == FamilyUTXO:
+		// ZMQ endpoints are plain TCP, e.g. tcp://127.0.0.1:28332
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", strings.TrimPrefix(endpoint, "tcp://"))
+		if err != nil {
+			probe.status, probe.detail = "error", err.Error()
+			return probe
+		}
+		conn.Close()
+
+	case chain.Family == FamilyCosmos && options.CosmosSource == CosmosSourceMempool:
+		client := &http.Client{Timeout: timeout}
+		txs, err := fetchUnconfirmedTxs(ctx, client, strings.TrimSuffix(endpoint, "/")+"/unconfirmed_txs?limit="+strconv.Itoa(cosmosMempoolLimit))
+		if err != nil {
+			probe.status, probe.detail = "error", err.Error()
+			return probe
+		}
+		probe.detail = fmt.Sprintf("%d mempool transactions", len(txs))
+
+	case chain.Family == FamilyTron:
+		wallet, err := dialTronWallet(ctx, endpoint, options.TronAPIKey)
+		if err != nil {
+			probe.status, probe.detail = "error", err.Error()
+			return probe
+		}
+		defer wallet.Close()
+		height, err := wallet.NowBlock(ctx)
+		if err != nil {
+			probe.status, probe.detail = "error", err.Error()
+			return probe
+		}
+		probe.detail = fmt.Sprintf("height %d", height)
+
+	default:
+		endpointType, dialURL := splitEndpointType(endpoint)
+		var header http.Header
+		if endpointType == EndpointBloxroute {
+			header = http.Header{"Authorization": []string{options.BloxrouteAuth}}
+		}
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, dialURL, header)
+		if err != nil {
+			probe.status, probe.detail = "error", err.Error()
+			return probe
+		}
+		conn.Close()
+
+		if chain.Family == FamilyEVM && endpointType == EndpointRPC {
+			result := probeEndpoint(ctx, httpURLFor(dialURL))
+			switch {
+			case result.err != nil:
+				probe.detail = fmt.Sprintf("websocket ok, http probe failed: %v", result.err)
+			case result.syncing:
+				probe.status = "syncing"
+				probe.detail = fmt.Sprintf("height %d", result.height)
+			default:
+				probe.detail = fmt.Sprintf("height %d", result.height)
+			}
+		}
+	}
+	probe.latency = time.Since(start)
+	return probe
+}
+
+// cmdChains lists the chains that can be enabled by name, with their presets
+func cmdChains(args []string) int {
+	names := make([]string, 0, len(chainRegistry))
+	for name := range chainRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CHAIN\tFAMILY\tCHAIN ID\tBLOCK TIME\tCONFIRMATIONS\tSUBSCRIPTION\tENDPOINTS")
+	for _, name := range names {
+		chain := chainRegistry[name]
+		chainID, blockTime, confirms, mode := "-", "-", "-", "-"
+		if chain.ChainID != 0 {
+			chainID = fmt.Sprintf("%d", chain.ChainID)
+		}
+		if chain.BlockTime > 0 {
+			blockTime = chain.BlockTime.String()
+		}
+		if chain.FinalityDepth > 0 {
+			confirms = fmt.Sprintf("%d", chain.FinalityDepth)
+		}
+		if chain.SubscriptionMode != "" {
+			mode = chain.SubscriptionMode
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", name, chain.Family, chainID, blockTime, confirms, mode, chain.endpointsSetting())
+	}
+	w.Flush()
+	return 0
+}
+
+// cmdVersion prints build metadata
+func cmdVersion(args []string) int {
+	fmt.Printf("scorpius-ingestion %s\n", versionString())
+	return 0
+}
+
+// versionString describes the build, falling back to VCS stamps from the Go toolchain
+func versionString() string {
+	rev, date := commit, buildDate
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && rev == "":
+				rev = setting.Value
+			case setting.Key == "vcs.time" && date == "":
+				date = setting.Value
+			}
+		}
+	}
+	if rev == "" {
+		rev = "unknown"
+	}
+	if len(rev) > 12 {
+		rev = rev[:12]
+	}
+
+	s := fmt.Sprintf("%s (commit %s", version, rev)
+	if date != "" {
+		s += ", built " + date
+	}
+	return s + ", " + runtime.Version() + ")"
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// ClickHouseConfig configures the ClickHouse archiver
+type ClickHouseConfig struct {
+	DSN           string
+	Table         string
+	BatchSize     int
+	FlushInterval time.Duration
+	AsyncInsert   bool
+}
+
+// loadClickHouseConfig reads CLICKHOUSE_* settings; the archiver is disabled without a DSN
+func loadClickHouseConfig() ClickHouseConfig {
+	return ClickHouseConfig{
+		DSN:           getEnv("CLICKHOUSE_DSN"),
+		Table:         getEnvOrDefault("CLICKHOUSE_TABLE", "mempool_transactions"),
+		BatchSize:     getEnvInt("CLICKHOUSE_BATCH_SIZE", 10000),
+		FlushInterval: getEnvDuration("CLICKHOUSE_FLUSH_INTERVAL", time.Second),
+		AsyncInsert:   getEnvBool("CLICKHOUSE_ASYNC_INSERT", true),
+	}
+}
+
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// clickHouseSchema is the columnar layout for archived transactions. Quantities
+// are stored as integers rather than hex strings so they can be aggregated.
+const clickHouseSchema = `CREATE TABLE IF NOT EXISTS %s (
+	seen_at                  DateTime CODEC(Delta, ZSTD),
+	chain                    LowCardinality(String),
+	chain_id                 Int64,
+	chain_family             LowCardinality(String),
+	hash                     String,
+	type                     LowCardinality(String),
+	from_address             String,
+	to_address               String,
+	value                    UInt256,
+	gas                      UInt64,
+	gas_price                UInt256,
+	max_fee_per_gas          UInt256,
+	max_priority_fee_per_gas UInt256,
+	nonce                    UInt64,
+	data                     String CODEC(ZSTD(3)),
+	status                   LowCardinality(String),
+	token_standard           LowCardinality(String),
+	token                    String,
+	token_to                 String,
+	token_amount             String,
+	tags                     Array(LowCardinality(String))
+) ENGINE = MergeTree
+PARTITION BY toYYYYMMDD(seen_at)
+ORDER BY (chain, seen_at, hash)`
+
+// ClickHouseArchiver batch-inserts every published transaction into ClickHouse
+type ClickHouseArchiver struct {
+	conn    driver.Conn
+	table   string
+	batcher *archiveBatcher
+}
+
+// NewClickHouseArchiver connects to ClickHouse and creates the table if needed
+func NewClickHouseArchiver(config ClickHouseConfig) (*ClickHouseArchiver, error) {
+	if !tableNamePattern.MatchString(config.Table) {
+		return nil, fmt.Errorf("invalid ClickHouse table name %q", config.Table)
+	}
+
+	options, err := clickhouse.ParseDSN(config.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CLICKHOUSE_DSN: %v", err)
+	}
+	if config.AsyncInsert {
+		if options.Settings == nil {
+			options.Settings = clickhouse.Settings{}
+		}
+		// Let the server buffer inserts; we do not need to wait for the part to be written
+		options.Settings["async_insert"] = 1
+		options.Settings["wait_for_async_insert"] = 0
+	}
+
+	conn, err := clickhouse.Open(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ClickHouse: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := conn.Exec(ctx, fmt.Sprintf(clickHouseSchema, config.Table)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create ClickHouse table %s: %v", config.Table, err)
+	}
+
+	a := &ClickHouseArchiver{conn: conn, table: config.Table}
+	a.batcher = newArchiveBatcher(a.Name(), config.BatchSize, config.FlushInterval, a.insert)
+	return a, nil
+}
+
+// Name returns the archiver name
+func (a *ClickHouseArchiver) Name() string {
+	return "clickhouse"
+}
+
+// Archive queues tx for the next batch insert
+func (a *ClickHouseArchiver) Archive(tx Transaction) {
+	a.batcher.Add(tx)
+}
+
+// insert writes one batch with the native protocol
+func (a *ClickHouseArchiver) insert(txs []Transaction) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	batch, err := a.conn.PrepareBatch(ctx, "INSERT INTO "+a.table)
+	if err != nil {
+		return err
+	}
+
+	for _, tx := range txs {
+		var tokenStandard, token, tokenTo, tokenAmount string
+		if t := tx.TokenTransfer; t != nil {
+			tokenStandard, token, tokenTo, tokenAmount = t.Standard, t.Token, t.To, t.Amount
+		}
+		tags := make([]string, 0, len(tx.Tags))
+		for _, tag := range tx.Tags {
+			tags = append(tags, tag.Tag)
+		}
+
+		err := batch.Append(
+			time.Unix(tx.Timestamp, 0),
+			tx.Chain,
+			tx.ChainID,
+			tx.ChainFamily,
+			tx.Hash,
+			tx.Type,
+			tx.From,
+			tx.To,
+			hexToBig(tx.Value),
+			hexToUint64(tx.Gas),
+			hexToBig(tx.GasPrice),
+			hexToBig(tx.MaxFeePerGas),
+			hexToBig(tx.MaxPriorityFeePerGas),
+			hexToUint64(tx.Nonce),
+			tx.Data,
+			tx.Status,
+			tokenStandard,
+			token,
+			tokenTo,
+			tokenAmount,
+			tags,
+		)
+		if err != nil {
+			batch.Abort()
+			return err
+		}
+	}
+
+	return batch.Send()
+}
+
+// Close flushes buffered transactions and closes the connection
+func (a *ClickHouseArchiver) Close() {
+	a.batcher.Close()
+	a.conn.Close()
+}
@@ -0,0 +1,855 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/yaml.v3"
+)
+
+// keyValueSettings are settings written as maps in YAML/TOML and flattened
+// to key=value lists joined by the given separator
+var keyValueSettings = map[string]string{
+	"TOPIC_FORMATS":           ",",
+	"TOPIC_ROUTES":            ",",
+	"EXPR_ROUTES":             ";",
+	"KAFKA_MIRRORS":           ";",
+	"RPC_RATE_LIMITS":         ",",
+	"RPC_SUBSCRIPTION_LIMITS": ",",
+	"RPC_PROVIDER_HOSTS":      ",",
+	"RPC_PROVIDER_KEYS":       ";",
+	"AUTH_API_KEYS":           ",",
+	"AUTH_ADMIN_KEYS":         ",",
+	"SLO_LATENCY_THRESHOLDS":  ",",
+}
+
+// configAudit records which settings loadConfig reads and which values it
+// rejects, so a config file can be checked for typos and bad values
+var configAudit struct {
+	mu       sync.Mutex
+	active   bool
+	keys     map[string]bool
+	problems []string
+}
+
+// getEnv reads a setting from the environment, recording it during an audit
+// and resolving any ${secret:...} references in it
+func getEnv(key string) string {
+	configAudit.mu.Lock()
+	if configAudit.active {
+		configAudit.keys[key] = true
+	}
+	configAudit.mu.Unlock()
+
+	return secrets.Expand(key, os.Getenv(key))
+}
+
+// invalidSetting logs a rejected value and records it during an audit
+func invalidSetting(key, value, kind string, fallback interface{}) {
+	slog.Warn("invalid setting, using default", "key", key, "kind", kind, "value", value, "default", fallback)
+
+	configAudit.mu.Lock()
+	if configAudit.active {
+		configAudit.problems = append(configAudit.problems, fmt.Sprintf("%s: invalid %s %q", settingSource(key), kind, value))
+	}
+	configAudit.mu.Unlock()
+}
+
+// ConfigError lists every problem found while validating configuration
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// loadValidatedConfig loads configuration and checks it, returning a
+// ConfigError that lists malformed values, unknown config file settings and
+// inconsistent options together rather than failing on the first one
+func loadValidatedConfig() (Config, error) {
+	configAudit.mu.Lock()
+	configAudit.active = true
+	configAudit.keys = make(map[string]bool)
+	configAudit.problems = nil
+	configAudit.mu.Unlock()
+
+	// Secrets are configured first so the other settings can reference them
+	secretsConfig := loadSecretsConfig()
+	secrets.Configure(secretsConfig)
+	config := loadConfig()
+	config.Secrets = secretsConfig
+
+	configAudit.mu.Lock()
+	configAudit.active = false
+	problems := configAudit.problems
+	consulted := configAudit.keys
+	configAudit.mu.Unlock()
+
+	fileEnvMu.Lock()
+	var unknown []string
+	for key, source := range fileEnv {
+		if !consulted[key] {
+			unknown = append(unknown, fmt.Sprintf("%s (%s): unknown setting", source, key))
+		}
+	}
+	fileEnvMu.Unlock()
+	sort.Strings(unknown)
+
+	problems = append(problems, unknown...)
+	problems = append(problems, validateConfig(config)...)
+	if config.DryRun {
+		config = dryRunConfig(config)
+	}
+	if len(problems) > 0 {
+		return config, &ConfigError{Problems: problems}
+	}
+	return config, nil
+}
+
+// validateConfig checks option values and combinations that parse but cannot work
+func validateConfig(config Config) []string {
+	var problems []string
+	invalid := func(key, value string, allowed ...string) {
+		if !containsString(allowed, value) {
+			problems = append(problems, fmt.Sprintf("%s: %q is not one of %s", settingSource(key), value, strings.Join(allowed, ", ")))
+		}
+	}
+
+	if len(config.ChainEndpoints) == 0 {
+		problems = append(problems, "no chains configured: set <CHAIN>_RPC_URLS (or chains.<chain>.rpc_urls in the config file)")
+	}
+
+	invalid("SINK", config.Sink, SinkKafka, SinkNATS, SinkKinesis, SinkPubSub, SinkRedisStreams)
+	// Kafka settings only matter when a chain publishes to Kafka
+	usesKafka := config.Sink == SinkKafka
+	for _, options := range config.ChainOptions {
+		usesKafka = usesKafka || options.Sink == SinkKafka
+	}
+	if usesKafka {
+		invalid("KAFKA_PROFILE", config.Kafka.Profile, KafkaThroughput, KafkaLatency)
+		invalid("KAFKA_COMPRESSION", config.Kafka.Compression, kafkaCompressions...)
+		invalid("KAFKA_ACKS", config.Kafka.Acks, KafkaAcksAll, KafkaAcksLeader, KafkaAcksNone)
+		if config.KafkaIdempotent && config.Kafka.Acks != KafkaAcksAll {
+			problems = append(problems, fmt.Sprintf("%s: idempotent delivery needs acks=%s, got %q; set KAFKA_IDEMPOTENT=false to use it", settingSource("KAFKA_ACKS"), KafkaAcksAll, config.Kafka.Acks))
+		}
+		if config.Kafka.LingerMS < 0 {
+			problems = append(problems, fmt.Sprintf("%s: must not be negative, got %d", settingSource("KAFKA_LINGER_MS"), config.Kafka.LingerMS))
+		}
+		positive := map[string]int{
+			"KAFKA_BATCH_BYTES":        config.Kafka.BatchBytes,
+			"KAFKA_BATCH_MESSAGES":     config.Kafka.BatchMessages,
+			"KAFKA_MESSAGE_MAX_BYTES":  config.Kafka.MessageMaxBytes,
+			"KAFKA_QUEUE_MAX_MESSAGES": config.Kafka.QueueMaxMessages,
+		}
+		for key, value := range positive {
+			if value <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: must be positive, got %d", settingSource(key), value))
+			}
+		}
+		if config.KafkaHealthInterval <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("KAFKA_HEALTH_INTERVAL"), config.KafkaHealthInterval))
+		}
+		if config.KafkaHealthTimeout <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("KAFKA_HEALTH_TIMEOUT"), config.KafkaHealthTimeout))
+		}
+		for name, brokers := range config.KafkaMirrors {
+			switch {
+			case name == "" || name == kafkaPrimary:
+				problems = append(problems, fmt.Sprintf("%s: invalid mirror name %q", settingSource("KAFKA_MIRRORS"), name))
+			case brokers == "":
+				problems = append(problems, fmt.Sprintf("%s: mirror %s has no brokers", settingSource("KAFKA_MIRRORS"), name))
+			case brokers == config.KafkaBrokers:
+				problems = append(problems, fmt.Sprintf("%s: mirror %s is the primary cluster", settingSource("KAFKA_MIRRORS"), name))
+			}
+		}
+		if config.Spool.Enabled() {
+			if config.Spool.MaxMessages <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: must be positive, got %d", settingSource("SPOOL_MAX_MESSAGES"), config.Spool.MaxMessages))
+			}
+			if config.Spool.ReplayBatch <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: must be positive, got %d", settingSource("SPOOL_REPLAY_BATCH"), config.Spool.ReplayBatch))
+			}
+			if config.Spool.ReplayInterval <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("SPOOL_REPLAY_INTERVAL"), config.Spool.ReplayInterval))
+			}
+		}
+	}
+	invalid("CACHE_BACKEND", config.CacheBackend, CacheRedis, CacheMemory, CacheNone)
+	if config.CacheBackend == CacheMemory && config.CacheSize <= 0 {
+		problems = append(problems, fmt.Sprintf("%s: must be positive, got %d", settingSource("CACHE_MEMORY_SIZE"), config.CacheSize))
+	}
+	// Features built on Redis data structures have no other backend
+	needsRedis := func(key, feature string) {
+		if config.CacheBackend != CacheRedis {
+			problems = append(problems, fmt.Sprintf("%s: %s needs the %s cache backend, not %q", settingSource(key), feature, CacheRedis, config.CacheBackend))
+		}
+	}
+	if config.Sink == SinkRedisStreams {
+		needsRedis("SINK", "the Redis Streams sink")
+	}
+	if config.Watchlist.Enabled() {
+		needsRedis("WATCHLIST_ADDRESSES", "the watchlist")
+	}
+	if config.Leader.Enabled {
+		needsRedis("LEADER_ELECTION", "leader election")
+		if config.Leader.LeaseTTL <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("LEADER_LEASE_TTL"), config.Leader.LeaseTTL))
+		}
+		// A leader needs a second chance to renew before its lease lapses
+		if config.Leader.Renew <= 0 || config.Leader.Renew*2 > config.Leader.LeaseTTL {
+			problems = append(problems, fmt.Sprintf("%s: must be positive and at most half of LEADER_LEASE_TTL (%s), got %s", settingSource("LEADER_RENEW_INTERVAL"), config.Leader.LeaseTTL, config.Leader.Renew))
+		}
+		// MEV-Share events are fenced by their chain's lease, so a chain that
+		// is never monitored would never publish them
+		if _, ok := config.ChainEndpoints[config.MEVShareChain]; config.MEVShareURL != "" && !ok {
+			problems = append(problems, fmt.Sprintf("%s: %q has no RPC endpoints configured", settingSource("MEV_SHARE_CHAIN"), config.MEVShareChain))
+		}
+	}
+	if config.Shards.Enabled {
+		needsRedis("SHARDING", "sharding")
+		if config.Shards.Heartbeat <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("SHARD_HEARTBEAT"), config.Shards.Heartbeat))
+		}
+		// Instances must miss more than one heartbeat before being dropped
+		if config.Shards.TTL < 2*config.Shards.Heartbeat {
+			problems = append(problems, fmt.Sprintf("%s: must be at least twice SHARD_HEARTBEAT (%s), got %s", settingSource("SHARD_TTL"), config.Shards.Heartbeat, config.Shards.TTL))
+		}
+	}
+	if config.Sanctions.Enabled() {
+		needsRedis("SANCTIONS_FILES", "sanctions screening")
+	}
+	if config.Labels.Enabled() {
+		if config.Labels.SyncURL != "" {
+			needsRedis("LABEL_SYNC_URL", "address label sync")
+			if config.Labels.SyncInterval <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("LABEL_SYNC_INTERVAL"), config.Labels.SyncInterval))
+			}
+			if config.Labels.SyncTimeout <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("LABEL_SYNC_TIMEOUT"), config.Labels.SyncTimeout))
+			}
+		}
+		if config.Labels.Refresh <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("LABEL_REFRESH"), config.Labels.Refresh))
+		}
+	}
+	if len(config.RPCLimits.Keys) > 0 && config.RPCLimits.KeyCooldown <= 0 {
+		problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("RPC_KEY_COOLDOWN"), config.RPCLimits.KeyCooldown))
+	}
+	if config.Drain.Timeout <= 0 {
+		problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("SHUTDOWN_DRAIN_TIMEOUT"), config.Drain.Timeout))
+	}
+	if config.Secrets.Enabled() {
+		invalid("SECRETS_PROVIDER", config.Secrets.Provider, SecretsVault, SecretsAWS, SecretsFile)
+		if config.Secrets.Provider == SecretsVault && config.Secrets.VaultAddr == "" {
+			problems = append(problems, fmt.Sprintf("%s: the vault secrets provider needs the Vault address", settingSource("VAULT_ADDR")))
+		}
+		if config.Secrets.Provider == SecretsVault && config.Secrets.VaultToken == "" {
+			problems = append(problems, fmt.Sprintf("%s: the vault secrets provider needs VAULT_TOKEN or VAULT_TOKEN_FILE", settingSource("VAULT_TOKEN")))
+		}
+		if config.Secrets.Refresh <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("SECRETS_REFRESH"), config.Secrets.Refresh))
+		}
+		if config.Secrets.Timeout <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("SECRETS_TIMEOUT"), config.Secrets.Timeout))
+		}
+	}
+	if config.AlertCooldown < 0 {
+		problems = append(problems, fmt.Sprintf("%s: must not be negative, got %s", settingSource("ALERT_COOLDOWN"), config.AlertCooldown))
+	}
+	if config.AlertProduceFailures < 0 {
+		problems = append(problems, fmt.Sprintf("%s: must not be negative, got %d", settingSource("ALERT_PRODUCE_FAILURES_PER_MIN"), config.AlertProduceFailures))
+	}
+	if config.SLO.Enabled() {
+		if config.SLO.Threshold < 0 {
+			problems = append(problems, fmt.Sprintf("%s: must not be negative, got %s", settingSource("SLO_LATENCY_THRESHOLD"), config.SLO.Threshold))
+		}
+		for chain, threshold := range config.SLO.Thresholds {
+			if threshold <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: threshold for %s must be positive, got %s", settingSource("SLO_LATENCY_THRESHOLDS"), chain, threshold))
+			}
+		}
+		if config.SLO.Objective <= 0 || config.SLO.Objective >= 1 {
+			problems = append(problems, fmt.Sprintf("%s: must be between 0 and 1, got %g", settingSource("SLO_OBJECTIVE"), config.SLO.Objective))
+		}
+		if config.SLO.ShortWindow < sloBucketWidth {
+			problems = append(problems, fmt.Sprintf("%s: must be at least %s, got %s", settingSource("SLO_SHORT_WINDOW"), sloBucketWidth, config.SLO.ShortWindow))
+		}
+		if config.SLO.LongWindow < config.SLO.ShortWindow {
+			problems = append(problems, fmt.Sprintf("%s: must not be shorter than SLO_SHORT_WINDOW, got %s", settingSource("SLO_LONG_WINDOW"), config.SLO.LongWindow))
+		}
+		if config.SLO.BurnRate <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %g", settingSource("SLO_BURN_RATE"), config.SLO.BurnRate))
+		}
+		if config.SLO.MinEvents < 0 {
+			problems = append(problems, fmt.Sprintf("%s: must not be negative, got %d", settingSource("SLO_MIN_EVENTS"), config.SLO.MinEvents))
+		}
+		if url := config.SLO.WebhookURL; url != "" && !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			problems = append(problems, fmt.Sprintf("%s: must be an http or https URL", settingSource("SLO_WEBHOOK_URL")))
+		}
+	}
+	if config.Drain.Marker && config.Drain.MarkerTopic == "" {
+		problems = append(problems, fmt.Sprintf("%s: must not be empty while SHUTDOWN_MARKER is on", settingSource("SHUTDOWN_MARKER_TOPIC")))
+	}
+	invalid("MESSAGE_FORMAT", config.MessageFormat, FormatJSON, FormatAvro, FormatJSONSchema, FormatProtobuf)
+	for topic, format := range config.TopicFormats {
+		if !containsString([]string{FormatJSON, FormatAvro, FormatJSONSchema, FormatProtobuf}, format) {
+			problems = append(problems, fmt.Sprintf("%s: topic %s has unknown format %q", settingSource("TOPIC_FORMATS"), topic, format))
+		}
+	}
+	for _, version := range config.Envelope.Versions {
+		invalid("ENVELOPE_VERSIONS", version, EnvelopeV1, EnvelopeV2)
+	}
+	if len(config.Envelope.Versions) == 0 {
+		problems = append(problems, fmt.Sprintf("%s: must list at least one version", settingSource("ENVELOPE_VERSIONS")))
+	}
+	if containsString(config.Envelope.Versions, EnvelopeV1) && containsString(config.Envelope.Versions, EnvelopeV2) && config.Envelope.V2Suffix == "" {
+		problems = append(problems, fmt.Sprintf("%s: must not be empty while ENVELOPE_VERSIONS publishes both versions", settingSource("ENVELOPE_V2_TOPIC_SUFFIX")))
+	}
+	invalid("LOG_LEVEL", strings.ToLower(config.LogLevel), logLevels...)
+	invalid("LOG_FORMAT", config.LogFormat, LogFormatJSON, LogFormatConsole)
+	if config.Tracing.SampleRatio < 0 || config.Tracing.SampleRatio > 1 {
+		problems = append(problems, fmt.Sprintf("%s: must be between 0 and 1, got %g", settingSource("TRACE_SAMPLE_RATIO"), config.Tracing.SampleRatio))
+	}
+	if len(config.Watchlist.Sets) > 0 && config.Watchlist.Refresh <= 0 {
+		problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("WATCHLIST_REFRESH"), config.Watchlist.Refresh))
+	}
+	if config.MEV.Enabled {
+		if _, ok := parseWei(config.MEV.LargeSwap); !ok {
+			problems = append(problems, fmt.Sprintf("%s: invalid amount %q", settingSource("MEV_LARGE_SWAP_VALUE"), config.MEV.LargeSwap))
+		}
+		if config.MEV.MinConfidence < 0 || config.MEV.MinConfidence > 1 {
+			problems = append(problems, fmt.Sprintf("%s: must be between 0 and 1, got %g", settingSource("MEV_MIN_CONFIDENCE"), config.MEV.MinConfidence))
+		}
+	}
+	if config.GasOracle.Enabled {
+		if config.GasOracle.Window <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("GAS_ORACLE_WINDOW"), config.GasOracle.Window))
+		}
+		if config.GasOracle.Interval <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("GAS_ORACLE_INTERVAL"), config.GasOracle.Interval))
+		}
+		if _, err := parsePercentiles(config.GasOracle.Percentiles); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", settingSource("GAS_ORACLE_PERCENTILES"), err))
+		}
+	}
+	if config.Sanctions.Enabled() {
+		invalid("SANCTIONS_ACTION", config.Sanctions.Action, SanctionsTag, SanctionsSuppress)
+		if config.Sanctions.Refresh <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("SANCTIONS_REFRESH"), config.Sanctions.Refresh))
+		}
+	}
+	if config.Deploys.Enabled && (config.Deploys.RiskThreshold < 0 || config.Deploys.RiskThreshold > 1) {
+		problems = append(problems, fmt.Sprintf("%s: must be between 0 and 1, got %g", settingSource("DEPLOY_RISK_THRESHOLD"), config.Deploys.RiskThreshold))
+	}
+	if config.Simulation.Timeout <= 0 {
+		problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("SIMULATION_TIMEOUT"), config.Simulation.Timeout))
+	}
+	if config.Simulation.Concurrency <= 0 {
+		problems = append(problems, fmt.Sprintf("%s: must be positive, got %d", settingSource("SIMULATION_CONCURRENCY"), config.Simulation.Concurrency))
+	}
+	if config.Streams.Buffer <= 0 {
+		problems = append(problems, fmt.Sprintf("%s: must be positive, got %d", settingSource("STREAM_BUFFER"), config.Streams.Buffer))
+	}
+	if config.Streams.RateLimit < 0 {
+		problems = append(problems, fmt.Sprintf("%s: must not be negative, got %g", settingSource("STREAM_RATE_LIMIT"), config.Streams.RateLimit))
+	}
+	if config.Streams.RateBurst <= 0 {
+		problems = append(problems, fmt.Sprintf("%s: must be positive, got %d", settingSource("STREAM_RATE_BURST"), config.Streams.RateBurst))
+	}
+	if config.Streams.WebSocket && config.AdminAddr == "" {
+		problems = append(problems, fmt.Sprintf("%s: WebSocket streaming is served by the admin server and needs an address", settingSource("ADMIN_ADDR")))
+	}
+	if config.Streams.SSE {
+		if config.AdminAddr == "" {
+			problems = append(problems, fmt.Sprintf("%s: Server-Sent Events streaming is served by the admin server and needs an address", settingSource("ADMIN_ADDR")))
+		}
+		if config.Streams.SSEReplay <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %d", settingSource("SSE_REPLAY"), config.Streams.SSEReplay))
+		}
+	}
+	if config.GraphQL.Enabled {
+		if config.AdminAddr == "" {
+			problems = append(problems, fmt.Sprintf("%s: the GraphQL API is served by the admin server and needs an address", settingSource("ADMIN_ADDR")))
+		}
+		if config.GraphQL.Recent <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %d", settingSource("GRAPHQL_RECENT_TXS"), config.GraphQL.Recent))
+		}
+		if config.GraphQL.MaxDepth <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %d", settingSource("GRAPHQL_MAX_DEPTH"), config.GraphQL.MaxDepth))
+		}
+	}
+	if config.Streams.GRPC && config.GRPCAddr == "" {
+		problems = append(problems, fmt.Sprintf("%s: gRPC streaming needs an address", settingSource("GRPC_ADDR")))
+	}
+	if (config.Auth.TLSCert == "") != (config.Auth.TLSKey == "") {
+		problems = append(problems, fmt.Sprintf("%s: TLS_CERT_FILE and TLS_KEY_FILE must be set together", settingSource("TLS_CERT_FILE")))
+	}
+	invalid("TLS_CLIENT_AUTH", config.Auth.ClientAuth, ClientAuthNone, ClientAuthOptional, ClientAuthRequire)
+	if config.Auth.ClientCA != "" && !config.Auth.TLS() {
+		problems = append(problems, fmt.Sprintf("%s: client certificates need TLS_CERT_FILE and TLS_KEY_FILE", settingSource("TLS_CLIENT_CA_FILE")))
+	}
+	if config.Auth.ClientAuth != ClientAuthNone && config.Auth.ClientCA == "" {
+		problems = append(problems, fmt.Sprintf("%s: verifying client certificates needs TLS_CLIENT_CA_FILE", settingSource("TLS_CLIENT_AUTH")))
+	}
+	if config.Auth.JWTSecret != "" && config.Auth.JWTPublicKey != "" {
+		problems = append(problems, fmt.Sprintf("%s: set either AUTH_JWT_SECRET or AUTH_JWT_PUBLIC_KEY_FILE, not both", settingSource("AUTH_JWT_PUBLIC_KEY_FILE")))
+	}
+	invalid("AUTH_CERT_ROLE", config.Auth.CertRole, RoleRead, RoleAdmin)
+	tenantKeys := make(map[string]string)
+	for name, tenant := range config.Tenants {
+		prefix := tenantPrefix(name)
+		if len(tenant.Keys) == 0 {
+			problems = append(problems, fmt.Sprintf("%s: tenant %s has no keys", settingSource(prefix+"KEYS"), name))
+		}
+		for _, key := range tenant.Keys {
+			if other, ok := tenantKeys[key]; ok {
+				problems = append(problems, fmt.Sprintf("%s: tenant %s shares a key with tenant %s", settingSource(prefix+"KEYS"), name, other))
+			}
+			tenantKeys[key] = name
+		}
+		if tenant.RateLimit < 0 {
+			problems = append(problems, fmt.Sprintf("%s: must not be negative, got %g", settingSource(prefix+"RATE_LIMIT"), tenant.RateLimit))
+		}
+		if tenant.RateBurst <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %d", settingSource(prefix+"RATE_BURST"), tenant.RateBurst))
+		}
+		if tenant.MaxStreams < 0 {
+			problems = append(problems, fmt.Sprintf("%s: must not be negative, got %d", settingSource(prefix+"MAX_STREAMS"), tenant.MaxStreams))
+		}
+		for _, chain := range tenant.Chains {
+			if _, ok := chainRegistry[chain]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: unknown chain %q", settingSource(prefix+"CHAINS"), chain))
+			}
+		}
+	}
+	credentials := map[string]map[string]string{
+		"AUTH_API_KEYS":   config.Auth.APIKeys,
+		"AUTH_ADMIN_KEYS": config.Auth.AdminKeys,
+		"STREAM_TOKENS":   config.Streams.Tokens,
+	}
+	for setting, keys := range credentials {
+		for name, key := range keys {
+			if key == "" {
+				problems = append(problems, fmt.Sprintf("%s: %s has an empty key", settingSource(setting), name))
+			}
+		}
+	}
+	if config.BundleSim.Enabled() {
+		if config.GRPCAddr == "" {
+			problems = append(problems, fmt.Sprintf("%s: bundle simulation is served over gRPC and needs an address", settingSource("GRPC_ADDR")))
+		}
+		if config.BundleSim.Timeout <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("BUNDLE_SIM_TIMEOUT"), config.BundleSim.Timeout))
+		}
+		if config.BundleSim.MaxTxs <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %d", settingSource("BUNDLE_SIM_MAX_TXS"), config.BundleSim.MaxTxs))
+		}
+		forked := make(map[string]string)
+		for chain := range config.BundleSim.Forks {
+			forked[chain] = "BUNDLE_SIM_FORKS"
+		}
+		for _, chain := range config.BundleSim.Spawn {
+			forked[chain] = "BUNDLE_SIM_SPAWN"
+		}
+		for chain, key := range forked {
+			if _, ok := config.ChainEndpoints[chain]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: chain %q is not configured", settingSource(key), chain))
+			} else if chainRegistry[chain].Family != FamilyEVM {
+				problems = append(problems, fmt.Sprintf("%s: only EVM chains can be forked, not %q", settingSource(key), chain))
+			}
+		}
+	}
+	if config.Nonces.Enabled && config.Nonces.TTL <= 0 {
+		problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("NONCE_TRACKING_TTL"), config.Nonces.TTL))
+	}
+	tracking := false
+	for _, options := range config.ChainOptions {
+		tracking = tracking || options.BlockTracking
+	}
+	if config.MEV.Detection && !tracking {
+		problems = append(problems, fmt.Sprintf("%s: MEV detection needs BLOCK_TRACKING on at least one chain", settingSource("MEV_DETECTION")))
+	}
+	if config.PrivateFlow.Enabled && !tracking {
+		problems = append(problems, fmt.Sprintf("%s: private flow detection needs BLOCK_TRACKING on at least one chain", settingSource("PRIVATE_FLOW_DETECTION")))
+	}
+	if config.Drops.Enabled {
+		if !tracking {
+			problems = append(problems, fmt.Sprintf("%s: dropped transaction tracking needs BLOCK_TRACKING on at least one chain", settingSource("DROP_TRACKING")))
+		}
+		if config.Drops.Window <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("DROP_WINDOW"), config.Drops.Window))
+		}
+		if config.Drops.MaxTracked <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %d", settingSource("DROP_MAX_TRACKED"), config.Drops.MaxTracked))
+		}
+	}
+	beacon := false
+	for _, options := range config.ChainOptions {
+		beacon = beacon || options.BeaconURL != ""
+	}
+	if config.BeaconEvents.Enabled && !beacon {
+		problems = append(problems, fmt.Sprintf("%s: beacon event ingestion needs BEACON_URL on at least one chain", settingSource("BEACON_EVENTS")))
+	}
+	if config.Whales.Enabled {
+		if config.Whales.Threshold <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %g", settingSource("WHALE_THRESHOLD"), config.Whales.Threshold))
+		}
+		for symbol, value := range config.Whales.Thresholds {
+			if threshold, err := strconv.ParseFloat(value, 64); err != nil || threshold <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: expected a positive amount for %s, got %q", settingSource("WHALE_THRESHOLDS"), symbol, value))
+			}
+		}
+	}
+	if config.Bridges.Enabled && config.Bridges.Window <= 0 {
+		problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("BRIDGE_CORRELATION_WINDOW"), config.Bridges.Window))
+	}
+	lending := false
+	for _, options := range config.ChainOptions {
+		lending = lending || options.LendingSubgraph != ""
+	}
+	if config.Liquidations.Enabled {
+		if !lending {
+			problems = append(problems, fmt.Sprintf("%s: liquidation detection needs LENDING_SUBGRAPH on at least one chain", settingSource("LIQUIDATIONS")))
+		}
+		if config.Liquidations.HealthFactor <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %g", settingSource("LIQUIDATION_HEALTH_FACTOR"), config.Liquidations.HealthFactor))
+		}
+		if config.Liquidations.Refresh <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("LIQUIDATION_REFRESH_INTERVAL"), config.Liquidations.Refresh))
+		}
+		if config.Liquidations.MaxPositions <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %d", settingSource("LIQUIDATION_MAX_POSITIONS"), config.Liquidations.MaxPositions))
+		}
+	}
+	invalid("ENDPOINT_STRATEGY", config.EndpointStrategy, StrategyBest, StrategyWeighted, StrategyLatency, StrategyRoundRobin)
+	if _, err := newTopicRouter(config.TopicTemplate, config.TopicRoutes); err != nil {
+		problems = append(problems, fmt.Sprintf("%s: %v", settingSource("TOPIC_ROUTES"), err))
+	}
+	if _, err := compileExprRoutes(config.ExprRoutes); err != nil {
+		problems = append(problems, fmt.Sprintf("%s: %v", settingSource("EXPR_ROUTES"), err))
+	}
+	if len(config.ExactlyOnceTopics) > 0 && config.TransactionalID == "" {
+		problems = append(problems, fmt.Sprintf("%s: %s delivery requires a stable KAFKA_TRANSACTIONAL_ID", settingSource("EXACTLY_ONCE_TOPICS"), DeliveryExactlyOnce))
+	}
+
+	chains := make([]string, 0, len(config.ChainEndpoints))
+	for chainName := range config.ChainEndpoints {
+		chains = append(chains, chainName)
+	}
+	sort.Strings(chains)
+
+	for _, chainName := range chains {
+		prefix := strings.ToUpper(chainName) + "_"
+		if len(config.ChainEndpoints[chainName]) == 0 {
+			problems = append(problems, fmt.Sprintf("%s: no endpoints configured", chainName))
+		}
+		for _, endpoint := range config.ChainEndpoints[chainName] {
+			if endpointType, _ := splitEndpointType(endpoint); endpointType == EndpointMock {
+				if _, err := parseMockEndpoint(endpoint); err != nil {
+					problems = append(problems, fmt.Sprintf("%s: %v", chainName, err))
+				}
+			}
+			if !strings.Contains(endpoint, rpcKeyPlaceholder) {
+				continue
+			}
+			if provider := config.RPCLimits.Provider(endpoint); len(config.RPCLimits.Keys[provider]) == 0 {
+				problems = append(problems, fmt.Sprintf("%s: endpoint %s has a %s placeholder but %s has no keys in RPC_PROVIDER_KEYS", chainName, displayEndpoint(endpoint), rpcKeyPlaceholder, provider))
+			}
+		}
+
+		options := config.ChainOptions[chainName]
+		invalid(prefix+"SUBSCRIPTION_MODE", options.SubscriptionMode, SubscriptionFull, SubscriptionHashes, SubscriptionAlchemy, SubscriptionAuto)
+		for _, mode := range options.EndpointModes {
+			invalid(prefix+"ENDPOINT_SUBSCRIPTIONS", mode, SubscriptionFull, SubscriptionHashes, SubscriptionAlchemy, SubscriptionAuto)
+		}
+		if options.WatchlistFilter {
+			if !config.Watchlist.Enabled() {
+				problems = append(problems, fmt.Sprintf("%s: filtering the subscription on the watchlist needs WATCHLIST_ADDRESSES or WATCHLIST_SETS", settingSource(prefix+"SUBSCRIPTION_WATCHLIST")))
+			}
+			modes := []string{options.SubscriptionMode}
+			for _, mode := range options.EndpointModes {
+				modes = append(modes, mode)
+			}
+			if !containsString(modes, SubscriptionAlchemy) && !containsString(modes, SubscriptionAuto) {
+				problems = append(problems, fmt.Sprintf("%s: only %s subscriptions are filtered server-side; set %sSUBSCRIPTION_MODE to %s or %s", settingSource(prefix+"SUBSCRIPTION_WATCHLIST"), SubscriptionAlchemy, prefix, SubscriptionAlchemy, SubscriptionAuto))
+			}
+		}
+		invalid(prefix+"DELIVERY_MODE", options.DeliveryMode, DeliveryAtLeastOnce, DeliveryExactlyOnce)
+		invalid(prefix+"INGEST_MODE", options.IngestMode, IngestModeRPC, IngestModeP2P, IngestModeSequencer)
+		if options.IngestMode == IngestModeSequencer {
+			feed, ok := sequencerFeeds[chainRegistry[chainName].ChainID]
+			switch {
+			case !ok:
+				problems = append(problems, fmt.Sprintf("%s: sequencer feeds are not supported for %s", settingSource(prefix+"INGEST_MODE"), chainName))
+			case feed.kind == SequencerOPStack && options.SequencerEngine == "":
+				problems = append(problems, fmt.Sprintf("%s: OP-stack sequencer ingestion needs %sSEQUENCER_ENGINE_URL", chainName, prefix))
+			case feed.kind == SequencerOPStack && options.SequencerPoll <= 0:
+				problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource(prefix+"SEQUENCER_POLL_INTERVAL"), options.SequencerPoll))
+			}
+		}
+		invalid(prefix+"QUEUE_POLICY", options.QueuePolicy, QueueDrop, QueuePark)
+		invalid(prefix+"ENDPOINT_STRATEGY", options.EndpointStrategy, StrategyBest, StrategyWeighted, StrategyLatency, StrategyRoundRobin)
+		if chainRegistry[chainName].Family == FamilyCosmos {
+			invalid(prefix+"COSMOS_SOURCE", options.CosmosSource, CosmosSourceEvents, CosmosSourceMempool)
+			if options.CosmosSource == CosmosSourceMempool && options.CosmosPoll <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource(prefix+"COSMOS_MEMPOOL_POLL"), options.CosmosPoll))
+			}
+		}
+		if chainRegistry[chainName].Family == FamilyTron && options.TronPoll <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource(prefix+"TRON_PENDING_POLL"), options.TronPoll))
+		}
+		if options.LogLevel != "" {
+			invalid(prefix+"LOG_LEVEL", strings.ToLower(options.LogLevel), logLevels...)
+		}
+
+		positive := map[string]int{
+			prefix + "WORKERS":           options.Workers,
+			prefix + "QUEUE_CAPACITY":    options.QueueCapacity,
+			prefix + "HYDRATION_WORKERS": options.HydrationWorkers,
+			prefix + "CACHE_BATCH_SIZE":  options.CacheBatch,
+		}
+		for key, value := range positive {
+			if value <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: must be positive, got %d", settingSource(key), value))
+			}
+		}
+		if options.CacheTTL <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource(prefix+"CACHE_TTL"), options.CacheTTL))
+		}
+		if options.Sink == SinkRedisStreams && config.Sink != SinkRedisStreams {
+			needsRedis(prefix+"SINK", "the Redis Streams sink")
+		}
+		if options.Indexes {
+			needsRedis(prefix+"CACHE_INDEXES", "transaction indexes")
+			if options.IndexMax <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: must be positive, got %d", settingSource(prefix+"CACHE_INDEX_MAX"), options.IndexMax))
+			}
+			if options.IndexTTL <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource(prefix+"CACHE_INDEX_TTL"), options.IndexTTL))
+			}
+		}
+		if options.CacheBatch > 1 && options.CacheFlush <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive when batching, got %s", settingSource(prefix+"CACHE_FLUSH_INTERVAL"), options.CacheFlush))
+		}
+		invalid(prefix+"FILTER_ACTION", options.FilterAction, FilterDrop, FilterRoute)
+		if _, err := newTxFilter(options); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: filter rules: %v", chainName, err))
+		}
+		if options.BackoffMax < options.BackoffBase {
+			problems = append(problems, fmt.Sprintf("%s: %s is below the base backoff %s", settingSource(prefix+"RECONNECT_BACKOFF_MAX"), options.BackoffMax, options.BackoffBase))
+		}
+		if options.ReadTimeout > 0 && options.PingInterval >= options.ReadTimeout {
+			problems = append(problems, fmt.Sprintf("%s: ping interval %s must be shorter than the read timeout %s", settingSource(prefix+"WS_PING_INTERVAL"), options.PingInterval, options.ReadTimeout))
+		}
+		if options.BlockTracking {
+			if options.BlockPoll <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource(prefix+"BLOCK_POLL_INTERVAL"), options.BlockPoll))
+			}
+			if options.BlockConfirms < 0 {
+				problems = append(problems, fmt.Sprintf("%s: must not be negative, got %d", settingSource(prefix+"BLOCK_CONFIRMATIONS"), options.BlockConfirms))
+			}
+			if options.ReorgAlertDepth < 0 {
+				problems = append(problems, fmt.Sprintf("%s: must not be negative, got %d", settingSource(prefix+"ALERT_REORG_DEPTH"), options.ReorgAlertDepth))
+			}
+			if (options.IngestMode == IngestModeP2P || options.IngestMode == IngestModeSequencer) && options.BlockURL == "" {
+				problems = append(problems, fmt.Sprintf("%s: block tracking in %s mode needs %sBLOCK_URL", chainName, options.IngestMode, prefix))
+			}
+			if config.PrivateFlow.Enabled && options.DedupTTL <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: private flow detection needs dedup enabled to know which transactions were seen", settingSource(prefix+"DEDUP_TTL")))
+			}
+		}
+		if options.BeaconURL != "" && chainRegistry[chainName].Family != FamilyEVM {
+			problems = append(problems, fmt.Sprintf("%s: beacon nodes only serve EVM chains", settingSource(prefix+"BEACON_URL")))
+		}
+		if len(options.BundlerURLs) > 0 {
+			if chainRegistry[chainName].Family != FamilyEVM {
+				problems = append(problems, fmt.Sprintf("%s: ERC-4337 bundlers only serve EVM chains", settingSource(prefix+"BUNDLER_URLS")))
+			}
+			if config.UserOps.Poll <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: must be positive, got %s", settingSource("USEROPS_POLL_INTERVAL"), config.UserOps.Poll))
+			}
+		}
+		for address, protocol := range options.Bridges {
+			if err := validateBridge(address, protocol, chainRegistry[chainName].ChainID); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", settingSource(prefix+"BRIDGES"), err))
+			}
+		}
+		for symbol, value := range options.Stablecoins {
+			if _, _, err := parseStablecoin(symbol, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", settingSource(prefix+"STABLECOINS"), err))
+			}
+		}
+		if options.LendingSubgraph != "" && chainRegistry[chainName].Family != FamilyEVM {
+			problems = append(problems, fmt.Sprintf("%s: lending positions are only tracked on EVM chains", settingSource(prefix+"LENDING_SUBGRAPH")))
+		}
+		if len(options.PriceFeeds) > 0 && options.LendingSubgraph == "" {
+			problems = append(problems, fmt.Sprintf("%s: price feeds reprice lending positions and need %sLENDING_SUBGRAPH", settingSource(prefix+"PRICE_FEEDS"), prefix))
+		}
+		for aggregator, asset := range options.PriceFeeds {
+			if !common.IsHexAddress(aggregator) || !common.IsHexAddress(asset) {
+				problems = append(problems, fmt.Sprintf("%s: expected aggregator=asset addresses, got %s=%s", settingSource(prefix+"PRICE_FEEDS"), aggregator, asset))
+			}
+		}
+		if config.Blobs.Enabled && options.BeaconURL != "" && !options.BlockTracking {
+			problems = append(problems, fmt.Sprintf("%s: blob sidecars are fetched for confirmed blocks and need %sBLOCK_TRACKING", settingSource(prefix+"BEACON_URL"), prefix))
+		}
+		if options.SimulationURL != "" {
+			if _, err := newSimulator(options, config.Simulation); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", settingSource(prefix+"SIMULATION_EXPR"), err))
+			}
+		}
+		if options.DeliveryMode == DeliveryExactlyOnce && options.Sink != SinkKafka {
+			problems = append(problems, fmt.Sprintf("%s: %s delivery requires the kafka sink", settingSource(prefix+"DELIVERY_MODE"), DeliveryExactlyOnce))
+		}
+		// A replacement instance fences a crashed one's open transaction only
+		// under the same transactional.id, which a hostname does not give
+		if options.DeliveryMode == DeliveryExactlyOnce && config.TransactionalID == "" {
+			problems = append(problems, fmt.Sprintf("%s: %s delivery requires a stable KAFKA_TRANSACTIONAL_ID", settingSource(prefix+"DELIVERY_MODE"), DeliveryExactlyOnce))
+		}
+	}
+
+	return problems
+}
+
+// settingSource names where a setting came from, for error messages
+func settingSource(key string) string {
+	fileEnvMu.Lock()
+	defer fileEnvMu.Unlock()
+
+	if source, ok := fileEnv[key]; ok {
+		return fmt.Sprintf("%s (%s)", source, key)
+	}
+	return key
+}
+
+// readConfigFile parses a config file into environment-style settings, each
+// mapped to its source for error messages. YAML and TOML files are flattened
+// by joining nested keys with underscores, with an optional top-level chains
+// section (chains.ethereum.rpc_urls becomes ETHEREUM_RPC_URLS); anything else
+// is read as KEY=VALUE lines.
+func readConfigFile(path string) (map[string]string, map[string]string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".toml":
+	default:
+		return readEnvFile(path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	var tree map[string]interface{}
+	if strings.ToLower(filepath.Ext(path)) == ".toml" {
+		err = toml.Unmarshal(data, &tree)
+	} else {
+		err = yaml.Unmarshal(data, &tree)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	values := make(map[string]string)
+	sources := make(map[string]string)
+	var problems []string
+	for key, value := range tree {
+		if key == "chains" {
+			chains, ok := value.(map[string]interface{})
+			if !ok {
+				problems = append(problems, "chains: expected a map of chain names to settings")
+				continue
+			}
+			for chainName, settings := range chains {
+				flattenSetting(chainName, "chains."+chainName, settings, values, sources, &problems)
+			}
+			continue
+		}
+		flattenSetting(key, key, value, values, sources, &problems)
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, nil, &ConfigError{Problems: problems}
+	}
+	return values, sources, nil
+}
+
+// flattenSetting converts one YAML/TOML value into environment-style settings
+func flattenSetting(key, path string, value interface{}, values, sources map[string]string, problems *[]string) {
+	envKey := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if separator, ok := keyValueSettings[envKey]; ok {
+			pairs := make([]string, 0, len(v))
+			for name, item := range v {
+				pairs = append(pairs, fmt.Sprintf("%s=%v", name, item))
+			}
+			sort.Strings(pairs)
+			values[envKey] = strings.Join(pairs, separator)
+			sources[envKey] = path
+			return
+		}
+		for name, item := range v {
+			flattenSetting(key+"_"+name, path+"."+name, item, values, sources, problems)
+		}
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				*problems = append(*problems, fmt.Sprintf("%s: lists may only contain scalar values", path))
+				return
+			}
+			items = append(items, fmt.Sprint(item))
+		}
+		values[envKey] = strings.Join(items, ",")
+		sources[envKey] = path
+	case nil:
+		values[envKey] = ""
+		sources[envKey] = path
+	default:
+		values[envKey] = fmt.Sprint(v)
+		sources[envKey] = path
+	}
+}
+
+// readEnvFile parses KEY=VALUE lines. Blank lines and # comments are ignored.
+func readEnvFile(path string) (map[string]string, map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open config file: %v", err)
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	sources := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(text, "export "), "=")
+		if !ok {
+			return nil, nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, line)
+		}
+		key = strings.TrimSpace(key)
+		values[key] = strings.Trim(strings.TrimSpace(value), `"'`)
+		sources[key] = fmt.Sprintf("%s:%d", filepath.Base(path), line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	return values, sources, nil
+}
@@ -0,0 +1,580 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ripemd160"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Cosmos transaction sources, selected with <CHAIN>_COSMOS_SOURCE
+const (
+	// CosmosSourceEvents subscribes to committed transactions over the
+	// Tendermint websocket (ws://host:26657/websocket)
+	CosmosSourceEvents = "events"
+	// CosmosSourceMempool polls unconfirmed_txs over Tendermint HTTP RPC
+	// (http://host:26657) for transactions still in the mempool
+	CosmosSourceMempool = "mempool"
+)
+
+// cosmosMempoolLimit is how many mempool transactions one poll reads, the
+// most Tendermint returns per request
+const cosmosMempoolLimit = 100
+
+// CosmosMonitor ingests transactions from Cosmos SDK chains through
+// Tendermint (CometBFT) RPC, either as Tx events once committed or from the
+// mempool. Transaction bytes are decoded from protobuf, or amino for
+// pre-Stargate chains, into the chain-agnostic envelope; the bytes and the
+// decoded messages are kept in Raw.
+type CosmosMonitor struct {
+	*ChainMonitor
+	prefix string
+}
+
+// NewCosmosMonitor creates a Cosmos monitor sharing the chain monitor's connection management
+func NewCosmosMonitor(chain ChainInfo, endpoints []string, options ChainOptions, sink Sink, cache Cache, alerter *Alerter) *CosmosMonitor {
+	cm := &CosmosMonitor{
+		ChainMonitor: NewChainMonitor(chain.Name, chain.ChainID, endpoints, options, sink, cache, alerter),
+		prefix:       chain.Bech32Prefix,
+	}
+	cm.family = chain.Family
+	cm.protocol = cm
+	if options.CosmosSource == CosmosSourceMempool {
+		cm.streamer = cm
+	}
+	return cm
+}
+
+// subscribeRequests subscribes to every committed transaction
+func (cm *CosmosMonitor) subscribeRequests() []interface{} {
+	return []interface{}{map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "subscribe",
+		"params":  map[string]string{"query": "tm.event='Tx'"},
+	}}
+}
+
+// cosmosTxEvent is the result of a Tx event notification
+type cosmosTxEvent struct {
+	Data struct {
+		Value struct {
+			TxResult *struct {
+				Height string          `json:"height"`
+				Index  int             `json:"index"`
+				Tx     []byte          `json:"tx"`
+				Result json.RawMessage `json:"result"`
+			} `json:"TxResult"`
+		} `json:"value"`
+	} `json:"data"`
+}
+
+// handleMessage maps Tx events into the Transaction envelope. Tendermint
+// delivers events as results of the subscribe request, without a method.
+func (cm *CosmosMonitor) handleMessage(msg *rpcMessage) error {
+	if msg.Error != nil {
+		return fmt.Errorf("tx event subscription failed: %v", msg.Error)
+	}
+	if isJSONNull(msg.Result) {
+		return nil
+	}
+
+	var event cosmosTxEvent
+	if err := wireJSON.Unmarshal(msg.Result, &event); err != nil {
+		return fmt.Errorf("failed to decode tx event: %v", err)
+	}
+	result := event.Data.Value.TxResult
+	if result == nil || len(result.Tx) == 0 {
+		return nil
+	}
+
+	var outcome struct {
+		Code int `json:"code"`
+	}
+	if len(result.Result) > 0 {
+		if err := wireJSON.Unmarshal(result.Result, &outcome); err != nil {
+			return fmt.Errorf("failed to decode tx result: %v", err)
+		}
+	}
+	status := "confirmed"
+	if outcome.Code != 0 {
+		status = "failed"
+	}
+
+	tx := cm.envelope(result.Tx, status, result.Result)
+	if height, err := strconv.ParseInt(result.Height, 10, 64); err == nil {
+		tx.BlockNumber = &height
+	}
+	index := result.Index
+	tx.TransactionIndex = &index
+	return cm.publishTransaction(tx)
+}
+
+// stream polls the mempool of the Tendermint HTTP endpoint, publishing each
+// transaction the first time it is seen there
+func (cm *CosmosMonitor) stream(endpoint string) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	mempoolURL := strings.TrimSuffix(endpoint, "/") + "/unconfirmed_txs?limit=" + strconv.Itoa(cosmosMempoolLimit)
+	ticker := time.NewTicker(cm.options.CosmosPoll)
+	defer ticker.Stop()
+
+	seen := make(map[string]bool)
+	for first := true; ; first = false {
+		start := time.Now()
+		txs, err := fetchUnconfirmedTxs(cm.ctx, client, mempoolURL)
+		if err != nil {
+			if cm.ctx.Err() != nil {
+				return nil
+			}
+			if first {
+				cm.updateHealthScore(endpoint, 0.0)
+			}
+			return err
+		}
+		if first {
+			latency := time.Since(start)
+			connectionLatency.WithLabelValues(cm.chainName, endpointLabel(endpoint)).Observe(latency.Seconds())
+			cm.recordLatency(endpoint, latency)
+			cm.backoff.Reset(endpoint)
+			cm.breaker.Success(endpoint)
+			cm.beginWarmup()
+		}
+
+		// Transactions stay in the mempool across polls until committed
+		current := make(map[string]bool, len(txs))
+		for _, txBytes := range txs {
+			hash := cosmosTxHash(txBytes)
+			current[hash] = true
+			if seen[hash] {
+				continue
+			}
+			if err := cm.publishTransaction(cm.envelope(txBytes, "pending", nil)); err != nil {
+				cm.logger.Error("failed to handle message", "tx_hash", hash, "error", err)
+			}
+		}
+		seen = current
+		cm.recordActivity(endpoint)
+
+		select {
+		case <-cm.ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetchUnconfirmedTxs reads transaction bytes from a Tendermint unconfirmed_txs URL
+func fetchUnconfirmedTxs(ctx context.Context, client *http.Client, endpoint string) ([][]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mempool endpoint")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// The url.Error would repeat the endpoint, API key included
+		if urlErr, ok := err.(*url.Error); ok {
+			return nil, fmt.Errorf("unconfirmed_txs: %v", urlErr.Err)
+		}
+		return nil, fmt.Errorf("unconfirmed_txs: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unconfirmed_txs: unexpected status %s", resp.Status)
+	}
+
+	var envelope struct {
+		Result struct {
+			Txs [][]byte `json:"txs"`
+		} `json:"result"`
+		Error *rpcError `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("unconfirmed_txs: failed to decode response: %v", err)
+	}
+	if envelope.Error != nil {
+		return nil, envelope.Error
+	}
+	return envelope.Result.Txs, nil
+}
+
+// envelope maps transaction bytes into the Transaction envelope. Bytes
+// that decode as neither protobuf nor amino are still published by hash.
+func (cm *CosmosMonitor) envelope(txBytes []byte, status string, result json.RawMessage) Transaction {
+	tx := Transaction{
+		Hash:        cosmosTxHash(txBytes),
+		ChainID:     cm.chainID,
+		Chain:       cm.chainName,
+		ChainFamily: cm.family,
+		Type:        "unknown",
+		Status:      status,
+		Timestamp:   time.Now().Unix(),
+	}
+	raw := cosmosRaw{Tx: txBytes, Encoding: "unknown", Result: result}
+
+	decoded, err := decodeCosmosTx(txBytes)
+	if err != nil {
+		cm.logger.Debug("failed to decode transaction bytes", "tx_hash", tx.Hash, "error", err)
+	} else {
+		raw.Encoding, raw.Memo, raw.Fee, raw.GasLimit = decoded.encoding, decoded.memo, decoded.fee, decoded.gasLimit
+		for _, msg := range decoded.messages {
+			raw.Messages = append(raw.Messages, cosmosRawMsg{TypeURL: msg.typeURL, Value: msg.value})
+		}
+
+		tx.Gas = strconv.FormatUint(decoded.gasLimit, 10)
+		if len(decoded.signers) > 0 {
+			signer := decoded.signers[0]
+			tx.From = cm.address(signer.address)
+			if decoded.encoding == "proto" {
+				tx.Nonce = strconv.FormatUint(signer.sequence, 10)
+			}
+		}
+		if len(decoded.messages) > 0 {
+			first := decoded.messages[0]
+			tx.Type = first.typeURL
+			from, to, amount := cm.summarize(first, decoded.encoding == "amino")
+			if from != "" {
+				tx.From = from
+			}
+			tx.To, tx.Value = to, formatCoins(amount)
+		}
+	}
+
+	tx.Raw, _ = json.Marshal(raw)
+	return tx
+}
+
+// address encodes a 20-byte account address with the chain's bech32 prefix
+func (cm *CosmosMonitor) address(addr []byte) string {
+	if len(addr) == 0 || cm.prefix == "" {
+		return ""
+	}
+	return bech32Encode(cm.prefix, convertBits(addr, 8, 5), 1)
+}
+
+// summarize reads the sender, recipient and amount of common messages. Amino
+// messages carry raw address bytes where protobuf ones carry bech32 strings.
+func (cm *CosmosMonitor) summarize(msg cosmosMsg, amino bool) (from, to string, amount []cosmosCoin) {
+	var fromField, toField, amountField protowire.Number
+	switch msg.typeURL {
+	case "/cosmos.bank.v1beta1.MsgSend":
+		fromField, toField, amountField = 1, 2, 3
+	case "/cosmos.staking.v1beta1.MsgDelegate", "/cosmos.staking.v1beta1.MsgUndelegate":
+		fromField, toField, amountField = 1, 2, 3
+	case "/ibc.applications.transfer.v1.MsgTransfer":
+		fromField, toField, amountField = 4, 5, 3
+	default:
+		return "", "", nil
+	}
+
+	address := func(value []byte) string {
+		if amino {
+			return cm.address(value)
+		}
+		return string(value)
+	}
+	decodeFields(msg.value, func(f wireField) error {
+		switch f.num {
+		case fromField:
+			from = address(f.bytes)
+		case toField:
+			to = address(f.bytes)
+		case amountField:
+			amount = append(amount, decodeCosmosCoin(f.bytes))
+		}
+		return nil
+	})
+	return from, to, amount
+}
+
+// cosmosTxHash is the Tendermint transaction hash: SHA-256 of the bytes, upper-case hex
+func cosmosTxHash(txBytes []byte) string {
+	sum := sha256.Sum256(txBytes)
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// cosmosRaw is the Raw payload of a Cosmos transaction
+type cosmosRaw struct {
+	Tx       []byte          `json:"tx"`
+	Encoding string          `json:"encoding"`
+	Messages []cosmosRawMsg  `json:"messages,omitempty"`
+	Memo     string          `json:"memo,omitempty"`
+	Fee      []cosmosCoin    `json:"fee,omitempty"`
+	GasLimit uint64          `json:"gas_limit,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+}
+
+// cosmosRawMsg is a message in Raw, with its protobuf or amino encoded value
+type cosmosRawMsg struct {
+	TypeURL string `json:"type_url"`
+	Value   []byte `json:"value"`
+}
+
+// cosmosTx is a decoded Cosmos SDK transaction
+type cosmosTx struct {
+	encoding string
+	messages []cosmosMsg
+	memo     string
+	fee      []cosmosCoin
+	gasLimit uint64
+	signers  []cosmosSigner
+}
+
+// cosmosMsg is one message of a transaction, identified by its type URL
+type cosmosMsg struct {
+	typeURL string
+	value   []byte
+}
+
+// cosmosSigner is a transaction signer's account address and sequence
+type cosmosSigner struct {
+	address  []byte
+	sequence uint64
+}
+
+// cosmosCoin is an amount of one denomination
+type cosmosCoin struct {
+	Denom  string `json:"denom"`
+	Amount string `json:"amount"`
+}
+
+// formatCoins renders coins the way the Cosmos SDK does, e.g. 1000uatom,5uosmo
+func formatCoins(coins []cosmosCoin) string {
+	parts := make([]string, 0, len(coins))
+	for _, coin := range coins {
+		parts = append(parts, coin.Amount+coin.Denom)
+	}
+	return strings.Join(parts, ",")
+}
+
+// decodeCosmosTx decodes a protobuf TxRaw, falling back to an amino StdTx
+func decodeCosmosTx(txBytes []byte) (*cosmosTx, error) {
+	if tx, err := decodeCosmosProtoTx(txBytes); err == nil {
+		return tx, nil
+	}
+	return decodeAminoStdTx(txBytes)
+}
+
+// decodeCosmosProtoTx decodes a cosmos.tx.v1beta1.TxRaw and its body and auth info
+func decodeCosmosProtoTx(txBytes []byte) (*cosmosTx, error) {
+	var body, authInfo []byte
+	if err := decodeFields(txBytes, func(f wireField) error {
+		switch f.num {
+		case 1:
+			body = f.bytes
+		case 2:
+			authInfo = f.bytes
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	tx := &cosmosTx{encoding: "proto"}
+	if err := decodeFields(body, func(f wireField) error {
+		switch f.num {
+		case 1:
+			var msg cosmosMsg
+			decodeFields(f.bytes, func(f wireField) error {
+				switch f.num {
+				case 1:
+					msg.typeURL = string(f.bytes)
+				case 2:
+					msg.value = f.bytes
+				}
+				return nil
+			})
+			tx.messages = append(tx.messages, msg)
+		case 2:
+			tx.memo = string(f.bytes)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if len(tx.messages) == 0 || !strings.HasPrefix(tx.messages[0].typeURL, "/") {
+		return nil, fmt.Errorf("not a protobuf transaction")
+	}
+
+	// AuthInfo: signer_infos (public_key, mode_info, sequence) and fee
+	decodeFields(authInfo, func(f wireField) error {
+		switch f.num {
+		case 1:
+			var signer cosmosSigner
+			decodeFields(f.bytes, func(f wireField) error {
+				switch f.num {
+				case 1:
+					signer.address = cosmosPubKeyAddress(f.bytes)
+				case 3:
+					signer.sequence = f.varint
+				}
+				return nil
+			})
+			tx.signers = append(tx.signers, signer)
+		case 2:
+			decodeFields(f.bytes, func(f wireField) error {
+				switch f.num {
+				case 1:
+					tx.fee = append(tx.fee, decodeCosmosCoin(f.bytes))
+				case 2:
+					tx.gasLimit = f.varint
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	return tx, nil
+}
+
+// cosmosPubKeyAddress derives the account address of a public key packed
+// in an Any, for the key types with Cosmos SDK addresses
+func cosmosPubKeyAddress(anyKey []byte) []byte {
+	var typeURL string
+	var key []byte
+	decodeFields(anyKey, func(f wireField) error {
+		switch f.num {
+		case 1:
+			typeURL = string(f.bytes)
+		case 2:
+			// PubKey messages hold the key bytes in field 1
+			decodeFields(f.bytes, func(f wireField) error {
+				if f.num == 1 {
+					key = f.bytes
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+
+	switch typeURL {
+	case "/cosmos.crypto.secp256k1.PubKey":
+		return secp256k1AccountAddress(key)
+	case "/cosmos.crypto.ed25519.PubKey":
+		sum := sha256.Sum256(key)
+		return sum[:20]
+	default:
+		return nil
+	}
+}
+
+// secp256k1AccountAddress is RIPEMD-160 of SHA-256 of a compressed public key
+func secp256k1AccountAddress(key []byte) []byte {
+	if len(key) != 33 {
+		return nil
+	}
+	sum := sha256.Sum256(key)
+	hasher := ripemd160.New()
+	hasher.Write(sum[:])
+	return hasher.Sum(nil)
+}
+
+// decodeCosmosCoin decodes a cosmos.base.v1beta1.Coin, which amino encodes the same way
+func decodeCosmosCoin(b []byte) cosmosCoin {
+	var coin cosmosCoin
+	decodeFields(b, func(f wireField) error {
+		switch f.num {
+		case 1:
+			coin.Denom = string(f.bytes)
+		case 2:
+			coin.Amount = string(f.bytes)
+		}
+		return nil
+	})
+	return coin
+}
+
+// aminoPrefix derives the 4-byte prefix amino writes before a registered
+// concrete type: SHA-256 of the name, past leading zero bytes and the three
+// disambiguation bytes
+func aminoPrefix(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	b := sum[:]
+	for len(b) > 0 && b[0] == 0 {
+		b = b[1:]
+	}
+	b = b[3:]
+	for len(b) > 0 && b[0] == 0 {
+		b = b[1:]
+	}
+	return string(b[:4])
+}
+
+var (
+	aminoStdTxPrefix     = aminoPrefix("cosmos-sdk/StdTx")
+	aminoSecp256k1Prefix = aminoPrefix("tendermint/PubKeySecp256k1")
+
+	// aminoMsgTypes maps the prefixes of common legacy messages to the type
+	// URLs of their protobuf successors, whose field numbers they share
+	aminoMsgTypes = map[string]string{
+		aminoPrefix("cosmos-sdk/MsgSend"):                     "/cosmos.bank.v1beta1.MsgSend",
+		aminoPrefix("cosmos-sdk/MsgDelegate"):                 "/cosmos.staking.v1beta1.MsgDelegate",
+		aminoPrefix("cosmos-sdk/MsgUndelegate"):               "/cosmos.staking.v1beta1.MsgUndelegate",
+		aminoPrefix("cosmos-sdk/MsgWithdrawDelegationReward"): "/cosmos.distribution.v1beta1.MsgWithdrawDelegatorReward",
+		aminoPrefix("cosmos-sdk/MsgVote"):                     "/cosmos.gov.v1beta1.MsgVote",
+	}
+)
+
+// decodeAminoStdTx decodes a legacy amino StdTx, length-prefixed as the SDK
+// broadcast it before Stargate. Messages of unknown types are named by
+// their amino prefix.
+func decodeAminoStdTx(txBytes []byte) (*cosmosTx, error) {
+	if length, n := protowire.ConsumeVarint(txBytes); n > 0 && length == uint64(len(txBytes)-n) {
+		txBytes = txBytes[n:]
+	}
+	if len(txBytes) < 4 || string(txBytes[:4]) != aminoStdTxPrefix {
+		return nil, fmt.Errorf("neither a protobuf nor an amino transaction")
+	}
+
+	tx := &cosmosTx{encoding: "amino"}
+	err := decodeFields(txBytes[4:], func(f wireField) error {
+		switch f.num {
+		case 1:
+			if len(f.bytes) < 4 {
+				return nil
+			}
+			typeURL, ok := aminoMsgTypes[string(f.bytes[:4])]
+			if !ok {
+				typeURL = "amino:" + hex.EncodeToString(f.bytes[:4])
+			}
+			tx.messages = append(tx.messages, cosmosMsg{typeURL: typeURL, value: f.bytes[4:]})
+		case 2:
+			decodeFields(f.bytes, func(f wireField) error {
+				switch f.num {
+				case 1:
+					tx.fee = append(tx.fee, decodeCosmosCoin(f.bytes))
+				case 2:
+					tx.gasLimit = f.varint
+				}
+				return nil
+			})
+		case 3:
+			// StdSignature's public key is amino prefixed and length prefixed
+			decodeFields(f.bytes, func(f wireField) error {
+				if f.num != 1 || len(f.bytes) < 5 || string(f.bytes[:4]) != aminoSecp256k1Prefix {
+					return nil
+				}
+				if key, n := protowire.ConsumeBytes(f.bytes[4:]); n > 0 {
+					tx.signers = append(tx.signers, cosmosSigner{address: secp256k1AccountAddress(key)})
+				}
+				return nil
+			})
+		case 4:
+			tx.memo = string(f.bytes)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
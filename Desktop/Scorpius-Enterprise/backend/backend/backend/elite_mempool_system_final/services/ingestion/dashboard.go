@@ -0,0 +1,105 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+//go:embed web/dashboard.html
+var dashboardHTML []byte
+
+// EndpointStatus describes a single RPC endpoint on the dashboard
+type EndpointStatus struct {
+	Endpoint    string  `json:"endpoint"`
+	HealthScore float64 `json:"health_score"`
+	Active      bool    `json:"active"`
+	Disabled    string  `json:"disabled,omitempty"`
+}
+
+// ChainStatus is a point-in-time view of a chain monitor
+type ChainStatus struct {
+	Chain     string           `json:"chain"`
+	ChainID   int64            `json:"chain_id"`
+	Family    string           `json:"family"`
+	Connected bool             `json:"connected"`
+	Warmup    bool             `json:"warmup"`
+	TxRate    float64          `json:"tx_rate"`
+	TxTotal   uint64           `json:"tx_total"`
+	Endpoints []EndpointStatus `json:"endpoints"`
+}
+
+// Status returns a snapshot of the monitor's connection and health state
+func (cm *ChainMonitor) Status() ChainStatus {
+	warmup := cm.inWarmup()
+
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	status := ChainStatus{
+		Chain:     cm.chainName,
+		ChainID:   cm.chainID,
+		Family:    cm.family,
+		Connected: cm.activeEndpoint != "",
+		Warmup:    warmup,
+		TxRate:    cm.txRate.Rate(),
+		TxTotal:   cm.txRate.Total(),
+	}
+
+	for _, endpoint := range cm.endpoints {
+		status.Endpoints = append(status.Endpoints, EndpointStatus{
+			Endpoint:    displayEndpoint(endpoint),
+			HealthScore: cm.healthScores[endpoint],
+			Active:      endpoint == cm.activeEndpoint,
+			Disabled:    cm.disabled[endpoint],
+		})
+	}
+
+	return status
+}
+
+// displayEndpoint strips paths and credentials so API keys embedded in RPC URLs are not exposed
+func displayEndpoint(endpoint string) string {
+	endpointType, rawURL := splitEndpointType(endpoint)
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "invalid-endpoint"
+	}
+	if endpointType != EndpointRPC && endpointType != u.Scheme {
+		return endpointType + "+" + u.Scheme + "://" + u.Host
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// registerDashboard mounts the dashboard page and its JSON API
+func (is *IngestionService) registerDashboard(mux *http.ServeMux) {
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(dashboardHTML)
+	})
+
+	mux.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"chains": is.chainStatuses(),
+			"alerts": is.alerter.Recent(),
+		})
+	})
+}
+
+// chainStatuses returns the status of every chain monitor, sorted by chain
+func (is *IngestionService) chainStatuses() []ChainStatus {
+	is.mu.RLock()
+	defer is.mu.RUnlock()
+
+	chains := make([]ChainStatus, 0, len(is.monitors))
+	for _, monitor := range is.monitors {
+		chains = append(chains, monitor.Status())
+	}
+	sort.Slice(chains, func(i, j int) bool { return chains[i].Chain < chains[j].Chain })
+	return chains
+}
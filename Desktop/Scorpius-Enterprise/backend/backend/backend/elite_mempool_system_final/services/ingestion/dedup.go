@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var txDuplicates = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_tx_duplicates_total",
+		Help: "Transactions skipped because they were already published",
+	},
+	[]string{"chain"},
+)
+
+// dedupKey is the Redis key marking a transaction hash as published
+func (cm *ChainMonitor) dedupKey(hash string) string {
+	return fmt.Sprintf("dedup:%s:%s", cm.chainName, hash)
+}
+
+// claimTransaction marks tx as published for the chain's dedup TTL and
+// reports whether this caller is the first to do so. The claim lives in Redis,
+// so it survives reconnects, endpoint failover and process restarts. Redis
+// errors fail open: a duplicate is preferable to a dropped transaction.
+//
+// The claim records where and when tx was first seen, which later sightings
+// use for propagation metrics.
+func (cm *ChainMonitor) claimTransaction(tx *Transaction) bool {
+	if cm.options.DedupTTL <= 0 {
+		return true
+	}
+
+	endpointSightings.WithLabelValues(cm.chainName, sightingEndpoint(tx)).Inc()
+	claimed, err := cm.cache.SetNX(cm.deliverCtx, cm.dedupKey(tx.Hash), []byte(sightingValue(tx)), cm.options.DedupTTL)
+	if err != nil {
+		cm.logger.Warn("dedup check failed, publishing anyway", "tx_hash", tx.Hash, "error", err)
+		return true
+	}
+	if !claimed {
+		txDuplicates.WithLabelValues(cm.chainName).Inc()
+		cm.recordDuplicateSighting(tx)
+		return false
+	}
+	endpointFirstSeen.WithLabelValues(cm.chainName, sightingEndpoint(tx)).Inc()
+	return true
+}
+
+// releaseTransaction drops a claim after a failed publish so a later delivery can retry
+func (cm *ChainMonitor) releaseTransaction(hash string) {
+	if cm.options.DedupTTL <= 0 {
+		return
+	}
+	if err := cm.cache.Delete(cm.deliverCtx, cm.dedupKey(hash)); err != nil {
+		cm.logger.Warn("failed to release dedup claim", "tx_hash", hash, "error", err)
+	}
+}
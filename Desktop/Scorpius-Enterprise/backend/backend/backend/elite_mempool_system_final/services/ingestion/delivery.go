@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var kafkaDeliveries = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_kafka_deliveries_total",
+		Help: "Kafka delivery reports by outcome (delivered, retried, dead_lettered, lost)",
+	},
+	[]string{"cluster", "topic", "result"},
+)
+
+// deliveryAttempt travels in kafka.Message.Opaque so a delivery report knows
+// its chain and how many times its message has been produced
+type deliveryAttempt struct {
+	attempt int
+	chain   string
+	slo     *sloDelivery
+}
+
+// handleDeliveryReports consumes a producer's event channel until it is
+// closed, retrying failed deliveries with exponential backoff and moving
+// messages that exhaust their retries to the dead-letter topic
+func (s *KafkaSink) handleDeliveryReports(producer *kafka.Producer) {
+	defer s.reports.Done()
+
+	for event := range producer.Events() {
+		switch e := event.(type) {
+		case *kafka.Message:
+			topic := *e.TopicPartition.Topic
+			if e.TopicPartition.Error == nil {
+				kafkaDeliveries.WithLabelValues(s.cluster, topic, "delivered").Inc()
+				// Mirrors are off the ingest path, so only the primary's
+				// messages carry an attempt and count towards latency
+				if a, ok := e.Opaque.(*deliveryAttempt); ok {
+					if !e.Timestamp.IsZero() {
+						produceLatency.WithLabelValues(a.chain).Observe(time.Since(e.Timestamp).Seconds())
+					}
+					a.slo.Delivered()
+				}
+				continue
+			}
+			s.deliveryFailed(e)
+
+		case kafka.Error:
+			slog.Error("kafka producer error", "cluster", s.cluster, "error", e, "fatal", e.IsFatal())
+			if e.IsFatal() && s.isCurrent(producer) {
+				// The producer is unusable; the supervisor replaces it
+				select {
+				case s.fatal <- e:
+				default:
+				}
+			}
+
+		case *kafka.Stats:
+			s.recordStats(e.String())
+		}
+	}
+}
+
+// deliveryFailed schedules a retry or dead-letters a failed message
+func (s *KafkaSink) deliveryFailed(msg *kafka.Message) {
+	topic := *msg.TopicPartition.Topic
+	produceFailures.Add(s.cluster)
+	attempt := 1
+	var chain string
+	var delivery *sloDelivery
+	if a, ok := msg.Opaque.(*deliveryAttempt); ok {
+		attempt, chain, delivery = a.attempt, a.chain, a.slo
+	}
+
+	// Messages that fail to reach the DLQ itself are only logged
+	if topic == s.dlqTopic {
+		kafkaDeliveries.WithLabelValues(s.cluster, topic, "lost").Inc()
+		slog.Error("failed to deliver message to dead-letter topic", "cluster", s.cluster, "tx_hash", string(msg.Key), "error", msg.TopicPartition.Error)
+		return
+	}
+
+	if attempt > s.maxRetries || !s.beginRetry() {
+		delivery.Failed()
+		s.deadLetter(msg, attempt)
+		return
+	}
+
+	kafkaDeliveries.WithLabelValues(s.cluster, topic, "retried").Inc()
+	backoff := s.retryBackoff << (attempt - 1)
+
+	retry := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            msg.Key,
+		Value:          msg.Value,
+		Headers:        msg.Headers,
+		Opaque:         &deliveryAttempt{attempt: attempt + 1, chain: chain, slo: delivery},
+	}
+
+	time.AfterFunc(backoff, func() {
+		defer s.retries.Done()
+		if err := s.produce(retry); err != nil {
+			delivery.Failed()
+			s.deadLetter(retry, attempt+1)
+		}
+	})
+}
+
+// deadLetter produces msg to the DLQ topic with the failure as headers
+func (s *KafkaSink) deadLetter(msg *kafka.Message, attempts int) {
+	topic := *msg.TopicPartition.Topic
+	kafkaDeliveries.WithLabelValues(s.cluster, topic, "dead_lettered").Inc()
+
+	reason := "unknown"
+	if msg.TopicPartition.Error != nil {
+		reason = msg.TopicPartition.Error.Error()
+	}
+
+	headers := append([]kafka.Header{}, msg.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: "dlq_original_topic", Value: []byte(topic)},
+		kafka.Header{Key: "dlq_error", Value: []byte(reason)},
+		kafka.Header{Key: "dlq_attempts", Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: "dlq_failed_at", Value: []byte(fmt.Sprintf("%d", time.Now().Unix()))},
+	)
+
+	err := s.produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &s.dlqTopic, Partition: kafka.PartitionAny},
+		Key:            msg.Key,
+		Value:          msg.Value,
+		Headers:        headers,
+	})
+	if err != nil {
+		kafkaDeliveries.WithLabelValues(s.cluster, s.dlqTopic, "lost").Inc()
+		slog.Error("failed to dead-letter message", "cluster", s.cluster, "tx_hash", string(msg.Key), "topic", topic, "error", err)
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// knownBytecodeTag marks deployments matching a configured bytecode signature
+const knownBytecodeTag = "known_bytecode"
+
+// Deployment patterns recognised in init code
+const (
+	PatternMinimalProxy = "eip1167_proxy"
+	PatternProxy        = "eip1967_proxy"
+	PatternBeaconProxy  = "eip1967_beacon_proxy"
+	PatternUUPS         = "uups"
+)
+
+var contractDeploys = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_contract_deploys_total",
+		Help: "Pending contract creation transactions, by verdict (matched, risky, proxy or other)",
+	},
+	[]string{"chain", "verdict"},
+)
+
+var (
+	// EIP-1167 minimal proxy runtime code, around the 20-byte implementation address
+	minimalProxyPrefix = common.FromHex("0x363d3d373d3d3d363d73")
+	minimalProxySuffix = common.FromHex("0x5af43d82803e903d91602b57fd5bf3")
+
+	// EIP-1967 storage slots for the implementation and beacon addresses
+	proxyImplementationSlot = common.FromHex("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")
+	proxyBeaconSlot         = common.FromHex("0xa3f0ad74e5423aebfd80d3ef4346578335a9a72aeaee59ff6cb3582b35133d50")
+)
+
+// uupsSelector is proxiableUUID(), which every EIP-1822 implementation exposes
+const uupsSelector = "0x52d1902d"
+
+// riskyOpcodes are reported when found in the init code
+var riskyOpcodes = map[byte]string{
+	0xf2: "callcode",
+	0xf4: "delegatecall",
+	0xf5: "create2",
+	0xff: "selfdestruct",
+}
+
+// DeployConfig configures contract creation monitoring
+type DeployConfig struct {
+	Enabled        bool
+	Topic          string
+	Signatures     map[string]string
+	SignatureFiles []string
+	RiskThreshold  float64
+}
+
+// loadDeployConfig reads DEPLOY_* settings
+func loadDeployConfig() DeployConfig {
+	return DeployConfig{
+		Enabled:        getEnvBool("DEPLOY_MONITORING", false),
+		Topic:          getEnvOrDefault("DEPLOY_TOPIC", "contract_deploys"),
+		Signatures:     parseKeyValues(getEnv("DEPLOY_SIGNATURES")),
+		SignatureFiles: splitNonEmpty(getEnv("DEPLOY_SIGNATURE_FILES")),
+		RiskThreshold:  getEnvFloat("DEPLOY_RISK_THRESHOLD", 0.5),
+	}
+}
+
+// ContractDeploy describes a pending contract creation
+type ContractDeploy struct {
+	Chain          string    `json:"chain"`
+	ChainID        int64     `json:"chain_id"`
+	Hash           string    `json:"hash"`
+	Deployer       string    `json:"deployer"`
+	Nonce          uint64    `json:"nonce"`
+	Address        string    `json:"address"`
+	Value          string    `json:"value"`
+	InitCodeHash   string    `json:"init_code_hash"`
+	InitCodeSize   int       `json:"init_code_size"`
+	SelectorHash   string    `json:"selector_hash,omitempty"`
+	Selectors      []string  `json:"selectors,omitempty"`
+	Patterns       []string  `json:"patterns,omitempty"`
+	Implementation string    `json:"implementation,omitempty"`
+	Opcodes        []string  `json:"opcodes,omitempty"`
+	Token          bool      `json:"token"`
+	RiskScore      float64   `json:"risk_score"`
+	RiskFactors    []string  `json:"risk_factors,omitempty"`
+	Matches        []string  `json:"matches,omitempty"`
+	DetectedAt     time.Time `json:"detected_at"`
+}
+
+// bytecodeFingerprint summarises init code
+type bytecodeFingerprint struct {
+	initCodeHash   string
+	selectorHash   string
+	selectors      []string
+	patterns       []string
+	implementation string
+	opcodes        []string
+}
+
+// fingerprintInitCode hashes code and extracts what can be read from it
+// without executing it. The init code hash identifies an exact deployment;
+// the selector hash, over the sorted function selectors compared in the
+// dispatcher, identifies the same contract deployed with other constructor
+// arguments or compiler metadata.
+func fingerprintInitCode(code []byte) bytecodeFingerprint {
+	fp := bytecodeFingerprint{initCodeHash: crypto.Keccak256Hash(code).Hex()}
+
+	if i := bytes.Index(code, minimalProxyPrefix); i >= 0 {
+		rest := code[i+len(minimalProxyPrefix):]
+		if len(rest) >= common.AddressLength+len(minimalProxySuffix) &&
+			bytes.HasPrefix(rest[common.AddressLength:], minimalProxySuffix) {
+			fp.patterns = append(fp.patterns, PatternMinimalProxy)
+			fp.implementation = strings.ToLower(common.BytesToAddress(rest[:common.AddressLength]).Hex())
+		}
+	}
+	if bytes.Contains(code, proxyImplementationSlot) {
+		fp.patterns = append(fp.patterns, PatternProxy)
+	}
+	if bytes.Contains(code, proxyBeaconSlot) {
+		fp.patterns = append(fp.patterns, PatternBeaconProxy)
+	}
+
+	// A linear scan: constructor arguments and metadata are read as code too,
+	// so opcodes are a hint rather than proof
+	selectors := make(map[string]bool)
+	opcodes := make(map[string]bool)
+	for pc := 0; pc < len(code); pc++ {
+		op := code[pc]
+		if name, ok := riskyOpcodes[op]; ok {
+			opcodes[name] = true
+		}
+		if op < 0x60 || op > 0x7f {
+			continue
+		}
+		size := int(op-0x60) + 1
+		// PUSH4 <selector> EQ is how Solidity and Vyper dispatchers match calls
+		if size == 4 && pc+5 < len(code) && code[pc+5] == 0x14 {
+			selectors["0x"+hex.EncodeToString(code[pc+1:pc+5])] = true
+		}
+		pc += size
+	}
+
+	for selector := range selectors {
+		fp.selectors = append(fp.selectors, selector)
+	}
+	sort.Strings(fp.selectors)
+	if len(fp.selectors) > 0 {
+		fp.selectorHash = crypto.Keccak256Hash([]byte(strings.Join(fp.selectors, ","))).Hex()
+	}
+	if selectors[uupsSelector] {
+		fp.patterns = append(fp.patterns, PatternUUPS)
+	}
+
+	for name := range opcodes {
+		fp.opcodes = append(fp.opcodes, name)
+	}
+	sort.Strings(fp.opcodes)
+	return fp
+}
+
+// DeployMonitor fingerprints pending contract creations and publishes a
+// ContractDeploy for each one, before the deployment confirms.
+//
+// Signatures map a label to an init code hash, a selector hash or, for
+// minimal proxies, an implementation address; a deployment matching one is
+// tagged, published with the label and raises a warning alert. Token
+// deployments also carry a honeypot risk score, and are tagged and alerted
+// on when it reaches the risk threshold.
+type DeployMonitor struct {
+	sinks         *chainSinks
+	alerter       *Alerter
+	topic         string
+	signatures    map[string]string
+	riskThreshold float64
+}
+
+// NewDeployMonitor loads the signature files and creates a monitor publishing to sinks
+func NewDeployMonitor(sinks *chainSinks, alerter *Alerter, config DeployConfig) (*DeployMonitor, error) {
+	signatures := make(map[string]string)
+	for label, value := range config.Signatures {
+		signatures[strings.ToLower(value)] = label
+	}
+	for _, path := range config.SignatureFiles {
+		if err := loadLabelFile(path, "bytecode signatures", signatures); err != nil {
+			return nil, err
+		}
+	}
+	return &DeployMonitor{
+		sinks:         sinks,
+		alerter:       alerter,
+		topic:         config.Topic,
+		signatures:    signatures,
+		riskThreshold: config.RiskThreshold,
+	}, nil
+}
+
+// loadLabelFile reads "<hash or address> <label>" lines (# comments) into
+// labels; what names the file's contents in errors
+func loadLabelFile(path, what string, labels map[string]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", what, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		label := path
+		if len(fields) > 1 {
+			label = strings.Join(fields[1:], " ")
+		}
+		labels[strings.ToLower(fields[0])] = label
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s %s: %v", what, path, err)
+	}
+	return nil
+}
+
+// Name returns the enricher name
+func (d *DeployMonitor) Name() string {
+	return "deploys"
+}
+
+// Enrich fingerprints contract creations, tags signature matches and publishes the deployment
+func (d *DeployMonitor) Enrich(tx *Transaction) {
+	if tx.ChainFamily != FamilyEVM || tx.Canary || tx.Status != "pending" || tx.To != "" || tx.From == "" {
+		return
+	}
+	code := common.FromHex(tx.Data)
+	if len(code) == 0 {
+		return
+	}
+
+	fp := fingerprintInitCode(code)
+	nonce := hexToUint64(tx.Nonce)
+	deploy := ContractDeploy{
+		Chain:          tx.Chain,
+		ChainID:        tx.ChainID,
+		Hash:           tx.Hash,
+		Deployer:       strings.ToLower(tx.From),
+		Nonce:          nonce,
+		Address:        strings.ToLower(crypto.CreateAddress(common.HexToAddress(tx.From), nonce).Hex()),
+		Value:          tx.Value,
+		InitCodeHash:   fp.initCodeHash,
+		InitCodeSize:   len(code),
+		SelectorHash:   fp.selectorHash,
+		Selectors:      fp.selectors,
+		Patterns:       fp.patterns,
+		Implementation: fp.implementation,
+		Opcodes:        fp.opcodes,
+		DetectedAt:     time.Now(),
+	}
+	deploy.RiskScore, deploy.RiskFactors, deploy.Token = tokenRisk(fp)
+	for _, value := range []string{fp.initCodeHash, fp.selectorHash, fp.implementation} {
+		if label, ok := d.signatures[value]; ok && value != "" && !containsString(deploy.Matches, label) {
+			deploy.Matches = append(deploy.Matches, label)
+		}
+	}
+
+	verdict := "other"
+	switch {
+	case len(deploy.Matches) > 0:
+		verdict = "matched"
+		for _, label := range deploy.Matches {
+			tx.Tags = append(tx.Tags, TxTag{Tag: knownBytecodeTag, Source: label, CreatedAt: deploy.DetectedAt})
+		}
+		raiseTxAlert(d.alerter, tx, Alert{
+			Chain:    tx.Chain,
+			Category: "deploy",
+			Severity: SeverityWarning,
+			Message: fmt.Sprintf("%s is deploying %s matching %s (tx %s)",
+				deploy.Deployer, deploy.Address, strings.Join(deploy.Matches, ", "), tx.Hash),
+		})
+	case deploy.Token && deploy.RiskScore >= d.riskThreshold:
+		verdict = "risky"
+		tx.Tags = append(tx.Tags, TxTag{
+			Tag:       honeypotTag,
+			Note:      strings.Join(deploy.RiskFactors, ", "),
+			CreatedAt: deploy.DetectedAt,
+		})
+		raiseTxAlert(d.alerter, tx, Alert{
+			Chain:    tx.Chain,
+			Category: "deploy",
+			Severity: SeverityWarning,
+			Message: fmt.Sprintf("%s is deploying token %s with honeypot risk %.2f (%s, tx %s)",
+				deploy.Deployer, deploy.Address, deploy.RiskScore, strings.Join(deploy.RiskFactors, ", "), tx.Hash),
+		})
+	case len(deploy.Patterns) > 0:
+		verdict = "proxy"
+	}
+	contractDeploys.WithLabelValues(tx.Chain, verdict).Inc()
+
+	d.publish(deploy, tx.ChainFamily)
+}
+
+// publish produces a deployment to the deploy topic
+func (d *DeployMonitor) publish(deploy ContractDeploy, family string) {
+	data, err := json.Marshal(deploy)
+	if err != nil {
+		slog.Error("failed to marshal contract deploy", "chain", deploy.Chain, "tx_hash", deploy.Hash, "error", err)
+		return
+	}
+
+	topic := expandTopic(d.topic, deploy.Chain, deploy.ChainID, family)
+	headers := map[string]string{
+		"chain_id":   fmt.Sprintf("%d", deploy.ChainID),
+		"chain_name": deploy.Chain,
+		"format":     FormatJSON,
+	}
+	if err := d.sinks.Publish(context.Background(), deploy.Chain, topic, []byte(deploy.Hash), data, headers); err != nil {
+		slog.Warn("failed to publish contract deploy", "chain", deploy.Chain, "tx_hash", deploy.Hash, "error", err)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Reasons a chain monitor is drained
+const (
+	DrainShutdown  = "shutdown"
+	DrainReload    = "reload"
+	DrainRebalance = "rebalance"
+)
+
+var drainMessages = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_drain_messages_total",
+		Help: "Queued transactions published (flushed) or lost (dropped) while a chain monitor drained",
+	},
+	[]string{"chain", "result"},
+)
+
+// DrainConfig configures how chain monitors drain when they stop
+type DrainConfig struct {
+	Timeout     time.Duration
+	Marker      bool
+	MarkerTopic string
+}
+
+// loadDrainConfig reads SHUTDOWN_* settings
+func loadDrainConfig() DrainConfig {
+	return DrainConfig{
+		Timeout:     getEnvDuration("SHUTDOWN_DRAIN_TIMEOUT", 30*time.Second),
+		Marker:      getEnvBool("SHUTDOWN_MARKER", true),
+		MarkerTopic: getEnvOrDefault("SHUTDOWN_MARKER_TOPIC", "ingestion_lifecycle"),
+	}
+}
+
+// DrainResult counts what happened to the transactions queued when a chain
+// monitor stopped reading
+type DrainResult struct {
+	Flushed int `json:"flushed"`
+	Dropped int `json:"dropped"`
+}
+
+// ShutdownMarker is published once per chain after its queue has drained, so
+// consumers can tell a deliberate stop from an outage and see what was lost
+type ShutdownMarker struct {
+	Event     string `json:"event"`
+	Chain     string `json:"chain"`
+	ChainID   int64  `json:"chain_id"`
+	Instance  string `json:"instance"`
+	Reason    string `json:"reason"`
+	Flushed   int    `json:"flushed"`
+	Dropped   int    `json:"dropped"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Drain stops the monitor without losing what it has already read: it stops
+// reading from the websocket, publishes the queued transactions for up to
+// the drain timeout, publishes the shutdown marker and only then stops the
+// delivery path. Transactions still queued at the deadline are dropped.
+func (cm *ChainMonitor) Drain(reason string) DrainResult {
+	cm.logger.Info("draining monitor", "reason", reason)
+	cm.cancel()
+
+	cm.mu.Lock()
+	if cm.activeConn != nil {
+		cm.activeConn.Close()
+	}
+	cm.mu.Unlock()
+
+	flushed, dropped := cm.queue.Drain(cm.drain.Timeout)
+	result := DrainResult{Flushed: flushed, Dropped: dropped}
+	drainMessages.WithLabelValues(cm.chainName, "flushed").Add(float64(flushed))
+	drainMessages.WithLabelValues(cm.chainName, "dropped").Add(float64(dropped))
+
+	if cm.drain.Marker {
+		if err := cm.publishShutdownMarker(reason, result); err != nil {
+			cm.logger.Warn("failed to publish shutdown marker", "error", err)
+		}
+	}
+	cm.stopDelivery()
+
+	cm.logger.Info("drained monitor", "reason", reason, "flushed", flushed, "dropped", dropped)
+	return result
+}
+
+// publishShutdownMarker publishes the chain's shutdown marker through its
+// sink, so with leader election only the leader's marker is sent
+func (cm *ChainMonitor) publishShutdownMarker(reason string, result DrainResult) error {
+	marker := ShutdownMarker{
+		Event:     "shutdown",
+		Chain:     cm.chainName,
+		ChainID:   cm.chainID,
+		Instance:  instanceID(),
+		Reason:    reason,
+		Flushed:   result.Flushed,
+		Dropped:   result.Dropped,
+		Timestamp: time.Now().UnixMilli(),
+	}
+	data, err := json.Marshal(marker)
+	if err != nil {
+		return err
+	}
+
+	topic := expandTopic(cm.drain.MarkerTopic, cm.chainName, cm.chainID, cm.family)
+	headers := map[string]string{
+		"chain_id":   fmt.Sprintf("%d", cm.chainID),
+		"chain_name": cm.chainName,
+		"format":     FormatJSON,
+		"event":      marker.Event,
+	}
+	ctx, cancel := context.WithTimeout(cm.deliverCtx, 5*time.Second)
+	defer cancel()
+	return cm.sink.Publish(ctx, topic, []byte(cm.chainName), data, headers)
+}
+
+// drainMonitors drains monitors in parallel, so shutdown takes at most one
+// drain timeout however many chains there are, and logs the totals
+func drainMonitors(monitors map[string]Monitor, reason string) DrainResult {
+	start := time.Now()
+	var (
+		mu    sync.Mutex
+		total DrainResult
+		wg    sync.WaitGroup
+	)
+	for _, monitor := range monitors {
+		wg.Add(1)
+		go func(monitor Monitor) {
+			defer wg.Done()
+			result := monitor.Drain(reason)
+			mu.Lock()
+			total.Flushed += result.Flushed
+			total.Dropped += result.Dropped
+			mu.Unlock()
+		}(monitor)
+	}
+	wg.Wait()
+
+	slog.Info("drained monitors", "chains", len(monitors), "flushed", total.Flushed, "dropped", total.Dropped, "duration", time.Since(start))
+	return total
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Reasons a pending transaction is evicted as dropped
+const (
+	// DropExpired is a transaction still unconfirmed after DROP_WINDOW
+	DropExpired = "expired"
+	// DropReplaced is a transaction whose nonce went to another transaction,
+	// pending or confirmed
+	DropReplaced = "replaced"
+)
+
+var (
+	droppedTransactions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_dropped_transactions_total",
+			Help: "Pending transactions evicted as dropped, by reason",
+		},
+		[]string{"chain", "reason"},
+	)
+
+	pendingTracked = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_pending_tracked",
+			Help: "Pending transactions awaiting confirmation in the drop tracker",
+		},
+		[]string{"chain"},
+	)
+
+	pendingUntracked = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_pending_untracked_total",
+			Help: "Pending transactions the drop tracker stopped following without an event, by reason",
+		},
+		[]string{"chain", "reason"},
+	)
+)
+
+// DropConfig configures dropped transaction tracking
+type DropConfig struct {
+	Enabled bool
+	Topic   string
+	// Window is how long a pending transaction may go unconfirmed before it
+	// is evicted as dropped
+	Window time.Duration
+	// MaxTracked bounds the pending transactions followed per chain
+	MaxTracked int
+}
+
+// loadDropConfig reads DROP_* settings
+func loadDropConfig() DropConfig {
+	return DropConfig{
+		Enabled:    getEnvBool("DROP_TRACKING", false),
+		Topic:      getEnvOrDefault("DROP_TOPIC", "tx_dropped"),
+		Window:     getEnvDuration("DROP_WINDOW", 30*time.Minute),
+		MaxTracked: getEnvInt("DROP_MAX_TRACKED", 500000),
+	}
+}
+
+// DroppedTransaction is the eviction event of a pending transaction that
+// left the mempool without confirming. ReplacedBy names the transaction
+// that took its nonce, when known. Pending is how long it was followed.
+type DroppedTransaction struct {
+	Chain      string    `json:"chain"`
+	ChainID    int64     `json:"chain_id"`
+	Hash       string    `json:"hash"`
+	From       string    `json:"from"`
+	To         string    `json:"to,omitempty"`
+	Nonce      string    `json:"nonce"`
+	Status     string    `json:"status"`
+	Reason     string    `json:"reason"`
+	ReplacedBy string    `json:"replaced_by,omitempty"`
+	SeenAt     time.Time `json:"seen_at"`
+	DroppedAt  time.Time `json:"dropped_at"`
+	Pending    float64   `json:"pending_seconds"`
+}
+
+// DropMonitor routes pending transactions to the drop tracker of their
+// chain. Each EVM chain with BLOCK_TRACKING has a tracker (see dropTracker).
+type DropMonitor struct {
+	sinks  *chainSinks
+	config DropConfig
+
+	mu       sync.RWMutex
+	trackers map[string]*dropTracker
+}
+
+// NewDropMonitor creates a monitor for the chains registered with it
+func NewDropMonitor(sinks *chainSinks, config DropConfig) *DropMonitor {
+	return &DropMonitor{sinks: sinks, config: config, trackers: make(map[string]*dropTracker)}
+}
+
+// Name returns the enricher name
+func (m *DropMonitor) Name() string {
+	return "drops"
+}
+
+// Enrich follows pending transactions until they confirm, and evicts the
+// ones a replacement takes the nonce of
+func (m *DropMonitor) Enrich(tx *Transaction) {
+	if tx.ChainFamily != FamilyEVM || tx.Canary || tx.Status != "pending" || tx.From == "" || tx.Nonce == "" {
+		return
+	}
+	m.mu.RLock()
+	tracker := m.trackers[tx.Chain]
+	m.mu.RUnlock()
+	if tracker == nil {
+		return
+	}
+	tracker.track(tx)
+}
+
+// tracker creates the drop tracker of a chain, replacing any left by an
+// earlier monitor of the same chain
+func (m *DropMonitor) tracker(monitor *ChainMonitor) *dropTracker {
+	t := &dropTracker{
+		monitor:  monitor,
+		sinks:    m.sinks,
+		config:   m.config,
+		pending:  make(map[string]*pendingTx),
+		bySender: make(map[string]map[string]*pendingTx),
+	}
+	m.mu.Lock()
+	m.trackers[monitor.chainName] = t
+	m.mu.Unlock()
+	return t
+}
+
+// pendingTx is a transaction the drop tracker follows
+type pendingTx struct {
+	hash   string
+	from   string
+	to     string
+	nonce  uint64
+	seenAt time.Time
+}
+
+// dropTracker follows a chain's pending transactions until they appear in a
+// confirmed block. One that does not within DROP_WINDOW is evicted as
+// expired; one whose nonce another transaction takes, as replaced. Each
+// eviction is published to the drop topic with status "dropped".
+//
+// Confirmations come from the block tracker, BlockConfirms deep, so the
+// window should comfortably exceed the time to reach that depth. When the
+// block tracker skips ahead, transactions that may have confirmed in the
+// skipped blocks are forgotten rather than reported.
+type dropTracker struct {
+	monitor *ChainMonitor
+	sinks   *chainSinks
+	config  DropConfig
+
+	mu       sync.Mutex
+	pending  map[string]*pendingTx
+	bySender map[string]map[string]*pendingTx
+	// lastBlock is the last confirmed block handled
+	lastBlock uint64
+}
+
+// track starts following a pending transaction
+func (t *dropTracker) track(tx *Transaction) {
+	entry := &pendingTx{
+		hash:   strings.ToLower(tx.Hash),
+		from:   strings.ToLower(tx.From),
+		to:     strings.ToLower(tx.To),
+		nonce:  hexToUint64(tx.Nonce),
+		seenAt: time.Now(),
+	}
+
+	t.mu.Lock()
+	var replaced *pendingTx
+	if tx.ReplacedHash != "" {
+		if previous, ok := t.pending[strings.ToLower(tx.ReplacedHash)]; ok {
+			t.remove(previous)
+			replaced = previous
+		}
+	}
+	tracked := len(t.pending) < t.config.MaxTracked
+	if tracked {
+		t.pending[entry.hash] = entry
+		if t.bySender[entry.from] == nil {
+			t.bySender[entry.from] = make(map[string]*pendingTx)
+		}
+		t.bySender[entry.from][entry.hash] = entry
+	}
+	pendingTracked.WithLabelValues(t.monitor.chainName).Set(float64(len(t.pending)))
+	t.mu.Unlock()
+
+	if !tracked {
+		pendingUntracked.WithLabelValues(t.monitor.chainName, "full").Inc()
+	}
+	if replaced != nil {
+		t.evict(replaced, DropReplaced, entry.hash, entry.seenAt)
+	}
+}
+
+// remove stops following a transaction. The caller holds t.mu.
+func (t *dropTracker) remove(entry *pendingTx) {
+	delete(t.pending, entry.hash)
+	if sent := t.bySender[entry.from]; sent != nil {
+		delete(sent, entry.hash)
+		if len(sent) == 0 {
+			delete(t.bySender, entry.from)
+		}
+	}
+}
+
+// HandleBlock is the tracker's blockHandler. It stops following the
+// block's transactions, evicts those whose nonce the block used, and then
+// those past the drop window.
+func (t *dropTracker) HandleBlock(block *confirmedBlock) {
+	now := time.Now()
+	type eviction struct {
+		entry      *pendingTx
+		replacedBy string
+	}
+	var evictions []eviction
+
+	t.mu.Lock()
+	if t.lastBlock != 0 && block.Number > t.lastBlock+1 {
+		pendingUntracked.WithLabelValues(t.monitor.chainName, "block_gap").Add(float64(len(t.pending)))
+		t.pending = make(map[string]*pendingTx)
+		t.bySender = make(map[string]map[string]*pendingTx)
+	}
+	t.lastBlock = block.Number
+
+	for _, tx := range block.Transactions {
+		hash := strings.ToLower(tx.Hash)
+		if entry, ok := t.pending[hash]; ok {
+			t.remove(entry)
+		}
+		// Reverted transactions use their nonce too
+		nonce := hexToUint64(tx.Nonce)
+		for _, entry := range t.bySender[strings.ToLower(tx.From)] {
+			if entry.nonce <= nonce {
+				t.remove(entry)
+				evictions = append(evictions, eviction{entry: entry, replacedBy: hash})
+			}
+		}
+	}
+
+	cutoff := now.Add(-t.config.Window)
+	for _, entry := range t.pending {
+		if entry.seenAt.Before(cutoff) {
+			t.remove(entry)
+			evictions = append(evictions, eviction{entry: entry})
+		}
+	}
+	pendingTracked.WithLabelValues(t.monitor.chainName).Set(float64(len(t.pending)))
+	t.mu.Unlock()
+
+	for _, e := range evictions {
+		reason := DropExpired
+		if e.replacedBy != "" {
+			reason = DropReplaced
+		}
+		t.evict(e.entry, reason, e.replacedBy, now)
+	}
+}
+
+// evict publishes a dropped transaction to the drop topic
+func (t *dropTracker) evict(entry *pendingTx, reason, replacedBy string, now time.Time) {
+	cm := t.monitor
+	droppedTransactions.WithLabelValues(cm.chainName, reason).Inc()
+
+	event := DroppedTransaction{
+		Chain:      cm.chainName,
+		ChainID:    cm.chainID,
+		Hash:       entry.hash,
+		From:       entry.from,
+		To:         entry.to,
+		Nonce:      fmt.Sprintf("%d", entry.nonce),
+		Status:     "dropped",
+		Reason:     reason,
+		ReplacedBy: replacedBy,
+		SeenAt:     entry.seenAt,
+		DroppedAt:  now,
+		Pending:    now.Sub(entry.seenAt).Seconds(),
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("failed to marshal dropped transaction", "chain", event.Chain, "tx_hash", event.Hash, "error", err)
+		return
+	}
+
+	topic := expandTopic(t.config.Topic, cm.chainName, cm.chainID, cm.family)
+	headers := map[string]string{
+		"chain_id":   fmt.Sprintf("%d", cm.chainID),
+		"chain_name": cm.chainName,
+		"reason":     reason,
+		"format":     FormatJSON,
+	}
+	if err := t.sinks.Publish(context.Background(), cm.chainName, topic, []byte(event.Hash), data, headers); err != nil {
+		cm.logger.Warn("failed to publish dropped transaction", "tx_hash", event.Hash, "error", err)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// dryRunSummaryInterval is how often a dry run logs what it would have published
+const dryRunSummaryInterval = time.Minute
+
+var dryRunMessages = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_dry_run_messages_total",
+		Help: "Messages a dry run would have published, by sink and topic",
+	},
+	[]string{"sink", "topic"},
+)
+
+// dryRunConfig turns off everything that writes outside the process, so a
+// dry run connects to the configured endpoints and processes transactions
+// with no side effects. Sinks are replaced by a logging sink in newSink.
+func dryRunConfig(config Config) Config {
+	var disabled []string
+	disable := func(feature string, enabled bool) bool {
+		if enabled {
+			disabled = append(disabled, feature)
+		}
+		return enabled
+	}
+
+	// Dedup and the transaction cache keep working in memory
+	if disable("redis cache", config.CacheBackend == CacheRedis) {
+		config.CacheBackend = CacheMemory
+	}
+	if disable("leader election", config.Leader.Enabled) {
+		config.Leader.Enabled = false
+	}
+	if disable("sharding", config.Shards.Enabled) {
+		config.Shards.Enabled = false
+	}
+	if disable("watchlist", config.Watchlist.Enabled()) {
+		config.Watchlist = WatchlistConfig{}
+	}
+	if disable("sanctions screening", config.Sanctions.Enabled()) {
+		config.Sanctions = SanctionsConfig{}
+	}
+	// Label files still load; synced labels live in Redis
+	if disable("label sync", config.Labels.SyncURL != "") {
+		config.Labels.SyncURL = ""
+	}
+	if disable("disk spool", config.Spool.Enabled()) {
+		config.Spool.Dir = ""
+	}
+	if disable("ClickHouse archive", config.ClickHouse.DSN != "") {
+		config.ClickHouse.DSN = ""
+	}
+	if disable("Postgres store", config.Postgres.DSN != "") {
+		config.Postgres.DSN = ""
+	}
+	if disable("S3 export", config.S3Export.Bucket != "") {
+		config.S3Export.Bucket = ""
+	}
+	if disable("alert transports", len(config.AlertTransports) > 0) {
+		config.AlertTransports = nil
+	}
+	if disable("SLO webhook", config.SLO.WebhookURL != "") {
+		config.SLO.WebhookURL = ""
+	}
+
+	options := make(map[string]ChainOptions, len(config.ChainOptions))
+	indexes, exactlyOnce := false, false
+	for chainName, chainOptions := range config.ChainOptions {
+		indexes = indexes || chainOptions.Indexes
+		exactlyOnce = exactlyOnce || chainOptions.DeliveryMode == DeliveryExactlyOnce
+		chainOptions.Indexes = false
+		if chainOptions.DeliveryMode == DeliveryExactlyOnce {
+			chainOptions.DeliveryMode = DeliveryAtLeastOnce
+		}
+		options[chainName] = chainOptions
+	}
+	config.ChainOptions = options
+	disable("transaction indexes", indexes)
+	disable("exactly-once delivery", exactlyOnce)
+	if disable("exactly-once topics", len(config.ExactlyOnceTopics) > 0) {
+		config.ExactlyOnceTopics = nil
+	}
+
+	sort.Strings(disabled)
+	slog.Warn("dry run: transactions are processed but not published or stored", "disabled", disabled)
+	return config
+}
+
+// dryRunSink stands in for a sink during a dry run. It counts what would
+// have been published and logs a summary per topic every minute.
+type dryRunSink struct {
+	name    string
+	mu      sync.Mutex
+	counts  map[string]int
+	bytes   map[string]int
+	done    chan struct{}
+	stopped sync.WaitGroup
+}
+
+func newDryRunSink(name string) *dryRunSink {
+	s := &dryRunSink{
+		name:   name,
+		counts: make(map[string]int),
+		bytes:  make(map[string]int),
+		done:   make(chan struct{}),
+	}
+	s.stopped.Add(1)
+	go s.run()
+	return s
+}
+
+// Name returns the sink being stood in for, so per-sink logic and metrics
+// behave as in a real run
+func (s *dryRunSink) Name() string {
+	return s.name
+}
+
+func (s *dryRunSink) Publish(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
+	dryRunMessages.WithLabelValues(s.name, topic).Inc()
+	slog.Debug("dry run: would publish", "sink", s.name, "topic", topic, "key", string(key), "bytes", len(value), "headers", headers)
+
+	s.mu.Lock()
+	s.counts[topic]++
+	s.bytes[topic] += len(value)
+	s.mu.Unlock()
+	return nil
+}
+
+// run logs the summary until the sink is closed
+func (s *dryRunSink) run() {
+	defer s.stopped.Done()
+	ticker := time.NewTicker(dryRunSummaryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			s.summarize()
+			return
+		case <-ticker.C:
+			s.summarize()
+		}
+	}
+}
+
+// summarize logs and resets the messages counted since the last summary
+func (s *dryRunSink) summarize() {
+	s.mu.Lock()
+	counts, bytes := s.counts, s.bytes
+	s.counts, s.bytes = make(map[string]int), make(map[string]int)
+	s.mu.Unlock()
+
+	topics := make([]string, 0, len(counts))
+	for topic := range counts {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	for _, topic := range topics {
+		slog.Info("dry run: would have published", "sink", s.name, "topic", topic, "messages", counts[topic], "bytes", bytes[topic])
+	}
+}
+
+func (s *dryRunSink) Close() {
+	close(s.done)
+	s.stopped.Wait()
+}
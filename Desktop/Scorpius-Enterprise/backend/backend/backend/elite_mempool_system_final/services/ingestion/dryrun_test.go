@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDryRunConfig(t *testing.T) {
+	config := Config{
+		CacheBackend: CacheRedis,
+		ChainOptions: map[string]ChainOptions{
+			"ethereum": {DeliveryMode: DeliveryExactlyOnce, Indexes: true},
+			"polygon":  {DeliveryMode: DeliveryAtLeastOnce},
+		},
+		Spool:             SpoolConfig{Dir: "/var/lib/scorpius/spool"},
+		Leader:            LeaderConfig{Enabled: true},
+		Shards:            ShardConfig{Enabled: true},
+		Watchlist:         WatchlistConfig{Addresses: []string{"0xd8da6bf26964af9d7eed9e03e53415d37aa96045"}},
+		Sanctions:         SanctionsConfig{Files: []string{"sdn.txt"}},
+		Labels:            LabelConfig{Files: []string{"labels.txt"}, SyncURL: "https://labels.example.com/v1/labels"},
+		ClickHouse:        ClickHouseConfig{DSN: "clickhouse://localhost:9000"},
+		Postgres:          PostgresConfig{DSN: "postgres://localhost/scorpius"},
+		S3Export:          S3ExportConfig{Bucket: "mempool-archive"},
+		AlertTransports:   []AlertTransportConfig{{}},
+		SLO:               SLOConfig{WebhookURL: "https://hooks.example.com/slo"},
+		ExactlyOnceTopics: []string{"tx_raw"},
+	}
+
+	got := dryRunConfig(config)
+
+	if got.CacheBackend != CacheMemory {
+		t.Errorf("CacheBackend = %q, want %q", got.CacheBackend, CacheMemory)
+	}
+	if got.Leader.Enabled || got.Shards.Enabled {
+		t.Errorf("leader election or sharding still enabled")
+	}
+	if got.Watchlist.Enabled() || got.Sanctions.Enabled() {
+		t.Errorf("watchlist or sanctions screening still enabled")
+	}
+	if got.Labels.SyncURL != "" {
+		t.Errorf("Labels.SyncURL = %q, want label sync disabled", got.Labels.SyncURL)
+	}
+	if len(got.Labels.Files) != 1 {
+		t.Errorf("Labels.Files = %v, want label files kept", got.Labels.Files)
+	}
+	if got.Spool.Enabled() {
+		t.Errorf("disk spool still enabled")
+	}
+	if got.ClickHouse.DSN != "" || got.Postgres.DSN != "" || got.S3Export.Bucket != "" {
+		t.Errorf("archive sinks still enabled")
+	}
+	if got.AlertTransports != nil || got.SLO.WebhookURL != "" {
+		t.Errorf("alert transports or SLO webhook still enabled")
+	}
+	if got.ExactlyOnceTopics != nil {
+		t.Errorf("ExactlyOnceTopics = %v, want none", got.ExactlyOnceTopics)
+	}
+	for chainName, options := range got.ChainOptions {
+		if options.DeliveryMode != DeliveryAtLeastOnce {
+			t.Errorf("%s: DeliveryMode = %q, want %q", chainName, options.DeliveryMode, DeliveryAtLeastOnce)
+		}
+		if options.Indexes {
+			t.Errorf("%s: transaction indexes still enabled", chainName)
+		}
+	}
+
+	// The caller's config is left as it was
+	if config.ChainOptions["ethereum"].DeliveryMode != DeliveryExactlyOnce {
+		t.Errorf("dryRunConfig modified the caller's chain options")
+	}
+}
+
+func TestDryRunSink(t *testing.T) {
+	s := newDryRunSink("kafka")
+	defer s.Close()
+
+	if s.Name() != "kafka" {
+		t.Errorf("Name() = %q, want the replaced sink's name", s.Name())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		if err := s.Publish(ctx, "tx_raw", []byte("key"), []byte("value"), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.mu.Lock()
+	count, bytes := s.counts["tx_raw"], s.bytes["tx_raw"]
+	s.mu.Unlock()
+	if count != 3 || bytes != 15 {
+		t.Errorf("counted %d messages, %d bytes; want 3, 15", count, bytes)
+	}
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// Message formats
+const (
+	FormatJSON       = "json"
+	FormatAvro       = "avro"
+	FormatJSONSchema = "json_schema"
+)
+
+//go:embed schemas/transaction.avsc
+var transactionAvroSchema string
+
+//go:embed schemas/transaction.schema.json
+var transactionJSONSchema string
+
+// MessageEncoder serializes transactions for publishing to a topic. Encode
+// appends the message to dst, so the publish path can reuse its buffers.
+type MessageEncoder interface {
+	Format() string
+	Encode(dst []byte, topic string, tx *Transaction) ([]byte, error)
+}
+
+// newMessageEncoder creates the encoder for a format; registry may be nil
+// for formats that do not use Schema Registry
+func newMessageEncoder(format string, registry *schemaRegistryClient) (MessageEncoder, error) {
+	switch format {
+	case "", FormatJSON:
+		return jsonEncoder{}, nil
+	case FormatProtobuf:
+		return protobufEncoder{}, nil
+	case FormatAvro, FormatJSONSchema:
+		if registry == nil {
+			return nil, fmt.Errorf("%s format requires SCHEMA_REGISTRY_URL", format)
+		}
+		if format == FormatAvro {
+			return newAvroEncoder(registry)
+		}
+		return &jsonSchemaEncoder{
+			ids: &schemaIDs{registry: registry, schemaType: SchemaTypeJSONSchema, schema: transactionJSONSchema},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown message format %q", format)
+	}
+}
+
+// topicEncoders selects the wire format for each topic, falling back to MESSAGE_FORMAT
+type topicEncoders struct {
+	fallback MessageEncoder
+	topics   map[string]MessageEncoder
+	// versions and v2Suffix are ENVELOPE_VERSIONS and ENVELOPE_V2_TOPIC_SUFFIX
+	versions []string
+	v2Suffix string
+}
+
+func newTopicEncoders(config Config) (*topicEncoders, error) {
+	var registry *schemaRegistryClient
+	if config.SchemaRegistryURL != "" {
+		registry = newSchemaRegistryClient(config.SchemaRegistryURL, config.SchemaRegistryUser, config.SchemaRegistryPassword)
+		registry.dryRun = config.DryRun
+	}
+
+	fallback, err := newMessageEncoder(config.MessageFormat, registry)
+	if err != nil {
+		return nil, err
+	}
+
+	encoders := &topicEncoders{
+		fallback: fallback,
+		topics:   make(map[string]MessageEncoder),
+		versions: config.Envelope.Versions,
+		v2Suffix: config.Envelope.V2Suffix,
+	}
+	for topic, format := range config.TopicFormats {
+		encoder, err := newMessageEncoder(format, registry)
+		if err != nil {
+			return nil, fmt.Errorf("topic %s: %v", topic, err)
+		}
+		encoders.topics[topic] = encoder
+	}
+	return encoders, nil
+}
+
+// For returns the encoder for topic
+func (e *topicEncoders) For(topic string) MessageEncoder {
+	if encoder, ok := e.topics[topic]; ok {
+		return encoder
+	}
+	return e.fallback
+}
+
+// jsonEncoder writes plain JSON, the historical tx_raw format
+type jsonEncoder struct{}
+
+func (jsonEncoder) Format() string {
+	return FormatJSON
+}
+
+func (jsonEncoder) Encode(dst []byte, topic string, tx *Transaction) ([]byte, error) {
+	return appendJSON(dst, tx)
+}
+
+// jsonAppender is a json.Encoder writing to the end of a byte slice
+type jsonAppender struct {
+	buf     []byte
+	encoder *json.Encoder
+}
+
+func (a *jsonAppender) Write(p []byte) (int, error) {
+	a.buf = append(a.buf, p...)
+	return len(p), nil
+}
+
+// jsonAppenders recycles encoders, which json.Marshal would otherwise
+// replace with a fresh output slice per message
+var jsonAppenders = sync.Pool{
+	New: func() interface{} {
+		a := new(jsonAppender)
+		a.encoder = json.NewEncoder(a)
+		return a
+	},
+}
+
+// appendJSON appends v to dst encoded exactly as json.Marshal encodes it
+func appendJSON(dst []byte, v interface{}) ([]byte, error) {
+	a := jsonAppenders.Get().(*jsonAppender)
+	a.buf = dst
+	err := a.encoder.Encode(v)
+	out := a.buf
+	a.buf = nil
+	jsonAppenders.Put(a)
+	if err != nil {
+		return dst, err
+	}
+	// Encode ends the value with a newline that Marshal does not write
+	return out[:len(out)-1], nil
+}
+
+// subjectFor returns the registry subject for a topic's values (TopicNameStrategy)
+func subjectFor(topic string) string {
+	return topic + "-value"
+}
+
+// schemaIDs registers a schema once per topic and caches the resulting ID
+type schemaIDs struct {
+	registry   *schemaRegistryClient
+	schemaType string
+	schema     string
+
+	mu  sync.RWMutex
+	ids map[string]int
+}
+
+func (s *schemaIDs) lookup(topic string) (int, error) {
+	s.mu.RLock()
+	id, ok := s.ids[topic]
+	s.mu.RUnlock()
+	if ok {
+		return id, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id, err := s.registry.Register(ctx, subjectFor(topic), s.schemaType, s.schema)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	if s.ids == nil {
+		s.ids = make(map[string]int)
+	}
+	s.ids[topic] = id
+	s.mu.Unlock()
+	return id, nil
+}
+
+// appendFrameHeader appends the Confluent wire format header that prefixes
+// each payload: magic byte 0 and the schema ID
+func appendFrameHeader(dst []byte, schemaID int) []byte {
+	dst = append(dst, 0)
+	return binary.BigEndian.AppendUint32(dst, uint32(schemaID))
+}
+
+// avroEncoder writes Avro binary registered under the topic's subject
+type avroEncoder struct {
+	codec *goavro.Codec
+	ids   *schemaIDs
+}
+
+func newAvroEncoder(registry *schemaRegistryClient) (*avroEncoder, error) {
+	codec, err := goavro.NewCodec(transactionAvroSchema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction Avro schema: %v", err)
+	}
+	return &avroEncoder{
+		codec: codec,
+		ids:   &schemaIDs{registry: registry, schemaType: SchemaTypeAvro, schema: codec.Schema()},
+	}, nil
+}
+
+func (e *avroEncoder) Format() string {
+	return FormatAvro
+}
+
+func (e *avroEncoder) Encode(dst []byte, topic string, tx *Transaction) ([]byte, error) {
+	id, err := e.ids.lookup(topic)
+	if err != nil {
+		return dst, err
+	}
+
+	native, err := avroNative(tx)
+	if err != nil {
+		return dst, err
+	}
+
+	out, err := e.codec.BinaryFromNative(appendFrameHeader(dst, id), native)
+	if err != nil {
+		return dst, fmt.Errorf("failed to encode Avro transaction: %v", err)
+	}
+	return out, nil
+}
+
+// avroNative converts a transaction into goavro's native representation
+func avroNative(tx *Transaction) (map[string]interface{}, error) {
+	accessList := make([]interface{}, 0, len(tx.AccessList))
+	for _, tuple := range tx.AccessList {
+		accessList = append(accessList, map[string]interface{}{
+			"address":      tuple.Address,
+			"storage_keys": stringsToNative(tuple.StorageKeys),
+		})
+	}
+
+	tags := make([]interface{}, 0, len(tx.Tags))
+	for _, tag := range tx.Tags {
+		tags = append(tags, map[string]interface{}{
+			"tag":        tag.Tag,
+			"source":     tag.Source,
+			"note":       tag.Note,
+			"created_at": tag.CreatedAt,
+		})
+	}
+
+	inputs := make([]interface{}, 0, len(tx.Inputs))
+	for _, input := range tx.Inputs {
+		inputs = append(inputs, map[string]interface{}{
+			"prev_txid":  input.PrevTxID,
+			"prev_index": int64(input.PrevIndex),
+			"script_sig": input.ScriptSig,
+			"sequence":   int64(input.Sequence),
+			"witness":    stringsToNative(input.Witness),
+		})
+	}
+
+	outputs := make([]interface{}, 0, len(tx.Outputs))
+	for _, output := range tx.Outputs {
+		outputs = append(outputs, map[string]interface{}{
+			"index":         int64(output.Index),
+			"value":         output.Value,
+			"script_pubkey": output.ScriptPubKey,
+			"script_type":   output.ScriptType,
+			"address":       output.Address,
+		})
+	}
+
+	native := map[string]interface{}{
+		"hash":                     tx.Hash,
+		"chain_id":                 tx.ChainID,
+		"chain":                    tx.Chain,
+		"chain_family":             tx.ChainFamily,
+		"type":                     tx.Type,
+		"from":                     tx.From,
+		"to":                       tx.To,
+		"value":                    tx.Value,
+		"gas":                      tx.Gas,
+		"gas_price":                tx.GasPrice,
+		"max_fee_per_gas":          tx.MaxFeePerGas,
+		"max_priority_fee_per_gas": tx.MaxPriorityFeePerGas,
+		"max_fee_per_blob_gas":     tx.MaxFeePerBlobGas,
+		"blob_versioned_hashes":    stringsToNative(tx.BlobVersionedHashes),
+		"access_list":              accessList,
+		"data":                     tx.Data,
+		"nonce":                    tx.Nonce,
+		"timestamp":                tx.Timestamp,
+		"block_number":             nil,
+		"transaction_index":        nil,
+		"status":                   tx.Status,
+		"canary":                   tx.Canary,
+		"token_transfer":           nil,
+		"tags":                     tags,
+		"inputs":                   inputs,
+		"outputs":                  outputs,
+		"raw":                      nil,
+		"mev":                      nil,
+		"is_replacement":           tx.IsReplacement,
+		"replaced_hash":            tx.ReplacedHash,
+		"nonce_gap":                int64(tx.NonceGap),
+		"simulation":               nil,
+		"swap":                     nil,
+		"from_label":               nil,
+		"to_label":                 nil,
+		"decimal":                  nil,
+	}
+
+	if tx.BlockNumber != nil {
+		native["block_number"] = goavro.Union("long", *tx.BlockNumber)
+	}
+	if tx.TransactionIndex != nil {
+		native["transaction_index"] = goavro.Union("int", int32(*tx.TransactionIndex))
+	}
+	if t := tx.TokenTransfer; t != nil {
+		native["token_transfer"] = goavro.Union("io.scorpius.ingestion.TokenTransfer", map[string]interface{}{
+			"method":   t.Method,
+			"standard": t.Standard,
+			"token":    t.Token,
+			"from":     t.From,
+			"to":       t.To,
+			"amount":   t.Amount,
+			"token_id": t.TokenID,
+		})
+	}
+	if tx.Raw != nil {
+		native["raw"] = goavro.Union("string", string(tx.Raw))
+	}
+	if m := tx.MEV; m != nil {
+		native["mev"] = goavro.Union("io.scorpius.ingestion.MEVClassification", map[string]interface{}{
+			"kinds":      stringsToNative(m.Kinds),
+			"protocol":   m.Protocol,
+			"method":     m.Method,
+			"confidence": m.Confidence,
+			"reasons":    stringsToNative(m.Reasons),
+		})
+	}
+	if s := tx.Simulation; s != nil {
+		stateDiff := make([]interface{}, len(s.StateDiff))
+		for i, change := range s.StateDiff {
+			stateDiff[i] = map[string]interface{}{
+				"address": change.Address,
+				"field":   change.Field,
+				"slot":    change.Slot,
+				"before":  change.Before,
+				"after":   change.After,
+			}
+		}
+		native["simulation"] = goavro.Union("io.scorpius.ingestion.Simulation", map[string]interface{}{
+			"success":       s.Success,
+			"revert_reason": s.RevertReason,
+			"return_data":   s.ReturnData,
+			"state_diff":    stateDiff,
+		})
+	}
+	if s := tx.Swap; s != nil {
+		native["swap"] = goavro.Union("io.scorpius.ingestion.SwapIntent", map[string]interface{}{
+			"protocol":       s.Protocol,
+			"method":         s.Method,
+			"router":         s.Router,
+			"exact_input":    s.ExactInput,
+			"token_in":       s.TokenIn,
+			"token_out":      s.TokenOut,
+			"amount_in":      s.AmountIn,
+			"amount_out":     s.AmountOut,
+			"amount_out_min": s.AmountOutMin,
+			"amount_in_max":  s.AmountInMax,
+			"path":           stringsToNative(s.Path),
+			"pools":          stringsToNative(s.Pools),
+			"recipient":      s.Recipient,
+			"deadline":       s.Deadline,
+		})
+	}
+	if l := tx.FromLabel; l != nil {
+		native["from_label"] = goavro.Union("io.scorpius.ingestion.AddressLabel", map[string]interface{}{
+			"name":     l.Name,
+			"category": l.Category,
+			"source":   l.Source,
+		})
+	}
+	if l := tx.ToLabel; l != nil {
+		native["to_label"] = goavro.Union("io.scorpius.ingestion.AddressLabel", map[string]interface{}{
+			"name":     l.Name,
+			"category": l.Category,
+			"source":   l.Source,
+		})
+	}
+	if d := tx.Decimal; d != nil {
+		native["decimal"] = goavro.Union("io.scorpius.ingestion.DecimalAmounts", map[string]interface{}{
+			"value":                    d.Value,
+			"gas":                      d.Gas,
+			"gas_price":                d.GasPrice,
+			"max_fee_per_gas":          d.MaxFeePerGas,
+			"max_priority_fee_per_gas": d.MaxPriorityFeePerGas,
+			"max_fee_per_blob_gas":     d.MaxFeePerBlobGas,
+			"nonce":                    d.Nonce,
+		})
+	}
+
+	return native, nil
+}
+
+func stringsToNative(values []string) []interface{} {
+	native := make([]interface{}, len(values))
+	for i, v := range values {
+		native[i] = v
+	}
+	return native
+}
+
+// jsonSchemaEncoder writes JSON validated by consumers against a registered JSON Schema
+type jsonSchemaEncoder struct {
+	ids *schemaIDs
+}
+
+func (e *jsonSchemaEncoder) Format() string {
+	return FormatJSONSchema
+}
+
+func (e *jsonSchemaEncoder) Encode(dst []byte, topic string, tx *Transaction) ([]byte, error) {
+	id, err := e.ids.lookup(topic)
+	if err != nil {
+		return dst, err
+	}
+
+	out, err := appendJSON(appendFrameHeader(dst, id), tx)
+	if err != nil {
+		return dst, err
+	}
+	return out, nil
+}
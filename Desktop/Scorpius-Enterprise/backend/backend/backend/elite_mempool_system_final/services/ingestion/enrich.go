@@ -0,0 +1,7 @@
+package main
+
+// Enricher annotates a transaction in place before it is published
+type Enricher interface {
+	Name() string
+	Enrich(tx *Transaction)
+}
@@ -0,0 +1,180 @@
+package main
+
+import "encoding/json"
+
+// Envelope versions, sent in the schema_version header of every transaction
+const (
+	// EnvelopeV1 is the flat Transaction, in whichever format the topic uses
+	EnvelopeV1 = "1"
+	// EnvelopeV2 groups the transaction's fields by what they describe. It is
+	// JSON only; topics in other formats keep publishing v1.
+	EnvelopeV2 = "2"
+)
+
+// EnvelopeConfig selects the envelope versions JSON topics are published in
+type EnvelopeConfig struct {
+	Versions []string
+	// V2Suffix names the topic v2 goes to while v1 is still published: with
+	// both versions on, <topic> carries v1 and <topic><suffix> v2, so
+	// consumers move over one at a time
+	V2Suffix string
+}
+
+// loadEnvelopeConfig reads ENVELOPE_* settings
+func loadEnvelopeConfig() EnvelopeConfig {
+	return EnvelopeConfig{
+		Versions: splitNonEmpty(getEnvOrDefault("ENVELOPE_VERSIONS", EnvelopeV1)),
+		V2Suffix: getEnvOrDefault("ENVELOPE_V2_TOPIC_SUFFIX", ".v2"),
+	}
+}
+
+// encodeTarget is one message a transaction bound for a topic is published as
+type encodeTarget struct {
+	topic   string
+	version string
+	encoder MessageEncoder
+}
+
+// Targets appends the messages to publish for topic to targets: the topic's
+// encoder for v1 and, on JSON topics with v2 on, the v2 envelope
+func (e *topicEncoders) Targets(targets []encodeTarget, topic string) []encodeTarget {
+	encoder := e.For(topic)
+	v1, v2 := len(e.versions) == 0 || containsString(e.versions, EnvelopeV1), containsString(e.versions, EnvelopeV2)
+	if encoder.Format() != FormatJSON || !v2 {
+		return append(targets, encodeTarget{topic: topic, version: EnvelopeV1, encoder: encoder})
+	}
+	if !v1 {
+		return append(targets, encodeTarget{topic: topic, version: EnvelopeV2, encoder: jsonV2Encoder{}})
+	}
+	return append(targets,
+		encodeTarget{topic: topic, version: EnvelopeV1, encoder: encoder},
+		encodeTarget{topic: topic + e.v2Suffix, version: EnvelopeV2, encoder: jsonV2Encoder{}},
+	)
+}
+
+// jsonV2Encoder writes the v2 envelope as JSON
+type jsonV2Encoder struct{}
+
+func (jsonV2Encoder) Format() string {
+	return FormatJSON
+}
+
+func (jsonV2Encoder) Encode(dst []byte, topic string, tx *Transaction) ([]byte, error) {
+	envelope := newEnvelopeV2(tx)
+	return appendJSON(dst, &envelope)
+}
+
+// envelopeV2 is the v2 transaction message. It carries the same data as v1:
+// the chain it came from, the transaction as signed, where it is in its
+// lifecycle, and what the enrichers added.
+type envelopeV2 struct {
+	Version    string             `json:"version"`
+	Chain      envelopeChain      `json:"chain"`
+	Tx         envelopeTx         `json:"transaction"`
+	Status     envelopeStatus     `json:"status"`
+	Enrichment envelopeEnrichment `json:"enrichment"`
+	Canary     bool               `json:"canary,omitempty"`
+	Raw        json.RawMessage    `json:"raw,omitempty"`
+}
+
+type envelopeChain struct {
+	Name   string `json:"name"`
+	ID     int64  `json:"id"`
+	Family string `json:"family"`
+	CAIP2  string `json:"caip2,omitempty"`
+}
+
+// envelopeTx holds the transaction's own fields, amounts as the node
+// reported them and, where normalized, in decimal
+type envelopeTx struct {
+	Hash                 string          `json:"hash"`
+	Type                 string          `json:"type"`
+	From                 string          `json:"from"`
+	To                   string          `json:"to,omitempty"`
+	Value                string          `json:"value"`
+	Gas                  string          `json:"gas,omitempty"`
+	GasPrice             string          `json:"gas_price,omitempty"`
+	MaxFeePerGas         string          `json:"max_fee_per_gas,omitempty"`
+	MaxPriorityFeePerGas string          `json:"max_priority_fee_per_gas,omitempty"`
+	MaxFeePerBlobGas     string          `json:"max_fee_per_blob_gas,omitempty"`
+	BlobVersionedHashes  []string        `json:"blob_versioned_hashes,omitempty"`
+	AccessList           []AccessTuple   `json:"access_list,omitempty"`
+	Data                 string          `json:"data,omitempty"`
+	Nonce                string          `json:"nonce,omitempty"`
+	Decimal              *DecimalAmounts `json:"decimal,omitempty"`
+	Inputs               []UTXOInput     `json:"inputs,omitempty"`
+	Outputs              []UTXOOutput    `json:"outputs,omitempty"`
+}
+
+// envelopeStatus places the transaction in its lifecycle. SeenAt is the
+// Unix time it was received; Replaces names the transaction it replaced and
+// NonceGap counts the nonces missing below it.
+type envelopeStatus struct {
+	State            string `json:"state"`
+	SeenAt           int64  `json:"seen_at"`
+	BlockNumber      *int64 `json:"block_number,omitempty"`
+	TransactionIndex *int   `json:"transaction_index,omitempty"`
+	Replaces         string `json:"replaces,omitempty"`
+	NonceGap         uint64 `json:"nonce_gap,omitempty"`
+}
+
+type envelopeEnrichment struct {
+	TokenTransfer *TokenTransfer     `json:"token_transfer,omitempty"`
+	Swap          *SwapIntent        `json:"swap,omitempty"`
+	MEV           *MEVClassification `json:"mev,omitempty"`
+	Simulation    *Simulation        `json:"simulation,omitempty"`
+	FromLabel     *AddressLabel      `json:"from_label,omitempty"`
+	ToLabel       *AddressLabel      `json:"to_label,omitempty"`
+	Tags          []TxTag            `json:"tags,omitempty"`
+}
+
+// newEnvelopeV2 restructures a transaction into the v2 envelope
+func newEnvelopeV2(tx *Transaction) envelopeV2 {
+	return envelopeV2{
+		Version: EnvelopeV2,
+		Chain: envelopeChain{
+			Name:   tx.Chain,
+			ID:     tx.ChainID,
+			Family: tx.ChainFamily,
+			CAIP2:  caip2(tx.Chain, tx.ChainFamily, tx.ChainID),
+		},
+		Tx: envelopeTx{
+			Hash:                 tx.Hash,
+			Type:                 tx.Type,
+			From:                 tx.From,
+			To:                   tx.To,
+			Value:                tx.Value,
+			Gas:                  tx.Gas,
+			GasPrice:             tx.GasPrice,
+			MaxFeePerGas:         tx.MaxFeePerGas,
+			MaxPriorityFeePerGas: tx.MaxPriorityFeePerGas,
+			MaxFeePerBlobGas:     tx.MaxFeePerBlobGas,
+			BlobVersionedHashes:  tx.BlobVersionedHashes,
+			AccessList:           tx.AccessList,
+			Data:                 tx.Data,
+			Nonce:                tx.Nonce,
+			Decimal:              tx.Decimal,
+			Inputs:               tx.Inputs,
+			Outputs:              tx.Outputs,
+		},
+		Status: envelopeStatus{
+			State:            tx.Status,
+			SeenAt:           tx.Timestamp,
+			BlockNumber:      tx.BlockNumber,
+			TransactionIndex: tx.TransactionIndex,
+			Replaces:         tx.ReplacedHash,
+			NonceGap:         tx.NonceGap,
+		},
+		Enrichment: envelopeEnrichment{
+			TokenTransfer: tx.TokenTransfer,
+			Swap:          tx.Swap,
+			MEV:           tx.MEV,
+			Simulation:    tx.Simulation,
+			FromLabel:     tx.FromLabel,
+			ToLabel:       tx.ToLabel,
+			Tags:          tx.Tags,
+		},
+		Canary: tx.Canary,
+		Raw:    tx.Raw,
+	}
+}
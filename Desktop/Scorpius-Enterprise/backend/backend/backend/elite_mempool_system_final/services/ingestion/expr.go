@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var exprErrors = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_expr_errors_total",
+		Help: "Filter and route expressions that failed to evaluate, counted as no match",
+	},
+	[]string{"chain", "use"},
+)
+
+// exprEnv declares the variables available to filter and route expressions.
+// tx holds the transaction's fields by their JSON names, with addresses
+// lowercased and numeric quantities decoded; value and gas_price are in wei
+// and selector is the first four bytes of calldata, e.g.
+//
+//	tx.to == '0xdac17f958d2ee523a2206206994597c13d831ec7' && value > 1e18
+//	selector in ['0xa9059cbb', '0x23b872dd'] || 'watchlist' in tx.tags
+var exprEnv = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("tx", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("value", cel.DoubleType),
+		cel.Variable("gas_price", cel.DoubleType),
+		cel.Variable("selector", cel.StringType),
+	)
+})
+
+// txExpr is a compiled boolean expression over a transaction
+type txExpr struct {
+	source  string
+	program cel.Program
+}
+
+// compileExpr parses and type-checks a boolean expression
+func compileExpr(source string) (*txExpr, error) {
+	env, err := exprEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create expression environment: %v", err)
+	}
+
+	ast, issues := env.Compile(source)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid expression %q: %v", source, issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("expression %q must return a bool, not %s", source, ast.OutputType())
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %v", source, err)
+	}
+	return &txExpr{source: source, program: program}, nil
+}
+
+// Matches evaluates the expression against vars; evaluation errors, such as
+// a missing map key, count as no match
+func (e *txExpr) Matches(vars map[string]interface{}, chain, use string) bool {
+	out, _, err := e.program.Eval(vars)
+	if err != nil {
+		exprErrors.WithLabelValues(chain, use).Inc()
+		return false
+	}
+	matched, ok := out.Value().(bool)
+	return ok && matched
+}
+
+// exprVars builds the expression variables for tx
+func exprVars(tx *Transaction) map[string]interface{} {
+	tags := make([]string, 0, len(tx.Tags))
+	for _, tag := range tx.Tags {
+		tags = append(tags, tag.Tag)
+	}
+
+	var selector string
+	if len(tx.Data) >= 10 {
+		selector = strings.ToLower(tx.Data[:10])
+	}
+
+	value := weiFloat(tx.Value)
+	gasPrice := tx.GasPrice
+	if gasPrice == "" {
+		gasPrice = tx.MaxFeePerGas
+	}
+
+	return map[string]interface{}{
+		"tx": map[string]interface{}{
+			"hash":                     tx.Hash,
+			"chain":                    tx.Chain,
+			"chain_id":                 tx.ChainID,
+			"family":                   tx.ChainFamily,
+			"type":                     tx.Type,
+			"from":                     strings.ToLower(tx.From),
+			"to":                       strings.ToLower(tx.To),
+			"value":                    value,
+			"gas":                      int64(hexToUint64(tx.Gas)),
+			"gas_price":                weiFloat(tx.GasPrice),
+			"max_fee_per_gas":          weiFloat(tx.MaxFeePerGas),
+			"max_priority_fee_per_gas": weiFloat(tx.MaxPriorityFeePerGas),
+			"nonce":                    int64(hexToUint64(tx.Nonce)),
+			"data":                     tx.Data,
+			"status":                   tx.Status,
+			"canary":                   tx.Canary,
+			"tags":                     tags,
+		},
+		"value":     value,
+		"gas_price": weiFloat(gasPrice),
+		"selector":  selector,
+	}
+}
+
+// weiFloat converts a hex quantity to a float, zero when empty
+func weiFloat(value string) float64 {
+	if value == "" {
+		return 0
+	}
+	f, _ := new(big.Float).SetInt(hexToBig(value)).Float64()
+	return f
+}
+
+// exprRoute publishes transactions matching an expression to an additional topic
+type exprRoute struct {
+	expr     *txExpr
+	template string
+}
+
+// parseExprRoutes parses "topic=expression" pairs separated by semicolons, as
+// expressions may themselves contain commas
+func parseExprRoutes(value string) map[string]string {
+	routes := make(map[string]string)
+	for _, item := range strings.Split(value, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		topic, expression, ok := strings.Cut(item, "=")
+		if !ok {
+			routes[strings.TrimSpace(item)] = ""
+			continue
+		}
+		routes[strings.TrimSpace(topic)] = strings.TrimSpace(expression)
+	}
+	return routes
+}
+
+// compileExprRoutes compiles topic template -> expression routes in topic order
+func compileExprRoutes(routes map[string]string) ([]exprRoute, error) {
+	topics := make([]string, 0, len(routes))
+	for topic := range routes {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	compiled := make([]exprRoute, 0, len(routes))
+	for _, topic := range topics {
+		if routes[topic] == "" {
+			return nil, fmt.Errorf("expression route %q has no expression", topic)
+		}
+		expr, err := compileExpr(routes[topic])
+		if err != nil {
+			return nil, fmt.Errorf("expression route %s: %v", topic, err)
+		}
+		compiled = append(compiled, exprRoute{expr: expr, template: topic})
+	}
+	return compiled, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Filter actions for transactions that fail a chain's filter rules
+const (
+	FilterDrop  = "drop"
+	FilterRoute = "route"
+)
+
+// Filter rejection reasons, used as metric labels
+const (
+	filterValue       = "min_value"
+	filterGasPrice    = "min_gas_price"
+	filterPriorityFee = "min_priority_fee"
+	filterKind        = "kind"
+	filterExpr        = "expression"
+)
+
+var txFiltered = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_tx_filtered_total",
+		Help: "Transactions rejected by filter rules, by reason and action",
+	},
+	[]string{"chain", "reason", "action"},
+)
+
+var selectorPattern = regexp.MustCompile(`^0x[0-9a-f]{8}$`)
+
+// txFilter drops or demotes low-value transactions before they reach the sink.
+//
+// A transaction passes when it meets every configured threshold, is a
+// contract creation or calls one of the selectors (if either is set) and
+// satisfies the filter expression (if any). Thresholds on fields a chain
+// does not report (gas prices on Bitcoin, say) are not checked, and
+// watchlist matches always pass.
+type txFilter struct {
+	minValue       *big.Int
+	minGasPrice    *big.Int
+	minPriorityFee *big.Int
+	creationOnly   bool
+	selectors      map[string]bool
+	expr           *txExpr
+	action         string
+	topic          string
+}
+
+// newTxFilter builds a chain's filter, returning nil when no rules are set
+func newTxFilter(options ChainOptions) (*txFilter, error) {
+	f := &txFilter{
+		creationOnly: options.FilterCreation,
+		action:       options.FilterAction,
+		topic:        options.FilterTopic,
+	}
+
+	thresholds := []struct {
+		name  string
+		value string
+		dest  **big.Int
+	}{
+		{"minimum value", options.FilterMinValue, &f.minValue},
+		{"minimum gas price", options.FilterGasPrice, &f.minGasPrice},
+		{"minimum priority fee", options.FilterMinTip, &f.minPriorityFee},
+	}
+	for _, threshold := range thresholds {
+		if threshold.value == "" {
+			continue
+		}
+		amount, ok := parseWei(threshold.value)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s %q", threshold.name, threshold.value)
+		}
+		*threshold.dest = amount
+	}
+
+	if len(options.FilterSelectors) > 0 {
+		f.selectors = make(map[string]bool)
+		for _, selector := range options.FilterSelectors {
+			selector = strings.ToLower(selector)
+			if !selectorPattern.MatchString(selector) {
+				return nil, fmt.Errorf("invalid selector %q, expected 0x and 8 hex digits", selector)
+			}
+			f.selectors[selector] = true
+		}
+	}
+
+	if options.FilterExpr != "" {
+		expr, err := compileExpr(options.FilterExpr)
+		if err != nil {
+			return nil, err
+		}
+		f.expr = expr
+	}
+
+	if f.minValue == nil && f.minGasPrice == nil && f.minPriorityFee == nil && !f.creationOnly && f.selectors == nil && f.expr == nil {
+		return nil, nil
+	}
+	if f.action != FilterDrop && f.action != FilterRoute {
+		return nil, fmt.Errorf("unknown filter action %q", f.action)
+	}
+	return f, nil
+}
+
+// Rejects returns the first rule tx fails, if any. A nil filter passes
+// everything, as do watchlisted transactions and canaries.
+func (f *txFilter) Rejects(tx *Transaction) (string, bool) {
+	if f == nil || tx.Canary || hasTag(tx, watchlistTag) {
+		return "", false
+	}
+
+	if f.minValue != nil && tx.Value != "" && hexToBig(tx.Value).Cmp(f.minValue) < 0 {
+		return filterValue, true
+	}
+
+	// Dynamic-fee transactions report their fee cap as the gas price while pending
+	gasPrice := tx.GasPrice
+	if gasPrice == "" {
+		gasPrice = tx.MaxFeePerGas
+	}
+	if f.minGasPrice != nil && gasPrice != "" && hexToBig(gasPrice).Cmp(f.minGasPrice) < 0 {
+		return filterGasPrice, true
+	}
+
+	// A legacy transaction's whole gas price is its tip
+	priorityFee := tx.MaxPriorityFeePerGas
+	if priorityFee == "" {
+		priorityFee = tx.GasPrice
+	}
+	if f.minPriorityFee != nil && priorityFee != "" && hexToBig(priorityFee).Cmp(f.minPriorityFee) < 0 {
+		return filterPriorityFee, true
+	}
+
+	if f.creationOnly || f.selectors != nil {
+		creation := f.creationOnly && tx.To == "" && tx.ChainFamily == FamilyEVM
+		selected := len(tx.Data) >= 10 && f.selectors[strings.ToLower(tx.Data[:10])]
+		if !creation && !selected {
+			return filterKind, true
+		}
+	}
+
+	if f.expr != nil && !f.expr.Matches(exprVars(tx), tx.Chain, "filter") {
+		return filterExpr, true
+	}
+	return "", false
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxGasSamples caps the samples kept per chain; on busy chains the window is
+// effectively shortened to the most recent transactions
+const maxGasSamples = 100000
+
+// GasOracleConfig configures the mempool gas price oracle
+type GasOracleConfig struct {
+	Enabled     bool
+	Window      time.Duration
+	Interval    time.Duration
+	Percentiles []string
+	Topic       string
+}
+
+// loadGasOracleConfig reads GAS_ORACLE_* settings
+func loadGasOracleConfig() GasOracleConfig {
+	return GasOracleConfig{
+		Enabled:     getEnvBool("GAS_ORACLE", false),
+		Window:      getEnvDuration("GAS_ORACLE_WINDOW", time.Minute),
+		Interval:    getEnvDuration("GAS_ORACLE_INTERVAL", 5*time.Second),
+		Percentiles: splitNonEmpty(getEnvOrDefault("GAS_ORACLE_PERCENTILES", "10,25,50,75,90,99")),
+		Topic:       getEnvOrDefault("GAS_ORACLE_TOPIC", "gas_oracle"),
+	}
+}
+
+// parsePercentiles parses percentile settings such as "50" or "99.9"
+func parsePercentiles(values []string) ([]float64, error) {
+	percentiles := make([]float64, 0, len(values))
+	for _, value := range values {
+		p, err := strconv.ParseFloat(value, 64)
+		if err != nil || p <= 0 || p > 100 {
+			return nil, fmt.Errorf("invalid percentile %q, expected a number in (0, 100]", value)
+		}
+		percentiles = append(percentiles, p)
+	}
+	sort.Float64s(percentiles)
+	return percentiles, nil
+}
+
+// GasEstimate is a chain's gas price and priority fee distribution over the
+// oracle window, in wei, keyed by percentile ("p50")
+type GasEstimate struct {
+	Chain       string            `json:"chain"`
+	ChainID     int64             `json:"chain_id"`
+	Samples     int               `json:"samples"`
+	Window      string            `json:"window"`
+	GasPrice    map[string]uint64 `json:"gas_price"`
+	PriorityFee map[string]uint64 `json:"priority_fee"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// gasSample is one pending transaction's bid
+type gasSample struct {
+	at          time.Time
+	gasPrice    uint64
+	priorityFee uint64
+}
+
+// gasSamples is a chain's samples in arrival order
+type gasSamples struct {
+	chainID int64
+	family  string
+	samples []gasSample
+}
+
+// GasOracle keeps rolling percentiles of the gas prices and priority fees
+// bid by pending transactions. It samples every EVM transaction as an
+// enricher, and every interval recomputes each chain's estimate, stores it
+// in Redis (gas_oracle:<chain>) and publishes it to the oracle topic.
+type GasOracle struct {
+	cache       Cache
+	sinks       *chainSinks
+	window      time.Duration
+	percentiles []float64
+	topic       string
+	mu          sync.Mutex
+	chains      map[string]*gasSamples
+	estimates   map[string]GasEstimate
+}
+
+// NewGasOracle creates a gas oracle from config
+func NewGasOracle(cache Cache, sinks *chainSinks, config GasOracleConfig) (*GasOracle, error) {
+	percentiles, err := parsePercentiles(config.Percentiles)
+	if err != nil {
+		return nil, err
+	}
+	return &GasOracle{
+		cache:       cache,
+		sinks:       sinks,
+		window:      config.Window,
+		percentiles: percentiles,
+		topic:       config.Topic,
+		chains:      make(map[string]*gasSamples),
+		estimates:   make(map[string]GasEstimate),
+	}, nil
+}
+
+func gasOracleKey(chain string) string {
+	return "gas_oracle:" + chain
+}
+
+// Name returns the enricher name
+func (o *GasOracle) Name() string {
+	return "gas_oracle"
+}
+
+// Enrich records tx's bid; it does not modify tx
+func (o *GasOracle) Enrich(tx *Transaction) {
+	if tx.ChainFamily != FamilyEVM || tx.Canary || tx.Status != "pending" {
+		return
+	}
+
+	// Dynamic-fee transactions bid their fee cap; a legacy gas price is all tip
+	gasPrice, priorityFee := tx.GasPrice, tx.MaxPriorityFeePerGas
+	if gasPrice == "" {
+		gasPrice = tx.MaxFeePerGas
+	}
+	if priorityFee == "" {
+		priorityFee = tx.GasPrice
+	}
+	if gasPrice == "" {
+		return
+	}
+
+	sample := gasSample{at: time.Now(), gasPrice: hexToUint64(gasPrice), priorityFee: hexToUint64(priorityFee)}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	chain, ok := o.chains[tx.Chain]
+	if !ok {
+		chain = &gasSamples{chainID: tx.ChainID, family: tx.ChainFamily}
+		o.chains[tx.Chain] = chain
+	}
+	if len(chain.samples) >= maxGasSamples {
+		chain.samples = chain.samples[len(chain.samples)-maxGasSamples+1:]
+	}
+	chain.samples = append(chain.samples, sample)
+}
+
+// Run recomputes and publishes estimates every interval until ctx is cancelled
+func (o *GasOracle) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, estimate := range o.update() {
+				// The chain's leader caches and publishes its estimates
+				if o.sinks.standby(estimate.Chain) {
+					continue
+				}
+				o.publish(ctx, estimate, interval)
+			}
+		}
+	}
+}
+
+// update drops samples older than the window and recomputes every chain's estimate
+func (o *GasOracle) update() []GasEstimate {
+	cutoff := time.Now().Add(-o.window)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var updated []GasEstimate
+	for name, chain := range o.chains {
+		expired := sort.Search(len(chain.samples), func(i int) bool {
+			return chain.samples[i].at.After(cutoff)
+		})
+		chain.samples = append(chain.samples[:0], chain.samples[expired:]...)
+		if len(chain.samples) == 0 {
+			continue
+		}
+
+		gasPrices := make([]uint64, len(chain.samples))
+		priorityFees := make([]uint64, len(chain.samples))
+		for i, sample := range chain.samples {
+			gasPrices[i] = sample.gasPrice
+			priorityFees[i] = sample.priorityFee
+		}
+
+		estimate := GasEstimate{
+			Chain:       name,
+			ChainID:     chain.chainID,
+			Samples:     len(chain.samples),
+			Window:      o.window.String(),
+			GasPrice:    o.distribution(gasPrices),
+			PriorityFee: o.distribution(priorityFees),
+			UpdatedAt:   time.Now(),
+		}
+		o.estimates[name] = estimate
+		updated = append(updated, estimate)
+	}
+	return updated
+}
+
+// distribution returns the configured nearest-rank percentiles of values
+func (o *GasOracle) distribution(values []uint64) map[string]uint64 {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	result := make(map[string]uint64, len(o.percentiles))
+	for _, p := range o.percentiles {
+		rank := int(math.Ceil(p/100*float64(len(values)))) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		result["p"+strconv.FormatFloat(p, 'f', -1, 64)] = values[rank]
+	}
+	return result
+}
+
+// publish caches an estimate in Redis and produces it to the oracle topic
+func (o *GasOracle) publish(ctx context.Context, estimate GasEstimate, interval time.Duration) {
+	data, err := json.Marshal(estimate)
+	if err != nil {
+		slog.Error("failed to marshal gas estimate", "chain", estimate.Chain, "error", err)
+		return
+	}
+
+	// Keep the cached estimate a little past the next refresh so readers never see a gap
+	if err := o.cache.Set(ctx, gasOracleKey(estimate.Chain), data, 3*interval); err != nil {
+		slog.Warn("failed to cache gas estimate", "chain", estimate.Chain, "error", err)
+	}
+
+	o.mu.Lock()
+	family := o.chains[estimate.Chain].family
+	o.mu.Unlock()
+
+	topic := expandTopic(o.topic, estimate.Chain, estimate.ChainID, family)
+	headers := map[string]string{
+		"chain_id":   fmt.Sprintf("%d", estimate.ChainID),
+		"chain_name": estimate.Chain,
+		"format":     FormatJSON,
+	}
+	if err := o.sinks.Publish(ctx, estimate.Chain, topic, []byte(estimate.Chain), data, headers); err != nil {
+		slog.Warn("failed to publish gas estimate", "chain", estimate.Chain, "error", err)
+	}
+}
+
+// Estimate returns the latest estimate for chain
+func (o *GasOracle) Estimate(chain string) (GasEstimate, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	estimate, ok := o.estimates[chain]
+	return estimate, ok
+}
+
+// registerGasOracleAPI mounts the gas oracle endpoint
+func (is *IngestionService) registerGasOracleAPI(mux *http.ServeMux) {
+	mux.HandleFunc("/api/gas", func(w http.ResponseWriter, r *http.Request) {
+		if is.gasOracle == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "gas oracle is not enabled"})
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+
+		if chain := r.URL.Query().Get("chain"); chain != "" {
+			estimate, ok := is.gasOracle.Estimate(chain)
+			if !ok {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": "no estimate for " + chain})
+				return
+			}
+			writeJSON(w, http.StatusOK, estimate)
+			return
+		}
+
+		estimates := make(map[string]GasEstimate)
+		for _, chain := range is.chainNames() {
+			if estimate, ok := is.gasOracle.Estimate(chain); ok {
+				estimates[chain] = estimate
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"estimates": estimates})
+	})
+}
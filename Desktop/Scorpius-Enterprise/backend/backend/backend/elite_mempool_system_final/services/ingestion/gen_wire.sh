@@ -0,0 +1,10 @@
+#!/bin/sh
+# Generates wire_easyjson.go. easyjson cannot bootstrap inside package main,
+# so the codecs are generated for a copy of wire.go in a scratch package.
+set -e
+dir=$(mktemp -d ./.wiregen.XXXXXX)
+trap 'rm -rf "$dir"' EXIT
+sed 's/^package main$/package wiregen/' wire.go > "$dir/wire.go"
+printf 'package wiregen\n\nimport "encoding/json"\n\ntype rpcError struct{ Data json.RawMessage }\n' > "$dir/stub.go"
+(cd "$dir" && easyjson -output_filename wire_easyjson.go wire.go)
+sed 's/^package wiregen$/package main/' "$dir/wire_easyjson.go" > wire_easyjson.go
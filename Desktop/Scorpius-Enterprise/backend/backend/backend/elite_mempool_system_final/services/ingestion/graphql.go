@@ -0,0 +1,628 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// graphqlTransport labels the recent transaction buffer in the stream metrics
+const graphqlTransport = "graphql"
+
+// graphqlSchema is the schema served on /graphql. Amounts are strings
+// because wei values overflow GraphQL integers.
+const graphqlSchema = `
+schema {
+	query: Query
+}
+
+type Query {
+	# Recently published transactions, newest first. Every argument narrows
+	# the result: filter takes an expression in the FILTER_EXPR language and
+	# minValue is in wei.
+	transactions(chain: String, from: String, to: String, status: String, tag: String, minValue: Float, filter: String, limit: Int = 100): [Transaction!]!
+	# A recently published transaction
+	transaction(hash: String!): Transaction
+	# Monitored chains, sorted by name
+	chains(chain: String): [Chain!]!
+	# Latest gas oracle estimates, empty when the oracle is disabled
+	gasEstimates(chain: String): [GasEstimate!]!
+}
+
+type Transaction {
+	hash: String!
+	chain: String!
+	chainId: Int!
+	family: String!
+	type: String!
+	from: String!
+	to: String
+	value: String!
+	gas: String!
+	gasPrice: String
+	maxFeePerGas: String
+	maxPriorityFeePerGas: String
+	nonce: String!
+	data: String!
+	status: String!
+	timestamp: Float!
+	tags: [String!]!
+	replacedHash: String
+	mev: MEV
+	simulation: Simulation
+	swap: Swap
+	fromLabel: Label
+	toLabel: Label
+	decimal: Decimal
+}
+
+type MEV {
+	kinds: [String!]!
+	protocol: String!
+	method: String
+	confidence: Float!
+}
+
+type Simulation {
+	success: Boolean!
+	revertReason: String
+}
+
+type Swap {
+	protocol: String!
+	method: String!
+	router: String!
+	exactInput: Boolean!
+	tokenIn: String
+	tokenOut: String
+	amountIn: String
+	amountOut: String
+	amountOutMin: String
+	amountInMax: String
+	path: [String!]!
+	pools: [String!]!
+	deadline: Float
+}
+
+type Label {
+	name: String
+	category: String!
+	source: String!
+}
+
+type Decimal {
+	value: String
+	gas: String
+	gasPrice: String
+	maxFeePerGas: String
+	maxPriorityFeePerGas: String
+	maxFeePerBlobGas: String
+	nonce: String
+}
+
+type Chain {
+	name: String!
+	chainId: Int!
+	family: String!
+	connected: Boolean!
+	warmup: Boolean!
+	txRate: Float!
+	txTotal: Float!
+	# Published transactions held for the transactions query
+	buffered: Int!
+	endpoints(active: Boolean, minHealth: Float): [Endpoint!]!
+	gas: GasEstimate
+}
+
+type Endpoint {
+	endpoint: String!
+	healthScore: Float!
+	active: Boolean!
+	disabled: String
+}
+
+type GasEstimate {
+	chain: String!
+	chainId: Int!
+	samples: Int!
+	window: String!
+	gasPrice: [Percentile!]!
+	priorityFee: [Percentile!]!
+	updatedAt: String!
+}
+
+type Percentile {
+	percentile: String!
+	wei: String!
+}
+`
+
+// GraphQLConfig configures the GraphQL query API
+type GraphQLConfig struct {
+	Enabled     bool
+	Recent      int
+	MaxDepth    int
+	CORSOrigins []string
+}
+
+// loadGraphQLConfig reads GRAPHQL_* settings
+func loadGraphQLConfig() GraphQLConfig {
+	return GraphQLConfig{
+		Enabled:     getEnvBool("GRAPHQL_API", false),
+		Recent:      getEnvInt("GRAPHQL_RECENT_TXS", 1000),
+		MaxDepth:    getEnvInt("GRAPHQL_MAX_DEPTH", 8),
+		CORSOrigins: splitNonEmpty(getEnv("GRAPHQL_CORS_ORIGINS")),
+	}
+}
+
+// recentTxs keeps the most recently published transactions for queries,
+// fed from the stream hub, whose detached copies are never recycled
+type recentTxs struct {
+	mu   sync.RWMutex
+	txs  []*Transaction
+	next int
+	full bool
+}
+
+func newRecentTxs(size int) *recentTxs {
+	return &recentTxs{txs: make([]*Transaction, size)}
+}
+
+// Run buffers published transactions until ctx is cancelled
+func (r *recentTxs) Run(ctx context.Context, hub *txHub, buffer int) {
+	sub := hub.Subscribe(graphqlTransport, buffer, nil)
+	defer hub.Unsubscribe(sub)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case tx := <-sub.C:
+			r.add(tx)
+		}
+	}
+}
+
+func (r *recentTxs) add(tx *Transaction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.txs[r.next] = tx
+	r.next = (r.next + 1) % len(r.txs)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Each calls fn for buffered transactions, newest first, until it returns false
+func (r *recentTxs) Each(fn func(tx *Transaction) bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := r.next
+	if r.full {
+		count = len(r.txs)
+	}
+	for i := 1; i <= count; i++ {
+		if !fn(r.txs[(r.next-i+len(r.txs))%len(r.txs)]) {
+			return
+		}
+	}
+}
+
+// registerGraphQL mounts /graphql, which answers queries over recent
+// transactions, chain and endpoint health and gas estimates. Queries are
+// POSTed as JSON ({"query", "operationName", "variables"}) or sent as GET
+// query parameters.
+func (is *IngestionService) registerGraphQL(mux *http.ServeMux) {
+	if !is.config.GraphQL.Enabled {
+		mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "GraphQL API is not enabled"})
+		})
+		return
+	}
+
+	schema := graphql.MustParseSchema(graphqlSchema, &graphqlResolver{is},
+		graphql.MaxDepth(is.config.GraphQL.MaxDepth))
+
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && allowedOrigin(origin, is.config.GraphQL.CORSOrigins) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Add("Vary", "Origin")
+		}
+
+		var params struct {
+			Query         string                 `json:"query"`
+			OperationName string                 `json:"operationName"`
+			Variables     map[string]interface{} `json:"variables"`
+		}
+		switch r.Method {
+		case http.MethodOptions:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case http.MethodGet:
+			query := r.URL.Query()
+			params.Query = query.Get("query")
+			params.OperationName = query.Get("operationName")
+			if variables := query.Get("variables"); variables != "" {
+				if err := json.Unmarshal([]byte(variables), &params.Variables); err != nil {
+					writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid variables: " + err.Error()})
+					return
+				}
+			}
+		case http.MethodPost:
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&params); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request: " + err.Error()})
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST, OPTIONS")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, schema.Exec(r.Context(), params.Query, params.OperationName, params.Variables))
+	})
+}
+
+// allowedOrigin reports whether a browser at origin may call the API
+func allowedOrigin(origin string, allowed []string) bool {
+	for _, candidate := range allowed {
+		if candidate == "*" || strings.EqualFold(candidate, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// graphqlResolver resolves the Query type
+type graphqlResolver struct {
+	is *IngestionService
+}
+
+type transactionsArgs struct {
+	Chain    *string
+	From     *string
+	To       *string
+	Status   *string
+	Tag      *string
+	MinValue *float64
+	Filter   *string
+	Limit    int32
+}
+
+func (q *graphqlResolver) Transactions(args transactionsArgs) ([]*txResolver, error) {
+	var chains []string
+	if args.Chain != nil {
+		chains = []string{*args.Chain}
+	}
+	var expression string
+	if args.Filter != nil {
+		expression = *args.Filter
+	}
+	match, err := txMatcher(chains, expression, graphqlTransport)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*txResolver{}
+	if q.is.recent == nil || args.Limit <= 0 {
+		return result, nil
+	}
+	q.is.recent.Each(func(tx *Transaction) bool {
+		switch {
+		case !match(tx):
+		case args.From != nil && !strings.EqualFold(tx.From, *args.From):
+		case args.To != nil && !strings.EqualFold(tx.To, *args.To):
+		case args.Status != nil && tx.Status != *args.Status:
+		case args.Tag != nil && !hasTag(tx, *args.Tag):
+		case args.MinValue != nil && weiFloat(tx.Value) < *args.MinValue:
+		default:
+			result = append(result, &txResolver{tx})
+		}
+		return len(result) < int(args.Limit)
+	})
+	return result, nil
+}
+
+func (q *graphqlResolver) Transaction(args struct{ Hash string }) *txResolver {
+	var found *txResolver
+	if q.is.recent != nil {
+		q.is.recent.Each(func(tx *Transaction) bool {
+			if strings.EqualFold(tx.Hash, args.Hash) {
+				found = &txResolver{tx}
+				return false
+			}
+			return true
+		})
+	}
+	return found
+}
+
+func (q *graphqlResolver) Chains(args struct{ Chain *string }) []*chainResolver {
+	buffered := make(map[string]int32)
+	if q.is.recent != nil {
+		q.is.recent.Each(func(tx *Transaction) bool {
+			buffered[tx.Chain]++
+			return true
+		})
+	}
+
+	var chains []*chainResolver
+	for _, status := range q.is.chainStatuses() {
+		if args.Chain != nil && status.Chain != *args.Chain {
+			continue
+		}
+		chains = append(chains, &chainResolver{status: status, buffered: buffered[status.Chain], oracle: q.is.gasOracle})
+	}
+	return chains
+}
+
+func (q *graphqlResolver) GasEstimates(args struct{ Chain *string }) []*gasEstimateResolver {
+	var estimates []*gasEstimateResolver
+	if q.is.gasOracle == nil {
+		return estimates
+	}
+	for _, chain := range q.is.chainNames() {
+		if args.Chain != nil && chain != *args.Chain {
+			continue
+		}
+		if estimate, ok := q.is.gasOracle.Estimate(chain); ok {
+			estimates = append(estimates, &gasEstimateResolver{estimate})
+		}
+	}
+	return estimates
+}
+
+// optionalString is nil for an empty value, which GraphQL returns as null
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// txResolver resolves a published transaction, which it must not modify
+type txResolver struct {
+	tx *Transaction
+}
+
+func (r *txResolver) Hash() string                  { return r.tx.Hash }
+func (r *txResolver) Chain() string                 { return r.tx.Chain }
+func (r *txResolver) ChainID() int32                { return int32(r.tx.ChainID) }
+func (r *txResolver) Family() string                { return r.tx.ChainFamily }
+func (r *txResolver) Type() string                  { return r.tx.Type }
+func (r *txResolver) From() string                  { return r.tx.From }
+func (r *txResolver) To() *string                   { return optionalString(r.tx.To) }
+func (r *txResolver) Value() string                 { return r.tx.Value }
+func (r *txResolver) Gas() string                   { return r.tx.Gas }
+func (r *txResolver) GasPrice() *string             { return optionalString(r.tx.GasPrice) }
+func (r *txResolver) MaxFeePerGas() *string         { return optionalString(r.tx.MaxFeePerGas) }
+func (r *txResolver) MaxPriorityFeePerGas() *string { return optionalString(r.tx.MaxPriorityFeePerGas) }
+func (r *txResolver) Nonce() string                 { return r.tx.Nonce }
+func (r *txResolver) Data() string                  { return r.tx.Data }
+func (r *txResolver) Status() string                { return r.tx.Status }
+func (r *txResolver) Timestamp() float64            { return float64(r.tx.Timestamp) }
+func (r *txResolver) ReplacedHash() *string         { return optionalString(r.tx.ReplacedHash) }
+
+func (r *txResolver) Tags() []string {
+	tags := make([]string, 0, len(r.tx.Tags))
+	for _, tag := range r.tx.Tags {
+		tags = append(tags, tag.Tag)
+	}
+	return tags
+}
+
+func (r *txResolver) MEV() *mevResolver {
+	if r.tx.MEV == nil {
+		return nil
+	}
+	return &mevResolver{r.tx.MEV}
+}
+
+func (r *txResolver) Simulation() *simulationResolver {
+	if r.tx.Simulation == nil {
+		return nil
+	}
+	return &simulationResolver{r.tx.Simulation}
+}
+
+func (r *txResolver) Swap() *swapResolver {
+	if r.tx.Swap == nil {
+		return nil
+	}
+	return &swapResolver{r.tx.Swap}
+}
+
+func (r *txResolver) FromLabel() *labelResolver {
+	if r.tx.FromLabel == nil {
+		return nil
+	}
+	return &labelResolver{r.tx.FromLabel}
+}
+
+func (r *txResolver) ToLabel() *labelResolver {
+	if r.tx.ToLabel == nil {
+		return nil
+	}
+	return &labelResolver{r.tx.ToLabel}
+}
+
+func (r *txResolver) Decimal() *decimalResolver {
+	if r.tx.Decimal == nil {
+		return nil
+	}
+	return &decimalResolver{r.tx.Decimal}
+}
+
+type mevResolver struct {
+	mev *MEVClassification
+}
+
+func (r *mevResolver) Kinds() []string     { return r.mev.Kinds }
+func (r *mevResolver) Protocol() string    { return r.mev.Protocol }
+func (r *mevResolver) Method() *string     { return optionalString(r.mev.Method) }
+func (r *mevResolver) Confidence() float64 { return r.mev.Confidence }
+
+type simulationResolver struct {
+	simulation *Simulation
+}
+
+func (r *simulationResolver) Success() bool         { return r.simulation.Success }
+func (r *simulationResolver) RevertReason() *string { return optionalString(r.simulation.RevertReason) }
+
+type swapResolver struct {
+	swap *SwapIntent
+}
+
+func (r *swapResolver) Protocol() string      { return r.swap.Protocol }
+func (r *swapResolver) Method() string        { return r.swap.Method }
+func (r *swapResolver) Router() string        { return r.swap.Router }
+func (r *swapResolver) ExactInput() bool      { return r.swap.ExactInput }
+func (r *swapResolver) TokenIn() *string      { return optionalString(r.swap.TokenIn) }
+func (r *swapResolver) TokenOut() *string     { return optionalString(r.swap.TokenOut) }
+func (r *swapResolver) AmountIn() *string     { return optionalString(r.swap.AmountIn) }
+func (r *swapResolver) AmountOut() *string    { return optionalString(r.swap.AmountOut) }
+func (r *swapResolver) AmountOutMin() *string { return optionalString(r.swap.AmountOutMin) }
+func (r *swapResolver) AmountInMax() *string  { return optionalString(r.swap.AmountInMax) }
+func (r *swapResolver) Path() []string        { return append([]string{}, r.swap.Path...) }
+func (r *swapResolver) Pools() []string       { return append([]string{}, r.swap.Pools...) }
+
+func (r *swapResolver) Deadline() *float64 {
+	if r.swap.Deadline == 0 {
+		return nil
+	}
+	deadline := float64(r.swap.Deadline)
+	return &deadline
+}
+
+type labelResolver struct {
+	label *AddressLabel
+}
+
+func (r *labelResolver) Name() *string    { return optionalString(r.label.Name) }
+func (r *labelResolver) Category() string { return r.label.Category }
+func (r *labelResolver) Source() string   { return r.label.Source }
+
+type decimalResolver struct {
+	decimal *DecimalAmounts
+}
+
+func (r *decimalResolver) Value() *string        { return optionalString(r.decimal.Value) }
+func (r *decimalResolver) Gas() *string          { return optionalString(r.decimal.Gas) }
+func (r *decimalResolver) GasPrice() *string     { return optionalString(r.decimal.GasPrice) }
+func (r *decimalResolver) MaxFeePerGas() *string { return optionalString(r.decimal.MaxFeePerGas) }
+func (r *decimalResolver) Nonce() *string        { return optionalString(r.decimal.Nonce) }
+
+func (r *decimalResolver) MaxPriorityFeePerGas() *string {
+	return optionalString(r.decimal.MaxPriorityFeePerGas)
+}
+
+func (r *decimalResolver) MaxFeePerBlobGas() *string {
+	return optionalString(r.decimal.MaxFeePerBlobGas)
+}
+
+// chainResolver resolves a chain monitor's status
+type chainResolver struct {
+	status   ChainStatus
+	buffered int32
+	oracle   *GasOracle
+}
+
+func (r *chainResolver) Name() string     { return r.status.Chain }
+func (r *chainResolver) ChainID() int32   { return int32(r.status.ChainID) }
+func (r *chainResolver) Family() string   { return r.status.Family }
+func (r *chainResolver) Connected() bool  { return r.status.Connected }
+func (r *chainResolver) Warmup() bool     { return r.status.Warmup }
+func (r *chainResolver) TxRate() float64  { return r.status.TxRate }
+func (r *chainResolver) TxTotal() float64 { return float64(r.status.TxTotal) }
+func (r *chainResolver) Buffered() int32  { return r.buffered }
+
+func (r *chainResolver) Endpoints(args struct {
+	Active    *bool
+	MinHealth *float64
+}) []*endpointResolver {
+	endpoints := []*endpointResolver{}
+	for _, endpoint := range r.status.Endpoints {
+		if args.Active != nil && endpoint.Active != *args.Active {
+			continue
+		}
+		if args.MinHealth != nil && endpoint.HealthScore < *args.MinHealth {
+			continue
+		}
+		endpoints = append(endpoints, &endpointResolver{endpoint})
+	}
+	return endpoints
+}
+
+func (r *chainResolver) Gas() *gasEstimateResolver {
+	if r.oracle == nil {
+		return nil
+	}
+	estimate, ok := r.oracle.Estimate(r.status.Chain)
+	if !ok {
+		return nil
+	}
+	return &gasEstimateResolver{estimate}
+}
+
+type endpointResolver struct {
+	endpoint EndpointStatus
+}
+
+func (r *endpointResolver) Endpoint() string     { return r.endpoint.Endpoint }
+func (r *endpointResolver) HealthScore() float64 { return r.endpoint.HealthScore }
+func (r *endpointResolver) Active() bool         { return r.endpoint.Active }
+func (r *endpointResolver) Disabled() *string    { return optionalString(r.endpoint.Disabled) }
+
+// gasEstimateResolver resolves a gas oracle estimate
+type gasEstimateResolver struct {
+	estimate GasEstimate
+}
+
+func (r *gasEstimateResolver) Chain() string  { return r.estimate.Chain }
+func (r *gasEstimateResolver) ChainID() int32 { return int32(r.estimate.ChainID) }
+func (r *gasEstimateResolver) Samples() int32 { return int32(r.estimate.Samples) }
+func (r *gasEstimateResolver) Window() string { return r.estimate.Window }
+func (r *gasEstimateResolver) UpdatedAt() string {
+	return r.estimate.UpdatedAt.UTC().Format(time.RFC3339)
+}
+
+func (r *gasEstimateResolver) GasPrice() []*percentileResolver {
+	return percentiles(r.estimate.GasPrice)
+}
+
+func (r *gasEstimateResolver) PriorityFee() []*percentileResolver {
+	return percentiles(r.estimate.PriorityFee)
+}
+
+type percentileResolver struct {
+	percentile string
+	wei        uint64
+}
+
+func (r *percentileResolver) Percentile() string { return r.percentile }
+func (r *percentileResolver) Wei() string        { return strconv.FormatUint(r.wei, 10) }
+
+// percentiles lists a distribution in ascending percentile order
+func percentiles(distribution map[string]uint64) []*percentileResolver {
+	result := make([]*percentileResolver, 0, len(distribution))
+	for percentile, wei := range distribution {
+		result = append(result, &percentileResolver{percentile, wei})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, _ := strconv.ParseFloat(strings.TrimPrefix(result[i].percentile, "p"), 64)
+		b, _ := strconv.ParseFloat(strings.TrimPrefix(result[j].percentile, "p"), 64)
+		return a < b
+	})
+	return result
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// wireMessage is a gRPC message encoded by hand with protowire, like the
+// transaction encoder, so the services need no generated code. The layouts
+// are in proto/.
+type wireMessage interface {
+	marshalWire() []byte
+	unmarshalWire(b []byte) error
+}
+
+// wireCodec is the gRPC codec for wireMessages. It registers as "proto", so
+// clients generated from proto/ talk to it unchanged.
+type wireCodec struct{}
+
+func (wireCodec) Name() string {
+	return "proto"
+}
+
+func (wireCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(wireMessage)
+	if !ok {
+		return nil, fmt.Errorf("cannot marshal %T", v)
+	}
+	return m.marshalWire(), nil
+}
+
+func (wireCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(wireMessage)
+	if !ok {
+		return fmt.Errorf("cannot unmarshal into %T", v)
+	}
+	return m.unmarshalWire(data)
+}
+
+// wireField is one decoded field: varints in varint, everything else in bytes
+type wireField struct {
+	num    protowire.Number
+	typ    protowire.Type
+	varint uint64
+	bytes  []byte
+}
+
+// decodeFields calls field for each field of an encoded message, skipping
+// fixed-width and group fields, which none of the messages use
+func decodeFields(b []byte, field func(f wireField) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		f := wireField{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if typ == protowire.VarintType || typ == protowire.BytesType {
+			if err := field(f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// appendBool writes a singular bool field, omitting the proto3 default
+func appendBool(b []byte, num protowire.Number, v bool) []byte {
+	if !v {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, 1)
+}
+
+// appendPackedVarints writes a packed repeated integer field
+func appendPackedVarints(b []byte, num protowire.Number, values []uint64) []byte {
+	if len(values) == 0 {
+		return b
+	}
+	var packed []byte
+	for _, v := range values {
+		packed = protowire.AppendVarint(packed, v)
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, packed)
+}
+
+// consumeVarints reads a repeated integer field, packed or not
+func consumeVarints(f wireField) ([]uint64, error) {
+	if f.typ == protowire.VarintType {
+		return []uint64{f.varint}, nil
+	}
+	var values []uint64
+	for b := f.bytes; len(b) > 0; {
+		v, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		values = append(values, v)
+		b = b[n:]
+	}
+	return values, nil
+}
+
+// startGRPCServer serves the registered gRPC services on addr
+func startGRPCServer(addr string, options []grpc.ServerOption, register func(*grpc.Server)) (*grpc.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for gRPC on %s: %v", addr, err)
+	}
+
+	server := grpc.NewServer(append(options, grpc.ForceServerCodec(wireCodec{}))...)
+	register(server)
+
+	go func() {
+		slog.Info("gRPC server listening", "addr", addr)
+		if err := server.Serve(listener); err != nil {
+			slog.Error("gRPC server failed", "error", err)
+		}
+	}()
+	return server, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// pinger is implemented by sinks that can check connectivity to their backend
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// registerHealth mounts the Prometheus metrics endpoint and the Kubernetes
+// liveness and readiness probes
+func (is *IngestionService) registerHealth(mux *http.ServeMux) {
+	mux.Handle("/metrics", promhttp.Handler())
+
+	// Liveness only reports that the process is serving requests; a broken
+	// dependency should take the pod out of rotation, not restart it
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+
+		checks, ready := is.readiness(ctx)
+		status, code := "ready", http.StatusOK
+		if !ready {
+			status, code = "not ready", http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, map[string]interface{}{
+			"status": status,
+			"checks": checks,
+		})
+	})
+}
+
+// readiness checks the cache, every sink that supports it and that each chain has
+// at least one healthy endpoint. It returns the result of each check by name.
+func (is *IngestionService) readiness(ctx context.Context) (map[string]string, bool) {
+	checks := make(map[string]string)
+	ready := true
+	record := func(name string, err error) {
+		if err != nil {
+			checks[name] = err.Error()
+			ready = false
+			return
+		}
+		checks[name] = "ok"
+	}
+
+	record(is.cache.Name(), is.cache.Ping(ctx))
+
+	is.mu.RLock()
+	sinks := make([]Sink, 0, len(is.sinks))
+	for _, sink := range is.sinks {
+		sinks = append(sinks, sink)
+	}
+	is.mu.RUnlock()
+	sort.Slice(sinks, func(i, j int) bool { return sinks[i].Name() < sinks[j].Name() })
+
+	for _, sink := range sinks {
+		if p, ok := sink.(pinger); ok {
+			record("sink:"+sink.Name(), p.Ping(ctx))
+		}
+	}
+
+	for _, chain := range is.chainStatuses() {
+		record("chain:"+chain.Chain, chainReadiness(chain))
+	}
+	return checks, ready
+}
+
+// chainReadiness reports an error unless the chain is connected or has an
+// enabled endpoint with a passing health score
+func chainReadiness(chain ChainStatus) error {
+	if chain.Connected {
+		return nil
+	}
+	for _, endpoint := range chain.Endpoints {
+		if endpoint.Disabled == "" && endpoint.HealthScore >= minHealthyScore {
+			return nil
+		}
+	}
+	return fmt.Errorf("no healthy endpoint")
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/hex"
+	"math"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// honeypotTag marks token deployments scoring at or above the risk threshold
+const honeypotTag = "honeypot_risk"
+
+// tokenRiskFactor is a group of owner-only functions common in scam tokens
+type tokenRiskFactor struct {
+	name       string
+	weight     float64
+	signatures []string
+}
+
+// tokenRiskFactors are scored against the selectors of ERC-20 deployments.
+// Each is legitimate on its own; together they let the owner mint at will,
+// stop chosen holders from selling or raise the transfer fee after launch.
+var tokenRiskFactors = []tokenRiskFactor{
+	{"mint", 0.3, []string{"mint(address,uint256)", "mint(uint256)", "mintTo(address,uint256)", "issue(uint256)"}},
+	{"blacklist", 0.3, []string{
+		"blacklist(address)", "addToBlacklist(address)", "setBlacklist(address,bool)", "blacklistAddress(address,bool)",
+		"addBots(address[])", "setBots(address[])", "setBot(address,bool)", "addBot(address)", "setIsBot(address,bool)",
+	}},
+	{"adjustable_fee", 0.2, []string{
+		"setFee(uint256)", "setTaxFee(uint256)", "setTaxFeePercent(uint256)", "setBuyFee(uint256)", "setSellFee(uint256)",
+		"setFees(uint256,uint256)", "updateFees(uint256,uint256)", "setTax(uint256,uint256)",
+	}},
+	{"trading_switch", 0.2, []string{"enableTrading()", "openTrading()", "setTradingEnabled(bool)", "setTrading(bool)", "pause()"}},
+	{"transfer_limit", 0.1, []string{"setMaxTxAmount(uint256)", "setMaxTxPercent(uint256)", "setMaxWalletSize(uint256)", "setMaxWallet(uint256)"}},
+}
+
+// erc20Selectors must all be present for code to be scored as a token
+var erc20Selectors = []string{
+	selectorOf("transfer(address,uint256)"),
+	selectorOf("transferFrom(address,address,uint256)"),
+	selectorOf("balanceOf(address)"),
+}
+
+// riskFactorSelectors maps each factor's selectors to the factor
+var riskFactorSelectors = func() map[string]*tokenRiskFactor {
+	selectors := make(map[string]*tokenRiskFactor)
+	for i := range tokenRiskFactors {
+		for _, signature := range tokenRiskFactors[i].signatures {
+			selectors[selectorOf(signature)] = &tokenRiskFactors[i]
+		}
+	}
+	return selectors
+}()
+
+// selectorOf returns the 0x-prefixed function selector of a signature
+func selectorOf(signature string) string {
+	return "0x" + hex.EncodeToString(crypto.Keccak256([]byte(signature))[:4])
+}
+
+// tokenRisk scores a deployment that looks like an ERC-20 token between 0
+// and 1 from the owner-only functions its dispatcher exposes and the risky
+// opcodes in its code. It reports whether the code is a token at all.
+//
+// Only function selectors are matched, so renamed functions go unnoticed and
+// the score is a prompt for review rather than a verdict.
+func tokenRisk(fp bytecodeFingerprint) (float64, []string, bool) {
+	present := make(map[string]bool, len(fp.selectors))
+	for _, selector := range fp.selectors {
+		present[selector] = true
+	}
+	for _, selector := range erc20Selectors {
+		if !present[selector] {
+			return 0, nil, false
+		}
+	}
+
+	var score float64
+	var factors []string
+	for _, selector := range fp.selectors {
+		factor, ok := riskFactorSelectors[selector]
+		if !ok || containsString(factors, factor.name) {
+			continue
+		}
+		factors = append(factors, factor.name)
+		score += factor.weight
+	}
+
+	// A token that can destroy itself or run code it does not contain can
+	// change behaviour after holders buy in
+	for _, opcode := range fp.opcodes {
+		switch opcode {
+		case "selfdestruct":
+			factors = append(factors, opcode)
+			score += 0.2
+		case "delegatecall":
+			if len(fp.patterns) == 0 {
+				factors = append(factors, opcode)
+				score += 0.1
+			}
+		}
+	}
+
+	return math.Min(math.Round(score*100)/100, 1), factors, true
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	streamSubscribers = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_stream_subscribers",
+			Help: "Clients consuming transactions directly from the service, by transport",
+		},
+		[]string{"transport"},
+	)
+
+	streamDropped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_stream_dropped_total",
+			Help: "Transactions not delivered to a streaming client because its buffer was full, by transport",
+		},
+		[]string{"transport"},
+	)
+)
+
+// StreamConfig configures direct transaction streaming to clients
+type StreamConfig struct {
+	GRPC      bool
+	WebSocket bool
+	SSE       bool
+	SSEReplay int
+	Buffer    int
+	Tokens    map[string]string
+	RateLimit float64
+	RateBurst int
+}
+
+// loadStreamConfig reads the streaming settings
+func loadStreamConfig() StreamConfig {
+	return StreamConfig{
+		GRPC:      getEnvBool("GRPC_STREAMING", false),
+		WebSocket: getEnvBool("WS_FANOUT", false),
+		SSE:       getEnvBool("SSE_STREAMING", false),
+		SSEReplay: getEnvInt("SSE_REPLAY", 10000),
+		Buffer:    getEnvInt("STREAM_BUFFER", 1024),
+		Tokens:    parseKeyValues(getEnv("STREAM_TOKENS")),
+		RateLimit: getEnvFloat("STREAM_RATE_LIMIT", 100),
+		RateBurst: getEnvInt("STREAM_RATE_BURST", 100),
+	}
+}
+
+// txSubscription is one streaming client's feed
+type txSubscription struct {
+	C         chan *Transaction
+	match     func(*Transaction) bool
+	transport string
+}
+
+// txHub fans published transactions out to streaming clients. Publishing
+// never blocks ingestion: a client that falls a full buffer behind misses
+// transactions until it catches up.
+type txHub struct {
+	mu   sync.RWMutex
+	subs map[*txSubscription]struct{}
+}
+
+func newTxHub() *txHub {
+	return &txHub{subs: make(map[*txSubscription]struct{})}
+}
+
+// Subscribe registers a client receiving transactions that match
+func (h *txHub) Subscribe(transport string, buffer int, match func(*Transaction) bool) *txSubscription {
+	sub := &txSubscription{
+		C:         make(chan *Transaction, buffer),
+		match:     match,
+		transport: transport,
+	}
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	streamSubscribers.WithLabelValues(transport).Inc()
+	return sub
+}
+
+// Unsubscribe removes a client
+func (h *txHub) Unsubscribe(sub *txSubscription) {
+	h.mu.Lock()
+	_, ok := h.subs[sub]
+	delete(h.subs, sub)
+	h.mu.Unlock()
+	if ok {
+		streamSubscribers.WithLabelValues(sub.transport).Dec()
+	}
+}
+
+// Publish offers a published transaction to every matching client. Canaries
+// and transactions demoted by the spam filter are not streamed. tx is pooled
+// and recycled once Publish returns, so clients share a detached copy and must
+// treat it as read-only.
+func (h *txHub) Publish(tx *Transaction) {
+	if tx.Canary || tx.lowPriority {
+		return
+	}
+
+	var detached *Transaction
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subs {
+		if sub.match != nil && !sub.match(tx) {
+			continue
+		}
+		if detached == nil {
+			detached = detachTransaction(tx)
+		}
+		select {
+		case sub.C <- detached:
+		default:
+			streamDropped.WithLabelValues(sub.transport).Inc()
+		}
+	}
+}
+
+// txMatcher builds a client's filter from the chains it wants, none meaning
+// all, and an optional expression
+func txMatcher(chains []string, expression, use string) (func(*Transaction) bool, error) {
+	var expr *txExpr
+	if expression != "" {
+		var err error
+		if expr, err = compileExpr(expression); err != nil {
+			return nil, err
+		}
+	}
+	return func(tx *Transaction) bool {
+		if len(chains) > 0 && !containsString(chains, tx.Chain) {
+			return false
+		}
+		return expr == nil || expr.Matches(exprVars(tx), tx.Chain, use)
+	}, nil
+}
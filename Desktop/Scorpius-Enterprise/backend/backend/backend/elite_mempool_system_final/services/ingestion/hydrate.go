@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Subscription modes
+const (
+	SubscriptionFull   = "full"
+	SubscriptionHashes = "hashes"
+	// SubscriptionAlchemy streams full bodies through Alchemy's
+	// alchemy_pendingTransactions
+	SubscriptionAlchemy = "alchemy"
+	// SubscriptionAuto probes each endpoint for the richest of the above
+	SubscriptionAuto = "auto"
+)
+
+var hydrationResults = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_tx_hydration_total",
+		Help: "Results of hydrating hash-only pending transaction notifications",
+	},
+	[]string{"chain", "result"},
+)
+
+// hydrator fetches full transaction bodies for hash-only notifications
+type hydrator struct {
+	monitor *ChainMonitor
+	workers int
+	jobs    chan string
+	mu      sync.RWMutex
+	client  *rpcClient
+}
+
+func newHydrator(monitor *ChainMonitor) *hydrator {
+	workers := monitor.options.HydrationWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	return &hydrator{
+		monitor: monitor,
+		workers: workers,
+		jobs:    make(chan string, workers*256),
+	}
+}
+
+// start launches the worker pool; workers exit when the monitor context ends
+func (h *hydrator) start() {
+	for i := 0; i < h.workers; i++ {
+		go h.worker()
+	}
+}
+
+// setEndpoint points hydration at the HTTP endpoint paired with the active websocket
+func (h *hydrator) setEndpoint(wsEndpoint string) {
+	endpoint := h.monitor.options.HydrationURL
+	if endpoint == "" {
+		endpoint = httpURLFor(wsEndpoint)
+	}
+
+	h.mu.Lock()
+	h.client = newRPCClient(endpoint, 5*time.Second)
+	h.mu.Unlock()
+}
+
+// enqueue schedules a hash for hydration, dropping it if the queue is full
+func (h *hydrator) enqueue(hash string) {
+	select {
+	case h.jobs <- hash:
+	default:
+		hydrationResults.WithLabelValues(h.monitor.chainName, "dropped").Inc()
+	}
+}
+
+func (h *hydrator) worker() {
+	ctx := h.monitor.ctx
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case hash := <-h.jobs:
+			h.hydrate(ctx, hash)
+		}
+	}
+}
+
+// hydrate fetches a transaction by hash and feeds it through the normal pipeline
+func (h *hydrator) hydrate(ctx context.Context, hash string) {
+	h.mu.RLock()
+	client := h.client
+	h.mu.RUnlock()
+
+	if client == nil {
+		hydrationResults.WithLabelValues(h.monitor.chainName, "error").Inc()
+		return
+	}
+
+	var txData json.RawMessage
+	if err := client.Call(ctx, "eth_getTransactionByHash", []interface{}{hash}, &txData); err != nil {
+		hydrationResults.WithLabelValues(h.monitor.chainName, "error").Inc()
+		h.monitor.logger.Error("failed to hydrate transaction", "tx_hash", hash, "error", err)
+		return
+	}
+
+	// Already mined and pruned, or dropped from the node's pool
+	if isJSONNull(txData) {
+		hydrationResults.WithLabelValues(h.monitor.chainName, "not_found").Inc()
+		return
+	}
+
+	hydrationResults.WithLabelValues(h.monitor.chainName, "success").Inc()
+	if err := h.monitor.processPendingTransaction(txData); err != nil {
+		h.monitor.logger.Error("failed to process hydrated transaction", "tx_hash", hash, "error", err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// benchFrame is a full-body pending transaction notification as a node sends it
+var benchFrame = []byte(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"0x9ce59a13059e417087c02d3236a0b1cc","result":{"blockHash":null,"blockNumber":null,"from":"0x8a1e2c2bb4f3b3fd8ad59cf8e48c1bb0a3c1d7e5","gas":"0x5208","gasPrice":"0x4a817c800","maxFeePerGas":"0x4a817c800","maxPriorityFeePerGas":"0x3b9aca00","hash":"0x2b4f8e7d0a6c9e3f5d1b7a8c4e2f6d0b9a3c5e7f1d8b2a4c6e0f3d5b7a9c1e2f","input":"0xa9059cbb000000000000000000000000d8da6bf26964af9d7eed9e03e53415d37aa960450000000000000000000000000000000000000000000000000de0b6b3a7640000","nonce":"0x1b","to":"0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48","transactionIndex":null,"value":"0x0","type":"0x2","accessList":[],"chainId":"0x1","v":"0x1","r":"0x1f","s":"0x2e"}}}`)
+
+// countingSink stands in for Kafka, counting published messages
+type countingSink struct {
+	published atomic.Int64
+}
+
+func (s *countingSink) Name() string { return "counting" }
+
+func (s *countingSink) Publish(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
+	s.published.Add(1)
+	return nil
+}
+
+func (s *countingSink) Close() {}
+
+// newBenchMonitor returns a monitor delivering to sink with one publish worker
+func newBenchMonitor(b *testing.B, sink Sink) *ChainMonitor {
+	b.Helper()
+	options := ChainOptions{
+		QueueCapacity:    4096,
+		QueuePolicy:      QueuePark,
+		QueueParkTimeout: time.Minute,
+		Workers:          1,
+		EndpointStrategy: StrategyBest,
+	}
+	cm := NewChainMonitor("ethereum", 1, nil, options, sink, noopCache{}, nil)
+	cm.hub = newTxHub()
+	cm.queue.start(cm.deliverTransaction, options.Workers)
+	b.Cleanup(func() { cm.Drain(DrainShutdown) })
+	return cm
+}
+
+// The ingest path allocates under twice per transaction: envelopes, encoder
+// output, headers and produce messages are pooled, decoded strings share the
+// frame and spans are skipped while tracing is off. TestIngestAllocs holds
+// it to that; these benchmarks track the cost.
+
+func BenchmarkDecodeFrame(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchFrame)))
+	for i := 0; i < b.N; i++ {
+		var msg rpcMessage
+		if err := decodeWire(benchFrame, &msg); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := (&ChainMonitor{chainName: "ethereum", chainID: 1}).decodeTransaction(msg.Params.Result); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkIngest covers the ingest path end to end: frame decode, queue,
+// delivery and produce to a sink
+func BenchmarkIngest(b *testing.B) {
+	sink := &countingSink{}
+	cm := newBenchMonitor(b, sink)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchFrame)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var msg rpcMessage
+		if err := decodeWire(benchFrame, &msg); err != nil {
+			b.Fatal(err)
+		}
+		if err := cm.handleMessage(&msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+	for sink.published.Load() < int64(b.N) {
+		time.Sleep(time.Millisecond)
+	}
+	b.StopTimer()
+}
+
+// TestIngestAllocs measures a transaction from its received frame to the
+// sink. Reading the frame is left out: each one is a fresh buffer that the
+// decoded strings then share, as with a websocket connection.
+func TestIngestAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts are not meaningful under the race detector")
+	}
+	sink := &countingSink{}
+	options := ChainOptions{QueueCapacity: 16, QueuePolicy: QueueDrop, EndpointStrategy: StrategyBest}
+	cm := NewChainMonitor("ethereum", 1, nil, options, sink, noopCache{}, nil)
+	cm.hub = newTxHub()
+	t.Cleanup(func() { cm.Drain(DrainShutdown) })
+
+	frames := make([][]byte, 200)
+	for i := range frames {
+		frames[i] = append([]byte(nil), benchFrame...)
+	}
+	var msg rpcMessage
+	next := 0
+	allocs := testing.AllocsPerRun(len(frames)-1, func() {
+		msg = rpcMessage{}
+		if err := decodeWire(frames[next], &msg); err != nil {
+			t.Fatal(err)
+		}
+		next++
+		if err := cm.handleMessage(&msg); err != nil {
+			t.Fatal(err)
+		}
+		// Deliver on this goroutine, as a publish worker would
+		tx := <-cm.queue.items
+		if err := cm.deliverTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+		recycleTransaction(tx)
+	})
+	t.Logf("%v allocs", allocs)
+	if got := sink.published.Load(); got != int64(len(frames)) {
+		t.Fatalf("published %d transactions, want %d", got, len(frames))
+	}
+	if allocs >= 2 {
+		t.Errorf("ingest path allocates %v times per transaction, want fewer than 2", allocs)
+	}
+}
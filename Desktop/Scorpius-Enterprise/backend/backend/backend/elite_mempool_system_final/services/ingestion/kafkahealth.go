@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	kafkaHealthy = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_kafka_healthy",
+			Help: "Whether the latest Kafka broker health check passed (1) or failed (0)",
+		},
+		[]string{"cluster"},
+	)
+
+	kafkaBrokerUp = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_kafka_broker_up",
+			Help: "Whether the producer's connection to a Kafka broker is up, from librdkafka statistics",
+		},
+		[]string{"cluster", "broker"},
+	)
+
+	kafkaQueueDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_kafka_producer_queue_depth",
+			Help: "Messages and delivery reports waiting in the Kafka producer's queues",
+		},
+		[]string{"cluster"},
+	)
+
+	kafkaProducerRecreations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_kafka_producer_recreations_total",
+			Help: "Kafka producers replaced after a fatal error by outcome (recreated, failed)",
+		},
+		[]string{"cluster", "result"},
+	)
+)
+
+// errKafkaUnchecked is the sink's health until the first check completes
+var errKafkaUnchecked = errors.New("kafka health not checked yet")
+
+// supervise checks broker health every healthInterval and replaces the
+// producer after a fatal error, retrying on each tick if that fails, until
+// the sink closes
+func (s *KafkaSink) supervise() {
+	defer close(s.supervised)
+
+	ticker := time.NewTicker(s.healthInterval)
+	defer ticker.Stop()
+
+	var failed error
+	s.checkHealth()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case err := <-s.fatal:
+			failed = err
+			s.setHealth(fmt.Errorf("kafka producer failed: %v", err))
+		case <-ticker.C:
+			if failed == nil {
+				s.checkHealth()
+			}
+		}
+
+		if failed != nil && s.recreate(failed) == nil {
+			failed = nil
+			s.checkHealth()
+		}
+	}
+}
+
+// checkHealth samples the producer's queue depth and fetches cluster metadata
+// to confirm a broker is reachable
+func (s *KafkaSink) checkHealth() {
+	s.producerMu.RLock()
+	defer s.producerMu.RUnlock()
+
+	kafkaQueueDepth.WithLabelValues(s.cluster).Set(float64(s.producer.Len()))
+
+	metadata, err := s.producer.GetMetadata(nil, false, int(s.healthTimeout.Milliseconds()))
+	if err != nil {
+		err = fmt.Errorf("kafka unreachable: %v", err)
+	} else if len(metadata.Brokers) == 0 {
+		err = fmt.Errorf("kafka unreachable: no brokers in cluster metadata")
+	}
+
+	previous := s.setHealth(err)
+	switch {
+	case err != nil && (previous == nil || previous == errKafkaUnchecked):
+		slog.Warn("kafka brokers unavailable", "cluster", s.cluster, "error", err)
+	case err == nil && previous != nil:
+		slog.Info("kafka brokers available", "cluster", s.cluster, "brokers", len(metadata.Brokers))
+	}
+}
+
+// setHealth records the sink's health and returns the previous state
+func (s *KafkaSink) setHealth(err error) error {
+	if err != nil {
+		kafkaHealthy.WithLabelValues(s.cluster).Set(0)
+	} else {
+		kafkaHealthy.WithLabelValues(s.cluster).Set(1)
+	}
+
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	previous := s.healthErr
+	s.healthErr = err
+	return previous
+}
+
+// isCurrent reports whether producer is the one publishing, as opposed to one
+// being replaced
+func (s *KafkaSink) isCurrent(producer *kafka.Producer) bool {
+	s.producerMu.RLock()
+	defer s.producerMu.RUnlock()
+	return s.producer == producer
+}
+
+// recreate replaces the producer after a fatal error. Messages still queued
+// on the failed producer are purged, which fails their deliveries, so they
+// take the usual retry path onto the replacement.
+func (s *KafkaSink) recreate(cause error) error {
+	producer, err := kafka.NewProducer(s.producerConfig)
+	if err != nil {
+		kafkaProducerRecreations.WithLabelValues(s.cluster, "failed").Inc()
+		slog.Error("failed to recreate Kafka producer", "cluster", s.cluster, "error", err)
+		return err
+	}
+
+	s.producerMu.Lock()
+	failed := s.producer
+	s.producer = producer
+	s.producerMu.Unlock()
+
+	s.reports.Add(1)
+	go s.handleDeliveryReports(producer)
+
+	queued := failed.Len()
+	if err := failed.Purge(kafka.PurgeQueue | kafka.PurgeInFlight); err != nil {
+		slog.Warn("failed to purge failed Kafka producer", "cluster", s.cluster, "error", err)
+	}
+	// Wait for the purged deliveries to be reported and retried
+	failed.Flush(5 * 1000)
+	failed.Close()
+
+	kafkaProducerRecreations.WithLabelValues(s.cluster, "recreated").Inc()
+	slog.Warn("recreated Kafka producer after fatal error", "cluster", s.cluster, "cause", cause, "requeued", queued)
+	return nil
+}
+
+// kafkaStats is the part of librdkafka's statistics the sink reports
+type kafkaStats struct {
+	Brokers map[string]struct {
+		NodeID int    `json:"nodeid"`
+		State  string `json:"state"`
+	} `json:"brokers"`
+}
+
+// recordStats updates the broker connection gauges from a statistics event
+func (s *KafkaSink) recordStats(data string) {
+	var stats kafkaStats
+	if err := json.Unmarshal([]byte(data), &stats); err != nil {
+		slog.Debug("failed to parse Kafka statistics", "cluster", s.cluster, "error", err)
+		return
+	}
+	for name, broker := range stats.Brokers {
+		// Bootstrap entries have no node ID and are replaced by the real brokers
+		if broker.NodeID < 0 {
+			continue
+		}
+		up := 0.0
+		if broker.State == "UP" {
+			up = 1
+		}
+		kafkaBrokerUp.WithLabelValues(s.cluster, name).Set(up)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// kafkaPrimary names the KAFKA_BROKERS cluster in metrics and logs
+const kafkaPrimary = "primary"
+
+var kafkaMirrorErrors = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_kafka_mirror_errors_total",
+		Help: "Messages a mirror Kafka cluster rejected; the primary and other mirrors are unaffected",
+	},
+	[]string{"cluster"},
+)
+
+// parseKafkaMirrors parses KAFKA_MIRRORS, name=brokers entries separated by
+// semicolons because broker lists contain commas: "dr=dr-1:9092,dr-2:9092"
+func parseKafkaMirrors(value string) map[string]string {
+	mirrors := make(map[string]string)
+	for _, item := range strings.Split(value, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		name, brokers, ok := strings.Cut(item, "=")
+		if !ok {
+			slog.Warn("ignoring malformed Kafka mirror, expected name=brokers", "entry", item)
+			continue
+		}
+		mirrors[strings.TrimSpace(name)] = strings.TrimSpace(brokers)
+	}
+	return mirrors
+}
+
+// newKafkaSinks creates the Kafka sink for the primary cluster and, with
+// mirrors configured, wraps it with one sink per mirror
+func newKafkaSinks(config Config) (Sink, error) {
+	primary, err := newKafkaClusterSink(config, kafkaPrimary, config.KafkaBrokers)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.KafkaMirrors) == 0 {
+		return primary, nil
+	}
+
+	names := make([]string, 0, len(config.KafkaMirrors))
+	for name := range config.KafkaMirrors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	mirror := &kafkaMirrorSink{primary: primary}
+	for _, name := range names {
+		sink, err := newKafkaClusterSink(config, name, config.KafkaMirrors[name])
+		if err != nil {
+			mirror.Close()
+			return nil, err
+		}
+		mirror.mirrors = append(mirror.mirrors, kafkaMirror{cluster: name, sink: sink})
+		slog.Info("mirroring to Kafka cluster", "cluster", name, "brokers", config.KafkaMirrors[name])
+	}
+	return mirror, nil
+}
+
+// newKafkaClusterSink creates one cluster's sink, spooled to its own file
+// when the disk spool is enabled
+func newKafkaClusterSink(config Config, cluster, brokers string) (Sink, error) {
+	sink, err := NewKafkaSink(config, cluster, brokers)
+	if err != nil {
+		return nil, err
+	}
+	if !config.Spool.Enabled() {
+		return sink, nil
+	}
+
+	// The primary keeps the spool file name used before mirroring existed
+	name := SinkKafka
+	if cluster != kafkaPrimary {
+		name = SinkKafka + "-" + cluster
+	}
+	spool, err := newSpoolSink(name, sink, config.Spool, config.Drain.Timeout)
+	if err != nil {
+		sink.Close()
+		return nil, err
+	}
+	return spool, nil
+}
+
+// kafkaMirror is one mirror cluster's sink
+type kafkaMirror struct {
+	cluster string
+	sink    Sink
+}
+
+// kafkaMirrorSink publishes every message to the primary cluster and to each
+// mirror. Each cluster has its own producer, health checks, retries,
+// dead-letter topic and spool, so an outage in one region does not hold up
+// the others. Only the primary's errors are returned to the caller; mirror
+// errors are counted and logged. Exactly-once chains commit through their
+// transactional producer on the primary only.
+type kafkaMirrorSink struct {
+	primary Sink
+	mirrors []kafkaMirror
+}
+
+// Name returns the sink name
+func (s *kafkaMirrorSink) Name() string {
+	return SinkKafka
+}
+
+// Ping reports the primary cluster's health; mirror health is in the
+// scorpius_kafka_healthy metric so a mirror outage does not fail readiness
+func (s *kafkaMirrorSink) Ping(ctx context.Context) error {
+	if p, ok := s.primary.(pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// Publish sends the message to the primary and every mirror
+func (s *kafkaMirrorSink) Publish(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
+	err := s.primary.Publish(ctx, topic, key, value, headers)
+	for _, mirror := range s.mirrors {
+		if mirrorErr := mirror.sink.Publish(ctx, topic, key, value, headers); mirrorErr != nil {
+			kafkaMirrorErrors.WithLabelValues(mirror.cluster).Inc()
+			slog.Debug("mirror Kafka cluster rejected message", "cluster", mirror.cluster, "topic", topic, "error", mirrorErr)
+		}
+	}
+	return err
+}
+
+// Close flushes and closes every cluster's sink in parallel
+func (s *kafkaMirrorSink) Close() {
+	var wg sync.WaitGroup
+	closeSink := func(sink Sink) {
+		defer wg.Done()
+		sink.Close()
+	}
+
+	wg.Add(1 + len(s.mirrors))
+	go closeSink(s.primary)
+	for _, mirror := range s.mirrors {
+		go closeSink(mirror.sink)
+	}
+	wg.Wait()
+}
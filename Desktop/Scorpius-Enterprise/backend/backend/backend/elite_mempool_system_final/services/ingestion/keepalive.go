@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var staleConnections = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_ws_stale_connections_total",
+		Help: "Websocket connections torn down after no data or pong within the read timeout",
+	},
+	[]string{"chain", "endpoint"},
+)
+
+// keepalive pings conn on the configured interval and arms a read deadline
+// that every pong and every received frame pushes forward, so a connection
+// that dies silently is torn down within the read timeout. The returned
+// function stops the pinger.
+func (cm *ChainMonitor) keepalive(conn *websocket.Conn) func() {
+	timeout := cm.options.ReadTimeout
+	if timeout <= 0 {
+		return func() {}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(timeout))
+	})
+
+	interval := cm.options.PingInterval
+	if interval <= 0 || interval >= timeout {
+		interval = timeout / 2
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-cm.ctx.Done():
+				return
+			case <-ticker.C:
+				// WriteControl is safe alongside the reader and other writers
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(interval)); err != nil {
+					cm.logger.Warn("failed to ping endpoint", "remote", conn.RemoteAddr().String(), "error", err)
+					return
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// extendReadDeadline pushes the read deadline forward after a frame arrives
+func (cm *ChainMonitor) extendReadDeadline(conn *websocket.Conn) {
+	if cm.options.ReadTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(cm.options.ReadTimeout))
+	}
+}
+
+// isReadTimeout reports whether err came from an expired read deadline
+func isReadTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// SinkKinesis publishes to Amazon Kinesis Data Streams
+const SinkKinesis = "kinesis"
+
+// kinesisMaxBatch is the PutRecords per-request record limit
+const kinesisMaxBatch = 500
+
+var kinesisRecords = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_kinesis_records_total",
+		Help: "Records written to Kinesis by outcome",
+	},
+	[]string{"stream", "result"},
+)
+
+// KinesisConfig configures the Kinesis sink
+type KinesisConfig struct {
+	Region       string
+	StreamPrefix string
+	BatchSize    int
+	BatchWindow  time.Duration
+	MaxRetries   int
+}
+
+// loadKinesisConfig reads KINESIS_* settings; the region falls back to the AWS SDK defaults
+func loadKinesisConfig() KinesisConfig {
+	return KinesisConfig{
+		Region:       getEnv("KINESIS_REGION"),
+		StreamPrefix: getEnv("KINESIS_STREAM_PREFIX"),
+		BatchSize:    getEnvInt("KINESIS_BATCH_SIZE", kinesisMaxBatch),
+		BatchWindow:  getEnvDuration("KINESIS_BATCH_WINDOW", 100*time.Millisecond),
+		MaxRetries:   getEnvInt("KINESIS_MAX_RETRIES", 5),
+	}
+}
+
+// kinesisRecord is a queued record and the stream it belongs to
+type kinesisRecord struct {
+	stream string
+	entry  types.PutRecordsRequestEntry
+}
+
+// KinesisSink writes each topic to the stream <prefix><topic>, batching
+// records with PutRecords and retrying throttled entries. Kinesis has no
+// message headers, so only the key (as partition key) and value are kept.
+type KinesisSink struct {
+	client *kinesis.Client
+	config KinesisConfig
+	queue  chan kinesisRecord
+	done   chan struct{}
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewKinesisSink creates a Kinesis client from the default AWS credential chain
+func NewKinesisSink(config KinesisConfig) (*KinesisSink, error) {
+	if config.BatchSize <= 0 || config.BatchSize > kinesisMaxBatch {
+		config.BatchSize = kinesisMaxBatch
+	}
+	if config.BatchWindow <= 0 {
+		config.BatchWindow = 100 * time.Millisecond
+	}
+
+	var opts []func(*awsconfig.LoadOptions) error
+	if config.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(config.Region))
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %v", err)
+	}
+
+	s := &KinesisSink{
+		client: kinesis.NewFromConfig(awsCfg),
+		config: config,
+		queue:  make(chan kinesisRecord, config.BatchSize*4),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Name returns the sink name
+func (s *KinesisSink) Name() string {
+	return SinkKinesis
+}
+
+// Publish queues a record for the next batch
+func (s *KinesisSink) Publish(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return fmt.Errorf("kinesis sink is closed")
+	}
+
+	// Records are sent after Publish returns, by when value may be reused
+	record := kinesisRecord{
+		stream: s.config.StreamPrefix + topic,
+		entry: types.PutRecordsRequestEntry{
+			Data:         bytes.Clone(value),
+			PartitionKey: aws.String(string(key)),
+		},
+	}
+
+	select {
+	case s.queue <- record:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run groups queued records per stream and flushes them by size or window
+func (s *KinesisSink) run() {
+	defer close(s.done)
+
+	batches := make(map[string][]types.PutRecordsRequestEntry)
+	ticker := time.NewTicker(s.config.BatchWindow)
+	defer ticker.Stop()
+
+	flushAll := func() {
+		for stream, batch := range batches {
+			if len(batch) > 0 {
+				s.flush(stream, batch)
+			}
+			delete(batches, stream)
+		}
+	}
+
+	for {
+		select {
+		case record, ok := <-s.queue:
+			if !ok {
+				flushAll()
+				return
+			}
+			batch := append(batches[record.stream], record.entry)
+			if len(batch) >= s.config.BatchSize {
+				s.flush(record.stream, batch)
+				batch = nil
+			}
+			batches[record.stream] = batch
+		case <-ticker.C:
+			flushAll()
+		}
+	}
+}
+
+// flush writes a batch, resubmitting individually failed entries with backoff
+func (s *KinesisSink) flush(stream string, batch []types.PutRecordsRequestEntry) {
+	backoff := 100 * time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		out, err := s.client.PutRecords(ctx, &kinesis.PutRecordsInput{
+			StreamName: aws.String(stream),
+			Records:    batch,
+		})
+		cancel()
+
+		if err == nil {
+			var failed []types.PutRecordsRequestEntry
+			for i, result := range out.Records {
+				if result.ErrorCode != nil {
+					failed = append(failed, batch[i])
+				}
+			}
+			kinesisRecords.WithLabelValues(stream, "success").Add(float64(len(batch) - len(failed)))
+			if len(failed) == 0 {
+				return
+			}
+			batch = failed
+		}
+
+		if attempt >= s.config.MaxRetries {
+			kinesisRecords.WithLabelValues(stream, "failed").Add(float64(len(batch)))
+			slog.Error("failed to write records to Kinesis", "stream", stream, "count", len(batch), "attempts", attempt+1, "error", err)
+			return
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Close flushes queued records
+func (s *KinesisSink) Close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	<-s.done
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+)
+
+// Address label categories. Label files and the sync API may use others.
+const (
+	LabelExchange = "exchange"
+	LabelBridge   = "bridge"
+	LabelMEVBot   = "mev_bot"
+	LabelMixer    = "mixer"
+)
+
+// Redis keys of the labels synced from the label API
+const (
+	labelSyncKey   = "labels:synced"
+	labelSyncAtKey = "labels:synced_at"
+)
+
+// labelSyncSource names labels read from the label API
+const labelSyncSource = "api"
+
+var (
+	addressLabelHits = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_address_labels_total",
+			Help: "Senders and recipients annotated with a label, by side and category",
+		},
+		[]string{"chain", "side", "category"},
+	)
+
+	addressLabelsLoaded = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_address_labels_loaded",
+			Help: "Address labels held in memory, by source kind",
+		},
+		[]string{"source"},
+	)
+)
+
+// LabelConfig configures address labeling
+type LabelConfig struct {
+	Files        []string
+	SyncURL      string
+	SyncKey      string
+	SyncInterval time.Duration
+	SyncTimeout  time.Duration
+	Refresh      time.Duration
+}
+
+// loadLabelConfig reads LABEL_* settings
+func loadLabelConfig() LabelConfig {
+	return LabelConfig{
+		Files:        splitNonEmpty(getEnv("LABEL_FILES")),
+		SyncURL:      getEnv("LABEL_SYNC_URL"),
+		SyncKey:      getEnv("LABEL_SYNC_KEY"),
+		SyncInterval: getEnvDuration("LABEL_SYNC_INTERVAL", time.Hour),
+		SyncTimeout:  getEnvDuration("LABEL_SYNC_TIMEOUT", time.Minute),
+		Refresh:      getEnvDuration("LABEL_REFRESH", time.Minute),
+	}
+}
+
+// Enabled reports whether any label source is configured
+func (c LabelConfig) Enabled() bool {
+	return len(c.Files) > 0 || c.SyncURL != ""
+}
+
+// AddressLabel names the owner of an address
+type AddressLabel struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	// Source is the label file, or "api" for synced labels
+	Source string `json:"source"`
+}
+
+// AddressLabeler annotates senders and recipients with labels from local
+// files and, optionally, a label API.
+//
+// Label files hold "<address> <category> <name>" lines (# comments), the
+// name running to the end of the line. The label API is fetched every
+// LABEL_SYNC_INTERVAL and must return a JSON array of
+// {"address", "category", "name"} objects; the result is stored in Redis,
+// where every instance reads it, so the API is called once per interval
+// across the deployment and a failed sync keeps serving the previous copy.
+// File labels take precedence over synced ones. Without Redis, each
+// instance calls the label API itself and holds the result in memory.
+type AddressLabeler struct {
+	config LabelConfig
+	redis  *redis.Client
+	client *http.Client
+
+	mu     sync.RWMutex
+	labels map[string]AddressLabel
+
+	// synced and syncedAt hold the synced labels when there is no Redis
+	synced   map[string]string
+	syncedAt time.Time
+}
+
+// NewAddressLabeler loads the label files; call Run to load synced labels
+func NewAddressLabeler(redisClient *redis.Client, config LabelConfig) (*AddressLabeler, error) {
+	if config.SyncURL != "" {
+		registerSecrets(config.SyncURL)
+	}
+	l := &AddressLabeler{
+		config: config,
+		redis:  redisClient,
+		client: &http.Client{Timeout: config.SyncTimeout},
+	}
+	labels, err := l.loadFiles()
+	if err != nil {
+		return nil, err
+	}
+	l.labels = labels
+	addressLabelsLoaded.WithLabelValues("file").Set(float64(len(labels)))
+	return l, nil
+}
+
+// loadFiles reads every label file into a fresh address index
+func (l *AddressLabeler) loadFiles() (map[string]AddressLabel, error) {
+	labels := make(map[string]AddressLabel)
+	for _, path := range l.config.Files {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open address labels: %v", err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line, _, _ := strings.Cut(scanner.Text(), "#")
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			label := AddressLabel{Category: strings.ToLower(fields[1]), Source: path}
+			if len(fields) > 2 {
+				label.Name = strings.Join(fields[2:], " ")
+			}
+			labels[normalizeAddress(fields[0])] = label
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read address labels %s: %v", path, err)
+		}
+	}
+	return labels, nil
+}
+
+// Run reloads the files and synced labels every refresh interval until ctx is cancelled
+func (l *AddressLabeler) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.config.Refresh)
+	defer ticker.Stop()
+
+	for {
+		l.refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh rebuilds the label index. A source that fails to load keeps its
+// previous labels.
+func (l *AddressLabeler) refresh(ctx context.Context) {
+	l.mu.RLock()
+	previous := l.labels
+	l.mu.RUnlock()
+
+	keep := func(labels map[string]AddressLabel, fromFiles bool) {
+		for address, label := range previous {
+			if (label.Source != labelSyncSource) == fromFiles {
+				labels[address] = label
+			}
+		}
+	}
+
+	labels := make(map[string]AddressLabel)
+	if l.config.SyncURL != "" {
+		if err := l.sync(ctx); err != nil {
+			slog.Warn("failed to sync address labels", "endpoint", displayEndpoint(l.config.SyncURL), "error", err)
+		}
+		synced, err := l.loadSynced(ctx)
+		if err != nil {
+			slog.Warn("failed to load synced address labels", "error", err)
+			keep(labels, false)
+		}
+		for address, value := range synced {
+			var label AddressLabel
+			if err := json.Unmarshal([]byte(value), &label); err != nil {
+				continue
+			}
+			labels[address] = label
+		}
+	}
+	addressLabelsLoaded.WithLabelValues(labelSyncSource).Set(float64(len(labels)))
+
+	files, err := l.loadFiles()
+	if err != nil {
+		slog.Warn("failed to reload address labels", "error", err)
+		files = make(map[string]AddressLabel)
+		keep(files, true)
+	}
+	for address, label := range files {
+		labels[address] = label
+	}
+	addressLabelsLoaded.WithLabelValues("file").Set(float64(len(files)))
+
+	l.mu.Lock()
+	l.labels = labels
+	l.mu.Unlock()
+}
+
+// loadSynced returns the synced labels as JSON by address
+func (l *AddressLabeler) loadSynced(ctx context.Context) (map[string]string, error) {
+	if l.redis == nil {
+		return l.synced, nil
+	}
+	return l.redis.HGetAll(ctx, labelSyncKey).Result()
+}
+
+// sync replaces the synced labels in Redis with the label API's list, once
+// LABEL_SYNC_INTERVAL has passed since any instance last synced
+func (l *AddressLabeler) sync(ctx context.Context) error {
+	if l.redis == nil {
+		if time.Since(l.syncedAt) < l.config.SyncInterval {
+			return nil
+		}
+		values, err := l.fetchLabels(ctx)
+		if err != nil {
+			return err
+		}
+		l.synced = make(map[string]string, len(values))
+		for address, data := range values {
+			l.synced[address] = string(data)
+		}
+		l.syncedAt = time.Now()
+		slog.Info("synced address labels", "labels", len(values))
+		return nil
+	}
+
+	last, err := l.redis.Get(ctx, labelSyncAtKey).Int64()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	if time.Since(time.Unix(last, 0)) < l.config.SyncInterval {
+		return nil
+	}
+	// Claim the sync so other instances wait for the next interval
+	claimed, err := l.redis.SetNX(ctx, labelSyncAtKey+":lock", "1", l.config.SyncTimeout).Result()
+	if err != nil || !claimed {
+		return err
+	}
+
+	values, err := l.fetchLabels(ctx)
+	if err != nil {
+		return err
+	}
+	fields := make(map[string]interface{}, len(values))
+	for address, data := range values {
+		fields[address] = data
+	}
+
+	pipe := l.redis.TxPipeline()
+	pipe.Del(ctx, labelSyncKey)
+	pipe.HSet(ctx, labelSyncKey, fields)
+	pipe.Set(ctx, labelSyncAtKey, strconv.FormatInt(time.Now().Unix(), 10), 0)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	slog.Info("synced address labels", "labels", len(values))
+	return nil
+}
+
+// fetchLabels downloads the label API's list as JSON labels by address
+func (l *AddressLabeler) fetchLabels(ctx context.Context) (map[string][]byte, error) {
+	entries, err := l.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string][]byte, len(entries))
+	for _, entry := range entries {
+		if entry.Address == "" || entry.Category == "" {
+			continue
+		}
+		data, err := json.Marshal(AddressLabel{Name: entry.Name, Category: strings.ToLower(entry.Category), Source: labelSyncSource})
+		if err != nil {
+			continue
+		}
+		values[normalizeAddress(entry.Address)] = data
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("label API returned no labels")
+	}
+	return values, nil
+}
+
+// labelEntry is one label as the label API returns it
+type labelEntry struct {
+	Address  string `json:"address"`
+	Category string `json:"category"`
+	Name     string `json:"name"`
+}
+
+// fetch downloads the label API's list
+func (l *AddressLabeler) fetch(ctx context.Context) ([]labelEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.config.SyncURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid label API endpoint")
+	}
+	req.Header.Set("Accept", "application/json")
+	if l.config.SyncKey != "" {
+		req.Header.Set("X-API-Key", l.config.SyncKey)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var entries []labelEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	return entries, nil
+}
+
+// Lookup returns the label of an address
+func (l *AddressLabeler) Lookup(address string) (AddressLabel, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	label, ok := l.labels[normalizeAddress(address)]
+	return label, ok
+}
+
+// Name returns the enricher name
+func (l *AddressLabeler) Name() string {
+	return "labels"
+}
+
+// Enrich annotates the sender and recipient with their labels
+func (l *AddressLabeler) Enrich(tx *Transaction) {
+	if tx.Canary {
+		return
+	}
+	if label, ok := l.Lookup(tx.From); ok && tx.From != "" {
+		tx.FromLabel = &label
+		addressLabelHits.WithLabelValues(tx.Chain, "from", label.Category).Inc()
+	}
+	if label, ok := l.Lookup(tx.To); ok && tx.To != "" {
+		tx.ToLabel = &label
+		addressLabelHits.WithLabelValues(tx.Chain, "to", label.Category).Inc()
+	}
+}
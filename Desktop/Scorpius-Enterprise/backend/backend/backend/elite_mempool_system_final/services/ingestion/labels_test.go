@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	labeledExchange = "0x28c6c06298d514db089934071355e5743bf21d60"
+	labeledBuilder  = "0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5"
+)
+
+// writeLabelFile writes a label file holding the exchange address
+func writeLabelFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "labels.txt")
+	if err := os.WriteFile(path, []byte("# exchanges\n"+labeledExchange+" Exchange Binance 14\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// labelAPI serves the builder label and counts requests
+func labelAPI(t *testing.T, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"address":"` + labeledBuilder + `","category":"MEV_BOT","name":"beaverbuild"},{"address":"","category":"exchange"}]`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAddressLabelerDryRunRefresh(t *testing.T) {
+	var calls atomic.Int32
+	server := labelAPI(t, &calls)
+	config := Config{
+		CacheBackend: CacheRedis,
+		Labels: LabelConfig{
+			Files:        []string{writeLabelFile(t)},
+			SyncURL:      server.URL,
+			SyncInterval: time.Hour,
+			SyncTimeout:  time.Second,
+			Refresh:      time.Minute,
+		},
+	}
+	config = dryRunConfig(config)
+
+	// A dry run has no Redis client
+	l, err := NewAddressLabeler(nil, config.Labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.refresh(context.Background())
+
+	if calls.Load() != 0 {
+		t.Errorf("label API called %d times during a dry run", calls.Load())
+	}
+	if label, ok := l.Lookup(labeledExchange); !ok || label.Category != LabelExchange {
+		t.Errorf("Lookup(exchange) = %+v, %v; want the file label", label, ok)
+	}
+}
+
+func TestAddressLabelerSyncWithoutRedis(t *testing.T) {
+	var calls atomic.Int32
+	server := labelAPI(t, &calls)
+	l, err := NewAddressLabeler(nil, LabelConfig{
+		Files:        []string{writeLabelFile(t)},
+		SyncURL:      server.URL,
+		SyncInterval: time.Hour,
+		SyncTimeout:  time.Second,
+		Refresh:      time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.refresh(context.Background())
+	l.refresh(context.Background())
+
+	if calls.Load() != 1 {
+		t.Errorf("label API called %d times, want once per sync interval", calls.Load())
+	}
+	label, ok := l.Lookup(labeledBuilder)
+	if !ok || label.Category != LabelMEVBot || label.Source != labelSyncSource {
+		t.Errorf("Lookup(builder) = %+v, %v; want the synced label", label, ok)
+	}
+	if label, ok := l.Lookup(labeledExchange); !ok || label.Name != "Binance 14" {
+		t.Errorf("Lookup(exchange) = %+v, %v; want the file label", label, ok)
+	}
+}
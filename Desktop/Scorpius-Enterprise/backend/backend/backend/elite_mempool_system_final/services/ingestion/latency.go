@@ -0,0 +1,117 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	ingestLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "scorpius_ingest_latency_seconds",
+			Help:    "Time from receiving a transaction from the node to handing it to the sink",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 16),
+		},
+		[]string{"chain"},
+	)
+
+	produceLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "scorpius_kafka_produce_latency_seconds",
+			Help:    "Time from Kafka produce to broker acknowledgement",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+		},
+		[]string{"chain"},
+	)
+
+	cacheLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "scorpius_redis_cache_latency_seconds",
+			Help:    "Time to write a transaction, or a batch of them, to the transaction cache",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 2, 14),
+		},
+		[]string{"chain"},
+	)
+
+	messageSize = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "scorpius_message_size_bytes",
+			Help:    "Size of encoded messages handed to the sink",
+			Buckets: prometheus.ExponentialBuckets(128, 2, 12),
+		},
+		[]string{"chain", "format"},
+	)
+
+	messagesPerSecondDesc = prometheus.NewDesc(
+		"scorpius_messages_per_second",
+		"Transactions delivered per second, averaged over the last 10 seconds",
+		[]string{"chain"}, nil,
+	)
+)
+
+// throughputCollector reports each monitor's delivery rate at scrape time, so
+// the gauge drops to zero when a chain goes quiet instead of going stale
+type throughputCollector struct {
+	service *IngestionService
+}
+
+// Describe implements prometheus.Collector
+func (c throughputCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- messagesPerSecondDesc
+}
+
+// Collect implements prometheus.Collector
+func (c throughputCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, status := range c.service.chainStatuses() {
+		ch <- prometheus.MustNewConstMetric(messagesPerSecondDesc, prometheus.GaugeValue, status.TxRate, status.Chain)
+	}
+}
+
+// receivedAt returns when tx was received, falling back to its second-resolution timestamp
+func receivedAt(tx *Transaction) time.Time {
+	if !tx.received.IsZero() {
+		return tx.received
+	}
+	return time.Unix(tx.Timestamp, 0)
+}
+
+// chainMetrics holds the children of a chain's per-transaction metrics.
+// Looking a child up by its label values allocates, so the publish path
+// resolves each one once.
+type chainMetrics struct {
+	chain         string
+	delivered     prometheus.Counter
+	ingestLatency prometheus.Observer
+	mu            sync.RWMutex
+	messageSizes  map[string]prometheus.Observer
+}
+
+func newChainMetrics(chain string) *chainMetrics {
+	return &chainMetrics{
+		chain:         chain,
+		delivered:     txIngested.WithLabelValues(chain, "success"),
+		ingestLatency: ingestLatency.WithLabelValues(chain),
+		messageSizes:  make(map[string]prometheus.Observer),
+	}
+}
+
+// messageSize returns the message size histogram of an encoding format
+func (m *chainMetrics) messageSize(format string) prometheus.Observer {
+	m.mu.RLock()
+	observer, ok := m.messageSizes[format]
+	m.mu.RUnlock()
+	if ok {
+		return observer
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if observer, ok = m.messageSizes[format]; !ok {
+		observer = messageSize.WithLabelValues(m.chain, format)
+		m.messageSizes[format] = observer
+	}
+	return observer
+}
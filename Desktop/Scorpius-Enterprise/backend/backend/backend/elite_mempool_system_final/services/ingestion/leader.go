@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+)
+
+// leaderEpochHeader carries the publishing leader's epoch. Epochs only grow,
+// so consumers can fence a deposed leader by dropping messages for a chain
+// whose epoch is below the highest they have seen.
+const leaderEpochHeader = "leader_epoch"
+
+var (
+	leaderStatus = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_leader",
+			Help: "Whether this instance is the publishing leader for a chain (1) or a standby (0)",
+		},
+		[]string{"chain"},
+	)
+
+	leaderEpoch = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_leader_epoch",
+			Help: "Epoch of this instance's current leadership of a chain",
+		},
+		[]string{"chain"},
+	)
+
+	leaderStandbySkipped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_leader_standby_skipped_total",
+			Help: "Messages not published because this instance is a standby for the chain",
+		},
+		[]string{"chain"},
+	)
+)
+
+// LeaderConfig configures active/standby leader election
+type LeaderConfig struct {
+	Enabled    bool
+	InstanceID string
+	LeaseTTL   time.Duration
+	Renew      time.Duration
+}
+
+// loadLeaderConfig reads LEADER_* settings
+func loadLeaderConfig() LeaderConfig {
+	return LeaderConfig{
+		Enabled:    getEnvBool("LEADER_ELECTION", false),
+		InstanceID: getEnvOrDefault("LEADER_INSTANCE_ID", instanceID()),
+		LeaseTTL:   getEnvDuration("LEADER_LEASE_TTL", 5*time.Second),
+		Renew:      getEnvDuration("LEADER_RENEW_INTERVAL", time.Second),
+	}
+}
+
+// leaderAcquireScript renews the lease if this instance holds it, or takes
+// it with a new epoch if nobody does. It returns the epoch held, or 0 when
+// another instance is the leader.
+var leaderAcquireScript = redis.NewScript(`
+local holder = redis.call('GET', KEYS[1])
+if holder == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return tonumber(redis.call('GET', KEYS[2]) or '0')
+end
+if holder then
+	return 0
+end
+local epoch = redis.call('INCR', KEYS[2])
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return epoch
+`)
+
+// leaderReleaseScript gives up the lease if this instance still holds it
+var leaderReleaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// leaderLease elects one instance to publish a chain with a Redis lease
+// (leader:<chain>) holding the leader's instance ID. Every instance keeps
+// ingesting so a standby is warm, but only the leader publishes.
+//
+// A leader stops publishing on its own when it cannot renew before its lease
+// would have expired, less a tenth of the TTL for clock drift, so a new
+// leader is only elected after the old one has stopped. Each election
+// increments leader_epoch:<chain>, which the leader sends in every message.
+type leaderLease struct {
+	chainName string
+	client    *redis.Client
+	config    LeaderConfig
+	logger    *slog.Logger
+	mu        sync.RWMutex
+	epoch     uint64
+	validTill time.Time
+}
+
+func newLeaderLease(chainName string, client *redis.Client, config LeaderConfig, logger *slog.Logger) *leaderLease {
+	leaderStatus.WithLabelValues(chainName).Set(0)
+	return &leaderLease{
+		chainName: chainName,
+		client:    client,
+		config:    config,
+		logger:    logger,
+	}
+}
+
+func (l *leaderLease) key() string {
+	return "leader:" + l.chainName
+}
+
+func (l *leaderLease) epochKey() string {
+	return "leader_epoch:" + l.chainName
+}
+
+// Epoch returns the epoch of this instance's leadership, or 0 on a standby
+func (l *leaderLease) Epoch() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if time.Now().After(l.validTill) {
+		return 0
+	}
+	return l.epoch
+}
+
+// standby reports whether another instance leads the chain, so this one
+// leaves publishing to it
+func (cm *ChainMonitor) standby() bool {
+	return cm.leader != nil && cm.leader.Epoch() == 0
+}
+
+// Run campaigns for the lease every renew interval until ctx is cancelled,
+// then releases it so a standby can take over without waiting for expiry
+func (l *leaderLease) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.config.Renew)
+	defer ticker.Stop()
+
+	for {
+		l.campaign(ctx)
+
+		select {
+		case <-ctx.Done():
+			l.release()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// campaign acquires or renews the lease
+func (l *leaderLease) campaign(ctx context.Context) {
+	start := time.Now()
+	result, err := leaderAcquireScript.Run(ctx, l.client, []string{l.key(), l.epochKey()}, l.config.InstanceID, l.config.LeaseTTL.Milliseconds()).Int64()
+	if err != nil {
+		// Leadership lapses at validTill unless a later renewal succeeds
+		if ctx.Err() == nil {
+			l.logger.Warn("failed to renew leader lease", "error", err)
+		}
+		return
+	}
+
+	l.mu.Lock()
+	previous := l.epoch
+	if time.Now().After(l.validTill) {
+		previous = 0
+	}
+	l.epoch = uint64(result)
+	if result > 0 {
+		// The lease was extended at or after start, so it outlives this
+		l.validTill = start.Add(l.config.LeaseTTL - l.config.LeaseTTL/10)
+	} else {
+		l.validTill = time.Time{}
+	}
+	l.mu.Unlock()
+
+	switch {
+	case result > 0 && uint64(result) != previous:
+		leaderStatus.WithLabelValues(l.chainName).Set(1)
+		leaderEpoch.WithLabelValues(l.chainName).Set(float64(result))
+		l.logger.Info("became publishing leader", "instance", l.config.InstanceID, "epoch", result)
+	case result == 0 && previous != 0:
+		leaderStatus.WithLabelValues(l.chainName).Set(0)
+		l.logger.Warn("lost publishing leadership", "instance", l.config.InstanceID, "epoch", previous)
+	case result == 0:
+		leaderStatus.WithLabelValues(l.chainName).Set(0)
+	}
+}
+
+// release gives up the lease on shutdown
+func (l *leaderLease) release() {
+	l.mu.Lock()
+	held := l.epoch != 0 && time.Now().Before(l.validTill)
+	l.epoch, l.validTill = 0, time.Time{}
+	l.mu.Unlock()
+
+	leaderStatus.WithLabelValues(l.chainName).Set(0)
+	if !held {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := leaderReleaseScript.Run(ctx, l.client, []string{l.key()}, l.config.InstanceID).Err(); err != nil {
+		l.logger.Warn("failed to release leader lease", "error", err)
+		return
+	}
+	l.logger.Info("released publishing leadership", "instance", l.config.InstanceID)
+}
+
+// fencedSink publishes a chain's messages only while its instance leads the
+// chain, stamping each with the leader's epoch
+type fencedSink struct {
+	sink  Sink
+	lease *leaderLease
+}
+
+// Name returns the wrapped sink's name
+func (s *fencedSink) Name() string {
+	return s.sink.Name()
+}
+
+// Ping reports the wrapped sink's health
+func (s *fencedSink) Ping(ctx context.Context) error {
+	if p, ok := s.sink.(pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// Publish sends the message with the epoch header, or drops it on a standby
+func (s *fencedSink) Publish(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
+	epoch := s.lease.Epoch()
+	if epoch == 0 {
+		leaderStandbySkipped.WithLabelValues(s.lease.chainName).Inc()
+		return nil
+	}
+
+	fenced := make(map[string]string, len(headers)+1)
+	for name, value := range headers {
+		fenced[name] = value
+	}
+	fenced[leaderEpochHeader] = strconv.FormatUint(epoch, 10)
+	return s.sink.Publish(ctx, topic, key, value, fenced)
+}
+
+// Close is a no-op: the wrapped sink is shared and closed by the service
+func (s *fencedSink) Close() {}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// publishedMessage is a message as a sink received it
+type publishedMessage struct {
+	topic   string
+	headers map[string]string
+}
+
+// headerSink records the topics and headers it is sent
+type headerSink struct {
+	mu       sync.Mutex
+	messages []publishedMessage
+}
+
+func (s *headerSink) Name() string { return "headers" }
+
+func (s *headerSink) Publish(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
+	s.mu.Lock()
+	s.messages = append(s.messages, publishedMessage{topic: topic, headers: headers})
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *headerSink) Close() {}
+
+func (s *headerSink) published() []publishedMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]publishedMessage(nil), s.messages...)
+}
+
+// testLease returns a lease held at epoch until validTill, without Redis
+func testLease(chain string, epoch uint64, validTill time.Time) *leaderLease {
+	lease := newLeaderLease(chain, nil, LeaderConfig{}, slog.Default())
+	lease.epoch, lease.validTill = epoch, validTill
+	return lease
+}
+
+func TestFencedSink(t *testing.T) {
+	sink := &headerSink{}
+	lease := testLease("ethereum", 7, time.Now().Add(time.Minute))
+	fenced := &fencedSink{sink: sink, lease: lease}
+
+	headers := map[string]string{"chain_name": "ethereum"}
+	if err := fenced.Publish(context.Background(), "tx_raw", nil, nil, headers); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := headers[leaderEpochHeader]; ok {
+		t.Error("fencedSink modified the caller's headers")
+	}
+
+	// A lease past its validity leaves publishing to the next leader
+	lease.mu.Lock()
+	lease.validTill = time.Now().Add(-time.Second)
+	lease.mu.Unlock()
+	if err := fenced.Publish(context.Background(), "tx_raw", nil, nil, headers); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := sink.published()
+	if len(messages) != 1 {
+		t.Fatalf("published %d messages, want 1 from the leader only", len(messages))
+	}
+	if got := messages[0].headers[leaderEpochHeader]; got != "7" {
+		t.Errorf("%s header = %q, want 7", leaderEpochHeader, got)
+	}
+	if got := messages[0].headers["chain_name"]; got != "ethereum" {
+		t.Errorf("chain_name header = %q, want the caller's headers kept", got)
+	}
+}
+
+func TestChainSinksFenceSideTopics(t *testing.T) {
+	service := &headerSink{}
+	chain := &headerSink{}
+	lease := testLease("ethereum", 3, time.Now().Add(time.Minute))
+	monitor := &ChainMonitor{chainName: "ethereum", leader: lease, sink: &fencedSink{sink: chain, lease: lease}}
+
+	sinks := newChainSinks(service, true)
+	sinks.add(monitor)
+	nonces := NewNonceTracker(sinks, NonceConfig{Topic: "replacements"})
+
+	event := ReplacementEvent{Chain: "ethereum", ChainID: 1, Kind: "speedup", Hash: "0x01"}
+	nonces.publish(event, FamilyEVM)
+	if sinks.standby("ethereum") {
+		t.Error("standby() = true for the chain's leader")
+	}
+
+	// Once deposed, the instance publishes nothing for the chain
+	lease.mu.Lock()
+	lease.epoch = 0
+	lease.mu.Unlock()
+	nonces.publish(event, FamilyEVM)
+	if !sinks.standby("ethereum") {
+		t.Error("standby() = false after losing the lease")
+	}
+
+	// Chains without a running monitor have no lease to publish under
+	nonces.publish(ReplacementEvent{Chain: "polygon", ChainID: 137, Kind: "speedup", Hash: "0x02"}, FamilyEVM)
+	if !sinks.standby("polygon") {
+		t.Error("standby() = false for a chain without a monitor")
+	}
+
+	if messages := service.published(); len(messages) != 0 {
+		t.Errorf("service sink received %d unfenced messages", len(messages))
+	}
+	messages := chain.published()
+	if len(messages) != 1 {
+		t.Fatalf("chain sink received %d messages, want 1 from the leader", len(messages))
+	}
+	if messages[0].topic != "replacements" || messages[0].headers[leaderEpochHeader] != "3" {
+		t.Errorf("published %+v, want the replacement topic with epoch 3", messages[0])
+	}
+}
+
+func TestMEVShareSourceFenced(t *testing.T) {
+	service := &headerSink{}
+	chain := &headerSink{}
+	lease := testLease("ethereum", 5, time.Now().Add(time.Minute))
+	monitor := &ChainMonitor{chainName: "ethereum", leader: lease, sink: &fencedSink{sink: chain, lease: lease}}
+
+	sinks := newChainSinks(service, true)
+	sinks.add(monitor)
+	source := NewMEVShareSource("http://localhost", "ethereum", sinks)
+
+	ctx := context.Background()
+	source.handleEvent(ctx, `{"hash":"0x01"}`)
+
+	// A standby reads the stream but leaves publishing to the leader
+	lease.mu.Lock()
+	lease.epoch = 0
+	lease.mu.Unlock()
+	source.handleEvent(ctx, `{"hash":"0x02"}`)
+
+	if messages := service.published(); len(messages) != 0 {
+		t.Errorf("service sink received %d unfenced messages", len(messages))
+	}
+	messages := chain.published()
+	if len(messages) != 1 {
+		t.Fatalf("chain sink received %d messages, want 1 from the leader", len(messages))
+	}
+	if messages[0].topic != mevShareTopic || messages[0].headers[leaderEpochHeader] != "5" {
+		t.Errorf("published %+v, want the %s topic with epoch 5", messages[0], mevShareTopic)
+	}
+}
+
+func TestChainSinksWithoutLeaderElection(t *testing.T) {
+	service := &headerSink{}
+	chain := &headerSink{}
+	monitor := &ChainMonitor{chainName: "ethereum", sink: chain}
+
+	sinks := newChainSinks(service, false)
+	sinks.add(monitor)
+	ctx := context.Background()
+	if err := sinks.Publish(ctx, "ethereum", "gas_oracle", nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := sinks.Publish(ctx, "polygon", "gas_oracle", nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	sinks.remove(monitor)
+	if err := sinks.Publish(ctx, "ethereum", "gas_oracle", nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(chain.published()); got != 1 {
+		t.Errorf("chain sink received %d messages, want 1", got)
+	}
+	if got := len(service.published()); got != 2 {
+		t.Errorf("service sink received %d messages, want 2 for chains without a monitor", got)
+	}
+	if sinks.standby("ethereum") || sinks.standby("polygon") {
+		t.Error("standby() = true without leader election")
+	}
+}
+
+func TestLeaderLeaseLapsesWithoutRenewal(t *testing.T) {
+	// Nothing listens on the port, so every renewal fails
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	defer client.Close()
+	lease := newLeaderLease("ethereum", client, LeaderConfig{InstanceID: "a", LeaseTTL: time.Second, Renew: 100 * time.Millisecond}, slog.Default())
+	lease.epoch, lease.validTill = 4, time.Now().Add(500*time.Millisecond)
+
+	lease.campaign(context.Background())
+	if got := lease.Epoch(); got != 4 {
+		t.Fatalf("Epoch() = %d after a failed renewal, want 4 until the lease lapses", got)
+	}
+	monitor := &ChainMonitor{chainName: "ethereum", leader: lease}
+	if monitor.standby() {
+		t.Error("standby() = true while the lease is valid")
+	}
+
+	time.Sleep(550 * time.Millisecond)
+	if got := lease.Epoch(); got != 0 {
+		t.Errorf("Epoch() = %d after the lease lapsed, want 0", got)
+	}
+	if !monitor.standby() {
+		t.Error("standby() = false after the lease lapsed")
+	}
+}
@@ -0,0 +1,592 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Liquidation triggers
+const (
+	LiquidationTriggerPending   = "pending_oracle"
+	LiquidationTriggerConfirmed = "price_update"
+)
+
+// Chainlink aggregator calls and events carrying new prices
+const (
+	// transmit(bytes32[3],bytes,bytes32[],bytes32[],bytes32) of OCR2 aggregators
+	transmitSelectorOCR2 = "b1dc65a4"
+	// transmit(bytes,bytes32[],bytes32[],bytes32) of OCR1 aggregators
+	transmitSelectorOCR1 = "c9807539"
+	// AnswerUpdated(int256 indexed current, uint256 indexed roundId, uint256 updatedAt)
+	answerUpdatedTopic = "0x0559884fd3a460db3073b7fc896cc77986f16e378210ded43186175bf646fc5f"
+)
+
+// subgraphPageSize is the most entities a subgraph returns per query
+const subgraphPageSize = 1000
+
+var (
+	liquidationCandidates = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_liquidation_candidates_total",
+			Help: "Positions flagged as liquidatable, by trigger and publish status",
+		},
+		[]string{"chain", "trigger", "status"},
+	)
+
+	lendingPositions = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_lending_positions",
+			Help: "Borrowing positions watched for liquidation",
+		},
+		[]string{"chain"},
+	)
+)
+
+// LiquidationConfig configures the liquidation detector
+type LiquidationConfig struct {
+	Enabled bool
+	Topic   string
+	// HealthFactor is the health factor below which a position is flagged
+	HealthFactor float64
+	Refresh      time.Duration
+	MaxPositions int
+}
+
+// loadLiquidationConfig reads LIQUIDATION_* settings
+func loadLiquidationConfig() LiquidationConfig {
+	return LiquidationConfig{
+		Enabled:      getEnvBool("LIQUIDATIONS", false),
+		Topic:        getEnvOrDefault("LIQUIDATIONS_TOPIC", "liquidations"),
+		HealthFactor: getEnvFloat("LIQUIDATION_HEALTH_FACTOR", 1.0),
+		Refresh:      getEnvDuration("LIQUIDATION_REFRESH_INTERVAL", time.Minute),
+		MaxPositions: getEnvInt("LIQUIDATION_MAX_POSITIONS", 5000),
+	}
+}
+
+// LiquidationTrigger is the price change that makes a position liquidatable.
+// Prices are raw oracle answers, in the protocol's base currency units.
+type LiquidationTrigger struct {
+	Kind          string `json:"kind"`
+	Feed          string `json:"feed"`
+	Asset         string `json:"asset"`
+	Price         string `json:"price"`
+	PreviousPrice string `json:"previous_price,omitempty"`
+	TxHash        string `json:"tx_hash"`
+	BlockNumber   uint64 `json:"block_number,omitempty"`
+}
+
+// LiquidationCandidate is a borrowing position whose health factor falls
+// below LIQUIDATION_HEALTH_FACTOR once the trigger's price applies. Values
+// are in the protocol's base currency units, as its oracle quotes them.
+type LiquidationCandidate struct {
+	Chain                string             `json:"chain"`
+	ChainID              int64              `json:"chain_id"`
+	Protocol             string             `json:"protocol"`
+	Borrower             string             `json:"borrower"`
+	HealthFactor         float64            `json:"health_factor"`
+	PreviousHealthFactor float64            `json:"previous_health_factor"`
+	CollateralValue      float64            `json:"collateral_value"`
+	DebtValue            float64            `json:"debt_value"`
+	CollateralAsset      string             `json:"collateral_asset"`
+	DebtAsset            string             `json:"debt_asset"`
+	Trigger              LiquidationTrigger `json:"trigger"`
+	DetectedAt           time.Time          `json:"detected_at"`
+}
+
+// lendingReserve is a borrower's balance in one reserve, in whole tokens
+type lendingReserve struct {
+	asset      string
+	collateral float64
+	debt       float64
+	// threshold is the liquidation threshold as a fraction, zero when the
+	// reserve does not count as the borrower's collateral
+	threshold float64
+}
+
+// lendingPosition is one borrower's reserves
+type lendingPosition struct {
+	borrower string
+	reserves []lendingReserve
+}
+
+// health returns a position's health factor under prices, with override
+// replacing the price of one asset; ok is false when a price is missing
+func (p *lendingPosition) health(prices map[string]float64, override string, overridePrice float64) (health float64, value positionValue, ok bool) {
+	var largestCollateral, largestDebt float64
+	for _, reserve := range p.reserves {
+		price, known := prices[reserve.asset]
+		if reserve.asset == override {
+			price, known = overridePrice, true
+		}
+		if !known {
+			if reserve.debt > 0 {
+				return 0, value, false
+			}
+			continue
+		}
+
+		if reserve.threshold > 0 && reserve.collateral > 0 {
+			worth := reserve.collateral * price
+			value.collateral += worth
+			value.adjusted += worth * reserve.threshold
+			if worth > largestCollateral {
+				largestCollateral, value.collateralAsset = worth, reserve.asset
+			}
+		}
+		if reserve.debt > 0 {
+			owed := reserve.debt * price
+			value.debt += owed
+			if owed > largestDebt {
+				largestDebt, value.debtAsset = owed, reserve.asset
+			}
+		}
+	}
+	if value.debt == 0 {
+		return math.Inf(1), value, true
+	}
+	return value.adjusted / value.debt, value, true
+}
+
+// positionValue totals a position's collateral and debt
+type positionValue struct {
+	collateral      float64
+	adjusted        float64
+	debt            float64
+	collateralAsset string
+	debtAsset       string
+}
+
+// LiquidationMonitor routes pending oracle transactions to the liquidation
+// detector of their chain. Each chain with <CHAIN>_LENDING_SUBGRAPH has a
+// detector (see liquidationDetector); <CHAIN>_PRICE_FEEDS maps the
+// Chainlink aggregators it follows to the reserve assets they price.
+type LiquidationMonitor struct {
+	config LiquidationConfig
+
+	mu        sync.RWMutex
+	detectors map[string]*liquidationDetector
+}
+
+// NewLiquidationMonitor creates a monitor for the chains registered with it
+func NewLiquidationMonitor(config LiquidationConfig) *LiquidationMonitor {
+	return &LiquidationMonitor{config: config, detectors: make(map[string]*liquidationDetector)}
+}
+
+// Name returns the enricher name
+func (m *LiquidationMonitor) Name() string {
+	return "liquidations"
+}
+
+// Enrich projects the price of pending transmissions to a followed aggregator
+func (m *LiquidationMonitor) Enrich(tx *Transaction) {
+	if tx.ChainFamily != FamilyEVM || tx.Canary || tx.Status != "pending" || tx.To == "" {
+		return
+	}
+	m.mu.RLock()
+	detector := m.detectors[tx.Chain]
+	m.mu.RUnlock()
+	if detector == nil {
+		return
+	}
+	feed := strings.ToLower(tx.To)
+	asset, ok := detector.feeds[feed]
+	if !ok {
+		return
+	}
+
+	price := decodeTransmit(common.FromHex(tx.Data))
+	if price == nil {
+		return
+	}
+	detector.evaluate(LiquidationTrigger{
+		Kind:   LiquidationTriggerPending,
+		Feed:   feed,
+		Asset:  asset,
+		Price:  price.String(),
+		TxHash: tx.Hash,
+	}, price)
+}
+
+// detector creates the liquidation detector of a chain, replacing any
+// left by an earlier monitor of the same chain
+func (m *LiquidationMonitor) detector(monitor *ChainMonitor) *liquidationDetector {
+	feeds := make(map[string]string, len(monitor.options.PriceFeeds))
+	for aggregator, asset := range monitor.options.PriceFeeds {
+		feeds[strings.ToLower(aggregator)] = strings.ToLower(asset)
+	}
+	registerSecrets(monitor.options.LendingSubgraph)
+
+	d := &liquidationDetector{
+		monitor:    monitor,
+		config:     m.config,
+		subgraph:   monitor.options.LendingSubgraph,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		feeds:      feeds,
+		feedPrices: make(map[string]float64),
+	}
+	m.mu.Lock()
+	m.detectors[monitor.chainName] = d
+	m.mu.Unlock()
+	return d
+}
+
+// liquidationDetector watches a chain's borrowing positions, read from an
+// Aave V3 style subgraph every LIQUIDATION_REFRESH_INTERVAL, and reprices
+// them as oracle prices move: pending Chainlink transmissions project the
+// price they will set, and AnswerUpdated events in confirmed blocks (with
+// BLOCK_TRACKING) set it. A position is published to the liquidations topic
+// when a new price takes its health factor from at or above the threshold to
+// below it. Prices seen on chain take precedence over the subgraph's.
+type liquidationDetector struct {
+	monitor  *ChainMonitor
+	config   LiquidationConfig
+	subgraph string
+	client   *http.Client
+	// feeds maps an aggregator to the reserve asset it prices
+	feeds map[string]string
+
+	mu         sync.Mutex
+	positions  []*lendingPosition
+	holders    map[string][]*lendingPosition
+	prices     map[string]float64
+	feedPrices map[string]float64
+}
+
+// startLiquidations watches lending positions when <CHAIN>_LENDING_SUBGRAPH is set
+func (cm *ChainMonitor) startLiquidations() {
+	if cm.liquidations != nil {
+		go cm.liquidations.run()
+	}
+}
+
+// run refreshes the positions until the monitor stops
+func (d *liquidationDetector) run() {
+	cm := d.monitor
+	for {
+		if err := d.refresh(cm.ctx); err != nil && cm.ctx.Err() == nil {
+			cm.logger.Warn("failed to read lending positions", "endpoint", displayEndpoint(d.subgraph), "error", err)
+		}
+		if !cm.sleep(d.config.Refresh) {
+			return
+		}
+	}
+}
+
+// subgraphUser is a borrower as the Aave V3 subgraph describes it
+type subgraphUser struct {
+	ID    string `json:"id"`
+	EMode *struct {
+		ID                   string `json:"id"`
+		LiquidationThreshold string `json:"liquidationThreshold"`
+	} `json:"eModeCategoryId"`
+	Reserves []struct {
+		ATokenBalance     string `json:"currentATokenBalance"`
+		TotalDebt         string `json:"currentTotalDebt"`
+		CollateralEnabled bool   `json:"usageAsCollateralEnabledOnUser"`
+		Reserve           struct {
+			UnderlyingAsset      string `json:"underlyingAsset"`
+			Decimals             int    `json:"decimals"`
+			LiquidationThreshold string `json:"reserveLiquidationThreshold"`
+			EMode                *struct {
+				ID string `json:"id"`
+			} `json:"eMode"`
+			Price struct {
+				PriceInEth string `json:"priceInEth"`
+			} `json:"price"`
+		} `json:"reserve"`
+	} `json:"reserves"`
+}
+
+const lendingPositionsQuery = `query($first: Int!, $after: String!) {
+  users(first: $first, orderBy: id, where: {id_gt: $after, borrowedReservesCount_gt: 0}) {
+    id
+    eModeCategoryId { id liquidationThreshold }
+    reserves {
+      currentATokenBalance
+      currentTotalDebt
+      usageAsCollateralEnabledOnUser
+      reserve { underlyingAsset decimals reserveLiquidationThreshold eMode { id } price { priceInEth } }
+    }
+  }
+}`
+
+// refresh replaces the watched positions and the subgraph's prices
+func (d *liquidationDetector) refresh(ctx context.Context) error {
+	var positions []*lendingPosition
+	prices := make(map[string]float64)
+	after := ""
+	for len(positions) < d.config.MaxPositions {
+		var page struct {
+			Users []subgraphUser `json:"users"`
+		}
+		variables := map[string]interface{}{"first": subgraphPageSize, "after": after}
+		if err := d.query(ctx, lendingPositionsQuery, variables, &page); err != nil {
+			return err
+		}
+		for _, user := range page.Users {
+			positions = append(positions, convertLendingPosition(user, prices))
+		}
+		if len(page.Users) < subgraphPageSize {
+			break
+		}
+		after = page.Users[len(page.Users)-1].ID
+	}
+	if len(positions) > d.config.MaxPositions {
+		positions = positions[:d.config.MaxPositions]
+	}
+
+	holders := make(map[string][]*lendingPosition)
+	for _, position := range positions {
+		for _, reserve := range position.reserves {
+			holders[reserve.asset] = append(holders[reserve.asset], position)
+		}
+	}
+
+	d.mu.Lock()
+	for asset, price := range d.feedPrices {
+		prices[asset] = price
+	}
+	d.positions, d.holders, d.prices = positions, holders, prices
+	d.mu.Unlock()
+	lendingPositions.WithLabelValues(d.monitor.chainName).Set(float64(len(positions)))
+	return nil
+}
+
+// convertLendingPosition converts a subgraph user, collecting reserve prices
+func convertLendingPosition(user subgraphUser, prices map[string]float64) *lendingPosition {
+	position := &lendingPosition{borrower: strings.ToLower(user.ID)}
+	for _, held := range user.Reserves {
+		asset := strings.ToLower(held.Reserve.UnderlyingAsset)
+		if price, err := strconv.ParseFloat(held.Reserve.Price.PriceInEth, 64); err == nil && price > 0 {
+			prices[asset] = price
+		}
+
+		scale := math.Pow10(held.Reserve.Decimals)
+		reserve := lendingReserve{
+			asset:      asset,
+			collateral: parseAmount(held.ATokenBalance) / scale,
+			debt:       parseAmount(held.TotalDebt) / scale,
+		}
+		if held.CollateralEnabled {
+			// Thresholds are in basis points; E-mode borrowers get their
+			// category's threshold on the reserves in it
+			threshold := held.Reserve.LiquidationThreshold
+			if user.EMode != nil && user.EMode.ID != "0" && held.Reserve.EMode != nil && held.Reserve.EMode.ID == user.EMode.ID {
+				threshold = user.EMode.LiquidationThreshold
+			}
+			reserve.threshold = parseAmount(threshold) / 10000
+		}
+		if reserve.collateral > 0 || reserve.debt > 0 {
+			position.reserves = append(position.reserves, reserve)
+		}
+	}
+	return position
+}
+
+// parseAmount parses a decimal integer string as a float, zero when malformed
+func parseAmount(value string) float64 {
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return amount
+}
+
+// query runs a GraphQL query against the subgraph, decoding its data into result
+func (d *liquidationDetector) query(ctx context.Context, query string, variables map[string]interface{}, result interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return fmt.Errorf("failed to marshal query: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.subgraph, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid subgraph endpoint")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		// The url.Error would repeat the endpoint, API key included
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	envelope := struct {
+		Data   interface{} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}{Data: result}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	if len(envelope.Errors) > 0 {
+		return fmt.Errorf("subgraph error: %s", envelope.Errors[0].Message)
+	}
+	return nil
+}
+
+// HandleBlock is the detector's blockHandler: it applies the answers
+// aggregators set in the block
+func (d *liquidationDetector) HandleBlock(block *confirmedBlock) {
+	for _, tx := range block.Transactions {
+		if !tx.Succeeded() {
+			continue
+		}
+		for _, l := range tx.Logs {
+			if len(l.Topics) < 2 || strings.ToLower(l.Topics[0]) != answerUpdatedTopic {
+				continue
+			}
+			feed := strings.ToLower(l.Address)
+			asset, ok := d.feeds[feed]
+			if !ok {
+				continue
+			}
+			answer := new(big.Int).SetBytes(common.FromHex(l.Topics[1]))
+			// Negative answers do not price collateral
+			if answer.Bit(255) == 1 || answer.Sign() == 0 {
+				continue
+			}
+
+			trigger := LiquidationTrigger{
+				Kind:        LiquidationTriggerConfirmed,
+				Feed:        feed,
+				Asset:       asset,
+				Price:       answer.String(),
+				TxHash:      tx.Hash,
+				BlockNumber: block.Number,
+			}
+			d.evaluate(trigger, answer)
+
+			price, _ := new(big.Float).SetInt(answer).Float64()
+			d.mu.Lock()
+			d.feedPrices[asset] = price
+			if d.prices != nil {
+				d.prices[asset] = price
+			}
+			d.mu.Unlock()
+		}
+	}
+}
+
+// evaluate publishes the positions a new price for the trigger's asset
+// takes below the health factor threshold
+func (d *liquidationDetector) evaluate(trigger LiquidationTrigger, answer *big.Int) {
+	cm := d.monitor
+	price, _ := new(big.Float).SetInt(answer).Float64()
+
+	var candidates []LiquidationCandidate
+	d.mu.Lock()
+	if previous, ok := d.prices[trigger.Asset]; ok {
+		trigger.PreviousPrice = strconv.FormatFloat(previous, 'f', -1, 64)
+	}
+	for _, position := range d.holders[trigger.Asset] {
+		before, _, ok := position.health(d.prices, "", 0)
+		if !ok || before < d.config.HealthFactor {
+			continue
+		}
+		after, value, ok := position.health(d.prices, trigger.Asset, price)
+		if !ok || after >= d.config.HealthFactor {
+			continue
+		}
+		candidates = append(candidates, LiquidationCandidate{
+			Chain:                cm.chainName,
+			ChainID:              cm.chainID,
+			Protocol:             "aave_v3",
+			Borrower:             position.borrower,
+			HealthFactor:         after,
+			PreviousHealthFactor: before,
+			CollateralValue:      value.collateral,
+			DebtValue:            value.debt,
+			CollateralAsset:      value.collateralAsset,
+			DebtAsset:            value.debtAsset,
+			Trigger:              trigger,
+			DetectedAt:           time.Now(),
+		})
+	}
+	d.mu.Unlock()
+
+	// The most underwater positions first
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].HealthFactor < candidates[j].HealthFactor })
+	for _, candidate := range candidates {
+		d.publish(candidate)
+	}
+}
+
+// publish produces a candidate to the liquidations topic
+func (d *liquidationDetector) publish(candidate LiquidationCandidate) {
+	cm := d.monitor
+	data, err := json.Marshal(candidate)
+	if err != nil {
+		cm.logger.Error("failed to marshal liquidation candidate", "borrower", candidate.Borrower, "error", err)
+		return
+	}
+
+	topic := expandTopic(d.config.Topic, candidate.Chain, candidate.ChainID, cm.family)
+	headers := map[string]string{
+		"chain_id":   fmt.Sprintf("%d", candidate.ChainID),
+		"chain_name": candidate.Chain,
+		"trigger":    candidate.Trigger.Kind,
+		"format":     FormatJSON,
+	}
+	if err := cm.sink.Publish(cm.ctx, topic, []byte(candidate.Borrower), data, headers); err != nil {
+		liquidationCandidates.WithLabelValues(cm.chainName, candidate.Trigger.Kind, "failed").Inc()
+		cm.logger.Warn("failed to publish liquidation candidate", "borrower", candidate.Borrower, "error", err)
+		return
+	}
+	liquidationCandidates.WithLabelValues(cm.chainName, candidate.Trigger.Kind, "success").Inc()
+}
+
+// decodeTransmit returns the median observation of a Chainlink OCR report,
+// which becomes the aggregator's answer, or nil when calldata is not a
+// transmission
+func decodeTransmit(data []byte) *big.Int {
+	if len(data) < 4 {
+		return nil
+	}
+	args := abiData(data[4:])
+
+	// Both report layouts put the sorted observations at the third word:
+	// OCR1 (rawReportContext, rawObservers, int192[] observations), OCR2
+	// (observationsTimestamp, rawObservers, int192[] observations, juelsPerFeeCoin)
+	var report abiData
+	switch hex.EncodeToString(data[:4]) {
+	case transmitSelectorOCR2:
+		report = args.bytes(3)
+	case transmitSelectorOCR1:
+		report = args.bytes(0)
+	default:
+		return nil
+	}
+	observations := report.uints(2)
+	if len(observations) == 0 {
+		return nil
+	}
+	median := observations[len(observations)/2]
+	if median == nil || median.Bit(255) == 1 || median.Sign() == 0 {
+		return nil
+	}
+	return median
+}
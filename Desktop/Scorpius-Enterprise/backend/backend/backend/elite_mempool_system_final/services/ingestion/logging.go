@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Log output formats
+const (
+	LogFormatJSON    = "json"
+	LogFormatConsole = "console"
+)
+
+// logLevels are the accepted LOG_LEVEL and <CHAIN>_LOG_LEVEL values
+var logLevels = []string{"debug", "info", "warn", "warning", "error"}
+
+var (
+	// logLevel is the global level; reloads adjust it in place
+	logLevel            = new(slog.LevelVar)
+	logFormat           = LogFormatJSON
+	logOutput io.Writer = os.Stderr
+)
+
+// setupLogging installs the global slog handler. Output from the standard
+// log package, including dependencies, is routed through it at info level.
+func setupLogging(level, format string) {
+	logLevel.Set(parseLogLevel(level))
+	if format == LogFormatConsole {
+		logFormat = LogFormatConsole
+	}
+
+	slog.SetDefault(slog.New(newLogHandler(logLevel)))
+	log.SetFlags(0)
+}
+
+// newLogHandler creates a handler in the configured format filtering at level
+// and redacting credentials
+func newLogHandler(level slog.Leveler) slog.Handler {
+	options := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+	if logFormat == LogFormatConsole {
+		return slog.NewTextHandler(logOutput, options)
+	}
+	return slog.NewJSONHandler(logOutput, options)
+}
+
+// chainLogger returns a logger tagged with the chain. A non-empty level
+// overrides the global level for that chain only.
+func chainLogger(chain, level string) *slog.Logger {
+	if level == "" {
+		return slog.Default().With("chain", chain)
+	}
+	return slog.New(newLogHandler(parseLogLevel(level))).With("chain", chain)
+}
+
+// parseLogLevel maps debug, info, warn and error to slog levels, defaulting to info
+func parseLogLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "", "info":
+		return slog.LevelInfo
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	slog.Warn("unknown log level, using info", "level", level)
+	return slog.LevelInfo
+}
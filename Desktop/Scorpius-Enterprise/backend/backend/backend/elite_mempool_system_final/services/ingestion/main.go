@@ -2,21 +2,25 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
-	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
 
-	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"github.com/gorilla/websocket"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
 )
 
 // Metrics
@@ -28,7 +32,7 @@ var (
 		},
 		[]string{"chain", "status"},
 	)
-	
+
 	endpointHealth = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "scorpius_endpoint_health_score",
@@ -36,7 +40,7 @@ var (
 		},
 		[]string{"chain", "endpoint"},
 	)
-	
+
 	connectionLatency = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name: "scorpius_connection_latency_seconds",
@@ -48,91 +52,360 @@ var (
 
 // Configuration struct
 type Config struct {
-	KafkaBrokers     string
-	RedisURL         string
-	ChainEndpoints   map[string][]string
-	BatchSize        int
-	FlushIntervalMS  int
-	MaxConnections   int
+	KafkaBrokers           string
+	RedisURL               string
+	CacheBackend           string
+	CacheSize              int
+	ChainEndpoints         map[string][]string
+	ChainOptions           map[string]ChainOptions
+	Kafka                  KafkaConfig
+	Spool                  SpoolConfig
+	MaxConnections         int
+	TransactionalID        string
+	LogLevel               string
+	LogFormat              string
+	AdminAddr              string
+	GRPCAddr               string
+	TokenEnrichment        bool
+	SwapDecoding           bool
+	Normalization          bool
+	AlertTransports        []AlertTransportConfig
+	TagTTL                 time.Duration
+	MEVShareURL            string
+	MEVShareChain          string
+	CanaryInterval         time.Duration
+	CanarySLO              time.Duration
+	MessageFormat          string
+	SchemaRegistryURL      string
+	SchemaRegistryUser     string
+	SchemaRegistryPassword string
+	TopicFormats           map[string]string
+	Envelope               EnvelopeConfig
+	Sink                   string
+	NATSURL                string
+	NATSSubjectPrefix      string
+	NATSStream             string
+	NATSCredentials        string
+	Kinesis                KinesisConfig
+	PubSub                 PubSubConfig
+	RedisStreamPrefix      string
+	RedisStreamMaxLen      int64
+	ClickHouse             ClickHouseConfig
+	Postgres               PostgresConfig
+	S3Export               S3ExportConfig
+	Tracing                TracingConfig
+	Watchlist              WatchlistConfig
+	MEV                    MEVConfig
+	GasOracle              GasOracleConfig
+	Nonces                 NonceConfig
+	PrivateFlow            PrivateFlowConfig
+	Sanctions              SanctionsConfig
+	Deploys                DeployConfig
+	Blobs                  BlobConfig
+	BeaconEvents           BeaconEventConfig
+	UserOps                UserOpConfig
+	Liquidations           LiquidationConfig
+	NFT                    NFTConfig
+	Whales                 WhaleConfig
+	Labels                 LabelConfig
+	Bridges                BridgeConfig
+	Drops                  DropConfig
+	Simulation             SimulationConfig
+	BundleSim              BundleSimConfig
+	Streams                StreamConfig
+	GraphQL                GraphQLConfig
+	Leader                 LeaderConfig
+	Shards                 ShardConfig
+	Drain                  DrainConfig
+	RPCLimits              RPCLimitConfig
+	Secrets                SecretsConfig
+	Auth                   AuthConfig
+	Tenants                map[string]TenantConfig
+	SLO                    SLOConfig
+	AlertCooldown          time.Duration
+	AlertProduceFailures   int
+	DryRun                 bool
+	ChaosEnabled           bool
+	TopicTemplate          string
+	TopicRoutes            map[string]string
+	ExprRoutes             map[string]string
+	ExactlyOnceTopics      []string
+	KafkaIdempotent        bool
+	KafkaDLQTopic          string
+	KafkaDeliveryRetries   int
+	KafkaRetryBackoff      time.Duration
+	KafkaHealthInterval    time.Duration
+	KafkaHealthTimeout     time.Duration
+	KafkaMirrors           map[string]string
+	EndpointStrategy       string
+}
+
+// ChainOptions holds per-chain tuning
+type ChainOptions struct {
+	WarmupDuration   time.Duration
+	SubscriptionMode string
+	EndpointModes    map[string]string
+	WatchlistFilter  bool
+	HydrationWorkers int
+	HydrationURL     string
+	Commitment       string
+	LogsMentions     []string
+	ZMQTopics        []string
+	Network          string
+	CosmosSource     string
+	CosmosPoll       time.Duration
+	TronAPIKey       string
+	TronPoll         time.Duration
+	TonAccounts      []string
+	SequencerPoll    time.Duration
+	SequencerEngine  string
+	DeliveryMode     string
+	BatchWindow      time.Duration
+	VerifyChainID    bool
+	BloxrouteAuth    string
+	BloxrouteStream  string
+	IngestMode       string
+	P2PNodeKey       string
+	P2PMaxPeers      int
+	P2PDiscovery     bool
+	P2PListenAddr    string
+	Sink             string
+	TopicTemplate    string
+	DedupTTL         time.Duration
+	CacheTTL         time.Duration
+	CacheBatch       int
+	CacheFlush       time.Duration
+	Indexes          bool
+	IndexMax         int
+	IndexTTL         time.Duration
+	QueueCapacity    int
+	QueuePolicy      string
+	QueueParkTimeout time.Duration
+	Workers          int
+	BackoffBase      time.Duration
+	BackoffMax       time.Duration
+	PingInterval     time.Duration
+	ReadTimeout      time.Duration
+	ActiveProbes     bool
+	MaxBlockLag      uint64
+	EndpointStrategy string
+	CircuitFailures  int
+	CircuitCooldown  time.Duration
 	LogLevel         string
+	FilterMinValue   string
+	FilterGasPrice   string
+	FilterMinTip     string
+	FilterCreation   bool
+	FilterSelectors  []string
+	FilterAction     string
+	FilterTopic      string
+	FilterExpr       string
+	BlockTracking    bool
+	BlockPoll        time.Duration
+	BlockConfirms    int
+	BlockURL         string
+	BeaconURL        string
+	BundlerURLs      []string
+	LendingSubgraph  string
+	PriceFeeds       map[string]string
+	Stablecoins      map[string]string
+	Bridges          map[string]string
+	ReorgAlertDepth  int
+	SimulationURL    string
+	SimulationExpr   string
 }
 
+// Transaction types (EIP-2718)
+const (
+	TxTypeLegacy     = "0x0"
+	TxTypeAccessList = "0x1"
+	TxTypeDynamicFee = "0x2"
+	TxTypeBlob       = "0x3"
+	// zkSync Era's EIP-712 account abstraction and L1 priority transactions
+	TxTypeZKSyncEIP712   = "0x71"
+	TxTypeZKSyncPriority = "0xff"
+)
+
 // Transaction represents a blockchain transaction
 type Transaction struct {
-	Hash             string                 `json:"hash"`
-	ChainID          int64                  `json:"chain_id"`
-	From             string                 `json:"from"`
-	To               string                 `json:"to"`
-	Value            string                 `json:"value"`
-	Gas              string                 `json:"gas"`
-	GasPrice         string                 `json:"gas_price"`
-	Data             string                 `json:"data"`
-	Nonce            string                 `json:"nonce"`
-	Timestamp        int64                  `json:"timestamp"`
-	BlockNumber      *int64                 `json:"block_number,omitempty"`
-	TransactionIndex *int                   `json:"transaction_index,omitempty"`
-	Status           string                 `json:"status"` // "pending", "confirmed", "failed"
-	Raw              map[string]interface{} `json:"raw"`
+	Hash                 string             `json:"hash"`
+	ChainID              int64              `json:"chain_id"`
+	Chain                string             `json:"chain"`
+	ChainFamily          string             `json:"chain_family"`
+	Type                 string             `json:"type"`
+	From                 string             `json:"from"`
+	To                   string             `json:"to"`
+	Value                string             `json:"value"`
+	Gas                  string             `json:"gas"`
+	GasPrice             string             `json:"gas_price"`
+	MaxFeePerGas         string             `json:"max_fee_per_gas,omitempty"`
+	MaxPriorityFeePerGas string             `json:"max_priority_fee_per_gas,omitempty"`
+	MaxFeePerBlobGas     string             `json:"max_fee_per_blob_gas,omitempty"`
+	BlobVersionedHashes  []string           `json:"blob_versioned_hashes,omitempty"`
+	AccessList           []AccessTuple      `json:"access_list,omitempty"`
+	Data                 string             `json:"data"`
+	Nonce                string             `json:"nonce"`
+	Timestamp            int64              `json:"timestamp"`
+	BlockNumber          *int64             `json:"block_number,omitempty"`
+	TransactionIndex     *int               `json:"transaction_index,omitempty"`
+	Status               string             `json:"status"` // "pending", "confirmed", "failed"
+	Canary               bool               `json:"canary,omitempty"`
+	TokenTransfer        *TokenTransfer     `json:"token_transfer,omitempty"`
+	MEV                  *MEVClassification `json:"mev,omitempty"`
+	IsReplacement        bool               `json:"is_replacement,omitempty"`
+	ReplacedHash         string             `json:"replaced_hash,omitempty"`
+	NonceGap             uint64             `json:"nonce_gap,omitempty"`
+	Simulation           *Simulation        `json:"simulation,omitempty"`
+	Swap                 *SwapIntent        `json:"swap,omitempty"`
+	FromLabel            *AddressLabel      `json:"from_label,omitempty"`
+	ToLabel              *AddressLabel      `json:"to_label,omitempty"`
+	Decimal              *DecimalAmounts    `json:"decimal,omitempty"`
+	Tags                 []TxTag            `json:"tags,omitempty"`
+	Inputs               []UTXOInput        `json:"inputs,omitempty"`
+	Outputs              []UTXOOutput       `json:"outputs,omitempty"`
+	Raw                  json.RawMessage    `json:"raw"`
+
+	// Pipeline state carried through the queue but not serialized
+	received    time.Time
+	endpoint    string
+	span        trace.Span
+	lowPriority bool
+	suppressed  bool
+	warmup      bool
+}
+
+// AccessTuple is a single EIP-2930 access list entry
+type AccessTuple struct {
+	Address     string   `json:"address"`
+	StorageKeys []string `json:"storage_keys"`
 }
 
 // ChainMonitor manages connections for a specific blockchain
 type ChainMonitor struct {
-	chainName    string
-	chainID      int64
-	endpoints    []string
-	activeConn   *websocket.Conn
-	producer     *kafka.Producer
-	redisClient  *redis.Client
-	ctx          context.Context
-	cancel       context.CancelFunc
-	mu           sync.RWMutex
-	healthScores map[string]float64
-	lastSeen     map[string]time.Time
+	chainName      string
+	chainID        int64
+	family         string
+	endpoints      []string
+	options        ChainOptions
+	protocol       wsProtocol
+	streamer       streamer
+	activeConn     *websocket.Conn
+	activeEndpoint string
+	sink           Sink
+	leader         *leaderLease
+	cache          Cache
+	alerter        *Alerter
+	enrichers      []Enricher
+	encoders       *topicEncoders
+	archivers      []Archiver
+	router         *topicRouter
+	filter         *txFilter
+	simulator      *simulator
+	txCache        *txCache
+	hub            *txHub
+	queue          *txQueue
+	backoff        *endpointBackoff
+	breaker        *circuitBreaker
+	hydrator       *hydrator
+	subscription   string
+	capabilities   map[string]string
+	watchFilter    *watchlistFilter
+	blocks         *blockTracker
+	beaconEvents   *beaconEventSource
+	userOps        *userOpSource
+	liquidations   *liquidationDetector
+	blockHandlers  []blockHandler
+	txRate         *rateMeter
+	drain          DrainConfig
+	chaos          *chaosInjector
+	slo            *sloTracker
+	ctx            context.Context
+	cancel         context.CancelFunc
+	deliverCtx     context.Context
+	stopDelivery   context.CancelFunc
+	mu             sync.RWMutex
+	healthScores   map[string]float64
+	lastSeen       map[string]time.Time
+	disabled       map[string]string
+	probeFactors   map[string]float64
+	latencies      map[string]time.Duration
+	roundRobin     atomic.Uint64
+	metrics        atomic.Pointer[chainMetrics]
+	headerValues   atomic.Pointer[chainHeaderValues]
+	timestamp      atomic.Pointer[timestampText]
+	warmupUntil    time.Time
+	endpointsDown  bool
+	logger         *slog.Logger
 }
 
 // NewChainMonitor creates a new chain monitor
-func NewChainMonitor(chainName string, chainID int64, endpoints []string, producer *kafka.Producer, redisClient *redis.Client) *ChainMonitor {
+func NewChainMonitor(chainName string, chainID int64, endpoints []string, options ChainOptions, sink Sink, cache Cache, alerter *Alerter) *ChainMonitor {
 	ctx, cancel := context.WithCancel(context.Background())
-	
-	return &ChainMonitor{
+	// Delivery outlives reading so a drain can publish what was already read
+	deliverCtx, stopDelivery := context.WithCancel(context.Background())
+	logger := chainLogger(chainName, options.LogLevel)
+
+	cm := &ChainMonitor{
 		chainName:    chainName,
 		chainID:      chainID,
+		family:       FamilyEVM,
 		endpoints:    endpoints,
-		producer:     producer,
-		redisClient:  redisClient,
+		options:      options,
+		sink:         sink,
+		cache:        cache,
+		alerter:      alerter,
+		encoders:     &topicEncoders{fallback: jsonEncoder{}},
+		router:       &topicRouter{template: defaultTopicTemplate},
+		queue:        newTxQueue(chainName, options.QueueCapacity, options.QueuePolicy, options.QueueParkTimeout, logger),
+		backoff:      newEndpointBackoff(chainName, options.BackoffBase, options.BackoffMax),
+		breaker:      newCircuitBreaker(chainName, options.CircuitFailures, options.CircuitCooldown, logger),
+		txRate:       newRateMeter(10 * time.Second),
+		txCache:      newTxCache(deliverCtx, chainName, cache, options, logger),
 		ctx:          ctx,
 		cancel:       cancel,
+		deliverCtx:   deliverCtx,
+		stopDelivery: stopDelivery,
 		healthScores: make(map[string]float64),
 		lastSeen:     make(map[string]time.Time),
+		disabled:     make(map[string]string),
+		latencies:    make(map[string]time.Duration),
+		capabilities: make(map[string]string),
+		logger:       logger,
 	}
+	cm.protocol = cm
+
+	if !validEndpointStrategy(chainName, options.EndpointStrategy) {
+		cm.options.EndpointStrategy = StrategyBest
+	}
+
+	return cm
 }
 
 // Start begins monitoring the blockchain
 func (cm *ChainMonitor) Start() error {
-	log.Printf("Starting monitor for %s (chain_id: %d)", cm.chainName, cm.chainID)
-	
+	cm.logger.Info("starting monitor", "chain_id", cm.chainID)
+
 	// Initialize health scores
 	for _, endpoint := range cm.endpoints {
 		cm.healthScores[endpoint] = 1.0
 		cm.lastSeen[endpoint] = time.Now()
 	}
-	
+
+	if cm.mayStreamHashes() {
+		cm.hydrator = newHydrator(cm)
+		cm.hydrator.start()
+	}
+	cm.startBlockTracker()
+	cm.startBeaconEvents()
+	cm.startUserOps()
+	cm.startLiquidations()
+
+	cm.queue.start(cm.deliverTransaction, cm.options.Workers)
 	go cm.monitorLoop()
 	go cm.healthCheckLoop()
-	
-	return nil
-}
+	go cm.circuitLoop()
 
-// Stop stops the chain monitor
-func (cm *ChainMonitor) Stop() {
-	log.Printf("Stopping monitor for %s", cm.chainName)
-	cm.cancel()
-	
-	cm.mu.Lock()
-	if cm.activeConn != nil {
-		cm.activeConn.Close()
-	}
-	cm.mu.Unlock()
+	return nil
 }
 
 // monitorLoop is the main monitoring loop
@@ -142,52 +415,147 @@ func (cm *ChainMonitor) monitorLoop() {
 		case <-cm.ctx.Done():
 			return
 		default:
-			if err := cm.connectAndListen(); err != nil {
-				log.Printf("Error in monitor loop for %s: %v", cm.chainName, err)
-				time.Sleep(5 * time.Second)
+			endpoint := cm.selectEndpoint()
+			if !cm.sleep(cm.backoff.Wait(endpoint)) {
+				return
+			}
+
+			if err := cm.connectAndListen(endpoint); err != nil {
+				cm.logger.Error("monitor loop failed", "endpoint", displayEndpoint(endpoint), "error", err)
+				cm.alerter.Raise(Alert{
+					Chain:    cm.chainName,
+					Category: "connection",
+					Severity: SeverityWarning,
+					Message:  err.Error(),
+				})
+				cm.backoff.Failure(endpoint)
+				if endpoint != "" {
+					cm.breaker.Failure(endpoint)
+				}
 			}
 		}
 	}
 }
 
-// connectAndListen connects to the best available endpoint and listens for transactions
-func (cm *ChainMonitor) connectAndListen() error {
-	endpoint := cm.getBestEndpoint()
+// sleep waits for d unless the monitor stops first, reporting whether it is still running
+func (cm *ChainMonitor) sleep(d time.Duration) bool {
+	if d <= 0 {
+		return cm.ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-cm.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// connectAndListen connects to endpoint and listens for transactions
+func (cm *ChainMonitor) connectAndListen(endpoint string) error {
 	if endpoint == "" {
 		return fmt.Errorf("no healthy endpoints available for %s", cm.chainName)
 	}
-	
-	log.Printf("Connecting to %s endpoint: %s", cm.chainName, endpoint)
-	
+
+	cm.logger.Info("connecting", "endpoint", displayEndpoint(endpoint))
+	if endpointType, _ := splitEndpointType(endpoint); endpointType == EndpointMock {
+		return cm.runMock(endpoint)
+	}
+
+	// Hold one of the provider's subscription slots for as long as we listen;
+	// with several API keys each key has its own slots
+	resolved, key := rpcLimits.Resolve(endpoint)
+	limiter := rpcLimits.For(resolved)
+	release, err := limiter.Subscribe()
+	if err != nil {
+		return err
+	}
+	defer release()
+	if err := limiter.Wait(cm.ctx); err != nil {
+		return nil
+	}
+
+	if cm.streamer != nil {
+		return cm.streamEndpoint(endpoint)
+	}
+
+	endpointType, dialURL := splitEndpointType(endpoint)
+	_, keyedURL := splitEndpointType(resolved)
+	protocol := cm.protocol
+	var header http.Header
+	if endpointType == EndpointBloxroute {
+		protocol = &bloxrouteProtocol{cm: cm}
+		header = cm.bloxrouteHeader()
+	}
+
 	// Track connection latency
 	start := time.Now()
-	
-	conn, _, err := websocket.DefaultDialer.Dial(endpoint, nil)
+
+	conn, resp, err := websocket.DefaultDialer.Dial(keyedURL, header)
 	if err != nil {
+		key.Observe(resp != nil && resp.StatusCode == http.StatusTooManyRequests, err)
 		cm.updateHealthScore(endpoint, 0.0)
 		return fmt.Errorf("failed to connect to %s: %v", endpoint, err)
 	}
-	
+	key.Observe(false, nil)
+
 	latency := time.Since(start)
-	connectionLatency.WithLabelValues(cm.chainName, endpoint).Observe(latency.Seconds())
-	
+	connectionLatency.WithLabelValues(cm.chainName, endpointLabel(endpoint)).Observe(latency.Seconds())
+	cm.recordLatency(endpoint, latency)
+
+	if cm.family == FamilyEVM && endpointType == EndpointRPC && cm.options.VerifyChainID {
+		if err := cm.verifyChainID(conn, endpoint); err != nil {
+			conn.Close()
+			cm.updateHealthScore(endpoint, 0.0)
+			return err
+		}
+	}
+
+	subscription := cm.options.SubscriptionMode
+	if cm.family == FamilyEVM && endpointType == EndpointRPC {
+		subscription, err = cm.selectSubscription(endpoint, keyedURL, limiter)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		cm.recordSubscription(endpoint, subscription)
+	}
+
 	cm.mu.Lock()
 	cm.activeConn = conn
+	cm.activeEndpoint = endpoint
+	cm.subscription = subscription
 	cm.mu.Unlock()
-	
-	// Subscribe to pending transactions
-	subscribeMsg := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      1,
-		"method":  "eth_subscribe",
-		"params":  []interface{}{"newPendingTransactions", true},
+	defer cm.clearActiveConn()
+
+	if cm.hydrator != nil {
+		cm.hydrator.setEndpoint(dialURL)
+	}
+	if cm.blocks != nil {
+		cm.blocks.setEndpoint(dialURL)
+	}
+
+	for _, subscribeMsg := range protocol.subscribeRequests() {
+		if err := conn.WriteJSON(subscribeMsg); err != nil {
+			conn.Close()
+			return fmt.Errorf("failed to subscribe to pending transactions: %v", err)
+		}
 	}
-	
-	if err := conn.WriteJSON(subscribeMsg); err != nil {
-		conn.Close()
-		return fmt.Errorf("failed to subscribe to pending transactions: %v", err)
+
+	cm.backoff.Reset(endpoint)
+	cm.breaker.Success(endpoint)
+	cm.beginWarmup()
+
+	stopKeepalive := cm.keepalive(conn)
+	defer stopKeepalive()
+	if cm.watchFilter != nil && endpointType == EndpointRPC && subscription == SubscriptionAlchemy {
+		stopFilter := cm.watchFilter.follow(conn)
+		defer stopFilter()
 	}
-	
+
 	// Listen for messages
 	for {
 		select {
@@ -195,140 +563,440 @@ func (cm *ChainMonitor) connectAndListen() error {
 			conn.Close()
 			return nil
 		default:
-			var msg map[string]interface{}
-			if err := conn.ReadJSON(&msg); err != nil {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
 				conn.Close()
 				cm.updateHealthScore(endpoint, 0.5)
+				if isReadTimeout(err) {
+					staleConnections.WithLabelValues(cm.chainName, endpointLabel(endpoint)).Inc()
+					return fmt.Errorf("no data or pong from %s within %s", endpoint, cm.options.ReadTimeout)
+				}
 				return fmt.Errorf("error reading message: %v", err)
 			}
-			
-			if err := cm.handleMessage(msg); err != nil {
-				log.Printf("Error handling message: %v", err)
+			cm.extendReadDeadline(conn)
+			if cm.chaos.Drop(cm.chainName) {
+				continue
 			}
-			
-			cm.updateHealthScore(endpoint, 1.0)
-			cm.lastSeen[endpoint] = time.Now()
+			data = cm.chaos.Corrupt(cm.chainName, data)
+
+			var msg rpcMessage
+			if err := decodeWire(data, &msg); err != nil {
+				cm.logger.Error("failed to decode message", "endpoint", displayEndpoint(endpoint), "error", err)
+			} else if err := protocol.handleMessage(&msg); err != nil {
+				cm.logger.Error("failed to handle message", "endpoint", displayEndpoint(endpoint), "error", err)
+			}
+
+			cm.recordActivity(endpoint)
 		}
 	}
 }
 
+// clearActiveConn resets the active connection state after a disconnect
+func (cm *ChainMonitor) clearActiveConn() {
+	cm.mu.Lock()
+	cm.activeConn = nil
+	cm.activeEndpoint = ""
+	cm.subscription = ""
+	cm.mu.Unlock()
+}
+
+// subscribeRequests returns the eth_subscribe request for pending transactions
+// in the subscription mode selected for the active endpoint, filtered on the
+// watchlist with <CHAIN>_SUBSCRIPTION_WATCHLIST on Alchemy endpoints
+func (cm *ChainMonitor) subscribeRequests() []interface{} {
+	cm.mu.RLock()
+	mode := cm.subscription
+	cm.mu.RUnlock()
+
+	params := subscriptionParams(mode)
+	if cm.watchFilter != nil && mode == SubscriptionAlchemy {
+		if params = cm.watchFilter.begin(); params == nil {
+			return nil
+		}
+	}
+
+	return []interface{}{map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_subscribe",
+		"params":  params,
+	}}
+}
+
 // handleMessage processes incoming WebSocket messages
-func (cm *ChainMonitor) handleMessage(msg map[string]interface{}) error {
-	// Check if this is a subscription notification
-	if params, ok := msg["params"].(map[string]interface{}); ok {
-		if result, ok := params["result"].(map[string]interface{}); ok {
-			return cm.processPendingTransaction(result)
+func (cm *ChainMonitor) handleMessage(msg *rpcMessage) error {
+	// Responses tell the watchlist filter which subscriptions it opened
+	if cm.watchFilter != nil && msg.Params == nil {
+		cm.watchFilter.handleResponse(msg)
+	}
+
+	// Subscription notifications carry either a full transaction or just its hash
+	if msg.Params == nil || isJSONNull(msg.Params.Result) {
+		return nil
+	}
+
+	if msg.Params.Result[0] == '"' {
+		if cm.hydrator == nil {
+			return nil
+		}
+		var hash string
+		if err := wireJSON.Unmarshal(msg.Params.Result, &hash); err != nil {
+			return fmt.Errorf("failed to decode transaction hash: %v", err)
 		}
+		cm.hydrator.enqueue(hash)
+		return nil
 	}
-	
-	return nil
+
+	return cm.processPendingTransaction(msg.Params.Result)
 }
 
 // processPendingTransaction processes a pending transaction
-func (cm *ChainMonitor) processPendingTransaction(txData map[string]interface{}) error {
+func (cm *ChainMonitor) processPendingTransaction(data json.RawMessage) error {
+	return cm.ingestPendingTransaction(data, false)
+}
+
+// processCanary ingests a synthetic canary. Only the canary monitor marks
+// transactions as canaries: the marker in the payload comes from the wire
+// and is never trusted.
+func (cm *ChainMonitor) processCanary(data json.RawMessage) error {
+	return cm.ingestPendingTransaction(data, true)
+}
+
+// ingestPendingTransaction decodes a pending transaction and queues it for delivery
+func (cm *ChainMonitor) ingestPendingTransaction(data json.RawMessage, canary bool) error {
+	span := cm.startIngestSpan()
+	_, decodeSpan := startSpan(contextWithSpan(cm.ctx, span), "decode", trace.SpanKindInternal)
+	tx, err := cm.decodeTransaction(data)
+	endSpan(decodeSpan, err)
+	if err != nil {
+		endSpan(span, err)
+		return err
+	}
+
+	// The span is ended by deliverTransaction once the transaction leaves the queue
+	if span.IsRecording() {
+		span.SetAttributes(attribute.String("tx_hash", tx.Hash))
+	}
+	tx.span = span
+	tx.Canary = canary
+	if err := cm.publishTransaction(tx); err != nil {
+		endSpan(span, err)
+		return err
+	}
+	return nil
+}
+
+// decodeTransaction maps a JSON-RPC transaction object into the Transaction envelope
+func (cm *ChainMonitor) decodeTransaction(data json.RawMessage) (Transaction, error) {
+	var rpcTx rpcTransaction
+	if err := rpcTx.decode(data); err != nil {
+		return Transaction{}, fmt.Errorf("failed to decode transaction: %v", err)
+	}
+
 	tx := Transaction{
-		ChainID:   cm.chainID,
-		Status:    "pending",
-		Timestamp: time.Now().Unix(),
-		Raw:       txData,
+		Hash:                 rpcTx.Hash,
+		ChainID:              cm.chainID,
+		Chain:                cm.chainName,
+		ChainFamily:          cm.family,
+		From:                 rpcTx.From,
+		To:                   rpcTx.To,
+		Value:                rpcTx.Value,
+		Gas:                  rpcTx.Gas,
+		GasPrice:             rpcTx.GasPrice,
+		MaxFeePerGas:         rpcTx.MaxFeePerGas,
+		MaxPriorityFeePerGas: rpcTx.MaxPriorityFeePerGas,
+		MaxFeePerBlobGas:     rpcTx.MaxFeePerBlobGas,
+		BlobVersionedHashes:  rpcTx.BlobVersionedHashes,
+		Data:                 rpcTx.Input,
+		Nonce:                rpcTx.Nonce,
+		Status:               "pending",
+		Timestamp:            time.Now().Unix(),
+		Raw:                  data,
+	}
+
+	// Typed transaction fields (EIP-2718/1559/2930/4844)
+	tx.Type = TxTypeLegacy
+	if rpcTx.Type != "" {
+		tx.Type = normalizeTxType(rpcTx.Type)
 	}
-	
-	// Extract transaction fields
-	if hash, ok := txData["hash"].(string); ok {
-		tx.Hash = hash
+	if rpcTx.AccessList != nil {
+		tx.AccessList = make([]AccessTuple, 0, len(rpcTx.AccessList))
+		for _, entry := range rpcTx.AccessList {
+			tuple := AccessTuple{Address: entry.Address, StorageKeys: entry.StorageKeys}
+			if tuple.StorageKeys == nil {
+				tuple.StorageKeys = []string{}
+			}
+			tx.AccessList = append(tx.AccessList, tuple)
+		}
+	}
+
+	return tx, nil
+}
+
+// publishTransaction queues a decoded transaction for delivery, keeping the reader off the publish path
+func (cm *ChainMonitor) publishTransaction(tx Transaction) error {
+	if tx.received.IsZero() {
+		tx.received = time.Now()
+	}
+	if tx.endpoint == "" {
+		cm.mu.RLock()
+		tx.endpoint = cm.activeEndpoint
+		cm.mu.RUnlock()
 	}
-	if from, ok := txData["from"].(string); ok {
-		tx.From = from
+	return cm.queue.Put(tx)
+}
+
+// deliverTransaction enriches a queued transaction and sends it downstream
+func (cm *ChainMonitor) deliverTransaction(tx *Transaction) (err error) {
+	// Sources that bypass processPendingTransaction start their trace here
+	span := tx.span
+	if span == nil {
+		span = cm.startIngestSpan()
+		if span.IsRecording() {
+			span.SetAttributes(attribute.String("tx_hash", tx.Hash))
+		}
 	}
-	if to, ok := txData["to"].(string); ok {
-		tx.To = to
+	defer func() { endSpan(span, err) }()
+	ctx := contextWithSpan(cm.deliverCtx, span)
+
+	// Standbys stay connected for a fast failover but leave publishing, and
+	// so dedup claims, to the chain's leader
+	if cm.standby() {
+		leaderStandbySkipped.WithLabelValues(cm.chainName).Inc()
+		span.SetAttributes(attribute.Bool("standby", true))
+		return nil
 	}
-	if value, ok := txData["value"].(string); ok {
-		tx.Value = value
+
+	// Skip transactions already published before a reconnect or restart
+	if !cm.claimTransaction(tx) {
+		span.SetAttributes(attribute.Bool("duplicate", true))
+		return nil
 	}
-	if gas, ok := txData["gas"].(string); ok {
-		tx.Gas = gas
+
+	// Enrichers raise their alerts through raiseTxAlert, which holds them
+	// back while the chain is warming up
+	tx.warmup = cm.inWarmup()
+	for _, enricher := range cm.enrichers {
+		enricher.Enrich(tx)
 	}
-	if gasPrice, ok := txData["gasPrice"].(string); ok {
-		tx.GasPrice = gasPrice
+
+	// Sanctioned transactions are dropped when screening is set to suppress
+	if tx.suppressed {
+		span.SetAttributes(attribute.Bool("suppressed", true))
+		return nil
 	}
-	if data, ok := txData["input"].(string); ok {
-		tx.Data = data
+
+	// Low-value spam is dropped, or demoted to the filter topic, before it reaches the sink
+	if reason, rejected := cm.filter.Rejects(tx); rejected {
+		txFiltered.WithLabelValues(cm.chainName, reason, cm.filter.action).Inc()
+		span.SetAttributes(attribute.String("filtered", reason))
+		if cm.filter.action == FilterDrop {
+			return nil
+		}
+		tx.lowPriority = true
 	}
-	if nonce, ok := txData["nonce"].(string); ok {
-		tx.Nonce = nonce
+
+	// Transactions that survive the filter may be simulated against the latest state
+	if !tx.lowPriority {
+		cm.simulate(ctx, tx)
 	}
-	
-	// Send to Kafka
-	if err := cm.sendToKafka(tx); err != nil {
+
+	// Publish to the output sink. Sinks acknowledging asynchronously defer
+	// the SLO measurement until the broker confirms every message.
+	sloCtx, delivery := cm.slo.track(ctx, cm.chainName, receivedAt(tx))
+	if err := cm.sendToSink(sloCtx, tx); err != nil {
+		delivery.Failed()
+		cm.releaseTransaction(tx.Hash)
 		txIngested.WithLabelValues(cm.chainName, "failed").Inc()
-		return fmt.Errorf("failed to send transaction to Kafka: %v", err)
+		return fmt.Errorf("failed to send transaction to %s: %v", cm.sink.Name(), err)
 	}
-	
+	delivery.Delivered()
+	cm.hotMetrics().ingestLatency.Observe(time.Since(receivedAt(tx)).Seconds())
+
 	// Cache in Redis for quick lookups
-	if err := cm.cacheTransaction(tx); err != nil {
-		log.Printf("Warning: failed to cache transaction in Redis: %v", err)
+	if err := cm.txCache.Write(ctx, tx); err != nil {
+		cm.logger.Warn("failed to cache transaction in Redis", "tx_hash", tx.Hash, "error", err)
+	}
+
+	// Canaries are synthetic and stay out of historical stores
+	if !tx.Canary {
+		for _, archiver := range cm.archivers {
+			archiver.Archive(*tx)
+		}
 	}
-	
-	txIngested.WithLabelValues(cm.chainName, "success").Inc()
+	cm.hub.Publish(tx)
+
+	cm.hotMetrics().delivered.Inc()
+	cm.txRate.Mark(1)
 	return nil
 }
 
-// sendToKafka sends transaction to Kafka topic
-func (cm *ChainMonitor) sendToKafka(tx Transaction) error {
-	data, err := json.Marshal(tx)
-	if err != nil {
-		return fmt.Errorf("failed to marshal transaction: %v", err)
-	}
-	
-	topic := "tx_raw"
-	
-	return cm.producer.Produce(&kafka.Message{
-		TopicPartition: kafka.TopicPartition{
-			Topic:     &topic,
-			Partition: kafka.PartitionAny,
-		},
-		Key:   []byte(tx.Hash),
-		Value: data,
-		Headers: []kafka.Header{
-			{Key: "chain_id", Value: []byte(fmt.Sprintf("%d", tx.ChainID))},
-			{Key: "chain_name", Value: []byte(cm.chainName)},
-			{Key: "timestamp", Value: []byte(fmt.Sprintf("%d", tx.Timestamp))},
-		},
-	}, nil)
+// normalizeTxType converts a hex quantity type field ("0x02", "0x2") to canonical form
+func normalizeTxType(txType string) string {
+	lower := strings.ToLower(txType)
+	trimmed := strings.TrimLeft(strings.TrimPrefix(lower, "0x"), "0")
+	switch {
+	case trimmed == "":
+		return TxTypeLegacy
+	case strings.HasPrefix(lower, "0x") && len(lower) == len(trimmed)+2:
+		// Already canonical, as nodes usually send it
+		return lower
+	}
+	return "0x" + trimmed
 }
 
-// cacheTransaction caches transaction in Redis
-func (cm *ChainMonitor) cacheTransaction(tx Transaction) error {
-	key := fmt.Sprintf("tx:%s:%s", cm.chainName, tx.Hash)
-	
-	data, err := json.Marshal(tx)
-	if err != nil {
-		return err
+// sendToSink publishes the transaction to its base topic and any routed topics
+func (cm *ChainMonitor) sendToSink(ctx context.Context, tx *Transaction) error {
+	// The span name is built per call, so skip it altogether while tracing is off
+	if !tracingEnabled.Load() {
+		return cm.produce(ctx, tx)
+	}
+	ctx, span := tracer.Start(ctx, cm.sink.Name()+".produce", trace.WithSpanKind(trace.SpanKindProducer))
+	err := cm.produce(ctx, tx)
+	endSpan(span, err)
+	return err
+}
+
+// produce encodes and publishes tx to each of its topics, carrying the trace context in the headers
+func (cm *ChainMonitor) produce(ctx context.Context, tx *Transaction) error {
+	buf := acquirePublishBuffers()
+	defer releasePublishBuffers(buf)
+
+	if tx.lowPriority {
+		buf.topics = append(buf.topics, expandTopic(cm.filter.topic, tx.Chain, tx.ChainID, tx.ChainFamily))
+	} else {
+		buf.topics = cm.router.Topics(buf.topics, tx)
+	}
+	for _, topic := range buf.topics {
+		buf.targets = cm.encoders.Targets(buf.targets, topic)
+	}
+
+	// Every message of the transaction carries the same key and, apart from
+	// its format, the same headers
+	buf.key = append(buf.key[:0], tx.Hash...)
+	values := cm.chainHeaders(tx)
+	buf.headers["chain_id"] = values.chainID
+	buf.headers["chain_name"] = cm.chainName
+	buf.headers["timestamp"] = cm.timestampHeader(tx.Timestamp)
+	if values.caip2 != "" {
+		buf.headers["chain_caip2"] = values.caip2
+		from, to := caip10Pair(values.caip2, tx.From, tx.To)
+		if from != "" {
+			buf.headers["from_caip10"] = from
+		}
+		if to != "" {
+			buf.headers["to_caip10"] = to
+		}
+	}
+	if tx.Canary {
+		buf.headers["canary"] = "true"
+	}
+	if hasTag(tx, watchlistTag) {
+		buf.headers["priority"] = "high"
+	}
+	injectTraceContext(ctx, buf.headers)
+
+	metrics := cm.hotMetrics()
+	for _, target := range buf.targets {
+		topic, encoder := target.topic, target.encoder
+		data, err := encoder.Encode(buf.value[:0], topic, tx)
+		if err != nil {
+			return fmt.Errorf("failed to encode transaction for %s: %v", topic, err)
+		}
+		buf.value = data
+		metrics.messageSize(encoder.Format()).Observe(float64(len(data)))
+
+		buf.headers["format"] = encoder.Format()
+		buf.headers["schema_version"] = target.version
+		if err := cm.sink.Publish(ctx, topic, buf.key, data, buf.headers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hotMetrics returns the chain's per-transaction metric children, resolving
+// them on first use
+func (cm *ChainMonitor) hotMetrics() *chainMetrics {
+	if metrics := cm.metrics.Load(); metrics != nil {
+		return metrics
+	}
+	cm.metrics.CompareAndSwap(nil, newChainMetrics(cm.chainName))
+	return cm.metrics.Load()
+}
+
+// chainHeaderValues are the header values a chain's messages share
+type chainHeaderValues struct {
+	id      int64
+	family  string
+	chainID string
+	caip2   string
+}
+
+// chainHeaders returns the chain_id and chain_caip2 header values of tx,
+// formatting them again only when the chain they were cached for changes
+func (cm *ChainMonitor) chainHeaders(tx *Transaction) *chainHeaderValues {
+	values := cm.headerValues.Load()
+	if values == nil || values.id != tx.ChainID || values.family != tx.ChainFamily {
+		values = &chainHeaderValues{
+			id:      tx.ChainID,
+			family:  tx.ChainFamily,
+			chainID: strconv.FormatInt(tx.ChainID, 10),
+			caip2:   caip2(cm.chainName, tx.ChainFamily, tx.ChainID),
+		}
+		cm.headerValues.Store(values)
+	}
+	return values
+}
+
+// timestampText is a formatted timestamp header
+type timestampText struct {
+	seconds int64
+	text    string
+}
+
+// timestampHeader returns the timestamp header value, formatted once per
+// second rather than once per message
+func (cm *ChainMonitor) timestampHeader(seconds int64) string {
+	stamp := cm.timestamp.Load()
+	if stamp == nil || stamp.seconds != seconds {
+		stamp = &timestampText{seconds: seconds, text: strconv.FormatInt(seconds, 10)}
+		cm.timestamp.Store(stamp)
 	}
-	
-	return cm.redisClient.Set(cm.ctx, key, data, 5*time.Minute).Err()
+	return stamp.text
 }
 
-// getBestEndpoint returns the endpoint with the highest health score
+// baseTopic returns the topic every transaction from this chain is published to
+func (cm *ChainMonitor) baseTopic() string {
+	return expandTopic(cm.router.template, cm.chainName, cm.chainID, cm.family)
+}
+
+// getBestEndpoint returns the endpoint with the highest health score after probe penalties
 func (cm *ChainMonitor) getBestEndpoint() string {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
-	
+
 	var bestEndpoint string
 	var bestScore float64
-	
+
 	for endpoint, score := range cm.healthScores {
+		if _, disabled := cm.disabled[endpoint]; disabled {
+			continue
+		}
+		if !cm.breaker.Allowed(endpoint) {
+			continue
+		}
+		score *= cm.probeFactor(endpoint)
 		if score > bestScore {
 			bestScore = score
 			bestEndpoint = endpoint
 		}
 	}
-	
-	if bestScore < 0.5 {
+
+	if bestScore < minHealthyScore {
 		return ""
 	}
-	
+
 	return bestEndpoint
 }
 
@@ -336,7 +1004,7 @@ func (cm *ChainMonitor) getBestEndpoint() string {
 func (cm *ChainMonitor) updateHealthScore(endpoint string, score float64) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
-	
+
 	// Exponential moving average
 	alpha := 0.1
 	if currentScore, exists := cm.healthScores[endpoint]; exists {
@@ -344,15 +1012,15 @@ func (cm *ChainMonitor) updateHealthScore(endpoint string, score float64) {
 	} else {
 		cm.healthScores[endpoint] = score
 	}
-	
-	endpointHealth.WithLabelValues(cm.chainName, endpoint).Set(cm.healthScores[endpoint])
+
+	endpointHealth.WithLabelValues(cm.chainName, endpointLabel(endpoint)).Set(cm.healthScores[endpoint])
 }
 
 // healthCheckLoop periodically checks endpoint health
 func (cm *ChainMonitor) healthCheckLoop() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
-	
+
 	for {
 		select {
 		case <-cm.ctx.Done():
@@ -367,162 +1035,1096 @@ func (cm *ChainMonitor) healthCheckLoop() {
 func (cm *ChainMonitor) performHealthChecks() {
 	for _, endpoint := range cm.endpoints {
 		go func(ep string) {
-			if time.Since(cm.lastSeen[ep]) > 2*time.Minute {
+			cm.mu.RLock()
+			lastSeen := cm.lastSeen[ep]
+			cm.mu.RUnlock()
+			if time.Since(lastSeen) > 2*time.Minute {
 				cm.updateHealthScore(ep, 0.1)
 			}
 		}(endpoint)
 	}
+
+	if cm.family == FamilyEVM && cm.options.ActiveProbes {
+		cm.probeEndpoints()
+	}
+	cm.checkEndpoints()
 }
 
 // IngestionService manages all chain monitors
 type IngestionService struct {
-	config   Config
-	producer *kafka.Producer
-	redis    *redis.Client
-	monitors map[string]*ChainMonitor
-	wg       sync.WaitGroup
+	config     Config
+	sink       Sink
+	sinks      map[string]Sink
+	redis      *redis.Client
+	cache      Cache
+	alerter    *Alerter
+	enrichers  []Enricher
+	encoders   *topicEncoders
+	archivers  []Archiver
+	postgres   *PostgresStore
+	tags       *TagStore
+	watchlist  *Watchlist
+	gasOracle  *GasOracle
+	nonces     *NonceTracker
+	blobs      *BlobMonitor
+	lending    *LiquidationMonitor
+	bridges    *BridgeMonitor
+	drops      *DropMonitor
+	sanctions  *SanctionsScreener
+	labels     *AddressLabeler
+	bundleSim  *BundleSimulator
+	hub        *txHub
+	recent     *recentTxs
+	sseLog     *sseLog
+	shards     *shardCoordinator
+	auth       *authenticator
+	tenants    map[string]*tenantQuota
+	chaos      *chaosInjector
+	slo        *sloTracker
+	tls        *tls.Config
+	admin      *http.Server
+	grpc       *grpc.Server
+	monitors   map[string]Monitor
+	batchers   map[string]*txnBatcher
+	chainSinks *chainSinks
+	canaries   context.CancelFunc
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+	mu         sync.RWMutex
+	reloadMu   sync.Mutex
 }
 
 // NewIngestionService creates a new ingestion service
 func NewIngestionService(config Config) (*IngestionService, error) {
-	// Create Kafka producer
-	producer, err := kafka.NewProducer(&kafka.ConfigMap{
-		"bootstrap.servers": config.KafkaBrokers,
-		"batch.size":        config.BatchSize,
-		"linger.ms":         config.FlushIntervalMS,
-		"compression.type":  "lz4",
-	})
+	// Every RPC client created from here on is held to its provider's quota
+	rpcLimits.Configure(config.RPCLimits)
+
+	// Connect the cache; the Redis client is nil unless Redis is the backend
+	cache, redisClient, err := newCache(config)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("using cache backend", "cache", cache.Name())
+
+	// Create default output sink; chains may override it with <CHAIN>_SINK
+	sink, err := newSink(config.Sink, config, redisClient)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("publishing to sink", "sink", sink.Name())
+
+	// Side-topic events publish through their chain's sink once its monitor runs
+	chainSinks := newChainSinks(sink, config.Leader.Enabled)
+
+	alerter := NewAlerter(100, config.AlertCooldown)
+	for _, transportConfig := range config.AlertTransports {
+		transport, err := newAlertTransport(transportConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s alert transport: %v", transportConfig.Type, err)
+		}
+		alerter.AddTransport(transport, transportConfig.Categories, transportConfig.MinSeverity)
+		slog.Info("alert transport enabled", "transport", transport.Name(), "min_severity", transportConfig.MinSeverity)
+	}
+
+	// Normalization runs first so every enricher sees canonical addresses
+	var enrichers []Enricher
+	if config.Normalization {
+		enrichers = append(enrichers, &Normalizer{})
+	}
+	if config.TokenEnrichment {
+		enrichers = append(enrichers, &TokenTransferEnricher{})
+	}
+	if config.SwapDecoding {
+		enrichers = append(enrichers, &SwapDecoder{})
+	}
+
+	// Tags are shared between instances through Redis
+	var tags *TagStore
+	if redisClient != nil {
+		tags = NewTagStore(redisClient, config.TagTTL)
+		enrichers = append(enrichers, tags)
+	}
+
+	// The tag store replaces tx.Tags, so the watchlist appends after it
+	var watchlist *Watchlist
+	if config.Watchlist.Enabled() {
+		watchlist = NewWatchlist(redisClient, config.Watchlist)
+		enrichers = append(enrichers, watchlist)
+	}
+
+	var sanctions *SanctionsScreener
+	if config.Sanctions.Enabled() {
+		sanctions, err = NewSanctionsScreener(redisClient, config.Sanctions)
+		if err != nil {
+			return nil, err
+		}
+		enrichers = append(enrichers, sanctions)
+		slog.Info("screening transactions against sanctions lists", "action", config.Sanctions.Action)
+	}
+
+	var labels *AddressLabeler
+	if config.Labels.Enabled() {
+		labels, err = NewAddressLabeler(redisClient, config.Labels)
+		if err != nil {
+			return nil, err
+		}
+		enrichers = append(enrichers, labels)
+	}
+
+	if config.MEV.Enabled {
+		enrichers = append(enrichers, NewMEVClassifier(config.MEV))
+	}
+
+	var gasOracle *GasOracle
+	if config.GasOracle.Enabled {
+		gasOracle, err = NewGasOracle(cache, chainSinks, config.GasOracle)
+		if err != nil {
+			return nil, err
+		}
+		enrichers = append(enrichers, gasOracle)
+	}
+
+	var nonces *NonceTracker
+	if config.Nonces.Enabled {
+		nonces = NewNonceTracker(chainSinks, config.Nonces)
+		enrichers = append(enrichers, nonces)
+	}
+
+	if config.Deploys.Enabled {
+		deploys, err := NewDeployMonitor(chainSinks, alerter, config.Deploys)
+		if err != nil {
+			return nil, err
+		}
+		enrichers = append(enrichers, deploys)
+	}
+
+	var blobs *BlobMonitor
+	if config.Blobs.Enabled {
+		blobs = NewBlobMonitor(chainSinks, config.Blobs)
+		enrichers = append(enrichers, blobs)
+	}
+
+	var liquidations *LiquidationMonitor
+	if config.Liquidations.Enabled {
+		liquidations = NewLiquidationMonitor(config.Liquidations)
+		enrichers = append(enrichers, liquidations)
+	}
+	if config.NFT.Enabled {
+		enrichers = append(enrichers, NewNFTMonitor(chainSinks, config.NFT))
+	}
+	if config.Whales.Enabled {
+		whales, err := NewWhaleMonitor(chainSinks, alerter, config.Whales, config.ChainOptions)
+		if err != nil {
+			return nil, err
+		}
+		enrichers = append(enrichers, whales)
+	}
+	var bridges *BridgeMonitor
+	if config.Bridges.Enabled {
+		bridges, err = NewBridgeMonitor(chainSinks, config.Bridges, config.ChainOptions)
+		if err != nil {
+			return nil, err
+		}
+		enrichers = append(enrichers, bridges)
+	}
+	var drops *DropMonitor
+	if config.Drops.Enabled {
+		drops = NewDropMonitor(chainSinks, config.Drops)
+		enrichers = append(enrichers, drops)
+	}
+
+	var recent *recentTxs
+	if config.GraphQL.Enabled {
+		recent = newRecentTxs(config.GraphQL.Recent)
+	}
+
+	var sseEvents *sseLog
+	if config.Streams.SSE {
+		sseEvents = newSSELog(config.Streams.SSEReplay)
+	}
+
+	var shards *shardCoordinator
+	if config.Shards.Enabled {
+		shards = newShardCoordinator(redisClient, config.Shards)
+	}
+
+	var bundleSim *BundleSimulator
+	if config.BundleSim.Enabled() {
+		bundleSim = NewBundleSimulator(cache, config)
+	}
+
+	encoders, err := newTopicEncoders(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Kafka producer: %v", err)
-	}
-	
-	// Create Redis client
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: config.RedisURL,
-	})
-	
-	// Test Redis connection
-	if err := redisClient.Ping(context.Background()).Err(); err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
-	}
-	
+		return nil, fmt.Errorf("failed to create message encoders: %v", err)
+	}
+	for topic, format := range config.TopicFormats {
+		slog.Info("topic format", "topic", topic, "format", format)
+	}
+
+	var archivers []Archiver
+	if config.ClickHouse.DSN != "" {
+		archiver, err := NewClickHouseArchiver(config.ClickHouse)
+		if err != nil {
+			return nil, err
+		}
+		archivers = append(archivers, archiver)
+		slog.Info("archiving transactions to ClickHouse", "table", config.ClickHouse.Table)
+	}
+
+	var postgres *PostgresStore
+	if config.Postgres.DSN != "" {
+		postgres, err = NewPostgresStore(config.Postgres)
+		if err != nil {
+			return nil, err
+		}
+		archivers = append(archivers, postgres)
+		slog.Info("persisting transactions and endpoint health to Postgres")
+	}
+
+	if config.S3Export.Bucket != "" {
+		exporter, err := NewS3Exporter(config.S3Export)
+		if err != nil {
+			return nil, err
+		}
+		archivers = append(archivers, exporter)
+		slog.Info("exporting Parquet archives to S3", "bucket", config.S3Export.Bucket, "prefix", config.S3Export.Prefix, "interval", config.S3Export.Interval)
+	}
+
+	// The admin and gRPC servers share the TLS and authentication settings
+	tlsConfig, err := serverTLSConfig(config.Auth)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := newAuthenticator(config.Auth, config.Streams.Tokens, config.Tenants)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fault injection is for resilience tests and starts with no faults
+	var chaos *chaosInjector
+	if config.ChaosEnabled {
+		chaos = newChaosInjector()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &IngestionService{
-		config:   config,
-		producer: producer,
-		redis:    redisClient,
-		monitors: make(map[string]*ChainMonitor),
+		config:     config,
+		sink:       sink,
+		sinks:      map[string]Sink{sink.Name(): sink},
+		chainSinks: chainSinks,
+		redis:      redisClient,
+		cache:      cache,
+		alerter:    alerter,
+		enrichers:  enrichers,
+		encoders:   encoders,
+		archivers:  archivers,
+		postgres:   postgres,
+		tags:       tags,
+		watchlist:  watchlist,
+		gasOracle:  gasOracle,
+		nonces:     nonces,
+		blobs:      blobs,
+		lending:    liquidations,
+		bridges:    bridges,
+		drops:      drops,
+		sanctions:  sanctions,
+		labels:     labels,
+		bundleSim:  bundleSim,
+		hub:        newTxHub(),
+		recent:     recent,
+		sseLog:     sseEvents,
+		shards:     shards,
+		auth:       auth,
+		tenants:    newTenantQuotas(config.Tenants),
+		chaos:      chaos,
+		slo:        newSLOTracker(config.SLO, chainSinks, alerter),
+		tls:        tlsConfig,
+		monitors:   make(map[string]Monitor),
+		batchers:   make(map[string]*txnBatcher),
+		ctx:        ctx,
+		cancel:     cancel,
 	}, nil
 }
 
 // Start starts the ingestion service
 func (is *IngestionService) Start() error {
-	log.Println("Starting Scorpius Mempool Elite Ingestion Service")
-	
-	// Create monitors for each configured chain
-	chainIDs := map[string]int64{
-		"ethereum": 1,
-		"arbitrum": 42161,
-		"optimism": 10,
-		"base":     8453,
+	slog.Info("starting Scorpius Mempool Elite Ingestion Service")
+
+	// With sharding, chains are split between the instances in the group
+	if is.shards != nil {
+		if err := is.shards.Join(is.ctx); err != nil {
+			return err
+		}
 	}
-	
+
+	// Create monitors for each configured chain
 	for chainName, endpoints := range is.config.ChainEndpoints {
-		chainID, exists := chainIDs[chainName]
-		if !exists {
-			log.Printf("Warning: Unknown chain %s, skipping", chainName)
+		if !is.ownsChain(chainName) {
 			continue
 		}
-		
-		monitor := NewChainMonitor(chainName, chainID, endpoints, is.producer, is.redis)
-		is.monitors[chainName] = monitor
-		
+		if err := is.startMonitor(chainName, endpoints); err != nil {
+			return err
+		}
+	}
+
+	if is.shards != nil {
+		is.updateShardOwned()
+		is.shards.rebalance = is.rebalance
+		// Stop waits for the instance to leave the group before closing Redis
 		is.wg.Add(1)
-		go func(m *ChainMonitor) {
+		go func() {
 			defer is.wg.Done()
-			if err := m.Start(); err != nil {
-				log.Printf("Error starting monitor for %s: %v", m.chainName, err)
+			is.shards.Run(is.ctx)
+		}()
+	}
+
+	slog.Info("started monitoring", "chains", len(is.monitors))
+
+	if err := prometheus.Register(throughputCollector{is}); err != nil {
+		slog.Warn("failed to register throughput metrics", "error", err)
+	}
+
+	if is.tags != nil {
+		go is.tags.Run(is.ctx, is.chainNames, 5*time.Second)
+	}
+	if is.watchlist != nil {
+		go is.watchlist.Run(is.ctx, is.config.Watchlist.Refresh)
+	}
+	if is.gasOracle != nil {
+		go is.gasOracle.Run(is.ctx, is.config.GasOracle.Interval)
+	}
+	if is.nonces != nil {
+		go is.nonces.Run(is.ctx)
+	}
+	if is.sanctions != nil {
+		go is.sanctions.Run(is.ctx)
+	}
+	if is.labels != nil {
+		go is.labels.Run(is.ctx)
+	}
+	if is.chaos != nil {
+		go is.chaos.Run(is.ctx, is.chaosMonitors)
+	}
+	if is.slo != nil {
+		go is.slo.Run(is.ctx)
+	}
+	if is.config.AlertProduceFailures > 0 {
+		go is.watchProduceFailures(is.ctx)
+	}
+	if is.config.Secrets.Enabled() {
+		go secrets.Run(is.ctx, is.config.Secrets.Refresh, func() {
+			if err := is.Reload(); err != nil {
+				slog.Error("failed to reload after secrets changed", "error", err)
+			}
+		})
+	}
+
+	if is.config.MEVShareURL != "" {
+		go NewMEVShareSource(is.config.MEVShareURL, is.config.MEVShareChain, is.chainSinks).Run(is.ctx)
+	}
+
+	if is.config.CanaryInterval > 0 {
+		is.startCanaries()
+	}
+
+	if is.postgres != nil {
+		go is.postgres.RunHealthSnapshots(is.ctx, is.config.Postgres.HealthInterval, is.chainStatuses)
+	}
+
+	if is.recent != nil {
+		go is.recent.Run(is.ctx, is.hub, is.config.Streams.Buffer)
+	}
+	if is.sseLog != nil {
+		go is.sseLog.Run(is.ctx, is.hub, is.config.Streams.Buffer)
+	}
+	if is.config.AdminAddr != "" {
+		is.admin = is.startAdminServer(is.config.AdminAddr)
+	}
+
+	if is.bundleSim != nil {
+		go is.bundleSim.Run(is.ctx)
+	}
+	if is.bundleSim != nil || is.config.Streams.GRPC {
+		server, err := startGRPCServer(is.config.GRPCAddr, is.auth.grpcOptions(is.tls), func(s *grpc.Server) {
+			if is.bundleSim != nil {
+				s.RegisterService(&bundleSimulatorServiceDesc, is.bundleSim)
+			}
+			if is.config.Streams.GRPC {
+				s.RegisterService(&transactionStreamServiceDesc, is)
 			}
-		}(monitor)
+		})
+		if err != nil {
+			return err
+		}
+		is.grpc = server
 	}
-	
-	log.Printf("Started monitoring %d chains", len(is.monitors))
+
+	if path := getEnv("CONFIG_FILE"); path != "" {
+		go is.watchConfig(path)
+	}
+
 	return nil
 }
 
+// startMonitor creates and starts the monitor for a configured chain
+func (is *IngestionService) startMonitor(chainName string, endpoints []string) error {
+	chain, exists := chainRegistry[chainName]
+	if !exists {
+		slog.Warn("unknown chain, skipping", "chain", chainName)
+		return nil
+	}
+
+	is.mu.Lock()
+	monitor, err := is.newMonitor(chain, endpoints)
+	if err != nil {
+		is.mu.Unlock()
+		return fmt.Errorf("failed to create monitor for %s: %v", chainName, err)
+	}
+	is.monitors[chainName] = monitor
+	is.chainSinks.add(monitor.base())
+	is.mu.Unlock()
+
+	is.wg.Add(1)
+	go func() {
+		defer is.wg.Done()
+		if err := monitor.Start(); err != nil {
+			slog.Error("failed to start monitor", "chain", chainName, "error", err)
+		}
+	}()
+	return nil
+}
+
+// stopMonitor drains a chain's monitor and closes its transactional batcher,
+// if any
+func (is *IngestionService) stopMonitor(chainName, reason string) {
+	is.mu.Lock()
+	monitor, ok := is.monitors[chainName]
+	batcher := is.batchers[chainName]
+	delete(is.monitors, chainName)
+	delete(is.batchers, chainName)
+	is.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	// Side-topic events keep going through the chain's sink until it has
+	// drained, and no longer once its batcher closes
+	if batcher == nil {
+		monitor.Drain(reason)
+		is.chainSinks.remove(monitor.base())
+	} else {
+		// A batcher retrying against unreachable brokers must not hold the
+		// drain past its deadline
+		deadline := time.AfterFunc(is.config.Drain.Timeout, batcher.Stop)
+		monitor.Drain(reason)
+		is.chainSinks.remove(monitor.base())
+		batcher.Close()
+		deadline.Stop()
+	}
+	slog.Info("stopped monitor", "chain", chainName)
+}
+
+// chainNames returns the chains currently being monitored
+func (is *IngestionService) chainNames() []string {
+	is.mu.RLock()
+	defer is.mu.RUnlock()
+
+	names := make([]string, 0, len(is.monitors))
+	for chainName := range is.monitors {
+		names = append(names, chainName)
+	}
+	return names
+}
+
+// startCanaries launches end-to-end canary verification across all chain
+// monitors, replacing any previous canary run after a reload
+func (is *IngestionService) startCanaries() {
+	is.mu.Lock()
+	if is.canaries != nil {
+		is.canaries()
+	}
+	ctx, cancel := context.WithCancel(is.ctx)
+	is.canaries = cancel
+
+	chainMonitors := make(map[string]*ChainMonitor)
+	for chainName, monitor := range is.monitors {
+		chainMonitors[chainName] = monitor.base()
+	}
+	is.mu.Unlock()
+
+	// The canary consumer reads back from Kafka; other sinks are verified through Redis only
+	brokers := ""
+	for _, monitor := range chainMonitors {
+		if isKafkaSink(monitor.sink) {
+			brokers = is.config.KafkaBrokers
+		}
+	}
+
+	canaries := NewCanaryMonitor(chainMonitors, brokers, is.cache, is.alerter, is.config.CanaryInterval, is.config.CanarySLO)
+	go func() {
+		if err := canaries.Run(ctx); err != nil {
+			slog.Error("canary monitor failed", "error", err)
+		}
+	}()
+}
+
+// newMonitor creates the monitor implementation for a chain's family
+func (is *IngestionService) newMonitor(chain ChainInfo, endpoints []string) (Monitor, error) {
+	options := is.config.ChainOptions[chain.Name]
+
+	sink, err := is.sinkFor(options.Sink)
+	if err != nil {
+		return nil, err
+	}
+
+	var monitor Monitor
+	switch {
+	case options.IngestMode == IngestModeP2P:
+		p2pMonitor, err := NewP2PMonitor(chain, endpoints, options, sink, is.cache, is.alerter)
+		if err != nil {
+			return nil, err
+		}
+		monitor = p2pMonitor
+	case options.IngestMode == IngestModeSequencer:
+		sequencerMonitor, err := NewSequencerMonitor(chain, endpoints, options, sink, is.cache, is.alerter)
+		if err != nil {
+			return nil, err
+		}
+		monitor = sequencerMonitor
+	case chain.Family == FamilySolana:
+		monitor = NewSolanaMonitor(chain, endpoints, options, sink, is.cache, is.alerter)
+	case chain.Family == FamilyStarknet:
+		monitor = NewStarknetMonitor(chain, endpoints, options, sink, is.cache, is.alerter)
+	case chain.Family == FamilyCosmos:
+		monitor = NewCosmosMonitor(chain, endpoints, options, sink, is.cache, is.alerter)
+	case chain.Family == FamilyTron:
+		monitor = NewTronMonitor(chain, endpoints, options, sink, is.cache, is.alerter)
+	case chain.Family == FamilyTON:
+		monitor = NewTonMonitor(chain, endpoints, options, sink, is.cache, is.alerter)
+	case chain.Family == FamilyUTXO:
+		monitor = NewBitcoinMonitor(chain, endpoints, options, sink, is.cache, is.alerter)
+	default:
+		monitor = NewChainMonitor(chain.Name, chain.ChainID, endpoints, options, sink, is.cache, is.alerter)
+	}
+	base := monitor.base()
+	base.enrichers = is.enrichers
+	base.encoders = is.encoders
+	base.archivers = is.archivers
+	base.hub = is.hub
+	base.drain = is.config.Drain
+
+	router, err := newTopicRouter(options.TopicTemplate, is.config.TopicRoutes)
+	if err != nil {
+		return nil, err
+	}
+	if is.watchlist != nil {
+		router.watchlist = is.config.Watchlist.Topic
+	}
+	if is.config.MEV.Enabled {
+		router.mev = is.config.MEV.Topic
+	}
+	if is.watchlist != nil && options.WatchlistFilter {
+		base.watchFilter = newWatchlistFilter(base, is.watchlist, is.config.Watchlist.Refresh)
+	}
+	router.exprRoutes, err = compileExprRoutes(is.config.ExprRoutes)
+	if err != nil {
+		return nil, err
+	}
+	base.router = router
+
+	base.filter, err = newTxFilter(options)
+	if err != nil {
+		return nil, err
+	}
+	base.simulator, err = newSimulator(options, is.config.Simulation)
+	if err != nil {
+		return nil, err
+	}
+
+	if is.config.MEV.Detection {
+		detector := &mevDetector{monitor: base, topic: is.config.MEV.IncidentTopic}
+		base.blockHandlers = append(base.blockHandlers, detector.HandleBlock)
+	}
+	if is.config.PrivateFlow.Enabled {
+		detector := &privateFlowDetector{monitor: base, topic: is.config.PrivateFlow.Topic}
+		base.blockHandlers = append(base.blockHandlers, detector.HandleBlock)
+	}
+	if is.blobs != nil && options.BeaconURL != "" {
+		fetcher := newBlobSidecarFetcher(base, is.blobs)
+		base.blockHandlers = append(base.blockHandlers, fetcher.HandleBlock)
+	}
+	if options.Indexes {
+		base.blockHandlers = append(base.blockHandlers, base.txCache.IndexBlock)
+	}
+	if is.config.BeaconEvents.Enabled && options.BeaconURL != "" {
+		base.beaconEvents = newBeaconEventSource(base, is.config.BeaconEvents.Topic)
+	}
+	if len(options.BundlerURLs) > 0 {
+		base.userOps = newUserOpSource(base, is.config.UserOps)
+	}
+	if is.lending != nil && options.LendingSubgraph != "" {
+		base.liquidations = is.lending.detector(base)
+		base.blockHandlers = append(base.blockHandlers, base.liquidations.HandleBlock)
+	}
+	if is.bridges != nil {
+		base.blockHandlers = append(base.blockHandlers, is.bridges.blockHandler(base))
+	}
+	if is.drops != nil && options.BlockTracking {
+		base.blockHandlers = append(base.blockHandlers, is.drops.tracker(base).HandleBlock)
+	}
+
+	// Exactly-once chains commit through transactional micro-batches, as do
+	// the topics selected for exactly-once delivery on other Kafka chains
+	exactlyOnceTopics := len(is.config.ExactlyOnceTopics) > 0 && sink.Name() == SinkKafka
+	if options.DeliveryMode == DeliveryExactlyOnce || exactlyOnceTopics {
+		if sink.Name() != SinkKafka {
+			return nil, fmt.Errorf("%s delivery requires the kafka sink", DeliveryExactlyOnce)
+		}
+		txnID := fmt.Sprintf("%s-%s", is.config.TransactionalID, chain.Name)
+		batcher, err := newTxnBatcher(chain.Name, is.config.KafkaBrokers, txnID, is.config.Kafka, options.BatchWindow)
+		if err != nil {
+			return nil, err
+		}
+		is.batchers[chain.Name] = batcher
+		if options.DeliveryMode == DeliveryExactlyOnce {
+			base.sink = batcher
+			slog.Info("using exactly-once delivery", "chain", chain.Name, "batch_window", options.BatchWindow, "transactional_id", txnID)
+		} else {
+			base.sink = newRoutedDeliverySink(sink, batcher, is.config.ExactlyOnceTopics, chain.Name, chain.ChainID, chain.Family)
+			slog.Info("using exactly-once delivery for selected topics", "chain", chain.Name, "topics", is.config.ExactlyOnceTopics, "batch_window", options.BatchWindow, "transactional_id", txnID)
+		}
+	}
+
+	// With leader election only the chain's leader publishes
+	if is.config.Leader.Enabled {
+		base.leader = newLeaderLease(chain.Name, is.redis, is.config.Leader, base.logger)
+		base.sink = &fencedSink{sink: base.sink, lease: base.leader}
+		// The lease is held until the chain has drained, and Stop waits for
+		// it to be released before closing Redis
+		is.wg.Add(1)
+		go func() {
+			defer is.wg.Done()
+			base.leader.Run(base.deliverCtx)
+		}()
+	}
+
+	base.slo = is.slo
+
+	// Injected produce delays apply to everything the chain publishes
+	if is.chaos != nil {
+		base.chaos = is.chaos
+		base.sink = &chaosSink{Sink: base.sink, chaos: is.chaos, chain: chain.Name}
+	}
+
+	return monitor, nil
+}
+
+// sinkFor returns the named sink, creating it on first use
+func (is *IngestionService) sinkFor(name string) (Sink, error) {
+	if sink, ok := is.sinks[name]; ok {
+		return sink, nil
+	}
+
+	sink, err := newSink(name, is.config, is.redis)
+	if err != nil {
+		return nil, err
+	}
+	is.sinks[name] = sink
+	slog.Info("publishing to sink", "sink", sink.Name())
+	return sink, nil
+}
+
 // Stop stops the ingestion service
 func (is *IngestionService) Stop() {
-	log.Println("Stopping Scorpius Mempool Elite Ingestion Service")
-	
-	for _, monitor := range is.monitors {
-		monitor.Stop()
+	slog.Info("stopping Scorpius Mempool Elite Ingestion Service")
+	is.cancel()
+
+	if is.admin != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		is.admin.Shutdown(ctx)
+		cancel()
+	}
+
+	if is.grpc != nil {
+		stopped := make(chan struct{})
+		go func() {
+			is.grpc.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			is.grpc.Stop()
+		}
+	}
+
+	// Stop reading, publish what was already read and mark each chain's end.
+	// Holding reloadMu waits out a reload or rebalance already under way.
+	is.reloadMu.Lock()
+	is.mu.RLock()
+	monitors := make(map[string]Monitor, len(is.monitors))
+	for chainName, monitor := range is.monitors {
+		monitors[chainName] = monitor
 	}
-	
+	// A batcher retrying against unreachable brokers must not hold the
+	// drain past its deadline
+	for _, batcher := range is.batchers {
+		deadline := time.AfterFunc(is.config.Drain.Timeout, batcher.Stop)
+		defer deadline.Stop()
+	}
+	is.mu.RUnlock()
+	drainMonitors(monitors, DrainShutdown)
+	is.reloadMu.Unlock()
+
 	is.wg.Wait()
-	
-	is.producer.Flush(15 * 1000) // 15 seconds
-	is.producer.Close()
-	is.redis.Close()
-	
-	log.Println("Ingestion service stopped")
+
+	for _, batcher := range is.batchers {
+		batcher.Close()
+	}
+
+	for _, archiver := range is.archivers {
+		archiver.Close()
+	}
+	for _, sink := range is.sinks {
+		sink.Close()
+	}
+	is.cache.Close()
+	is.alerter.Close()
+
+	slog.Info("ingestion service stopped")
 }
 
 // loadConfig loads configuration from environment variables
 func loadConfig() Config {
 	config := Config{
-		KafkaBrokers:    getEnvOrDefault("KAFKA_BROKERS", "localhost:9092"),
-		RedisURL:        getEnvOrDefault("REDIS_URL", "redis://localhost:6379"),
-		BatchSize:       1000,
-		FlushIntervalMS: 100,
-		MaxConnections:  10,
-		LogLevel:        getEnvOrDefault("LOG_LEVEL", "info"),
-	}
-	
+		KafkaBrokers:           getEnvOrDefault("KAFKA_BROKERS", "localhost:9092"),
+		RedisURL:               getEnvOrDefault("REDIS_URL", "redis://localhost:6379"),
+		CacheBackend:           getEnvOrDefault("CACHE_BACKEND", CacheRedis),
+		CacheSize:              getEnvInt("CACHE_MEMORY_SIZE", 100000),
+		Kafka:                  loadKafkaConfig(),
+		Spool:                  loadSpoolConfig(),
+		MaxConnections:         10,
+		TransactionalID:        getEnv("KAFKA_TRANSACTIONAL_ID"),
+		LogLevel:               getEnvOrDefault("LOG_LEVEL", "info"),
+		LogFormat:              getEnvOrDefault("LOG_FORMAT", LogFormatJSON),
+		AdminAddr:              getEnvOrDefault("ADMIN_ADDR", ":8080"),
+		GRPCAddr:               getEnvOrDefault("GRPC_ADDR", ":9090"),
+		TokenEnrichment:        getEnvBool("ENRICH_TOKEN_TRANSFERS", true),
+		SwapDecoding:           getEnvBool("ENRICH_SWAPS", true),
+		Normalization:          getEnvBool("NORMALIZE", true),
+		AlertTransports:        loadAlertTransports(),
+		TagTTL:                 getEnvDuration("TAG_TTL", 7*24*time.Hour),
+		MEVShareURL:            getEnv("MEV_SHARE_URL"),
+		MEVShareChain:          getEnvOrDefault("MEV_SHARE_CHAIN", "ethereum"),
+		CanaryInterval:         getEnvDuration("CANARY_INTERVAL", 0),
+		CanarySLO:              getEnvDuration("CANARY_SLO", 10*time.Second),
+		MessageFormat:          getEnvOrDefault("MESSAGE_FORMAT", FormatJSON),
+		SchemaRegistryURL:      getEnv("SCHEMA_REGISTRY_URL"),
+		SchemaRegistryUser:     getEnv("SCHEMA_REGISTRY_USERNAME"),
+		SchemaRegistryPassword: getEnv("SCHEMA_REGISTRY_PASSWORD"),
+		TopicFormats:           parseKeyValues(getEnv("TOPIC_FORMATS")),
+		Envelope:               loadEnvelopeConfig(),
+		Sink:                   getEnvOrDefault("SINK", SinkKafka),
+		NATSURL:                getEnvOrDefault("NATS_URL", "nats://localhost:4222"),
+		NATSSubjectPrefix:      getEnvOrDefault("NATS_SUBJECT_PREFIX", "scorpius"),
+		NATSStream:             getEnvOrDefault("NATS_STREAM", "SCORPIUS"),
+		NATSCredentials:        getEnv("NATS_CREDENTIALS"),
+		Kinesis:                loadKinesisConfig(),
+		PubSub:                 loadPubSubConfig(),
+		RedisStreamPrefix:      getEnvOrDefault("REDIS_STREAM_PREFIX", "stream:"),
+		RedisStreamMaxLen:      int64(getEnvInt("REDIS_STREAM_MAXLEN", 100000)),
+		ClickHouse:             loadClickHouseConfig(),
+		Postgres:               loadPostgresConfig(),
+		S3Export:               loadS3ExportConfig(),
+		Tracing:                loadTracingConfig(),
+		Watchlist:              loadWatchlistConfig(),
+		MEV:                    loadMEVConfig(),
+		GasOracle:              loadGasOracleConfig(),
+		Nonces:                 loadNonceConfig(),
+		PrivateFlow:            loadPrivateFlowConfig(),
+		Sanctions:              loadSanctionsConfig(),
+		Deploys:                loadDeployConfig(),
+		Blobs:                  loadBlobConfig(),
+		BeaconEvents:           loadBeaconEventConfig(),
+		UserOps:                loadUserOpConfig(),
+		Liquidations:           loadLiquidationConfig(),
+		NFT:                    loadNFTConfig(),
+		Whales:                 loadWhaleConfig(),
+		Labels:                 loadLabelConfig(),
+		Bridges:                loadBridgeConfig(),
+		Drops:                  loadDropConfig(),
+		Simulation:             loadSimulationConfig(),
+		BundleSim:              loadBundleSimConfig(),
+		Streams:                loadStreamConfig(),
+		GraphQL:                loadGraphQLConfig(),
+		Leader:                 loadLeaderConfig(),
+		Shards:                 loadShardConfig(),
+		Drain:                  loadDrainConfig(),
+		RPCLimits:              loadRPCLimitConfig(),
+		Auth:                   loadAuthConfig(),
+		Tenants:                loadTenantConfigs(),
+		SLO:                    loadSLOConfig(),
+		AlertCooldown:          getEnvDuration("ALERT_COOLDOWN", 5*time.Minute),
+		AlertProduceFailures:   getEnvInt("ALERT_PRODUCE_FAILURES_PER_MIN", 100),
+		DryRun:                 getEnvBool("DRY_RUN", false),
+		ChaosEnabled:           getEnvBool("CHAOS_ENABLED", false),
+		TopicTemplate:          getEnvOrDefault("TOPIC_TEMPLATE", defaultTopicTemplate),
+		TopicRoutes:            parseKeyValues(getEnv("TOPIC_ROUTES")),
+		ExprRoutes:             parseExprRoutes(getEnv("EXPR_ROUTES")),
+		ExactlyOnceTopics:      splitNonEmpty(getEnv("EXACTLY_ONCE_TOPICS")),
+		KafkaIdempotent:        getEnvBool("KAFKA_IDEMPOTENT", true),
+		KafkaDLQTopic:          getEnvOrDefault("KAFKA_DLQ_TOPIC", "tx_dlq"),
+		KafkaDeliveryRetries:   getEnvInt("KAFKA_DELIVERY_RETRIES", 3),
+		KafkaRetryBackoff:      getEnvDuration("KAFKA_RETRY_BACKOFF", 500*time.Millisecond),
+		KafkaHealthInterval:    getEnvDuration("KAFKA_HEALTH_INTERVAL", 10*time.Second),
+		KafkaHealthTimeout:     getEnvDuration("KAFKA_HEALTH_TIMEOUT", 5*time.Second),
+		KafkaMirrors:           parseKafkaMirrors(getEnv("KAFKA_MIRRORS")),
+		EndpointStrategy:       getEnvOrDefault("ENDPOINT_STRATEGY", StrategyBest),
+	}
+
 	// Parse chain endpoints
 	config.ChainEndpoints = make(map[string][]string)
-	
-	if ethEndpoints := os.Getenv("ETHEREUM_RPC_URLS"); ethEndpoints != "" {
-		config.ChainEndpoints["ethereum"] = strings.Split(ethEndpoints, ",")
-	}
-	if arbEndpoints := os.Getenv("ARBITRUM_RPC_URLS"); arbEndpoints != "" {
-		config.ChainEndpoints["arbitrum"] = strings.Split(arbEndpoints, ",")
+
+	for chainName, chain := range chainRegistry {
+		if endpoints := getEnv(chain.endpointsSetting()); endpoints != "" {
+			config.ChainEndpoints[chainName] = strings.Split(endpoints, ",")
+		}
 	}
-	if opEndpoints := os.Getenv("OPTIMISM_RPC_URLS"); opEndpoints != "" {
-		config.ChainEndpoints["optimism"] = strings.Split(opEndpoints, ",")
+
+	// Chains in p2p mode peer with the enode URLs in <CHAIN>_P2P_NODES, and
+	// chains in sequencer mode read the feeds in <CHAIN>_SEQUENCER_URLS,
+	// instead of RPC endpoints
+	for chainName := range chainRegistry {
+		prefix := strings.ToUpper(chainName) + "_"
+		switch getEnv(prefix + "INGEST_MODE") {
+		case IngestModeP2P:
+			config.ChainEndpoints[chainName] = splitNonEmpty(getEnv(prefix + "P2P_NODES"))
+		case IngestModeSequencer:
+			config.ChainEndpoints[chainName] = splitNonEmpty(getEnv(prefix + "SEQUENCER_URLS"))
+		}
 	}
-	if baseEndpoints := os.Getenv("BASE_RPC_URLS"); baseEndpoints != "" {
-		config.ChainEndpoints["base"] = strings.Split(baseEndpoints, ",")
+
+	// Per-chain options, with <CHAIN>_ prefixed overrides
+	warmup := getEnvDuration("WARMUP_DURATION", 30*time.Second)
+	subscriptionMode := getEnvOrDefault("SUBSCRIPTION_MODE", SubscriptionFull)
+	hydrationWorkers := getEnvInt("HYDRATION_WORKERS", 16)
+	deliveryMode := getEnvOrDefault("DELIVERY_MODE", DeliveryAtLeastOnce)
+	batchWindow := getEnvDuration("TXN_BATCH_WINDOW", 50*time.Millisecond)
+	verifyChainID := getEnvBool("VERIFY_CHAIN_ID", true)
+	bloxrouteAuth := getEnv("BLOXROUTE_AUTH_HEADER")
+	bloxrouteStream := getEnvOrDefault("BLOXROUTE_STREAM", "newTxs")
+	dedupTTL := getEnvDuration("DEDUP_TTL", 10*time.Minute)
+	cacheTTL := getEnvDuration("CACHE_TTL", 5*time.Minute)
+	cacheBatch := getEnvInt("CACHE_BATCH_SIZE", 100)
+	cacheFlush := getEnvDuration("CACHE_FLUSH_INTERVAL", 10*time.Millisecond)
+	indexes := getEnvBool("CACHE_INDEXES", false)
+	indexMax := getEnvInt("CACHE_INDEX_MAX", 1000)
+	indexTTL := getEnvDuration("CACHE_INDEX_TTL", time.Hour)
+	queueCapacity := getEnvInt("QUEUE_CAPACITY", 10000)
+	queuePolicy := getEnvOrDefault("QUEUE_POLICY", QueuePark)
+	queueParkTimeout := getEnvDuration("QUEUE_PARK_TIMEOUT", time.Second)
+	workers := getEnvInt("WORKERS", 4)
+	backoffBase := getEnvDuration("RECONNECT_BACKOFF_BASE", time.Second)
+	backoffMax := getEnvDuration("RECONNECT_BACKOFF_MAX", time.Minute)
+	pingInterval := getEnvDuration("WS_PING_INTERVAL", 20*time.Second)
+	readTimeout := getEnvDuration("WS_READ_TIMEOUT", time.Minute)
+	activeProbes := getEnvBool("ACTIVE_PROBES", true)
+	maxBlockLag := getEnvInt("MAX_BLOCK_LAG", 3)
+	circuitFailures := getEnvInt("CIRCUIT_FAILURES", 5)
+	circuitCooldown := getEnvDuration("CIRCUIT_COOLDOWN", 30*time.Second)
+	filterMinValue := getEnv("FILTER_MIN_VALUE")
+	filterGasPrice := getEnv("FILTER_MIN_GAS_PRICE")
+	filterMinTip := getEnv("FILTER_MIN_PRIORITY_FEE")
+	filterCreation := getEnvBool("FILTER_CONTRACT_CREATION_ONLY", false)
+	filterSelectors := getEnv("FILTER_SELECTORS")
+	filterAction := getEnvOrDefault("FILTER_ACTION", FilterDrop)
+	filterTopic := getEnvOrDefault("FILTER_TOPIC", "tx_lowpriority.{chain}")
+	filterExpr := getEnv("FILTER_EXPR")
+	blockTracking := getEnvBool("BLOCK_TRACKING", false)
+	blockPoll := getEnvDuration("BLOCK_POLL_INTERVAL", 2*time.Second)
+	blockConfirms := getEnvInt("BLOCK_CONFIRMATIONS", 2)
+	reorgAlertDepth := getEnvInt("ALERT_REORG_DEPTH", 2)
+	simulationExpr := getEnvOrDefault("SIMULATION_EXPR", "value >= 1e18")
+	cosmosSource := getEnvOrDefault("COSMOS_SOURCE", CosmosSourceEvents)
+	cosmosPoll := getEnvDuration("COSMOS_MEMPOOL_POLL", time.Second)
+	tronAPIKey := getEnv("TRON_API_KEY")
+	tronPoll := getEnvDuration("TRON_PENDING_POLL", time.Second)
+	sequencerPoll := getEnvDuration("SEQUENCER_POLL_INTERVAL", 250*time.Millisecond)
+	config.ChainOptions = make(map[string]ChainOptions)
+	for chainName := range config.ChainEndpoints {
+		prefix := strings.ToUpper(chainName) + "_"
+		subscriptionMode, blockPoll, blockConfirms := chainRegistry[chainName].withPresets(subscriptionMode, blockPoll, blockConfirms)
+		config.ChainOptions[chainName] = ChainOptions{
+			WarmupDuration:   getEnvDuration(prefix+"WARMUP_DURATION", warmup),
+			SubscriptionMode: getEnvOrDefault(prefix+"SUBSCRIPTION_MODE", subscriptionMode),
+			EndpointModes:    parseKeyValues(getEnv(prefix + "ENDPOINT_SUBSCRIPTIONS")),
+			WatchlistFilter:  getEnvBool(prefix+"SUBSCRIPTION_WATCHLIST", false),
+			HydrationWorkers: getEnvInt(prefix+"HYDRATION_WORKERS", hydrationWorkers),
+			HydrationURL:     getEnv(prefix + "HYDRATION_URL"),
+			Commitment:       getEnv(prefix + "COMMITMENT"),
+			LogsMentions:     splitNonEmpty(getEnv(prefix + "LOGS_MENTIONS")),
+			ZMQTopics:        splitNonEmpty(getEnv(prefix + "ZMQ_TOPICS")),
+			Network:          getEnvOrDefault(prefix+"NETWORK", "mainnet"),
+			CosmosSource:     getEnvOrDefault(prefix+"COSMOS_SOURCE", cosmosSource),
+			CosmosPoll:       getEnvDuration(prefix+"COSMOS_MEMPOOL_POLL", cosmosPoll),
+			TronAPIKey:       getEnvOrDefault(prefix+"TRON_API_KEY", tronAPIKey),
+			TronPoll:         getEnvDuration(prefix+"TRON_PENDING_POLL", tronPoll),
+			TonAccounts:      splitNonEmpty(getEnv(prefix + "TON_ACCOUNTS")),
+			SequencerPoll:    getEnvDuration(prefix+"SEQUENCER_POLL_INTERVAL", sequencerPoll),
+			SequencerEngine:  getEnv(prefix + "SEQUENCER_ENGINE_URL"),
+			DeliveryMode:     getEnvOrDefault(prefix+"DELIVERY_MODE", deliveryMode),
+			BatchWindow:      getEnvDuration(prefix+"TXN_BATCH_WINDOW", batchWindow),
+			VerifyChainID:    getEnvBool(prefix+"VERIFY_CHAIN_ID", verifyChainID),
+			BloxrouteAuth:    getEnvOrDefault(prefix+"BLOXROUTE_AUTH_HEADER", bloxrouteAuth),
+			BloxrouteStream:  getEnvOrDefault(prefix+"BLOXROUTE_STREAM", bloxrouteStream),
+			IngestMode:       getEnvOrDefault(prefix+"INGEST_MODE", IngestModeRPC),
+			P2PNodeKey:       getEnv(prefix + "P2P_NODE_KEY"),
+			P2PMaxPeers:      getEnvInt(prefix+"P2P_MAX_PEERS", 50),
+			P2PDiscovery:     getEnvBool(prefix+"P2P_DISCOVERY", false),
+			P2PListenAddr:    getEnvOrDefault(prefix+"P2P_LISTEN_ADDR", ":30303"),
+			Sink:             getEnvOrDefault(prefix+"SINK", config.Sink),
+			TopicTemplate:    getEnvOrDefault(prefix+"TOPIC_TEMPLATE", config.TopicTemplate),
+			DedupTTL:         getEnvDuration(prefix+"DEDUP_TTL", dedupTTL),
+			CacheTTL:         getEnvDuration(prefix+"CACHE_TTL", cacheTTL),
+			CacheBatch:       getEnvInt(prefix+"CACHE_BATCH_SIZE", cacheBatch),
+			CacheFlush:       getEnvDuration(prefix+"CACHE_FLUSH_INTERVAL", cacheFlush),
+			Indexes:          getEnvBool(prefix+"CACHE_INDEXES", indexes),
+			IndexMax:         getEnvInt(prefix+"CACHE_INDEX_MAX", indexMax),
+			IndexTTL:         getEnvDuration(prefix+"CACHE_INDEX_TTL", indexTTL),
+			QueueCapacity:    getEnvInt(prefix+"QUEUE_CAPACITY", queueCapacity),
+			QueuePolicy:      getEnvOrDefault(prefix+"QUEUE_POLICY", queuePolicy),
+			QueueParkTimeout: getEnvDuration(prefix+"QUEUE_PARK_TIMEOUT", queueParkTimeout),
+			Workers:          getEnvInt(prefix+"WORKERS", workers),
+			BackoffBase:      getEnvDuration(prefix+"RECONNECT_BACKOFF_BASE", backoffBase),
+			BackoffMax:       getEnvDuration(prefix+"RECONNECT_BACKOFF_MAX", backoffMax),
+			PingInterval:     getEnvDuration(prefix+"WS_PING_INTERVAL", pingInterval),
+			ReadTimeout:      getEnvDuration(prefix+"WS_READ_TIMEOUT", readTimeout),
+			ActiveProbes:     getEnvBool(prefix+"ACTIVE_PROBES", activeProbes),
+			MaxBlockLag:      uint64(getEnvInt(prefix+"MAX_BLOCK_LAG", maxBlockLag)),
+			EndpointStrategy: getEnvOrDefault(prefix+"ENDPOINT_STRATEGY", config.EndpointStrategy),
+			CircuitFailures:  getEnvInt(prefix+"CIRCUIT_FAILURES", circuitFailures),
+			CircuitCooldown:  getEnvDuration(prefix+"CIRCUIT_COOLDOWN", circuitCooldown),
+			LogLevel:         getEnv(prefix + "LOG_LEVEL"),
+			FilterMinValue:   getEnvOrDefault(prefix+"FILTER_MIN_VALUE", filterMinValue),
+			FilterGasPrice:   getEnvOrDefault(prefix+"FILTER_MIN_GAS_PRICE", filterGasPrice),
+			FilterMinTip:     getEnvOrDefault(prefix+"FILTER_MIN_PRIORITY_FEE", filterMinTip),
+			FilterCreation:   getEnvBool(prefix+"FILTER_CONTRACT_CREATION_ONLY", filterCreation),
+			FilterSelectors:  splitNonEmpty(getEnvOrDefault(prefix+"FILTER_SELECTORS", filterSelectors)),
+			FilterAction:     getEnvOrDefault(prefix+"FILTER_ACTION", filterAction),
+			FilterTopic:      getEnvOrDefault(prefix+"FILTER_TOPIC", filterTopic),
+			FilterExpr:       getEnvOrDefault(prefix+"FILTER_EXPR", filterExpr),
+			BlockTracking:    getEnvBool(prefix+"BLOCK_TRACKING", blockTracking),
+			BlockPoll:        getEnvDuration(prefix+"BLOCK_POLL_INTERVAL", blockPoll),
+			BlockConfirms:    getEnvInt(prefix+"BLOCK_CONFIRMATIONS", blockConfirms),
+			BlockURL:         getEnv(prefix + "BLOCK_URL"),
+			BeaconURL:        getEnv(prefix + "BEACON_URL"),
+			BundlerURLs:      splitNonEmpty(getEnv(prefix + "BUNDLER_URLS")),
+			LendingSubgraph:  getEnv(prefix + "LENDING_SUBGRAPH"),
+			PriceFeeds:       parseKeyValues(getEnv(prefix + "PRICE_FEEDS")),
+			Stablecoins:      parseKeyValues(getEnv(prefix + "STABLECOINS")),
+			Bridges:          parseKeyValues(getEnv(prefix + "BRIDGES")),
+			ReorgAlertDepth:  getEnvInt(prefix+"ALERT_REORG_DEPTH", reorgAlertDepth),
+			SimulationURL:    getEnv(prefix + "SIMULATION_URL"),
+			SimulationExpr:   getEnvOrDefault(prefix+"SIMULATION_EXPR", simulationExpr),
+		}
 	}
-	
+
 	return config
 }
 
+// instanceID derives an instance ID from the hostname. It is not
+// used as the transactional.id: a replacement pod gets a new hostname and
+// would never fence the crashed instance's transaction.
+func instanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "scorpius-ingestion"
+	}
+	return "scorpius-ingestion-" + hostname
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+	if value := getEnv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
 
+// splitNonEmpty splits a comma-separated list, dropping empty entries
+func splitNonEmpty(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseKeyValues parses a comma-separated list of key=value pairs
+func parseKeyValues(value string) map[string]string {
+	pairs := make(map[string]string)
+	for _, item := range splitNonEmpty(value) {
+		key, val, ok := strings.Cut(item, "=")
+		if !ok {
+			slog.Warn("ignoring malformed entry, expected key=value", "entry", item)
+			continue
+		}
+		pairs[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return pairs
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := getEnv(key); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			invalidSetting(key, value, "integer", defaultValue)
+			return defaultValue
+		}
+		return parsed
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := getEnv(key); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			invalidSetting(key, value, "duration", defaultValue)
+			return defaultValue
+		}
+		return parsed
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := getEnv(key); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			invalidSetting(key, value, "number", defaultValue)
+			return defaultValue
+		}
+		return parsed
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := getEnv(key); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			invalidSetting(key, value, "boolean", defaultValue)
+			return defaultValue
+		}
+		return parsed
+	}
+	return defaultValue
+}
+
 func main() {
-	// Load configuration
-	config := loadConfig()
-	
-	// Create ingestion service
-	service, err := NewIngestionService(config)
-	if err != nil {
-		log.Fatalf("Failed to create ingestion service: %v", err)
-	}
-	
-	// Start service
-	if err := service.Start(); err != nil {
-		log.Fatalf("Failed to start service: %v", err)
-	}
-	
-	// Wait for shutdown signal
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
-	
-	// Graceful shutdown
-	service.Stop()
+	os.Exit(runCLI(os.Args[1:]))
 }
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// MEV opportunity kinds a pending transaction may expose
+const (
+	MEVSandwich = "sandwich"
+	MEVBackrun  = "backrun"
+)
+
+var mevCandidates = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_mev_candidates_total",
+		Help: "Pending transactions classified as MEV candidates, by protocol",
+	},
+	[]string{"chain", "protocol"},
+)
+
+// MEVClassification describes why a pending transaction looks like an MEV target
+type MEVClassification struct {
+	Kinds      []string `json:"kinds"`
+	Protocol   string   `json:"protocol"`
+	Method     string   `json:"method,omitempty"`
+	Confidence float64  `json:"confidence"`
+	Reasons    []string `json:"reasons"`
+}
+
+// MEVConfig configures the MEV pre-classifier and the confirmed-block
+// sandwich and frontrun detector
+type MEVConfig struct {
+	Enabled       bool
+	Topic         string
+	LargeSwap     string
+	MinConfidence float64
+	Contracts     map[string]string
+	Detection     bool
+	IncidentTopic string
+}
+
+// loadMEVConfig reads MEV_* settings
+func loadMEVConfig() MEVConfig {
+	return MEVConfig{
+		Enabled:       getEnvBool("MEV_CLASSIFIER", false),
+		Topic:         getEnvOrDefault("MEV_TOPIC", "mev_candidates"),
+		LargeSwap:     getEnvOrDefault("MEV_LARGE_SWAP_VALUE", "10e18"),
+		MinConfidence: getEnvFloat("MEV_MIN_CONFIDENCE", 0.5),
+		Contracts:     parseKeyValues(getEnv("MEV_CONTRACTS")),
+		Detection:     getEnvBool("MEV_DETECTION", false),
+		IncidentTopic: getEnvOrDefault("MEV_INCIDENT_TOPIC", "mev_incidents"),
+	}
+}
+
+// dexMethod describes a swap entry point. minOutArg is the ABI word holding
+// the caller's minimum output, or -1 when the method has none.
+type dexMethod struct {
+	protocol   string
+	method     string
+	exactInput bool
+	aggregator bool
+	minOutArg  int
+}
+
+var dexSelectors = map[string]dexMethod{
+	// Uniswap V2 and forks (SushiSwap, PancakeSwap, ...)
+	"38ed1739": {protocol: "uniswap_v2", method: "swapExactTokensForTokens", exactInput: true, minOutArg: 1},
+	"7ff36ab5": {protocol: "uniswap_v2", method: "swapExactETHForTokens", exactInput: true, minOutArg: 0},
+	"18cbafe5": {protocol: "uniswap_v2", method: "swapExactTokensForETH", exactInput: true, minOutArg: 1},
+	"5c11d795": {protocol: "uniswap_v2", method: "swapExactTokensForTokensSupportingFeeOnTransferTokens", exactInput: true, minOutArg: 1},
+	"b6f9de95": {protocol: "uniswap_v2", method: "swapExactETHForTokensSupportingFeeOnTransferTokens", exactInput: true, minOutArg: 0},
+	"791ac947": {protocol: "uniswap_v2", method: "swapExactTokensForETHSupportingFeeOnTransferTokens", exactInput: true, minOutArg: 1},
+	"8803dbee": {protocol: "uniswap_v2", method: "swapTokensForExactTokens", minOutArg: -1},
+	"fb3bdb41": {protocol: "uniswap_v2", method: "swapETHForExactTokens", minOutArg: -1},
+	"4a25d94a": {protocol: "uniswap_v2", method: "swapTokensForExactETH", minOutArg: -1},
+
+	// Uniswap V3 SwapRouter and SwapRouter02
+	"414bf389": {protocol: "uniswap_v3", method: "exactInputSingle", exactInput: true, minOutArg: 6},
+	"c04b8d59": {protocol: "uniswap_v3", method: "exactInput", exactInput: true, minOutArg: 5},
+	"db3e2198": {protocol: "uniswap_v3", method: "exactOutputSingle", minOutArg: -1},
+	"f28c0498": {protocol: "uniswap_v3", method: "exactOutput", minOutArg: -1},
+	"04e45aaf": {protocol: "uniswap_v3", method: "exactInputSingle", exactInput: true, minOutArg: 5},
+	"b858183f": {protocol: "uniswap_v3", method: "exactInput", exactInput: true, minOutArg: 4},
+	"472b43f3": {protocol: "uniswap_v3", method: "swapExactTokensForTokens", exactInput: true, minOutArg: 1},
+	"ac9650d8": {protocol: "uniswap_v3", method: "multicall", minOutArg: -1},
+	"5ae401dc": {protocol: "uniswap_v3", method: "multicall", minOutArg: -1},
+
+	// Uniswap Universal Router
+	"3593564c": {protocol: "uniswap_universal", method: "execute", minOutArg: -1},
+	"24856bc3": {protocol: "uniswap_universal", method: "execute", minOutArg: -1},
+
+	// Curve pools
+	"3df02124": {protocol: "curve", method: "exchange", exactInput: true, minOutArg: 3},
+	"a6417ed6": {protocol: "curve", method: "exchange_underlying", exactInput: true, minOutArg: 3},
+
+	// Balancer V2 vault
+	"52bbbe29": {protocol: "balancer", method: "swap", minOutArg: -1},
+
+	// Aggregators route through several pools, leaving backrun opportunities
+	"12aa3caf": {protocol: "1inch", method: "swap", aggregator: true, minOutArg: -1},
+	"0502b1c5": {protocol: "1inch", method: "unoswap", aggregator: true, minOutArg: -1},
+	"e449022e": {protocol: "1inch", method: "uniswapV3Swap", aggregator: true, minOutArg: -1},
+	"415565b0": {protocol: "0x", method: "transformERC20", aggregator: true, minOutArg: -1},
+}
+
+// dexContracts are well-known Ethereum mainnet routers; MEV_CONTRACTS adds more
+var dexContracts = map[string]string{
+	"0x7a250d5630b4cf539739df2c5dacb4c659f2488d": "uniswap_v2_router",
+	"0xe592427a0aece92de3edee1f18e0157c05861564": "uniswap_v3_router",
+	"0x68b3465833fb72a70ecdf485e0e4c7bd8665fc45": "uniswap_swap_router02",
+	"0x3fc91a3afd70395cd496c647d5a6cc9d4b2b7fad": "uniswap_universal_router",
+	"0xef1c6e67703c7bd7107eed8303fbe6ec2554bf6b": "uniswap_universal_router",
+	"0xd9e1ce17f2641f24ae83637ab66a2cca9c378b9f": "sushiswap_router",
+	"0x1111111254eeb25477b68fb85ed929f73a960582": "1inch_v5",
+	"0x1111111254fb6c44bac0bed2854e76f90643097d": "1inch_v4",
+	"0xdef1c0ded9bec7f1a1670819833240f027b25eff": "0x_exchange_proxy",
+	"0xdef171fe48cf0115b1d80b88dc8eab59176fee57": "paraswap_v5",
+	"0xba12222222228d8ba445958a75a0704d566bf2c8": "balancer_vault",
+}
+
+// MEVClassifier flags pending swaps that searchers are likely to sandwich or
+// backrun. Confidence is a heuristic score: a known swap selector, a known
+// router, a zero minimum output and a large native value each add to it.
+type MEVClassifier struct {
+	largeSwap     *big.Int
+	minConfidence float64
+	contracts     map[string]string
+}
+
+// NewMEVClassifier creates a classifier from config
+func NewMEVClassifier(config MEVConfig) *MEVClassifier {
+	largeSwap, ok := parseWei(config.LargeSwap)
+	if !ok {
+		largeSwap, _ = parseWei("10e18")
+	}
+
+	contracts := make(map[string]string, len(dexContracts)+len(config.Contracts))
+	for address, name := range dexContracts {
+		contracts[address] = name
+	}
+	for address, name := range config.Contracts {
+		contracts[strings.ToLower(address)] = name
+	}
+
+	return &MEVClassifier{
+		largeSwap:     largeSwap,
+		minConfidence: config.MinConfidence,
+		contracts:     contracts,
+	}
+}
+
+// Name returns the enricher name
+func (c *MEVClassifier) Name() string {
+	return "mev"
+}
+
+// Enrich attaches an MEVClassification to likely MEV targets
+func (c *MEVClassifier) Enrich(tx *Transaction) {
+	if tx.ChainFamily != FamilyEVM || tx.To == "" || tx.Canary {
+		return
+	}
+
+	selector, args, _ := splitCalldata(tx.Data)
+	method, isSwap := dexSelectors[selector]
+	contract, isRouter := c.contracts[strings.ToLower(tx.To)]
+	if !isSwap && !isRouter {
+		return
+	}
+
+	class := &MEVClassification{Protocol: method.protocol, Method: method.method}
+	if !isSwap {
+		class.Protocol = contract
+	}
+
+	kinds := make(map[string]bool)
+	if isSwap {
+		class.Confidence += 0.4
+		class.Reasons = append(class.Reasons, "swap_selector")
+		if method.exactInput {
+			kinds[MEVSandwich] = true
+		}
+		if method.aggregator {
+			kinds[MEVBackrun] = true
+			class.Reasons = append(class.Reasons, "aggregator")
+		}
+	}
+	if isRouter {
+		class.Confidence += 0.2
+		class.Reasons = append(class.Reasons, "known_router")
+	}
+
+	// A zero minimum output accepts any price, so the whole swap can be sandwiched
+	if isSwap && method.minOutArg >= 0 && method.minOutArg < len(args) && strings.Trim(args[method.minOutArg], "0") == "" {
+		class.Confidence += 0.3
+		class.Reasons = append(class.Reasons, "no_slippage_limit")
+		kinds[MEVSandwich] = true
+	}
+
+	// Large swaps move the pool price far enough to arbitrage back
+	if tx.Value != "" && hexToBig(tx.Value).Cmp(c.largeSwap) >= 0 {
+		class.Confidence += 0.2
+		class.Reasons = append(class.Reasons, "large_swap")
+		kinds[MEVBackrun] = true
+		if isSwap && method.exactInput {
+			kinds[MEVSandwich] = true
+		}
+	}
+
+	class.Confidence = math.Min(math.Round(class.Confidence*100)/100, 1)
+	if class.Confidence < c.minConfidence || len(kinds) == 0 {
+		return
+	}
+	for _, kind := range []string{MEVSandwich, MEVBackrun} {
+		if kinds[kind] {
+			class.Kinds = append(class.Kinds, kind)
+		}
+	}
+
+	mevCandidates.WithLabelValues(tx.Chain, class.Protocol).Inc()
+	tx.MEV = class
+}
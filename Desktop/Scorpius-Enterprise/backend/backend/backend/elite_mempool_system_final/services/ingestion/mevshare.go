@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const mevShareTopic = "mev_share"
+
+var mevShareEvents = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_mev_share_events_total",
+		Help: "MEV-Share hint events ingested",
+	},
+	[]string{"kind", "status"},
+)
+
+// MEVShareLog is a log hint revealed by the MEV-Share matchmaker
+type MEVShareLog struct {
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    string   `json:"data,omitempty"`
+}
+
+// MEVShareTx is a transaction hint; fields are only present when the user opted to share them
+type MEVShareTx struct {
+	Hash             string `json:"hash,omitempty"`
+	To               string `json:"to,omitempty"`
+	FunctionSelector string `json:"functionSelector,omitempty"`
+	CallData         string `json:"callData,omitempty"`
+}
+
+// MEVShareEvent is a single event from the MEV-Share SSE stream
+type MEVShareEvent struct {
+	Hash        string        `json:"hash"`
+	Logs        []MEVShareLog `json:"logs,omitempty"`
+	Txs         []MEVShareTx  `json:"txs,omitempty"`
+	MevGasPrice string        `json:"mevGasPrice,omitempty"`
+	GasUsed     string        `json:"gasUsed,omitempty"`
+}
+
+// MEVShareMessage is published to the mev_share topic
+type MEVShareMessage struct {
+	Chain      string        `json:"chain"`
+	Kind       string        `json:"kind"` // "transaction" or "bundle"
+	Hash       string        `json:"hash"`
+	Hints      MEVShareEvent `json:"hints"`
+	ReceivedAt int64         `json:"received_at"`
+}
+
+// MEVShareSource ingests hinted private transactions and bundles from the
+// Flashbots MEV-Share event stream
+type MEVShareSource struct {
+	url    string
+	chain  string
+	sinks  *chainSinks
+	client *http.Client
+}
+
+// NewMEVShareSource creates a source reading the SSE stream at url and
+// publishing its events through chain's sink
+func NewMEVShareSource(url, chain string, sinks *chainSinks) *MEVShareSource {
+	return &MEVShareSource{
+		url:   url,
+		chain: chain,
+		sinks: sinks,
+		// No overall timeout: the stream is long-lived
+		client: &http.Client{},
+	}
+}
+
+// Run consumes the stream until ctx is cancelled, reconnecting on errors
+func (s *MEVShareSource) Run(ctx context.Context) {
+	slog.Info("starting MEV-Share ingestion", "endpoint", displayEndpoint(s.url))
+
+	for {
+		if err := s.consume(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("MEV-Share stream failed", "endpoint", displayEndpoint(s.url), "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// consume reads events from a single SSE connection
+func (s *MEVShareSource) consume(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return fmt.Errorf("invalid MEV-Share URL: %v", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case line == "":
+			// A blank line terminates the event
+			if data.Len() > 0 {
+				s.handleEvent(ctx, data.String())
+				data.Reset()
+			}
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("stream read failed: %v", err)
+	}
+	return fmt.Errorf("stream closed by server")
+}
+
+// handleEvent decodes an event and publishes it with its hint metadata
+func (s *MEVShareSource) handleEvent(ctx context.Context, payload string) {
+	// Every replica reads the stream so a standby is ready to take over, but
+	// only the chain's leader publishes
+	if s.sinks.standby(s.chain) {
+		mevShareEvents.WithLabelValues("unknown", "standby").Inc()
+		return
+	}
+
+	var event MEVShareEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		mevShareEvents.WithLabelValues("unknown", "invalid").Inc()
+		return
+	}
+
+	kind := "transaction"
+	if len(event.Txs) > 1 {
+		kind = "bundle"
+	}
+
+	msg := MEVShareMessage{
+		Chain:      s.chain,
+		Kind:       kind,
+		Hash:       event.Hash,
+		Hints:      event,
+		ReceivedAt: time.Now().UnixMilli(),
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		mevShareEvents.WithLabelValues(kind, "failed").Inc()
+		slog.Error("failed to marshal MEV-Share event", "tx_hash", event.Hash, "error", err)
+		return
+	}
+
+	headers := map[string]string{
+		"chain_name": s.chain,
+		"kind":       kind,
+		"format":     FormatJSON,
+	}
+	if err := s.sinks.Publish(ctx, s.chain, mevShareTopic, []byte(event.Hash), data, headers); err != nil {
+		mevShareEvents.WithLabelValues(kind, "failed").Inc()
+		slog.Error("failed to publish MEV-Share event", "tx_hash", event.Hash, "error", err)
+		return
+	}
+
+	mevShareEvents.WithLabelValues(kind, "success").Inc()
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// mockTick is how often a mock endpoint emits the transactions due
+const mockTick = 10 * time.Millisecond
+
+var mockTransactions = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_mock_transactions_total",
+		Help: "Synthetic transactions generated by mock endpoints",
+	},
+	[]string{"chain"},
+)
+
+// mockSource configures a mock endpoint, mock://<name>?rate=100&seed=1.
+// The same seed generates the same transactions in the same order.
+//
+//	rate      transactions per second (default 100)
+//	seed      random seed (default 1)
+//	senders   distinct sending addresses (default 1000)
+//	count     stop after this many transactions; 0 runs forever
+//	replace   share of transactions that replace an earlier one with a higher fee (default 0.02)
+//	base_fee  base fee in gwei that EVM fees are drawn around (default 20)
+type mockSource struct {
+	rate    float64
+	seed    int64
+	senders int
+	count   int
+	replace float64
+	baseFee float64
+}
+
+// parseMockEndpoint reads a mock endpoint's parameters
+func parseMockEndpoint(endpoint string) (mockSource, error) {
+	source := mockSource{rate: 100, seed: 1, senders: 1000, replace: 0.02, baseFee: 20}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return source, fmt.Errorf("invalid mock endpoint: %v", err)
+	}
+
+	var problems []string
+	float := func(name string, target *float64, min float64) {
+		if raw := u.Query().Get(name); raw != "" {
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil || v < min {
+				problems = append(problems, fmt.Sprintf("%s=%s", name, raw))
+				return
+			}
+			*target = v
+		}
+	}
+	integer := func(name string, target *int, min int) {
+		if raw := u.Query().Get(name); raw != "" {
+			v, err := strconv.Atoi(raw)
+			if err != nil || v < min {
+				problems = append(problems, fmt.Sprintf("%s=%s", name, raw))
+				return
+			}
+			*target = v
+		}
+	}
+	float("rate", &source.rate, 0)
+	float("replace", &source.replace, 0)
+	float("base_fee", &source.baseFee, 0)
+	integer("senders", &source.senders, 1)
+	integer("count", &source.count, 0)
+	if raw := u.Query().Get("seed"); raw != "" {
+		if source.seed, err = strconv.ParseInt(raw, 10, 64); err != nil {
+			problems = append(problems, "seed="+raw)
+		}
+	}
+	switch {
+	case len(problems) > 0:
+		return source, fmt.Errorf("invalid mock endpoint parameters %v", problems)
+	case source.rate <= 0:
+		return source, fmt.Errorf("mock endpoint rate must be positive")
+	case source.replace > 1:
+		return source, fmt.Errorf("mock endpoint replace must be between 0 and 1")
+	}
+	return source, nil
+}
+
+// runMock feeds the monitor synthetic pending transactions from a mock
+// endpoint. EVM transactions go through the JSON-RPC decoder like those
+// from a node; other families are built directly.
+func (cm *ChainMonitor) runMock(endpoint string) error {
+	source, err := parseMockEndpoint(endpoint)
+	if err != nil {
+		return err
+	}
+
+	cm.mu.Lock()
+	cm.activeEndpoint = endpoint
+	cm.mu.Unlock()
+	defer cm.clearActiveConn()
+
+	cm.backoff.Reset(endpoint)
+	cm.breaker.Success(endpoint)
+	cm.beginWarmup()
+	cm.logger.Info("generating synthetic transactions", "endpoint", displayEndpoint(endpoint), "rate", source.rate, "seed", source.seed)
+
+	generator := newMockGenerator(source, cm.chainID)
+	ticker := time.NewTicker(mockTick)
+	defer ticker.Stop()
+	start, kills := time.Now(), cm.chaos.Kills(cm.chainName)
+	for {
+		select {
+		case <-cm.ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if cm.chaos.Kills(cm.chainName) != kills {
+			return fmt.Errorf("mock endpoint %s killed by fault injection", displayEndpoint(endpoint))
+		}
+
+		due := int(source.rate * time.Since(start).Seconds())
+		if source.count > 0 && due > source.count {
+			due = source.count
+		}
+		for generator.generated < due {
+			var err error
+			switch cm.family {
+			case FamilyEVM:
+				// Injected faults hit synthetic messages as they would a websocket's
+				data := generator.evm()
+				if cm.chaos.Drop(cm.chainName) {
+					continue
+				}
+				err = cm.processPendingTransaction(cm.chaos.Corrupt(cm.chainName, data))
+			case FamilyUTXO:
+				err = cm.publishTransaction(generator.utxo(cm.chainName))
+			default:
+				err = cm.publishTransaction(generator.signature(cm.chainName, cm.family))
+			}
+			if err != nil {
+				cm.logger.Error("failed to handle synthetic transaction", "endpoint", displayEndpoint(endpoint), "error", err)
+			}
+			mockTransactions.WithLabelValues(cm.chainName).Inc()
+		}
+		cm.recordActivity(endpoint)
+	}
+}
+
+// mockSent is an EVM transaction kept so a later one can replace it
+type mockSent struct {
+	fields map[string]interface{}
+	tip    float64
+	fee    float64
+}
+
+// mockGenerator draws transactions with a skewed distribution of senders
+// and contracts, per-sender nonces, and realistic mixes of transaction
+// types, values and fees
+type mockGenerator struct {
+	source    mockSource
+	chainID   int64
+	rng       *rand.Rand
+	senders   []string
+	nonces    []uint64
+	pickFrom  *rand.Zipf
+	tokens    []string
+	contracts []string
+	pickTo    *rand.Zipf
+	recent    []mockSent
+	generated int
+}
+
+func newMockGenerator(source mockSource, chainID int64) *mockGenerator {
+	rng := rand.New(rand.NewSource(source.seed))
+	g := &mockGenerator{
+		source:   source,
+		chainID:  chainID,
+		rng:      rng,
+		nonces:   make([]uint64, source.senders),
+		pickFrom: rand.NewZipf(rng, 1.2, 10, uint64(source.senders-1)),
+		pickTo:   rand.NewZipf(rng, 1.2, 2, 49),
+	}
+	for i := 0; i < source.senders; i++ {
+		g.senders = append(g.senders, g.hex(20))
+		// Senders have history, so nonces do not all start at zero
+		g.nonces[i] = uint64(rng.ExpFloat64() * 200)
+	}
+	for i := 0; i < 50; i++ {
+		g.tokens = append(g.tokens, g.hex(20))
+		g.contracts = append(g.contracts, g.hex(20))
+	}
+	return g
+}
+
+// hex returns n random bytes as 0x-prefixed hex
+func (g *mockGenerator) hex(n int) string {
+	b := make([]byte, n)
+	g.rng.Read(b)
+	return "0x" + hex.EncodeToString(b)
+}
+
+// abiWord left-pads a hex value to a 32-byte ABI word
+func abiWord(value string) string {
+	value = value[2:]
+	return fmt.Sprintf("%064s", value)
+}
+
+// hexQuantity formats n as a JSON-RPC hex quantity
+func hexQuantity(n uint64) string {
+	return "0x" + strconv.FormatUint(n, 16)
+}
+
+// gweiQuantity formats an amount of gwei in wei as a JSON-RPC hex quantity
+func gweiQuantity(amount float64) string {
+	return hexQuantity(uint64(amount * 1e9))
+}
+
+// logNormal draws from a log-normal distribution with the given median
+func (g *mockGenerator) logNormal(median, sigma float64) float64 {
+	return median * math.Exp(g.rng.NormFloat64()*sigma)
+}
+
+// evm returns the next transaction as a node would send it with
+// newPendingTransactions: half plain transfers, a third ERC-20 transfers
+// and the rest contract calls, mostly as EIP-1559 transactions
+func (g *mockGenerator) evm() json.RawMessage {
+	g.generated++
+	if len(g.recent) > 0 && g.rng.Float64() < g.source.replace {
+		return g.replacement()
+	}
+
+	sender := int(g.pickFrom.Uint64())
+	fields := map[string]interface{}{
+		"hash":    g.hex(32),
+		"from":    g.senders[sender],
+		"nonce":   hexQuantity(g.nonces[sender]),
+		"chainId": hexQuantity(uint64(g.chainID)),
+		"value":   "0x0",
+		"input":   "0x",
+	}
+	g.nonces[sender]++
+
+	switch kind := g.rng.Float64(); {
+	case kind < 0.5:
+		eth := new(big.Float).SetFloat64(g.logNormal(0.05, 2))
+		wei, _ := eth.Mul(eth, big.NewFloat(1e18)).Int(nil)
+		fields["to"] = g.hex(20)
+		fields["value"] = "0x" + wei.Text(16)
+		fields["gas"] = hexQuantity(21000)
+	case kind < 0.83:
+		amount := new(big.Float).SetFloat64(g.logNormal(500, 2.5))
+		units, _ := amount.Mul(amount, big.NewFloat(1e6)).Int(nil)
+		fields["to"] = g.tokens[g.pickTo.Uint64()]
+		fields["input"] = "0xa9059cbb" + abiWord(g.hex(20)) + fmt.Sprintf("%064x", units)
+		fields["gas"] = hexQuantity(uint64(45000 + g.rng.Intn(20000)))
+	default:
+		input := g.hex(4)
+		for words := 2 + g.rng.Intn(10); words > 0; words-- {
+			input += abiWord(g.hex(32))
+		}
+		fields["to"] = g.contracts[g.pickTo.Uint64()]
+		fields["input"] = input
+		fields["gas"] = hexQuantity(uint64(80000 + g.rng.Intn(400000)))
+		if g.rng.Float64() < 0.1 {
+			eth := new(big.Float).SetFloat64(g.logNormal(0.5, 1.5))
+			wei, _ := eth.Mul(eth, big.NewFloat(1e18)).Int(nil)
+			fields["value"] = "0x" + wei.Text(16)
+		}
+	}
+
+	tip := g.logNormal(1.5, 0.8)
+	fee := g.source.baseFee*(1.2+g.rng.Float64()) + tip
+	sent := mockSent{fields: fields, tip: tip, fee: fee}
+	g.price(sent, g.rng.Float64())
+
+	g.recent = append(g.recent, sent)
+	if len(g.recent) > 256 {
+		g.recent = g.recent[1:]
+	}
+	data, _ := json.Marshal(fields)
+	return data
+}
+
+// price sets a transaction's type and fee fields: three quarters EIP-1559,
+// a fifth legacy and the rest EIP-2930
+func (g *mockGenerator) price(sent mockSent, kind float64) {
+	fields := sent.fields
+	switch {
+	case kind < 0.75:
+		fields["type"] = "0x2"
+		fields["maxFeePerGas"] = gweiQuantity(sent.fee)
+		fields["maxPriorityFeePerGas"] = gweiQuantity(sent.tip)
+		fields["gasPrice"] = gweiQuantity(sent.fee)
+		fields["accessList"] = []interface{}{}
+	case kind < 0.95:
+		fields["type"] = "0x0"
+		fields["gasPrice"] = gweiQuantity(sent.fee)
+	default:
+		fields["type"] = "0x1"
+		fields["gasPrice"] = gweiQuantity(sent.fee)
+		fields["accessList"] = []map[string]interface{}{{
+			"address":     fields["to"],
+			"storageKeys": []string{g.hex(32)},
+		}}
+	}
+}
+
+// replacement resends a recent transaction with the same sender and nonce
+// and fees bumped past the 10% replacement threshold
+func (g *mockGenerator) replacement() json.RawMessage {
+	previous := g.recent[g.rng.Intn(len(g.recent))]
+	bump := 1.1 + g.rng.Float64()*0.4
+	fields := make(map[string]interface{}, len(previous.fields))
+	for name, value := range previous.fields {
+		fields[name] = value
+	}
+	fields["hash"] = g.hex(32)
+
+	sent := mockSent{fields: fields, tip: previous.tip * bump, fee: previous.fee * bump}
+	kind := 0.0
+	switch previous.fields["type"] {
+	case "0x0":
+		kind = 0.8
+	case "0x1":
+		kind = 0.99
+	}
+	g.price(sent, kind)
+	data, _ := json.Marshal(fields)
+	return data
+}
+
+// utxo returns a transaction spending one to three outputs to one or two
+// P2WPKH outputs, a payment and usually change
+func (g *mockGenerator) utxo(chain string) Transaction {
+	g.generated++
+	tx := Transaction{
+		Hash:        g.hex(32)[2:],
+		ChainID:     g.chainID,
+		Chain:       chain,
+		ChainFamily: FamilyUTXO,
+		Status:      "pending",
+		Timestamp:   time.Now().Unix(),
+	}
+	for i := 1 + g.rng.Intn(3); i > 0; i-- {
+		tx.Inputs = append(tx.Inputs, UTXOInput{PrevTxID: g.hex(32)[2:], PrevIndex: uint32(g.rng.Intn(4)), Sequence: 0xfffffffd})
+	}
+	outputs := 1 + g.rng.Intn(2)
+	for i := 0; i < outputs; i++ {
+		program, _ := hex.DecodeString(g.hex(20)[2:])
+		tx.Outputs = append(tx.Outputs, UTXOOutput{
+			Index:        uint32(i),
+			Value:        int64(g.logNormal(2e6, 2)),
+			ScriptPubKey: "0014" + hex.EncodeToString(program),
+			ScriptType:   "p2wpkh",
+			Address:      segwitAddress("bc", 0, program),
+		})
+	}
+	return tx
+}
+
+// signature returns a transaction with only a random signature, as the
+// Solana monitor publishes for log notifications
+func (g *mockGenerator) signature(chain, family string) Transaction {
+	g.generated++
+	signature := make([]byte, 64)
+	g.rng.Read(signature)
+	return Transaction{
+		Hash:        hex.EncodeToString(signature),
+		ChainID:     g.chainID,
+		Chain:       chain,
+		ChainFamily: family,
+		Status:      "pending",
+		Timestamp:   time.Now().Unix(),
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var natsPublishErrors = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_nats_publish_errors_total",
+		Help: "JetStream publishes that were not acknowledged",
+	},
+	[]string{"subject"},
+)
+
+// NATSSink publishes to NATS JetStream. Topics map to subjects under a prefix
+// (tx_raw -> scorpius.tx_raw), all captured by one stream. The message key is
+// sent as Nats-Msg-Id so JetStream drops redelivered duplicates within the
+// stream's duplicate window.
+type NATSSink struct {
+	conn   *nats.Conn
+	js     nats.JetStreamContext
+	prefix string
+}
+
+// NewNATSSink connects to url and ensures the stream for prefix exists
+func NewNATSSink(url, prefix, stream, credentials string) (*NATSSink, error) {
+	opts := []nats.Option{
+		nats.Name("scorpius-ingestion"),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				slog.Warn("disconnected from NATS", "error", err)
+			}
+		}),
+	}
+	if credentials != "" {
+		opts = append(opts, nats.UserCredentials(credentials))
+	}
+
+	conn, err := nats.Connect(url, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %v", err)
+	}
+
+	js, err := conn.JetStream(
+		nats.PublishAsyncMaxPending(65536),
+		nats.PublishAsyncErrHandler(func(_ nats.JetStream, msg *nats.Msg, err error) {
+			natsPublishErrors.WithLabelValues(msg.Subject).Inc()
+			slog.Error("failed to publish to NATS", "subject", msg.Subject, "error", err)
+		}),
+	)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open JetStream context: %v", err)
+	}
+
+	prefix = strings.TrimSuffix(prefix, ".")
+	if _, err := js.StreamInfo(stream); errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = js.AddStream(&nats.StreamConfig{
+			Name:       stream,
+			Subjects:   []string{prefix + ".>"},
+			Storage:    nats.FileStorage,
+			Duplicates: 2 * time.Minute,
+		})
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to create stream %s: %v", stream, err)
+		}
+		slog.Info("created JetStream stream", "stream", stream, "subjects", prefix+".>")
+	} else if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to look up stream %s: %v", stream, err)
+	}
+
+	return &NATSSink{conn: conn, js: js, prefix: prefix}, nil
+}
+
+// Name returns the sink name
+func (s *NATSSink) Name() string {
+	return SinkNATS
+}
+
+// Publish sends a message asynchronously; failures are reported by the error handler
+func (s *NATSSink) Publish(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
+	msg := nats.NewMsg(s.prefix + "." + topic)
+	// Async publishes keep the message for retries after Publish returns
+	msg.Data = bytes.Clone(value)
+	for name, v := range headers {
+		msg.Header.Set(name, v)
+	}
+	if len(key) > 0 {
+		msg.Header.Set(nats.MsgIdHdr, string(key))
+	}
+
+	_, err := s.js.PublishMsgAsync(msg)
+	return err
+}
+
+// Close waits for outstanding acknowledgements and drains the connection
+func (s *NATSSink) Close() {
+	select {
+	case <-s.js.PublishAsyncComplete():
+	case <-time.After(15 * time.Second):
+		slog.Warn("JetStream publishes unacknowledged at shutdown", "pending", s.js.PublishAsyncPending())
+	}
+	s.conn.Drain()
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// NFT marketplace activity kinds
+const (
+	// NFTSale is a buyer filling a listing
+	NFTSale = "sale"
+	// NFTBidAccepted is an owner selling into a bid or collection offer
+	NFTBidAccepted = "bid_accepted"
+	// NFTListing and NFTBid are orders registered on chain rather than
+	// signed off chain, such as through Seaport's validate
+	NFTListing = "listing"
+	NFTBid     = "bid"
+	NFTCancel  = "cancel"
+)
+
+// Token standards of NFT items
+const (
+	StandardERC721  = "erc721"
+	StandardERC1155 = "erc1155"
+)
+
+var nftActivity = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_nft_activity_total",
+		Help: "Pending NFT marketplace orders decoded, by marketplace, kind and publish status",
+	},
+	[]string{"chain", "marketplace", "kind", "status"},
+)
+
+// nftMarketplaces maps exchange contracts to the marketplace whose calldata
+// they take. Seaport is deployed at the same addresses on every chain.
+var nftMarketplaces = map[string]string{
+	"0x00000000006c3852cbef3e08e8df289169ede581": "seaport", // 1.1
+	"0x00000000000001ad428e4906ae43d8f9852d0dd6": "seaport", // 1.4
+	"0x00000000000000adc04c56bf30ac9d3c0aaf14dc": "seaport", // 1.5
+	"0x0000000000000068f116a894984e2db1123eb395": "seaport", // 1.6
+	"0x000000000000ad05ccc4f10045630fb830b95127": "blur",
+	"0x0000000000e655fae4d56241588680f86e3b2377": "looksrare",
+}
+
+// nftDecoder decodes the orders of one marketplace method. from is the
+// transaction sender, who takes the orders.
+type nftDecoder struct {
+	method string
+	decode func(args abiData, from string, now time.Time) []NFTActivity
+}
+
+var nftDecoders = map[string]map[string]nftDecoder{
+	"seaport": {
+		"fb0f3ee1": {method: "fulfillBasicOrder", decode: seaportBasicOrder},
+		"00000000": {method: "fulfillBasicOrder_efficient_6GL6yc", decode: seaportBasicOrder},
+		"b3a34c4c": {method: "fulfillOrder", decode: seaportOrder},
+		"e7acab24": {method: "fulfillAdvancedOrder", decode: seaportOrder},
+		"ed98a574": {method: "fulfillAvailableOrders", decode: seaportOrders(false)},
+		"87201b41": {method: "fulfillAvailableAdvancedOrders", decode: seaportOrders(false)},
+		"a8174404": {method: "matchOrders", decode: seaportOrders(false)},
+		"55944a42": {method: "matchAdvancedOrders", decode: seaportOrders(false)},
+		"f2d12b12": {method: "matchAdvancedOrders", decode: seaportOrders(false)},
+		"88147732": {method: "validate", decode: seaportOrders(true)},
+		"fd9f1e10": {method: "cancel", decode: seaportCancel},
+	},
+	"blur": {
+		"9a1fc3a7": {method: "execute", decode: blurExecute},
+		"b3be57f8": {method: "bulkExecute", decode: blurBulkExecute},
+	},
+	"looksrare": {
+		// (Taker, Maker, bytes makerSignature, MerkleTree, address affiliate)
+		"8585ae03": {method: "executeTakerBid", decode: looksRareTake(NFTSale)},
+		"e72853e1": {method: "executeTakerAsk", decode: looksRareTake(NFTBidAccepted)},
+		"f4288a21": {method: "executeMultipleTakerBids", decode: looksRareMultipleTakerBids},
+	},
+}
+
+// NFTItem is a token an order trades. Criteria items (collection and trait
+// offers) leave TokenID empty, as the token is chosen when the order fills.
+type NFTItem struct {
+	Collection string `json:"collection"`
+	TokenID    string `json:"token_id,omitempty"`
+	Amount     string `json:"amount"`
+	Standard   string `json:"standard,omitempty"`
+	Criteria   bool   `json:"criteria,omitempty"`
+}
+
+// NFTActivity is one marketplace order taken, registered or cancelled by a
+// pending transaction. Maker signed the order and Taker sent the
+// transaction. Price is the order's total in Currency, fees included, with
+// the zero address for the native currency.
+type NFTActivity struct {
+	Chain       string    `json:"chain"`
+	ChainID     int64     `json:"chain_id"`
+	Hash        string    `json:"hash"`
+	Marketplace string    `json:"marketplace"`
+	Exchange    string    `json:"exchange"`
+	Method      string    `json:"method"`
+	Kind        string    `json:"kind"`
+	Maker       string    `json:"maker"`
+	Taker       string    `json:"taker,omitempty"`
+	Items       []NFTItem `json:"items"`
+	Currency    string    `json:"currency,omitempty"`
+	Price       string    `json:"price,omitempty"`
+	Status      string    `json:"status"`
+	DetectedAt  time.Time `json:"detected_at"`
+}
+
+// NFTConfig configures NFT marketplace decoding
+type NFTConfig struct {
+	Enabled bool
+	Topic   string
+}
+
+// loadNFTConfig reads NFT_* settings
+func loadNFTConfig() NFTConfig {
+	return NFTConfig{
+		Enabled: getEnvBool("NFT_TRACKING", false),
+		Topic:   getEnvOrDefault("NFT_TOPIC", "nft_activity"),
+	}
+}
+
+// NFTMonitor publishes an NFTActivity for each order in pending calls to
+// Seaport, Blur and LooksRare exchanges
+type NFTMonitor struct {
+	sinks *chainSinks
+	topic string
+}
+
+// NewNFTMonitor creates a monitor publishing to sinks
+func NewNFTMonitor(sinks *chainSinks, config NFTConfig) *NFTMonitor {
+	return &NFTMonitor{sinks: sinks, topic: config.Topic}
+}
+
+// Name returns the enricher name
+func (m *NFTMonitor) Name() string {
+	return "nft"
+}
+
+// Enrich decodes and publishes the marketplace orders of pending transactions
+func (m *NFTMonitor) Enrich(tx *Transaction) {
+	if tx.ChainFamily != FamilyEVM || tx.Canary || tx.Status != "pending" {
+		return
+	}
+	exchange := strings.ToLower(tx.To)
+	marketplace, ok := nftMarketplaces[exchange]
+	if !ok {
+		return
+	}
+	data := common.FromHex(tx.Data)
+	if len(data) < 4 {
+		return
+	}
+	decoder, ok := nftDecoders[marketplace][hex.EncodeToString(data[:4])]
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	from := strings.ToLower(tx.From)
+	for _, activity := range decoder.decode(abiData(data[4:]), from, now) {
+		if len(activity.Items) == 0 {
+			continue
+		}
+		activity.Chain = tx.Chain
+		activity.ChainID = tx.ChainID
+		activity.Hash = tx.Hash
+		activity.Marketplace = marketplace
+		activity.Exchange = exchange
+		activity.Method = decoder.method
+		activity.Status = tx.Status
+		activity.DetectedAt = now
+		if activity.Kind != NFTListing && activity.Kind != NFTBid && activity.Kind != NFTCancel {
+			activity.Taker = from
+		}
+		m.publish(activity, tx.ChainFamily)
+	}
+}
+
+// publish produces an activity to the NFT topic
+func (m *NFTMonitor) publish(activity NFTActivity, family string) {
+	data, err := json.Marshal(activity)
+	if err != nil {
+		slog.Error("failed to marshal NFT activity", "chain", activity.Chain, "tx_hash", activity.Hash, "error", err)
+		return
+	}
+
+	topic := expandTopic(m.topic, activity.Chain, activity.ChainID, family)
+	headers := map[string]string{
+		"chain_id":   fmt.Sprintf("%d", activity.ChainID),
+		"chain_name": activity.Chain,
+		"kind":       activity.Kind,
+		"format":     FormatJSON,
+	}
+	if err := m.sinks.Publish(context.Background(), activity.Chain, topic, []byte(activity.Hash), data, headers); err != nil {
+		nftActivity.WithLabelValues(activity.Chain, activity.Marketplace, activity.Kind, "failed").Inc()
+		slog.Warn("failed to publish NFT activity", "chain", activity.Chain, "tx_hash", activity.Hash, "error", err)
+		return
+	}
+	nftActivity.WithLabelValues(activity.Chain, activity.Marketplace, activity.Kind, "success").Inc()
+}
+
+// Seaport item types
+const (
+	seaportNative = iota
+	seaportERC20
+	seaportERC721
+	seaportERC1155
+	seaportERC721Criteria
+	seaportERC1155Criteria
+)
+
+// seaportBasicOrder decodes fulfillBasicOrder. The order type's route
+// (type / 4) says which side holds the NFT: routes 0-3 pay native or
+// ERC20 for an offered ERC721 or ERC1155, routes 4-5 offer ERC20 for one.
+func seaportBasicOrder(args abiData, _ string, _ time.Time) []NFTActivity {
+	params := args.tuple(0)
+	if params.word(15) == nil {
+		return nil
+	}
+	route := params.int64(8) / 4
+	activity := NFTActivity{Maker: params.address(3)}
+	switch route {
+	case 0, 1, 2, 3:
+		standard := StandardERC721
+		if route%2 == 1 {
+			standard = StandardERC1155
+		}
+		// The buyer pays the seller's amount plus every additional recipient's
+		price := params.uint(2)
+		for _, recipient := range params.staticTuples(16, 2) {
+			price = new(big.Int).Add(price, recipient.uint(0))
+		}
+		activity.Kind = NFTSale
+		activity.Items = []NFTItem{{Collection: params.address(5), TokenID: params.uint(6).String(), Amount: params.uint(7).String(), Standard: standard}}
+		activity.Currency, activity.Price = params.address(0), price.String()
+	case 4, 5:
+		standard := StandardERC721
+		if route == 5 {
+			standard = StandardERC1155
+		}
+		activity.Kind = NFTBidAccepted
+		activity.Items = []NFTItem{{Collection: params.address(0), TokenID: params.uint(1).String(), Amount: params.uint(2).String(), Standard: standard}}
+		activity.Currency, activity.Price = params.address(5), params.uint(7).String()
+	default:
+		return nil
+	}
+	return []NFTActivity{activity}
+}
+
+// seaportOrder decodes fulfillOrder and fulfillAdvancedOrder, whose first
+// argument is an Order or AdvancedOrder, both starting with the parameters
+func seaportOrder(args abiData, from string, now time.Time) []NFTActivity {
+	activity, ok := seaportActivity(args.tuple(0).tuple(0), now, false)
+	if !ok || activity.Maker == from {
+		return nil
+	}
+	return []NFTActivity{activity}
+}
+
+// seaportOrders decodes the methods taking an array of orders. Orders made
+// by the sender, like the counter-order of a match, are not activity of
+// their own. validate registers the orders as listings and bids.
+func seaportOrders(register bool) func(abiData, string, time.Time) []NFTActivity {
+	return func(args abiData, from string, now time.Time) []NFTActivity {
+		var activities []NFTActivity
+		for _, order := range args.tuples(0) {
+			activity, ok := seaportActivity(order.tuple(0), now, register)
+			if !ok || (!register && activity.Maker == from) {
+				continue
+			}
+			activities = append(activities, activity)
+		}
+		return activities
+	}
+}
+
+// seaportCancel decodes cancel, whose OrderComponents share the layout of
+// OrderParameters
+func seaportCancel(args abiData, _ string, now time.Time) []NFTActivity {
+	var activities []NFTActivity
+	for _, components := range args.tuples(0) {
+		activity, ok := seaportActivity(components, now, true)
+		if !ok {
+			continue
+		}
+		activity.Kind = NFTCancel
+		activities = append(activities, activity)
+	}
+	return activities
+}
+
+// seaportActivity reads OrderParameters (offerer, zone, offer, consideration,
+// orderType, startTime, endTime, ...). An order offering NFTs is a listing,
+// priced by the currency its consideration asks for; an order offering
+// currency for NFTs is a bid, priced by its offer.
+func seaportActivity(params abiData, now time.Time, register bool) (NFTActivity, bool) {
+	if params.word(6) == nil {
+		return NFTActivity{}, false
+	}
+	start, end := params.int64(5), params.int64(6)
+	offer := params.staticTuples(2, 5)
+	consideration := params.staticTuples(3, 6)
+
+	activity := NFTActivity{Maker: params.address(0)}
+	offerItems, offerCurrency, offerPrice := seaportItems(offer, start, end, now)
+	considerationItems, considerationCurrency, considerationPrice := seaportItems(consideration, start, end, now)
+	switch {
+	case len(offerItems) > 0:
+		activity.Kind, activity.Items = NFTSale, offerItems
+		activity.Currency, activity.Price = considerationCurrency, considerationPrice
+		if register {
+			activity.Kind = NFTListing
+		}
+	case len(considerationItems) > 0:
+		activity.Kind, activity.Items = NFTBidAccepted, considerationItems
+		activity.Currency, activity.Price = offerCurrency, offerPrice
+		if register {
+			activity.Kind = NFTBid
+		}
+	default:
+		return NFTActivity{}, false
+	}
+	return activity, true
+}
+
+// seaportItems splits offer or consideration items into NFTs and the total
+// of the first currency among them, at the amount the order is at now
+// (Seaport interpolates amounts linearly from start to end time)
+func seaportItems(items []abiData, start, end int64, now time.Time) (nfts []NFTItem, currency, total string) {
+	sum := new(big.Int)
+	for _, item := range items {
+		itemType := item.int64(0)
+		amount := seaportAmount(item.uint(3), item.uint(4), start, end, now.Unix())
+		switch itemType {
+		case seaportNative, seaportERC20:
+			token := item.address(1)
+			if currency == "" {
+				currency = token
+			}
+			if token == currency {
+				sum.Add(sum, amount)
+			}
+		case seaportERC721, seaportERC1155:
+			nfts = append(nfts, NFTItem{Collection: item.address(1), TokenID: item.uint(2).String(), Amount: amount.String(), Standard: seaportStandard(itemType)})
+		case seaportERC721Criteria, seaportERC1155Criteria:
+			nfts = append(nfts, NFTItem{Collection: item.address(1), Amount: amount.String(), Standard: seaportStandard(itemType), Criteria: true})
+		}
+	}
+	if currency != "" {
+		total = sum.String()
+	}
+	return nfts, currency, total
+}
+
+func seaportStandard(itemType int64) string {
+	if itemType == seaportERC1155 || itemType == seaportERC1155Criteria {
+		return StandardERC1155
+	}
+	return StandardERC721
+}
+
+// seaportAmount interpolates an item's amount between its start and end
+// amounts over the order's lifetime
+func seaportAmount(startAmount, endAmount *big.Int, start, end, now int64) *big.Int {
+	if startAmount.Cmp(endAmount) == 0 || end <= start {
+		return startAmount
+	}
+	if now <= start {
+		return startAmount
+	}
+	if now >= end {
+		return endAmount
+	}
+	elapsed, remaining := big.NewInt(now-start), big.NewInt(end-now)
+	amount := new(big.Int).Mul(startAmount, remaining)
+	amount.Add(amount, new(big.Int).Mul(endAmount, elapsed))
+	return amount.Div(amount, big.NewInt(end-start))
+}
+
+// blurExecute decodes execute(Input sell, Input buy). The sender's own order
+// is the taking side: a buyer filling a listing, or a seller a bid.
+func blurExecute(args abiData, from string, _ time.Time) []NFTActivity {
+	activity, ok := blurActivity(args.tuple(0), args.tuple(1), from)
+	if !ok {
+		return nil
+	}
+	return []NFTActivity{activity}
+}
+
+// blurBulkExecute decodes bulkExecute((Input sell, Input buy)[])
+func blurBulkExecute(args abiData, from string, _ time.Time) []NFTActivity {
+	var activities []NFTActivity
+	for _, execution := range args.tuples(0) {
+		if activity, ok := blurActivity(execution.tuple(0), execution.tuple(1), from); ok {
+			activities = append(activities, activity)
+		}
+	}
+	return activities
+}
+
+// blurActivity reads the Order of each Input: trader, side, matchingPolicy,
+// collection, tokenId, amount, paymentToken, price, ...
+func blurActivity(sellInput, buyInput abiData, from string) (NFTActivity, bool) {
+	sell, buy := sellInput.tuple(0), buyInput.tuple(0)
+	if sell.word(7) == nil || buy.word(0) == nil {
+		return NFTActivity{}, false
+	}
+	activity := NFTActivity{
+		Kind:     NFTSale,
+		Maker:    sell.address(0),
+		Items:    []NFTItem{{Collection: sell.address(3), TokenID: sell.uint(4).String(), Amount: sell.uint(5).String()}},
+		Currency: sell.address(6),
+		Price:    sell.uint(7).String(),
+	}
+	if sell.address(0) == from {
+		activity.Kind, activity.Maker = NFTBidAccepted, buy.address(0)
+	}
+	return activity, true
+}
+
+// looksRareTake decodes executeTakerBid and executeTakerAsk, whose second
+// argument is the maker's ask or bid
+func looksRareTake(kind string) func(abiData, string, time.Time) []NFTActivity {
+	return func(args abiData, _ string, _ time.Time) []NFTActivity {
+		activity, ok := looksRareActivity(args.tuple(1), kind)
+		if !ok {
+			return nil
+		}
+		return []NFTActivity{activity}
+	}
+}
+
+// looksRareMultipleTakerBids decodes executeMultipleTakerBids, which fills
+// an array of asks
+func looksRareMultipleTakerBids(args abiData, _ string, _ time.Time) []NFTActivity {
+	var activities []NFTActivity
+	for _, maker := range args.tuples(1) {
+		if activity, ok := looksRareActivity(maker, NFTSale); ok {
+			activities = append(activities, activity)
+		}
+	}
+	return activities
+}
+
+// looksRareActivity reads a Maker order: quoteType, globalNonce,
+// subsetNonce, orderNonce, strategyId, collectionType, collection,
+// currency, signer, startTime, endTime, price, itemIds, amounts, ...
+func looksRareActivity(maker abiData, kind string) (NFTActivity, bool) {
+	if maker.word(13) == nil {
+		return NFTActivity{}, false
+	}
+	standard := StandardERC721
+	if maker.int64(5) == 1 {
+		standard = StandardERC1155
+	}
+	activity := NFTActivity{
+		Kind:     kind,
+		Maker:    maker.address(8),
+		Currency: maker.address(7),
+		Price:    maker.uint(11).String(),
+	}
+	ids, amounts := maker.uints(12), maker.uints(13)
+	for i, id := range ids {
+		item := NFTItem{Collection: maker.address(6), TokenID: id.String(), Amount: "1", Standard: standard}
+		if i < len(amounts) {
+			item.Amount = amounts[i].String()
+		}
+		activity.Items = append(activity.Items, item)
+	}
+	return activity, true
+}
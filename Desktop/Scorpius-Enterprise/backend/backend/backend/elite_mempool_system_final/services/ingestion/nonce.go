@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	txReplacements = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_tx_replacements_total",
+			Help: "Pending transactions replacing an earlier one with the same sender and nonce, by kind",
+		},
+		[]string{"chain", "kind"},
+	)
+
+	nonceGaps = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_nonce_gaps_total",
+			Help: "Pending transactions whose nonce skips past the sender's highest pending nonce",
+		},
+		[]string{"chain"},
+	)
+)
+
+// Replacement kinds
+const (
+	ReplacementSpeedUp = "speed_up"
+	ReplacementCancel  = "cancel"
+)
+
+// NonceConfig configures nonce and replacement tracking
+type NonceConfig struct {
+	Enabled bool
+	TTL     time.Duration
+	Topic   string
+}
+
+// loadNonceConfig reads NONCE_TRACKING* settings
+func loadNonceConfig() NonceConfig {
+	return NonceConfig{
+		Enabled: getEnvBool("NONCE_TRACKING", false),
+		TTL:     getEnvDuration("NONCE_TRACKING_TTL", 10*time.Minute),
+		Topic:   getEnvOrDefault("REPLACEMENT_TOPIC", "tx_replacements"),
+	}
+}
+
+// ReplacementEvent records a pending transaction being replaced by another
+// with the same sender and nonce and a higher fee
+type ReplacementEvent struct {
+	Chain        string    `json:"chain"`
+	ChainID      int64     `json:"chain_id"`
+	From         string    `json:"from"`
+	Nonce        uint64    `json:"nonce"`
+	Kind         string    `json:"kind"`
+	Hash         string    `json:"hash"`
+	ReplacedHash string    `json:"replaced_hash"`
+	OldFee       string    `json:"old_fee"`
+	NewFee       string    `json:"new_fee"`
+	ReplacedAge  float64   `json:"replaced_age_seconds"`
+	DetectedAt   time.Time `json:"detected_at"`
+}
+
+// pendingNonce is the transaction currently holding a sender's nonce
+type pendingNonce struct {
+	hash   string
+	fee    *big.Int
+	seenAt time.Time
+}
+
+// senderNonces are a sender's pending transactions by nonce
+type senderNonces struct {
+	nonces   map[uint64]pendingNonce
+	highest  uint64
+	lastSeen time.Time
+}
+
+// NonceTracker follows each sender's pending nonces. A transaction reusing a
+// pending nonce with a higher fee is a replacement: it is annotated with the
+// hash it replaces and a ReplacementEvent is published. A transaction whose
+// nonce is more than one past the sender's highest pending nonce is
+// annotated with the size of the gap.
+//
+// State is kept in memory per instance and forgotten TTL after a sender's
+// last transaction, so only replacements seen by the same instance are found.
+type NonceTracker struct {
+	sinks   *chainSinks
+	topic   string
+	ttl     time.Duration
+	mu      sync.Mutex
+	senders map[string]*senderNonces
+}
+
+// NewNonceTracker creates a tracker that publishes replacement events to sinks
+func NewNonceTracker(sinks *chainSinks, config NonceConfig) *NonceTracker {
+	return &NonceTracker{
+		sinks:   sinks,
+		topic:   config.Topic,
+		ttl:     config.TTL,
+		senders: make(map[string]*senderNonces),
+	}
+}
+
+// Name returns the enricher name
+func (n *NonceTracker) Name() string {
+	return "nonce"
+}
+
+// Enrich records tx's nonce and annotates replacements and gaps
+func (n *NonceTracker) Enrich(tx *Transaction) {
+	if tx.ChainFamily != FamilyEVM || tx.Canary || tx.Status != "pending" || tx.From == "" || tx.Nonce == "" {
+		return
+	}
+
+	nonce := hexToUint64(tx.Nonce)
+	fee := hexToBig(bidFee(tx))
+	key := tx.Chain + ":" + strings.ToLower(tx.From)
+	now := time.Now()
+
+	n.mu.Lock()
+	sender, ok := n.senders[key]
+	if !ok {
+		sender = &senderNonces{nonces: make(map[uint64]pendingNonce), highest: nonce}
+		n.senders[key] = sender
+	}
+	sender.lastSeen = now
+
+	previous, held := sender.nonces[nonce]
+	switch {
+	case held && previous.hash == tx.Hash:
+		n.mu.Unlock()
+		return
+	case held && fee.Cmp(previous.fee) <= 0:
+		// Nodes reject an underpriced replacement, so the original keeps the nonce
+		n.mu.Unlock()
+		return
+	case !held && ok && nonce > sender.highest+1:
+		tx.NonceGap = nonce - sender.highest - 1
+		nonceGaps.WithLabelValues(tx.Chain).Inc()
+	}
+	if nonce > sender.highest {
+		sender.highest = nonce
+	}
+	sender.nonces[nonce] = pendingNonce{hash: tx.Hash, fee: fee, seenAt: now}
+	n.mu.Unlock()
+
+	if !held {
+		return
+	}
+
+	tx.IsReplacement = true
+	tx.ReplacedHash = previous.hash
+
+	kind := ReplacementSpeedUp
+	if strings.EqualFold(tx.To, tx.From) && (tx.Data == "" || tx.Data == "0x") && hexToBig(tx.Value).Sign() == 0 {
+		kind = ReplacementCancel
+	}
+	txReplacements.WithLabelValues(tx.Chain, kind).Inc()
+
+	n.publish(ReplacementEvent{
+		Chain:        tx.Chain,
+		ChainID:      tx.ChainID,
+		From:         strings.ToLower(tx.From),
+		Nonce:        nonce,
+		Kind:         kind,
+		Hash:         tx.Hash,
+		ReplacedHash: previous.hash,
+		OldFee:       previous.fee.String(),
+		NewFee:       fee.String(),
+		ReplacedAge:  now.Sub(previous.seenAt).Seconds(),
+		DetectedAt:   now,
+	}, tx.ChainFamily)
+}
+
+// bidFee is the most a transaction pays per gas: its fee cap, or its gas price
+func bidFee(tx *Transaction) string {
+	if tx.MaxFeePerGas != "" {
+		return tx.MaxFeePerGas
+	}
+	return tx.GasPrice
+}
+
+// publish produces a replacement event to the replacement topic
+func (n *NonceTracker) publish(event ReplacementEvent, family string) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("failed to marshal replacement event", "chain", event.Chain, "error", err)
+		return
+	}
+
+	topic := expandTopic(n.topic, event.Chain, event.ChainID, family)
+	headers := map[string]string{
+		"chain_id":   fmt.Sprintf("%d", event.ChainID),
+		"chain_name": event.Chain,
+		"kind":       event.Kind,
+		"format":     FormatJSON,
+	}
+	if err := n.sinks.Publish(context.Background(), event.Chain, topic, []byte(event.Hash), data, headers); err != nil {
+		slog.Warn("failed to publish replacement event", "chain", event.Chain, "tx_hash", event.Hash, "error", err)
+	}
+}
+
+// Run forgets idle senders until ctx is cancelled
+func (n *NonceTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(n.ttl / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cutoff := time.Now().Add(-n.ttl)
+			n.mu.Lock()
+			for key, sender := range n.senders {
+				if sender.lastSeen.Before(cutoff) {
+					delete(n.senders, key)
+				}
+			}
+			n.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DecimalAmounts repeats a transaction's hex quantities in decimal, for the
+// families whose nodes report them in hex (EVM and Starknet). Fields the
+// transaction leaves empty stay empty.
+type DecimalAmounts struct {
+	Value                string `json:"value"`
+	Gas                  string `json:"gas,omitempty"`
+	GasPrice             string `json:"gas_price,omitempty"`
+	MaxFeePerGas         string `json:"max_fee_per_gas,omitempty"`
+	MaxPriorityFeePerGas string `json:"max_priority_fee_per_gas,omitempty"`
+	MaxFeePerBlobGas     string `json:"max_fee_per_blob_gas,omitempty"`
+	Nonce                string `json:"nonce,omitempty"`
+}
+
+// Normalizer puts addresses in their canonical form and adds decimal
+// amounts, so every transaction of a family looks the same whichever node,
+// relay or peer it came from. EVM addresses are EIP-55 checksummed;
+// Starknet addresses are zero-padded to 64 lowercase hex digits.
+type Normalizer struct{}
+
+// Name returns the enricher name
+func (n *Normalizer) Name() string {
+	return "normalize"
+}
+
+// Enrich normalizes tx in place
+func (n *Normalizer) Enrich(tx *Transaction) {
+	switch tx.ChainFamily {
+	case FamilyEVM:
+		tx.From = checksumAddress(tx.From)
+		tx.To = checksumAddress(tx.To)
+		for i := range tx.AccessList {
+			tx.AccessList[i].Address = checksumAddress(tx.AccessList[i].Address)
+		}
+	case FamilyStarknet:
+		tx.From = starknetAddress(tx.From)
+		tx.To = starknetAddress(tx.To)
+	default:
+		return
+	}
+
+	tx.Decimal = &DecimalAmounts{
+		Value:                hexToDecimal(tx.Value),
+		Gas:                  hexToDecimal(tx.Gas),
+		GasPrice:             hexToDecimal(tx.GasPrice),
+		MaxFeePerGas:         hexToDecimal(tx.MaxFeePerGas),
+		MaxPriorityFeePerGas: hexToDecimal(tx.MaxPriorityFeePerGas),
+		MaxFeePerBlobGas:     hexToDecimal(tx.MaxFeePerBlobGas),
+		Nonce:                hexToDecimal(tx.Nonce),
+	}
+}
+
+// checksumAddress returns the EIP-55 form of an EVM address, or the value
+// unchanged when it is not one
+func checksumAddress(address string) string {
+	if !common.IsHexAddress(address) {
+		return address
+	}
+	return common.HexToAddress(address).Hex()
+}
+
+// starknetAddress zero-pads a Starknet felt address to 64 hex digits
+func starknetAddress(address string) string {
+	n, ok := new(big.Int).SetString(strings.TrimPrefix(address, "0x"), 16)
+	if !ok || !strings.HasPrefix(address, "0x") || n.BitLen() > 256 {
+		return address
+	}
+	return fmt.Sprintf("0x%064x", n)
+}
+
+// hexToDecimal converts a 0x-prefixed quantity to decimal, leaving empty or
+// invalid values empty
+func hexToDecimal(value string) string {
+	if !strings.HasPrefix(value, "0x") {
+		return ""
+	}
+	n, ok := new(big.Int).SetString(value[2:], 16)
+	if !ok {
+		return ""
+	}
+	return n.String()
+}
+
+// caip2 returns a chain's CAIP-2 identifier, or "" when it has none
+func caip2(chainName, family string, chainID int64) string {
+	if family == FamilyEVM {
+		return fmt.Sprintf("eip155:%d", chainID)
+	}
+	return chainRegistry[chainName].CAIP2
+}
+
+// caip10 returns the CAIP-10 identifier of an account on a CAIP-2 chain, or
+// "" without either
+func caip10(chain, address string) string {
+	if chain == "" || address == "" {
+		return ""
+	}
+	return chain + ":" + address
+}
+
+// caip10Pair returns caip10(chain, from) and caip10(chain, to) sharing one
+// allocation
+func caip10Pair(chain, from, to string) (string, string) {
+	if chain == "" || (from == "" && to == "") {
+		return "", ""
+	}
+	var b strings.Builder
+	b.Grow(2*len(chain) + 2 + len(from) + len(to))
+	if from != "" {
+		b.WriteString(chain)
+		b.WriteByte(':')
+		b.WriteString(from)
+	}
+	split := b.Len()
+	if to != "" {
+		b.WriteString(chain)
+		b.WriteByte(':')
+		b.WriteString(to)
+	}
+	both := b.String()
+	return both[:split], both[split:]
+}
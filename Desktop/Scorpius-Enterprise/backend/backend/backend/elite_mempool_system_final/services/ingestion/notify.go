@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// AlertTransport delivers alerts to an external destination
+type AlertTransport interface {
+	Name() string
+	Send(ctx context.Context, alert Alert) error
+}
+
+// AlertTransportConfig configures one alert transport and the alerts routed to it
+type AlertTransportConfig struct {
+	Type        string
+	Categories  []string
+	MinSeverity string
+	Settings    map[string]string
+}
+
+// alertTransportSettings lists the settings read for each transport type; the first one is required
+var alertTransportSettings = map[string][]string{
+	"email":     {"SMTP_ADDR", "FROM", "TO", "USERNAME", "PASSWORD"},
+	"telegram":  {"BOT_TOKEN", "CHAT_ID"},
+	"discord":   {"WEBHOOK_URL"},
+	"slack":     {"WEBHOOK_URL"},
+	"webhook":   {"URL", "HEADERS"},
+	"pagerduty": {"ROUTING_KEY", "SOURCE"},
+}
+
+// pagerDutyEventsURL is the PagerDuty Events API v2 endpoint
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// loadAlertTransports reads ALERT_<TYPE>_* environment variables for every known transport
+func loadAlertTransports() []AlertTransportConfig {
+	var configs []AlertTransportConfig
+
+	for transportType, keys := range alertTransportSettings {
+		prefix := "ALERT_" + strings.ToUpper(transportType) + "_"
+		if getEnv(prefix+keys[0]) == "" {
+			continue
+		}
+
+		cfg := AlertTransportConfig{
+			Type:        transportType,
+			MinSeverity: getEnvOrDefault(prefix+"MIN_SEVERITY", SeverityWarning),
+			Settings:    make(map[string]string),
+		}
+		if categories := getEnv(prefix + "CATEGORIES"); categories != "" {
+			cfg.Categories = strings.Split(categories, ",")
+		}
+		for _, key := range keys {
+			cfg.Settings[strings.ToLower(key)] = getEnv(prefix + key)
+		}
+
+		configs = append(configs, cfg)
+	}
+
+	return configs
+}
+
+// newAlertTransport builds the transport described by cfg
+func newAlertTransport(cfg AlertTransportConfig) (AlertTransport, error) {
+	switch cfg.Type {
+	case "email":
+		if cfg.Settings["from"] == "" || cfg.Settings["to"] == "" {
+			return nil, fmt.Errorf("email transport requires from and to addresses")
+		}
+		return &EmailTransport{
+			addr:     cfg.Settings["smtp_addr"],
+			from:     cfg.Settings["from"],
+			to:       strings.Split(cfg.Settings["to"], ","),
+			username: cfg.Settings["username"],
+			password: cfg.Settings["password"],
+		}, nil
+	case "telegram":
+		if cfg.Settings["chat_id"] == "" {
+			return nil, fmt.Errorf("telegram transport requires a chat_id")
+		}
+		return &TelegramTransport{
+			token:  cfg.Settings["bot_token"],
+			chatID: cfg.Settings["chat_id"],
+			client: &http.Client{Timeout: 10 * time.Second},
+		}, nil
+	case "discord":
+		return &DiscordTransport{
+			webhookURL: cfg.Settings["webhook_url"],
+			client:     &http.Client{Timeout: 10 * time.Second},
+		}, nil
+	case "slack":
+		return &SlackTransport{
+			webhookURL: cfg.Settings["webhook_url"],
+			client:     &http.Client{Timeout: 10 * time.Second},
+		}, nil
+	case "webhook":
+		return &WebhookTransport{
+			url:     cfg.Settings["url"],
+			headers: parseKeyValues(cfg.Settings["headers"]),
+			client:  &http.Client{Timeout: 10 * time.Second},
+		}, nil
+	case "pagerduty":
+		source := cfg.Settings["source"]
+		if source == "" {
+			source, _ = os.Hostname()
+		}
+		return &PagerDutyTransport{
+			routingKey: cfg.Settings["routing_key"],
+			source:     source,
+			eventsURL:  pagerDutyEventsURL,
+			client:     &http.Client{Timeout: 10 * time.Second},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown alert transport type %q", cfg.Type)
+	}
+}
+
+// formatAlert renders an alert as a single line of text
+func formatAlert(alert Alert) string {
+	status := strings.ToUpper(alert.Severity)
+	if alert.Resolved {
+		status = "RESOLVED"
+	}
+	// Service-wide alerts, such as Kafka failures, belong to no chain
+	if alert.Chain == "" {
+		return fmt.Sprintf("[%s] %s: %s", status, alert.Category, alert.Message)
+	}
+	return fmt.Sprintf("[%s] %s/%s: %s", status, alert.Chain, alert.Category, alert.Message)
+}
+
+// EmailTransport sends alerts over SMTP
+type EmailTransport struct {
+	addr     string
+	from     string
+	to       []string
+	username string
+	password string
+}
+
+// Name returns the transport name
+func (t *EmailTransport) Name() string {
+	return "email"
+}
+
+// Send delivers the alert as a plain-text email. The whole exchange is bound
+// by ctx, so a hung SMTP server cannot hold up other transports.
+func (t *EmailTransport) Send(ctx context.Context, alert Alert) error {
+	host := t.addr
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+
+	subject := fmt.Sprintf("Scorpius %s alert: %s/%s", alert.Severity, alert.Chain, alert.Category)
+	body := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n\r\nTime: %s\r\n",
+		t.from, strings.Join(t.to, ", "), subject, alert.Message, alert.Time.Format(time.RFC3339))
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", t.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Upgrade to TLS when offered, as smtp.SendMail does
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if t.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", t.username, t.password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(t.from); err != nil {
+		return err
+	}
+	for _, rcpt := range t.to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(body)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// TelegramTransport sends alerts through the Telegram Bot API
+type TelegramTransport struct {
+	token  string
+	chatID string
+	client *http.Client
+}
+
+// Name returns the transport name
+func (t *TelegramTransport) Name() string {
+	return "telegram"
+}
+
+// Send delivers the alert as a bot message
+func (t *TelegramTransport) Send(ctx context.Context, alert Alert) error {
+	endpoint := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", t.token)
+	return postJSON(ctx, t.client, endpoint, map[string]string{
+		"chat_id": t.chatID,
+		"text":    formatAlert(alert),
+	})
+}
+
+// DiscordTransport sends alerts to a Discord webhook
+type DiscordTransport struct {
+	webhookURL string
+	client     *http.Client
+}
+
+// Name returns the transport name
+func (t *DiscordTransport) Name() string {
+	return "discord"
+}
+
+// Send delivers the alert as a webhook message
+func (t *DiscordTransport) Send(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, t.client, t.webhookURL, map[string]string{
+		"content": formatAlert(alert),
+	})
+}
+
+// SlackTransport sends alerts to a Slack incoming webhook
+type SlackTransport struct {
+	webhookURL string
+	client     *http.Client
+}
+
+// slackColors marks Slack attachments by severity
+var slackColors = map[string]string{
+	SeverityInfo:     "#439fe0",
+	SeverityWarning:  "warning",
+	SeverityCritical: "danger",
+}
+
+// Name returns the transport name
+func (t *SlackTransport) Name() string {
+	return "slack"
+}
+
+// Send delivers the alert as a message with an attachment colored by
+// severity, or green once resolved
+func (t *SlackTransport) Send(ctx context.Context, alert Alert) error {
+	color := slackColors[alert.Severity]
+	if alert.Resolved {
+		color = "good"
+	}
+	return postJSON(ctx, t.client, t.webhookURL, map[string]interface{}{
+		"text": formatAlert(alert),
+		"attachments": []map[string]interface{}{{
+			"color": color,
+			"text":  alert.Message,
+			"ts":    alert.Time.Unix(),
+		}},
+	})
+}
+
+// WebhookTransport posts each alert as JSON to an HTTP endpoint, with the
+// configured headers (e.g. HEADERS="Authorization=Bearer abc")
+type WebhookTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// Name returns the transport name
+func (t *WebhookTransport) Name() string {
+	return "webhook"
+}
+
+// Send delivers the alert as its JSON encoding
+func (t *WebhookTransport) Send(ctx context.Context, alert Alert) error {
+	return postJSONWithHeaders(ctx, t.client, t.url, t.headers, alert)
+}
+
+// PagerDutyTransport triggers and resolves PagerDuty incidents through the
+// Events API v2. Alerts for the same condition share a dedup key, so they
+// update one incident rather than opening new ones.
+type PagerDutyTransport struct {
+	routingKey string
+	source     string
+	eventsURL  string
+	client     *http.Client
+}
+
+// Name returns the transport name
+func (t *PagerDutyTransport) Name() string {
+	return "pagerduty"
+}
+
+// Send triggers an incident for the alert, or resolves it
+func (t *PagerDutyTransport) Send(ctx context.Context, alert Alert) error {
+	event := map[string]interface{}{
+		"routing_key":  t.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    alert.DedupKey(),
+	}
+	if alert.Resolved {
+		event["event_action"] = "resolve"
+		return postJSON(ctx, t.client, t.eventsURL, event)
+	}
+
+	component := alert.Chain
+	if component == "" {
+		component = "ingestion"
+	}
+	event["payload"] = map[string]interface{}{
+		"summary":   formatAlert(alert),
+		"source":    t.source,
+		"severity":  alert.Severity,
+		"timestamp": alert.Time.Format(time.RFC3339),
+		"component": component,
+		"group":     "scorpius-ingestion",
+		"class":     alert.Category,
+	}
+	return postJSON(ctx, t.client, t.eventsURL, event)
+}
+
+// postJSON posts payload as JSON and treats any non-2xx response as an error.
+// Transport errors are unwrapped so webhook URLs and bot tokens never reach the logs.
+func postJSON(ctx context.Context, client *http.Client, endpoint string, payload interface{}) error {
+	return postJSONWithHeaders(ctx, client, endpoint, nil, payload)
+}
+
+// postJSONWithHeaders is postJSON with extra request headers
+func postJSONWithHeaders(ctx context.Context, client *http.Client, endpoint string, headers map[string]string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
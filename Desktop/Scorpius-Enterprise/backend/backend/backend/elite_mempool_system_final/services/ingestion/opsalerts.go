@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// produceFailureWindow is the period Kafka produce failures are counted over
+const produceFailureWindow = time.Minute
+
+// produceFailures counts failed Kafka produces per cluster until the
+// service's watcher collects them
+var produceFailures = &failureCounter{counts: make(map[string]int)}
+
+// failureCounter counts failures by source between collections
+type failureCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// Add counts one failure for source
+func (c *failureCounter) Add(source string) {
+	c.mu.Lock()
+	c.counts[source]++
+	c.mu.Unlock()
+}
+
+// Collect returns the counts since the last collection and resets them
+func (c *failureCounter) Collect() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := c.counts
+	c.counts = make(map[string]int)
+	return counts
+}
+
+// watchProduceFailures raises an alert for every Kafka cluster with more
+// produce failures in a minute than ALERT_PRODUCE_FAILURES_PER_MIN, until
+// ctx is done. The alert resolves after a minute at or under the threshold.
+func (is *IngestionService) watchProduceFailures(ctx context.Context) {
+	threshold := is.config.AlertProduceFailures
+	ticker := time.NewTicker(produceFailureWindow)
+	defer ticker.Stop()
+
+	produceFailures.Collect()
+	firing := make(map[string]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		counts := produceFailures.Collect()
+		for cluster, failures := range counts {
+			if failures <= threshold {
+				continue
+			}
+			firing[cluster] = true
+			is.alerter.Raise(Alert{
+				Category: "kafka_produce_failures",
+				Severity: SeverityCritical,
+				Key:      cluster,
+				Message:  fmt.Sprintf("%d produces to the %s Kafka cluster failed in the last minute (threshold %d)", failures, cluster, threshold),
+			})
+		}
+		for cluster := range firing {
+			if counts[cluster] > threshold {
+				continue
+			}
+			delete(firing, cluster)
+			is.alerter.Raise(Alert{
+				Category: "kafka_produce_failures",
+				Severity: SeverityInfo,
+				Key:      cluster,
+				Resolved: true,
+				Message:  fmt.Sprintf("produce failures to the %s Kafka cluster are back under %d a minute", cluster, threshold),
+			})
+		}
+	}
+}
+
+// checkEndpoints raises a critical alert on every health check that finds
+// none of the chain's endpoints selectable, and resolves it once one is
+func (cm *ChainMonitor) checkEndpoints() {
+	if len(cm.healthyEndpoints()) > 0 {
+		if cm.endpointsDown {
+			cm.endpointsDown = false
+			cm.alerter.Raise(Alert{
+				Chain:    cm.chainName,
+				Category: "endpoints_unhealthy",
+				Severity: SeverityInfo,
+				Key:      "all",
+				Resolved: true,
+				Message:  "an endpoint is healthy again",
+			})
+		}
+		return
+	}
+
+	cm.endpointsDown = true
+	cm.alerter.Raise(Alert{
+		Chain:    cm.chainName,
+		Category: "endpoints_unhealthy",
+		Severity: SeverityCritical,
+		Key:      "all",
+		Message:  fmt.Sprintf("all %d endpoints are unhealthy, disabled or behind an open circuit breaker", len(cm.endpoints)),
+	})
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// eth wire protocol message codes (eth/68)
+const (
+	ethStatusMsg                     = 0x00
+	ethTransactionsMsg               = 0x02
+	ethNewPooledTransactionHashesMsg = 0x08
+	ethGetPooledTransactionsMsg      = 0x09
+	ethPooledTransactionsMsg         = 0x0a
+
+	ethProtocolVersion = 68
+	ethProtocolLength  = 17
+	maxEthMessageSize  = 10 * 1024 * 1024
+)
+
+var (
+	p2pPeers = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_p2p_peers",
+			Help: "Connected devp2p peers",
+		},
+		[]string{"chain"},
+	)
+
+	p2pMessages = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_p2p_messages_total",
+			Help: "eth wire protocol messages received by type",
+		},
+		[]string{"chain", "type"},
+	)
+)
+
+// ethStatusPacket is the eth/68 handshake
+type ethStatusPacket struct {
+	ProtocolVersion uint32
+	NetworkID       uint64
+	TD              *big.Int
+	Head            common.Hash
+	Genesis         common.Hash
+	ForkID          forkid.ID
+}
+
+// ethNewPooledHashesPacket announces pooled transactions (eth/68 layout)
+type ethNewPooledHashesPacket struct {
+	Types  []byte
+	Sizes  []uint32
+	Hashes []common.Hash
+}
+
+type ethGetPooledTransactionsPacket struct {
+	RequestID uint64
+	Hashes    []common.Hash
+}
+
+type ethPooledTransactionsPacket struct {
+	RequestID    uint64
+	Transactions []*types.Transaction
+}
+
+// p2pNetwork holds what the handshake needs to look like a syncing node on a chain
+type p2pNetwork struct {
+	genesis *types.Block
+	config  *params.ChainConfig
+}
+
+// p2pNetworks lists chains with public devp2p transaction gossip
+var p2pNetworks = map[int64]func() p2pNetwork{
+	1: func() p2pNetwork {
+		return p2pNetwork{genesis: core.DefaultGenesisBlock().ToBlock(), config: params.MainnetChainConfig}
+	},
+	11155111: func() p2pNetwork {
+		return p2pNetwork{genesis: core.DefaultSepoliaGenesisBlock().ToBlock(), config: params.SepoliaChainConfig}
+	},
+}
+
+// P2PMonitor peers directly with Ethereum nodes over devp2p and ingests
+// transactions from Transactions and NewPooledTransactionHashes gossip,
+// bypassing RPC providers. Endpoints are enode URLs used as static peers.
+type P2PMonitor struct {
+	*ChainMonitor
+	server  *p2p.Server
+	status  ethStatusPacket
+	signer  types.Signer
+	seen    *hashSet
+	nextReq atomic.Uint64
+}
+
+// NewP2PMonitor creates a devp2p monitor for an EVM chain with a known genesis
+func NewP2PMonitor(chain ChainInfo, endpoints []string, options ChainOptions, sink Sink, cache Cache, alerter *Alerter) (*P2PMonitor, error) {
+	network, ok := p2pNetworks[chain.ChainID]
+	if !ok {
+		return nil, fmt.Errorf("p2p ingestion is not supported for chain id %d", chain.ChainID)
+	}
+	net := network()
+
+	key, err := loadNodeKey(options.P2PNodeKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var static []*enode.Node
+	for _, endpoint := range endpoints {
+		node, err := enode.ParseV4(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid enode %q: %v", displayEndpoint(endpoint), err)
+		}
+		static = append(static, node)
+	}
+
+	var bootnodes []*enode.Node
+	if options.P2PDiscovery && chain.ChainID == 1 {
+		for _, url := range params.MainnetBootnodes {
+			bootnodes = append(bootnodes, enode.MustParse(url))
+		}
+	}
+
+	pm := &P2PMonitor{
+		ChainMonitor: NewChainMonitor(chain.Name, chain.ChainID, endpoints, options, sink, cache, alerter),
+		signer:       types.LatestSignerForChainID(big.NewInt(chain.ChainID)),
+		seen:         newHashSet(200000),
+		// Advertise ourselves at genesis: peers treat us as a syncing node,
+		// which the fork ID filter accepts, and we never need to serve blocks
+		status: ethStatusPacket{
+			ProtocolVersion: ethProtocolVersion,
+			NetworkID:       uint64(chain.ChainID),
+			TD:              net.genesis.Difficulty(),
+			Head:            net.genesis.Hash(),
+			Genesis:         net.genesis.Hash(),
+			ForkID:          forkid.NewID(net.config, net.genesis, 0, net.genesis.Time()),
+		},
+	}
+
+	maxPeers := options.P2PMaxPeers
+	if maxPeers <= 0 {
+		maxPeers = 50
+	}
+
+	pm.server = &p2p.Server{Config: p2p.Config{
+		PrivateKey:     key,
+		MaxPeers:       maxPeers,
+		Name:           "scorpius-ingestion",
+		StaticNodes:    static,
+		BootstrapNodes: bootnodes,
+		NoDiscovery:    !options.P2PDiscovery,
+		ListenAddr:     options.P2PListenAddr,
+		Protocols: []p2p.Protocol{{
+			Name:    "eth",
+			Version: ethProtocolVersion,
+			Length:  ethProtocolLength,
+			Run:     pm.runPeer,
+		}},
+	}}
+
+	return pm, nil
+}
+
+// loadNodeKey parses a hex node key, generating an ephemeral one when empty
+func loadNodeKey(hexKey string) (*ecdsa.PrivateKey, error) {
+	if hexKey == "" {
+		return crypto.GenerateKey()
+	}
+	key, err := crypto.HexToECDSA(hexKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p2p node key: %v", err)
+	}
+	return key, nil
+}
+
+// Start starts the devp2p server instead of the websocket monitor loop
+func (pm *P2PMonitor) Start() error {
+	if err := pm.server.Start(); err != nil {
+		return fmt.Errorf("failed to start p2p server: %v", err)
+	}
+	pm.logger.Info("started p2p monitor", "node", pm.server.Self().URLv4())
+
+	pm.queue.start(pm.deliverTransaction, pm.options.Workers)
+	pm.startBlockTracker()
+	pm.startBeaconEvents()
+	pm.startUserOps()
+	pm.startLiquidations()
+
+	go pm.peerCountLoop()
+	return nil
+}
+
+// Drain disconnects all peers, then drains the queued transactions
+func (pm *P2PMonitor) Drain(reason string) DrainResult {
+	pm.server.Stop()
+	return pm.ChainMonitor.Drain(reason)
+}
+
+// Status reports connected peers in place of RPC endpoints
+func (pm *P2PMonitor) Status() ChainStatus {
+	status := pm.ChainMonitor.Status()
+	peers := pm.server.PeersInfo()
+
+	status.Connected = len(peers) > 0
+	status.Endpoints = status.Endpoints[:0]
+	for _, peer := range peers {
+		status.Endpoints = append(status.Endpoints, EndpointStatus{
+			Endpoint:    "enode://" + peer.Network.RemoteAddress,
+			HealthScore: 1.0,
+			Active:      true,
+		})
+	}
+	return status
+}
+
+func (pm *P2PMonitor) peerCountLoop() {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pm.ctx.Done():
+			return
+		case <-ticker.C:
+			count := pm.server.PeerCount()
+			p2pPeers.WithLabelValues(pm.chainName).Set(float64(count))
+			if count > 0 {
+				pm.beginWarmup()
+			}
+		}
+	}
+}
+
+// runPeer handles the eth protocol for one peer until it disconnects
+func (pm *P2PMonitor) runPeer(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
+	if err := pm.handshake(rw); err != nil {
+		return err
+	}
+
+	for {
+		msg, err := rw.ReadMsg()
+		if err != nil {
+			return err
+		}
+		if msg.Size > maxEthMessageSize {
+			msg.Discard()
+			return fmt.Errorf("message too large: %d bytes", msg.Size)
+		}
+
+		err = pm.handlePeerMessage(rw, msg)
+		msg.Discard()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// handshake exchanges eth Status messages and checks the peer is on our network
+func (pm *P2PMonitor) handshake(rw p2p.MsgReadWriter) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- p2p.Send(rw, ethStatusMsg, &pm.status)
+	}()
+
+	msg, err := rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	defer msg.Discard()
+
+	if msg.Code != ethStatusMsg {
+		return fmt.Errorf("expected status message, got %d", msg.Code)
+	}
+
+	var status ethStatusPacket
+	if err := msg.Decode(&status); err != nil {
+		return fmt.Errorf("invalid status message: %v", err)
+	}
+	if status.NetworkID != pm.status.NetworkID || status.Genesis != pm.status.Genesis {
+		return fmt.Errorf("peer is on a different network")
+	}
+
+	return <-errc
+}
+
+// handlePeerMessage processes transaction gossip and ignores everything else
+func (pm *P2PMonitor) handlePeerMessage(rw p2p.MsgReadWriter, msg p2p.Msg) error {
+	switch msg.Code {
+	case ethTransactionsMsg:
+		p2pMessages.WithLabelValues(pm.chainName, "transactions").Inc()
+		var txs []*types.Transaction
+		if err := msg.Decode(&txs); err != nil {
+			return fmt.Errorf("invalid transactions message: %v", err)
+		}
+		pm.ingest(txs)
+
+	case ethNewPooledTransactionHashesMsg:
+		p2pMessages.WithLabelValues(pm.chainName, "pooled_hashes").Inc()
+		var announcement ethNewPooledHashesPacket
+		if err := msg.Decode(&announcement); err != nil {
+			return fmt.Errorf("invalid pooled hashes message: %v", err)
+		}
+
+		var unseen []common.Hash
+		for _, hash := range announcement.Hashes {
+			if !pm.seen.Contains(hash) {
+				unseen = append(unseen, hash)
+			}
+		}
+		if len(unseen) > 0 {
+			return p2p.Send(rw, ethGetPooledTransactionsMsg, &ethGetPooledTransactionsPacket{
+				RequestID: pm.nextReq.Add(1),
+				Hashes:    unseen,
+			})
+		}
+
+	case ethPooledTransactionsMsg:
+		p2pMessages.WithLabelValues(pm.chainName, "pooled_transactions").Inc()
+		var response ethPooledTransactionsPacket
+		if err := msg.Decode(&response); err != nil {
+			return fmt.Errorf("invalid pooled transactions message: %v", err)
+		}
+		pm.ingest(response.Transactions)
+
+	default:
+		p2pMessages.WithLabelValues(pm.chainName, "other").Inc()
+	}
+	return nil
+}
+
+// ingest publishes transactions not already received from another peer
+func (pm *P2PMonitor) ingest(txs []*types.Transaction) {
+	for _, gethTx := range txs {
+		if !pm.seen.Add(gethTx.Hash()) {
+			continue
+		}
+
+		tx, err := pm.convertGethTx(pm.signer, gethTx)
+		if err != nil {
+			pm.logger.Error("failed to convert p2p transaction", "tx_hash", gethTx.Hash().Hex(), "error", err)
+			continue
+		}
+		if err := pm.publishTransaction(tx); err != nil {
+			pm.logger.Error("failed to publish p2p transaction", "tx_hash", tx.Hash, "error", err)
+		}
+	}
+}
+
+// convertGethTx maps a go-ethereum transaction into the Transaction envelope
+func (cm *ChainMonitor) convertGethTx(signer types.Signer, gethTx *types.Transaction) (Transaction, error) {
+	from, err := types.Sender(signer, gethTx)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("failed to recover sender: %v", err)
+	}
+
+	var raw json.RawMessage
+	if data, err := gethTx.MarshalJSON(); err == nil {
+		var fields map[string]interface{}
+		if json.Unmarshal(data, &fields) == nil {
+			fields["from"] = from.Hex()
+			raw, _ = json.Marshal(fields)
+		}
+	}
+
+	tx := Transaction{
+		Hash:        gethTx.Hash().Hex(),
+		ChainID:     cm.chainID,
+		Chain:       cm.chainName,
+		ChainFamily: cm.family,
+		Type:        fmt.Sprintf("0x%x", gethTx.Type()),
+		From:        from.Hex(),
+		Value:       "0x" + gethTx.Value().Text(16),
+		Gas:         fmt.Sprintf("0x%x", gethTx.Gas()),
+		GasPrice:    "0x" + gethTx.GasPrice().Text(16),
+		Data:        "0x" + common.Bytes2Hex(gethTx.Data()),
+		Nonce:       fmt.Sprintf("0x%x", gethTx.Nonce()),
+		Status:      "pending",
+		Timestamp:   time.Now().Unix(),
+		Raw:         raw,
+	}
+	if to := gethTx.To(); to != nil {
+		tx.To = to.Hex()
+	}
+
+	if gethTx.Type() >= types.DynamicFeeTxType {
+		tx.MaxFeePerGas = "0x" + gethTx.GasFeeCap().Text(16)
+		tx.MaxPriorityFeePerGas = "0x" + gethTx.GasTipCap().Text(16)
+	}
+	if gethTx.Type() == types.BlobTxType {
+		tx.MaxFeePerBlobGas = "0x" + gethTx.BlobGasFeeCap().Text(16)
+		for _, hash := range gethTx.BlobHashes() {
+			tx.BlobVersionedHashes = append(tx.BlobVersionedHashes, hash.Hex())
+		}
+	}
+	for _, tuple := range gethTx.AccessList() {
+		entry := AccessTuple{Address: tuple.Address.Hex(), StorageKeys: []string{}}
+		for _, key := range tuple.StorageKeys {
+			entry.StorageKeys = append(entry.StorageKeys, key.Hex())
+		}
+		tx.AccessList = append(tx.AccessList, entry)
+	}
+
+	return tx, nil
+}
+
+// hashSet is a bounded set of recently seen transaction hashes
+type hashSet struct {
+	mu    sync.Mutex
+	items map[common.Hash]struct{}
+	ring  []common.Hash
+	next  int
+}
+
+func newHashSet(size int) *hashSet {
+	return &hashSet{
+		items: make(map[common.Hash]struct{}, size),
+		ring:  make([]common.Hash, size),
+	}
+}
+
+// Contains reports whether hash was recently added
+func (s *hashSet) Contains(hash common.Hash) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.items[hash]
+	return ok
+}
+
+// Add inserts hash, evicting the oldest entry when full; it returns false if already present
+func (s *hashSet) Add(hash common.Hash) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.items[hash]; ok {
+		return false
+	}
+
+	if evicted := s.ring[s.next]; evicted != (common.Hash{}) {
+		delete(s.items, evicted)
+	}
+	s.ring[s.next] = hash
+	s.next = (s.next + 1) % len(s.ring)
+	s.items[hash] = struct{}{}
+	return true
+}
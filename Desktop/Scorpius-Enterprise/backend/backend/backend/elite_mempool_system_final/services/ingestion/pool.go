@@ -0,0 +1,121 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// transactionPool recycles Transaction envelopes between the websocket
+// readers and the publish workers so steady-state ingest does not allocate
+// a fresh envelope per transaction
+var transactionPool = sync.Pool{
+	New: func() interface{} { return new(Transaction) },
+}
+
+// acquireTransaction returns a pooled envelope holding a copy of tx
+func acquireTransaction(tx Transaction) *Transaction {
+	pooled := transactionPool.Get().(*Transaction)
+	*pooled = tx
+	return pooled
+}
+
+// recycleTransaction returns an envelope to the pool. Slices and maps are
+// dropped rather than reused because archivers and batchers keep copies
+func recycleTransaction(tx *Transaction) {
+	*tx = Transaction{}
+	transactionPool.Put(tx)
+}
+
+// detachTransaction returns an unpooled copy of tx that stays valid after
+// tx is recycled. Recycling drops slices and maps, so a shallow copy suffices.
+func detachTransaction(tx *Transaction) *Transaction {
+	detached := *tx
+	return &detached
+}
+
+// kafkaMessage is a produce request with the storage its topic pointer and
+// header values refer to, so filling it in does not allocate
+type kafkaMessage struct {
+	kafka.Message
+	topic       string
+	headerBytes []byte
+}
+
+// kafkaMessagePool recycles produce requests. librdkafka copies the key,
+// value and headers during Produce, so a message can be reused as soon as
+// Produce returns
+var kafkaMessagePool = sync.Pool{
+	New: func() interface{} { return new(kafkaMessage) },
+}
+
+// fillKafkaMessage resets msg for topic, reusing its header slice and
+// storage, with headers in a stable order
+func fillKafkaMessage(msg *kafkaMessage, topic string, key, value []byte, headers map[string]string) {
+	msg.topic = topic
+	msg.Message = kafka.Message{
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &msg.topic,
+			Partition: kafka.PartitionAny,
+		},
+		Key:     key,
+		Value:   value,
+		Headers: msg.Headers[:0],
+	}
+
+	var scratch [16]string
+	names := scratch[:0]
+	size := 0
+	for name, value := range headers {
+		names = append(names, name)
+		size += len(value)
+	}
+	sort.Strings(names)
+
+	// Header values share one buffer, sized up front so appending never
+	// moves the values already sliced from it
+	msg.headerBytes = slices.Grow(msg.headerBytes[:0], size)
+	for _, name := range names {
+		start := len(msg.headerBytes)
+		msg.headerBytes = append(msg.headerBytes, headers[name]...)
+		msg.Headers = append(msg.Headers, kafka.Header{Key: name, Value: msg.headerBytes[start:len(msg.headerBytes):len(msg.headerBytes)]})
+	}
+}
+
+// publishBuffers is the scratch space produce builds a transaction's
+// messages in. Sinks do not keep the key, value or headers past Publish, so
+// the buffers go back to the pool once the transaction is published.
+type publishBuffers struct {
+	topics  []string
+	targets []encodeTarget
+	key     []byte
+	value   []byte
+	headers map[string]string
+}
+
+// maxPooledValue caps the value buffer kept in the pool, so one huge
+// transaction does not pin its memory
+const maxPooledValue = 64 << 10
+
+var publishBufferPool = sync.Pool{
+	New: func() interface{} { return &publishBuffers{headers: make(map[string]string, 12)} },
+}
+
+// acquirePublishBuffers returns empty scratch buffers
+func acquirePublishBuffers() *publishBuffers {
+	return publishBufferPool.Get().(*publishBuffers)
+}
+
+// releasePublishBuffers empties b and returns it to the pool
+func releasePublishBuffers(b *publishBuffers) {
+	clear(b.topics)
+	clear(b.targets)
+	b.topics, b.targets = b.topics[:0], b.targets[:0]
+	if cap(b.value) > maxPooledValue {
+		b.value = nil
+	}
+	clear(b.headers)
+	publishBufferPool.Put(b)
+}
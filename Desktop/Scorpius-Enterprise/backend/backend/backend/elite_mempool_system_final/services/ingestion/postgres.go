@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresConfig configures the Postgres/TimescaleDB store
+type PostgresConfig struct {
+	DSN            string
+	BatchSize      int
+	FlushInterval  time.Duration
+	Timescale      bool
+	HealthInterval time.Duration
+}
+
+// loadPostgresConfig reads POSTGRES_* settings; persistence is disabled without a DSN
+func loadPostgresConfig() PostgresConfig {
+	return PostgresConfig{
+		DSN:            getEnv("POSTGRES_DSN"),
+		BatchSize:      getEnvInt("POSTGRES_BATCH_SIZE", 5000),
+		FlushInterval:  getEnvDuration("POSTGRES_FLUSH_INTERVAL", time.Second),
+		Timescale:      getEnvBool("POSTGRES_TIMESCALE", false),
+		HealthInterval: getEnvDuration("POSTGRES_HEALTH_INTERVAL", 30*time.Second),
+	}
+}
+
+// postgresMigration is one schema change, applied once in version order
+type postgresMigration struct {
+	version    int
+	statements []string
+	// timescale migrations run only when POSTGRES_TIMESCALE is enabled
+	timescale bool
+}
+
+// postgresMigrations is append-only: never edit an applied migration, add a new one
+var postgresMigrations = []postgresMigration{
+	{version: 1, statements: []string{
+		`CREATE TABLE IF NOT EXISTS transactions (
+			seen_at                  TIMESTAMPTZ NOT NULL,
+			chain                    TEXT NOT NULL,
+			chain_id                 BIGINT NOT NULL,
+			hash                     TEXT NOT NULL,
+			type                     TEXT,
+			from_address             TEXT,
+			to_address               TEXT,
+			value                    NUMERIC(78, 0),
+			gas                      BIGINT,
+			gas_price                NUMERIC(78, 0),
+			max_fee_per_gas          NUMERIC(78, 0),
+			max_priority_fee_per_gas NUMERIC(78, 0),
+			nonce                    BIGINT,
+			data                     TEXT,
+			status                   TEXT NOT NULL,
+			tags                     TEXT[]
+		)`,
+		`CREATE INDEX IF NOT EXISTS transactions_chain_seen_at_idx ON transactions (chain, seen_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS transactions_hash_idx ON transactions (hash)`,
+		`CREATE INDEX IF NOT EXISTS transactions_from_idx ON transactions (from_address, seen_at DESC)`,
+		`CREATE TABLE IF NOT EXISTS endpoint_health (
+			recorded_at  TIMESTAMPTZ NOT NULL,
+			chain        TEXT NOT NULL,
+			endpoint     TEXT NOT NULL,
+			health_score DOUBLE PRECISION NOT NULL,
+			active       BOOLEAN NOT NULL,
+			disabled     TEXT
+		)`,
+		`CREATE INDEX IF NOT EXISTS endpoint_health_chain_idx ON endpoint_health (chain, recorded_at DESC)`,
+	}},
+	{version: 2, timescale: true, statements: []string{
+		`CREATE EXTENSION IF NOT EXISTS timescaledb`,
+		`SELECT create_hypertable('transactions', 'seen_at', if_not_exists => TRUE, migrate_data => TRUE)`,
+		`SELECT create_hypertable('endpoint_health', 'recorded_at', if_not_exists => TRUE, migrate_data => TRUE)`,
+	}},
+}
+
+// postgresMigrationLock is the advisory lock key serializing migrations across replicas
+const postgresMigrationLock = 0x5c0e91
+
+// PostgresStore persists transactions and endpoint health snapshots for SQL
+// analytics, independent of Kafka retention
+type PostgresStore struct {
+	pool    *pgxpool.Pool
+	batcher *archiveBatcher
+}
+
+// NewPostgresStore connects and applies pending migrations
+func NewPostgresStore(config PostgresConfig) (*PostgresStore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	pool, err := pgxpool.New(ctx, config.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Postgres: %v", err)
+	}
+
+	if err := migratePostgres(ctx, pool, config.Timescale); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	s := &PostgresStore{pool: pool}
+	s.batcher = newArchiveBatcher(s.Name(), config.BatchSize, config.FlushInterval, s.insert)
+	return s, nil
+}
+
+// migratePostgres applies migrations newer than the recorded schema version
+func migratePostgres(ctx context.Context, pool *pgxpool.Pool, timescale bool) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire Postgres connection: %v", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, postgresMigrationLock); err != nil {
+		return fmt.Errorf("failed to take migration lock: %v", err)
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, postgresMigrationLock)
+
+	if _, err := conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %v", err)
+	}
+
+	applied := make(map[int]bool)
+	rows, err := conn.Query(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %v", err)
+	}
+	versions, err := pgx.CollectRows(rows, pgx.RowTo[int32])
+	if err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %v", err)
+	}
+	for _, v := range versions {
+		applied[int(v)] = true
+	}
+
+	for _, migration := range postgresMigrations {
+		if applied[migration.version] || (migration.timescale && !timescale) {
+			continue
+		}
+
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		for _, statement := range migration.statements {
+			if _, err := tx.Exec(ctx, statement); err != nil {
+				tx.Rollback(ctx)
+				return fmt.Errorf("migration %d failed: %v", migration.version, err)
+			}
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, migration.version); err != nil {
+			tx.Rollback(ctx)
+			return fmt.Errorf("migration %d failed: %v", migration.version, err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("migration %d failed: %v", migration.version, err)
+		}
+		slog.Info("applied Postgres migration", "version", migration.version)
+	}
+	return nil
+}
+
+// Name returns the archiver name
+func (s *PostgresStore) Name() string {
+	return "postgres"
+}
+
+// Archive queues tx for the next COPY batch
+func (s *PostgresStore) Archive(tx Transaction) {
+	s.batcher.Add(tx)
+}
+
+// insert writes a batch with COPY
+func (s *PostgresStore) insert(txs []Transaction) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	columns := []string{"seen_at", "chain", "chain_id", "hash", "type", "from_address", "to_address", "value", "gas",
+		"gas_price", "max_fee_per_gas", "max_priority_fee_per_gas", "nonce", "data", "status", "tags"}
+
+	_, err := s.pool.CopyFrom(ctx, pgx.Identifier{"transactions"}, columns, pgx.CopyFromSlice(len(txs), func(i int) ([]interface{}, error) {
+		tx := txs[i]
+		tags := make([]string, 0, len(tx.Tags))
+		for _, tag := range tx.Tags {
+			tags = append(tags, tag.Tag)
+		}
+		return []interface{}{
+			time.Unix(tx.Timestamp, 0),
+			tx.Chain,
+			tx.ChainID,
+			tx.Hash,
+			tx.Type,
+			tx.From,
+			tx.To,
+			pgNumeric(tx.Value),
+			int64(hexToUint64(tx.Gas)),
+			pgNumeric(tx.GasPrice),
+			pgNumeric(tx.MaxFeePerGas),
+			pgNumeric(tx.MaxPriorityFeePerGas),
+			int64(hexToUint64(tx.Nonce)),
+			tx.Data,
+			tx.Status,
+			tags,
+		}, nil
+	}))
+	return err
+}
+
+// pgNumeric converts a hex quantity to NUMERIC, NULL when absent
+func pgNumeric(value string) pgtype.Numeric {
+	if value == "" {
+		return pgtype.Numeric{}
+	}
+	return pgtype.Numeric{Int: hexToBig(value), Valid: true}
+}
+
+// RecordHealth writes one endpoint health snapshot per endpoint
+func (s *PostgresStore) RecordHealth(ctx context.Context, chains []ChainStatus) error {
+	now := time.Now()
+	batch := &pgx.Batch{}
+	for _, chain := range chains {
+		for _, endpoint := range chain.Endpoints {
+			batch.Queue(`INSERT INTO endpoint_health (recorded_at, chain, endpoint, health_score, active, disabled)
+				VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))`,
+				now, chain.Chain, endpoint.Endpoint, endpoint.HealthScore, endpoint.Active, endpoint.Disabled)
+		}
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+	return s.pool.SendBatch(ctx, batch).Close()
+}
+
+// RunHealthSnapshots records chain endpoint health every interval until ctx is cancelled
+func (s *PostgresStore) RunHealthSnapshots(ctx context.Context, interval time.Duration, statuses func() []ChainStatus) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RecordHealth(ctx, statuses()); err != nil {
+				slog.Error("failed to record endpoint health", "error", err)
+			}
+		}
+	}
+}
+
+// Close flushes buffered transactions and closes the pool
+func (s *PostgresStore) Close() {
+	s.batcher.Close()
+	s.pool.Close()
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	privateTransactions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_private_transactions_total",
+			Help: "Confirmed transactions never seen in the public mempool, by block builder",
+		},
+		[]string{"chain", "builder"},
+	)
+
+	privateFlowShare = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_private_flow_ratio",
+			Help: "Share of the last confirmed block's transactions never seen in the public mempool",
+		},
+		[]string{"chain"},
+	)
+)
+
+// PrivateFlowConfig configures private order flow detection
+type PrivateFlowConfig struct {
+	Enabled bool
+	Topic   string
+}
+
+// loadPrivateFlowConfig reads PRIVATE_FLOW_* settings
+func loadPrivateFlowConfig() PrivateFlowConfig {
+	return PrivateFlowConfig{
+		Enabled: getEnvBool("PRIVATE_FLOW_DETECTION", false),
+		Topic:   getEnvOrDefault("PRIVATE_FLOW_TOPIC", "private_flow"),
+	}
+}
+
+// knownBuilders maps Ethereum block builder fee recipients to builder names;
+// other builders are named from the block's extra data when it is readable
+var knownBuilders = map[string]string{
+	"0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5": "beaverbuild",
+	"0x4838b106fce9647bdf1e7877bf73ce8b0bad5f97": "titan",
+	"0x1f9090aae28b8a3dceadf281b0f12828e676c326": "rsync",
+	"0xdafea492d9c6733ae3d56b7ed1adb60692c98bc5": "flashbots",
+}
+
+// PrivateTransaction is a confirmed transaction that was never seen pending
+type PrivateTransaction struct {
+	Chain            string    `json:"chain"`
+	ChainID          int64     `json:"chain_id"`
+	Hash             string    `json:"hash"`
+	From             string    `json:"from"`
+	To               string    `json:"to"`
+	Nonce            string    `json:"nonce"`
+	BlockNumber      uint64    `json:"block_number"`
+	BlockHash        string    `json:"block_hash"`
+	TransactionIndex int       `json:"transaction_index"`
+	GasPrice         string    `json:"gas_price"`
+	Builder          string    `json:"builder,omitempty"`
+	FeeRecipient     string    `json:"fee_recipient"`
+	DetectedAt       time.Time `json:"detected_at"`
+}
+
+// privateFlowDetector flags confirmed transactions that this service never
+// saw in the public mempool, which most likely reached the builder through
+// a private relay or bundle.
+//
+// A transaction counts as seen if its dedup key is still in Redis, so any
+// instance ingesting the chain contributes and transactions pending longer
+// than the dedup TTL are reported as private. Blocks are skipped while the
+// chain is warming up or disconnected, when every transaction would look
+// private.
+type privateFlowDetector struct {
+	monitor *ChainMonitor
+	topic   string
+}
+
+// HandleBlock is the detector's blockHandler
+func (d *privateFlowDetector) HandleBlock(block *confirmedBlock) {
+	cm := d.monitor
+	if len(block.Transactions) == 0 || cm.inWarmup() {
+		return
+	}
+
+	// P2P chains have no active endpoint; peers come and go individually
+	cm.mu.RLock()
+	connected := cm.activeEndpoint != "" || cm.options.IngestMode == IngestModeP2P
+	warmupUntil := cm.warmupUntil
+	cm.mu.RUnlock()
+	if !connected || block.Timestamp < warmupUntil.Unix() {
+		return
+	}
+
+	keys := make([]string, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		keys[i] = cm.dedupKey(tx.Hash)
+	}
+	seen, err := cm.cache.Exists(cm.ctx, keys...)
+	if err != nil {
+		cm.logger.Warn("failed to check block transactions against the mempool", "block", block.Number, "error", err)
+		return
+	}
+
+	builder := blockBuilder(block)
+	private := 0
+	for i, tx := range block.Transactions {
+		// The builder's payment to the proposer never passes through the mempool
+		if seen[i] || tx.From == block.FeeRecipient {
+			continue
+		}
+		private++
+		privateTransactions.WithLabelValues(cm.chainName, builderLabel(builder)).Inc()
+
+		d.publish(PrivateTransaction{
+			Chain:            cm.chainName,
+			ChainID:          cm.chainID,
+			Hash:             tx.Hash,
+			From:             tx.From,
+			To:               tx.To,
+			Nonce:            tx.Nonce,
+			BlockNumber:      block.Number,
+			BlockHash:        block.Hash,
+			TransactionIndex: tx.Index(),
+			GasPrice:         effectiveGasPrice(tx),
+			Builder:          builder,
+			FeeRecipient:     block.FeeRecipient,
+			DetectedAt:       time.Now(),
+		})
+	}
+	privateFlowShare.WithLabelValues(cm.chainName).Set(float64(private) / float64(len(block.Transactions)))
+}
+
+// publish produces a private transaction to the private flow topic
+func (d *privateFlowDetector) publish(event PrivateTransaction) {
+	cm := d.monitor
+	data, err := json.Marshal(event)
+	if err != nil {
+		cm.logger.Error("failed to marshal private transaction", "tx_hash", event.Hash, "error", err)
+		return
+	}
+
+	topic := expandTopic(d.topic, event.Chain, event.ChainID, cm.family)
+	headers := map[string]string{
+		"chain_id":   fmt.Sprintf("%d", event.ChainID),
+		"chain_name": event.Chain,
+		"format":     FormatJSON,
+	}
+	if err := cm.sink.Publish(cm.ctx, topic, []byte(event.Hash), data, headers); err != nil {
+		cm.logger.Warn("failed to publish private transaction", "tx_hash", event.Hash, "error", err)
+	}
+}
+
+// blockBuilder names the builder of a block from its fee recipient or, failing
+// that, its extra data (builders commonly put their name there)
+func blockBuilder(block *confirmedBlock) string {
+	if name, ok := knownBuilders[block.FeeRecipient]; ok {
+		return name
+	}
+
+	raw, err := hex.DecodeString(strings.TrimPrefix(block.ExtraData, "0x"))
+	if err != nil {
+		return ""
+	}
+	text := strings.TrimSpace(string(raw))
+	for _, r := range text {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+			return ""
+		}
+	}
+	return text
+}
+
+// builderLabel bounds metric cardinality to the known builders
+func builderLabel(builder string) string {
+	for _, name := range knownBuilders {
+		if builder == name {
+			return name
+		}
+	}
+	if builder == "" {
+		return "unknown"
+	}
+	return "other"
+}
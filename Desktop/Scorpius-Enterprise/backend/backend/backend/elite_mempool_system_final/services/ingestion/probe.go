@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	endpointBlockHeight = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_endpoint_block_height",
+			Help: "Head block reported by eth_blockNumber for each endpoint",
+		},
+		[]string{"chain", "endpoint"},
+	)
+
+	endpointBlockLag = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_endpoint_block_lag",
+			Help: "Blocks an endpoint trails the highest head seen across the chain's endpoints",
+		},
+		[]string{"chain", "endpoint"},
+	)
+
+	endpointProbes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_endpoint_probes_total",
+			Help: "Active endpoint probes by result (ok, lagging, syncing, error)",
+		},
+		[]string{"chain", "endpoint", "result"},
+	)
+)
+
+// Probe penalties multiply an endpoint's health score during selection
+const (
+	probeFactorSyncing = 0.2
+	probeFactorLagging = 0.4
+)
+
+// probeResult is one endpoint's answer to eth_blockNumber / eth_syncing
+type probeResult struct {
+	height  uint64
+	syncing bool
+	latency time.Duration
+	err     error
+}
+
+// probeEndpoint queries an endpoint's head height and sync state over HTTP
+func probeEndpoint(ctx context.Context, url string) probeResult {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	client := newRPCClient(url, 5*time.Second)
+
+	start := time.Now()
+	var height string
+	if err := client.Call(ctx, "eth_blockNumber", nil, &height); err != nil {
+		return probeResult{err: err}
+	}
+	latency := time.Since(start)
+
+	// eth_syncing returns false, or an object describing sync progress
+	var syncing json.RawMessage
+	if err := client.Call(ctx, "eth_syncing", nil, &syncing); err != nil {
+		return probeResult{err: err}
+	}
+
+	return probeResult{
+		height:  hexToUint64(height),
+		syncing: !isJSONNull(syncing) && string(syncing) != "false",
+		latency: latency,
+	}
+}
+
+// probeEndpoints compares head heights across the chain's RPC endpoints and
+// penalizes syncing or lagging nodes. If the connected endpoint is penalized
+// while a healthy alternative exists, the connection is dropped so the
+// monitor loop fails over.
+func (cm *ChainMonitor) probeEndpoints() {
+	results := make(map[string]probeResult)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, endpoint := range cm.endpoints {
+		endpointType, dialURL := splitEndpointType(endpoint)
+		if endpointType != EndpointRPC {
+			continue
+		}
+
+		wg.Add(1)
+		go func(endpoint, url string) {
+			defer wg.Done()
+			result := probeEndpoint(cm.ctx, url)
+
+			mu.Lock()
+			results[endpoint] = result
+			mu.Unlock()
+		}(endpoint, httpURLFor(dialURL))
+	}
+	wg.Wait()
+
+	var head uint64
+	for _, result := range results {
+		if result.err == nil && result.height > head {
+			head = result.height
+		}
+	}
+
+	factors := make(map[string]float64, len(results))
+	healthy := 0
+	for endpoint, result := range results {
+		factor := 1.0
+		status := "ok"
+
+		switch {
+		case result.err != nil:
+			// Connection failures already lower the score; HTTP may simply be unavailable
+			status = "error"
+		case result.syncing:
+			factor = probeFactorSyncing
+			status = "syncing"
+		case head-result.height > cm.options.MaxBlockLag:
+			factor = probeFactorLagging
+			status = "lagging"
+		}
+
+		if result.err == nil {
+			cm.recordLatency(endpoint, result.latency)
+			endpointBlockHeight.WithLabelValues(cm.chainName, endpointLabel(endpoint)).Set(float64(result.height))
+			endpointBlockLag.WithLabelValues(cm.chainName, endpointLabel(endpoint)).Set(float64(head - result.height))
+		}
+		endpointProbes.WithLabelValues(cm.chainName, endpointLabel(endpoint), status).Inc()
+
+		factors[endpoint] = factor
+		if factor == 1.0 {
+			healthy++
+		}
+	}
+
+	cm.mu.Lock()
+	cm.probeFactors = factors
+	active, conn := cm.activeEndpoint, cm.activeConn
+	cm.mu.Unlock()
+
+	if factor, ok := factors[active]; ok && factor < 1.0 && healthy > 0 && conn != nil {
+		reason := "lagging"
+		if results[active].syncing {
+			reason = "syncing"
+		}
+		cm.logger.Warn("endpoint unhealthy, failing over", "endpoint", displayEndpoint(active), "reason", reason, "height", results[active].height, "head", head)
+		cm.alerter.Raise(Alert{
+			Chain:    cm.chainName,
+			Category: "connection",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("endpoint %s is %s at height %d (head %d)", active, reason, results[active].height, head),
+		})
+		conn.Close()
+	}
+}
+
+// probeFactor returns the probe penalty for endpoint; callers hold cm.mu
+func (cm *ChainMonitor) probeFactor(endpoint string) float64 {
+	if factor, ok := cm.probeFactors[endpoint]; ok {
+		return factor
+	}
+	return 1.0
+}
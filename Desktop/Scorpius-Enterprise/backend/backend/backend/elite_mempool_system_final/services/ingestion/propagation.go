@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Propagation metrics compare how quickly endpoints deliver the same
+// transaction. Every instance records each transaction it receives as a
+// sighting; the sighting that claims the dedup key is the first seen, and
+// later ones observe how far behind the first endpoint they were. The
+// first-seen win rate of an endpoint is
+//
+//	rate(scorpius_endpoint_first_seen_total) / rate(scorpius_endpoint_sightings_total)
+//
+// Endpoints are labelled by scheme and host, without paths or credentials,
+// so the same provider lines up across instances with different API keys.
+var (
+	endpointSightings = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_endpoint_sightings_total",
+			Help: "Pending transactions received from each endpoint, including duplicates",
+		},
+		[]string{"chain", "endpoint"},
+	)
+
+	endpointFirstSeen = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_endpoint_first_seen_total",
+			Help: "Pending transactions each endpoint delivered before any other endpoint or instance",
+		},
+		[]string{"chain", "endpoint"},
+	)
+
+	propagationDelay = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "scorpius_propagation_delay_seconds",
+			Help:    "Delay between a transaction's first sighting and a later sighting on another endpoint",
+			Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{"chain", "endpoint", "first_endpoint"},
+	)
+)
+
+// sightingValue encodes when and where a transaction was first seen, stored
+// as the value of its dedup key
+func sightingValue(tx *Transaction) string {
+	return strconv.FormatInt(receivedAt(tx).UnixMicro(), 10) + " " + sightingEndpoint(tx)
+}
+
+// parseSighting decodes a dedup key value written by sightingValue
+func parseSighting(value string) (time.Time, string, bool) {
+	micros, endpoint, ok := strings.Cut(value, " ")
+	if !ok {
+		return time.Time{}, "", false
+	}
+	n, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return time.UnixMicro(n), endpoint, true
+}
+
+// sightingEndpoint labels the endpoint tx arrived on; sources without one,
+// such as MEV-Share, are reported as "none"
+func sightingEndpoint(tx *Transaction) string {
+	if tx.endpoint == "" {
+		return "none"
+	}
+	return displayEndpoint(tx.endpoint)
+}
+
+// recordDuplicateSighting observes how far behind the first sighting tx arrived
+func (cm *ChainMonitor) recordDuplicateSighting(tx *Transaction) {
+	value, err := cm.cache.Get(cm.deliverCtx, cm.dedupKey(tx.Hash))
+	if err != nil {
+		return
+	}
+	first, firstEndpoint, ok := parseSighting(string(value))
+	if !ok {
+		return
+	}
+
+	// Clock skew between instances can make a later sighting look earlier
+	delay := receivedAt(tx).Sub(first)
+	if delay < 0 {
+		delay = 0
+	}
+	propagationDelay.WithLabelValues(cm.chainName, sightingEndpoint(tx), firstEndpoint).Observe(delay.Seconds())
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"math"
+	"slices"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// FormatProtobuf encodes transactions with the layout in proto/transaction.proto
+const FormatProtobuf = "protobuf"
+
+// protobufEncoder writes transactions as scorpius.ingestion.v1.Transaction.
+// Encoding is done directly with protowire so the hot path avoids reflection
+// and there is no generated code to keep in sync beyond the field numbers.
+type protobufEncoder struct{}
+
+func (protobufEncoder) Format() string {
+	return FormatProtobuf
+}
+
+func (protobufEncoder) Encode(dst []byte, topic string, tx *Transaction) ([]byte, error) {
+	b := slices.Grow(dst, 512+len(tx.Data))
+
+	b = appendString(b, 1, tx.Hash)
+	b = appendInt64(b, 2, tx.ChainID)
+	b = appendString(b, 3, tx.Chain)
+	b = appendString(b, 4, tx.ChainFamily)
+	b = appendString(b, 5, tx.Type)
+	b = appendString(b, 6, tx.From)
+	b = appendString(b, 7, tx.To)
+	b = appendString(b, 8, tx.Value)
+	b = appendString(b, 9, tx.Gas)
+	b = appendString(b, 10, tx.GasPrice)
+	b = appendString(b, 11, tx.MaxFeePerGas)
+	b = appendString(b, 12, tx.MaxPriorityFeePerGas)
+	b = appendString(b, 13, tx.MaxFeePerBlobGas)
+	for _, hash := range tx.BlobVersionedHashes {
+		b = appendRepeatedString(b, 14, hash)
+	}
+	for _, tuple := range tx.AccessList {
+		b = appendMessage(b, 15, func(m []byte) []byte {
+			m = appendString(m, 1, tuple.Address)
+			for _, key := range tuple.StorageKeys {
+				m = appendRepeatedString(m, 2, key)
+			}
+			return m
+		})
+	}
+	b = appendString(b, 16, tx.Data)
+	b = appendString(b, 17, tx.Nonce)
+	b = appendInt64(b, 18, tx.Timestamp)
+	// proto3 optional fields are written whenever set, including zero
+	if tx.BlockNumber != nil {
+		b = protowire.AppendTag(b, 19, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(*tx.BlockNumber))
+	}
+	if tx.TransactionIndex != nil {
+		b = protowire.AppendTag(b, 20, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(int32(*tx.TransactionIndex)))
+	}
+	b = appendString(b, 21, tx.Status)
+	if tx.Canary {
+		b = protowire.AppendTag(b, 22, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	if t := tx.TokenTransfer; t != nil {
+		b = appendMessage(b, 23, func(m []byte) []byte {
+			m = appendString(m, 1, t.Method)
+			m = appendString(m, 2, t.Standard)
+			m = appendString(m, 3, t.Token)
+			m = appendString(m, 4, t.From)
+			m = appendString(m, 5, t.To)
+			m = appendString(m, 6, t.Amount)
+			return appendString(m, 7, t.TokenID)
+		})
+	}
+	for _, tag := range tx.Tags {
+		b = appendMessage(b, 24, func(m []byte) []byte {
+			m = appendString(m, 1, tag.Tag)
+			m = appendString(m, 2, tag.Source)
+			m = appendString(m, 3, tag.Note)
+			return appendInt64(m, 4, tag.CreatedAt.UnixMilli())
+		})
+	}
+	for _, input := range tx.Inputs {
+		b = appendMessage(b, 25, func(m []byte) []byte {
+			m = appendString(m, 1, input.PrevTxID)
+			m = appendInt64(m, 2, int64(input.PrevIndex))
+			m = appendString(m, 3, input.ScriptSig)
+			m = appendInt64(m, 4, int64(input.Sequence))
+			for _, item := range input.Witness {
+				m = appendRepeatedString(m, 5, item)
+			}
+			return m
+		})
+	}
+	for _, output := range tx.Outputs {
+		b = appendMessage(b, 26, func(m []byte) []byte {
+			m = appendInt64(m, 1, int64(output.Index))
+			m = appendInt64(m, 2, output.Value)
+			m = appendString(m, 3, output.ScriptPubKey)
+			m = appendString(m, 4, output.ScriptType)
+			return appendString(m, 5, output.Address)
+		})
+	}
+	if tx.Raw != nil {
+		b = protowire.AppendTag(b, 27, protowire.BytesType)
+		b = protowire.AppendBytes(b, tx.Raw)
+	}
+	if c := tx.MEV; c != nil {
+		b = appendMessage(b, 28, func(m []byte) []byte {
+			for _, kind := range c.Kinds {
+				m = appendRepeatedString(m, 1, kind)
+			}
+			m = appendString(m, 2, c.Protocol)
+			m = appendString(m, 3, c.Method)
+			m = appendDouble(m, 4, c.Confidence)
+			for _, reason := range c.Reasons {
+				m = appendRepeatedString(m, 5, reason)
+			}
+			return m
+		})
+	}
+	if tx.IsReplacement {
+		b = protowire.AppendTag(b, 29, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	b = appendString(b, 30, tx.ReplacedHash)
+	b = appendInt64(b, 31, int64(tx.NonceGap))
+	if s := tx.Simulation; s != nil {
+		b = appendMessage(b, 32, func(m []byte) []byte {
+			if s.Success {
+				m = protowire.AppendTag(m, 1, protowire.VarintType)
+				m = protowire.AppendVarint(m, 1)
+			}
+			m = appendString(m, 2, s.RevertReason)
+			m = appendString(m, 3, s.ReturnData)
+			for _, change := range s.StateDiff {
+				m = appendMessage(m, 4, func(c []byte) []byte {
+					c = appendString(c, 1, change.Address)
+					c = appendString(c, 2, change.Field)
+					c = appendString(c, 3, change.Slot)
+					c = appendString(c, 4, change.Before)
+					return appendString(c, 5, change.After)
+				})
+			}
+			return m
+		})
+	}
+	if s := tx.Swap; s != nil {
+		b = appendMessage(b, 33, func(m []byte) []byte {
+			m = appendString(m, 1, s.Protocol)
+			m = appendString(m, 2, s.Method)
+			m = appendString(m, 3, s.Router)
+			if s.ExactInput {
+				m = protowire.AppendTag(m, 4, protowire.VarintType)
+				m = protowire.AppendVarint(m, 1)
+			}
+			m = appendString(m, 5, s.TokenIn)
+			m = appendString(m, 6, s.TokenOut)
+			m = appendString(m, 7, s.AmountIn)
+			m = appendString(m, 8, s.AmountOut)
+			m = appendString(m, 9, s.AmountOutMin)
+			m = appendString(m, 10, s.AmountInMax)
+			for _, token := range s.Path {
+				m = appendRepeatedString(m, 11, token)
+			}
+			for _, pool := range s.Pools {
+				m = appendRepeatedString(m, 12, pool)
+			}
+			m = appendString(m, 13, s.Recipient)
+			return appendInt64(m, 14, s.Deadline)
+		})
+	}
+	b = appendAddressLabel(b, 34, tx.FromLabel)
+	b = appendAddressLabel(b, 35, tx.ToLabel)
+	if d := tx.Decimal; d != nil {
+		b = appendMessage(b, 36, func(m []byte) []byte {
+			m = appendString(m, 1, d.Value)
+			m = appendString(m, 2, d.Gas)
+			m = appendString(m, 3, d.GasPrice)
+			m = appendString(m, 4, d.MaxFeePerGas)
+			m = appendString(m, 5, d.MaxPriorityFeePerGas)
+			m = appendString(m, 6, d.MaxFeePerBlobGas)
+			return appendString(m, 7, d.Nonce)
+		})
+	}
+
+	return b, nil
+}
+
+// appendAddressLabel writes an AddressLabel field, omitting a nil label
+func appendAddressLabel(b []byte, num protowire.Number, label *AddressLabel) []byte {
+	if label == nil {
+		return b
+	}
+	return appendMessage(b, num, func(m []byte) []byte {
+		m = appendString(m, 1, label.Name)
+		m = appendString(m, 2, label.Category)
+		return appendString(m, 3, label.Source)
+	})
+}
+
+// appendString writes a singular string field, omitting the proto3 default
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	return appendRepeatedString(b, num, v)
+}
+
+// appendRepeatedString writes one element of a repeated string field
+func appendRepeatedString(b []byte, num protowire.Number, v string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// appendInt64 writes a singular int64/uint32 field, omitting the proto3 default
+func appendInt64(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+// appendDouble writes a singular double field, omitting the proto3 default
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+// appendMessage writes an embedded message field built by fill
+func appendMessage(b []byte, num protowire.Number, fill func([]byte) []byte) []byte {
+	m := fill(nil)
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
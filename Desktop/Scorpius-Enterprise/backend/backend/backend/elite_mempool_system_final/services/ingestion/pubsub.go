@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// SinkPubSub publishes to Google Cloud Pub/Sub
+const SinkPubSub = "pubsub"
+
+var pubsubMessages = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_pubsub_messages_total",
+		Help: "Messages published to Pub/Sub by outcome",
+	},
+	[]string{"topic", "result"},
+)
+
+// PubSubConfig configures the Pub/Sub sink
+type PubSubConfig struct {
+	Project     string
+	TopicPrefix string
+	BatchSize   int
+	BatchWindow time.Duration
+	// RetryTimeout bounds how long the client keeps retrying a publish
+	RetryTimeout time.Duration
+}
+
+// loadPubSubConfig reads PUBSUB_* settings
+func loadPubSubConfig() PubSubConfig {
+	return PubSubConfig{
+		Project:      getEnv("PUBSUB_PROJECT"),
+		TopicPrefix:  getEnv("PUBSUB_TOPIC_PREFIX"),
+		BatchSize:    getEnvInt("PUBSUB_BATCH_SIZE", 100),
+		BatchWindow:  getEnvDuration("PUBSUB_BATCH_WINDOW", 10*time.Millisecond),
+		RetryTimeout: getEnvDuration("PUBSUB_RETRY_TIMEOUT", 60*time.Second),
+	}
+}
+
+// PubSubSink publishes each topic to the Pub/Sub topic <prefix><topic>.
+// Headers become message attributes. The client library batches and retries
+// publishes; results are checked asynchronously.
+type PubSubSink struct {
+	client *pubsub.Client
+	config PubSubConfig
+	mu     sync.Mutex
+	topics map[string]*pubsub.Topic
+	wg     sync.WaitGroup
+}
+
+// NewPubSubSink creates a Pub/Sub client using Application Default Credentials
+func NewPubSubSink(config PubSubConfig) (*PubSubSink, error) {
+	if config.Project == "" {
+		return nil, fmt.Errorf("pubsub sink requires PUBSUB_PROJECT")
+	}
+
+	client, err := pubsub.NewClient(context.Background(), config.Project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Pub/Sub client: %v", err)
+	}
+
+	return &PubSubSink{
+		client: client,
+		config: config,
+		topics: make(map[string]*pubsub.Topic),
+	}, nil
+}
+
+// Name returns the sink name
+func (s *PubSubSink) Name() string {
+	return SinkPubSub
+}
+
+// topic returns the publisher for name, configuring batching on first use
+func (s *PubSubSink) topic(name string) *pubsub.Topic {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.topics[name]; ok {
+		return t
+	}
+
+	t := s.client.Topic(s.config.TopicPrefix + name)
+	t.PublishSettings.CountThreshold = s.config.BatchSize
+	t.PublishSettings.DelayThreshold = s.config.BatchWindow
+	t.PublishSettings.Timeout = s.config.RetryTimeout
+	s.topics[name] = t
+	return t
+}
+
+// Publish queues a message; failures are logged once the client gives up retrying
+func (s *PubSubSink) Publish(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
+	attributes := make(map[string]string, len(headers)+1)
+	for name, v := range headers {
+		attributes[name] = v
+	}
+	attributes["key"] = string(key)
+
+	// The client batches messages after Publish returns, by when value may be reused
+	t := s.topic(topic)
+	result := t.Publish(ctx, &pubsub.Message{Data: bytes.Clone(value), Attributes: attributes})
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if _, err := result.Get(context.Background()); err != nil {
+			pubsubMessages.WithLabelValues(topic, "failed").Inc()
+			slog.Error("failed to publish to Pub/Sub", "topic", t.ID(), "error", err)
+			return
+		}
+		pubsubMessages.WithLabelValues(topic, "success").Inc()
+	}()
+	return nil
+}
+
+// Close flushes pending publishes and closes the client
+func (s *PubSubSink) Close() {
+	s.mu.Lock()
+	for _, t := range s.topics {
+		t.Stop()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	s.client.Close()
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Queue overflow policies
+const (
+	// QueueDrop discards new transactions while the queue is full
+	QueueDrop = "drop"
+	// QueuePark blocks the reader until space frees up, dropping after the park timeout
+	QueuePark = "park"
+)
+
+var (
+	queueDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_queue_depth",
+			Help: "Transactions waiting in the per-chain publish queue",
+		},
+		[]string{"chain"},
+	)
+
+	queueCapacity = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_queue_capacity",
+			Help: "Capacity of the per-chain publish queue",
+		},
+		[]string{"chain"},
+	)
+
+	queueWorkersBusy = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_queue_workers_busy",
+			Help: "Publish workers currently delivering a transaction",
+		},
+		[]string{"chain"},
+	)
+
+	queueDropped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_queue_dropped_total",
+			Help: "Transactions dropped because the publish queue was full",
+		},
+		[]string{"chain", "policy"},
+	)
+)
+
+// txQueue decouples websocket readers from sink and Redis latency with a
+// bounded buffer, so a slow Kafka cluster applies backpressure (or sheds
+// load) instead of growing memory without limit
+type txQueue struct {
+	chain       string
+	items       chan *Transaction
+	depth       prometheus.Gauge
+	closing     chan struct{}
+	closeOnce   sync.Once
+	policy      string
+	parkTimeout time.Duration
+	wg          sync.WaitGroup
+	mu          sync.RWMutex
+	closed      bool
+	handled     atomic.Int64
+	failed      atomic.Int64
+	abandoned   atomic.Int64
+	abandon     atomic.Bool
+	logger      *slog.Logger
+}
+
+func newTxQueue(chain string, capacity int, policy string, parkTimeout time.Duration, logger *slog.Logger) *txQueue {
+	if capacity <= 0 {
+		capacity = 10000
+	}
+	if policy != QueueDrop {
+		policy = QueuePark
+	}
+
+	queueCapacity.WithLabelValues(chain).Set(float64(capacity))
+	return &txQueue{
+		chain:       chain,
+		items:       make(chan *Transaction, capacity),
+		depth:       queueDepth.WithLabelValues(chain),
+		closing:     make(chan struct{}),
+		policy:      policy,
+		parkTimeout: parkTimeout,
+		logger:      logger,
+	}
+}
+
+// start runs handler for queued transactions on a pool of workers. Ordering
+// across workers is not preserved; sinks key messages by hash, not arrival order
+func (q *txQueue) start(handler func(*Transaction) error, workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	busy := queueWorkersBusy.WithLabelValues(q.chain)
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for tx := range q.items {
+				q.depth.Set(float64(len(q.items)))
+				// Past the drain deadline the rest of the queue is discarded
+				if q.abandon.Load() {
+					q.abandoned.Add(1)
+					recycleTransaction(tx)
+					continue
+				}
+				busy.Inc()
+				if err := handler(tx); err != nil {
+					q.failed.Add(1)
+					q.logger.Error("failed to publish transaction", "tx_hash", tx.Hash, "error", err)
+				} else {
+					q.handled.Add(1)
+				}
+				busy.Dec()
+				recycleTransaction(tx)
+			}
+		}()
+	}
+}
+
+// Put enqueues tx according to the overflow policy. A parked Put gives up
+// as soon as Drain starts, so shutdown does not wait out the park timeout.
+func (q *txQueue) Put(tx Transaction) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return fmt.Errorf("publish queue for %s is closed", q.chain)
+	}
+
+	pooled := acquireTransaction(tx)
+	select {
+	case q.items <- pooled:
+		q.depth.Set(float64(len(q.items)))
+		return nil
+	default:
+	}
+
+	if q.policy == QueuePark {
+		timer := time.NewTimer(q.parkTimeout)
+		defer timer.Stop()
+
+		select {
+		case q.items <- pooled:
+			return nil
+		case <-timer.C:
+		case <-q.closing:
+			recycleTransaction(pooled)
+			q.abandoned.Add(1)
+			return fmt.Errorf("publish queue for %s is closed, dropped %s", q.chain, tx.Hash)
+		}
+	}
+
+	recycleTransaction(pooled)
+
+	queueDropped.WithLabelValues(q.chain, q.policy).Inc()
+	return fmt.Errorf("publish queue for %s is full, dropped %s", q.chain, tx.Hash)
+}
+
+// Drain stops accepting transactions and waits up to timeout for the queued
+// ones to be handled, discarding whatever is left at the deadline; a
+// non-positive timeout waits for all of them. It returns how many were
+// flushed and how many were dropped after the queue closed: by failing to
+// publish, by the deadline or while parked on the full queue.
+func (q *txQueue) Drain(timeout time.Duration) (flushed, dropped int) {
+	handled, failed, abandoned := q.handled.Load(), q.failed.Load(), q.abandoned.Load()
+
+	// Release parked producers first; they hold the read lock
+	q.closeOnce.Do(func() { close(q.closing) })
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.items)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-done:
+		case <-timer.C:
+			q.logger.Warn("drain timed out, dropping queued transactions", "timeout", timeout, "queued", len(q.items))
+			q.abandon.Store(true)
+		}
+	}
+	// Handlers already running finish; the workers then skip the rest
+	<-done
+	q.depth.Set(0)
+
+	flushed = int(q.handled.Load() - handled)
+	dropped = int(q.failed.Load() - failed + q.abandoned.Load() - abandoned)
+	return flushed, dropped
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTxQueueDropPolicy(t *testing.T) {
+	q := newTxQueue("ethereum", 1, QueueDrop, time.Minute, slog.Default())
+	if err := q.Put(Transaction{Hash: "0x01"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Put(Transaction{Hash: "0x02"}); err == nil {
+		t.Fatal("Put on a full queue with the drop policy succeeded")
+	}
+
+	var handled atomic.Int64
+	q.start(func(tx *Transaction) error {
+		handled.Add(1)
+		return nil
+	}, 1)
+	flushed, dropped := q.Drain(time.Second)
+	if flushed != 1 || dropped != 0 || handled.Load() != 1 {
+		t.Errorf("Drain() = %d flushed, %d dropped (handled %d); want 1, 0", flushed, dropped, handled.Load())
+	}
+}
+
+func TestTxQueueParkWaitsForSpace(t *testing.T) {
+	q := newTxQueue("ethereum", 1, QueuePark, time.Minute, slog.Default())
+	if err := q.Put(Transaction{Hash: "0x01"}); err != nil {
+		t.Fatal(err)
+	}
+
+	parked := make(chan error, 1)
+	go func() { parked <- q.Put(Transaction{Hash: "0x02"}) }()
+	var handled atomic.Int64
+	q.start(func(*Transaction) error {
+		handled.Add(1)
+		return nil
+	}, 1)
+
+	select {
+	case err := <-parked:
+		if err != nil {
+			t.Fatalf("parked Put failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("parked Put did not complete once the worker made space")
+	}
+	if _, dropped := q.Drain(time.Second); dropped != 0 || handled.Load() != 2 {
+		t.Errorf("handled %d, dropped %d; want 2, 0", handled.Load(), dropped)
+	}
+}
+
+func TestTxQueueParkTimeout(t *testing.T) {
+	q := newTxQueue("ethereum", 1, QueuePark, 10*time.Millisecond, slog.Default())
+	if err := q.Put(Transaction{Hash: "0x01"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Put(Transaction{Hash: "0x02"}); err == nil {
+		t.Fatal("Put parked past the park timeout succeeded")
+	}
+}
+
+func TestTxQueueDrainReleasesParkedPut(t *testing.T) {
+	// No workers run, so the second Put stays parked until Drain
+	q := newTxQueue("ethereum", 1, QueuePark, time.Hour, slog.Default())
+	if err := q.Put(Transaction{Hash: "0x01"}); err != nil {
+		t.Fatal(err)
+	}
+	parked := make(chan error, 1)
+	go func() { parked <- q.Put(Transaction{Hash: "0x02"}) }()
+	time.Sleep(20 * time.Millisecond)
+
+	drained := make(chan int, 1)
+	go func() {
+		_, dropped := q.Drain(0)
+		drained <- dropped
+	}()
+
+	select {
+	case dropped := <-drained:
+		if err := <-parked; err == nil {
+			t.Error("parked Put succeeded on a drained queue")
+		}
+		if dropped != 1 {
+			t.Errorf("Drain() dropped %d, want the parked transaction", dropped)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Drain waited for the parked Put's timeout")
+	}
+
+	if err := q.Put(Transaction{Hash: "0x03"}); err == nil {
+		t.Error("Put after Drain succeeded")
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package main
+
+// raceEnabled reports whether the race detector instruments the test
+// binary, which adds allocations of its own
+const raceEnabled = true
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// rateMeter tracks an event rate over a sliding window of one-second buckets
+type rateMeter struct {
+	mu      sync.Mutex
+	buckets []uint64
+	stamps  []int64
+	total   uint64
+}
+
+func newRateMeter(window time.Duration) *rateMeter {
+	size := int(window / time.Second)
+	if size < 1 {
+		size = 1
+	}
+	return &rateMeter{
+		buckets: make([]uint64, size),
+		stamps:  make([]int64, size),
+	}
+}
+
+// Mark records n events
+func (r *rateMeter) Mark(n uint64) {
+	now := time.Now().Unix()
+	idx := int(now % int64(len(r.buckets)))
+
+	r.mu.Lock()
+	if r.stamps[idx] != now {
+		r.stamps[idx] = now
+		r.buckets[idx] = 0
+	}
+	r.buckets[idx] += n
+	r.total += n
+	r.mu.Unlock()
+}
+
+// Rate returns events per second averaged over the window
+func (r *rateMeter) Rate() float64 {
+	now := time.Now().Unix()
+	oldest := now - int64(len(r.buckets))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var sum uint64
+	for i, stamp := range r.stamps {
+		if stamp > oldest && stamp <= now {
+			sum += r.buckets[i]
+		}
+	}
+	return float64(sum) / float64(len(r.buckets))
+}
+
+// Total returns the number of events recorded since creation
+func (r *rateMeter) Total() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.total
+}
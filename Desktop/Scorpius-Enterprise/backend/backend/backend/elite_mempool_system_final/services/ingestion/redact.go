@@ -0,0 +1,150 @@
+package main
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// redacted replaces secrets in logs, metric labels and alerts
+const redacted = "redacted"
+
+// urlPattern finds URLs, including typed endpoints such as bloxroute+wss://,
+// in free text
+var urlPattern = regexp.MustCompile(`[A-Za-z][A-Za-z0-9+.-]*://[^\s"'<>]+`)
+
+// knownSecrets holds every secret value the service has loaded, so they can
+// be removed from text wherever they appear
+var knownSecrets struct {
+	mu       sync.RWMutex
+	values   map[string]bool
+	replacer *strings.Replacer
+}
+
+// registerSecrets marks values as secret. Values too short to be credentials
+// are ignored so ordinary words are not redacted.
+func registerSecrets(values ...string) {
+	knownSecrets.mu.Lock()
+	defer knownSecrets.mu.Unlock()
+
+	added := false
+	for _, value := range values {
+		if len(value) < 8 || knownSecrets.values[value] {
+			continue
+		}
+		if knownSecrets.values == nil {
+			knownSecrets.values = make(map[string]bool)
+		}
+		knownSecrets.values[value] = true
+		added = true
+	}
+	if !added {
+		return
+	}
+
+	pairs := make([]string, 0, 2*len(knownSecrets.values))
+	for value := range knownSecrets.values {
+		pairs = append(pairs, value, redacted)
+	}
+	knownSecrets.replacer = strings.NewReplacer(pairs...)
+}
+
+// redact removes known secrets from s and masks the credentials in any URL
+// it contains: passwords, query values and path segments that look like API
+// keys
+func redact(s string) string {
+	knownSecrets.mu.RLock()
+	replacer := knownSecrets.replacer
+	knownSecrets.mu.RUnlock()
+	if replacer != nil {
+		s = replacer.Replace(s)
+	}
+	if !strings.Contains(s, "://") {
+		return s
+	}
+	return urlPattern.ReplaceAllStringFunc(s, redactURL)
+}
+
+// redactURL masks the credentials in one URL. It works on the raw text, so
+// placeholders such as {key} are kept as written.
+func redactURL(raw string) string {
+	scheme, rest, ok := strings.Cut(raw, "://")
+	if !ok {
+		return raw
+	}
+	rest, fragment, hasFragment := strings.Cut(rest, "#")
+	rest, query, hasQuery := strings.Cut(rest, "?")
+	authority, path, hasPath := strings.Cut(rest, "/")
+
+	if userinfo, host, ok := strings.Cut(authority, "@"); ok {
+		if user, _, hasPassword := strings.Cut(userinfo, ":"); hasPassword {
+			userinfo = user + ":" + redacted
+		}
+		authority = userinfo + "@" + host
+	}
+
+	var b strings.Builder
+	b.WriteString(scheme + "://" + authority)
+	if hasPath {
+		segments := strings.Split(path, "/")
+		for i, segment := range segments {
+			if looksLikeKey(segment) {
+				segments[i] = redacted
+			}
+		}
+		b.WriteString("/" + strings.Join(segments, "/"))
+	}
+	if hasQuery {
+		params := strings.Split(query, "&")
+		for i, param := range params {
+			if name, _, ok := strings.Cut(param, "="); ok {
+				params[i] = name + "=" + redacted
+			}
+		}
+		b.WriteString("?" + strings.Join(params, "&"))
+	}
+	if hasFragment {
+		b.WriteString("#" + fragment)
+	}
+	return b.String()
+}
+
+// looksLikeKey reports whether a path segment is shaped like a provider API
+// key: long, URL-safe and containing digits, unlike "v2" or "eth-mainnet"
+func looksLikeKey(segment string) bool {
+	if len(segment) < 16 {
+		return false
+	}
+	digits := false
+	for _, r := range segment {
+		switch {
+		case r >= '0' && r <= '9':
+			digits = true
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return digits
+}
+
+// endpointLabel is an endpoint as it appears in metric labels, without the
+// API key many providers embed in the URL
+func endpointLabel(endpoint string) string {
+	return redact(endpoint)
+}
+
+// redactAttr is the log handlers' ReplaceAttr hook: it redacts string
+// attributes, the message included, and errors
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(redact(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(redact(err.Error()))
+		}
+	}
+	return a
+}
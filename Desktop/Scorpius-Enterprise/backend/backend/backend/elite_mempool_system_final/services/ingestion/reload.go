@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// processEnv records which variables came from the real environment, so
+// values loaded from CONFIG_FILE never override them and can be withdrawn
+// again when they disappear from the file
+var processEnv = func() map[string]bool {
+	keys := make(map[string]bool)
+	for _, entry := range os.Environ() {
+		key, _, _ := strings.Cut(entry, "=")
+		keys[key] = true
+	}
+	return keys
+}()
+
+var (
+	fileEnvMu sync.Mutex
+	// fileEnv maps variables set from CONFIG_FILE to where they were defined
+	fileEnv = make(map[string]string)
+)
+
+// applyConfigFile loads settings from path into the environment that
+// loadConfig reads. Variables set in the process environment take precedence
+// over the file, and settings removed from the file are withdrawn.
+func applyConfigFile(path string) error {
+	values, sources, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	fileEnvMu.Lock()
+	defer fileEnvMu.Unlock()
+
+	for key := range fileEnv {
+		if _, ok := values[key]; !ok {
+			os.Unsetenv(key)
+			delete(fileEnv, key)
+		}
+	}
+	for key, value := range values {
+		if processEnv[key] {
+			continue
+		}
+		os.Setenv(key, value)
+		fileEnv[key] = sources[key]
+	}
+	return nil
+}
+
+// Reload re-reads configuration and reconciles chain monitors with it. Chains
+// that were added are started, removed chains are stopped, and chains whose
+// endpoints, options or topic routes changed are restarted; changed RPC
+// limits or provider keys restart every chain. With sharding, only chains
+// assigned to this instance run. Sink, storage and admin settings still
+// require a process restart.
+func (is *IngestionService) Reload() error {
+	is.reloadMu.Lock()
+	defer is.reloadMu.Unlock()
+
+	// Stop drains the monitors under reloadMu; do not restart them after it
+	if is.ctx.Err() != nil {
+		return fmt.Errorf("service is stopping")
+	}
+
+	if path := getEnv("CONFIG_FILE"); path != "" {
+		if err := applyConfigFile(path); err != nil {
+			return err
+		}
+	}
+	config, err := loadValidatedConfig()
+	if err != nil {
+		return err
+	}
+	logLevel.Set(parseLogLevel(config.LogLevel))
+
+	routesChanged := !reflect.DeepEqual(is.config.TopicRoutes, config.TopicRoutes) ||
+		!reflect.DeepEqual(is.config.ExprRoutes, config.ExprRoutes)
+	limitsChanged := !reflect.DeepEqual(is.config.RPCLimits, config.RPCLimits)
+	if limitsChanged {
+		rpcLimits.Configure(config.RPCLimits)
+	}
+
+	is.mu.RLock()
+	var stop []string
+	for chainName := range is.monitors {
+		endpoints, ok := config.ChainEndpoints[chainName]
+		if !ok || routesChanged || limitsChanged || !is.ownsChain(chainName) ||
+			!reflect.DeepEqual(endpoints, is.config.ChainEndpoints[chainName]) ||
+			!reflect.DeepEqual(config.ChainOptions[chainName], is.config.ChainOptions[chainName]) {
+			stop = append(stop, chainName)
+		}
+	}
+	is.mu.RUnlock()
+
+	for _, chainName := range stop {
+		is.stopMonitor(chainName, DrainReload)
+	}
+
+	is.mu.Lock()
+	is.config.ChainEndpoints = config.ChainEndpoints
+	is.config.ChainOptions = config.ChainOptions
+	is.config.TopicTemplate = config.TopicTemplate
+	is.config.TopicRoutes = config.TopicRoutes
+	is.config.ExprRoutes = config.ExprRoutes
+	is.config.RPCLimits = config.RPCLimits
+	is.mu.Unlock()
+
+	started := 0
+	for chainName, endpoints := range config.ChainEndpoints {
+		is.mu.RLock()
+		_, running := is.monitors[chainName]
+		is.mu.RUnlock()
+		if running || !is.ownsChain(chainName) {
+			continue
+		}
+
+		if err := is.startMonitor(chainName, endpoints); err != nil {
+			slog.Error("failed to start monitor after reload", "chain", chainName, "error", err)
+			continue
+		}
+		started++
+	}
+
+	if is.config.CanaryInterval > 0 && (len(stop) > 0 || started > 0) {
+		is.startCanaries()
+	}
+
+	is.updateShardOwned()
+	slog.Info("configuration reloaded", "stopped", len(stop), "started", started)
+	return nil
+}
+
+// watchConfig reloads whenever the config file changes. The directory is
+// watched rather than the file so editors that replace it atomically are seen.
+func (is *IngestionService) watchConfig(path string) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Warn("failed to watch config file", "path", path, "error", err)
+		return
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		slog.Warn("failed to watch config file", "path", path, "error", err)
+		return
+	}
+
+	// Editors often emit several events per save; settle before reloading
+	var debounce <-chan time.Time
+	name := filepath.Clean(path)
+
+	for {
+		select {
+		case <-is.ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) == name && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+				debounce = time.After(500 * time.Millisecond)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("config watcher error", "error", err)
+		case <-debounce:
+			debounce = nil
+			slog.Info("config file changed, reloading", "path", path)
+			if err := is.Reload(); err != nil {
+				slog.Error("failed to reload configuration", "error", err)
+			}
+		}
+	}
+}
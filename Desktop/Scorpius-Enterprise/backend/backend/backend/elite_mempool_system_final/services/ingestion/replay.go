@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/parquet-go/parquet-go"
+)
+
+// replayProgressInterval is how often a replay logs its progress
+const replayProgressInterval = 10 * time.Second
+
+// replayMessage is one archived message to publish again
+type replayMessage struct {
+	at      time.Time
+	topic   string
+	key     []byte
+	value   []byte
+	headers map[string]string
+}
+
+// replayer publishes archived messages with their original spacing, divided
+// by speed. A speed of zero publishes as fast as the sink accepts.
+type replayer struct {
+	sink      Sink
+	speed     float64
+	output    string
+	started   time.Time
+	first     time.Time
+	published int
+	reported  time.Time
+}
+
+// outputTopic names the topic a message archived from topic is replayed to
+func (r *replayer) outputTopic(topic, chain string, chainID int64, family string) string {
+	return expandTopic(strings.ReplaceAll(r.output, "{topic}", topic), chain, chainID, family)
+}
+
+// publish waits until the message is due and publishes it. Messages older
+// than one already published, from another partition or file, go at once.
+func (r *replayer) publish(ctx context.Context, msg replayMessage) error {
+	if r.published == 0 {
+		r.started, r.first, r.reported = time.Now(), msg.at, time.Now()
+	}
+	if r.speed > 0 {
+		offset := time.Duration(float64(msg.at.Sub(r.first)) / r.speed)
+		if wait := time.Until(r.started.Add(offset)); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+
+	msg.headers["replay"] = "true"
+	if err := r.sink.Publish(ctx, msg.topic, msg.key, msg.value, msg.headers); err != nil {
+		return fmt.Errorf("failed to publish to %s: %v", msg.topic, err)
+	}
+	r.published++
+
+	if time.Since(r.reported) >= replayProgressInterval {
+		r.reported = time.Now()
+		slog.Info("replaying", "published", r.published, "archived_at", msg.at.UTC(), "elapsed", time.Since(r.started).Round(time.Second))
+	}
+	return nil
+}
+
+// kafkaReplay reads a topic's messages from an offset range of every
+// partition, or of one
+type kafkaReplay struct {
+	brokers   string
+	topic     string
+	partition int
+	from      int64
+	to        int64
+}
+
+// run re-publishes the range. Without an end offset it stops at the end of
+// each partition as of the start of the replay.
+func (k *kafkaReplay) run(ctx context.Context, r *replayer) error {
+	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":    k.brokers,
+		"group.id":             "scorpius-replay-" + instanceID(),
+		"enable.auto.commit":   false,
+		"isolation.level":      "read_committed",
+		"enable.partition.eof": true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create replay consumer: %v", err)
+	}
+	defer consumer.Close()
+
+	metadata, err := consumer.GetMetadata(&k.topic, false, 10000)
+	if err != nil {
+		return fmt.Errorf("failed to read metadata for %s: %v", k.topic, err)
+	}
+	topicMetadata, ok := metadata.Topics[k.topic]
+	if !ok || topicMetadata.Error.Code() != kafka.ErrNoError {
+		return fmt.Errorf("topic %s not found", k.topic)
+	}
+
+	// Partitions left to read and the offset each one stops before
+	ends := make(map[int32]int64)
+	var assignment []kafka.TopicPartition
+	for _, p := range topicMetadata.Partitions {
+		if k.partition >= 0 && p.ID != int32(k.partition) {
+			continue
+		}
+		low, high, err := consumer.QueryWatermarkOffsets(k.topic, p.ID, 10000)
+		if err != nil {
+			return fmt.Errorf("failed to read offsets of %s/%d: %v", k.topic, p.ID, err)
+		}
+		from, end := k.from, high
+		if from < low {
+			from = low
+		}
+		if k.to > 0 && k.to < end {
+			end = k.to
+		}
+		if from >= end {
+			continue
+		}
+		ends[p.ID] = end
+		assignment = append(assignment, kafka.TopicPartition{Topic: &k.topic, Partition: p.ID, Offset: kafka.Offset(from)})
+		slog.Info("replaying partition", "topic", k.topic, "partition", p.ID, "from", from, "to", end)
+	}
+	if len(assignment) == 0 {
+		slog.Info("nothing to replay in the offset range", "topic", k.topic)
+		return nil
+	}
+	if err := consumer.Assign(assignment); err != nil {
+		return fmt.Errorf("failed to assign partitions: %v", err)
+	}
+
+	for len(ends) > 0 {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		switch event := consumer.Poll(500).(type) {
+		case *kafka.Message:
+			partition, offset := event.TopicPartition.Partition, int64(event.TopicPartition.Offset)
+			end, reading := ends[partition]
+			if !reading {
+				continue
+			}
+			if offset >= end {
+				delete(ends, partition)
+				continue
+			}
+			headers := make(map[string]string, len(event.Headers)+1)
+			for _, header := range event.Headers {
+				headers[header.Key] = string(header.Value)
+			}
+			headers["replay_source"] = fmt.Sprintf("%s/%d@%d", k.topic, partition, offset)
+			chainID, _ := strconv.ParseInt(headers["chain_id"], 10, 64)
+			msg := replayMessage{
+				at:      event.Timestamp,
+				topic:   r.outputTopic(k.topic, headers["chain_name"], chainID, ""),
+				key:     event.Key,
+				value:   event.Value,
+				headers: headers,
+			}
+			if err := r.publish(ctx, msg); err != nil {
+				return err
+			}
+			if offset+1 >= end {
+				delete(ends, partition)
+			}
+		case kafka.PartitionEOF:
+			delete(ends, event.Partition)
+		case kafka.Error:
+			if event.IsFatal() {
+				return event
+			}
+			slog.Warn("replay consumer error", "error", event)
+		}
+	}
+	return nil
+}
+
+// parquetReplay reads transactions from Parquet files written by the S3
+// exporter. Files are loaded into memory and replayed in the order the
+// transactions were seen.
+type parquetReplay struct {
+	paths    []string
+	chain    string
+	encoders *topicEncoders
+	template string
+}
+
+func (p *parquetReplay) run(ctx context.Context, r *replayer) error {
+	var files []string
+	for _, pattern := range p.paths {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid file pattern %q: %v", pattern, err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("no files match %s", pattern)
+		}
+		files = append(files, matches...)
+	}
+
+	type sourcedRow struct {
+		row  parquetTransaction
+		file string
+	}
+	var rows []sourcedRow
+	for _, file := range files {
+		fileRows, err := parquet.ReadFile[parquetTransaction](file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", file, err)
+		}
+		for _, row := range fileRows {
+			if p.chain == "" || row.Chain == p.chain {
+				rows = append(rows, sourcedRow{row: row, file: filepath.Base(file)})
+			}
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].row.SeenAt.Before(rows[j].row.SeenAt) })
+	slog.Info("replaying Parquet archives", "files", len(files), "transactions", len(rows))
+
+	for _, sourced := range rows {
+		tx := fromParquetRow(sourced.row)
+		base := expandTopic(p.template, tx.Chain, tx.ChainID, tx.ChainFamily)
+		topic := r.outputTopic(base, tx.Chain, tx.ChainID, tx.ChainFamily)
+
+		for _, target := range p.encoders.Targets(nil, topic) {
+			data, err := target.encoder.Encode(nil, target.topic, &tx)
+			if err != nil {
+				return fmt.Errorf("failed to encode transaction %s: %v", tx.Hash, err)
+			}
+			msg := replayMessage{
+				at:    sourced.row.SeenAt,
+				topic: target.topic,
+				key:   []byte(tx.Hash),
+				value: data,
+				headers: map[string]string{
+					"chain_id":       fmt.Sprintf("%d", tx.ChainID),
+					"chain_name":     tx.Chain,
+					"timestamp":      fmt.Sprintf("%d", tx.Timestamp),
+					"format":         target.encoder.Format(),
+					"schema_version": target.version,
+					"replay_source":  sourced.file,
+				},
+			}
+			if err := r.publish(ctx, msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cmdReplay re-publishes archived transactions, from a Kafka offset range
+// or Parquet archives, through the configured sink for backtesting
+func cmdReplay(args []string) int {
+	flags, configPath := newFlagSet("replay")
+	topic := flags.String("topic", "", "Kafka topic to replay")
+	partition := flags.Int("partition", -1, "only replay this partition of --topic")
+	from := flags.Int64("from-offset", 0, "first offset to replay in each partition")
+	to := flags.Int64("to-offset", 0, "offset to stop before in each partition (default: the end when the replay starts)")
+	files := flags.String("files", "", "comma-separated Parquet files or glob patterns from the S3 export, instead of --topic")
+	chain := flags.String("chain", "", "only replay this chain's transactions from --files")
+	output := flags.String("output-topic", "{topic}_replay", "topic to publish to; {topic} is the archived topic and {chain}, {chain_id} and {family} are expanded")
+	speed := flags.Float64("speed", 1, "replay speed relative to the original timing; 0 publishes as fast as possible")
+	dryRun := flags.Bool("dry-run", false, "read and pace the archive without publishing")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if (*topic == "") == (*files == "") {
+		fmt.Fprintln(os.Stderr, "replay needs exactly one of --topic or --files")
+		return 2
+	}
+	if *speed < 0 {
+		fmt.Fprintln(os.Stderr, "--speed must not be negative")
+		return 2
+	}
+	if *dryRun {
+		os.Setenv("DRY_RUN", "true")
+	}
+
+	config, err := loadCLIConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	setupLogging(config.LogLevel, config.LogFormat)
+
+	// The Redis Streams sink shares the cache's client, as in the service
+	cache, redisClient, err := newCache(config)
+	if err != nil {
+		slog.Error("failed to create cache", "error", err)
+		return 1
+	}
+	defer cache.Close()
+	sink, err := newSink(config.Sink, config, redisClient)
+	if err != nil {
+		slog.Error("failed to create sink", "error", err)
+		return 1
+	}
+	defer sink.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	r := &replayer{sink: sink, speed: *speed, output: *output, started: time.Now()}
+	if *topic != "" {
+		err = (&kafkaReplay{brokers: config.KafkaBrokers, topic: *topic, partition: *partition, from: *from, to: *to}).run(ctx, r)
+	} else {
+		encoders, encErr := newTopicEncoders(config)
+		if encErr != nil {
+			slog.Error("failed to create message encoders", "error", encErr)
+			return 1
+		}
+		err = (&parquetReplay{paths: splitNonEmpty(*files), chain: *chain, encoders: encoders, template: config.TopicTemplate}).run(ctx, r)
+	}
+
+	slog.Info("replay finished", "published", r.published, "elapsed", time.Since(r.started).Round(time.Millisecond))
+	if err != nil && err != context.Canceled {
+		slog.Error("replay failed", "error", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// rpcClient is a minimal JSON-RPC 2.0 client over HTTP, held to its
+// endpoint's rate limit. An endpoint with a {key} placeholder takes its
+// provider's keys in turn, retrying with the next key when one is limited.
+type rpcClient struct {
+	endpoint string
+	client   *http.Client
+	nextID   atomic.Uint64
+}
+
+// rpcError is a JSON-RPC error object
+type rpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+func newRPCClient(endpoint string, timeout time.Duration) *rpcClient {
+	return &rpcClient{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+// Call invokes method with params and decodes the result into result
+func (c *rpcClient) Call(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      c.nextID.Add(1),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		endpoint, key := rpcLimits.Resolve(c.endpoint)
+		limited, err := c.post(ctx, endpoint, method, body, result)
+		key.Observe(limited, err)
+		if !limited || attempt >= key.Retries() {
+			return err
+		}
+	}
+}
+
+// post sends one request to endpoint, reporting whether the provider
+// rejected it for exceeding the key's quota
+func (c *rpcClient) post(ctx context.Context, endpoint, method string, body []byte, result interface{}) (bool, error) {
+	if err := rpcLimits.For(endpoint).Wait(ctx); err != nil {
+		return false, fmt.Errorf("%s: %v", method, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("invalid rpc endpoint")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		// The url.Error would repeat the endpoint, API key included
+		if urlErr, ok := err.(*url.Error); ok {
+			return false, fmt.Errorf("%s: %v", method, urlErr.Err)
+		}
+		return false, fmt.Errorf("%s: %v", method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode == http.StatusTooManyRequests, fmt.Errorf("%s: unexpected status %s", method, resp.Status)
+	}
+
+	var envelope struct {
+		Result json.RawMessage `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return false, fmt.Errorf("%s: failed to decode response: %v", method, err)
+	}
+	if envelope.Error != nil {
+		return rpcLimitCodes[envelope.Error.Code], envelope.Error
+	}
+	if result == nil {
+		return false, nil
+	}
+	return false, json.Unmarshal(envelope.Result, result)
+}
+
+// httpURLFor maps a websocket RPC URL to its HTTP counterpart
+func httpURLFor(endpoint string) string {
+	switch {
+	case strings.HasPrefix(endpoint, "wss://"):
+		return "https://" + strings.TrimPrefix(endpoint, "wss://")
+	case strings.HasPrefix(endpoint, "ws://"):
+		return "http://" + strings.TrimPrefix(endpoint, "ws://")
+	default:
+		return endpoint
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// rpcKeyPlaceholder marks where an endpoint URL takes its provider's API key:
+// "wss://eth-mainnet.g.alchemy.com/v2/{key}"
+const rpcKeyPlaceholder = "{key}"
+
+// rpcLimitCodes are the JSON-RPC error codes providers answer with once a
+// key's quota is used up: Alchemy 429, Infura -32005, QuickNode -32007
+var rpcLimitCodes = map[int]bool{429: true, -32005: true, -32007: true}
+
+var (
+	rpcKeyRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_rpc_key_requests_total",
+			Help: "RPC requests and websocket connections made with each provider API key by outcome (ok, error, limited)",
+		},
+		[]string{"provider", "key", "result"},
+	)
+
+	rpcKeyRotations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_rpc_key_rotations_total",
+			Help: "Times a provider API key hit its limit and requests moved to the next key",
+		},
+		[]string{"provider"},
+	)
+)
+
+// parseProviderKeys parses RPC_PROVIDER_KEYS, provider=keys entries separated
+// by semicolons with the keys separated by commas: "alchemy=k1,k2;infura=k3"
+func parseProviderKeys(value string) map[string][]string {
+	keys := make(map[string][]string)
+	for _, item := range strings.Split(value, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		provider, list, ok := strings.Cut(item, "=")
+		if !ok {
+			slog.Warn("ignoring malformed provider keys, expected provider=key,key", "entry", item)
+			continue
+		}
+		keys[strings.TrimSpace(provider)] = splitNonEmpty(list)
+	}
+	return keys
+}
+
+// keyLabel identifies a key in metrics and logs without revealing it
+func keyLabel(key string, index int) string {
+	if len(key) < 12 {
+		return fmt.Sprintf("key-%d", index+1)
+	}
+	return "..." + key[len(key)-4:]
+}
+
+// keyRing hands out a provider's API keys in turn, skipping keys that were
+// recently rate limited, so the provider's quota is spread over every key
+type keyRing struct {
+	provider     string
+	keys         []string
+	cooldown     time.Duration
+	mu           sync.Mutex
+	next         int
+	limitedUntil []time.Time
+}
+
+func newKeyRing(provider string, keys []string, cooldown time.Duration) *keyRing {
+	return &keyRing{
+		provider:     provider,
+		keys:         keys,
+		cooldown:     cooldown,
+		limitedUntil: make([]time.Time, len(keys)),
+	}
+}
+
+// pick returns the next key that is not cooling down, or the one that
+// recovers first when all of them are
+func (r *keyRing) pick() *providerKey {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	soonest := r.next
+	for i := 0; i < len(r.keys); i++ {
+		index := (r.next + i) % len(r.keys)
+		if !now.Before(r.limitedUntil[index]) {
+			soonest = index
+			break
+		}
+		if r.limitedUntil[index].Before(r.limitedUntil[soonest]) {
+			soonest = index
+		}
+	}
+	r.next = (soonest + 1) % len(r.keys)
+	return &providerKey{ring: r, index: soonest}
+}
+
+// limit cools a key down after the provider rejected it
+func (r *keyRing) limit(index int) {
+	r.mu.Lock()
+	r.limitedUntil[index] = time.Now().Add(r.cooldown)
+	r.mu.Unlock()
+
+	rpcKeyRotations.WithLabelValues(r.provider).Inc()
+	slog.Warn("provider key rate limited, rotating to the next key", "provider", r.provider, "key", keyLabel(r.keys[index], index), "cooldown", r.cooldown)
+}
+
+// providerKey is the key chosen for one request or connection
+type providerKey struct {
+	ring  *keyRing
+	index int
+}
+
+// Observe records the outcome of using the key; a limit response cools it
+// down so the following requests use the provider's other keys. A nil key,
+// from an endpoint without the placeholder, records nothing.
+func (k *providerKey) Observe(limited bool, err error) {
+	if k == nil {
+		return
+	}
+	result := "ok"
+	switch {
+	case limited:
+		result = "limited"
+		k.ring.limit(k.index)
+	case err != nil:
+		result = "error"
+	}
+	rpcKeyRequests.WithLabelValues(k.ring.provider, keyLabel(k.ring.keys[k.index], k.index), result).Inc()
+}
+
+// Retries reports how many more keys a limited request can be retried with
+func (k *providerKey) Retries() int {
+	if k == nil {
+		return 0
+	}
+	return len(k.ring.keys) - 1
+}
+
+// Resolve substitutes the next usable key of endpoint's provider for its
+// {key} placeholder. Endpoints without the placeholder, or whose provider
+// has no keys, are returned unchanged with a nil key.
+func (r *endpointLimiters) Resolve(endpoint string) (string, *providerKey) {
+	if !strings.Contains(endpoint, rpcKeyPlaceholder) {
+		return endpoint, nil
+	}
+
+	r.mu.Lock()
+	ring := r.rings[r.config.Provider(endpoint)]
+	r.mu.Unlock()
+	if ring == nil {
+		return endpoint, nil
+	}
+
+	key := ring.pick()
+	return strings.ReplaceAll(endpoint, rpcKeyPlaceholder, ring.keys[key.index]), key
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+)
+
+// RPC providers with quota presets
+const (
+	ProviderAlchemy   = "alchemy"
+	ProviderInfura    = "infura"
+	ProviderQuickNode = "quicknode"
+	// ProviderDefault covers endpoints on hosts no provider claims
+	ProviderDefault = "default"
+)
+
+var (
+	rpcThrottled = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_rpc_throttled_total",
+			Help: "RPC requests delayed by an endpoint's rate limit",
+		},
+		[]string{"provider", "endpoint"},
+	)
+
+	rpcThrottleWait = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "scorpius_rpc_throttle_wait_seconds",
+			Help:    "Time RPC requests waited for an endpoint's rate limit",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 8),
+		},
+		[]string{"provider"},
+	)
+
+	rpcSubscriptions = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_rpc_subscriptions",
+			Help: "Open websocket subscriptions per endpoint",
+		},
+		[]string{"provider", "endpoint"},
+	)
+
+	rpcSubscriptionsRejected = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_rpc_subscriptions_rejected_total",
+			Help: "Websocket subscriptions not opened because the endpoint was at its limit",
+		},
+		[]string{"provider", "endpoint"},
+	)
+)
+
+// ProviderLimit is the quota applied to each endpoint of a provider. Zero
+// means unlimited.
+type ProviderLimit struct {
+	RPS           float64
+	Subscriptions int
+}
+
+// providerPresets keep each endpoint under the entry-level plans of the
+// major providers. Paid plans raise them with RPC_RATE_LIMITS and
+// RPC_SUBSCRIPTION_LIMITS.
+var providerPresets = map[string]ProviderLimit{
+	ProviderAlchemy:   {RPS: 25, Subscriptions: 20},
+	ProviderInfura:    {RPS: 10, Subscriptions: 10},
+	ProviderQuickNode: {RPS: 15, Subscriptions: 10},
+	ProviderDefault:   {},
+}
+
+// providerHosts maps host suffixes to the provider serving them
+var providerHosts = map[string]string{
+	"alchemy.com":   ProviderAlchemy,
+	"alchemyapi.io": ProviderAlchemy,
+	"infura.io":     ProviderInfura,
+	"quiknode.pro":  ProviderQuickNode,
+	"quicknode.com": ProviderQuickNode,
+}
+
+// RPCLimitConfig configures the per-endpoint RPC rate limits and the API
+// keys rotated through endpoints with a {key} placeholder
+type RPCLimitConfig struct {
+	Providers   map[string]ProviderLimit
+	Hosts       map[string]string
+	Keys        map[string][]string
+	KeyCooldown time.Duration
+}
+
+// loadRPCLimitConfig reads RPC_RATE_LIMITS (provider=requests per second),
+// RPC_SUBSCRIPTION_LIMITS (provider=concurrent subscriptions) and
+// RPC_PROVIDER_HOSTS (host suffix=provider) over the presets, and the
+// RPC_PROVIDER_KEYS to rotate. Providers named only in RPC_PROVIDER_HOSTS
+// start unlimited. Limits apply per key.
+func loadRPCLimitConfig() RPCLimitConfig {
+	config := RPCLimitConfig{
+		Providers:   make(map[string]ProviderLimit, len(providerPresets)),
+		Hosts:       make(map[string]string, len(providerHosts)),
+		Keys:        parseProviderKeys(getEnv("RPC_PROVIDER_KEYS")),
+		KeyCooldown: getEnvDuration("RPC_KEY_COOLDOWN", time.Minute),
+	}
+	for provider, limit := range providerPresets {
+		config.Providers[provider] = limit
+	}
+	for host, provider := range providerHosts {
+		config.Hosts[host] = provider
+	}
+	for host, provider := range parseKeyValues(getEnv("RPC_PROVIDER_HOSTS")) {
+		config.Hosts[strings.ToLower(host)] = provider
+	}
+
+	for provider, value := range parseKeyValues(getEnv("RPC_RATE_LIMITS")) {
+		rps, err := strconv.ParseFloat(value, 64)
+		if err != nil || rps < 0 {
+			invalidSetting("RPC_RATE_LIMITS", provider+"="+value, "non-negative number", config.Providers[provider].RPS)
+			continue
+		}
+		limit := config.Providers[provider]
+		limit.RPS = rps
+		config.Providers[provider] = limit
+	}
+	for provider, value := range parseKeyValues(getEnv("RPC_SUBSCRIPTION_LIMITS")) {
+		subscriptions, err := strconv.Atoi(value)
+		if err != nil || subscriptions < 0 {
+			invalidSetting("RPC_SUBSCRIPTION_LIMITS", provider+"="+value, "non-negative integer", config.Providers[provider].Subscriptions)
+			continue
+		}
+		limit := config.Providers[provider]
+		limit.Subscriptions = subscriptions
+		config.Providers[provider] = limit
+	}
+	return config
+}
+
+// Provider returns the provider serving endpoint, matched on the longest
+// host suffix
+func (c RPCLimitConfig) Provider(endpoint string) string {
+	_, rawURL := splitEndpointType(endpoint)
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ProviderDefault
+	}
+	host := strings.ToLower(u.Hostname())
+
+	provider, matched := ProviderDefault, 0
+	for suffix, name := range c.Hosts {
+		if len(suffix) > matched && (host == suffix || strings.HasSuffix(host, "."+suffix)) {
+			provider, matched = name, len(suffix)
+		}
+	}
+	return provider
+}
+
+// endpointLimiter holds one endpoint's request rate and subscription slots
+type endpointLimiter struct {
+	provider      string
+	label         string
+	limiter       *rate.Limiter
+	subscriptions chan struct{}
+}
+
+// Wait blocks until the endpoint's rate limit allows another request
+func (l *endpointLimiter) Wait(ctx context.Context) error {
+	if l.limiter.Limit() == rate.Inf {
+		return nil
+	}
+	reservation := l.limiter.Reserve()
+	delay := reservation.Delay()
+	if delay == 0 {
+		return nil
+	}
+
+	rpcThrottled.WithLabelValues(l.provider, l.label).Inc()
+	rpcThrottleWait.WithLabelValues(l.provider).Observe(delay.Seconds())
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		reservation.Cancel()
+		return ctx.Err()
+	}
+}
+
+// Subscribe takes a subscription slot, returning the function that frees it,
+// or an error while the endpoint is at its limit
+func (l *endpointLimiter) Subscribe() (func(), error) {
+	if l.subscriptions == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case l.subscriptions <- struct{}{}:
+	default:
+		rpcSubscriptionsRejected.WithLabelValues(l.provider, l.label).Inc()
+		return nil, fmt.Errorf("%s is at its %s limit of %d concurrent subscriptions", l.label, l.provider, cap(l.subscriptions))
+	}
+	rpcSubscriptions.WithLabelValues(l.provider, l.label).Inc()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-l.subscriptions
+			rpcSubscriptions.WithLabelValues(l.provider, l.label).Dec()
+		})
+	}, nil
+}
+
+// endpointLimiters shares one limiter per endpoint between every client of
+// it in the process: the chain's websocket, hydration, block tracking,
+// health probes and circuit breaker trials
+type endpointLimiters struct {
+	mu       sync.Mutex
+	config   RPCLimitConfig
+	limiters map[string]*endpointLimiter
+	rings    map[string]*keyRing
+}
+
+// rpcLimits is configured at startup; until then endpoints are unlimited
+var rpcLimits = &endpointLimiters{limiters: make(map[string]*endpointLimiter)}
+
+// Configure sets the provider limits and keys for endpoints seen from now on
+func (r *endpointLimiters) Configure(config RPCLimitConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.config = config
+	r.limiters = make(map[string]*endpointLimiter)
+	r.rings = make(map[string]*keyRing, len(config.Keys))
+	for provider, keys := range config.Keys {
+		registerSecrets(keys...)
+		if len(keys) > 0 {
+			r.rings[provider] = newKeyRing(provider, keys, config.KeyCooldown)
+		}
+	}
+}
+
+// For returns endpoint's limiter. The websocket and HTTP URLs of an endpoint
+// share one.
+func (r *endpointLimiters) For(endpoint string) *endpointLimiter {
+	_, rawURL := splitEndpointType(endpoint)
+	key := strings.TrimSuffix(httpURLFor(rawURL), "/")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if l, ok := r.limiters[key]; ok {
+		return l
+	}
+	provider := r.config.Provider(endpoint)
+	limit := r.config.Providers[provider]
+
+	l := &endpointLimiter{
+		provider: provider,
+		label:    displayEndpoint(endpoint),
+		limiter:  rate.NewLimiter(rate.Inf, 1),
+	}
+	if limit.RPS > 0 {
+		l.limiter = rate.NewLimiter(rate.Limit(limit.RPS), int(math.Max(1, math.Ceil(limit.RPS))))
+	}
+	if limit.Subscriptions > 0 {
+		l.subscriptions = make(chan struct{}, limit.Subscriptions)
+	}
+	r.limiters[key] = l
+	return l
+}
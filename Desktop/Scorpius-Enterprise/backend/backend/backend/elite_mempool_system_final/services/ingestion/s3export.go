@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/parquet-go/parquet-go"
+)
+
+// S3ExportConfig configures the Parquet cold storage exporter
+type S3ExportConfig struct {
+	Bucket   string
+	Prefix   string
+	Region   string
+	Endpoint string
+	// PathStyle is needed by most S3-compatible stores (MinIO, Ceph)
+	PathStyle bool
+	Interval  time.Duration
+	MaxRows   int
+}
+
+// loadS3ExportConfig reads S3_EXPORT_* settings; the exporter is disabled without a bucket
+func loadS3ExportConfig() S3ExportConfig {
+	return S3ExportConfig{
+		Bucket:    getEnv("S3_EXPORT_BUCKET"),
+		Prefix:    getEnvOrDefault("S3_EXPORT_PREFIX", "mempool/"),
+		Region:    getEnv("S3_EXPORT_REGION"),
+		Endpoint:  getEnv("S3_EXPORT_ENDPOINT"),
+		PathStyle: getEnvBool("S3_EXPORT_PATH_STYLE", false),
+		Interval:  getEnvDuration("S3_EXPORT_INTERVAL", 5*time.Minute),
+		MaxRows:   getEnvInt("S3_EXPORT_MAX_ROWS", 100000),
+	}
+}
+
+// parquetTransaction is the flat row layout of exported Parquet files
+type parquetTransaction struct {
+	SeenAt               time.Time `parquet:"seen_at,timestamp(millisecond)"`
+	Chain                string    `parquet:"chain,dict"`
+	ChainID              int64     `parquet:"chain_id"`
+	ChainFamily          string    `parquet:"chain_family,dict"`
+	Hash                 string    `parquet:"hash"`
+	Type                 string    `parquet:"type,dict"`
+	From                 string    `parquet:"from_address"`
+	To                   string    `parquet:"to_address"`
+	Value                string    `parquet:"value"`
+	Gas                  uint64    `parquet:"gas"`
+	GasPrice             string    `parquet:"gas_price"`
+	MaxFeePerGas         string    `parquet:"max_fee_per_gas"`
+	MaxPriorityFeePerGas string    `parquet:"max_priority_fee_per_gas"`
+	Nonce                uint64    `parquet:"nonce"`
+	Data                 string    `parquet:"data,zstd"`
+	Status               string    `parquet:"status,dict"`
+	TokenStandard        string    `parquet:"token_standard,dict"`
+	Token                string    `parquet:"token"`
+	TokenTo              string    `parquet:"token_to"`
+	TokenAmount          string    `parquet:"token_amount"`
+	Tags                 []string  `parquet:"tags,list"`
+}
+
+// S3Exporter buffers transactions and periodically uploads ZSTD-compressed
+// Parquet files partitioned as <prefix>chain=<chain>/date=<YYYY-MM-DD>/
+type S3Exporter struct {
+	client  *s3.Client
+	config  S3ExportConfig
+	batcher *archiveBatcher
+}
+
+// NewS3Exporter creates an S3 client from the default AWS credential chain
+func NewS3Exporter(config S3ExportConfig) (*S3Exporter, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if config.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(config.Region))
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %v", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if config.Endpoint != "" {
+			o.BaseEndpoint = aws.String(config.Endpoint)
+		}
+		o.UsePathStyle = config.PathStyle
+	})
+
+	e := &S3Exporter{client: client, config: config}
+	e.batcher = newArchiveBatcher(e.Name(), config.MaxRows, config.Interval, e.export)
+	return e, nil
+}
+
+// Name returns the archiver name
+func (e *S3Exporter) Name() string {
+	return "s3"
+}
+
+// Archive queues tx for the next export
+func (e *S3Exporter) Archive(tx Transaction) {
+	e.batcher.Add(tx)
+}
+
+// export writes one Parquet object per chain/date partition in the batch
+func (e *S3Exporter) export(txs []Transaction) error {
+	partitions := make(map[string][]parquetTransaction)
+	for _, tx := range txs {
+		key := fmt.Sprintf("chain=%s/date=%s", tx.Chain, time.Unix(tx.Timestamp, 0).UTC().Format("2006-01-02"))
+		partitions[key] = append(partitions[key], toParquetRow(tx))
+	}
+
+	keys := make([]string, 0, len(partitions))
+	for key := range partitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var failed []string
+	for _, partition := range keys {
+		if err := e.upload(partition, partitions[partition]); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", partition, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d partitions failed: %s", len(failed), len(keys), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (e *S3Exporter) upload(partition string, rows []parquetTransaction) error {
+	var buf bytes.Buffer
+	writer := parquet.NewGenericWriter[parquetTransaction](&buf, parquet.Compression(&parquet.Zstd))
+	if _, err := writer.Write(rows); err != nil {
+		return fmt.Errorf("failed to write parquet rows: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to finish parquet file: %v", err)
+	}
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	key := fmt.Sprintf("%s%s/%d-%s.parquet", e.config.Prefix, partition, time.Now().Unix(), hex.EncodeToString(suffix))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	_, err := e.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(e.config.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(buf.Bytes()),
+		ContentType: aws.String("application/vnd.apache.parquet"),
+	})
+	return err
+}
+
+func toParquetRow(tx Transaction) parquetTransaction {
+	row := parquetTransaction{
+		SeenAt:               time.Unix(tx.Timestamp, 0).UTC(),
+		Chain:                tx.Chain,
+		ChainID:              tx.ChainID,
+		ChainFamily:          tx.ChainFamily,
+		Hash:                 tx.Hash,
+		Type:                 tx.Type,
+		From:                 tx.From,
+		To:                   tx.To,
+		Value:                hexToBig(tx.Value).String(),
+		Gas:                  hexToUint64(tx.Gas),
+		GasPrice:             hexToBig(tx.GasPrice).String(),
+		MaxFeePerGas:         tx.MaxFeePerGas,
+		MaxPriorityFeePerGas: tx.MaxPriorityFeePerGas,
+		Nonce:                hexToUint64(tx.Nonce),
+		Data:                 tx.Data,
+		Status:               tx.Status,
+	}
+	if tx.MaxFeePerGas != "" {
+		row.MaxFeePerGas = hexToBig(tx.MaxFeePerGas).String()
+		row.MaxPriorityFeePerGas = hexToBig(tx.MaxPriorityFeePerGas).String()
+	}
+	if t := tx.TokenTransfer; t != nil {
+		row.TokenStandard, row.Token, row.TokenTo, row.TokenAmount = t.Standard, t.Token, t.To, t.Amount
+	}
+	for _, tag := range tx.Tags {
+		row.Tags = append(row.Tags, tag.Tag)
+	}
+	return row
+}
+
+// fromParquetRow rebuilds a transaction from an exported row, with the
+// quantities hex-encoded again. Fields not exported, such as the raw
+// payload, are left empty.
+func fromParquetRow(row parquetTransaction) Transaction {
+	tx := Transaction{
+		Hash:        row.Hash,
+		ChainID:     row.ChainID,
+		Chain:       row.Chain,
+		ChainFamily: row.ChainFamily,
+		Type:        row.Type,
+		From:        row.From,
+		To:          row.To,
+		Value:       decimalToHex(row.Value),
+		Gas:         "0x" + strconv.FormatUint(row.Gas, 16),
+		GasPrice:    decimalToHex(row.GasPrice),
+		Nonce:       "0x" + strconv.FormatUint(row.Nonce, 16),
+		Data:        row.Data,
+		Timestamp:   row.SeenAt.Unix(),
+		Status:      row.Status,
+	}
+	if row.MaxFeePerGas != "" {
+		tx.MaxFeePerGas = decimalToHex(row.MaxFeePerGas)
+		tx.MaxPriorityFeePerGas = decimalToHex(row.MaxPriorityFeePerGas)
+	}
+	if row.TokenStandard != "" {
+		tx.TokenTransfer = &TokenTransfer{Standard: row.TokenStandard, Token: row.Token, From: row.From, To: row.TokenTo, Amount: row.TokenAmount}
+	}
+	for _, tag := range row.Tags {
+		tx.Tags = append(tx.Tags, TxTag{Tag: tag})
+	}
+	return tx
+}
+
+// decimalToHex converts a decimal quantity to 0x-prefixed hex, zero when invalid
+func decimalToHex(value string) string {
+	n, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return "0x0"
+	}
+	return "0x" + n.Text(16)
+}
+
+// Close exports the remaining buffered transactions
+func (e *S3Exporter) Close() {
+	e.batcher.Close()
+}
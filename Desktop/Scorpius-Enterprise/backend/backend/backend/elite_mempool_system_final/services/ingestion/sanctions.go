@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+)
+
+// sanctionedTag marks transactions sent from or to a denied address
+const sanctionedTag = "sanctioned"
+
+// Sanctions actions for matching transactions
+const (
+	SanctionsTag      = "tag"
+	SanctionsSuppress = "suppress"
+)
+
+// sanctionsAPISource names matches reported by the screening API
+const sanctionsAPISource = "api"
+
+var sanctionsDecisions = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_sanctions_decisions_total",
+		Help: "Sanctions screening decisions, by outcome",
+	},
+	[]string{"chain", "decision"},
+)
+
+// SanctionsConfig configures deny-list screening
+type SanctionsConfig struct {
+	Files      []string
+	Sets       []string
+	APIURL     string
+	APIKey     string
+	APITimeout time.Duration
+	APICache   time.Duration
+	Refresh    time.Duration
+	Action     string
+	AuditLog   string
+	AuditAll   bool
+}
+
+// loadSanctionsConfig reads SANCTIONS_* settings
+func loadSanctionsConfig() SanctionsConfig {
+	return SanctionsConfig{
+		Files:      splitNonEmpty(getEnv("SANCTIONS_FILES")),
+		Sets:       splitNonEmpty(getEnv("SANCTIONS_REDIS_SETS")),
+		APIURL:     getEnv("SANCTIONS_API_URL"),
+		APIKey:     getEnv("SANCTIONS_API_KEY"),
+		APITimeout: getEnvDuration("SANCTIONS_API_TIMEOUT", 2*time.Second),
+		APICache:   getEnvDuration("SANCTIONS_API_CACHE_TTL", 24*time.Hour),
+		Refresh:    getEnvDuration("SANCTIONS_REFRESH", time.Minute),
+		Action:     getEnvOrDefault("SANCTIONS_ACTION", SanctionsTag),
+		AuditLog:   getEnv("SANCTIONS_AUDIT_LOG"),
+		AuditAll:   getEnvBool("SANCTIONS_AUDIT_ALL", false),
+	}
+}
+
+// Enabled reports whether any deny list is configured
+func (c SanctionsConfig) Enabled() bool {
+	return len(c.Files) > 0 || len(c.Sets) > 0 || c.APIURL != ""
+}
+
+// apiVerdict is a cached screening API answer
+type apiVerdict struct {
+	denied  bool
+	expires time.Time
+}
+
+// SanctionsScreener checks senders and recipients against deny lists: local
+// files (one address per line, # comments), Redis sets and a screening API
+// in the style of Chainalysis' sanctions API
+// (GET <url>/<address> returning {"identifications": [...]}).
+//
+// Matches are tagged, or suppressed entirely with SANCTIONS_ACTION=suppress.
+// Every match, and every API failure (which fails open), is written to the
+// audit log; with SANCTIONS_AUDIT_ALL clean transactions are recorded too.
+type SanctionsScreener struct {
+	config  SanctionsConfig
+	redis   *redis.Client
+	client  *http.Client
+	audit   *slog.Logger
+	mu      sync.RWMutex
+	denied  map[string]string
+	verdict map[string]apiVerdict
+}
+
+// NewSanctionsScreener loads the deny-list files; call Run to load the Redis sets
+func NewSanctionsScreener(redisClient *redis.Client, config SanctionsConfig) (*SanctionsScreener, error) {
+	audit := slog.Default().With("audit", "sanctions")
+	if config.AuditLog != "" {
+		f, err := os.OpenFile(config.AuditLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open sanctions audit log: %v", err)
+		}
+		audit = slog.New(slog.NewJSONHandler(f, nil)).With("audit", "sanctions")
+	}
+
+	s := &SanctionsScreener{
+		config:  config,
+		redis:   redisClient,
+		client:  &http.Client{Timeout: config.APITimeout},
+		audit:   audit,
+		verdict: make(map[string]apiVerdict),
+	}
+	denied, err := s.loadFiles()
+	if err != nil {
+		return nil, err
+	}
+	s.denied = denied
+	return s, nil
+}
+
+// loadFiles reads every deny-list file into a fresh address index
+func (s *SanctionsScreener) loadFiles() (map[string]string, error) {
+	denied := make(map[string]string)
+	for _, path := range s.config.Files {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open sanctions list: %v", err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line, _, _ := strings.Cut(scanner.Text(), "#")
+			if address := strings.TrimSpace(line); address != "" {
+				denied[normalizeAddress(address)] = path
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sanctions list %s: %v", path, err)
+		}
+	}
+	return denied, nil
+}
+
+// Run reloads the files and Redis sets every refresh interval until ctx is cancelled
+func (s *SanctionsScreener) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Refresh)
+	defer ticker.Stop()
+
+	for {
+		s.refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh rebuilds the deny list. A source that fails to load keeps its
+// previous entries, so a transient error never clears the list.
+func (s *SanctionsScreener) refresh(ctx context.Context) {
+	s.mu.RLock()
+	previous := s.denied
+	s.mu.RUnlock()
+
+	keep := func(denied map[string]string, source string) {
+		for address, list := range previous {
+			if list == source {
+				denied[address] = list
+			}
+		}
+	}
+
+	denied, err := s.loadFiles()
+	if err != nil {
+		slog.Warn("failed to reload sanctions lists", "error", err)
+		denied = make(map[string]string)
+		for _, path := range s.config.Files {
+			keep(denied, path)
+		}
+	}
+
+	for _, set := range s.config.Sets {
+		members, err := s.redis.SMembers(ctx, set).Result()
+		if err != nil {
+			slog.Warn("failed to load sanctions list", "list", set, "error", err)
+			keep(denied, set)
+			continue
+		}
+		for _, member := range members {
+			denied[normalizeAddress(member)] = set
+		}
+	}
+
+	s.mu.Lock()
+	s.denied = denied
+	s.mu.Unlock()
+	s.prune()
+}
+
+// Name returns the enricher name
+func (s *SanctionsScreener) Name() string {
+	return sanctionedTag
+}
+
+// Enrich screens both sides of tx, tagging or suppressing it on a match
+func (s *SanctionsScreener) Enrich(tx *Transaction) {
+	if tx.Canary {
+		return
+	}
+
+	matched := false
+	for _, side := range []struct{ field, address string }{{"from", tx.From}, {"to", tx.To}} {
+		if side.address == "" {
+			continue
+		}
+		list, denied := s.screen(tx, side.address)
+		if !denied {
+			continue
+		}
+
+		matched = true
+		tx.Tags = append(tx.Tags, TxTag{
+			Tag:       sanctionedTag,
+			Source:    list,
+			Note:      side.field + " " + side.address,
+			CreatedAt: time.Now(),
+		})
+		s.audit.Warn("sanctioned address", "decision", s.config.Action, "chain", tx.Chain, "tx_hash", tx.Hash,
+			"side", side.field, "address", side.address, "list", list)
+	}
+
+	if !matched {
+		sanctionsDecisions.WithLabelValues(tx.Chain, "allow").Inc()
+		if s.config.AuditAll {
+			s.audit.Info("screened transaction", "decision", "allow", "chain", tx.Chain, "tx_hash", tx.Hash, "from", tx.From, "to", tx.To)
+		}
+		return
+	}
+
+	sanctionsDecisions.WithLabelValues(tx.Chain, s.config.Action).Inc()
+	if s.config.Action == SanctionsSuppress {
+		tx.suppressed = true
+	}
+}
+
+// screen checks one address against the lists, then the API
+func (s *SanctionsScreener) screen(tx *Transaction, address string) (string, bool) {
+	address = normalizeAddress(address)
+
+	s.mu.RLock()
+	list, denied := s.denied[address]
+	verdict, cached := s.verdict[address]
+	s.mu.RUnlock()
+
+	if denied {
+		return list, true
+	}
+	if s.config.APIURL == "" {
+		return "", false
+	}
+	if cached && time.Now().Before(verdict.expires) {
+		return sanctionsAPISource, verdict.denied
+	}
+
+	deniedByAPI, err := s.checkAPI(address)
+	if err != nil {
+		sanctionsDecisions.WithLabelValues(tx.Chain, "error").Inc()
+		s.audit.Error("sanctions check failed, allowing", "decision", "error", "chain", tx.Chain, "tx_hash", tx.Hash,
+			"address", address, "error", err)
+		return "", false
+	}
+
+	s.mu.Lock()
+	s.verdict[address] = apiVerdict{denied: deniedByAPI, expires: time.Now().Add(s.config.APICache)}
+	s.mu.Unlock()
+	return sanctionsAPISource, deniedByAPI
+}
+
+// checkAPI asks the screening API whether address is sanctioned
+func (s *SanctionsScreener) checkAPI(address string) (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(s.config.APIURL, "/")+"/"+url.PathEscape(address), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if s.config.APIKey != "" {
+		req.Header.Set("X-API-Key", s.config.APIKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var result struct {
+		Identifications []json.RawMessage `json:"identifications"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("failed to decode response: %v", err)
+	}
+	return len(result.Identifications) > 0, nil
+}
+
+// prune drops expired API verdicts
+func (s *SanctionsScreener) prune() {
+	now := time.Now()
+	s.mu.Lock()
+	for address, verdict := range s.verdict {
+		if now.After(verdict.expires) {
+			delete(s.verdict, address)
+		}
+	}
+	s.mu.Unlock()
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// MEV incident types detected in confirmed blocks
+const (
+	IncidentSandwich = "sandwich"
+	IncidentFrontrun = "frontrun"
+)
+
+// Swap event topics of Uniswap V2 and V3 style pools, which most DEX forks share
+const (
+	swapTopicV2 = "0xd78ad95fa46c994b6551d0da85fc275fe613ce37657fb8d5e3d130840159d822"
+	swapTopicV3 = "0xc42079f94a6350d7e6235f29174924f928cc2ac818eb64fed8004e115fbcca67"
+)
+
+var mevIncidents = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_mev_incidents_total",
+		Help: "Sandwich and frontrun incidents detected in confirmed blocks",
+	},
+	[]string{"chain", "type"},
+)
+
+// MEVIncident is a sandwich or frontrun found in a confirmed block
+type MEVIncident struct {
+	Type        string    `json:"type"`
+	Chain       string    `json:"chain"`
+	ChainID     int64     `json:"chain_id"`
+	BlockNumber uint64    `json:"block_number"`
+	BlockHash   string    `json:"block_hash"`
+	Pool        string    `json:"pool"`
+	Protocol    string    `json:"protocol"`
+	Attacker    string    `json:"attacker"`
+	AttackerTxs []string  `json:"attacker_txs"`
+	VictimTxs   []string  `json:"victim_txs"`
+	DetectedAt  time.Time `json:"detected_at"`
+}
+
+// poolSwap is one Swap event, in block order
+type poolSwap struct {
+	tx         *blockTx
+	pool       string
+	protocol   string
+	zeroForOne bool
+}
+
+// sameActor reports whether two swaps come from the same searcher: the same
+// sender, or the same non-router contract (bots often rotate sender keys)
+func (s poolSwap) sameActor(other poolSwap) bool {
+	if s.tx.From == other.tx.From {
+		return true
+	}
+	_, router := dexContracts[s.tx.To]
+	return s.tx.To != "" && s.tx.To == other.tx.To && !router
+}
+
+// blockSwaps extracts the Swap events of successful transactions, grouped by pool
+func blockSwaps(block *confirmedBlock) map[string][]poolSwap {
+	swaps := make(map[string][]poolSwap)
+	for _, tx := range block.Transactions {
+		if !tx.Succeeded() {
+			continue
+		}
+		for _, l := range tx.Logs {
+			if len(l.Topics) == 0 {
+				continue
+			}
+			words := splitWords(l.Data)
+
+			swap := poolSwap{tx: tx, pool: strings.ToLower(l.Address)}
+			switch strings.ToLower(l.Topics[0]) {
+			case swapTopicV2:
+				// Swap(sender, amount0In, amount1In, amount0Out, amount1Out, to)
+				if len(words) < 4 {
+					continue
+				}
+				swap.protocol = "uniswap_v2"
+				swap.zeroForOne = strings.Trim(words[0], "0") != ""
+			case swapTopicV3:
+				// Swap(sender, recipient, int256 amount0, int256 amount1, ...); the
+				// pool receives token0 when amount0 is positive
+				if len(words) < 2 {
+					continue
+				}
+				swap.protocol = "uniswap_v3"
+				swap.zeroForOne = words[0][0] < '8' && strings.Trim(words[0], "0") != ""
+			default:
+				continue
+			}
+			swaps[swap.pool] = append(swaps[swap.pool], swap)
+		}
+	}
+	return swaps
+}
+
+// detectMEVIncidents finds sandwiches and frontruns in a confirmed block.
+//
+// A sandwich is a swap by one actor, one or more swaps in the same direction
+// by others, then a swap back by the first actor, all on one pool. A
+// frontrun is a swap immediately followed, in the next transaction, by a
+// swap in the same direction on the same pool from someone else who paid a
+// lower gas price, where the first transaction calls a contract that is not
+// a public router. Transactions already counted in a sandwich are not
+// reported again as frontruns.
+func detectMEVIncidents(block *confirmedBlock) []MEVIncident {
+	var incidents []MEVIncident
+	for pool, swaps := range blockSwaps(block) {
+		used := make(map[*blockTx]bool)
+
+		for i, front := range swaps {
+			if used[front.tx] {
+				continue
+			}
+			for k := i + 1; k < len(swaps); k++ {
+				back := swaps[k]
+				if back.tx == front.tx || !back.sameActor(front) || back.zeroForOne == front.zeroForOne {
+					continue
+				}
+
+				var victims []string
+				for _, victim := range swaps[i+1 : k] {
+					if victim.tx != front.tx && victim.tx != back.tx && !victim.sameActor(front) &&
+						victim.zeroForOne == front.zeroForOne && !containsString(victims, victim.tx.Hash) {
+						victims = append(victims, victim.tx.Hash)
+					}
+				}
+				if len(victims) == 0 {
+					continue
+				}
+
+				incidents = append(incidents, MEVIncident{
+					Type:        IncidentSandwich,
+					Pool:        pool,
+					Protocol:    front.protocol,
+					Attacker:    attackerAddress(front.tx),
+					AttackerTxs: []string{front.tx.Hash, back.tx.Hash},
+					VictimTxs:   victims,
+				})
+				used[front.tx], used[back.tx] = true, true
+				break
+			}
+		}
+
+		for i := 0; i+1 < len(swaps); i++ {
+			attacker, victim := swaps[i], swaps[i+1]
+			if used[attacker.tx] || used[victim.tx] || victim.tx.Index() != attacker.tx.Index()+1 {
+				continue
+			}
+			if attacker.sameActor(victim) || attacker.zeroForOne != victim.zeroForOne {
+				continue
+			}
+			if _, router := dexContracts[attacker.tx.To]; router || attacker.tx.To == "" {
+				continue
+			}
+			if hexToBig(effectiveGasPrice(attacker.tx)).Cmp(hexToBig(effectiveGasPrice(victim.tx))) <= 0 {
+				continue
+			}
+
+			incidents = append(incidents, MEVIncident{
+				Type:        IncidentFrontrun,
+				Pool:        pool,
+				Protocol:    attacker.protocol,
+				Attacker:    attackerAddress(attacker.tx),
+				AttackerTxs: []string{attacker.tx.Hash},
+				VictimTxs:   []string{victim.tx.Hash},
+			})
+			used[attacker.tx] = true
+		}
+	}
+
+	now := time.Now()
+	for i := range incidents {
+		incidents[i].BlockNumber = block.Number
+		incidents[i].BlockHash = block.Hash
+		incidents[i].DetectedAt = now
+	}
+	return incidents
+}
+
+// attackerAddress prefers the bot contract over the sender key that called it
+func attackerAddress(tx *blockTx) string {
+	if tx.To != "" {
+		return tx.To
+	}
+	return tx.From
+}
+
+// effectiveGasPrice returns the price the transaction actually paid per gas
+func effectiveGasPrice(tx *blockTx) string {
+	if tx.EffectiveGasPrice != "" {
+		return tx.EffectiveGasPrice
+	}
+	return tx.GasPrice
+}
+
+// mevDetector publishes sandwich and frontrun incidents for each confirmed block
+type mevDetector struct {
+	monitor *ChainMonitor
+	topic   string
+}
+
+// HandleBlock is the detector's blockHandler
+func (d *mevDetector) HandleBlock(block *confirmedBlock) {
+	cm := d.monitor
+	for _, incident := range detectMEVIncidents(block) {
+		incident.Chain = cm.chainName
+		incident.ChainID = cm.chainID
+		mevIncidents.WithLabelValues(cm.chainName, incident.Type).Inc()
+
+		if err := d.publish(cm.ctx, incident); err != nil {
+			cm.logger.Error("failed to publish MEV incident", "type", incident.Type, "block", incident.BlockNumber, "error", err)
+		}
+
+		cm.alerter.Raise(Alert{
+			Chain:    cm.chainName,
+			Category: "mev",
+			Severity: SeverityInfo,
+			Message: fmt.Sprintf("%s by %s on pool %s in block %d (victims: %s)",
+				incident.Type, incident.Attacker, incident.Pool, incident.BlockNumber, strings.Join(incident.VictimTxs, ", ")),
+		})
+	}
+}
+
+// publish writes an incident as JSON to the incident topic
+func (d *mevDetector) publish(ctx context.Context, incident MEVIncident) error {
+	data, err := json.Marshal(incident)
+	if err != nil {
+		return fmt.Errorf("failed to marshal incident: %v", err)
+	}
+
+	topic := expandTopic(d.topic, incident.Chain, incident.ChainID, d.monitor.family)
+	headers := map[string]string{
+		"chain_id":   fmt.Sprintf("%d", incident.ChainID),
+		"chain_name": incident.Chain,
+		"type":       incident.Type,
+		"format":     FormatJSON,
+	}
+	return d.monitor.sink.Publish(ctx, topic, []byte(incident.VictimTxs[0]), data, headers)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Schema types understood by Confluent Schema Registry
+const (
+	SchemaTypeAvro       = "AVRO"
+	SchemaTypeJSONSchema = "JSON"
+)
+
+// schemaRegistryClient registers schemas with a Confluent-compatible Schema
+// Registry. In a dry run it only looks schemas up.
+type schemaRegistryClient struct {
+	baseURL  string
+	username string
+	password string
+	client   *http.Client
+	dryRun   bool
+}
+
+func newSchemaRegistryClient(baseURL, username, password string) *schemaRegistryClient {
+	return &schemaRegistryClient{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		username: username,
+		password: password,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Register checks schema against the subject's latest version and registers it,
+// returning the schema ID. Registering an identical schema returns the existing ID.
+func (c *schemaRegistryClient) Register(ctx context.Context, subject, schemaType, schema string) (int, error) {
+	request := map[string]interface{}{"schema": schema}
+	// AVRO is the registry default and older registries reject an explicit schemaType
+	if schemaType != SchemaTypeAvro {
+		request["schemaType"] = schemaType
+	}
+
+	// Reject incompatible changes up front rather than failing on every produce
+	var compat struct {
+		IsCompatible bool `json:"is_compatible"`
+	}
+	status, err := c.do(ctx, "/compatibility/subjects/"+url.PathEscape(subject)+"/versions/latest", request, &compat)
+	if err != nil && status != http.StatusNotFound {
+		return 0, fmt.Errorf("failed to check compatibility for %s: %v", subject, err)
+	}
+	if err == nil && !compat.IsCompatible {
+		return 0, fmt.Errorf("schema is not compatible with the latest version of %s", subject)
+	}
+
+	var registered struct {
+		ID int `json:"id"`
+	}
+	if c.dryRun {
+		// Looking a schema up under its subject does not register it
+		status, err := c.do(ctx, "/subjects/"+url.PathEscape(subject), request, &registered)
+		if status == http.StatusNotFound {
+			slog.Warn("dry run: schema is not registered, a real run would register it", "subject", subject)
+			return 0, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to look up schema for %s: %v", subject, err)
+		}
+		return registered.ID, nil
+	}
+	if _, err := c.do(ctx, "/subjects/"+url.PathEscape(subject)+"/versions", request, &registered); err != nil {
+		return 0, fmt.Errorf("failed to register schema for %s: %v", subject, err)
+	}
+
+	return registered.ID, nil
+}
+
+// do POSTs a JSON request and decodes the response, returning the HTTP status
+func (c *schemaRegistryClient) do(ctx context.Context, path string, request, result interface{}) (int, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var regErr struct {
+			ErrorCode int    `json:"error_code"`
+			Message   string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(data, &regErr) == nil && regErr.Message != "" {
+			return resp.StatusCode, fmt.Errorf("registry error %d: %s", regErr.ErrorCode, regErr.Message)
+		}
+		return resp.StatusCode, fmt.Errorf("registry returned status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Secret providers
+const (
+	SecretsVault = "vault"
+	SecretsAWS   = "aws"
+	SecretsFile  = "file"
+)
+
+// secretPattern matches a secret reference inside a setting:
+// "wss://eth-mainnet.g.alchemy.com/v2/${secret:rpc/alchemy#key}"
+var secretPattern = regexp.MustCompile(`\$\{secret:([^}]+)\}`)
+
+var secretRefreshes = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_secret_refreshes_total",
+		Help: "Secret refreshes by outcome (unchanged, changed, failed)",
+	},
+	[]string{"result"},
+)
+
+// SecretsConfig selects where ${secret:...} references in settings are read from
+type SecretsConfig struct {
+	Provider   string
+	Refresh    time.Duration
+	Timeout    time.Duration
+	VaultAddr  string
+	VaultToken string
+	VaultMount string
+	VaultNS    string
+	AWSRegion  string
+	Dir        string
+}
+
+// loadSecretsConfig reads SECRETS_* and VAULT_* settings. They are loaded
+// before any secret is resolved, so cannot themselves reference secrets.
+func loadSecretsConfig() SecretsConfig {
+	token := getEnv("VAULT_TOKEN")
+	if path := getEnv("VAULT_TOKEN_FILE"); token == "" && path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			token = strings.TrimSpace(string(data))
+		} else {
+			invalidSetting("VAULT_TOKEN_FILE", path, "readable file", "")
+		}
+	}
+	registerSecrets(token)
+
+	return SecretsConfig{
+		Provider:   getEnv("SECRETS_PROVIDER"),
+		Refresh:    getEnvDuration("SECRETS_REFRESH", 5*time.Minute),
+		Timeout:    getEnvDuration("SECRETS_TIMEOUT", 10*time.Second),
+		VaultAddr:  strings.TrimSuffix(getEnv("VAULT_ADDR"), "/"),
+		VaultToken: token,
+		VaultMount: getEnvOrDefault("VAULT_MOUNT", "secret"),
+		VaultNS:    getEnv("VAULT_NAMESPACE"),
+		AWSRegion:  getEnv("SECRETS_AWS_REGION"),
+		Dir:        getEnvOrDefault("SECRETS_DIR", "/run/secrets"),
+	}
+}
+
+// Enabled reports whether settings may reference secrets
+func (c SecretsConfig) Enabled() bool {
+	return c.Provider != ""
+}
+
+// SecretProvider reads a named secret. Names are provider paths with an
+// optional #field selecting one value from a JSON or key/value secret.
+type SecretProvider interface {
+	Name() string
+	Fetch(ctx context.Context, name string) (string, error)
+}
+
+// newSecretProvider creates the configured provider
+func newSecretProvider(config SecretsConfig) (SecretProvider, error) {
+	switch config.Provider {
+	case SecretsVault:
+		if config.VaultAddr == "" || config.VaultToken == "" {
+			return nil, fmt.Errorf("the vault secrets provider needs VAULT_ADDR and VAULT_TOKEN or VAULT_TOKEN_FILE")
+		}
+		return &vaultSecrets{config: config, client: &http.Client{Timeout: config.Timeout}}, nil
+	case SecretsAWS:
+		return newAWSSecrets(config)
+	case SecretsFile:
+		return &fileSecrets{dir: config.Dir}, nil
+	default:
+		return nil, fmt.Errorf("unknown secrets provider %q", config.Provider)
+	}
+}
+
+// splitSecretName separates a secret name from its #field
+func splitSecretName(name string) (string, string) {
+	path, field, _ := strings.Cut(name, "#")
+	return path, field
+}
+
+// secretField returns field from a JSON object secret, or the whole secret
+// without a field
+func secretField(value, field string) (string, error) {
+	if field == "" {
+		return value, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object, cannot select %q", field)
+	}
+	return lookupSecretField(fields, field)
+}
+
+func lookupSecretField(fields map[string]interface{}, field string) (string, error) {
+	value, ok := fields[field]
+	if !ok {
+		return "", fmt.Errorf("secret has no field %q", field)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// vaultSecrets reads secrets from a HashiCorp Vault KV version 2 engine.
+// Names are paths under VAULT_MOUNT; the field defaults to "value".
+type vaultSecrets struct {
+	config SecretsConfig
+	client *http.Client
+}
+
+func (p *vaultSecrets) Name() string {
+	return SecretsVault
+}
+
+func (p *vaultSecrets) Fetch(ctx context.Context, name string) (string, error) {
+	path, field := splitSecretName(name)
+	if field == "" {
+		field = "value"
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/%s/data/%s", p.config.VaultAddr, p.config.VaultMount, strings.TrimPrefix(path, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.config.VaultToken)
+	if p.config.VaultNS != "" {
+		req.Header.Set("X-Vault-Namespace", p.config.VaultNS)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %s for %s", resp.Status, path)
+	}
+	var body struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode vault response: %v", err)
+	}
+	return lookupSecretField(body.Data.Data, field)
+}
+
+// awsSecrets reads secrets from AWS Secrets Manager with the default
+// credential chain. Names are secret IDs or ARNs; a #field selects a key
+// from a JSON secret string.
+type awsSecrets struct {
+	client      *http.Client
+	region      string
+	endpoint    string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+}
+
+func newAWSSecrets(config SecretsConfig) (*awsSecrets, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if config.AWSRegion != "" {
+		opts = append(opts, awsconfig.WithRegion(config.AWSRegion))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %v", err)
+	}
+	if awsCfg.Region == "" {
+		return nil, fmt.Errorf("the aws secrets provider needs SECRETS_AWS_REGION or AWS_REGION")
+	}
+
+	return &awsSecrets{
+		client:      &http.Client{Timeout: config.Timeout},
+		region:      awsCfg.Region,
+		endpoint:    "https://secretsmanager." + awsCfg.Region + ".amazonaws.com/",
+		credentials: awsCfg.Credentials,
+		signer:      v4.NewSigner(),
+	}, nil
+}
+
+func (p *awsSecrets) Name() string {
+	return SecretsAWS
+}
+
+// Fetch calls GetSecretValue, signing the request with SigV4
+func (p *awsSecrets) Fetch(ctx context.Context, name string) (string, error) {
+	id, field := splitSecretName(name)
+	body, err := json.Marshal(map[string]string{"SecretId": id})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+
+	credentials, err := p.credentials.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to load AWS credentials: %v", err)
+	}
+	hash := sha256.Sum256(body)
+	if err := p.signer.SignHTTP(ctx, credentials, req, hex.EncodeToString(hash[:]), "secretsmanager", p.region, time.Now()); err != nil {
+		return "", fmt.Errorf("failed to sign request: %v", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		json.Unmarshal(data, &failure)
+		return "", fmt.Errorf("secrets manager returned %s for %s: %s %s", resp.Status, id, failure.Type, failure.Message)
+	}
+	var secret struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", fmt.Errorf("failed to decode secrets manager response: %v", err)
+	}
+	return secretField(secret.SecretString, field)
+}
+
+// fileSecrets reads secrets from files under a directory, such as mounted
+// Kubernetes or Docker secrets. Names are paths relative to the directory.
+type fileSecrets struct {
+	dir string
+}
+
+func (p *fileSecrets) Name() string {
+	return SecretsFile
+}
+
+func (p *fileSecrets) Fetch(ctx context.Context, name string) (string, error) {
+	path, field := splitSecretName(name)
+	full := filepath.Join(p.dir, filepath.Clean("/"+path))
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return "", err
+	}
+	return secretField(strings.TrimSpace(string(data)), field)
+}
+
+// secretStore resolves ${secret:...} references in settings. Values are
+// fetched on first use and cached; Run refreshes them and reports changes so
+// the service can reload with the new values.
+type secretStore struct {
+	mu       sync.Mutex
+	config   SecretsConfig
+	provider SecretProvider
+	err      error
+	values   map[string]string
+}
+
+// secrets resolves secret references for getEnv
+var secrets = &secretStore{values: make(map[string]string)}
+
+// Configure switches to config's provider, dropping cached values when the
+// provider changes. An invalid provider is reported when a secret is used.
+func (s *secretStore) Configure(config SecretsConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config == config && (s.provider != nil || s.err != nil) {
+		return
+	}
+	s.config = config
+	s.values = make(map[string]string)
+	s.provider, s.err = nil, nil
+	if config.Enabled() {
+		s.provider, s.err = newSecretProvider(config)
+	}
+}
+
+// Expand replaces the secret references in a setting's value. Secrets that
+// cannot be read are reported as configuration problems and expand to "".
+func (s *secretStore) Expand(key, value string) string {
+	if !strings.Contains(value, "${secret:") {
+		return value
+	}
+	return secretPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		name := secretPattern.FindStringSubmatch(ref)[1]
+		secret, err := s.get(name)
+		if err != nil {
+			secretProblem(key, name, err)
+			return ""
+		}
+		return secret
+	})
+}
+
+// secretProblem logs a secret that could not be read and records it during
+// an audit
+func secretProblem(key, name string, err error) {
+	slog.Error("failed to read secret", "key", key, "secret", name, "error", err)
+
+	configAudit.mu.Lock()
+	if configAudit.active {
+		configAudit.problems = append(configAudit.problems, fmt.Sprintf("%s: failed to read secret %q: %v", settingSource(key), name, err))
+	}
+	configAudit.mu.Unlock()
+}
+
+// get returns a cached secret or fetches it
+func (s *secretStore) get(name string) (string, error) {
+	s.mu.Lock()
+	if value, ok := s.values[name]; ok {
+		s.mu.Unlock()
+		return value, nil
+	}
+	provider, err, timeout := s.provider, s.err, s.config.Timeout
+	s.mu.Unlock()
+
+	if err != nil {
+		return "", err
+	}
+	if provider == nil {
+		return "", fmt.Errorf("secret references need SECRETS_PROVIDER")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	value, err := provider.Fetch(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	registerSecrets(value)
+
+	s.mu.Lock()
+	s.values[name] = value
+	s.mu.Unlock()
+	return value, nil
+}
+
+// refresh re-reads every cached secret and reports whether any changed.
+// Secrets that cannot be read keep their previous value.
+func (s *secretStore) refresh(ctx context.Context) bool {
+	s.mu.Lock()
+	provider, timeout := s.provider, s.config.Timeout
+	names := make([]string, 0, len(s.values))
+	for name := range s.values {
+		names = append(names, name)
+	}
+	s.mu.Unlock()
+	if provider == nil {
+		return false
+	}
+
+	changed := false
+	for _, name := range names {
+		fetchCtx, cancel := context.WithTimeout(ctx, timeout)
+		value, err := provider.Fetch(fetchCtx, name)
+		cancel()
+		if err != nil {
+			secretRefreshes.WithLabelValues("failed").Inc()
+			slog.Warn("failed to refresh secret, keeping the previous value", "provider", provider.Name(), "secret", name, "error", err)
+			continue
+		}
+		registerSecrets(value)
+
+		s.mu.Lock()
+		if s.values[name] != value {
+			s.values[name] = value
+			changed = true
+			slog.Info("secret changed", "provider", provider.Name(), "secret", name)
+		}
+		s.mu.Unlock()
+	}
+	if changed {
+		secretRefreshes.WithLabelValues("changed").Inc()
+	} else {
+		secretRefreshes.WithLabelValues("unchanged").Inc()
+	}
+	return changed
+}
+
+// Run refreshes the cached secrets every interval until ctx is cancelled,
+// calling onChange after any of them changed
+func (s *secretStore) Run(ctx context.Context, interval time.Duration, onChange func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if s.refresh(ctx) {
+			onChange()
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"log/slog"
+	"math/rand"
+	"time"
+)
+
+// Endpoint selection strategies
+const (
+	// StrategyBest always picks the highest scoring endpoint
+	StrategyBest = "best"
+	// StrategyWeighted picks a healthy endpoint at random, weighted by health score
+	StrategyWeighted = "weighted"
+	// StrategyLatency picks the healthy endpoint with the lowest observed latency
+	StrategyLatency = "latency"
+	// StrategyRoundRobin rotates through healthy endpoints
+	StrategyRoundRobin = "round_robin"
+)
+
+// minHealthyScore is the effective score an endpoint needs to be selected
+const minHealthyScore = 0.5
+
+// endpointCandidate is a selectable endpoint with its effective score
+type endpointCandidate struct {
+	endpoint string
+	score    float64
+	latency  time.Duration
+}
+
+// selectEndpoint picks the endpoint to dial next using the chain's strategy
+func (cm *ChainMonitor) selectEndpoint() string {
+	switch cm.options.EndpointStrategy {
+	case StrategyWeighted, StrategyLatency, StrategyRoundRobin:
+	default:
+		return cm.getBestEndpoint()
+	}
+
+	candidates := cm.healthyEndpoints()
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	switch cm.options.EndpointStrategy {
+	case StrategyWeighted:
+		var total float64
+		for _, c := range candidates {
+			total += c.score
+		}
+		pick := rand.Float64() * total
+		for _, c := range candidates {
+			if pick -= c.score; pick <= 0 {
+				return c.endpoint
+			}
+		}
+		return candidates[len(candidates)-1].endpoint
+
+	case StrategyLatency:
+		// Unmeasured endpoints report zero latency so they get measured first
+		best := candidates[0]
+		for _, c := range candidates[1:] {
+			if c.latency < best.latency {
+				best = c
+			}
+		}
+		return best.endpoint
+
+	default:
+		next := cm.roundRobin.Add(1) - 1
+		return candidates[next%uint64(len(candidates))].endpoint
+	}
+}
+
+// healthyEndpoints returns enabled endpoints at or above the health threshold, in configured order
+func (cm *ChainMonitor) healthyEndpoints() []endpointCandidate {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	var candidates []endpointCandidate
+	for _, endpoint := range cm.endpoints {
+		if _, disabled := cm.disabled[endpoint]; disabled {
+			continue
+		}
+		if !cm.breaker.Allowed(endpoint) {
+			continue
+		}
+		score := cm.healthScores[endpoint] * cm.probeFactor(endpoint)
+		if score < minHealthyScore {
+			continue
+		}
+		candidates = append(candidates, endpointCandidate{
+			endpoint: endpoint,
+			score:    score,
+			latency:  cm.latencies[endpoint],
+		})
+	}
+	return candidates
+}
+
+// recordLatency folds a connect or probe round trip into the endpoint's moving average
+func (cm *ChainMonitor) recordLatency(endpoint string, latency time.Duration) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if current, ok := cm.latencies[endpoint]; ok {
+		latency = (latency + 3*current) / 4
+	}
+	cm.latencies[endpoint] = latency
+}
+
+// validEndpointStrategy reports whether strategy is known, logging a warning otherwise
+func validEndpointStrategy(chain, strategy string) bool {
+	switch strategy {
+	case StrategyBest, StrategyWeighted, StrategyLatency, StrategyRoundRobin:
+		return true
+	}
+	slog.Warn("unknown endpoint strategy", "chain", chain, "strategy", strategy, "using", StrategyBest)
+	return false
+}
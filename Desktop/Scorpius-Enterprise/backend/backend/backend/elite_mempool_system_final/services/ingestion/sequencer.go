@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gorilla/websocket"
+)
+
+// Sequencer feed kinds
+const (
+	// SequencerArbitrum reads the sequencer feed websocket of a Nitro chain
+	// (e.g. wss://arb1.arbitrum.io/feed)
+	SequencerArbitrum = "arbitrum"
+	// SequencerOPStack follows the unsafe head of an OP-stack rollup node
+	// (op-node HTTP RPC) and reads unsafe blocks from its execution engine
+	SequencerOPStack = "op-stack"
+)
+
+const (
+	// arbitrumL1MessageL2 is the L1 message kind of user transactions the
+	// sequencer orders; other kinds are deposits and ArbOS housekeeping
+	arbitrumL1MessageL2 = 3
+	// L2 message kinds carrying signed transactions
+	arbitrumL2MessageBatch    = 3
+	arbitrumL2MessageSignedTx = 4
+	// arbitrumMaxBatchDepth is how deeply Nitro allows batches to nest
+	arbitrumMaxBatchDepth = 16
+	// opStackDepositTxType marks deposits derived from L1, not sequenced by the rollup
+	opStackDepositTxType = "0x7e"
+)
+
+// sequencerFeed describes where a rollup's sequencer publishes the order of
+// its transactions
+type sequencerFeed struct {
+	kind string
+	// genesisBlock is the L2 block of the first feed message on Nitro
+	// chains, so feed sequence numbers map to block numbers
+	genesisBlock uint64
+}
+
+// sequencerFeeds lists the rollups whose sequencer output can be read by
+// chain ID
+var sequencerFeeds = map[int64]sequencerFeed{
+	42161: {kind: SequencerArbitrum, genesisBlock: 22207817},
+	10:    {kind: SequencerOPStack},
+	8453:  {kind: SequencerOPStack},
+}
+
+// SequencerMonitor captures L2 transactions as the sequencer orders them,
+// before they are posted to L1, where the public mempool sees little of
+// the flow. Transactions keep the sequencer's order in BlockNumber and
+// TransactionIndex; with <CHAIN>_WORKERS=1 they are also published in
+// that order. Endpoints come from <CHAIN>_SEQUENCER_URLS.
+type SequencerMonitor struct {
+	*ChainMonitor
+	feed   sequencerFeed
+	signer types.Signer
+	// next is the Arbitrum feed sequence number to resume from after a reconnect
+	next uint64
+}
+
+// NewSequencerMonitor creates a sequencer feed monitor for a supported rollup
+func NewSequencerMonitor(chain ChainInfo, endpoints []string, options ChainOptions, sink Sink, cache Cache, alerter *Alerter) (*SequencerMonitor, error) {
+	feed, ok := sequencerFeeds[chain.ChainID]
+	if !ok {
+		return nil, fmt.Errorf("sequencer feed ingestion is not supported for chain id %d", chain.ChainID)
+	}
+
+	sm := &SequencerMonitor{
+		ChainMonitor: NewChainMonitor(chain.Name, chain.ChainID, endpoints, options, sink, cache, alerter),
+		feed:         feed,
+		signer:       types.LatestSignerForChainID(big.NewInt(chain.ChainID)),
+	}
+	sm.family = chain.Family
+	sm.streamer = sm
+	return sm, nil
+}
+
+func (sm *SequencerMonitor) stream(endpoint string) error {
+	if sm.feed.kind == SequencerArbitrum {
+		return sm.streamArbitrum(endpoint)
+	}
+	return sm.streamOPStack(endpoint)
+}
+
+// arbitrumFeedFrame is a broadcast from the Arbitrum sequencer feed
+type arbitrumFeedFrame struct {
+	Messages []struct {
+		SequenceNumber uint64 `json:"sequenceNumber"`
+		Message        struct {
+			Message struct {
+				Header struct {
+					Kind uint8 `json:"kind"`
+				} `json:"header"`
+				L2Msg []byte `json:"l2Msg"`
+			} `json:"message"`
+		} `json:"message"`
+	} `json:"messages"`
+}
+
+// streamArbitrum reads the sequencer feed, resuming after the last message
+// read so a reconnect leaves no gap the feed still holds
+func (sm *SequencerMonitor) streamArbitrum(endpoint string) error {
+	header := http.Header{"Arbitrum-Feed-Client-Version": []string{"2"}}
+	if sm.next > 0 {
+		header.Set("Arbitrum-Requested-Sequence-Number", strconv.FormatUint(sm.next, 10))
+	}
+	dialer := websocket.Dialer{HandshakeTimeout: 10 * time.Second, EnableCompression: true}
+
+	start := time.Now()
+	conn, _, err := dialer.DialContext(sm.ctx, endpoint, header)
+	if err != nil {
+		sm.updateHealthScore(endpoint, 0.0)
+		return fmt.Errorf("failed to connect to %s: %v", endpoint, err)
+	}
+	defer conn.Close()
+	latency := time.Since(start)
+	connectionLatency.WithLabelValues(sm.chainName, endpointLabel(endpoint)).Observe(latency.Seconds())
+	sm.recordLatency(endpoint, latency)
+
+	// Registered so draining and fault injection can close it
+	sm.mu.Lock()
+	sm.activeConn = conn
+	sm.mu.Unlock()
+
+	sm.backoff.Reset(endpoint)
+	sm.breaker.Success(endpoint)
+	sm.beginWarmup()
+
+	stopKeepalive := sm.keepalive(conn)
+	defer stopKeepalive()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if sm.ctx.Err() != nil {
+				return nil
+			}
+			if isReadTimeout(err) {
+				staleConnections.WithLabelValues(sm.chainName, endpointLabel(endpoint)).Inc()
+				return fmt.Errorf("no data or pong from %s within %s", endpoint, sm.options.ReadTimeout)
+			}
+			return fmt.Errorf("error reading message: %v", err)
+		}
+		sm.extendReadDeadline(conn)
+		if sm.chaos.Drop(sm.chainName) {
+			continue
+		}
+		data = sm.chaos.Corrupt(sm.chainName, data)
+
+		var frame arbitrumFeedFrame
+		if err := wireJSON.Unmarshal(data, &frame); err != nil {
+			sm.logger.Error("failed to decode message", "endpoint", displayEndpoint(endpoint), "error", err)
+			continue
+		}
+		for _, msg := range frame.Messages {
+			// Resumed feeds may repeat messages already read
+			if msg.SequenceNumber < sm.next {
+				continue
+			}
+			sm.next = msg.SequenceNumber + 1
+			if msg.Message.Message.Header.Kind != arbitrumL1MessageL2 {
+				continue
+			}
+
+			txs, err := parseArbitrumL2Message(msg.Message.Message.L2Msg, 0)
+			if err != nil {
+				sm.logger.Error("failed to handle message", "sequence_number", msg.SequenceNumber, "error", err)
+			}
+			block := int64(sm.feed.genesisBlock + msg.SequenceNumber)
+			for i, gethTx := range txs {
+				tx, err := sm.convertGethTx(sm.signer, gethTx)
+				if err != nil {
+					sm.logger.Error("failed to convert sequenced transaction", "tx_hash", gethTx.Hash().Hex(), "error", err)
+					continue
+				}
+				// ArbOS opens every block with its own start-block transaction
+				index := i + 1
+				tx.BlockNumber, tx.TransactionIndex = &block, &index
+				if err := sm.publishTransaction(tx); err != nil {
+					sm.logger.Error("failed to handle message", "tx_hash", tx.Hash, "error", err)
+				}
+			}
+		}
+		sm.recordActivity(endpoint)
+	}
+}
+
+// parseArbitrumL2Message extracts the signed transactions of an L2 message,
+// in order. Batches hold length-prefixed nested messages; unsigned and
+// contract transactions and heartbeats are skipped.
+func parseArbitrumL2Message(data []byte, depth int) ([]*types.Transaction, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	switch data[0] {
+	case arbitrumL2MessageSignedTx:
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(data[1:]); err != nil {
+			return nil, fmt.Errorf("invalid signed transaction: %v", err)
+		}
+		return []*types.Transaction{tx}, nil
+
+	case arbitrumL2MessageBatch:
+		if depth >= arbitrumMaxBatchDepth {
+			return nil, fmt.Errorf("batch nested deeper than %d", arbitrumMaxBatchDepth)
+		}
+		var txs []*types.Transaction
+		for rest := data[1:]; len(rest) > 0; {
+			if len(rest) < 8 {
+				return txs, fmt.Errorf("truncated batch")
+			}
+			size := binary.BigEndian.Uint64(rest)
+			rest = rest[8:]
+			if size > uint64(len(rest)) {
+				return txs, fmt.Errorf("truncated batch")
+			}
+			nested, err := parseArbitrumL2Message(rest[:size], depth+1)
+			txs = append(txs, nested...)
+			if err != nil {
+				return txs, err
+			}
+			rest = rest[size:]
+		}
+		return txs, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// opStackBlock is an unsafe block with full transaction objects
+type opStackBlock struct {
+	Number       string            `json:"number"`
+	Hash         string            `json:"hash"`
+	Transactions []json.RawMessage `json:"transactions"`
+}
+
+// streamOPStack follows the rollup node's unsafe head and publishes the
+// transactions of each new unsafe block, read from the execution engine at
+// <CHAIN>_SEQUENCER_ENGINE_URL
+func (sm *SequencerMonitor) streamOPStack(endpoint string) error {
+	rollup := newRPCClient(endpoint, 10*time.Second)
+	engine := newRPCClient(sm.options.SequencerEngine, 10*time.Second)
+
+	start := time.Now()
+	head, err := unsafeHead(sm.ctx, rollup)
+	if err != nil {
+		if sm.ctx.Err() != nil {
+			return nil
+		}
+		sm.updateHealthScore(endpoint, 0.0)
+		return fmt.Errorf("failed to connect to %s: %v", endpoint, err)
+	}
+	latency := time.Since(start)
+	connectionLatency.WithLabelValues(sm.chainName, endpointLabel(endpoint)).Observe(latency.Seconds())
+	sm.recordLatency(endpoint, latency)
+
+	sm.backoff.Reset(endpoint)
+	sm.breaker.Success(endpoint)
+	sm.beginWarmup()
+
+	ticker := time.NewTicker(sm.options.SequencerPoll)
+	defer ticker.Stop()
+
+	next := head + 1
+	for {
+		select {
+		case <-sm.ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		head, err := unsafeHead(sm.ctx, rollup)
+		if err != nil {
+			if sm.ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if head >= next+maxBlockCatchUp {
+			sm.logger.Warn("sequencer feed fell behind, skipping ahead", "from", next, "to", head)
+			next = head
+		}
+
+		for ; next <= head; next++ {
+			var block opStackBlock
+			if err := engine.Call(sm.ctx, "eth_getBlockByNumber", []interface{}{fmt.Sprintf("0x%x", next), true}, &block); err != nil {
+				sm.logger.Warn("failed to read unsafe block", "block", next, "error", err)
+				break
+			}
+			// The engine has not imported the block yet
+			if block.Hash == "" {
+				break
+			}
+			sm.publishBlock(int64(next), block)
+		}
+		sm.recordActivity(endpoint)
+	}
+}
+
+// publishBlock publishes an unsafe block's sequenced transactions in block order
+func (sm *SequencerMonitor) publishBlock(number int64, block opStackBlock) {
+	for i, raw := range block.Transactions {
+		tx, err := sm.decodeTransaction(raw)
+		if err != nil {
+			sm.logger.Error("failed to handle message", "block", number, "error", err)
+			continue
+		}
+		if tx.Type == opStackDepositTxType {
+			continue
+		}
+		index := i
+		tx.BlockNumber, tx.TransactionIndex = &number, &index
+		if err := sm.publishTransaction(tx); err != nil {
+			sm.logger.Error("failed to handle message", "tx_hash", tx.Hash, "error", err)
+		}
+	}
+}
+
+// unsafeHead returns the number of the rollup node's unsafe L2 head, the
+// newest block the sequencer has produced
+func unsafeHead(ctx context.Context, rollup *rpcClient) (uint64, error) {
+	var status struct {
+		UnsafeL2 struct {
+			Number uint64 `json:"number"`
+		} `json:"unsafe_l2"`
+	}
+	if err := rollup.Call(ctx, "optimism_syncStatus", []interface{}{}, &status); err != nil {
+		return 0, err
+	}
+	return status.UnsafeL2.Number, nil
+}
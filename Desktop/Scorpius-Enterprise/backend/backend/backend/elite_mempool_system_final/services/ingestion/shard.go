@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+)
+
+// shardMembersKey is a sorted set of live instance IDs scored by their last
+// heartbeat in Unix milliseconds
+const shardMembersKey = "shard:instances"
+
+// shardVirtualNodes is how many points each instance has on the hash ring;
+// more points spread chains more evenly between instances
+const shardVirtualNodes = 128
+
+var (
+	shardMembers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "scorpius_shard_members",
+		Help: "Live ingestion instances sharing chains",
+	})
+
+	shardOwned = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "scorpius_shard_owned_chains",
+		Help: "Configured chains assigned to this instance",
+	})
+
+	shardRebalances = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "scorpius_shard_rebalances_total",
+		Help: "Chain reassignments after instances joined or left",
+	})
+)
+
+// ShardConfig configures sharding chains across instances
+type ShardConfig struct {
+	Enabled    bool
+	InstanceID string
+	Heartbeat  time.Duration
+	TTL        time.Duration
+}
+
+// loadShardConfig reads SHARD_* settings
+func loadShardConfig() ShardConfig {
+	return ShardConfig{
+		Enabled:    getEnvBool("SHARDING", false),
+		InstanceID: getEnvOrDefault("SHARD_INSTANCE_ID", instanceID()),
+		Heartbeat:  getEnvDuration("SHARD_HEARTBEAT", 2*time.Second),
+		TTL:        getEnvDuration("SHARD_TTL", 10*time.Second),
+	}
+}
+
+// hashRing assigns keys to members by consistent hashing, so a member
+// joining or leaving only moves the keys it gains or loses
+type hashRing struct {
+	points []uint64
+	owners map[uint64]string
+}
+
+// shardHash places a value on the ring. FNV alone clusters similar strings
+// such as an instance's point names, so its output is mixed with the
+// SplitMix64 finalizer.
+func shardHash(value string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+	x := h.Sum64()
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+func newHashRing(members []string) *hashRing {
+	ring := &hashRing{owners: make(map[uint64]string, len(members)*shardVirtualNodes)}
+	for _, member := range members {
+		for i := 0; i < shardVirtualNodes; i++ {
+			point := shardHash(member + "#" + strconv.Itoa(i))
+			ring.points = append(ring.points, point)
+			ring.owners[point] = member
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool { return ring.points[i] < ring.points[j] })
+	return ring
+}
+
+// Owner returns the member owning key: the first point at or after its hash
+func (r *hashRing) Owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	hash := shardHash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+// shardCoordinator registers this instance in Redis and tracks which
+// instances are alive. Each instance runs only the chains the hash ring
+// assigns to it; when membership changes every instance recomputes the ring
+// and starts or stops chains to match. An instance that stops heartbeating
+// drops out after the TTL and its chains move to the others.
+//
+// During a rebalance a moving chain can briefly run on both instances or
+// neither; shared dedup keys stop the overlap publishing duplicates.
+type shardCoordinator struct {
+	client    *redis.Client
+	config    ShardConfig
+	mu        sync.RWMutex
+	members   []string
+	ring      *hashRing
+	rebalance func()
+}
+
+func newShardCoordinator(client *redis.Client, config ShardConfig) *shardCoordinator {
+	return &shardCoordinator{
+		client: client,
+		config: config,
+		ring:   newHashRing([]string{config.InstanceID}),
+	}
+}
+
+// Join registers this instance and loads the current members, so the first
+// chains started are already this instance's share
+func (c *shardCoordinator) Join(ctx context.Context) error {
+	if _, err := c.heartbeat(ctx); err != nil {
+		return fmt.Errorf("failed to join shard group: %v", err)
+	}
+	slog.Info("joined shard group", "instance", c.config.InstanceID, "members", c.Members())
+	return nil
+}
+
+// Run heartbeats until ctx is cancelled, calling rebalance whenever the
+// members change, then leaves the group so chains move without waiting for
+// the TTL
+func (c *shardCoordinator) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.config.Heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.leave()
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := c.heartbeat(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("shard heartbeat failed", "error", err)
+			}
+			continue
+		}
+		if changed && c.rebalance != nil {
+			shardRebalances.Inc()
+			slog.Info("shard members changed, rebalancing chains", "members", c.Members())
+			c.rebalance()
+		}
+	}
+}
+
+// heartbeat refreshes this instance's registration, expires silent instances
+// and reports whether the members changed
+func (c *shardCoordinator) heartbeat(ctx context.Context) (bool, error) {
+	now := time.Now().UnixMilli()
+	pipe := c.client.TxPipeline()
+	pipe.ZAdd(ctx, shardMembersKey, redis.Z{Score: float64(now), Member: c.config.InstanceID})
+	pipe.ZRemRangeByScore(ctx, shardMembersKey, "-inf", strconv.FormatInt(now-c.config.TTL.Milliseconds(), 10))
+	members := pipe.ZRange(ctx, shardMembersKey, 0, -1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+
+	current := members.Val()
+	sort.Strings(current)
+	shardMembers.Set(float64(len(current)))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if reflect.DeepEqual(current, c.members) {
+		return false, nil
+	}
+	c.members = current
+	c.ring = newHashRing(current)
+	return true, nil
+}
+
+// leave removes this instance from the group
+func (c *shardCoordinator) leave() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.client.ZRem(ctx, shardMembersKey, c.config.InstanceID).Err(); err != nil {
+		slog.Warn("failed to leave shard group", "error", err)
+	}
+}
+
+// Members returns the live instance IDs
+func (c *shardCoordinator) Members() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]string(nil), c.members...)
+}
+
+// Owner returns the instance a chain is assigned to
+func (c *shardCoordinator) Owner(chainName string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ring.Owner(chainName)
+}
+
+// Owns reports whether this instance should run a chain
+func (c *shardCoordinator) Owns(chainName string) bool {
+	return c.Owner(chainName) == c.config.InstanceID
+}
+
+// ownsChain reports whether this instance should run a chain; without
+// sharding it runs every configured chain
+func (is *IngestionService) ownsChain(chainName string) bool {
+	return is.shards == nil || is.shards.Owns(chainName)
+}
+
+// rebalance starts the configured chains this instance now owns and stops
+// the ones it no longer does
+func (is *IngestionService) rebalance() {
+	is.reloadMu.Lock()
+	defer is.reloadMu.Unlock()
+
+	if is.ctx.Err() != nil {
+		return
+	}
+
+	is.mu.RLock()
+	var stop []string
+	for chainName := range is.monitors {
+		if !is.ownsChain(chainName) {
+			stop = append(stop, chainName)
+		}
+	}
+	start := make(map[string][]string)
+	for chainName, endpoints := range is.config.ChainEndpoints {
+		if _, running := is.monitors[chainName]; !running && is.ownsChain(chainName) {
+			start[chainName] = endpoints
+		}
+	}
+	is.mu.RUnlock()
+
+	for _, chainName := range stop {
+		is.stopMonitor(chainName, DrainRebalance)
+	}
+	started := 0
+	for chainName, endpoints := range start {
+		if err := is.startMonitor(chainName, endpoints); err != nil {
+			slog.Error("failed to start monitor after rebalance", "chain", chainName, "error", err)
+			continue
+		}
+		started++
+	}
+	is.updateShardOwned()
+
+	if is.config.CanaryInterval > 0 && (len(stop) > 0 || started > 0) {
+		is.startCanaries()
+	}
+	slog.Info("chains rebalanced", "stopped", len(stop), "started", started)
+}
+
+// updateShardOwned records how many chains this instance runs
+func (is *IngestionService) updateShardOwned() {
+	is.mu.RLock()
+	defer is.mu.RUnlock()
+	shardOwned.Set(float64(len(is.monitors)))
+}
+
+// registerShardAPI mounts GET /api/shards, listing the live instances and
+// which instance each configured chain is assigned to
+func (is *IngestionService) registerShardAPI(mux *http.ServeMux) {
+	mux.HandleFunc("/api/shards", func(w http.ResponseWriter, r *http.Request) {
+		if is.shards == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "sharding is not enabled"})
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+
+		is.mu.RLock()
+		assignments := make(map[string]string, len(is.config.ChainEndpoints))
+		for chainName := range is.config.ChainEndpoints {
+			assignments[chainName] = is.shards.Owner(chainName)
+		}
+		is.mu.RUnlock()
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"instance":    is.config.Shards.InstanceID,
+			"members":     is.shards.Members(),
+			"assignments": assignments,
+		})
+	})
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Solidity revert payload selectors: Error(string) and Panic(uint256)
+const (
+	revertErrorSelector = "08c379a0"
+	revertPanicSelector = "4e487b71"
+)
+
+var (
+	simulations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_simulations_total",
+			Help: "Pending transaction simulations, by result (success, revert, error or skipped)",
+		},
+		[]string{"chain", "result"},
+	)
+
+	simulationDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "scorpius_simulation_duration_seconds",
+			Help:    "Time spent simulating a pending transaction, including the state diff trace",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 10),
+		},
+		[]string{"chain"},
+	)
+)
+
+// SimulationConfig holds the simulation settings shared by every chain; the
+// endpoint and selection expression are per chain
+type SimulationConfig struct {
+	Trace       bool
+	Timeout     time.Duration
+	Concurrency int
+}
+
+// loadSimulationConfig reads SIMULATION_* settings
+func loadSimulationConfig() SimulationConfig {
+	return SimulationConfig{
+		Trace:       getEnvBool("SIMULATION_TRACE", false),
+		Timeout:     getEnvDuration("SIMULATION_TIMEOUT", 2*time.Second),
+		Concurrency: getEnvInt("SIMULATION_CONCURRENCY", 8),
+	}
+}
+
+// Simulation is the predicted outcome of executing a pending transaction
+// against the latest state
+type Simulation struct {
+	Success      bool          `json:"success"`
+	RevertReason string        `json:"revert_reason,omitempty"`
+	ReturnData   string        `json:"return_data,omitempty"`
+	StateDiff    []StateChange `json:"state_diff,omitempty"`
+}
+
+// StateChange is one account field or storage slot the transaction would change
+type StateChange struct {
+	Address string `json:"address"`
+	Field   string `json:"field"`
+	Slot    string `json:"slot,omitempty"`
+	Before  string `json:"before"`
+	After   string `json:"after"`
+}
+
+// prestateAccount is an account in a prestateTracer diff
+type prestateAccount struct {
+	Balance string            `json:"balance"`
+	Nonce   uint64            `json:"nonce"`
+	Code    string            `json:"code"`
+	Storage map[string]string `json:"storage"`
+}
+
+// simulator runs selected transactions through eth_call, and optionally
+// debug_traceCall with the prestate tracer for a state diff, on a chain's
+// simulation endpoint. It runs inline before publishing, bounded by the
+// timeout; when every slot is busy transactions are published unsimulated
+// rather than delayed further.
+type simulator struct {
+	client *rpcClient
+	expr   *txExpr
+	trace  bool
+	slots  chan struct{}
+}
+
+// newSimulator creates a chain's simulator, or nil when it has no simulation endpoint
+func newSimulator(options ChainOptions, config SimulationConfig) (*simulator, error) {
+	if options.SimulationURL == "" {
+		return nil, nil
+	}
+
+	s := &simulator{
+		client: newRPCClient(httpURLFor(options.SimulationURL), config.Timeout),
+		trace:  config.Trace,
+		slots:  make(chan struct{}, config.Concurrency),
+	}
+	if options.SimulationExpr != "" {
+		expr, err := compileExpr(options.SimulationExpr)
+		if err != nil {
+			return nil, err
+		}
+		s.expr = expr
+	}
+	return s, nil
+}
+
+// simulate attaches the predicted outcome to tx when it matches the chain's
+// simulation expression. Endpoint failures leave tx unsimulated.
+func (cm *ChainMonitor) simulate(ctx context.Context, tx *Transaction) {
+	s := cm.simulator
+	if s == nil || tx.ChainFamily != FamilyEVM || tx.Canary || tx.Status != "pending" {
+		return
+	}
+	if s.expr != nil && !s.expr.Matches(exprVars(tx), cm.chainName, "simulation") {
+		return
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	default:
+		simulations.WithLabelValues(cm.chainName, "skipped").Inc()
+		return
+	}
+
+	ctx, span := tracer.Start(ctx, "simulate")
+	start := time.Now()
+	simulation, err := s.run(ctx, tx)
+	simulationDuration.WithLabelValues(cm.chainName).Observe(time.Since(start).Seconds())
+	if err != nil {
+		endSpan(span, err)
+		simulations.WithLabelValues(cm.chainName, "error").Inc()
+		cm.logger.Debug("failed to simulate transaction", "tx_hash", tx.Hash, "error", err)
+		return
+	}
+
+	result := "success"
+	if !simulation.Success {
+		result = "revert"
+		span.SetAttributes(attribute.String("revert_reason", simulation.RevertReason))
+	}
+	endSpan(span, nil)
+	simulations.WithLabelValues(cm.chainName, result).Inc()
+	tx.Simulation = simulation
+}
+
+// run executes tx against the latest block
+func (s *simulator) run(ctx context.Context, tx *Transaction) (*Simulation, error) {
+	call := callObject(tx)
+
+	var returnData string
+	err := s.client.Call(ctx, "eth_call", []interface{}{call, "latest"}, &returnData)
+	var rpcErr *rpcError
+	if errors.As(err, &rpcErr) {
+		// The node executed the call and it failed: a revert, or a
+		// precondition such as the sender's balance
+		return &Simulation{RevertReason: revertReason(rpcErr)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	simulation := &Simulation{Success: true, ReturnData: returnData}
+	if returnData == "0x" {
+		simulation.ReturnData = ""
+	}
+	if !s.trace {
+		return simulation, nil
+	}
+
+	var diff struct {
+		Pre  map[string]prestateAccount `json:"pre"`
+		Post map[string]prestateAccount `json:"post"`
+	}
+	tracerOptions := map[string]interface{}{
+		"tracer":       "prestateTracer",
+		"tracerConfig": map[string]interface{}{"diffMode": true},
+	}
+	if err := s.client.Call(ctx, "debug_traceCall", []interface{}{call, "latest", tracerOptions}, &diff); err != nil {
+		return nil, err
+	}
+	simulation.StateDiff = stateChanges(diff.Pre, diff.Post)
+	return simulation, nil
+}
+
+// callObject builds the eth_call request for tx
+func callObject(tx *Transaction) map[string]interface{} {
+	call := map[string]interface{}{
+		"from": tx.From,
+	}
+	if tx.To != "" {
+		call["to"] = tx.To
+	}
+	if tx.Gas != "" {
+		call["gas"] = tx.Gas
+	}
+	if tx.Value != "" {
+		call["value"] = tx.Value
+	}
+	if tx.Data != "" {
+		call["data"] = tx.Data
+	}
+	// Nodes reject a call that sets both a gas price and dynamic fees
+	if tx.MaxFeePerGas != "" {
+		call["maxFeePerGas"] = tx.MaxFeePerGas
+		if tx.MaxPriorityFeePerGas != "" {
+			call["maxPriorityFeePerGas"] = tx.MaxPriorityFeePerGas
+		}
+	} else if tx.GasPrice != "" {
+		call["gasPrice"] = tx.GasPrice
+	}
+	if len(tx.AccessList) > 0 {
+		accessList := make([]rpcAccessTuple, len(tx.AccessList))
+		for i, tuple := range tx.AccessList {
+			accessList[i] = rpcAccessTuple{Address: tuple.Address, StorageKeys: tuple.StorageKeys}
+		}
+		call["accessList"] = accessList
+	}
+	return call
+}
+
+// revertReason decodes an Error(string) or Panic(uint256) revert payload,
+// falling back to the node's message
+func revertReason(err *rpcError) string {
+	var data string
+	if len(err.Data) > 0 && err.Data[0] == '"' {
+		data = strings.Trim(string(err.Data), `"`)
+	}
+	payload, decodeErr := hex.DecodeString(strings.TrimPrefix(data, "0x"))
+	if decodeErr != nil || len(payload) < 4 {
+		return err.Message
+	}
+
+	selector, args := hex.EncodeToString(payload[:4]), payload[4:]
+	switch {
+	case selector == revertErrorSelector && len(args) >= 64:
+		length := new(big.Int).SetBytes(args[32:64])
+		if length.IsUint64() && length.Uint64() <= uint64(len(args)-64) {
+			return string(args[64 : 64+length.Uint64()])
+		}
+	case selector == revertPanicSelector && len(args) >= 32:
+		return "panic 0x" + new(big.Int).SetBytes(args[:32]).Text(16)
+	}
+	return err.Message + " (" + data + ")"
+}
+
+// stateChanges flattens a prestateTracer diff. Post holds only what changed;
+// pre holds the earlier values, missing for new accounts and slots.
+func stateChanges(pre, post map[string]prestateAccount) []StateChange {
+	var changes []StateChange
+	for address, after := range post {
+		before := pre[address]
+		address = strings.ToLower(address)
+
+		if after.Balance != "" && after.Balance != before.Balance {
+			changes = append(changes, StateChange{Address: address, Field: "balance", Before: orZero(before.Balance), After: after.Balance})
+		}
+		if after.Nonce != 0 && after.Nonce != before.Nonce {
+			changes = append(changes, StateChange{Address: address, Field: "nonce",
+				Before: "0x" + strconv.FormatUint(before.Nonce, 16), After: "0x" + strconv.FormatUint(after.Nonce, 16)})
+		}
+		// Code is reported by hash to keep messages small
+		if after.Code != "" && after.Code != before.Code {
+			changes = append(changes, StateChange{Address: address, Field: "code_hash", Before: codeHash(before.Code), After: codeHash(after.Code)})
+		}
+		for slot, value := range after.Storage {
+			changes = append(changes, StateChange{Address: address, Field: "storage", Slot: slot, Before: orZero(before.Storage[slot]), After: value})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Address != b.Address {
+			return a.Address < b.Address
+		}
+		if a.Field != b.Field {
+			return a.Field < b.Field
+		}
+		return a.Slot < b.Slot
+	})
+	return changes
+}
+
+func orZero(value string) string {
+	if value == "" {
+		return "0x0"
+	}
+	return value
+}
+
+func codeHash(code string) string {
+	if code == "" || code == "0x" {
+		return ""
+	}
+	return crypto.Keccak256Hash(common.FromHex(code)).Hex()
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/redis/go-redis/v9"
+)
+
+// Sink types
+const (
+	SinkKafka = "kafka"
+	SinkNATS  = "nats"
+)
+
+// Sink publishes encoded messages to a topic on a message bus. Publish must
+// not keep key, value or headers once it returns: the publish path reuses
+// them for the next message, so a sink that sends them later copies them.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, topic string, key, value []byte, headers map[string]string) error
+	Close()
+}
+
+// newSink creates the named sink, or a logging stand-in for it in a dry run
+func newSink(name string, config Config, redisClient *redis.Client) (Sink, error) {
+	if config.DryRun {
+		if name == "" {
+			name = SinkKafka
+		}
+		return newDryRunSink(name), nil
+	}
+
+	switch name {
+	case "", SinkKafka:
+		return newKafkaSinks(config)
+	case SinkNATS:
+		return NewNATSSink(config.NATSURL, config.NATSSubjectPrefix, config.NATSStream, config.NATSCredentials)
+	case SinkKinesis:
+		return NewKinesisSink(config.Kinesis)
+	case SinkPubSub:
+		return NewPubSubSink(config.PubSub)
+	case SinkRedisStreams:
+		return NewRedisStreamSink(redisClient, config.RedisStreamPrefix, config.RedisStreamMaxLen), nil
+	default:
+		return nil, fmt.Errorf("unknown sink %q", name)
+	}
+}
+
+// Kafka tuning profiles
+const (
+	KafkaThroughput = "throughput"
+	KafkaLatency    = "latency"
+)
+
+// Kafka acknowledgement levels
+const (
+	KafkaAcksAll    = "all"
+	KafkaAcksLeader = "1"
+	KafkaAcksNone   = "0"
+)
+
+// kafkaCompressions are the compression.type values librdkafka accepts
+var kafkaCompressions = []string{"none", "gzip", "snappy", "lz4", "zstd"}
+
+// KafkaConfig tunes compression, batching and acknowledgements for the Kafka
+// producers. KAFKA_PROFILE picks the defaults; each setting overrides its own.
+type KafkaConfig struct {
+	Profile          string
+	Compression      string
+	Acks             string
+	LingerMS         int
+	BatchBytes       int
+	BatchMessages    int
+	MessageMaxBytes  int
+	QueueMaxMessages int
+}
+
+// kafkaProfiles are the defaults for each profile. Throughput lingers to
+// fill large compressed batches; latency sends almost immediately with cheap
+// compression and a shorter queue, so backpressure shows up sooner.
+var kafkaProfiles = map[string]KafkaConfig{
+	KafkaThroughput: {
+		Compression:      "lz4",
+		Acks:             KafkaAcksAll,
+		LingerMS:         100,
+		BatchBytes:       1000000,
+		BatchMessages:    10000,
+		MessageMaxBytes:  1000000,
+		QueueMaxMessages: 500000,
+	},
+	KafkaLatency: {
+		Compression:      "lz4",
+		Acks:             KafkaAcksAll,
+		LingerMS:         1,
+		BatchBytes:       131072,
+		BatchMessages:    1000,
+		MessageMaxBytes:  1000000,
+		QueueMaxMessages: 100000,
+	},
+}
+
+// loadKafkaConfig reads KAFKA_PROFILE and the settings overriding its defaults.
+// An unknown profile is reported by validation; its settings default to throughput.
+func loadKafkaConfig() KafkaConfig {
+	profile := getEnvOrDefault("KAFKA_PROFILE", KafkaThroughput)
+	defaults, ok := kafkaProfiles[profile]
+	if !ok {
+		defaults = kafkaProfiles[KafkaThroughput]
+	}
+
+	return KafkaConfig{
+		Profile:          profile,
+		Compression:      getEnvOrDefault("KAFKA_COMPRESSION", defaults.Compression),
+		Acks:             getEnvOrDefault("KAFKA_ACKS", defaults.Acks),
+		LingerMS:         getEnvInt("KAFKA_LINGER_MS", defaults.LingerMS),
+		BatchBytes:       getEnvInt("KAFKA_BATCH_BYTES", defaults.BatchBytes),
+		BatchMessages:    getEnvInt("KAFKA_BATCH_MESSAGES", defaults.BatchMessages),
+		MessageMaxBytes:  getEnvInt("KAFKA_MESSAGE_MAX_BYTES", defaults.MessageMaxBytes),
+		QueueMaxMessages: getEnvInt("KAFKA_QUEUE_MAX_MESSAGES", defaults.QueueMaxMessages),
+	}
+}
+
+// kafkaProducerConfig is the librdkafka configuration shared by every producer.
+// Delivery reports carry the headers so retried and dead-lettered messages
+// keep them.
+func kafkaProducerConfig(brokers string, config KafkaConfig) *kafka.ConfigMap {
+	return &kafka.ConfigMap{
+		"go.delivery.report.fields":    "key,value,headers",
+		"bootstrap.servers":            brokers,
+		"compression.type":             config.Compression,
+		"acks":                         config.Acks,
+		"linger.ms":                    config.LingerMS,
+		"batch.size":                   config.BatchBytes,
+		"batch.num.messages":           config.BatchMessages,
+		"message.max.bytes":            config.MessageMaxBytes,
+		"queue.buffering.max.messages": config.QueueMaxMessages,
+	}
+}
+
+// KafkaSink publishes through an asynchronous, batching Kafka producer.
+// Delivery reports are checked in the background; see handleDeliveryReports.
+// A supervisor checks broker health and replaces the producer after a fatal
+// error; see supervise.
+type KafkaSink struct {
+	cluster        string
+	producerConfig *kafka.ConfigMap
+	producerMu     sync.RWMutex
+	producer       *kafka.Producer
+	dlqTopic       string
+	maxRetries     int
+	retryBackoff   time.Duration
+	healthInterval time.Duration
+	healthTimeout  time.Duration
+	drainTimeout   time.Duration
+	retries        sync.WaitGroup
+	reports        sync.WaitGroup
+	fatal          chan error
+	stop           chan struct{}
+	supervised     chan struct{}
+	healthMu       sync.RWMutex
+	healthErr      error
+	mu             sync.RWMutex
+	closing        bool
+}
+
+// NewKafkaSink creates a Kafka producer for the named cluster from the
+// service configuration
+func NewKafkaSink(config Config, cluster, brokers string) (*KafkaSink, error) {
+	producerConfig := kafkaProducerConfig(brokers, config.Kafka)
+	// Idempotence (needs acks=all) stops broker-side duplicates when the
+	// producer retries after a timeout or leader change
+	producerConfig.SetKey("enable.idempotence", config.KafkaIdempotent)
+	// Statistics report each broker's connection state
+	producerConfig.SetKey("statistics.interval.ms", int(config.KafkaHealthInterval.Milliseconds()))
+	producer, err := kafka.NewProducer(producerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka producer for %s cluster: %v", cluster, err)
+	}
+
+	s := &KafkaSink{
+		cluster:        cluster,
+		producerConfig: producerConfig,
+		producer:       producer,
+		dlqTopic:       config.KafkaDLQTopic,
+		maxRetries:     config.KafkaDeliveryRetries,
+		retryBackoff:   config.KafkaRetryBackoff,
+		healthInterval: config.KafkaHealthInterval,
+		healthTimeout:  config.KafkaHealthTimeout,
+		drainTimeout:   config.Drain.Timeout,
+		fatal:          make(chan error, 1),
+		stop:           make(chan struct{}),
+		supervised:     make(chan struct{}),
+		healthErr:      errKafkaUnchecked,
+	}
+	s.reports.Add(1)
+	go s.handleDeliveryReports(producer)
+	go s.supervise()
+	return s, nil
+}
+
+// Name returns the sink name
+func (s *KafkaSink) Name() string {
+	return SinkKafka
+}
+
+// Ping reports the result of the latest broker health check, so readiness
+// follows broker availability without a metadata request per probe
+func (s *KafkaSink) Ping(ctx context.Context) error {
+	s.healthMu.RLock()
+	defer s.healthMu.RUnlock()
+	return s.healthErr
+}
+
+// Publish queues a message on the producer
+func (s *KafkaSink) Publish(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
+	msg := kafkaMessagePool.Get().(*kafkaMessage)
+	fillKafkaMessage(msg, topic, key, value, headers)
+
+	// The primary cluster's acknowledgement records produce latency and
+	// completes the SLO measurement
+	var delivery *sloDelivery
+	if s.cluster == kafkaPrimary {
+		delivery = sloDeliveryFrom(ctx)
+		delivery.Defer()
+		msg.Opaque = &deliveryAttempt{attempt: 1, chain: headers["chain_name"], slo: delivery}
+	}
+
+	// A failed produce fails the whole delivery, whether the caller gives up
+	// or spools the message
+	err := s.produce(&msg.Message)
+	if err != nil {
+		produceFailures.Add(s.cluster)
+		delivery.Failed()
+	}
+	kafkaMessagePool.Put(msg)
+	return err
+}
+
+// produce queues msg on the current producer. The read lock keeps a replaced
+// producer open until calls already using it return.
+func (s *KafkaSink) produce(msg *kafka.Message) error {
+	s.producerMu.RLock()
+	defer s.producerMu.RUnlock()
+	return s.producer.Produce(msg, nil)
+}
+
+// Flush waits up to timeout for queued messages to be delivered and returns
+// how many are still outstanding
+func (s *KafkaSink) Flush(timeout time.Duration) int {
+	s.producerMu.RLock()
+	defer s.producerMu.RUnlock()
+	return s.producer.Flush(int(timeout.Milliseconds()))
+}
+
+// beginRetry registers a pending retry unless the sink is closing
+func (s *KafkaSink) beginRetry() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closing {
+		return false
+	}
+	s.retries.Add(1)
+	return true
+}
+
+// Close flushes outstanding messages for up to the drain timeout and closes
+// the producer, logging how many were never acknowledged. Deliveries that
+// fail while closing go straight to the dead-letter topic.
+func (s *KafkaSink) Close() {
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+
+	// Stop the supervisor first so the producer is not replaced while closing
+	close(s.stop)
+	<-s.supervised
+
+	deadline := time.Now().Add(s.drainTimeout)
+	queued := s.producer.Len()
+	s.producer.Flush(int(s.drainTimeout.Milliseconds()))
+	s.retries.Wait()
+	// Retries and their dead-letter copies get whatever time is left, at least a second
+	undelivered := s.producer.Flush(int(max(time.Until(deadline), time.Second).Milliseconds()))
+	s.producer.Close()
+	s.reports.Wait()
+
+	if undelivered > 0 {
+		slog.Warn("closed Kafka producer with undelivered messages", "cluster", s.cluster, "queued", queued, "undelivered", undelivered)
+	} else {
+		slog.Info("flushed Kafka producer", "cluster", s.cluster, "queued", queued)
+	}
+}
+
+// isKafkaSink reports whether sink writes to Kafka, transactionally or not
+func isKafkaSink(sink Sink) bool {
+	switch s := sink.(type) {
+	case *KafkaSink, *txnBatcher, *kafkaMirrorSink:
+		return true
+	case *spoolSink:
+		return isKafkaSink(s.sink)
+	case *fencedSink:
+		return isKafkaSink(s.sink)
+	case *routedDeliverySink:
+		return isKafkaSink(s.sink)
+	}
+	return false
+}
+
+// newKafkaMessage builds a message with headers in a stable order. It
+// copies key and value, which the caller may reuse once Publish returns.
+func newKafkaMessage(topic string, key, value []byte, headers map[string]string) *kafka.Message {
+	data := append(append(make([]byte, 0, len(key)+len(value)), key...), value...)
+	msg := &kafkaMessage{}
+	fillKafkaMessage(msg, topic, data[:len(key):len(key)], data[len(key):], headers)
+	return &msg.Message
+}
+
+// chainSinks publishes the side-topic events of service-wide components
+// (enrichers, the gas oracle, the SLO tracker) through the sink of the chain
+// each event belongs to, so they are fenced, routed, chaos-injected and
+// delivered exactly like the chain's transactions. Events for a chain with
+// no running monitor go to the service sink, or nowhere with leader election,
+// where only a running chain's lease says who may publish.
+type chainSinks struct {
+	sink     Sink
+	fenced   bool
+	mu       sync.RWMutex
+	monitors map[string]*ChainMonitor
+}
+
+func newChainSinks(sink Sink, fenced bool) *chainSinks {
+	return &chainSinks{sink: sink, fenced: fenced, monitors: make(map[string]*ChainMonitor)}
+}
+
+// add routes a chain's events through its monitor's sink
+func (s *chainSinks) add(monitor *ChainMonitor) {
+	s.mu.Lock()
+	s.monitors[monitor.chainName] = monitor
+	s.mu.Unlock()
+}
+
+// remove stops routing through monitor, unless the chain has been given a
+// newer one
+func (s *chainSinks) remove(monitor *ChainMonitor) {
+	s.mu.Lock()
+	if s.monitors[monitor.chainName] == monitor {
+		delete(s.monitors, monitor.chainName)
+	}
+	s.mu.Unlock()
+}
+
+func (s *chainSinks) monitor(chain string) *ChainMonitor {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.monitors[chain]
+}
+
+// standby reports whether this instance must leave a chain's events to
+// another instance
+func (s *chainSinks) standby(chain string) bool {
+	monitor := s.monitor(chain)
+	if monitor == nil {
+		return s.fenced
+	}
+	return monitor.standby()
+}
+
+// Publish sends an event of chain through the chain's sink
+func (s *chainSinks) Publish(ctx context.Context, chain, topic string, key, value []byte, headers map[string]string) error {
+	monitor := s.monitor(chain)
+	switch {
+	case monitor != nil:
+		return monitor.sink.Publish(ctx, topic, key, value, headers)
+	case s.fenced:
+		leaderStandbySkipped.WithLabelValues(chain).Inc()
+		return nil
+	}
+	return s.sink.Publish(ctx, topic, key, value, headers)
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// sloBucketWidth is the resolution of the SLO windows and how often burn
+// rates are evaluated
+const sloBucketWidth = 10 * time.Second
+
+// sloBounds are the upper bounds, in seconds, of the latency histogram
+// percentiles are estimated from
+var sloBounds = prometheus.ExponentialBuckets(0.0005, 2, 16)
+
+var (
+	sloEvents = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_slo_events_total",
+			Help: "Transactions measured against the latency SLO by chain and result (good, bad)",
+		},
+		[]string{"chain", "result"},
+	)
+
+	sloBurnRate = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_slo_burn_rate",
+			Help: "Rate the latency SLO's error budget is spent over the long and short windows; 1 spends it exactly",
+		},
+		[]string{"chain", "window"},
+	)
+
+	sloLatency = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_slo_latency_seconds",
+			Help: "Node-to-Kafka latency percentiles over the SLO short window",
+		},
+		[]string{"chain", "quantile"},
+	)
+
+	sloFiring = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_slo_alert_firing",
+			Help: "Whether the chain's latency SLO burn-rate alert is firing",
+		},
+		[]string{"chain"},
+	)
+)
+
+// SLOConfig sets the node-to-Kafka latency objective: the share of
+// transactions acknowledged by the broker within the threshold of being
+// received from the node. An alert fires when the error budget burns
+// faster than the burn rate over both windows.
+type SLOConfig struct {
+	Threshold   time.Duration
+	Thresholds  map[string]time.Duration
+	Objective   float64
+	LongWindow  time.Duration
+	ShortWindow time.Duration
+	BurnRate    float64
+	MinEvents   int
+	Topic       string
+	WebhookURL  string
+}
+
+// loadSLOConfig reads SLO_* settings. SLO_LATENCY_THRESHOLDS overrides the
+// threshold per chain as chain=duration pairs.
+func loadSLOConfig() SLOConfig {
+	config := SLOConfig{
+		Threshold:   getEnvDuration("SLO_LATENCY_THRESHOLD", 0),
+		Thresholds:  make(map[string]time.Duration),
+		Objective:   getEnvFloat("SLO_OBJECTIVE", 0.99),
+		LongWindow:  getEnvDuration("SLO_LONG_WINDOW", time.Hour),
+		ShortWindow: getEnvDuration("SLO_SHORT_WINDOW", 5*time.Minute),
+		BurnRate:    getEnvFloat("SLO_BURN_RATE", 14.4),
+		MinEvents:   getEnvInt("SLO_MIN_EVENTS", 100),
+		Topic:       getEnvOrDefault("SLO_ALERT_TOPIC", "ingestion_slo_alerts"),
+		WebhookURL:  getEnv("SLO_WEBHOOK_URL"),
+	}
+	for chain, value := range parseKeyValues(getEnv("SLO_LATENCY_THRESHOLDS")) {
+		threshold, err := time.ParseDuration(value)
+		if err != nil {
+			invalidSetting("SLO_LATENCY_THRESHOLDS", chain+"="+value, "duration", config.Threshold)
+			continue
+		}
+		config.Thresholds[chain] = threshold
+	}
+	registerSecrets(config.WebhookURL)
+	return config
+}
+
+// Enabled reports whether any chain has a latency objective
+func (c SLOConfig) Enabled() bool {
+	return c.Threshold > 0 || len(c.Thresholds) > 0
+}
+
+// ThresholdFor returns the chain's latency threshold, zero when it has none
+func (c SLOConfig) ThresholdFor(chain string) time.Duration {
+	if threshold, ok := c.Thresholds[chain]; ok {
+		return threshold
+	}
+	return c.Threshold
+}
+
+// SLOAlertEvent is published when a chain's burn-rate alert fires or
+// resolves
+type SLOAlertEvent struct {
+	Event         string  `json:"event"`
+	Status        string  `json:"status"`
+	Chain         string  `json:"chain"`
+	ChainID       int64   `json:"chain_id"`
+	Instance      string  `json:"instance"`
+	Threshold     float64 `json:"threshold_seconds"`
+	Objective     float64 `json:"objective"`
+	LongWindow    string  `json:"long_window"`
+	ShortWindow   string  `json:"short_window"`
+	BurnRateLong  float64 `json:"burn_rate_long"`
+	BurnRateShort float64 `json:"burn_rate_short"`
+	Events        int     `json:"events"`
+	BadEvents     int     `json:"bad_events"`
+	P50           float64 `json:"p50_seconds"`
+	P95           float64 `json:"p95_seconds"`
+	P99           float64 `json:"p99_seconds"`
+	Timestamp     int64   `json:"timestamp"`
+}
+
+// sloBucket counts the transactions measured in one bucket width
+type sloBucket struct {
+	start  int64
+	total  int
+	bad    int
+	counts []int
+}
+
+// sloChain holds a chain's buckets covering the long window
+type sloChain struct {
+	threshold time.Duration
+	buckets   []sloBucket
+	firing    bool
+}
+
+// sloWindow is the sum of the buckets in a window
+type sloWindow struct {
+	total  int
+	bad    int
+	counts []int
+}
+
+// burnRate is how many times faster than allowed the window spends the
+// error budget
+func (w sloWindow) burnRate(objective float64) float64 {
+	if w.total == 0 {
+		return 0
+	}
+	return float64(w.bad) / float64(w.total) / (1 - objective)
+}
+
+// quantile estimates a latency percentile by interpolating within the
+// histogram bucket it falls in
+func (w sloWindow) quantile(q float64) float64 {
+	if w.total == 0 {
+		return 0
+	}
+	rank := q * float64(w.total)
+	cumulative, lower := 0, 0.0
+	for i, count := range w.counts {
+		if i == len(sloBounds) {
+			return sloBounds[len(sloBounds)-1]
+		}
+		if float64(cumulative+count) >= rank && count > 0 {
+			return lower + (sloBounds[i]-lower)*(rank-float64(cumulative))/float64(count)
+		}
+		cumulative += count
+		lower = sloBounds[i]
+	}
+	return lower
+}
+
+// sloTracker measures each chain's node-to-Kafka latency against its
+// objective and raises burn-rate alerts. A nil tracker measures nothing.
+type sloTracker struct {
+	config  SLOConfig
+	sinks   *chainSinks
+	alerter *Alerter
+	client  *http.Client
+	mu      sync.Mutex
+	chains  map[string]*sloChain
+}
+
+// newSLOTracker returns the tracker for config, or nil without objectives
+func newSLOTracker(config SLOConfig, sinks *chainSinks, alerter *Alerter) *sloTracker {
+	if !config.Enabled() {
+		return nil
+	}
+	slog.Info("latency SLO enabled", "threshold", config.Threshold, "thresholds", len(config.Thresholds), "objective", config.Objective,
+		"long_window", config.LongWindow, "short_window", config.ShortWindow, "burn_rate", config.BurnRate)
+	return &sloTracker{
+		config:  config,
+		sinks:   sinks,
+		alerter: alerter,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		chains:  make(map[string]*sloChain),
+	}
+}
+
+// sloDelivery follows one transaction to the broker. Sinks that confirm
+// delivery asynchronously defer it once per message produced, and it is
+// observed exactly once: when the last message is acknowledged after
+// publishing finished, or on the first failure.
+type sloDelivery struct {
+	tracker  *sloTracker
+	chain    string
+	received time.Time
+	// pending counts unacknowledged messages plus one held until publishing finishes
+	pending  atomic.Int32
+	observed atomic.Bool
+}
+
+type sloDeliveryKey struct{}
+
+// track returns ctx carrying a delivery to observe for tx's chain, or ctx
+// unchanged when the chain has no objective
+func (t *sloTracker) track(ctx context.Context, chain string, received time.Time) (context.Context, *sloDelivery) {
+	if t == nil || t.config.ThresholdFor(chain) <= 0 {
+		return ctx, nil
+	}
+	delivery := &sloDelivery{tracker: t, chain: chain, received: received}
+	delivery.pending.Store(1)
+	return context.WithValue(ctx, sloDeliveryKey{}, delivery), delivery
+}
+
+// sloDeliveryFrom returns the delivery carried by ctx, if any
+func sloDeliveryFrom(ctx context.Context) *sloDelivery {
+	delivery, _ := ctx.Value(sloDeliveryKey{}).(*sloDelivery)
+	return delivery
+}
+
+// Defer adds a message whose acknowledgement the delivery waits for. Call it
+// before producing the message so an early acknowledgement cannot complete
+// the delivery.
+func (d *sloDelivery) Defer() {
+	if d != nil {
+		d.pending.Add(1)
+	}
+}
+
+// Delivered marks one message acknowledged, or publishing finished, and
+// observes the latency from the node to now once nothing is outstanding
+func (d *sloDelivery) Delivered() {
+	if d != nil && d.pending.Add(-1) == 0 {
+		d.observe(false)
+	}
+}
+
+// Failed counts a transaction that never reached the broker against the
+// error budget
+func (d *sloDelivery) Failed() {
+	if d != nil {
+		d.observe(true)
+	}
+}
+
+func (d *sloDelivery) observe(failed bool) {
+	if d.observed.CompareAndSwap(false, true) {
+		d.tracker.observe(d.chain, time.Since(d.received), failed)
+	}
+}
+
+// observe records one transaction's latency
+func (t *sloTracker) observe(chain string, latency time.Duration, failed bool) {
+	threshold := t.config.ThresholdFor(chain)
+	bad := failed || latency > threshold
+	result := "good"
+	if bad {
+		result = "bad"
+	}
+	sloEvents.WithLabelValues(chain, result).Inc()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.chains[chain]
+	if c == nil {
+		c = &sloChain{threshold: threshold, buckets: make([]sloBucket, int(t.config.LongWindow/sloBucketWidth)+1)}
+		t.chains[chain] = c
+	}
+	now := time.Now().Unix() / int64(sloBucketWidth/time.Second)
+	b := &c.buckets[now%int64(len(c.buckets))]
+	if b.start != now {
+		*b = sloBucket{start: now, counts: make([]int, len(sloBounds)+1)}
+	}
+	b.total++
+	if bad {
+		b.bad++
+	}
+	b.counts[sort.SearchFloat64s(sloBounds, latency.Seconds())]++
+}
+
+// window sums the chain's buckets within d of now; callers hold t.mu
+func (t *sloTracker) window(c *sloChain, d time.Duration) sloWindow {
+	now := time.Now().Unix() / int64(sloBucketWidth/time.Second)
+	oldest := now - int64(d/sloBucketWidth)
+	w := sloWindow{counts: make([]int, len(sloBounds)+1)}
+	for _, b := range c.buckets {
+		if b.start <= oldest || b.start > now {
+			continue
+		}
+		w.total += b.total
+		w.bad += b.bad
+		for i, count := range b.counts {
+			w.counts[i] += count
+		}
+	}
+	return w
+}
+
+// Run evaluates burn rates every bucket width until ctx is done
+func (t *sloTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(sloBucketWidth)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, event := range t.evaluate() {
+				// A standby delivers nothing, so its SLO is the leader's to report
+				if t.sinks.standby(event.Chain) {
+					continue
+				}
+				t.emit(ctx, event)
+			}
+		}
+	}
+}
+
+// evaluate updates the SLO metrics and returns the alerts that started or
+// stopped firing
+func (t *sloTracker) evaluate() []SLOAlertEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var events []SLOAlertEvent
+	for chain, c := range t.chains {
+		long, short := t.window(c, t.config.LongWindow), t.window(c, t.config.ShortWindow)
+		longBurn, shortBurn := long.burnRate(t.config.Objective), short.burnRate(t.config.Objective)
+		sloBurnRate.WithLabelValues(chain, "long").Set(longBurn)
+		sloBurnRate.WithLabelValues(chain, "short").Set(shortBurn)
+		p50, p95, p99 := short.quantile(0.5), short.quantile(0.95), short.quantile(0.99)
+		sloLatency.WithLabelValues(chain, "0.5").Set(p50)
+		sloLatency.WithLabelValues(chain, "0.95").Set(p95)
+		sloLatency.WithLabelValues(chain, "0.99").Set(p99)
+
+		firing := long.total >= t.config.MinEvents && longBurn >= t.config.BurnRate && shortBurn >= t.config.BurnRate
+		if firing == c.firing {
+			continue
+		}
+		c.firing = firing
+		status := "resolved"
+		if firing {
+			status = "firing"
+			sloFiring.WithLabelValues(chain).Set(1)
+		} else {
+			sloFiring.WithLabelValues(chain).Set(0)
+		}
+		events = append(events, SLOAlertEvent{
+			Event:         "slo_burn_rate",
+			Status:        status,
+			Chain:         chain,
+			ChainID:       chainRegistry[chain].ChainID,
+			Instance:      instanceID(),
+			Threshold:     c.threshold.Seconds(),
+			Objective:     t.config.Objective,
+			LongWindow:    t.config.LongWindow.String(),
+			ShortWindow:   t.config.ShortWindow.String(),
+			BurnRateLong:  longBurn,
+			BurnRateShort: shortBurn,
+			Events:        long.total,
+			BadEvents:     long.bad,
+			P50:           p50,
+			P95:           p95,
+			P99:           p99,
+			Timestamp:     time.Now().UnixMilli(),
+		})
+	}
+	return events
+}
+
+// emit raises the alert and publishes it to the SLO topic and webhook
+func (t *sloTracker) emit(ctx context.Context, event SLOAlertEvent) {
+	severity := SeverityCritical
+	message := fmt.Sprintf("latency SLO burning %.1fx budget over %s and %.1fx over %s (%d of %d transactions over %s, p99 %.3fs)",
+		event.BurnRateLong, event.LongWindow, event.BurnRateShort, event.ShortWindow, event.BadEvents, event.Events,
+		time.Duration(event.Threshold*float64(time.Second)), event.P99)
+	if event.Status == "resolved" {
+		severity = SeverityInfo
+		message = fmt.Sprintf("latency SLO burn rate back under %.1fx budget", t.config.BurnRate)
+	}
+	t.alerter.Raise(Alert{Chain: event.Chain, Category: "slo", Severity: severity, Message: message, Key: "latency_burn", Resolved: event.Status == "resolved"})
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("failed to marshal SLO alert", "chain", event.Chain, "error", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if t.config.Topic != "" {
+		info := chainRegistry[event.Chain]
+		topic := expandTopic(t.config.Topic, event.Chain, info.ChainID, info.Family)
+		headers := map[string]string{
+			"chain_id":   fmt.Sprintf("%d", event.ChainID),
+			"chain_name": event.Chain,
+			"format":     FormatJSON,
+			"event":      event.Event,
+		}
+		if err := t.sinks.Publish(ctx, event.Chain, topic, []byte(event.Chain), data, headers); err != nil {
+			slog.Warn("failed to publish SLO alert", "chain", event.Chain, "error", err)
+		}
+	}
+
+	if t.config.WebhookURL != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.config.WebhookURL, bytes.NewReader(data))
+		if err != nil {
+			slog.Warn("invalid SLO webhook URL", "error", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := t.client.Do(req)
+		if err != nil {
+			slog.Warn("failed to send SLO alert webhook", "chain", event.Chain, "error", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			slog.Warn("SLO alert webhook rejected the alert", "chain", event.Chain, "status", resp.Status)
+		}
+	}
+}
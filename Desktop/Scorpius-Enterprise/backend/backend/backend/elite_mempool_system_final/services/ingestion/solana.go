@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SolanaMonitor ingests Solana transactions via websocket logsSubscribe.
+// Solana has no public mempool, so transactions are captured at the
+// configured commitment (processed by default) as early as the node sees them.
+type SolanaMonitor struct {
+	*ChainMonitor
+}
+
+// NewSolanaMonitor creates a Solana monitor sharing the chain monitor's connection management
+func NewSolanaMonitor(chain ChainInfo, endpoints []string, options ChainOptions, sink Sink, cache Cache, alerter *Alerter) *SolanaMonitor {
+	sm := &SolanaMonitor{
+		ChainMonitor: NewChainMonitor(chain.Name, chain.ChainID, endpoints, options, sink, cache, alerter),
+	}
+	sm.family = chain.Family
+	sm.protocol = sm
+	return sm
+}
+
+// subscribeRequests returns one logsSubscribe per mentioned program, or a single "all" subscription
+func (sm *SolanaMonitor) subscribeRequests() []interface{} {
+	commitment := sm.options.Commitment
+	if commitment == "" {
+		commitment = "processed"
+	}
+	config := map[string]interface{}{"commitment": commitment}
+
+	if len(sm.options.LogsMentions) == 0 {
+		return []interface{}{solanaRequest(1, "logsSubscribe", "all", config)}
+	}
+
+	requests := make([]interface{}, 0, len(sm.options.LogsMentions))
+	for i, program := range sm.options.LogsMentions {
+		filter := map[string]interface{}{"mentions": []string{program}}
+		requests = append(requests, solanaRequest(i+1, "logsSubscribe", filter, config))
+	}
+	return requests
+}
+
+func solanaRequest(id int, method string, params ...interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  method,
+		"params":  params,
+	}
+}
+
+// handleMessage maps logsNotification payloads into the chain-agnostic Transaction envelope
+func (sm *SolanaMonitor) handleMessage(msg *rpcMessage) error {
+	if msg.Method != "logsNotification" || msg.Params == nil {
+		return nil
+	}
+
+	var result solanaLogsResult
+	if err := wireJSON.Unmarshal(msg.Params.Result, &result); err != nil {
+		return fmt.Errorf("failed to decode logsNotification: %v", err)
+	}
+	if isJSONNull(result.Value) {
+		return nil
+	}
+
+	var value solanaLogsValue
+	if err := wireJSON.Unmarshal(result.Value, &value); err != nil {
+		return fmt.Errorf("failed to decode logsNotification value: %v", err)
+	}
+
+	signature := value.Signature
+	if signature == "" {
+		return nil
+	}
+
+	tx := Transaction{
+		Hash:        signature,
+		ChainID:     sm.chainID,
+		Chain:       sm.chainName,
+		ChainFamily: FamilySolana,
+		Status:      "pending",
+		Timestamp:   time.Now().Unix(),
+		Raw:         result.Value,
+		BlockNumber: result.Context.Slot,
+	}
+
+	if !isJSONNull(value.Err) {
+		tx.Status = "failed"
+	}
+
+	return sm.publishTransaction(tx)
+}
+
+// solanaLogsResult is the payload of a logsNotification
+type solanaLogsResult struct {
+	Context struct {
+		Slot *int64 `json:"slot"`
+	} `json:"context"`
+	Value json.RawMessage `json:"value"`
+}
+
+// solanaLogsValue holds the fields read from a logsNotification value
+type solanaLogsValue struct {
+	Signature string          `json:"signature"`
+	Err       json.RawMessage `json:"err"`
+}
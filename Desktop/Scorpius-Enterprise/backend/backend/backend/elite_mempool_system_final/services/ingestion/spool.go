@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	spoolMessages = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_spool_messages_total",
+			Help: "Messages handled by a disk spool by outcome (spooled, replayed, dropped)",
+		},
+		[]string{"spool", "result"},
+	)
+
+	spoolPending = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_spool_pending",
+			Help: "Messages waiting in a disk spool for the broker to recover",
+		},
+		[]string{"spool"},
+	)
+)
+
+// spoolBucket holds spooled messages keyed by a big-endian sequence number,
+// so iterating the bucket replays them in the order they were spooled
+var spoolBucket = []byte("messages")
+
+// SpoolConfig configures the disk spool in front of the Kafka sink
+type SpoolConfig struct {
+	Dir            string
+	MaxMessages    int
+	ReplayBatch    int
+	ReplayInterval time.Duration
+}
+
+// loadSpoolConfig reads SPOOL_* settings; the spool is off without a directory
+func loadSpoolConfig() SpoolConfig {
+	return SpoolConfig{
+		Dir:            getEnv("SPOOL_DIR"),
+		MaxMessages:    getEnvInt("SPOOL_MAX_MESSAGES", 1000000),
+		ReplayBatch:    getEnvInt("SPOOL_REPLAY_BATCH", 1000),
+		ReplayInterval: getEnvDuration("SPOOL_REPLAY_INTERVAL", time.Second),
+	}
+}
+
+// Enabled reports whether messages are spooled during broker outages
+func (c SpoolConfig) Enabled() bool {
+	return c.Dir != ""
+}
+
+// spooledMessage is a message waiting on disk
+type spooledMessage struct {
+	Topic   string            `json:"topic"`
+	Key     []byte            `json:"key"`
+	Value   []byte            `json:"value"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// flusher is implemented by sinks that can wait for queued messages to be
+// acknowledged, returning how many are still outstanding
+type flusher interface {
+	Flush(timeout time.Duration) int
+}
+
+// spoolSink writes messages to a bbolt file while its sink is unhealthy or
+// rejecting messages, and replays them in order once it recovers. While
+// anything is spooled, new messages are spooled behind it to keep the order.
+//
+// Replayed messages are removed only after the sink has acknowledged them,
+// so a crash mid-replay sends some of them again: delivery is at least once.
+type spoolSink struct {
+	name         string
+	sink         Sink
+	config       SpoolConfig
+	drainTimeout time.Duration
+	db           *bolt.DB
+	mu           sync.Mutex
+	pending      int
+	stop         chan struct{}
+	done         chan struct{}
+
+	// seq is the last sequence number reserved; writing holds the ones
+	// reserved but not yet in the spool, which replay must not pass
+	seq     uint64
+	writing map[uint64]struct{}
+}
+
+// newSpoolSink opens (or creates) the spool <name>.spool under config.Dir and
+// starts replaying anything left from a previous run once sink is healthy.
+// On close it keeps replaying for up to drainTimeout.
+func newSpoolSink(name string, sink Sink, config SpoolConfig, drainTimeout time.Duration) (*spoolSink, error) {
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %v", err)
+	}
+	path := filepath.Join(config.Dir, name+".spool")
+	// Another instance holding the file lock means a shared directory
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool %s: %v", path, err)
+	}
+
+	s := &spoolSink{
+		name:         name,
+		sink:         sink,
+		config:       config,
+		drainTimeout: drainTimeout,
+		db:           db,
+		writing:      make(map[uint64]struct{}),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(spoolBucket)
+		if err != nil {
+			return err
+		}
+		s.pending = bucket.Stats().KeyN
+		s.seq = bucket.Sequence()
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open spool %s: %v", path, err)
+	}
+	spoolPending.WithLabelValues(s.name).Set(float64(s.pending))
+	if s.pending > 0 {
+		slog.Info("replaying spooled messages from a previous run", "spool", s.name, "pending", s.pending)
+	}
+
+	go s.run()
+	return s, nil
+}
+
+// Name returns the wrapped sink's name
+func (s *spoolSink) Name() string {
+	return s.sink.Name()
+}
+
+// Ping reports the wrapped sink's health
+func (s *spoolSink) Ping(ctx context.Context) error {
+	if p, ok := s.sink.(pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// healthy reports whether the wrapped sink can take messages
+func (s *spoolSink) healthy() bool {
+	return s.Ping(context.Background()) == nil
+}
+
+// Publish sends the message to the sink, or spools it while the sink is
+// unhealthy, earlier messages are still spooled or the sink rejects it
+func (s *spoolSink) Publish(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
+	s.mu.Lock()
+	if s.pending == 0 && s.healthy() {
+		s.mu.Unlock()
+		err := s.sink.Publish(ctx, topic, key, value, headers)
+		if err == nil {
+			return nil
+		}
+		slog.Warn("spooling message the sink rejected", "spool", s.name, "topic", topic, "error", err)
+		s.mu.Lock()
+	}
+
+	// A spooled message reaches the broker only once the sink recovers, so
+	// its transaction counts against the SLO now rather than never
+	sloDeliveryFrom(ctx).Failed()
+	seq, err := s.reserve()
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return s.append(seq, spooledMessage{Topic: topic, Key: key, Value: value, Headers: headers})
+}
+
+// reserve counts a message about to be spooled and assigns its sequence
+// number, so later messages queue behind it while it is written and the
+// spool keeps the order messages were reserved in; the caller holds s.mu
+func (s *spoolSink) reserve() (uint64, error) {
+	if s.pending >= s.config.MaxMessages {
+		spoolMessages.WithLabelValues(s.name, "dropped").Inc()
+		return 0, fmt.Errorf("spool is full (%d messages)", s.pending)
+	}
+
+	if s.pending == 0 {
+		slog.Warn("sink unavailable, spooling messages to disk", "spool", s.name)
+	}
+	s.pending++
+	s.seq++
+	s.writing[s.seq] = struct{}{}
+	spoolPending.WithLabelValues(s.name).Set(float64(s.pending))
+	return s.seq, nil
+}
+
+// append writes a message under its reserved sequence number. Concurrent
+// appends are grouped into one bbolt transaction, and so one fsync, by
+// db.Batch.
+func (s *spoolSink) append(seq uint64, message spooledMessage) error {
+	data, err := json.Marshal(message)
+	if err == nil {
+		err = s.db.Batch(func(tx *bolt.Tx) error {
+			bucket := tx.Bucket(spoolBucket)
+			// Keep the bucket's sequence past every id for the next start
+			if seq > bucket.Sequence() {
+				if err := bucket.SetSequence(seq); err != nil {
+					return err
+				}
+			}
+			return bucket.Put(spoolID(seq), data)
+		})
+	}
+
+	s.mu.Lock()
+	delete(s.writing, seq)
+	if err != nil {
+		s.pending--
+		spoolPending.WithLabelValues(s.name).Set(float64(s.pending))
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to spool message: %v", err)
+	}
+
+	spoolMessages.WithLabelValues(s.name, "spooled").Inc()
+	return nil
+}
+
+// spoolID returns the bucket key of a sequence number
+func spoolID(seq uint64) []byte {
+	id := make([]byte, 8)
+	binary.BigEndian.PutUint64(id, seq)
+	return id
+}
+
+// replayLimit returns the first sequence number still being written, or
+// zero when none is; replay stops there so it never passes a message
+// reserved earlier than the ones it sends. The caller holds s.mu.
+func (s *spoolSink) replayLimit() uint64 {
+	var limit uint64
+	for seq := range s.writing {
+		if limit == 0 || seq < limit {
+			limit = seq
+		}
+	}
+	return limit
+}
+
+// run replays the spool every ReplayInterval while the sink is healthy
+func (s *spoolSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.config.ReplayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		pending := s.pending
+		s.mu.Unlock()
+		if pending == 0 || !s.healthy() {
+			continue
+		}
+
+		// Drain batch by batch until the spool empties or the sink fails
+		for {
+			replayed, err := s.replay()
+			if err != nil {
+				slog.Warn("spool replay stopped", "spool", s.name, "error", err)
+				break
+			}
+			if replayed == 0 {
+				break
+			}
+			select {
+			case <-s.stop:
+				return
+			default:
+			}
+		}
+	}
+}
+
+// replay publishes the oldest batch of spooled messages and removes them
+// once the sink has acknowledged them. It returns how many were removed.
+func (s *spoolSink) replay() (int, error) {
+	s.mu.Lock()
+	limit := s.replayLimit()
+	s.mu.Unlock()
+
+	var ids, corrupt [][]byte
+	var messages []spooledMessage
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(spoolBucket).Cursor()
+		for id, data := cursor.First(); id != nil && len(ids) < s.config.ReplayBatch; id, data = cursor.Next() {
+			if limit != 0 && binary.BigEndian.Uint64(id) >= limit {
+				break
+			}
+			id = append([]byte(nil), id...)
+			var message spooledMessage
+			if err := json.Unmarshal(data, &message); err != nil {
+				// Unreadable entries would block the spool forever; drop them
+				slog.Error("dropping corrupt spooled message", "spool", s.name, "error", err)
+				corrupt = append(corrupt, id)
+				continue
+			}
+			ids = append(ids, id)
+			messages = append(messages, message)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(ids)+len(corrupt) == 0 {
+		return 0, nil
+	}
+
+	for i, message := range messages {
+		if err := s.sink.Publish(context.Background(), message.Topic, message.Key, message.Value, message.Headers); err != nil {
+			// Keep this message and everything after it for the next attempt
+			ids = ids[:i]
+			if i == 0 && len(corrupt) == 0 {
+				return 0, err
+			}
+			break
+		}
+	}
+	if f, ok := s.sink.(flusher); ok {
+		if outstanding := f.Flush(10 * time.Second); outstanding > 0 {
+			return 0, fmt.Errorf("%d replayed messages not acknowledged", outstanding)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(spoolBucket)
+		for _, id := range append(ids, corrupt...) {
+			if err := bucket.Delete(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove replayed messages: %v", err)
+	}
+
+	s.pending -= len(ids) + len(corrupt)
+	spoolPending.WithLabelValues(s.name).Set(float64(s.pending))
+	spoolMessages.WithLabelValues(s.name, "replayed").Add(float64(len(ids)))
+	spoolMessages.WithLabelValues(s.name, "dropped").Add(float64(len(corrupt)))
+	if s.pending == 0 {
+		slog.Info("spool drained, publishing directly", "spool", s.name)
+	}
+	return len(ids) + len(corrupt), nil
+}
+
+// Close stops the replay loop, then drains the spool into the sink for up to
+// the drain timeout if the sink is healthy, and closes the sink and the
+// spool. Messages still spooled are replayed on the next start.
+func (s *spoolSink) Close() {
+	close(s.stop)
+	<-s.done
+
+	s.drain()
+	s.sink.Close()
+	s.mu.Lock()
+	if s.pending > 0 {
+		slog.Warn("closing with messages still spooled", "spool", s.name, "pending", s.pending)
+	}
+	s.mu.Unlock()
+	if err := s.db.Close(); err != nil {
+		slog.Error("failed to close spool", "spool", s.name, "error", err)
+	}
+}
+
+// drain replays the spool until it is empty, the sink fails or the drain
+// timeout passes
+func (s *spoolSink) drain() {
+	deadline := time.Now().Add(s.drainTimeout)
+	for time.Now().Before(deadline) && s.healthy() {
+		replayed, err := s.replay()
+		if err != nil {
+			slog.Warn("spool drain stopped", "spool", s.name, "error", err)
+			return
+		}
+		if replayed == 0 {
+			return
+		}
+	}
+}
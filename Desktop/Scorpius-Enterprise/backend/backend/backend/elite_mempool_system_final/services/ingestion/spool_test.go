@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink records published values and fails while marked down
+type recordingSink struct {
+	mu     sync.Mutex
+	down   bool
+	values []string
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Ping(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errors.New("broker unavailable")
+	}
+	return nil
+}
+
+func (s *recordingSink) Publish(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errors.New("broker unavailable")
+	}
+	s.values = append(s.values, string(value))
+	return nil
+}
+
+func (s *recordingSink) Close() {}
+
+func (s *recordingSink) setDown(down bool) {
+	s.mu.Lock()
+	s.down = down
+	s.mu.Unlock()
+}
+
+func (s *recordingSink) published() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.values...)
+}
+
+// newTestSpool opens a spool in dir whose replay loop only runs when called
+func newTestSpool(t *testing.T, dir string, sink Sink) *spoolSink {
+	t.Helper()
+	config := SpoolConfig{Dir: dir, MaxMessages: 100, ReplayBatch: 10, ReplayInterval: time.Hour}
+	s, err := newSpoolSink("kafka", sink, config, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// replayAll replays the spool until it is empty
+func replayAll(t *testing.T, s *spoolSink) {
+	t.Helper()
+	for {
+		replayed, err := s.replay()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if replayed == 0 {
+			return
+		}
+	}
+}
+
+func TestSpoolReplaysInOrder(t *testing.T) {
+	sink := &recordingSink{}
+	s := newTestSpool(t, t.TempDir(), sink)
+	defer s.Close()
+
+	ctx := context.Background()
+	if err := s.Publish(ctx, "tx_raw", nil, []byte("0"), nil); err != nil {
+		t.Fatal(err)
+	}
+	sink.setDown(true)
+	want := []string{"0"}
+	for i := 1; i <= 25; i++ {
+		value := fmt.Sprint(i)
+		want = append(want, value)
+		if err := s.Publish(ctx, "tx_raw", nil, []byte(value), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink.setDown(false)
+
+	// Spooled messages hold back new ones until replayed
+	if err := s.Publish(ctx, "tx_raw", nil, []byte("26"), nil); err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, "26")
+	replayAll(t, s)
+
+	if got := sink.published(); !reflect.DeepEqual(got, want) {
+		t.Errorf("published %v, want %v", got, want)
+	}
+	if s.pending != 0 {
+		t.Errorf("pending = %d after replay, want 0", s.pending)
+	}
+}
+
+func TestSpoolKeepsReservationOrder(t *testing.T) {
+	sink := &recordingSink{}
+	s := newTestSpool(t, t.TempDir(), sink)
+	defer s.Close()
+
+	s.mu.Lock()
+	first, err := s.reserve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.reserve()
+	s.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The later reservation reaches the disk first
+	if err := s.append(second, spooledMessage{Topic: "tx_raw", Value: []byte("second")}); err != nil {
+		t.Fatal(err)
+	}
+	if replayed, err := s.replay(); err != nil || replayed != 0 {
+		t.Fatalf("replay() = %d, %v while an earlier message was being written; want 0", replayed, err)
+	}
+	if err := s.append(first, spooledMessage{Topic: "tx_raw", Value: []byte("first")}); err != nil {
+		t.Fatal(err)
+	}
+	replayAll(t, s)
+
+	if got, want := sink.published(), []string{"first", "second"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("published %v, want %v", got, want)
+	}
+}
+
+func TestSpoolConcurrentPublishers(t *testing.T) {
+	sink := &recordingSink{down: true}
+	s := newTestSpool(t, t.TempDir(), sink)
+	defer s.Close()
+
+	const publishers, perPublisher = 8, 10
+	var wg sync.WaitGroup
+	for p := 0; p < publishers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perPublisher; i++ {
+				if err := s.Publish(context.Background(), "tx_raw", nil, []byte(fmt.Sprintf("%d-%d", p, i)), nil); err != nil {
+					t.Error(err)
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+	sink.setDown(false)
+	replayAll(t, s)
+
+	got := sink.published()
+	if len(got) != publishers*perPublisher {
+		t.Fatalf("replayed %d messages, want %d", len(got), publishers*perPublisher)
+	}
+	// Each publisher's messages come back in the order it sent them
+	next := make(map[int]int)
+	for _, value := range got {
+		var p, i int
+		fmt.Sscanf(value, "%d-%d", &p, &i)
+		if i != next[p] {
+			t.Fatalf("publisher %d: replayed message %d, want %d", p, i, next[p])
+		}
+		next[p]++
+	}
+}
+
+func TestSpoolReplaysAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	sink := &recordingSink{down: true}
+	s := newTestSpool(t, dir, sink)
+	for _, value := range []string{"a", "b"} {
+		if err := s.Publish(context.Background(), "tx_raw", nil, []byte(value), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+
+	s = newTestSpool(t, dir, sink)
+	defer s.Close()
+	if s.pending != 2 {
+		t.Fatalf("pending = %d after restart, want 2", s.pending)
+	}
+	// New messages are spooled after the ones left from the previous run
+	if err := s.Publish(context.Background(), "tx_raw", nil, []byte("c"), nil); err != nil {
+		t.Fatal(err)
+	}
+	sink.setDown(false)
+	replayAll(t, s)
+
+	if got, want := sink.published(), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("published %v, want %v", got, want)
+	}
+}
+
+func TestSpoolFull(t *testing.T) {
+	sink := &recordingSink{down: true}
+	s := newTestSpool(t, t.TempDir(), sink)
+	defer s.Close()
+	s.config.MaxMessages = 2
+
+	for i := 0; i < 2; i++ {
+		if err := s.Publish(context.Background(), "tx_raw", nil, []byte("tx"), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Publish(context.Background(), "tx_raw", nil, []byte("tx"), nil); err == nil {
+		t.Error("Publish to a full spool succeeded")
+	}
+}
+
+// rejectingSink reports healthy but fails every produce after deferring its
+// SLO delivery, as the Kafka sink does when the local queue is full
+type rejectingSink struct{}
+
+func (rejectingSink) Name() string                   { return "rejecting" }
+func (rejectingSink) Ping(ctx context.Context) error { return nil }
+func (rejectingSink) Close()                         {}
+
+func (rejectingSink) Publish(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
+	sloDeliveryFrom(ctx).Defer()
+	return errors.New("local queue full")
+}
+
+func TestSpoolCountsAgainstSLO(t *testing.T) {
+	tests := []struct {
+		name string
+		sink Sink
+	}{
+		{"sink down", &recordingSink{down: true}},
+		{"produce rejected", rejectingSink{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSpool(t, t.TempDir(), tt.sink)
+			defer s.Close()
+			tracker := newSLOTracker(SLOConfig{Threshold: time.Second, LongWindow: time.Hour}, nil, nil)
+
+			// As deliverTransaction does once the sink accepts the message
+			ctx, delivery := tracker.track(context.Background(), "ethereum", time.Now())
+			if err := s.Publish(ctx, "tx_raw", nil, []byte("tx"), nil); err != nil {
+				t.Fatal(err)
+			}
+			delivery.Delivered()
+
+			// observe records each sloEvents sample in the chain's buckets
+			tracker.mu.Lock()
+			defer tracker.mu.Unlock()
+			chain := tracker.chains["ethereum"]
+			if chain == nil {
+				t.Fatal("the transaction was never observed")
+			}
+			if w := tracker.window(chain, time.Hour); w.total != 1 || w.bad != 1 {
+				t.Errorf("observed %d transactions, %d bad; want 1 bad", w.total, w.bad)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Server-Sent Events labels in the stream metrics: the replay buffer
+// subscribes to the hub once and clients read from it
+const (
+	sseTransport       = "sse"
+	sseReplayTransport = "sse_replay"
+)
+
+const (
+	sseHeartbeat = 15 * time.Second
+	sseRetry     = 3 * time.Second
+)
+
+// txSummary is the compact transaction sent to Server-Sent Events clients
+type txSummary struct {
+	Hash      string   `json:"hash"`
+	Chain     string   `json:"chain"`
+	ChainID   int64    `json:"chain_id"`
+	From      string   `json:"from"`
+	To        string   `json:"to,omitempty"`
+	Value     string   `json:"value"`
+	GasPrice  string   `json:"gas_price,omitempty"`
+	Nonce     string   `json:"nonce"`
+	Selector  string   `json:"selector,omitempty"`
+	Status    string   `json:"status"`
+	Timestamp int64    `json:"timestamp"`
+	Tags      []string `json:"tags,omitempty"`
+	MEV       []string `json:"mev,omitempty"`
+}
+
+func summarize(tx *Transaction) txSummary {
+	summary := txSummary{
+		Hash:      tx.Hash,
+		Chain:     tx.Chain,
+		ChainID:   tx.ChainID,
+		From:      tx.From,
+		To:        tx.To,
+		Value:     tx.Value,
+		GasPrice:  tx.GasPrice,
+		Nonce:     tx.Nonce,
+		Status:    tx.Status,
+		Timestamp: tx.Timestamp,
+	}
+	if summary.GasPrice == "" {
+		summary.GasPrice = tx.MaxFeePerGas
+	}
+	if len(tx.Data) >= 10 {
+		summary.Selector = tx.Data[:10]
+	}
+	for _, tag := range tx.Tags {
+		summary.Tags = append(summary.Tags, tag.Tag)
+	}
+	if tx.MEV != nil {
+		summary.MEV = tx.MEV.Kinds
+	}
+	return summary
+}
+
+// sseEvent is a published transaction with its event ID. tx is the hub's
+// detached copy, which is never recycled.
+type sseEvent struct {
+	id uint64
+	tx *Transaction
+}
+
+// sseLog numbers published transactions and keeps the latest for clients
+// reconnecting with Last-Event-ID. IDs start at the service's start time in
+// microseconds, so they keep increasing across restarts and a client
+// resuming against a restarted service is sent everything buffered.
+type sseLog struct {
+	mu     sync.Mutex
+	events []sseEvent
+	start  int
+	count  int
+	last   uint64
+	notify chan struct{}
+}
+
+func newSSELog(size int) *sseLog {
+	return &sseLog{
+		events: make([]sseEvent, size),
+		last:   uint64(time.Now().UnixMicro()),
+		notify: make(chan struct{}),
+	}
+}
+
+// Run numbers published transactions until ctx is cancelled
+func (l *sseLog) Run(ctx context.Context, hub *txHub, buffer int) {
+	sub := hub.Subscribe(sseReplayTransport, buffer, nil)
+	defer hub.Unsubscribe(sub)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case tx := <-sub.C:
+			l.append(tx)
+		}
+	}
+}
+
+func (l *sseLog) append(tx *Transaction) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.last++
+	l.events[(l.start+l.count)%len(l.events)] = sseEvent{id: l.last, tx: tx}
+	if l.count < len(l.events) {
+		l.count++
+	} else {
+		l.start = (l.start + 1) % len(l.events)
+	}
+
+	// Wake every waiting client
+	close(l.notify)
+	l.notify = make(chan struct{})
+}
+
+// Since returns the buffered events after id, oldest first, the ID to resume
+// from next time and a channel closed when more arrive. A client whose last
+// event has left the buffer misses the events in between.
+func (l *sseLog) Since(id uint64, max int) ([]sseEvent, uint64, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var events []sseEvent
+	for i := 0; i < l.count && len(events) < max; i++ {
+		event := l.events[(l.start+i)%len(l.events)]
+		if event.id > id {
+			events = append(events, event)
+		}
+	}
+	if len(events) > 0 {
+		id = events[len(events)-1].id
+	} else if id > l.last {
+		// An ID from the future: resume from now
+		id = l.last
+	}
+	return events, id, l.notify
+}
+
+// Last is the ID of the latest event
+func (l *sseLog) Last() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.last
+}
+
+// registerSSE mounts /sse/txs, which streams summaries of published
+// transactions as Server-Sent Events for browsers. Each event's ID can be
+// sent back as Last-Event-ID (or a lastEventId query parameter) to resume
+// after a reconnect from the replay buffer.
+//
+// Query parameters are the same as /ws/txs: chain, filter, rate and token.
+func (is *IngestionService) registerSSE(mux *http.ServeMux) {
+	mux.HandleFunc("/sse/txs", func(w http.ResponseWriter, r *http.Request) {
+		if is.sseLog == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "Server-Sent Events streaming is not enabled"})
+			return
+		}
+		client, ok := streamClient(r, is.config.Streams.Tokens)
+		if !ok {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid token"})
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming is not supported"})
+			return
+		}
+
+		query := r.URL.Query()
+		tenant, chains, quotaErr := is.openTenantStream(r.Context(), sseTransport, splitNonEmpty(query.Get("chain")))
+		if quotaErr != nil {
+			writeJSON(w, quotaErr.HTTPStatus(), map[string]string{"error": quotaErr.Error()})
+			return
+		}
+		defer tenant.Close()
+		match, err := txMatcher(chains, query.Get("filter"), sseTransport)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		limit := rate.Inf
+		if perSecond := streamRateLimit(r, is.config.Streams.RateLimit); perSecond > 0 {
+			limit = rate.Limit(perSecond)
+		}
+
+		cursor := is.sseLog.Last()
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = query.Get("lastEventId")
+		}
+		if lastEventID != "" {
+			id, err := strconv.ParseUint(lastEventID, 10, 64)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid Last-Event-ID " + strconv.Quote(lastEventID)})
+				return
+			}
+			cursor = id
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		// Stop reverse proxies such as nginx buffering the stream
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+		flusher.Flush()
+
+		streamSubscribers.WithLabelValues(sseTransport).Inc()
+		defer streamSubscribers.WithLabelValues(sseTransport).Dec()
+		slog.Info("SSE client connected", "client", client, "remote", r.RemoteAddr, "last_event_id", lastEventID)
+
+		limiter := rate.NewLimiter(limit, is.config.Streams.RateBurst)
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			events, next, more := is.sseLog.Since(cursor, is.config.Streams.Buffer)
+			cursor = next
+			for _, event := range events {
+				if !match(event.tx) {
+					continue
+				}
+				if !limiter.Allow() {
+					streamRateLimited.WithLabelValues(sseTransport).Inc()
+					continue
+				}
+				if !tenant.Allow() {
+					continue
+				}
+				data, err := json.Marshal(summarize(event.tx))
+				if err != nil {
+					slog.Error("failed to marshal transaction for SSE client", "tx_hash", event.tx.Hash, "error", err)
+					continue
+				}
+				n, err := fmt.Fprintf(w, "id: %d\nevent: tx\ndata: %s\n\n", event.id, data)
+				if err != nil {
+					slog.Debug("SSE write failed", "client", client, "error", err)
+					return
+				}
+				tenant.Delivered(n)
+			}
+			if len(events) > 0 {
+				flusher.Flush()
+				// A full batch means the client is behind; catch up before waiting
+				if len(events) == is.config.Streams.Buffer {
+					continue
+				}
+			}
+
+			select {
+			case <-r.Context().Done():
+				slog.Info("SSE client disconnected", "client", client, "remote", r.RemoteAddr)
+				return
+			case <-is.ctx.Done():
+				return
+			case <-heartbeat.C:
+				// A comment line keeps idle connections open through proxies
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-more:
+			}
+		}
+	})
+}
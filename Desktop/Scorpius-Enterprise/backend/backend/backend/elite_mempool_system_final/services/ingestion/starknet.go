@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StarknetMonitor ingests pending Starknet transactions through the
+// starknet_subscribePendingTransactions websocket subscription served by
+// pathfinder and juno (JSON-RPC 0.8). Starknet's account-abstracted
+// transaction model is mapped onto the common envelope as far as it fits;
+// the node's transaction object is kept whole in Raw.
+type StarknetMonitor struct {
+	*ChainMonitor
+}
+
+// NewStarknetMonitor creates a Starknet monitor sharing the chain monitor's connection management
+func NewStarknetMonitor(chain ChainInfo, endpoints []string, options ChainOptions, sink Sink, cache Cache, alerter *Alerter) *StarknetMonitor {
+	sm := &StarknetMonitor{
+		ChainMonitor: NewChainMonitor(chain.Name, chain.ChainID, endpoints, options, sink, cache, alerter),
+	}
+	sm.family = chain.Family
+	sm.protocol = sm
+	return sm
+}
+
+// subscribeRequests subscribes to pending transactions with full details
+func (sm *StarknetMonitor) subscribeRequests() []interface{} {
+	return []interface{}{map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "starknet_subscribePendingTransactions",
+		"params":  map[string]interface{}{"transaction_details": true},
+	}}
+}
+
+// handleMessage maps subscription notifications into the Transaction envelope
+func (sm *StarknetMonitor) handleMessage(msg *rpcMessage) error {
+	if msg.Error != nil {
+		return fmt.Errorf("pending transaction subscription failed: %v", msg.Error)
+	}
+	if msg.Method != "starknet_subscriptionPendingTransactions" || msg.Params == nil || isJSONNull(msg.Params.Result) {
+		return nil
+	}
+
+	var stx starknetTransaction
+	if err := wireJSON.Unmarshal(msg.Params.Result, &stx); err != nil {
+		return fmt.Errorf("failed to decode pending transaction: %v", err)
+	}
+	if stx.Hash == "" {
+		return nil
+	}
+
+	tx := Transaction{
+		Hash:        stx.Hash,
+		ChainID:     sm.chainID,
+		Chain:       sm.chainName,
+		ChainFamily: FamilyStarknet,
+		Type:        starknetTxType(stx.Type, stx.Version),
+		From:        stx.SenderAddress,
+		Value:       "0x0",
+		Nonce:       stx.Nonce,
+		Data:        starknetCalldata(stx.Calldata),
+		Status:      "pending",
+		Timestamp:   time.Now().Unix(),
+		Raw:         msg.Params.Result,
+	}
+
+	switch stx.Type {
+	case "L1_HANDLER":
+		// Sent by the L1 messaging contract to the L2 contract it names
+		tx.To = stx.ContractAddress
+	case "DEPLOY_ACCOUNT":
+		tx.Data = starknetCalldata(stx.ConstructorCalldata)
+	}
+
+	// Version 3 transactions bound each resource; L2 gas is the one the
+	// envelope's gas fields describe. Earlier versions only cap the total fee.
+	if l2, ok := stx.ResourceBounds["l2_gas"]; ok {
+		tx.Gas = l2.MaxAmount
+		tx.MaxFeePerGas = l2.MaxPricePerUnit
+		tx.MaxPriorityFeePerGas = stx.Tip
+	}
+
+	return sm.publishTransaction(tx)
+}
+
+// starknetTransaction holds the fields read from a Starknet transaction object
+type starknetTransaction struct {
+	Hash                string                            `json:"transaction_hash"`
+	Type                string                            `json:"type"`
+	Version             string                            `json:"version"`
+	SenderAddress       string                            `json:"sender_address"`
+	ContractAddress     string                            `json:"contract_address"`
+	Nonce               string                            `json:"nonce"`
+	Calldata            []string                          `json:"calldata"`
+	ConstructorCalldata []string                          `json:"constructor_calldata"`
+	Tip                 string                            `json:"tip"`
+	ResourceBounds      map[string]starknetResourceBounds `json:"resource_bounds"`
+}
+
+// starknetResourceBounds caps one resource a version 3 transaction may use
+type starknetResourceBounds struct {
+	MaxAmount       string `json:"max_amount"`
+	MaxPricePerUnit string `json:"max_price_per_unit"`
+}
+
+// starknetTxType combines a transaction's type and version, e.g. invoke_v3
+func starknetTxType(txType, version string) string {
+	txType = strings.ToLower(txType)
+	v := strings.TrimLeft(strings.TrimPrefix(strings.ToLower(version), "0x"), "0")
+	if v == "" {
+		v = "0"
+	}
+	return txType + "_v" + v
+}
+
+// starknetCalldata encodes a felt array as hex with each felt in a
+// 32-byte word, the layout EVM calldata consumers expect
+func starknetCalldata(felts []string) string {
+	if len(felts) == 0 {
+		return "0x"
+	}
+	var b strings.Builder
+	b.Grow(2 + 64*len(felts))
+	b.WriteString("0x")
+	for _, felt := range felts {
+		digits := strings.TrimPrefix(strings.ToLower(felt), "0x")
+		if len(digits) < 64 {
+			b.WriteString(strings.Repeat("0", 64-len(digits)))
+		}
+		b.WriteString(digits)
+	}
+	return b.String()
+}
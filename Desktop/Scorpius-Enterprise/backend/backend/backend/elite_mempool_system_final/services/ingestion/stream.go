@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// streamTransport labels gRPC subscribers in the stream metrics
+const streamTransport = "grpc"
+
+// SubscribeRequest is scorpius.ingestion.v1.SubscribeRequest
+type SubscribeRequest struct {
+	Chains []string
+	Filter string
+}
+
+func (r *SubscribeRequest) marshalWire() []byte {
+	var b []byte
+	for _, chain := range r.Chains {
+		b = appendRepeatedString(b, 1, chain)
+	}
+	return appendString(b, 2, r.Filter)
+}
+
+func (r *SubscribeRequest) unmarshalWire(b []byte) error {
+	return decodeFields(b, func(f wireField) error {
+		switch f.num {
+		case 1:
+			r.Chains = append(r.Chains, string(f.bytes))
+		case 2:
+			r.Filter = string(f.bytes)
+		}
+		return nil
+	})
+}
+
+// streamedTransaction sends a Transaction in the protobuf topic format
+type streamedTransaction struct {
+	tx      *Transaction
+	encoded []byte
+}
+
+// marshalWire encodes the transaction once, so its size can be metered
+// after sending
+func (m *streamedTransaction) marshalWire() []byte {
+	if m.encoded == nil {
+		// The protobuf encoder cannot fail
+		m.encoded, _ = protobufEncoder{}.Encode(nil, "", m.tx)
+	}
+	return m.encoded
+}
+
+// unmarshalWire is unused by the server, which only sends transactions
+func (m *streamedTransaction) unmarshalWire(b []byte) error {
+	return fmt.Errorf("decoding transactions is not supported")
+}
+
+// SubscribeTransactions is the TransactionStream gRPC method. Tenants are
+// limited to their chains and rate limit.
+func (is *IngestionService) SubscribeTransactions(req *SubscribeRequest, stream grpc.ServerStream) error {
+	tenant, chains, quotaErr := is.openTenantStream(stream.Context(), streamTransport, req.Chains)
+	if quotaErr != nil {
+		return status.Error(quotaErr.GRPCCode(), quotaErr.Error())
+	}
+	defer tenant.Close()
+	match, err := txMatcher(chains, req.Filter, "stream")
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	sub := is.hub.Subscribe(streamTransport, is.config.Streams.Buffer, match)
+	defer is.hub.Unsubscribe(sub)
+
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-is.ctx.Done():
+			return status.Error(codes.Unavailable, "service is shutting down")
+		case tx := <-sub.C:
+			if !tenant.Allow() {
+				continue
+			}
+			msg := &streamedTransaction{tx: tx}
+			if err := stream.SendMsg(msg); err != nil {
+				return err
+			}
+			tenant.Delivered(len(msg.marshalWire()))
+		}
+	}
+}
+
+// transactionStreamService is the server interface of scorpius.ingestion.v1.TransactionStream
+type transactionStreamService interface {
+	SubscribeTransactions(*SubscribeRequest, grpc.ServerStream) error
+}
+
+var transactionStreamServiceDesc = grpc.ServiceDesc{
+	ServiceName: "scorpius.ingestion.v1.TransactionStream",
+	HandlerType: (*transactionStreamService)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "SubscribeTransactions",
+		ServerStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			req := new(SubscribeRequest)
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			return srv.(transactionStreamService).SubscribeTransactions(req, stream)
+		},
+	}},
+	Metadata: "proto/stream.proto",
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// subscriptionProbeTimeout bounds the wait for each probe's response
+	subscriptionProbeTimeout = 10 * time.Second
+	// subscriptionProbeWait is how long an accepted full body subscription
+	// is watched for its first notification
+	subscriptionProbeWait = 5 * time.Second
+)
+
+var (
+	subscriptionSelected = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_subscription_mode",
+			Help: "Pending transaction subscription of each endpoint, 1 for the mode in use",
+		},
+		[]string{"chain", "endpoint", "mode"},
+	)
+
+	subscriptionProbes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_subscription_probes_total",
+			Help: "Subscription capability probes, by the mode selected or failed",
+		},
+		[]string{"chain", "mode"},
+	)
+)
+
+// subscriptionCandidates are the modes an auto endpoint is probed for,
+// richest first
+var subscriptionCandidates = []string{SubscriptionAlchemy, SubscriptionFull, SubscriptionHashes}
+
+// subscriptionParams returns the eth_subscribe params of a subscription mode
+func subscriptionParams(mode string) []interface{} {
+	switch mode {
+	case SubscriptionAlchemy:
+		return []interface{}{"alchemy_pendingTransactions", map[string]interface{}{"hashesOnly": false}}
+	case SubscriptionHashes:
+		return []interface{}{"newPendingTransactions"}
+	}
+	return []interface{}{"newPendingTransactions", true}
+}
+
+// endpointSubscription returns the subscription mode configured for an
+// endpoint: its <CHAIN>_ENDPOINT_SUBSCRIPTIONS entry, matched on the
+// longest host suffix, or else the chain's <CHAIN>_SUBSCRIPTION_MODE
+func (cm *ChainMonitor) endpointSubscription(endpoint string) string {
+	mode := cm.options.SubscriptionMode
+	_, rawURL := splitEndpointType(endpoint)
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return mode
+	}
+	host := strings.ToLower(u.Hostname())
+
+	matched := 0
+	for suffix, override := range cm.options.EndpointModes {
+		suffix = strings.ToLower(suffix)
+		if len(suffix) > matched && (host == suffix || strings.HasSuffix(host, "."+suffix)) {
+			mode, matched = override, len(suffix)
+		}
+	}
+	return mode
+}
+
+// mayStreamHashes reports whether any endpoint of the chain may be
+// subscribed to hashes only, and so needs the hydrator
+func (cm *ChainMonitor) mayStreamHashes() bool {
+	modes := []string{cm.options.SubscriptionMode}
+	for _, mode := range cm.options.EndpointModes {
+		modes = append(modes, mode)
+	}
+	return containsString(modes, SubscriptionHashes) || containsString(modes, SubscriptionAuto)
+}
+
+// selectSubscription returns the subscription mode to use on an endpoint.
+// Endpoints in auto mode are probed the first time they are connected to;
+// the result is kept for the life of the process.
+func (cm *ChainMonitor) selectSubscription(endpoint, dialURL string, limiter *endpointLimiter) (string, error) {
+	mode := cm.endpointSubscription(endpoint)
+	if mode != SubscriptionAuto {
+		return mode, nil
+	}
+
+	cm.mu.RLock()
+	probed, ok := cm.capabilities[endpoint]
+	cm.mu.RUnlock()
+	if ok {
+		return probed, nil
+	}
+
+	mode, err := cm.probeSubscription(dialURL, limiter)
+	if err != nil {
+		subscriptionProbes.WithLabelValues(cm.chainName, "failed").Inc()
+		return "", fmt.Errorf("failed to probe %s for pending transaction subscriptions: %v", endpoint, err)
+	}
+	subscriptionProbes.WithLabelValues(cm.chainName, mode).Inc()
+	cm.logger.Info("selected pending transaction subscription", "endpoint", displayEndpoint(endpoint), "mode", mode)
+
+	cm.mu.Lock()
+	cm.capabilities[endpoint] = mode
+	cm.mu.Unlock()
+	return mode, nil
+}
+
+// probeSubscription tries each candidate subscription on a connection of
+// its own, richest first, and returns the first the endpoint accepts. An
+// accepted full body subscription is trusted only once a notification
+// carries a body, since some clients accept the flag and still send hashes;
+// a mempool quiet for subscriptionProbeWait is taken at its word.
+func (cm *ChainMonitor) probeSubscription(dialURL string, limiter *endpointLimiter) (string, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(dialURL, nil)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	var rejected []string
+	for i, mode := range subscriptionCandidates {
+		if err := limiter.Wait(cm.ctx); err != nil {
+			return "", err
+		}
+		requestID := fmt.Sprintf("subscription_probe_%d", i)
+		if err := conn.WriteJSON(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      requestID,
+			"method":  "eth_subscribe",
+			"params":  subscriptionParams(mode),
+		}); err != nil {
+			return "", err
+		}
+
+		rpcErr, err := readProbeResponse(conn, requestID)
+		if err != nil {
+			return "", err
+		}
+		if rpcErr != nil {
+			rejected = append(rejected, fmt.Sprintf("%s: %s", mode, rpcErr.Message))
+			continue
+		}
+		if mode == SubscriptionHashes {
+			return mode, nil
+		}
+		return firstNotification(conn, mode)
+	}
+	return "", fmt.Errorf("no subscription accepted (%s)", strings.Join(rejected, "; "))
+}
+
+// readProbeResponse reads until the response to requestID, returning its
+// JSON-RPC error if the request was rejected
+func readProbeResponse(conn *websocket.Conn, requestID string) (*rpcError, error) {
+	conn.SetReadDeadline(time.Now().Add(subscriptionProbeTimeout))
+	for {
+		var msg rpcMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return nil, err
+		}
+		if string(msg.ID) != `"`+requestID+`"` {
+			continue
+		}
+		return msg.Error, nil
+	}
+}
+
+// firstNotification waits for an accepted full body subscription's first
+// notification, returning hashes if it carries one rather than a body
+func firstNotification(conn *websocket.Conn, mode string) (string, error) {
+	conn.SetReadDeadline(time.Now().Add(subscriptionProbeWait))
+	for {
+		var msg rpcMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			if isReadTimeout(err) {
+				return mode, nil
+			}
+			return "", err
+		}
+		if msg.Params == nil || isJSONNull(msg.Params.Result) {
+			continue
+		}
+		if msg.Params.Result[0] == '"' {
+			return SubscriptionHashes, nil
+		}
+		return mode, nil
+	}
+}
+
+// recordSubscription exports the subscription mode in use on an endpoint
+func (cm *ChainMonitor) recordSubscription(endpoint, mode string) {
+	label := endpointLabel(endpoint)
+	for _, candidate := range subscriptionCandidates {
+		value := 0.0
+		if candidate == mode {
+			value = 1
+		}
+		subscriptionSelected.WithLabelValues(cm.chainName, label, candidate).Set(value)
+	}
+}
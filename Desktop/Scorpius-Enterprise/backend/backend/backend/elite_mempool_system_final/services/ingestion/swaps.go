@@ -0,0 +1,617 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var swapIntents = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_swap_intents_total",
+		Help: "Pending swaps decoded from router calldata, by protocol",
+	},
+	[]string{"chain", "protocol"},
+)
+
+// SwapIntent is a pending swap decoded from DEX router or aggregator
+// calldata. Exact-input swaps carry AmountIn and the AmountOutMin slippage
+// bound; exact-output swaps carry AmountOut and AmountInMax. Amounts are
+// decimal token units; Deadline is a Unix timestamp, zero when the method
+// has none.
+type SwapIntent struct {
+	Protocol     string   `json:"protocol"`
+	Method       string   `json:"method"`
+	Router       string   `json:"router"`
+	ExactInput   bool     `json:"exact_input"`
+	TokenIn      string   `json:"token_in,omitempty"`
+	TokenOut     string   `json:"token_out,omitempty"`
+	AmountIn     string   `json:"amount_in,omitempty"`
+	AmountOut    string   `json:"amount_out,omitempty"`
+	AmountOutMin string   `json:"amount_out_min,omitempty"`
+	AmountInMax  string   `json:"amount_in_max,omitempty"`
+	Path         []string `json:"path,omitempty"`
+	Pools        []string `json:"pools,omitempty"`
+	Recipient    string   `json:"recipient,omitempty"`
+	Deadline     int64    `json:"deadline,omitempty"`
+
+	// fees are the Uniswap V3 fee tiers between consecutive path tokens
+	fees []uint32
+}
+
+// swapDecoder decodes the arguments of one router method. value is the
+// native amount sent with the call.
+type swapDecoder struct {
+	protocol string
+	method   string
+	decode   func(args abiData, value *big.Int) *SwapIntent
+}
+
+var swapDecoders = map[string]swapDecoder{
+	// Uniswap V2 Router02 and forks
+	"38ed1739": {protocol: "uniswap_v2", method: "swapExactTokensForTokens", decode: v2Swap(true, 0, 1, 2, 3, 4)},
+	"5c11d795": {protocol: "uniswap_v2", method: "swapExactTokensForTokensSupportingFeeOnTransferTokens", decode: v2Swap(true, 0, 1, 2, 3, 4)},
+	"18cbafe5": {protocol: "uniswap_v2", method: "swapExactTokensForETH", decode: v2Swap(true, 0, 1, 2, 3, 4)},
+	"791ac947": {protocol: "uniswap_v2", method: "swapExactTokensForETHSupportingFeeOnTransferTokens", decode: v2Swap(true, 0, 1, 2, 3, 4)},
+	"7ff36ab5": {protocol: "uniswap_v2", method: "swapExactETHForTokens", decode: v2Swap(true, -1, 0, 1, 2, 3)},
+	"b6f9de95": {protocol: "uniswap_v2", method: "swapExactETHForTokensSupportingFeeOnTransferTokens", decode: v2Swap(true, -1, 0, 1, 2, 3)},
+	"8803dbee": {protocol: "uniswap_v2", method: "swapTokensForExactTokens", decode: v2Swap(false, 0, 1, 2, 3, 4)},
+	"4a25d94a": {protocol: "uniswap_v2", method: "swapTokensForExactETH", decode: v2Swap(false, 0, 1, 2, 3, 4)},
+	"fb3bdb41": {protocol: "uniswap_v2", method: "swapETHForExactTokens", decode: v2Swap(false, 0, -1, 1, 2, 3)},
+
+	// Uniswap V3 SwapRouter
+	"414bf389": {protocol: "uniswap_v3", method: "exactInputSingle", decode: v3SingleSwap(true, true)},
+	"db3e2198": {protocol: "uniswap_v3", method: "exactOutputSingle", decode: v3SingleSwap(false, true)},
+	"c04b8d59": {protocol: "uniswap_v3", method: "exactInput", decode: v3PathSwap(true, true)},
+	"f28c0498": {protocol: "uniswap_v3", method: "exactOutput", decode: v3PathSwap(false, true)},
+
+	// Uniswap SwapRouter02, which moved deadlines to multicall
+	"04e45aaf": {protocol: "uniswap_v3", method: "exactInputSingle", decode: v3SingleSwap(true, false)},
+	"5023b4df": {protocol: "uniswap_v3", method: "exactOutputSingle", decode: v3SingleSwap(false, false)},
+	"b858183f": {protocol: "uniswap_v3", method: "exactInput", decode: v3PathSwap(true, false)},
+	"09b81346": {protocol: "uniswap_v3", method: "exactOutput", decode: v3PathSwap(false, false)},
+	"472b43f3": {protocol: "uniswap_v2", method: "swapExactTokensForTokens", decode: v2Swap(true, 0, 1, 2, 3, -1)},
+	"42712a67": {protocol: "uniswap_v2", method: "swapTokensForExactTokens", decode: v2Swap(false, 0, 1, 2, 3, -1)},
+
+	// Uniswap Universal Router
+	"3593564c": {protocol: "uniswap_universal", method: "execute", decode: universalRouterSwap(true)},
+	"24856bc3": {protocol: "uniswap_universal", method: "execute", decode: universalRouterSwap(false)},
+
+	// 1inch AggregationRouter V5 and V6
+	"12aa3caf": {protocol: "1inch", method: "swap", decode: oneInchSwap},
+	"07ed2379": {protocol: "1inch", method: "swap", decode: oneInchSwap},
+	"0502b1c5": {protocol: "1inch", method: "unoswap", decode: oneInchUnoswap},
+	"e449022e": {protocol: "1inch", method: "uniswapV3Swap", decode: oneInchUniswapV3Swap},
+
+	// 0x Exchange Proxy
+	"415565b0": {protocol: "0x", method: "transformERC20", decode: zeroExTransformERC20},
+	"d9627aa4": {protocol: "0x", method: "sellToUniswap", decode: zeroExSellToUniswap},
+	"6af479b2": {protocol: "0x", method: "sellTokenForTokenToUniswapV3", decode: zeroExSellToUniswapV3},
+}
+
+// Multicall selectors of the Uniswap routers; the first swap among the calls is decoded
+var multicallSelectors = map[string]struct {
+	callsArg    int
+	deadlineArg int
+}{
+	"ac9650d8": {callsArg: 0, deadlineArg: -1}, // multicall(bytes[])
+	"5ae401dc": {callsArg: 1, deadlineArg: 0},  // multicall(uint256,bytes[])
+	"1f0464d1": {callsArg: 1, deadlineArg: -1}, // multicall(bytes32,bytes[])
+}
+
+// Universal Router commands that swap; the low 6 bits of a command byte select it
+const (
+	urV3SwapExactIn  = 0x00
+	urV3SwapExactOut = 0x01
+	urV2SwapExactIn  = 0x08
+	urV2SwapExactOut = 0x09
+	urCommandMask    = 0x3f
+)
+
+// Uniswap factories on Ethereum mainnet, from which pool addresses are
+// derived for swaps through the Uniswap routers
+var (
+	uniswapV2Factory  = common.HexToAddress("0x5c69bee701ef814a2b6a3edd4b1652cb9cc5aa6f")
+	uniswapV2InitHash = common.FromHex("0x96e8ac4277198ff8b6f785478aa9a39f403cb768dd02cbee326c3e7da348845f")
+	uniswapV3Factory  = common.HexToAddress("0x1f98431c8ad98523631ae4a59f267346ea31f984")
+	uniswapV3InitHash = common.FromHex("0xe34f199b19b2b4f47f68442619d555527d244f78a3297ea89325f843f87b8b54")
+)
+
+// uniswapRouters are the mainnet routers whose pools come from the Uniswap factories
+var uniswapRouters = map[string]bool{
+	"0x7a250d5630b4cf539739df2c5dacb4c659f2488d": true,
+	"0xe592427a0aece92de3edee1f18e0157c05861564": true,
+	"0x68b3465833fb72a70ecdf485e0e4c7bd8665fc45": true,
+	"0x3fc91a3afd70395cd496c647d5a6cc9d4b2b7fad": true,
+	"0xef1c6e67703c7bd7107eed8303fbe6ec2554bf6b": true,
+}
+
+// SwapDecoder attaches a SwapIntent to pending calls of the DEX routers and
+// aggregators in swapDecoders, recognised by selector so forks deployed at
+// other addresses are decoded too
+type SwapDecoder struct{}
+
+// Name returns the enricher name
+func (d *SwapDecoder) Name() string {
+	return "swaps"
+}
+
+// Enrich decodes swap calldata into tx.Swap
+func (d *SwapDecoder) Enrich(tx *Transaction) {
+	if tx.ChainFamily != FamilyEVM || tx.To == "" || tx.Canary {
+		return
+	}
+	data := common.FromHex(tx.Data)
+	if len(data) < 4 {
+		return
+	}
+
+	intent := decodeSwap(data, hexToBig(tx.Value))
+	if intent == nil {
+		return
+	}
+	intent.Router = strings.ToLower(tx.To)
+	if tx.ChainID == 1 && uniswapRouters[intent.Router] {
+		intent.Pools = uniswapPools(intent)
+	}
+
+	swapIntents.WithLabelValues(tx.Chain, intent.Protocol).Inc()
+	tx.Swap = intent
+}
+
+// decodeSwap decodes a router call, looking into multicalls for the first swap
+func decodeSwap(data []byte, value *big.Int) *SwapIntent {
+	selector := hex.EncodeToString(data[:4])
+	args := abiData(data[4:])
+
+	if multicall, ok := multicallSelectors[selector]; ok {
+		for _, call := range args.bytesArray(multicall.callsArg) {
+			if len(call) < 4 {
+				continue
+			}
+			if _, nested := multicallSelectors[hex.EncodeToString(call[:4])]; nested {
+				continue
+			}
+			if intent := decodeSwap(call, value); intent != nil {
+				if multicall.deadlineArg >= 0 {
+					intent.Deadline = args.int64(multicall.deadlineArg)
+				}
+				return intent
+			}
+		}
+		return nil
+	}
+
+	decoder, ok := swapDecoders[selector]
+	if !ok {
+		return nil
+	}
+	intent := decoder.decode(args, value)
+	if intent == nil {
+		return nil
+	}
+	intent.Protocol, intent.Method = decoder.protocol, decoder.method
+	if len(intent.Path) > 0 {
+		intent.TokenIn, intent.TokenOut = intent.Path[0], intent.Path[len(intent.Path)-1]
+	}
+	return intent
+}
+
+// newSwapIntent records a swap's amount and slippage bound: the input and
+// minimum output of exact-input swaps, the output and maximum input of
+// exact-output swaps
+func newSwapIntent(exactInput bool, amount, limit *big.Int) *SwapIntent {
+	intent := &SwapIntent{ExactInput: exactInput}
+	if exactInput {
+		intent.AmountIn, intent.AmountOutMin = decimalAmount(amount), decimalAmount(limit)
+	} else {
+		intent.AmountOut, intent.AmountInMax = decimalAmount(amount), decimalAmount(limit)
+	}
+	return intent
+}
+
+// v2Swap decodes the Uniswap V2 router methods from the positions of their
+// arguments; -1 for the amount or limit means the native value sent, and
+// -1 for the deadline that the method has none
+func v2Swap(exactInput bool, amountArg, limitArg, pathArg, recipientArg, deadlineArg int) func(abiData, *big.Int) *SwapIntent {
+	return func(args abiData, value *big.Int) *SwapIntent {
+		argOrValue := func(i int) *big.Int {
+			if i < 0 {
+				return value
+			}
+			return args.uint(i)
+		}
+		path := args.addresses(pathArg)
+		if len(path) < 2 {
+			return nil
+		}
+		intent := newSwapIntent(exactInput, argOrValue(amountArg), argOrValue(limitArg))
+		intent.Path = path
+		intent.Recipient = args.address(recipientArg)
+		if deadlineArg >= 0 {
+			intent.Deadline = args.int64(deadlineArg)
+		}
+		return intent
+	}
+}
+
+// v3SingleSwap decodes exactInputSingle and exactOutputSingle, whose
+// parameters are a static struct: tokenIn, tokenOut, fee, recipient, an
+// optional deadline, the amount and its limit
+func v3SingleSwap(exactInput, hasDeadline bool) func(abiData, *big.Int) *SwapIntent {
+	return func(args abiData, _ *big.Int) *SwapIntent {
+		next := 4
+		var deadline int64
+		if hasDeadline {
+			deadline = args.int64(next)
+			next++
+		}
+		if args.word(next+1) == nil {
+			return nil
+		}
+		intent := newSwapIntent(exactInput, args.uint(next), args.uint(next+1))
+		intent.Path = []string{args.address(0), args.address(1)}
+		intent.fees = []uint32{uint32(args.int64(2))}
+		intent.Recipient = args.address(3)
+		intent.Deadline = deadline
+		return intent
+	}
+}
+
+// v3PathSwap decodes exactInput and exactOutput, whose parameters are a
+// struct of the encoded path, recipient, an optional deadline, the amount
+// and its limit. Exact-output paths run from the output token back.
+func v3PathSwap(exactInput, hasDeadline bool) func(abiData, *big.Int) *SwapIntent {
+	return func(args abiData, _ *big.Int) *SwapIntent {
+		params := args.tuple(0)
+		path, fees := decodeV3Path(params.bytes(0))
+		if path == nil {
+			return nil
+		}
+		next := 2
+		var deadline int64
+		if hasDeadline {
+			deadline = params.int64(next)
+			next++
+		}
+		intent := newSwapIntent(exactInput, params.uint(next), params.uint(next+1))
+		if !exactInput {
+			reverseStrings(path)
+			reverseFees(fees)
+		}
+		intent.Path, intent.fees = path, fees
+		intent.Recipient = params.address(1)
+		intent.Deadline = deadline
+		return intent
+	}
+}
+
+// universalRouterSwap decodes the first swap command of a Universal Router
+// execute(bytes commands, bytes[] inputs[, uint256 deadline])
+func universalRouterSwap(hasDeadline bool) func(abiData, *big.Int) *SwapIntent {
+	return func(args abiData, _ *big.Int) *SwapIntent {
+		commands := args.bytes(0)
+		inputs := args.bytesArray(1)
+		for i, command := range commands {
+			if i >= len(inputs) {
+				break
+			}
+			input := abiData(inputs[i])
+
+			var intent *SwapIntent
+			switch command & urCommandMask {
+			case urV3SwapExactIn, urV3SwapExactOut:
+				exactInput := command&urCommandMask == urV3SwapExactIn
+				path, fees := decodeV3Path(input.bytes(3))
+				if path == nil {
+					continue
+				}
+				if !exactInput {
+					reverseStrings(path)
+					reverseFees(fees)
+				}
+				intent = newSwapIntent(exactInput, input.uint(1), input.uint(2))
+				intent.Path, intent.fees = path, fees
+			case urV2SwapExactIn, urV2SwapExactOut:
+				path := input.addresses(3)
+				if len(path) < 2 {
+					continue
+				}
+				intent = newSwapIntent(command&urCommandMask == urV2SwapExactIn, input.uint(1), input.uint(2))
+				intent.Path = path
+			default:
+				continue
+			}
+			intent.Recipient = input.address(0)
+			if hasDeadline {
+				intent.Deadline = args.int64(2)
+			}
+			return intent
+		}
+		return nil
+	}
+}
+
+// oneInchSwap decodes swap(executor, desc, ...), where desc is the static
+// struct (srcToken, dstToken, srcReceiver, dstReceiver, amount,
+// minReturnAmount, flags)
+func oneInchSwap(args abiData, _ *big.Int) *SwapIntent {
+	if args.word(7) == nil {
+		return nil
+	}
+	intent := newSwapIntent(true, args.uint(5), args.uint(6))
+	intent.Path = []string{args.address(1), args.address(2)}
+	intent.Recipient = args.address(4)
+	return intent
+}
+
+// oneInchUnoswap decodes unoswap(srcToken, amount, minReturn, pools). The
+// output token is only known to the pools.
+func oneInchUnoswap(args abiData, _ *big.Int) *SwapIntent {
+	if args.word(3) == nil {
+		return nil
+	}
+	intent := newSwapIntent(true, args.uint(1), args.uint(2))
+	intent.TokenIn = args.address(0)
+	intent.Pools = packedPools(args.uints(3))
+	return intent
+}
+
+// oneInchUniswapV3Swap decodes uniswapV3Swap(amount, minReturn, pools)
+func oneInchUniswapV3Swap(args abiData, _ *big.Int) *SwapIntent {
+	if args.word(2) == nil {
+		return nil
+	}
+	intent := newSwapIntent(true, args.uint(0), args.uint(1))
+	intent.Pools = packedPools(args.uints(2))
+	return intent
+}
+
+// zeroExTransformERC20 decodes transformERC20(inputToken, outputToken,
+// inputTokenAmount, minOutputTokenAmount, transformations)
+func zeroExTransformERC20(args abiData, _ *big.Int) *SwapIntent {
+	if args.word(3) == nil {
+		return nil
+	}
+	intent := newSwapIntent(true, args.uint(2), args.uint(3))
+	intent.Path = []string{args.address(0), args.address(1)}
+	return intent
+}
+
+// zeroExSellToUniswap decodes sellToUniswap(tokens, sellAmount, minBuyAmount, isSushi)
+func zeroExSellToUniswap(args abiData, _ *big.Int) *SwapIntent {
+	path := args.addresses(0)
+	if len(path) < 2 {
+		return nil
+	}
+	intent := newSwapIntent(true, args.uint(1), args.uint(2))
+	intent.Path = path
+	return intent
+}
+
+// zeroExSellToUniswapV3 decodes sellTokenForTokenToUniswapV3(encodedPath,
+// sellAmount, minBuyAmount, recipient)
+func zeroExSellToUniswapV3(args abiData, _ *big.Int) *SwapIntent {
+	path, fees := decodeV3Path(args.bytes(0))
+	if path == nil {
+		return nil
+	}
+	intent := newSwapIntent(true, args.uint(1), args.uint(2))
+	intent.Path, intent.fees = path, fees
+	intent.Recipient = args.address(3)
+	return intent
+}
+
+// decodeV3Path splits a Uniswap V3 path (token, then fee and token for
+// each hop) into its tokens and fee tiers
+func decodeV3Path(path []byte) ([]string, []uint32) {
+	if len(path) < 2*common.AddressLength+3 || (len(path)-common.AddressLength)%23 != 0 {
+		return nil, nil
+	}
+	tokens := []string{strings.ToLower(common.BytesToAddress(path[:common.AddressLength]).Hex())}
+	var fees []uint32
+	for rest := path[common.AddressLength:]; len(rest) > 0; rest = rest[23:] {
+		fees = append(fees, uint32(rest[0])<<16|uint32(rest[1])<<8|uint32(rest[2]))
+		tokens = append(tokens, strings.ToLower(common.BytesToAddress(rest[3:23]).Hex()))
+	}
+	return tokens, fees
+}
+
+// packedPools extracts the pool addresses 1inch packs into the low 160
+// bits of each word, above which it keeps direction and unwrap flags
+func packedPools(words []*big.Int) []string {
+	pools := make([]string, len(words))
+	for i, word := range words {
+		pools[i] = strings.ToLower(common.BytesToAddress(word.Bytes()).Hex())
+	}
+	return pools
+}
+
+// uniswapPools derives the pools a Uniswap swap trades through, with the
+// CREATE2 addresses of the mainnet factories
+func uniswapPools(intent *SwapIntent) []string {
+	var pools []string
+	for i := 0; i+1 < len(intent.Path); i++ {
+		token0, token1 := common.HexToAddress(intent.Path[i]), common.HexToAddress(intent.Path[i+1])
+		if bytes.Compare(token0.Bytes(), token1.Bytes()) > 0 {
+			token0, token1 = token1, token0
+		}
+
+		var pool common.Address
+		if intent.fees != nil {
+			if i >= len(intent.fees) {
+				break
+			}
+			fee := make([]byte, 32)
+			binary.BigEndian.PutUint32(fee[28:], intent.fees[i])
+			salt := crypto.Keccak256Hash(common.LeftPadBytes(token0.Bytes(), 32), common.LeftPadBytes(token1.Bytes(), 32), fee)
+			pool = crypto.CreateAddress2(uniswapV3Factory, salt, uniswapV3InitHash)
+		} else {
+			salt := crypto.Keccak256Hash(token0.Bytes(), token1.Bytes())
+			pool = crypto.CreateAddress2(uniswapV2Factory, salt, uniswapV2InitHash)
+		}
+		pools = append(pools, strings.ToLower(pool.Hex()))
+	}
+	return pools
+}
+
+// decimalAmount formats an amount in decimal, or empty when unknown
+func decimalAmount(n *big.Int) string {
+	if n == nil {
+		return ""
+	}
+	return n.String()
+}
+
+func reverseStrings(values []string) {
+	for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+		values[i], values[j] = values[j], values[i]
+	}
+}
+
+func reverseFees(values []uint32) {
+	for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+		values[i], values[j] = values[j], values[i]
+	}
+}
+
+// abiData is ABI-encoded call arguments. Accessors return zero values for
+// out-of-range or malformed data, so decoders check the last word they need.
+type abiData []byte
+
+// word returns the i-th 32-byte head word, or nil past the end
+func (d abiData) word(i int) []byte {
+	if i < 0 || (i+1)*32 > len(d) {
+		return nil
+	}
+	return d[i*32 : (i+1)*32]
+}
+
+func (d abiData) uint(i int) *big.Int {
+	w := d.word(i)
+	if w == nil {
+		return nil
+	}
+	return new(big.Int).SetBytes(w)
+}
+
+func (d abiData) int64(i int) int64 {
+	n := d.uint(i)
+	if n == nil || !n.IsInt64() {
+		return 0
+	}
+	return n.Int64()
+}
+
+func (d abiData) address(i int) string {
+	w := d.word(i)
+	if w == nil {
+		return ""
+	}
+	return strings.ToLower(common.BytesToAddress(w[12:]).Hex())
+}
+
+// tuple returns the encoding of the dynamic value whose offset is the i-th word
+func (d abiData) tuple(i int) abiData {
+	offset := d.uint(i)
+	if offset == nil || !offset.IsInt64() || offset.Int64() > int64(len(d)) {
+		return nil
+	}
+	return d[offset.Int64():]
+}
+
+// length reads the length word of a dynamic value, bounded by the data after it
+func (d abiData) length(elemSize int) (int, bool) {
+	n := d.uint(0)
+	if n == nil || !n.IsInt64() || n.Int64() > int64((len(d)-32)/elemSize) {
+		return 0, false
+	}
+	return int(n.Int64()), true
+}
+
+func (d abiData) bytes(i int) []byte {
+	value := d.tuple(i)
+	n, ok := value.length(1)
+	if !ok {
+		return nil
+	}
+	return value[32 : 32+n]
+}
+
+func (d abiData) addresses(i int) []string {
+	array := d.tuple(i)
+	n, ok := array.length(32)
+	if !ok {
+		return nil
+	}
+	addresses := make([]string, n)
+	for j := range addresses {
+		addresses[j] = array.address(j + 1)
+	}
+	return addresses
+}
+
+func (d abiData) uints(i int) []*big.Int {
+	array := d.tuple(i)
+	n, ok := array.length(32)
+	if !ok {
+		return nil
+	}
+	values := make([]*big.Int, n)
+	for j := range values {
+		values[j] = array.uint(j + 1)
+	}
+	return values
+}
+
+// bytesArray decodes a bytes[]; element offsets are relative to the data after the length
+func (d abiData) bytesArray(i int) [][]byte {
+	array := d.tuple(i)
+	n, ok := array.length(32)
+	if !ok {
+		return nil
+	}
+	elems := array[32:]
+	values := make([][]byte, n)
+	for j := range values {
+		values[j] = elems.bytes(j)
+	}
+	return values
+}
+
+// tuples decodes an array of dynamic tuples, each relative to the data after the length
+func (d abiData) tuples(i int) []abiData {
+	array := d.tuple(i)
+	n, ok := array.length(32)
+	if !ok {
+		return nil
+	}
+	elems := array[32:]
+	values := make([]abiData, n)
+	for j := range values {
+		values[j] = elems.tuple(j)
+	}
+	return values
+}
+
+// staticTuples decodes an array of static tuples of the given number of words
+func (d abiData) staticTuples(i, words int) []abiData {
+	array := d.tuple(i)
+	n, ok := array.length(32 * words)
+	if !ok {
+		return nil
+	}
+	values := make([]abiData, n)
+	for j := range values {
+		start := 32 + j*32*words
+		values[j] = array[start : start+32*words]
+	}
+	return values
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// encodeWord encodes an int or an address as one 32-byte word
+func encodeWord(v interface{}) []byte {
+	switch v := v.(type) {
+	case int:
+		return common.LeftPadBytes(big.NewInt(int64(v)).Bytes(), 32)
+	case string:
+		return common.LeftPadBytes(common.HexToAddress(v).Bytes(), 32)
+	default:
+		panic("unsupported abi word")
+	}
+}
+
+// abiBytes encodes the tail of a dynamic bytes value: its length and the data padded to words
+func abiBytes(data []byte) []byte {
+	padded := make([]byte, (len(data)+31)/32*32)
+	copy(padded, data)
+	return append(encodeWord(len(data)), padded...)
+}
+
+// abiCall joins a selector and encoded arguments into calldata
+func abiCall(selector string, parts ...[]byte) []byte {
+	data, _ := hex.DecodeString(selector)
+	for _, part := range parts {
+		data = append(data, part...)
+	}
+	return data
+}
+
+// abiWords encodes each value with encodeWord
+func abiWords(values ...interface{}) []byte {
+	var data []byte
+	for _, v := range values {
+		data = append(data, encodeWord(v)...)
+	}
+	return data
+}
+
+// v3Path encodes a Uniswap V3 path of tokens and the fees between them
+func v3Path(tokens []string, fees []int) []byte {
+	path := common.HexToAddress(tokens[0]).Bytes()
+	for i, fee := range fees {
+		path = append(path, byte(fee>>16), byte(fee>>8), byte(fee))
+		path = append(path, common.HexToAddress(tokens[i+1]).Bytes()...)
+	}
+	return path
+}
+
+const (
+	weth      = "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"
+	usdc      = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
+	dai       = "0x6b175474e89094c44da98b954eedeac495271d0f"
+	recipient = "0xd8da6bf26964af9d7eed9e03e53415d37aa96045"
+)
+
+func TestDecodeSwap(t *testing.T) {
+	exactInputSingle := abiWords(weth, usdc, 500, recipient, 1700000000, 1000, 990, 0)
+	exactOutputPath := v3Path([]string{usdc, dai, weth}, []int{100, 3000})
+
+	tests := []struct {
+		name  string
+		data  []byte
+		value *big.Int
+		want  *SwapIntent
+	}{
+		{
+			name: "v2 exact tokens for tokens",
+			data: abiCall("38ed1739",
+				abiWords(1000, 990, 0xa0, recipient, 1700000000),
+				abiWords(2, weth, usdc)),
+			want: &SwapIntent{
+				Protocol: "uniswap_v2", Method: "swapExactTokensForTokens", ExactInput: true,
+				TokenIn: weth, TokenOut: usdc, AmountIn: "1000", AmountOutMin: "990",
+				Path: []string{weth, usdc}, Recipient: recipient, Deadline: 1700000000,
+			},
+		},
+		{
+			name: "v2 exact native for tokens takes the amount from the value",
+			data: abiCall("7ff36ab5",
+				abiWords(990, 0x80, recipient, 1700000000),
+				abiWords(2, weth, dai)),
+			value: big.NewInt(5000),
+			want: &SwapIntent{
+				Protocol: "uniswap_v2", Method: "swapExactETHForTokens", ExactInput: true,
+				TokenIn: weth, TokenOut: dai, AmountIn: "5000", AmountOutMin: "990",
+				Path: []string{weth, dai}, Recipient: recipient, Deadline: 1700000000,
+			},
+		},
+		{
+			name: "v3 exact input single",
+			data: abiCall("414bf389", exactInputSingle),
+			want: &SwapIntent{
+				Protocol: "uniswap_v3", Method: "exactInputSingle", ExactInput: true,
+				TokenIn: weth, TokenOut: usdc, AmountIn: "1000", AmountOutMin: "990",
+				Path: []string{weth, usdc}, Recipient: recipient, Deadline: 1700000000,
+				fees: []uint32{500},
+			},
+		},
+		{
+			name: "v3 exact output path runs from the input token",
+			data: abiCall("f28c0498",
+				abiWords(0x20),
+				abiWords(0xa0, recipient, 1700000000, 2000, 2100),
+				abiBytes(exactOutputPath)),
+			want: &SwapIntent{
+				Protocol: "uniswap_v3", Method: "exactOutput", ExactInput: false,
+				TokenIn: weth, TokenOut: usdc, AmountOut: "2000", AmountInMax: "2100",
+				Path: []string{weth, dai, usdc}, Recipient: recipient, Deadline: 1700000000,
+				fees: []uint32{3000, 100},
+			},
+		},
+		{
+			name: "multicall with deadline decodes the first swap",
+			data: abiCall("5ae401dc",
+				abiWords(1700000000, 0x40),
+				abiWords(1, 0x20),
+				abiBytes(abiCall("04e45aaf", abiWords(weth, usdc, 500, recipient, 1000, 990, 0)))),
+			want: &SwapIntent{
+				Protocol: "uniswap_v3", Method: "exactInputSingle", ExactInput: true,
+				TokenIn: weth, TokenOut: usdc, AmountIn: "1000", AmountOutMin: "990",
+				Path: []string{weth, usdc}, Recipient: recipient, Deadline: 1700000000,
+				fees: []uint32{500},
+			},
+		},
+		{
+			name: "truncated arguments",
+			data: abiCall("414bf389", exactInputSingle[:5*32]),
+		},
+		{
+			name: "path offset past the end",
+			data: abiCall("38ed1739", abiWords(1000, 990, 0x1000, recipient, 1700000000)),
+		},
+		{
+			name: "path length past the end",
+			data: abiCall("38ed1739", abiWords(1000, 990, 0xa0, recipient, 1700000000, 1<<20, weth)),
+		},
+		{
+			name: "unknown selector",
+			data: abiCall("a9059cbb", abiWords(recipient, 1000)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := decodeSwap(tt.data, tt.value)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeSwap() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeV3Path(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   []byte
+		tokens []string
+		fees   []uint32
+	}{
+		{
+			name:   "single hop",
+			path:   v3Path([]string{weth, usdc}, []int{500}),
+			tokens: []string{weth, usdc},
+			fees:   []uint32{500},
+		},
+		{
+			name:   "two hops",
+			path:   v3Path([]string{usdc, weth, dai}, []int{3000, 10000}),
+			tokens: []string{usdc, weth, dai},
+			fees:   []uint32{3000, 10000},
+		},
+		{
+			name: "token only",
+			path: common.HexToAddress(weth).Bytes(),
+		},
+		{
+			name: "partial hop",
+			path: v3Path([]string{weth, usdc}, []int{500})[:40],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, fees := decodeV3Path(tt.path)
+			if !reflect.DeepEqual(tokens, tt.tokens) || !reflect.DeepEqual(fees, tt.fees) {
+				t.Errorf("decodeV3Path() = %v, %v, want %v, %v", tokens, fees, tt.tokens, tt.fees)
+			}
+		})
+	}
+}
+
+func TestUniswapPools(t *testing.T) {
+	// The mainnet WETH/USDC pools of the V2 factory and the V3 factory's 0.05% tier
+	tests := []struct {
+		name   string
+		intent *SwapIntent
+		want   string
+	}{
+		{"v2", &SwapIntent{Path: []string{weth, usdc}}, "0xb4e16d0168e52d35cacd2c6185b44281ec28c9dc"},
+		{"v3", &SwapIntent{Path: []string{usdc, weth}, fees: []uint32{500}}, "0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pools := uniswapPools(tt.intent)
+			if len(pools) != 1 || !strings.EqualFold(pools[0], tt.want) {
+				t.Errorf("uniswapPools() = %v, want [%s]", pools, tt.want)
+			}
+		})
+	}
+}
+
+func TestABIDataBounds(t *testing.T) {
+	// A length word claiming more elements than follow must not be trusted
+	data := abiData(abiWords(0x20, 1<<40))
+	if got := data.addresses(0); got != nil {
+		t.Errorf("addresses() = %v, want nil", got)
+	}
+	if got := data.bytes(0); got != nil {
+		t.Errorf("bytes() = %v, want nil", got)
+	}
+	if got := data.bytesArray(0); got != nil {
+		t.Errorf("bytesArray() = %v, want nil", got)
+	}
+	if got := data.word(2); got != nil {
+		t.Errorf("word() = %x, want nil", got)
+	}
+	if got := abiData(bytes.Repeat([]byte{0xff}, 32)).int64(0); got != 0 {
+		t.Errorf("int64() = %d, want 0 for a value out of range", got)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TxTag is a downstream verdict attached to a transaction
+type TxTag struct {
+	Tag       string    `json:"tag"`
+	Source    string    `json:"source,omitempty"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TagStore persists transaction tags in Redis and joins them onto published transactions.
+//
+// Tags live in a hash per transaction (tags:<chain>:<hash>). A sorted set per
+// chain (tags:index:<chain>, scored by expiry) lists tagged hashes so every
+// instance can keep a local copy and avoid a Redis round-trip per transaction.
+type TagStore struct {
+	redis  *redis.Client
+	ttl    time.Duration
+	mu     sync.RWMutex
+	tagged map[string]map[string]bool
+}
+
+// NewTagStore creates a tag store whose tags expire after ttl
+func NewTagStore(redisClient *redis.Client, ttl time.Duration) *TagStore {
+	return &TagStore{
+		redis:  redisClient,
+		ttl:    ttl,
+		tagged: make(map[string]map[string]bool),
+	}
+}
+
+func tagKey(chain, hash string) string {
+	return fmt.Sprintf("tags:%s:%s", chain, strings.ToLower(hash))
+}
+
+func tagIndexKey(chain string) string {
+	return "tags:index:" + chain
+}
+
+// Add attaches a tag to a transaction
+func (s *TagStore) Add(ctx context.Context, chain, hash string, tag TxTag) error {
+	if tag.CreatedAt.IsZero() {
+		tag.CreatedAt = time.Now()
+	}
+	data, err := json.Marshal(tag)
+	if err != nil {
+		return err
+	}
+
+	hash = strings.ToLower(hash)
+	expiry := time.Now().Add(s.ttl)
+
+	pipe := s.redis.TxPipeline()
+	pipe.HSet(ctx, tagKey(chain, hash), tag.Tag, data)
+	pipe.Expire(ctx, tagKey(chain, hash), s.ttl)
+	pipe.ZAdd(ctx, tagIndexKey(chain), redis.Z{Score: float64(expiry.Unix()), Member: hash})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	s.markTagged(chain, hash)
+	return nil
+}
+
+// Remove deletes a single tag from a transaction
+func (s *TagStore) Remove(ctx context.Context, chain, hash, tag string) error {
+	return s.redis.HDel(ctx, tagKey(chain, hash), tag).Err()
+}
+
+// Get returns all tags attached to a transaction
+func (s *TagStore) Get(ctx context.Context, chain, hash string) ([]TxTag, error) {
+	values, err := s.redis.HGetAll(ctx, tagKey(chain, hash)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]TxTag, 0, len(values))
+	for _, value := range values {
+		var tag TxTag
+		if err := json.Unmarshal([]byte(value), &tag); err != nil {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func (s *TagStore) markTagged(chain, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tagged[chain] == nil {
+		s.tagged[chain] = make(map[string]bool)
+	}
+	s.tagged[chain][hash] = true
+}
+
+func (s *TagStore) isTagged(chain, hash string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tagged[chain][strings.ToLower(hash)]
+}
+
+// Run refreshes the local index of tagged hashes until ctx is cancelled
+func (s *TagStore) Run(ctx context.Context, chains func() []string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.refresh(ctx, chains())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh prunes expired index entries and reloads the tagged hash set for each chain
+func (s *TagStore) refresh(ctx context.Context, chains []string) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	for _, chain := range chains {
+		index := tagIndexKey(chain)
+		if err := s.redis.ZRemRangeByScore(ctx, index, "-inf", now).Err(); err != nil {
+			slog.Warn("failed to prune tag index", "chain", chain, "error", err)
+		}
+
+		hashes, err := s.redis.ZRangeByScore(ctx, index, &redis.ZRangeBy{Min: now, Max: "+inf"}).Result()
+		if err != nil {
+			slog.Warn("failed to load tag index", "chain", chain, "error", err)
+			continue
+		}
+
+		tagged := make(map[string]bool, len(hashes))
+		for _, hash := range hashes {
+			tagged[hash] = true
+		}
+
+		s.mu.Lock()
+		s.tagged[chain] = tagged
+		s.mu.Unlock()
+	}
+}
+
+// Name returns the enricher name
+func (s *TagStore) Name() string {
+	return "tags"
+}
+
+// Enrich joins stored tags onto transactions that have been tagged
+func (s *TagStore) Enrich(tx *Transaction) {
+	if !s.isTagged(tx.Chain, tx.Hash) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	tags, err := s.Get(ctx, tx.Chain, tx.Hash)
+	if err != nil {
+		slog.Warn("failed to load tags", "chain", tx.Chain, "tx_hash", tx.Hash, "error", err)
+		return
+	}
+	tx.Tags = tags
+}
+
+// tagRequest is the body accepted by the tagging API
+type tagRequest struct {
+	Chain  string `json:"chain"`
+	Hash   string `json:"hash"`
+	Tag    string `json:"tag"`
+	Source string `json:"source"`
+	Note   string `json:"note"`
+}
+
+// registerTagAPI mounts the transaction tagging endpoints
+func (is *IngestionService) registerTagAPI(mux *http.ServeMux) {
+	mux.HandleFunc("/api/tags", func(w http.ResponseWriter, r *http.Request) {
+		if is.tags == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "tags need the redis cache backend"})
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			chain, hash := r.URL.Query().Get("chain"), r.URL.Query().Get("hash")
+			if chain == "" || hash == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "chain and hash are required"})
+				return
+			}
+			tags, err := is.tags.Get(r.Context(), chain, hash)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"chain": chain, "hash": hash, "tags": tags})
+
+		case http.MethodPost:
+			var req tagRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+				return
+			}
+			if req.Chain == "" || req.Hash == "" || req.Tag == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "chain, hash and tag are required"})
+				return
+			}
+			tag := TxTag{Tag: req.Tag, Source: req.Source, Note: req.Note}
+			if err := is.tags.Add(r.Context(), req.Chain, req.Hash, tag); err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusCreated, map[string]string{"status": "tagged"})
+
+		case http.MethodDelete:
+			query := r.URL.Query()
+			if query.Get("chain") == "" || query.Get("hash") == "" || query.Get("tag") == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "chain, hash and tag are required"})
+				return
+			}
+			if err := is.tags.Remove(r.Context(), query.Get("chain"), query.Get("hash"), query.Get("tag")); err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]string{"status": "removed"})
+
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	})
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	tenantMessages = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_tenant_messages_total",
+			Help: "Transactions streamed to each tenant by transport and result (delivered, rate_limited)",
+		},
+		[]string{"tenant", "transport", "result"},
+	)
+
+	tenantBytes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_tenant_bytes_total",
+			Help: "Bytes of transactions streamed to each tenant by transport",
+		},
+		[]string{"tenant", "transport"},
+	)
+
+	tenantStreams = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_tenant_streams",
+			Help: "Open streams per tenant and transport",
+		},
+		[]string{"tenant", "transport"},
+	)
+
+	tenantRejected = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_tenant_rejected_total",
+			Help: "Tenant streams refused by reason (chain, streams)",
+		},
+		[]string{"tenant", "transport", "reason"},
+	)
+)
+
+// TenantConfig is one team sharing the streaming APIs: the keys that
+// identify it, the transactions per second shared by all its streams, the
+// chains it may stream and how many streams it may hold open. Zero limits
+// mean unlimited and no chains means every chain.
+type TenantConfig struct {
+	Keys       []string
+	RateLimit  float64
+	RateBurst  int
+	Chains     []string
+	MaxStreams int
+}
+
+// loadTenantConfigs reads the tenants listed in TENANTS from
+// TENANT_<NAME>_KEYS, _RATE_LIMIT, _RATE_BURST, _CHAINS and _MAX_STREAMS.
+// Keys may be ${secret:...} references.
+func loadTenantConfigs() map[string]TenantConfig {
+	tenants := make(map[string]TenantConfig)
+	for _, name := range splitNonEmpty(getEnv("TENANTS")) {
+		prefix := tenantPrefix(name)
+		tenant := TenantConfig{
+			Keys:       splitNonEmpty(getEnv(prefix + "KEYS")),
+			RateLimit:  getEnvFloat(prefix+"RATE_LIMIT", 0),
+			RateBurst:  getEnvInt(prefix+"RATE_BURST", 100),
+			Chains:     splitNonEmpty(getEnv(prefix + "CHAINS")),
+			MaxStreams: getEnvInt(prefix+"MAX_STREAMS", 0),
+		}
+		registerSecrets(tenant.Keys...)
+		tenants[name] = tenant
+	}
+	return tenants
+}
+
+// tenantPrefix is the prefix of a tenant's settings
+func tenantPrefix(name string) string {
+	return "TENANT_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+}
+
+// tenantQuota is a tenant's quota shared between all of its streams
+type tenantQuota struct {
+	name    string
+	config  TenantConfig
+	limiter *rate.Limiter
+	mu      sync.Mutex
+	streams int
+}
+
+// newTenantQuotas creates the quota of every configured tenant
+func newTenantQuotas(tenants map[string]TenantConfig) map[string]*tenantQuota {
+	quotas := make(map[string]*tenantQuota, len(tenants))
+	for name, config := range tenants {
+		limit := rate.Inf
+		if config.RateLimit > 0 {
+			limit = rate.Limit(config.RateLimit)
+		}
+		quotas[name] = &tenantQuota{name: name, config: config, limiter: rate.NewLimiter(limit, config.RateBurst)}
+		slog.Info("tenant configured", "tenant", name, "rate_limit", config.RateLimit, "chains", config.Chains, "max_streams", config.MaxStreams)
+	}
+	return quotas
+}
+
+// quotaError is a stream a tenant's quota does not allow
+type quotaError struct {
+	reason string
+	msg    string
+}
+
+func (e *quotaError) Error() string {
+	return e.msg
+}
+
+// HTTPStatus is the response status for the refused stream
+func (e *quotaError) HTTPStatus() int {
+	if e.reason == "chain" {
+		return http.StatusForbidden
+	}
+	return http.StatusTooManyRequests
+}
+
+// GRPCCode is the gRPC status code for the refused stream
+func (e *quotaError) GRPCCode() codes.Code {
+	if e.reason == "chain" {
+		return codes.PermissionDenied
+	}
+	return codes.ResourceExhausted
+}
+
+// tenantStream meters one stream against its tenant's quota. A nil stream,
+// for clients that are not tenants, allows and meters nothing.
+type tenantStream struct {
+	quota     *tenantQuota
+	transport string
+	once      sync.Once
+}
+
+// openTenantStream checks a stream request against the quota of the tenant
+// authenticated in ctx. It returns the chains the stream may follow, the
+// requested ones or, without any, every chain the tenant is allowed.
+func (is *IngestionService) openTenantStream(ctx context.Context, transport string, chains []string) (*tenantStream, []string, *quotaError) {
+	principal, ok := principalFrom(ctx)
+	if !ok || principal.Tenant == "" {
+		return nil, chains, nil
+	}
+	quota := is.tenants[principal.Tenant]
+	if quota == nil {
+		return nil, chains, nil
+	}
+
+	if allowed := quota.config.Chains; len(allowed) > 0 {
+		if len(chains) == 0 {
+			chains = allowed
+		}
+		for _, chain := range chains {
+			if !containsString(allowed, chain) {
+				tenantRejected.WithLabelValues(quota.name, transport, "chain").Inc()
+				return nil, nil, &quotaError{reason: "chain", msg: fmt.Sprintf("tenant %s may not stream %s", quota.name, chain)}
+			}
+		}
+	}
+
+	quota.mu.Lock()
+	defer quota.mu.Unlock()
+	if quota.config.MaxStreams > 0 && quota.streams >= quota.config.MaxStreams {
+		tenantRejected.WithLabelValues(quota.name, transport, "streams").Inc()
+		return nil, nil, &quotaError{reason: "streams", msg: fmt.Sprintf("tenant %s has its limit of %d streams open", quota.name, quota.config.MaxStreams)}
+	}
+	quota.streams++
+	tenantStreams.WithLabelValues(quota.name, transport).Inc()
+	return &tenantStream{quota: quota, transport: transport}, chains, nil
+}
+
+// Allow reports whether the tenant's rate limit admits another transaction
+func (s *tenantStream) Allow() bool {
+	if s == nil {
+		return true
+	}
+	if s.quota.limiter.Allow() {
+		return true
+	}
+	tenantMessages.WithLabelValues(s.quota.name, s.transport, "rate_limited").Inc()
+	return false
+}
+
+// Delivered meters a transaction sent to the tenant
+func (s *tenantStream) Delivered(bytes int) {
+	if s == nil {
+		return
+	}
+	tenantMessages.WithLabelValues(s.quota.name, s.transport, "delivered").Inc()
+	tenantBytes.WithLabelValues(s.quota.name, s.transport).Add(float64(bytes))
+}
+
+// Close frees the stream's slot
+func (s *tenantStream) Close() {
+	if s == nil {
+		return
+	}
+	s.once.Do(func() {
+		s.quota.mu.Lock()
+		s.quota.streams--
+		s.quota.mu.Unlock()
+		tenantStreams.WithLabelValues(s.quota.name, s.transport).Dec()
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math/big"
+	"strings"
+)
+
+// Token standards
+const (
+	TokenStandardERC20  = "erc20"
+	TokenStandardERC721 = "erc721"
+	// TokenStandardUnknown covers selectors shared by ERC-20 and ERC-721
+	TokenStandardUnknown = "erc20_or_erc721"
+)
+
+// TokenTransfer describes a token movement or approval decoded from calldata
+type TokenTransfer struct {
+	Method   string `json:"method"`
+	Standard string `json:"standard"`
+	Token    string `json:"token"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Amount   string `json:"amount,omitempty"`
+	TokenID  string `json:"token_id,omitempty"`
+}
+
+// tokenSelector describes how to decode a token method's arguments
+type tokenSelector struct {
+	method   string
+	standard string
+	args     int
+	// hasFrom is true when the first argument is the sender instead of tx.From
+	hasFrom bool
+}
+
+var tokenSelectors = map[string]tokenSelector{
+	"a9059cbb": {method: "transfer", standard: TokenStandardERC20, args: 2},
+	"23b872dd": {method: "transferFrom", standard: TokenStandardUnknown, args: 3, hasFrom: true},
+	"095ea7b3": {method: "approve", standard: TokenStandardUnknown, args: 2},
+	"42842e0e": {method: "safeTransferFrom", standard: TokenStandardERC721, args: 3, hasFrom: true},
+	"b88d4fde": {method: "safeTransferFrom", standard: TokenStandardERC721, args: 3, hasFrom: true},
+}
+
+// TokenTransferEnricher recognizes ERC-20/ERC-721 transfer and approval calls
+type TokenTransferEnricher struct{}
+
+// Name returns the enricher name
+func (e *TokenTransferEnricher) Name() string {
+	return "token_transfer"
+}
+
+// Enrich attaches a TokenTransfer when the calldata matches a known token selector
+func (e *TokenTransferEnricher) Enrich(tx *Transaction) {
+	if transfer := decodeTokenTransfer(tx); transfer != nil {
+		tx.TokenTransfer = transfer
+	}
+}
+
+// decodeTokenTransfer decodes a token transfer or approval call, or returns nil
+func decodeTokenTransfer(tx *Transaction) *TokenTransfer {
+	if tx.To == "" {
+		return nil
+	}
+
+	selector, args, ok := splitCalldata(tx.Data)
+	if !ok {
+		return nil
+	}
+
+	spec, ok := tokenSelectors[selector]
+	if !ok || len(args) < spec.args {
+		return nil
+	}
+
+	transfer := &TokenTransfer{
+		Method:   spec.method,
+		Standard: spec.standard,
+		Token:    strings.ToLower(tx.To),
+		From:     strings.ToLower(tx.From),
+	}
+
+	if spec.hasFrom {
+		transfer.From = abiAddress(args[0])
+		args = args[1:]
+	}
+	transfer.To = abiAddress(args[0])
+
+	value := abiUint(args[1])
+	if spec.standard == TokenStandardERC721 {
+		transfer.TokenID = value
+	} else {
+		transfer.Amount = value
+	}
+	return transfer
+}
+
+// splitCalldata splits hex calldata into its 4-byte selector and 32-byte argument words
+func splitCalldata(data string) (string, []string, bool) {
+	data = strings.TrimPrefix(strings.ToLower(data), "0x")
+	if len(data) < 8 {
+		return "", nil, false
+	}
+
+	return data[:8], splitWords(data[8:]), true
+}
+
+// splitWords splits hex ABI data, such as an event log's data, into 32-byte words
+func splitWords(data string) []string {
+	data = strings.TrimPrefix(strings.ToLower(data), "0x")
+	words := make([]string, 0, len(data)/64)
+	for len(data) >= 64 {
+		words = append(words, data[:64])
+		data = data[64:]
+	}
+	return words
+}
+
+// abiAddress extracts an address from a 32-byte ABI word
+func abiAddress(word string) string {
+	return "0x" + word[24:]
+}
+
+// abiUint decodes a 32-byte ABI word as a decimal integer string
+func abiUint(word string) string {
+	n, ok := new(big.Int).SetString(word, 16)
+	if !ok {
+		return ""
+	}
+	return n.String()
+}
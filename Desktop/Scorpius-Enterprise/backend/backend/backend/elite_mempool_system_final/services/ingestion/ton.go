@@ -0,0 +1,331 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TonMonitor ingests TON through an indexer's JSON-RPC websocket in the
+// TonAPI format (wss://tonapi.io/v2/websocket): external messages entering
+// the mempool, and, for the accounts in <CHAIN>_TON_ACCOUNTS, their
+// committed transactions. Messages are bags of cells; the envelope carries
+// the message hash and the wallet it is addressed to, and Raw the BOC.
+type TonMonitor struct {
+	*ChainMonitor
+}
+
+// NewTonMonitor creates a TON monitor sharing the chain monitor's connection management
+func NewTonMonitor(chain ChainInfo, endpoints []string, options ChainOptions, sink Sink, cache Cache, alerter *Alerter) *TonMonitor {
+	tm := &TonMonitor{
+		ChainMonitor: NewChainMonitor(chain.Name, chain.ChainID, endpoints, options, sink, cache, alerter),
+	}
+	tm.family = chain.Family
+	tm.protocol = tm
+	return tm
+}
+
+// subscribeRequests subscribes to the mempool, narrowed to the configured
+// accounts if any, and to those accounts' transactions
+func (tm *TonMonitor) subscribeRequests() []interface{} {
+	accounts := tm.options.TonAccounts
+	params := []string{}
+	if len(accounts) > 0 {
+		params = append(params, "accounts="+strings.Join(accounts, ","))
+	}
+	requests := []interface{}{map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "subscribe_mempool",
+		"params":  params,
+	}}
+	if len(accounts) > 0 {
+		requests = append(requests, map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      2,
+			"method":  "subscribe_account",
+			"params":  accounts,
+		})
+	}
+	return requests
+}
+
+// handleMessage maps mempool messages and account transactions into the
+// Transaction envelope
+func (tm *TonMonitor) handleMessage(msg *rpcMessage) error {
+	if msg.Error != nil {
+		return fmt.Errorf("subscription failed: %v", msg.Error)
+	}
+	if msg.Params == nil || isJSONNull(msg.Params.Raw) {
+		return nil
+	}
+
+	tx := Transaction{
+		ChainID:     tm.chainID,
+		Chain:       tm.chainName,
+		ChainFamily: tm.family,
+		Timestamp:   time.Now().Unix(),
+	}
+	switch msg.Method {
+	case "mempool_message":
+		var params struct {
+			BOC string `json:"boc"`
+		}
+		if err := wireJSON.Unmarshal(msg.Params.Raw, &params); err != nil {
+			return fmt.Errorf("failed to decode mempool message: %v", err)
+		}
+		boc, err := base64.StdEncoding.DecodeString(params.BOC)
+		if err != nil {
+			return fmt.Errorf("failed to decode mempool message: %v", err)
+		}
+		decoded, err := decodeTonMessage(boc)
+		if err != nil {
+			return err
+		}
+		tx.Hash, tx.Type, tx.From = decoded.hash, decoded.kind, decoded.dest
+		tx.Status = "pending"
+		tx.Raw, _ = json.Marshal(map[string]string{"boc": params.BOC})
+
+	case "account_transaction":
+		var params struct {
+			AccountID string `json:"account_id"`
+			LT        uint64 `json:"lt"`
+			TxHash    string `json:"tx_hash"`
+		}
+		if err := wireJSON.Unmarshal(msg.Params.Raw, &params); err != nil {
+			return fmt.Errorf("failed to decode account transaction: %v", err)
+		}
+		tx.Hash, tx.From = params.TxHash, params.AccountID
+		tx.Type = "transaction"
+		tx.Nonce = strconv.FormatUint(params.LT, 10)
+		tx.Status = "confirmed"
+		tx.Raw = msg.Params.Raw
+
+	default:
+		return nil
+	}
+	if tx.Hash == "" {
+		return nil
+	}
+	return tm.publishTransaction(tx)
+}
+
+// tonMessage is what the envelope carries of a message: its hash, kind
+// (external_in, internal or external_out) and, for external messages to a
+// wallet, the wallet's raw address
+type tonMessage struct {
+	hash string
+	kind string
+	dest string
+}
+
+// decodeTonMessage reads the hash and header of a serialized message
+func decodeTonMessage(boc []byte) (*tonMessage, error) {
+	root, err := parseBOC(boc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message boc: %v", err)
+	}
+	msg := &tonMessage{hash: hex.EncodeToString(root.hash[:])}
+
+	r := &bitReader{data: root.data, n: root.bits}
+	switch {
+	case r.read(1) == 0:
+		msg.kind = "internal"
+	case r.read(1) == 1:
+		msg.kind = "external_out"
+	default:
+		// ext_in_msg_info$10 src:MsgAddressExt dest:MsgAddressInt
+		msg.kind = "external_in"
+		if r.read(2) == 1 {
+			r.skip(int(r.read(9)))
+		}
+		msg.dest = r.address()
+	}
+	if r.err {
+		return nil, fmt.Errorf("invalid message header")
+	}
+	return msg, nil
+}
+
+// tonCell is a cell of a bag of cells, with the hash and depth of its
+// representation once computed
+type tonCell struct {
+	d1, d2 byte
+	data   []byte
+	bits   int
+	refs   []int
+	hash   [32]byte
+	depth  uint16
+}
+
+// parseBOC decodes a bag of cells (serialized_boc#b5ee9c72) and returns its
+// first root. Hashes are those of ordinary cells; exotic cells with higher
+// levels, such as Merkle proofs, do not occur in messages.
+func parseBOC(boc []byte) (*tonCell, error) {
+	if len(boc) < 6 || binary.BigEndian.Uint32(boc) != 0xb5ee9c72 {
+		return nil, fmt.Errorf("unsupported boc format")
+	}
+	hasIndex := boc[4]&0x80 != 0
+	size, offBytes := int(boc[4]&7), int(boc[5])
+	if size < 1 || size > 4 || offBytes < 1 || offBytes > 8 {
+		return nil, fmt.Errorf("invalid boc header")
+	}
+	b := boc[6:]
+	readInt := func(n int) (int, bool) {
+		if len(b) < n {
+			return 0, false
+		}
+		v := 0
+		for _, c := range b[:n] {
+			v = v<<8 | int(c)
+		}
+		b = b[n:]
+		return v, true
+	}
+
+	cellCount, ok1 := readInt(size)
+	rootCount, ok2 := readInt(size)
+	_, ok3 := readInt(size)
+	_, ok4 := readInt(offBytes)
+	rootIndex, ok5 := readInt(size)
+	if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 || rootCount < 1 || rootIndex >= cellCount || cellCount > len(b)/2 {
+		return nil, fmt.Errorf("invalid boc header")
+	}
+	if _, ok := readInt((rootCount - 1) * size); !ok {
+		return nil, fmt.Errorf("truncated boc")
+	}
+	if hasIndex {
+		if _, ok := readInt(cellCount * offBytes); !ok {
+			return nil, fmt.Errorf("truncated boc")
+		}
+	}
+
+	cells := make([]*tonCell, cellCount)
+	for i := range cells {
+		if len(b) < 2 {
+			return nil, fmt.Errorf("truncated boc")
+		}
+		d1, d2 := b[0], b[1]
+		b = b[2:]
+		cell := &tonCell{d1: d1 &^ 16, d2: d2}
+
+		// Cells may be stored with a hash and depth per level, recomputed here
+		if d1&16 != 0 {
+			levels := bits.OnesCount8(d1>>5) + 1
+			if len(b) < levels*34 {
+				return nil, fmt.Errorf("truncated boc")
+			}
+			b = b[levels*34:]
+		}
+
+		dataLen := (int(d2) + 1) / 2
+		refCount := int(d1 & 7)
+		if len(b) < dataLen+refCount*size || refCount > 4 {
+			return nil, fmt.Errorf("truncated boc")
+		}
+		cell.data = b[:dataLen]
+		b = b[dataLen:]
+		cell.bits = dataLen * 8
+		if d2%2 == 1 && dataLen > 0 {
+			// An odd d2 marks a partial last byte, completed by a 1 bit and zeros
+			cell.bits -= bits.TrailingZeros8(cell.data[dataLen-1]) + 1
+		}
+		for r := 0; r < refCount; r++ {
+			ref, _ := readInt(size)
+			if ref <= i || ref >= cellCount {
+				return nil, fmt.Errorf("invalid cell reference")
+			}
+			cell.refs = append(cell.refs, ref)
+		}
+		cells[i] = cell
+	}
+
+	// References point forward, so hashing from the last cell finds every
+	// child already hashed
+	for i := cellCount - 1; i >= 0; i-- {
+		cell := cells[i]
+		repr := append([]byte{cell.d1, cell.d2}, cell.data...)
+		for _, ref := range cell.refs {
+			child := cells[ref]
+			repr = binary.BigEndian.AppendUint16(repr, child.depth)
+			if child.depth+1 > cell.depth {
+				cell.depth = child.depth + 1
+			}
+		}
+		for _, ref := range cell.refs {
+			repr = append(repr, cells[ref].hash[:]...)
+		}
+		cell.hash = sha256.Sum256(repr)
+	}
+	return cells[rootIndex], nil
+}
+
+// bitReader reads big-endian bit fields from cell data. Reading past the
+// end sets err and returns zeros.
+type bitReader struct {
+	data []byte
+	n    int
+	pos  int
+	err  bool
+}
+
+// read returns the next n bits, n <= 64
+func (r *bitReader) read(n int) uint64 {
+	var v uint64
+	for ; n > 0; n-- {
+		if r.pos >= r.n {
+			r.err = true
+			return 0
+		}
+		v = v<<1 | uint64(r.data[r.pos/8]>>(7-r.pos%8)&1)
+		r.pos++
+	}
+	return v
+}
+
+// skip discards the next n bits
+func (r *bitReader) skip(n int) {
+	if r.pos+n > r.n {
+		r.err = true
+		return
+	}
+	r.pos += n
+}
+
+// bytes reads n whole bytes, which need not be aligned
+func (r *bitReader) bytes(n int) []byte {
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = byte(r.read(8))
+	}
+	return out
+}
+
+// address reads a MsgAddressInt in the raw workchain:hex form
+func (r *bitReader) address() string {
+	tag := r.read(2)
+	if tag != 2 && tag != 3 {
+		r.err = true
+		return ""
+	}
+	// anycast:(Maybe Anycast), deprecated but still part of the layout
+	if r.read(1) == 1 {
+		r.skip(int(r.read(5)))
+	}
+	if tag == 2 {
+		// addr_std$10 workchain_id:int8 address:bits256
+		workchain := int8(r.read(8))
+		return fmt.Sprintf("%d:%s", workchain, hex.EncodeToString(r.bytes(32)))
+	}
+	// addr_var$11 addr_len:(## 9) workchain_id:int32 address:(bits addr_len)
+	length := int(r.read(9))
+	workchain := int32(r.read(32))
+	return fmt.Sprintf("%d:%s", workchain, hex.EncodeToString(r.bytes((length+7)/8)))
+}
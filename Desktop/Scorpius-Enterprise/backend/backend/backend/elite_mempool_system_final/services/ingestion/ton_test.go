@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// tonBits builds cell data from a string of 0 and 1 digits, returning the
+// d2 descriptor and the data with its completion tag
+func tonBits(bits string) (byte, []byte) {
+	data := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit == '1' {
+			data[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	if len(bits)%8 != 0 {
+		data[len(bits)/8] |= 0x80 >> (len(bits) % 8)
+	}
+	return byte(len(bits)/8 + (len(bits)+7)/8), data
+}
+
+// tonBOC serializes cells without an index, with one-byte sizes and
+// offsets, the first cell being the root
+func tonBOC(cells ...tonTestCell) []byte {
+	var body []byte
+	for _, cell := range cells {
+		d2, data := tonBits(cell.bits)
+		body = append(body, byte(len(cell.refs)), d2)
+		body = append(body, data...)
+		body = append(body, cell.refs...)
+	}
+	boc := []byte{0xb5, 0xee, 0x9c, 0x72, 0x01, 0x01, byte(len(cells)), 1, 0, byte(len(body)), 0}
+	return append(boc, body...)
+}
+
+type tonTestCell struct {
+	bits string
+	refs []byte
+}
+
+// bitString renders n as width bits
+func bitString(n uint64, width int) string {
+	var sb strings.Builder
+	for i := width - 1; i >= 0; i-- {
+		sb.WriteByte('0' + byte(n>>uint(i)&1))
+	}
+	return sb.String()
+}
+
+const tonWallet = "83dfd552e63729b472fcbcc8c45ebcc6691702558b68ec7527e1ba403a0f31a8"
+
+// tonStdAddress is an addr_std without anycast in workchain 0
+func tonStdAddress(account string) string {
+	raw, _ := hex.DecodeString(account)
+	var sb strings.Builder
+	sb.WriteString("10" + "0" + bitString(0, 8))
+	for _, b := range raw {
+		sb.WriteString(bitString(uint64(b), 8))
+	}
+	return sb.String()
+}
+
+func TestParseBOC(t *testing.T) {
+	// The hash of the empty cell is the well-known constant every TON library shares
+	root, err := parseBOC(tonBOC(tonTestCell{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(root.hash[:]); got != "96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7" {
+		t.Errorf("empty cell hash = %s", got)
+	}
+
+	// A parent's representation covers its child's depth and hash
+	root, err = parseBOC(tonBOC(tonTestCell{bits: "1", refs: []byte{1}}, tonTestCell{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, _ := hex.DecodeString("96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7")
+	want := sha256.Sum256(append([]byte{1, 1, 0xc0, 0, 0}, child...))
+	if root.hash != want || root.depth != 1 {
+		t.Errorf("parent hash = %x depth %d, want %x depth 1", root.hash, root.depth, want)
+	}
+}
+
+func TestParseBOCMalformed(t *testing.T) {
+	valid := tonBOC(tonTestCell{bits: "1", refs: []byte{1}}, tonTestCell{bits: "0101"})
+
+	tests := []struct {
+		name string
+		boc  []byte
+	}{
+		{"empty", nil},
+		{"wrong magic", append([]byte{0xb5, 0xee, 0x9c, 0x73}, valid[4:]...)},
+		{"zero size", append(append([]byte{}, valid[:4]...), append([]byte{0x00}, valid[5:]...)...)},
+		{"root past the cells", tonBOCWithRoot(valid, 2)},
+		{"backward reference", tonBOC(tonTestCell{bits: "1", refs: []byte{0}})},
+		{"reference past the cells", tonBOC(tonTestCell{bits: "1", refs: []byte{5}}, tonTestCell{})},
+		{"more cells than bytes", []byte{0xb5, 0xee, 0x9c, 0x72, 0x01, 0x01, 0xff, 1, 0, 2, 0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseBOC(tt.boc); err == nil {
+				t.Error("parseBOC() succeeded")
+			}
+		})
+	}
+
+	// Every truncation of a valid bag of cells fails cleanly
+	for n := 0; n < len(valid); n++ {
+		if _, err := parseBOC(valid[:n]); err == nil {
+			t.Errorf("parseBOC() of %d/%d bytes succeeded", n, len(valid))
+		}
+	}
+}
+
+// tonBOCWithRoot returns a copy of boc whose root index is root
+func tonBOCWithRoot(boc []byte, root byte) []byte {
+	out := append([]byte{}, boc...)
+	out[10] = root
+	return out
+}
+
+func TestDecodeTonMessage(t *testing.T) {
+	// ext_in_msg_info$10 src dest import_fee:(VarUInteger 16), then no
+	// state init and an inline empty body
+	tail := "0000" + "0" + "0"
+
+	tests := []struct {
+		name string
+		bits string
+		kind string
+		dest string
+		err  bool
+	}{
+		{
+			name: "external in from addr_none",
+			bits: "10" + "00" + tonStdAddress(tonWallet) + tail,
+			kind: "external_in",
+			dest: "0:" + tonWallet,
+		},
+		{
+			name: "external in from addr_extern",
+			bits: "10" + "01" + bitString(8, 9) + "10101010" + tonStdAddress(tonWallet) + tail,
+			kind: "external_in",
+			dest: "0:" + tonWallet,
+		},
+		{
+			name: "external in to a masterchain addr_var",
+			bits: "10" + "00" + "11" + "0" + bitString(16, 9) + bitString(0xffffffff, 32) + "1010101111001101" + tail,
+			kind: "external_in",
+			dest: "-1:abcd",
+		},
+		{
+			name: "internal",
+			bits: "0" + "1110",
+			kind: "internal",
+		},
+		{
+			name: "external out",
+			bits: "11" + "00",
+			kind: "external_out",
+		},
+		{
+			name: "external in with a truncated destination",
+			bits: "10" + "00" + tonStdAddress(tonWallet)[:100],
+			err:  true,
+		},
+		{
+			name: "external in to addr_none",
+			bits: "10" + "00" + "00" + tail,
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := decodeTonMessage(tonBOC(tonTestCell{bits: tt.bits}))
+			if tt.err {
+				if err == nil {
+					t.Errorf("decodeTonMessage() = %+v, want an error", msg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeTonMessage() error = %v", err)
+			}
+			if msg.kind != tt.kind || msg.dest != tt.dest || len(msg.hash) != 64 {
+				t.Errorf("decodeTonMessage() = %+v, want kind %s dest %s", msg, tt.kind, tt.dest)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultTopicTemplate keeps every chain on the historical shared topic
+const defaultTopicTemplate = "tx_raw"
+
+// Topic routing rule kinds
+const (
+	RouteMinValue = "min_value"
+	RouteTo       = "to"
+	RouteFrom     = "from"
+)
+
+// topicRoute publishes matching transactions to an additional topic
+type topicRoute struct {
+	kind      string
+	minValue  *big.Int
+	addresses map[string]bool
+	template  string
+}
+
+// matches reports whether tx satisfies the route's condition
+func (r topicRoute) matches(tx *Transaction) bool {
+	switch r.kind {
+	case RouteMinValue:
+		return tx.Value != "" && hexToBig(tx.Value).Cmp(r.minValue) >= 0
+	case RouteTo:
+		return r.addresses[strings.ToLower(tx.To)]
+	case RouteFrom:
+		return r.addresses[strings.ToLower(tx.From)]
+	}
+	return false
+}
+
+// topicRouter resolves the topics a transaction is published to: the chain's
+// base topic, plus the topic of every matching route. Templates may use
+// {chain}, {chain_id} and {family}, e.g. "tx_raw.{chain}".
+type topicRouter struct {
+	template   string
+	routes     []topicRoute
+	exprRoutes []exprRoute
+	watchlist  string
+	mev        string
+}
+
+// newTopicRouter parses routing rules of the form "<kind>:<arg>" -> topic template:
+//
+//	min_value:1000000000000000000 -> tx_whale.{chain}   (value in wei)
+//	to:0xabc...|0xdef...          -> tx_watched.{chain}
+//	from:0x123...                 -> tx_watched.{chain}
+func newTopicRouter(template string, rules map[string]string) (*topicRouter, error) {
+	if template == "" {
+		template = defaultTopicTemplate
+	}
+	router := &topicRouter{template: template}
+
+	for rule, topic := range rules {
+		kind, arg, ok := strings.Cut(rule, ":")
+		if !ok || topic == "" {
+			return nil, fmt.Errorf("invalid topic route %q", rule)
+		}
+
+		route := topicRoute{kind: kind, template: topic}
+		switch kind {
+		case RouteMinValue:
+			value, ok := parseWei(arg)
+			if !ok {
+				return nil, fmt.Errorf("invalid value threshold in topic route %q", rule)
+			}
+			route.minValue = value
+		case RouteTo, RouteFrom:
+			route.addresses = make(map[string]bool)
+			for _, address := range strings.Split(arg, "|") {
+				route.addresses[strings.ToLower(strings.TrimSpace(address))] = true
+			}
+		default:
+			return nil, fmt.Errorf("unknown topic route kind %q", kind)
+		}
+		router.routes = append(router.routes, route)
+	}
+	return router, nil
+}
+
+// parseWei parses an integer amount, accepting exponent notation such as 1e18
+func parseWei(value string) (*big.Int, bool) {
+	if n, ok := new(big.Int).SetString(value, 10); ok {
+		return n, true
+	}
+	f, ok := new(big.Float).SetPrec(256).SetString(value)
+	if !ok || f.Sign() < 0 {
+		return nil, false
+	}
+	n, _ := f.Int(nil)
+	return n, true
+}
+
+// Topics appends the base topic followed by any routed topics to topics,
+// without duplicates. Watchlist matches go to the watchlist topic first so
+// they are produced ahead of everything else.
+func (r *topicRouter) Topics(topics []string, tx *Transaction) []string {
+	if r.watchlist != "" && hasTag(tx, watchlistTag) {
+		topics = append(topics, expandTopic(r.watchlist, tx.Chain, tx.ChainID, tx.ChainFamily))
+	}
+	if base := expandTopic(r.template, tx.Chain, tx.ChainID, tx.ChainFamily); !containsString(topics, base) {
+		topics = append(topics, base)
+	}
+	if r.mev != "" && tx.MEV != nil {
+		if topic := expandTopic(r.mev, tx.Chain, tx.ChainID, tx.ChainFamily); !containsString(topics, topic) {
+			topics = append(topics, topic)
+		}
+	}
+	for _, route := range r.routes {
+		if !route.matches(tx) {
+			continue
+		}
+		topic := expandTopic(route.template, tx.Chain, tx.ChainID, tx.ChainFamily)
+		if !containsString(topics, topic) {
+			topics = append(topics, topic)
+		}
+	}
+
+	var vars map[string]interface{}
+	for _, route := range r.exprRoutes {
+		if vars == nil {
+			vars = exprVars(tx)
+		}
+		if !route.expr.Matches(vars, tx.Chain, "route") {
+			continue
+		}
+		topic := expandTopic(route.template, tx.Chain, tx.ChainID, tx.ChainFamily)
+		if !containsString(topics, topic) {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
+
+// topicExpansion identifies one expansion of a topic template
+type topicExpansion struct {
+	template string
+	chain    string
+	chainID  int64
+	family   string
+}
+
+// maxExpandedTopics bounds the expansions expandTopic remembers. Templates
+// and chains both come from config, so the bound is only hit by a caller
+// passing arbitrary chain names.
+const maxExpandedTopics = 4096
+
+// expandedTopics remembers the topics expandTopic built
+var expandedTopics struct {
+	mu     sync.RWMutex
+	topics map[topicExpansion]string
+}
+
+// expandTopic substitutes chain placeholders in a topic template. It runs
+// for every published message, so expansions are remembered rather than
+// built again for each one.
+func expandTopic(template, chain string, chainID int64, family string) string {
+	if strings.IndexByte(template, '{') < 0 {
+		return template
+	}
+
+	key := topicExpansion{template: template, chain: chain, chainID: chainID, family: family}
+	expandedTopics.mu.RLock()
+	topic, ok := expandedTopics.topics[key]
+	expandedTopics.mu.RUnlock()
+	if ok {
+		return topic
+	}
+
+	topic = buildTopic(template, chain, chainID, family)
+	expandedTopics.mu.Lock()
+	if expandedTopics.topics == nil {
+		expandedTopics.topics = make(map[topicExpansion]string)
+	}
+	if len(expandedTopics.topics) < maxExpandedTopics {
+		expandedTopics.topics[key] = topic
+	}
+	expandedTopics.mu.Unlock()
+	return topic
+}
+
+// buildTopic expands a template by scanning it by hand, rather than building
+// a strings.Replacer each time
+func buildTopic(template, chain string, chainID int64, family string) string {
+	var b strings.Builder
+	b.Grow(len(template) + len(chain) + len(family) + 20)
+	for rest := template; rest != ""; {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			b.WriteString(rest)
+			break
+		}
+		b.WriteString(rest[:start])
+		rest = rest[start:]
+		switch {
+		case strings.HasPrefix(rest, "{chain}"):
+			b.WriteString(chain)
+			rest = rest[len("{chain}"):]
+		case strings.HasPrefix(rest, "{chain_id}"):
+			var digits [20]byte
+			b.Write(strconv.AppendInt(digits[:0], chainID, 10))
+			rest = rest[len("{chain_id}"):]
+		case strings.HasPrefix(rest, "{family}"):
+			b.WriteString(family)
+			rest = rest[len("{family}"):]
+		default:
+			b.WriteByte('{')
+			rest = rest[1:]
+		}
+	}
+	return b.String()
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
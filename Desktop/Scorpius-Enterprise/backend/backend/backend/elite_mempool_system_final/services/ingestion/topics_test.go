@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestExpandTopic(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"tx_raw", "tx_raw"},
+		{"tx_raw.{chain}", "tx_raw.ethereum"},
+		{"{family}.{chain_id}.{chain}", "evm.1.ethereum"},
+		{"{chain}{chain}", "ethereumethereum"},
+		{"tx_{unknown}_{chain", "tx_{unknown}_{chain"},
+		{"{{chain}}", "{ethereum}"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := expandTopic(tt.template, "ethereum", 1, FamilyEVM); got != tt.want {
+			t.Errorf("expandTopic(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+	if got := expandTopic("{chain_id}", "custom", -42, FamilyEVM); got != "-42" {
+		t.Errorf("expandTopic({chain_id}) = %q, want -42", got)
+	}
+}
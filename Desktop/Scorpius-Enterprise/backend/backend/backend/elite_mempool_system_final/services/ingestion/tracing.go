@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer delegates to the global provider, so spans started before
+// setupTracing runs (or with tracing disabled) are no-ops
+var tracer = otel.Tracer("scorpius-ingestion")
+
+// tracingEnabled is set once setupTracing installs an exporter. Until then
+// the ingest path skips the tracer: even a no-op span from the global
+// delegate, and the context carrying it, allocate for every transaction.
+var tracingEnabled atomic.Bool
+
+// untracedSpan stands in for the ingest path's spans while tracing is off
+var untracedSpan = trace.SpanFromContext(context.Background())
+
+// TracingConfig configures OpenTelemetry trace export
+type TracingConfig struct {
+	Endpoint    string
+	Insecure    bool
+	SampleRatio float64
+}
+
+// loadTracingConfig reads the OTLP exporter settings; tracing is off without an endpoint
+func loadTracingConfig() TracingConfig {
+	return TracingConfig{
+		Endpoint:    getEnv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		Insecure:    getEnvBool("OTEL_EXPORTER_OTLP_INSECURE", false),
+		SampleRatio: getEnvFloat("TRACE_SAMPLE_RATIO", 0.01),
+	}
+}
+
+// setupTracing installs an OTLP/gRPC tracer provider and the W3C trace
+// context propagator. Root spans are sampled at SampleRatio; spans with a
+// sampled parent are always kept. The returned function flushes and stops
+// the exporter.
+func setupTracing(config TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if config.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	endpoint, insecure := config.Endpoint, config.Insecure
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		endpoint = u.Host
+		insecure = insecure || u.Scheme == "http"
+	}
+
+	options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+	if insecure {
+		options = append(options, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(context.Background(), options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName("scorpius-ingestion"),
+			semconv.ServiceVersion(version),
+		)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	tracingEnabled.Store(true)
+	return provider.Shutdown, nil
+}
+
+// startIngestSpan starts the root span covering a transaction from receipt to delivery
+func (cm *ChainMonitor) startIngestSpan() trace.Span {
+	if !tracingEnabled.Load() {
+		return untracedSpan
+	}
+	_, span := tracer.Start(cm.ctx, "ingest", trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.String("chain", cm.chainName)))
+	return span
+}
+
+// startSpan starts a span of kind under the span in ctx. While tracing is off
+// it returns ctx with untracedSpan instead.
+func startSpan(ctx context.Context, name string, kind trace.SpanKind) (context.Context, trace.Span) {
+	if !tracingEnabled.Load() {
+		return ctx, untracedSpan
+	}
+	return tracer.Start(ctx, name, trace.WithSpanKind(kind))
+}
+
+// contextWithSpan returns ctx carrying span, or ctx itself while tracing is off
+func contextWithSpan(ctx context.Context, span trace.Span) context.Context {
+	if !tracingEnabled.Load() {
+		return ctx
+	}
+	return trace.ContextWithSpan(ctx, span)
+}
+
+// injectTraceContext adds the span context in ctx to message headers so
+// consumers can continue the trace
+func injectTraceContext(ctx context.Context, headers map[string]string) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+}
+
+// endSpan records err on span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
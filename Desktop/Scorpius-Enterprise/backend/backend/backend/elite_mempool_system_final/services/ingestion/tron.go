@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// tronPendingFetchLimit caps the pending transactions fetched per poll;
+	// the rest are fetched by later polls if still pending
+	tronPendingFetchLimit = 500
+	// tronCallTimeout bounds each Wallet API call
+	tronCallTimeout = 10 * time.Second
+)
+
+// TronMonitor ingests pending transactions from a Tron full node's gRPC
+// Wallet API. The node has no pending transaction stream, so the pending
+// pool is polled and each transaction seen for the first time is fetched
+// and published. Values are in sun, and Gas carries the fee limit of smart
+// contract calls. Endpoints are host:port, grpc://host:port, or
+// grpcs://host:port for TLS.
+type TronMonitor struct {
+	*ChainMonitor
+}
+
+// NewTronMonitor creates a Tron monitor sharing the chain monitor's endpoint management
+func NewTronMonitor(chain ChainInfo, endpoints []string, options ChainOptions, sink Sink, cache Cache, alerter *Alerter) *TronMonitor {
+	registerSecrets(options.TronAPIKey)
+	tm := &TronMonitor{
+		ChainMonitor: NewChainMonitor(chain.Name, chain.ChainID, endpoints, options, sink, cache, alerter),
+	}
+	tm.family = chain.Family
+	tm.streamer = tm
+	return tm
+}
+
+// stream polls the endpoint's pending pool and publishes new transactions
+func (tm *TronMonitor) stream(endpoint string) error {
+	start := time.Now()
+	wallet, err := dialTronWallet(tm.ctx, endpoint, tm.options.TronAPIKey)
+	if err != nil {
+		tm.updateHealthScore(endpoint, 0.0)
+		return err
+	}
+	defer wallet.Close()
+
+	if _, err := wallet.NowBlock(tm.ctx); err != nil {
+		if tm.ctx.Err() != nil {
+			return nil
+		}
+		tm.updateHealthScore(endpoint, 0.0)
+		return fmt.Errorf("failed to connect to %s: %v", endpoint, err)
+	}
+	latency := time.Since(start)
+	connectionLatency.WithLabelValues(tm.chainName, endpointLabel(endpoint)).Observe(latency.Seconds())
+	tm.recordLatency(endpoint, latency)
+
+	tm.backoff.Reset(endpoint)
+	tm.breaker.Success(endpoint)
+	tm.beginWarmup()
+
+	ticker := time.NewTicker(tm.options.TronPoll)
+	defer ticker.Stop()
+
+	seen := make(map[string]bool)
+	for {
+		ids, err := wallet.PendingIDs(tm.ctx)
+		if err != nil {
+			if tm.ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		current := make(map[string]bool, len(ids))
+		fetched := 0
+		for _, id := range ids {
+			if seen[id] {
+				current[id] = true
+				continue
+			}
+			if fetched == tronPendingFetchLimit {
+				continue
+			}
+			fetched++
+			current[id] = true
+
+			txBytes, err := wallet.PendingTransaction(tm.ctx, id)
+			if err != nil {
+				if tm.ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+			// Empty when the transaction left the pool since the listing
+			if len(txBytes) == 0 {
+				continue
+			}
+			if err := tm.handleTransaction(txBytes); err != nil {
+				tm.logger.Error("failed to handle message", "tx_hash", id, "error", err)
+			}
+		}
+		seen = current
+		tm.recordActivity(endpoint)
+
+		select {
+		case <-tm.ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleTransaction maps an encoded protocol.Transaction into the Transaction envelope
+func (tm *TronMonitor) handleTransaction(txBytes []byte) error {
+	decoded, err := decodeTronTx(txBytes)
+	if err != nil {
+		return err
+	}
+
+	tx := Transaction{
+		Hash:        decoded.txid,
+		ChainID:     tm.chainID,
+		Chain:       tm.chainName,
+		ChainFamily: tm.family,
+		Type:        decoded.contractType,
+		From:        decoded.owner,
+		To:          decoded.to,
+		Value:       strconv.FormatUint(decoded.amount, 10),
+		Status:      "pending",
+		Timestamp:   time.Now().Unix(),
+	}
+	if decoded.feeLimit > 0 {
+		tx.Gas = strconv.FormatUint(decoded.feeLimit, 10)
+	}
+	if len(decoded.data) > 0 {
+		tx.Data = "0x" + hex.EncodeToString(decoded.data)
+	}
+	tx.Raw, _ = json.Marshal(map[string]string{"hex": hex.EncodeToString(txBytes)})
+	return tm.publishTransaction(tx)
+}
+
+// tronTx is the part of a Tron transaction the envelope carries. Amounts
+// are in sun; feeLimit is the energy fee cap of smart contract calls.
+type tronTx struct {
+	txid         string
+	contractType string
+	owner        string
+	to           string
+	amount       uint64
+	data         []byte
+	feeLimit     uint64
+}
+
+// decodeTronTx decodes a protocol.Transaction. The transaction ID is the
+// SHA-256 of its raw_data; only the first contract is read, as Tron
+// transactions carry exactly one.
+func decodeTronTx(txBytes []byte) (*tronTx, error) {
+	var rawData []byte
+	if err := decodeFields(txBytes, func(f wireField) error {
+		if f.num == 1 {
+			rawData = f.bytes
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("invalid transaction: %v", err)
+	}
+	if rawData == nil {
+		return nil, fmt.Errorf("invalid transaction: no raw_data")
+	}
+
+	sum := sha256.Sum256(rawData)
+	tx := &tronTx{txid: hex.EncodeToString(sum[:])}
+	var contract []byte
+	if err := decodeFields(rawData, func(f wireField) error {
+		switch f.num {
+		case 11:
+			if contract == nil {
+				contract = f.bytes
+			}
+		case 18:
+			tx.feeLimit = f.varint
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("invalid transaction raw_data: %v", err)
+	}
+
+	// Contract.parameter is an Any named after the contract message
+	var typeURL string
+	var parameter []byte
+	decodeFields(contract, func(f wireField) error {
+		if f.num == 2 {
+			decodeFields(f.bytes, func(f wireField) error {
+				switch f.num {
+				case 1:
+					typeURL = string(f.bytes)
+				case 2:
+					parameter = f.bytes
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	tx.contractType = typeURL[strings.LastIndex(typeURL, ".")+1:]
+
+	// Field numbers of the owner, recipient and amount; owner_address is 1
+	// in every contract but TransferAssetContract
+	ownerField, toField, amountField, dataField := protowire.Number(1), protowire.Number(0), protowire.Number(0), protowire.Number(0)
+	switch tx.contractType {
+	case "TransferContract":
+		toField, amountField = 2, 3
+	case "TransferAssetContract":
+		ownerField, toField, amountField = 2, 3, 4
+	case "TriggerSmartContract":
+		toField, amountField, dataField = 2, 3, 4
+	}
+	decodeFields(parameter, func(f wireField) error {
+		switch f.num {
+		case ownerField:
+			tx.owner = tronAddress(f.bytes)
+		case toField:
+			tx.to = tronAddress(f.bytes)
+		case amountField:
+			tx.amount = f.varint
+		case dataField:
+			tx.data = f.bytes
+		}
+		return nil
+	})
+	return tx, nil
+}
+
+// tronAddress encodes a 21-byte address (0x41 and the account hash) as base58check
+func tronAddress(addr []byte) string {
+	if len(addr) != 21 {
+		return ""
+	}
+	return base58Check(addr[0], addr[1:])
+}
+
+// tronWallet calls the protocol.Wallet service of a Tron full node
+type tronWallet struct {
+	conn   *grpc.ClientConn
+	apiKey string
+}
+
+// dialTronWallet connects to a Wallet API endpoint. The connection is
+// established lazily, on the first call.
+func dialTronWallet(ctx context.Context, endpoint, apiKey string) (*tronWallet, error) {
+	target, creds := endpoint, insecure.NewCredentials()
+	switch {
+	case strings.HasPrefix(endpoint, "grpcs://"):
+		target, creds = strings.TrimPrefix(endpoint, "grpcs://"), credentials.NewTLS(&tls.Config{})
+	case strings.HasPrefix(endpoint, "grpc://"):
+		target = strings.TrimPrefix(endpoint, "grpc://")
+	}
+	conn, err := grpc.DialContext(ctx, target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", endpoint, err)
+	}
+	return &tronWallet{conn: conn, apiKey: apiKey}, nil
+}
+
+func (w *tronWallet) Close() {
+	w.conn.Close()
+}
+
+// call invokes a Wallet method with an encoded request, returning the encoded response
+func (w *tronWallet) call(ctx context.Context, method string, request []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, tronCallTimeout)
+	defer cancel()
+	if w.apiKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "tron-pro-api-key", w.apiKey)
+	}
+
+	req, resp := tronMessage(request), tronMessage(nil)
+	if err := w.conn.Invoke(ctx, "/protocol.Wallet/"+method, &req, &resp, grpc.ForceCodec(wireCodec{})); err != nil {
+		return nil, fmt.Errorf("%s: %v", method, err)
+	}
+	return resp, nil
+}
+
+// NowBlock returns the number of the node's latest block
+func (w *tronWallet) NowBlock(ctx context.Context) (int64, error) {
+	block, err := w.call(ctx, "GetNowBlock2", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	// BlockExtention.block_header (2) -> BlockHeader.raw_data (1) -> number (7)
+	var number int64
+	err = decodeFields(block, func(f wireField) error {
+		if f.num != 2 {
+			return nil
+		}
+		return decodeFields(f.bytes, func(f wireField) error {
+			if f.num != 1 {
+				return nil
+			}
+			return decodeFields(f.bytes, func(f wireField) error {
+				if f.num == 7 {
+					number = int64(f.varint)
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("GetNowBlock2: %v", err)
+	}
+	return number, nil
+}
+
+// PendingIDs lists the IDs of the transactions in the node's pending pool
+func (w *tronWallet) PendingIDs(ctx context.Context) ([]string, error) {
+	list, err := w.call(ctx, "GetTransactionListFromPending", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	if err := decodeFields(list, func(f wireField) error {
+		if f.num == 1 {
+			ids = append(ids, string(f.bytes))
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("GetTransactionListFromPending: %v", err)
+	}
+	return ids, nil
+}
+
+// PendingTransaction fetches an encoded pending transaction by hex ID
+func (w *tronWallet) PendingTransaction(ctx context.Context, id string) ([]byte, error) {
+	txid, err := hex.DecodeString(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pending transaction id %q", id)
+	}
+	request := protowire.AppendTag(nil, 1, protowire.BytesType)
+	return w.call(ctx, "GetTransactionFromPending", protowire.AppendBytes(request, txid))
+}
+
+// tronMessage is an encoded Wallet API message, passed through wireCodec as is
+type tronMessage []byte
+
+func (m *tronMessage) marshalWire() []byte {
+	return *m
+}
+
+func (m *tronMessage) unmarshalWire(b []byte) error {
+	*m = append((*m)[:0], b...)
+	return nil
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Retry delays for a transactional batch that failed to commit
+const (
+	txnRetryBase = 100 * time.Millisecond
+	txnRetryMax  = 10 * time.Second
+)
+
+// Delivery modes
+const (
+	DeliveryAtLeastOnce = "at_least_once"
+	DeliveryExactlyOnce = "exactly_once"
+)
+
+var (
+	txnBatches = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_kafka_txn_batches_total",
+			Help: "Transactional micro-batches by outcome",
+		},
+		[]string{"chain", "result"},
+	)
+
+	txnBatchSize = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "scorpius_kafka_txn_batch_size",
+			Help:    "Messages per committed transactional micro-batch",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 14),
+		},
+		[]string{"chain"},
+	)
+)
+
+// txnBatcher groups messages into time-windowed micro-batches that are
+// committed atomically with Kafka transactions. Consumers reading with
+// isolation.level=read_committed see each batch exactly once, even if the
+// process crashes mid-batch: the next instance with the same transactional.id
+// fences the old producer and its open transaction is aborted.
+type txnBatcher struct {
+	chainName string
+	brokers   string
+	txnID     string
+	tuning    KafkaConfig
+	window    time.Duration
+	maxBatch  int
+	producer  *kafka.Producer
+	queue     chan *kafka.Message
+	closing   chan struct{}
+	stop      chan struct{}
+	stopOnce  sync.Once
+	done      chan struct{}
+	mu        sync.RWMutex
+	closed    bool
+
+	// commitBatch commits each batch run collects; tests replace commit
+	commitBatch func(batch []*kafka.Message)
+}
+
+// newTxnBatcher creates a transactional producer and starts its batching
+// loop. A micro-batch holds up to the tuning's batch message count.
+func newTxnBatcher(chainName, brokers, txnID string, tuning KafkaConfig, window time.Duration) (*txnBatcher, error) {
+	if window <= 0 {
+		window = 50 * time.Millisecond
+	}
+	maxBatch := tuning.BatchMessages
+	if maxBatch <= 0 {
+		maxBatch = 10000
+	}
+
+	b := &txnBatcher{
+		chainName: chainName,
+		brokers:   brokers,
+		txnID:     txnID,
+		tuning:    tuning,
+		window:    window,
+		maxBatch:  maxBatch,
+		queue:     make(chan *kafka.Message, maxBatch),
+		closing:   make(chan struct{}),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	b.commitBatch = b.commit
+	if err := b.initProducer(); err != nil {
+		return nil, err
+	}
+
+	go b.run()
+	return b, nil
+}
+
+// initProducer (re)creates the transactional producer and registers its transactional.id
+func (b *txnBatcher) initProducer() error {
+	// Batches are committed every window, so lingering longer than a few
+	// milliseconds would only delay the commit
+	config := kafkaProducerConfig(b.brokers, b.tuning)
+	config.SetKey("linger.ms", min(b.tuning.LingerMS, 5))
+	config.SetKey("transactional.id", b.txnID)
+	config.SetKey("enable.idempotence", true)
+	config.SetKey("go.delivery.reports", false)
+	producer, err := kafka.NewProducer(config)
+	if err != nil {
+		return fmt.Errorf("failed to create transactional producer: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := producer.InitTransactions(ctx); err != nil {
+		producer.Close()
+		return fmt.Errorf("failed to init transactions for %s: %v", b.txnID, err)
+	}
+
+	b.producer = producer
+	return nil
+}
+
+// Enqueue adds a message to the current micro-batch, blocking when the batch
+// buffer is full until there is room or the batcher is stopped. The read lock
+// is held across the send so Close cannot start the final commit while a
+// message is on its way into the buffer.
+func (b *txnBatcher) Enqueue(msg *kafka.Message) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return fmt.Errorf("transactional batcher for %s is closed", b.chainName)
+	}
+
+	select {
+	case b.queue <- msg:
+		return nil
+	case <-b.stop:
+		return fmt.Errorf("transactional batcher for %s is stopped", b.chainName)
+	}
+}
+
+// run collects messages for each window and commits them as one transaction
+func (b *txnBatcher) run() {
+	defer close(b.done)
+
+	batch := make([]*kafka.Message, 0, b.maxBatch)
+	ticker := time.NewTicker(b.window)
+	defer ticker.Stop()
+
+	defer func() {
+		if b.producer != nil {
+			b.producer.Close()
+		}
+	}()
+
+	for {
+		select {
+		case msg := <-b.queue:
+			batch = append(batch, msg)
+			if len(batch) >= b.maxBatch {
+				b.commitBatch(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				b.commitBatch(batch)
+				batch = batch[:0]
+			}
+		case <-b.closing:
+			// Commit whatever is buffered, unless stopped first
+			for len(b.queue) > 0 {
+				batch = append(batch, <-b.queue)
+				if len(batch) >= b.maxBatch {
+					b.commitBatch(batch)
+					batch = batch[:0]
+				}
+			}
+			b.commitBatch(batch)
+			return
+		case <-b.stop:
+			for len(b.queue) > 0 {
+				batch = append(batch, <-b.queue)
+			}
+			if len(batch) > 0 {
+				txnBatches.WithLabelValues(b.chainName, "dropped").Inc()
+				slog.Error("dropping transactional messages on stop", "chain", b.chainName, "messages", len(batch))
+				resolveDeliveries(batch, false)
+			}
+			return
+		}
+	}
+}
+
+// commit produces batch inside a transaction, retrying with backoff until it
+// commits or the batcher is stopped. The transactions' dedup claims are
+// already kept, so a batch is only dropped on stop: while it is retried
+// Enqueue blocks once the buffer fills, pushing back on the publish workers
+// instead.
+func (b *txnBatcher) commit(batch []*kafka.Message) {
+	if len(batch) == 0 {
+		return
+	}
+
+	delay := txnRetryBase
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(delay):
+			case <-b.stop:
+				txnBatches.WithLabelValues(b.chainName, "dropped").Inc()
+				slog.Error("dropping transactional batch on stop", "chain", b.chainName, "messages", len(batch), "attempts", attempt-1)
+				resolveDeliveries(batch, false)
+				return
+			}
+			delay = min(delay*2, txnRetryMax)
+		}
+
+		// A producer closed after a fatal error is recreated before retrying
+		if b.producer == nil {
+			if err := b.initProducer(); err != nil {
+				slog.Error("failed to recreate transactional producer", "chain", b.chainName, "attempt", attempt, "error", err)
+				continue
+			}
+		}
+
+		err := b.commitOnce(batch)
+		if err == nil {
+			txnBatches.WithLabelValues(b.chainName, "committed").Inc()
+			txnBatchSize.WithLabelValues(b.chainName).Observe(float64(len(batch)))
+			resolveDeliveries(batch, true)
+			return
+		}
+
+		slog.Warn("transactional batch failed", "chain", b.chainName, "attempt", attempt, "messages", len(batch), "error", err)
+
+		if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.IsFatal() {
+			txnBatches.WithLabelValues(b.chainName, "fatal").Inc()
+			b.producer.Close()
+			b.producer = nil
+			continue
+		}
+
+		txnBatches.WithLabelValues(b.chainName, "aborted").Inc()
+	}
+}
+
+func (b *txnBatcher) commitOnce(batch []*kafka.Message) error {
+	if err := b.producer.BeginTransaction(); err != nil {
+		return err
+	}
+
+	for _, msg := range batch {
+		if err := b.producer.Produce(msg, nil); err != nil {
+			b.abort()
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// A retriable commit error leaves the transaction open to be committed
+	// again after a backoff; any other non-fatal error leaves it to be
+	// aborted, so the next attempt can begin a new one
+	delay := txnRetryBase
+	for {
+		err := b.producer.CommitTransaction(ctx)
+		if err == nil {
+			return nil
+		}
+		kafkaErr, ok := err.(kafka.Error)
+		if ok && kafkaErr.IsRetriable() && ctx.Err() == nil {
+			select {
+			case <-time.After(delay):
+				delay = min(delay*2, txnRetryMax)
+				continue
+			case <-ctx.Done():
+			}
+		}
+		if !ok || !kafkaErr.IsFatal() {
+			b.abort()
+		}
+		return err
+	}
+}
+
+func (b *txnBatcher) abort() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := b.producer.AbortTransaction(ctx); err != nil {
+		slog.Error("failed to abort transaction", "chain", b.chainName, "error", err)
+	}
+}
+
+// Name returns the sink name
+func (b *txnBatcher) Name() string {
+	return "kafka_transactional"
+}
+
+// Publish adds a message to the current micro-batch. The transaction's SLO
+// delivery waits for the batch to commit.
+func (b *txnBatcher) Publish(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
+	msg := newKafkaMessage(topic, key, value, headers)
+	delivery := sloDeliveryFrom(ctx)
+	if delivery != nil {
+		delivery.Defer()
+		msg.Opaque = delivery
+	}
+	if err := b.Enqueue(msg); err != nil {
+		delivery.Failed()
+		return err
+	}
+	return nil
+}
+
+// resolveDeliveries completes the SLO deliveries of a batch's messages once
+// it commits or is dropped
+func resolveDeliveries(batch []*kafka.Message, committed bool) {
+	for _, msg := range batch {
+		delivery, _ := msg.Opaque.(*sloDelivery)
+		if committed {
+			delivery.Delivered()
+		} else {
+			delivery.Failed()
+		}
+	}
+}
+
+// routedDeliverySink publishes the topics selected for exactly-once delivery
+// through a chain's transactional batcher and every other topic through the
+// regular at-least-once sink
+type routedDeliverySink struct {
+	sink    Sink
+	batcher *txnBatcher
+	topics  map[string]bool
+}
+
+// newRoutedDeliverySink expands the selected topic templates for a chain
+func newRoutedDeliverySink(sink Sink, batcher *txnBatcher, templates []string, chain string, chainID int64, family string) *routedDeliverySink {
+	topics := make(map[string]bool, len(templates))
+	for _, template := range templates {
+		topics[expandTopic(template, chain, chainID, family)] = true
+	}
+	return &routedDeliverySink{sink: sink, batcher: batcher, topics: topics}
+}
+
+// Name returns the wrapped sink's name
+func (s *routedDeliverySink) Name() string {
+	return s.sink.Name()
+}
+
+// Ping reports the wrapped sink's health
+func (s *routedDeliverySink) Ping(ctx context.Context) error {
+	if p, ok := s.sink.(pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// Publish sends the message to the batcher if its topic was selected for
+// exactly-once delivery, or to the regular sink otherwise
+func (s *routedDeliverySink) Publish(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
+	if s.topics[topic] {
+		return s.batcher.Publish(ctx, topic, key, value, headers)
+	}
+	return s.sink.Publish(ctx, topic, key, value, headers)
+}
+
+// Close is a no-op: the sink is shared and the batcher is closed with its chain
+func (s *routedDeliverySink) Close() {}
+
+// Close commits any buffered messages and closes the producer. With the
+// brokers down it waits until Stop is called, which also releases Enqueue
+// calls blocked on a full buffer.
+func (b *txnBatcher) Close() {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.closing)
+	}
+	b.mu.Unlock()
+
+	<-b.done
+}
+
+// Stop abandons the batch being retried and any messages still buffered,
+// and fails blocked and later calls to Enqueue. It bounds a drain or Close
+// while the brokers are down.
+func (b *txnBatcher) Stop() {
+	b.stopOnce.Do(func() { close(b.stop) })
+}
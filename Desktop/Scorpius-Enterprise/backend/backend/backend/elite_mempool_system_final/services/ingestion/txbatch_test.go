@@ -0,0 +1,126 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// newTestTxnBatcher returns a batcher running its real loop without a
+// producer. Batches are collected instead of committed, and sent once run
+// returns.
+func newTestTxnBatcher(capacity int) (*txnBatcher, <-chan []*kafka.Message) {
+	b := newIdleTestTxnBatcher(capacity)
+	return b, runTestTxnBatcher(b)
+}
+
+// newIdleTestTxnBatcher returns a batcher whose loop is not started yet
+func newIdleTestTxnBatcher(capacity int) *txnBatcher {
+	return &txnBatcher{
+		chainName: "ethereum",
+		window:    time.Hour,
+		maxBatch:  capacity,
+		queue:     make(chan *kafka.Message, capacity),
+		closing:   make(chan struct{}),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// runTestTxnBatcher starts b's loop, collecting its batches
+func runTestTxnBatcher(b *txnBatcher) <-chan []*kafka.Message {
+	var committed []*kafka.Message
+	b.commitBatch = func(batch []*kafka.Message) {
+		committed = append(committed, batch...)
+	}
+	go b.run()
+
+	drained := make(chan []*kafka.Message, 1)
+	go func() {
+		<-b.done
+		drained <- committed
+	}()
+	return drained
+}
+
+func TestTxnBatcherCloseKeepsAcceptedMessages(t *testing.T) {
+	for i := 0; i < 500; i++ {
+		b, drained := newTestTxnBatcher(4)
+		msg := &kafka.Message{Value: []byte("tx")}
+
+		var wg sync.WaitGroup
+		var err error
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			err = b.Enqueue(msg)
+		}()
+		go func() {
+			defer wg.Done()
+			b.Close()
+		}()
+		wg.Wait()
+
+		batch := <-drained
+		if err == nil && len(batch) != 1 {
+			t.Fatalf("iteration %d: Enqueue accepted the message but the final commit drained %d", i, len(batch))
+		}
+		if err != nil && len(batch) != 0 {
+			t.Fatalf("iteration %d: Enqueue failed (%v) but the final commit drained %d", i, err, len(batch))
+		}
+	}
+}
+
+func TestTxnBatcherCloseCommitsBufferedMessages(t *testing.T) {
+	// Messages still buffered when the loop sees Close go into the final commit
+	b := newIdleTestTxnBatcher(32)
+	for i := 0; i < 16; i++ {
+		if err := b.Enqueue(&kafka.Message{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	drained := runTestTxnBatcher(b)
+	b.Close()
+
+	if batch := <-drained; len(batch) != 16 {
+		t.Fatalf("final commit drained %d of 16 buffered messages", len(batch))
+	}
+}
+
+func TestTxnBatcherEnqueueAfterClose(t *testing.T) {
+	b, drained := newTestTxnBatcher(4)
+	b.Close()
+	<-drained
+
+	if err := b.Enqueue(&kafka.Message{}); err == nil {
+		t.Fatal("Enqueue after Close succeeded")
+	}
+	if len(b.queue) != 0 {
+		t.Errorf("%d messages left in the buffer after Close", len(b.queue))
+	}
+}
+
+func TestTxnBatcherStopReleasesBlockedEnqueue(t *testing.T) {
+	b, drained := newTestTxnBatcher(1)
+	if err := b.Enqueue(&kafka.Message{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The buffer is full, so this Enqueue blocks and holds up Close
+	result := make(chan error, 1)
+	go func() { result <- b.Enqueue(&kafka.Message{}) }()
+	closed := make(chan struct{})
+	go func() {
+		b.Close()
+		close(closed)
+	}()
+
+	b.Stop()
+	if err := <-result; err == nil {
+		t.Error("blocked Enqueue succeeded after Stop")
+	}
+	<-closed
+	<-drained
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var cacheBatchSize = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "scorpius_redis_cache_batch_size",
+		Help:    "Transactions written to the transaction cache per batch",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	},
+	[]string{"chain"},
+)
+
+// cachedTx is a transaction waiting to be written to the cache, with what
+// its index entries need
+type cachedTx struct {
+	key       string
+	data      []byte
+	hash      string
+	addresses []string
+	timestamp int64
+	block     *int64
+}
+
+// txCache writes published transactions to the cache (tx:<chain>:<hash>)
+// for quick lookups. With a batch size above one, writes are buffered and
+// sent together, in one pipeline on Redis, when the batch fills or the flush
+// interval passes, so a cached transaction may appear up to an interval
+// after publishing.
+type txCache struct {
+	chainName string
+	cache     Cache
+	redis     *redis.Client
+	ttl       time.Duration
+	batchSize int
+	indexes   bool
+	indexMax  int
+	indexTTL  time.Duration
+	logger    *slog.Logger
+	mu        sync.Mutex
+	pending   []cachedTx
+	closed    bool
+}
+
+// newTxCache creates a chain's cache writer. Buffered writes are flushed
+// when ctx is cancelled.
+func newTxCache(ctx context.Context, chainName string, cache Cache, options ChainOptions, logger *slog.Logger) *txCache {
+	c := &txCache{
+		chainName: chainName,
+		cache:     cache,
+		ttl:       options.CacheTTL,
+		batchSize: options.CacheBatch,
+		indexes:   options.Indexes,
+		indexMax:  options.IndexMax,
+		indexTTL:  options.IndexTTL,
+		logger:    logger,
+	}
+	// Indexes are Redis sorted sets and sets, so need the Redis backend
+	if rc, ok := cache.(*redisCache); ok {
+		c.redis = rc.client
+	} else {
+		c.indexes = false
+	}
+	if c.batchSize > 1 {
+		c.pending = make([]cachedTx, 0, c.batchSize)
+		go c.run(ctx, options.CacheFlush)
+	}
+	return c
+}
+
+func cacheKey(chainName, hash string) string {
+	return fmt.Sprintf("tx:%s:%s", chainName, hash)
+}
+
+// Write caches tx, or queues it for the next pipeline
+func (c *txCache) Write(ctx context.Context, tx *Transaction) error {
+	// Without a cache there is nothing to marshal for
+	if c.cache.Name() == CacheNone {
+		return nil
+	}
+
+	data, err := json.Marshal(tx)
+	if err != nil {
+		return err
+	}
+	entry := cachedTx{key: cacheKey(c.chainName, tx.Hash), data: data}
+	if c.indexes {
+		entry.hash = tx.Hash
+		entry.timestamp = tx.Timestamp
+		entry.block = tx.BlockNumber
+		for _, address := range []string{tx.From, tx.To} {
+			if address != "" {
+				entry.addresses = append(entry.addresses, address)
+			}
+		}
+	}
+
+	c.mu.Lock()
+	if c.batchSize <= 1 || c.closed {
+		c.mu.Unlock()
+		return c.flush(ctx, []cachedTx{entry})
+	}
+	c.pending = append(c.pending, entry)
+	var batch []cachedTx
+	if len(c.pending) >= c.batchSize {
+		batch = c.take()
+	}
+	c.mu.Unlock()
+
+	// The worker that fills a batch writes it
+	if batch != nil {
+		return c.flush(ctx, batch)
+	}
+	return nil
+}
+
+// take swaps out the pending writes; the caller holds c.mu
+func (c *txCache) take() []cachedTx {
+	if len(c.pending) == 0 {
+		return nil
+	}
+	batch := c.pending
+	c.pending = make([]cachedTx, 0, c.batchSize)
+	return batch
+}
+
+// run flushes partial batches every interval until ctx is cancelled, then
+// flushes what is left and writes through from then on
+func (c *txCache) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.mu.Lock()
+			c.closed = true
+			batch := c.take()
+			c.mu.Unlock()
+			c.flushLogged(context.Background(), batch)
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			batch := c.take()
+			c.mu.Unlock()
+			c.flushLogged(ctx, batch)
+		}
+	}
+}
+
+func (c *txCache) flushLogged(ctx context.Context, batch []cachedTx) {
+	if err := c.flush(ctx, batch); err != nil {
+		c.logger.Warn("failed to cache transactions", "count", len(batch), "error", err)
+	}
+}
+
+// flush writes batch in one round trip
+func (c *txCache) flush(ctx context.Context, batch []cachedTx) (err error) {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	ctx, span := tracer.Start(ctx, c.cache.Name()+".cache", trace.WithSpanKind(trace.SpanKindClient))
+	span.SetAttributes(attribute.Int("batch_size", len(batch)))
+	defer func() { endSpan(span, err) }()
+	defer func(start time.Time) {
+		cacheLatency.WithLabelValues(c.chainName).Observe(time.Since(start).Seconds())
+	}(time.Now())
+	cacheBatchSize.WithLabelValues(c.chainName).Observe(float64(len(batch)))
+
+	if c.indexes {
+		// Entries and their index updates share one pipeline
+		pipe := c.redis.Pipeline()
+		for _, entry := range batch {
+			pipe.Set(ctx, entry.key, entry.data, c.ttl)
+			c.indexTx(ctx, pipe, entry)
+		}
+		_, err = pipe.Exec(ctx)
+		return err
+	}
+	if len(batch) == 1 {
+		return c.cache.Set(ctx, batch[0].key, batch[0].data, c.ttl)
+	}
+	entries := make([]cacheEntry, len(batch))
+	for i, entry := range batch {
+		entries[i] = cacheEntry{key: entry.key, value: entry.data}
+	}
+	return c.cache.SetMany(ctx, entries, c.ttl)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Secondary indexes over the transaction cache, kept when <CHAIN>_CACHE_INDEXES
+// is set:
+//
+//   - addr:<chain>:<address> is a sorted set of the hashes of transactions
+//     sent from or to the address, scored by timestamp and trimmed to the
+//     newest IndexMax
+//   - block:<chain>:<number> is the set of hashes included in a block, from
+//     confirmed transactions and, with block tracking, every confirmed block
+//
+// Both expire IndexTTL after their last update. An index can name
+// transactions whose tx: entry has already expired; lookups skip them.
+
+func addressIndexKey(chainName, address string) string {
+	return fmt.Sprintf("addr:%s:%s", chainName, strings.ToLower(address))
+}
+
+func blockIndexKey(chainName string, number uint64) string {
+	return fmt.Sprintf("block:%s:%d", chainName, number)
+}
+
+// indexTx queues entry's index updates on pipe
+func (c *txCache) indexTx(ctx context.Context, pipe redis.Pipeliner, entry cachedTx) {
+	for _, address := range entry.addresses {
+		key := addressIndexKey(c.chainName, address)
+		pipe.ZAdd(ctx, key, redis.Z{Score: float64(entry.timestamp), Member: entry.hash})
+		pipe.ZRemRangeByRank(ctx, key, 0, -int64(c.indexMax)-1)
+		pipe.Expire(ctx, key, c.indexTTL)
+	}
+	if entry.block != nil {
+		key := blockIndexKey(c.chainName, uint64(*entry.block))
+		pipe.SAdd(ctx, key, entry.hash)
+		pipe.Expire(ctx, key, c.indexTTL)
+	}
+}
+
+// IndexBlock is a blockHandler recording a confirmed block's transactions
+func (c *txCache) IndexBlock(block *confirmedBlock) {
+	if len(block.Transactions) == 0 {
+		return
+	}
+
+	hashes := make([]interface{}, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		hashes[i] = tx.Hash
+	}
+	key := blockIndexKey(c.chainName, block.Number)
+
+	pipe := c.redis.Pipeline()
+	pipe.SAdd(context.Background(), key, hashes...)
+	pipe.Expire(context.Background(), key, c.indexTTL)
+	if _, err := pipe.Exec(context.Background()); err != nil {
+		c.logger.Warn("failed to index block transactions", "block", block.Number, "error", err)
+	}
+}
+
+// indexedTxs returns the cached transactions an index lists, skipping expired ones
+func indexedTxs(ctx context.Context, redisClient *redis.Client, chainName string, hashes []string) ([]json.RawMessage, error) {
+	transactions := []json.RawMessage{}
+	if len(hashes) == 0 {
+		return transactions, nil
+	}
+
+	keys := make([]string, len(hashes))
+	for i, hash := range hashes {
+		keys[i] = cacheKey(chainName, hash)
+	}
+	values, err := redisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		if data, ok := value.(string); ok {
+			transactions = append(transactions, json.RawMessage(data))
+		}
+	}
+	return transactions, nil
+}
+
+// registerTxIndexAPI mounts the cached transaction lookup endpoint:
+// GET /api/txs?chain=&address=[&limit=] for an address's recent transactions,
+// newest first, or GET /api/txs?chain=&block= for a block's.
+func (is *IngestionService) registerTxIndexAPI(mux *http.ServeMux) {
+	mux.HandleFunc("/api/txs", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+
+		query := r.URL.Query()
+		chain := query.Get("chain")
+		options, ok := is.config.ChainOptions[chain]
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "chain must be a monitored chain"})
+			return
+		}
+		if !options.Indexes {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "transaction indexes are not enabled for " + chain})
+			return
+		}
+
+		var hashes []string
+		var err error
+		switch {
+		case query.Get("address") != "":
+			limit := options.IndexMax
+			if requested, parseErr := strconv.Atoi(query.Get("limit")); parseErr == nil && requested > 0 && requested < limit {
+				limit = requested
+			}
+			hashes, err = is.redis.ZRevRange(r.Context(), addressIndexKey(chain, query.Get("address")), 0, int64(limit)-1).Result()
+		case query.Get("block") != "":
+			number, parseErr := strconv.ParseUint(query.Get("block"), 10, 64)
+			if parseErr != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "block must be a block number"})
+				return
+			}
+			hashes, err = is.redis.SMembers(r.Context(), blockIndexKey(chain, number)).Result()
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "address or block is required"})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		transactions, err := indexedTxs(r.Context(), is.redis, chain, hashes)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"chain": chain, "transactions": transactions})
+	})
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ERC-4337 EntryPoint versions
+const (
+	EntryPointV06 = "v0.6"
+	EntryPointV07 = "v0.7"
+	EntryPointV08 = "v0.8"
+)
+
+// entryPoints maps the canonical EntryPoint deployments to their versions
+var entryPoints = map[string]string{
+	"0x5ff137d4b0fdcd49dca30c7cf57e578a026d2789": EntryPointV06,
+	"0x0000000071727de22e5e9d8baf0edac6f37da032": EntryPointV07,
+	"0x4337084d9e255ff0702461cf8895ce9e3b5ff108": EntryPointV08,
+}
+
+// Smart account call selectors decoded for the call target
+const (
+	// execute(address,uint256,bytes), used by SimpleAccount and most accounts derived from it
+	accountExecuteSelector = "b61d27f6"
+	// executeBatch(address[],bytes[])
+	accountExecuteBatchSelector = "18dfb3c7"
+)
+
+var (
+	// v0.8 hashes user operations as EIP-712 typed data
+	packedUserOpTypeHash = crypto.Keccak256([]byte("PackedUserOperation(address sender,uint256 nonce,bytes initCode,bytes callData,bytes32 accountGasLimits,uint256 preVerificationGas,bytes32 gasFees,bytes paymasterAndData)"))
+	eip712DomainTypeHash = crypto.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+)
+
+var userOps = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_userops_total",
+		Help: "Pending ERC-4337 user operations published, by EntryPoint version and status",
+	},
+	[]string{"chain", "entry_point", "status"},
+)
+
+// UserOpConfig configures user operation ingestion from bundlers
+type UserOpConfig struct {
+	Topic string
+	Poll  time.Duration
+}
+
+// loadUserOpConfig reads USEROPS_* settings
+func loadUserOpConfig() UserOpConfig {
+	return UserOpConfig{
+		Topic: getEnvOrDefault("USEROPS_TOPIC", "userops"),
+		Poll:  getEnvDuration("USEROPS_POLL_INTERVAL", time.Second),
+	}
+}
+
+// rpcUserOp is a user operation as bundler RPCs return it: the v0.6 fields,
+// or the unpacked v0.7 and later fields
+type rpcUserOp struct {
+	Sender               string `json:"sender"`
+	Nonce                string `json:"nonce"`
+	InitCode             string `json:"initCode"`
+	Factory              string `json:"factory"`
+	FactoryData          string `json:"factoryData"`
+	CallData             string `json:"callData"`
+	CallGasLimit         string `json:"callGasLimit"`
+	VerificationGasLimit string `json:"verificationGasLimit"`
+	PreVerificationGas   string `json:"preVerificationGas"`
+	MaxFeePerGas         string `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas string `json:"maxPriorityFeePerGas"`
+	PaymasterAndData     string `json:"paymasterAndData"`
+	Paymaster            string `json:"paymaster"`
+	PaymasterVerifyGas   string `json:"paymasterVerificationGasLimit"`
+	PaymasterPostOpGas   string `json:"paymasterPostOpGasLimit"`
+	PaymasterData        string `json:"paymasterData"`
+	Signature            string `json:"signature"`
+}
+
+// UserOperation is published to the userops topic for each pending user
+// operation. Gas values are decimal; Targets are the contracts the account
+// calls, when its calldata is a recognised execute call.
+type UserOperation struct {
+	Chain                string    `json:"chain"`
+	ChainID              int64     `json:"chain_id"`
+	Hash                 string    `json:"hash,omitempty"`
+	EntryPoint           string    `json:"entry_point"`
+	EntryPointVersion    string    `json:"entry_point_version,omitempty"`
+	Sender               string    `json:"sender"`
+	Nonce                string    `json:"nonce"`
+	Factory              string    `json:"factory,omitempty"`
+	Paymaster            string    `json:"paymaster,omitempty"`
+	CallData             string    `json:"call_data"`
+	Selector             string    `json:"selector,omitempty"`
+	Targets              []string  `json:"targets,omitempty"`
+	Value                string    `json:"value,omitempty"`
+	CallGasLimit         string    `json:"call_gas_limit"`
+	VerificationGasLimit string    `json:"verification_gas_limit"`
+	PreVerificationGas   string    `json:"pre_verification_gas"`
+	MaxFeePerGas         string    `json:"max_fee_per_gas"`
+	MaxPriorityFeePerGas string    `json:"max_priority_fee_per_gas"`
+	Bundler              string    `json:"bundler"`
+	Status               string    `json:"status"`
+	DetectedAt           time.Time `json:"detected_at"`
+}
+
+// userOpSource polls the mempools of the ERC-4337 bundlers at
+// <CHAIN>_BUNDLER_URLS and publishes each user operation the first time any
+// of them holds it. User operations reach the chain inside a bundler's
+// handleOps transaction, so the public mempool never sees them on their
+// own. Bundlers are read through debug_bundler_dumpMempool, for every
+// EntryPoint they report through eth_supportedEntryPoints; bundlers that
+// do not expose the debug namespace are logged and skipped.
+type userOpSource struct {
+	monitor  *ChainMonitor
+	bundlers []*rpcClient
+	topic    string
+	poll     time.Duration
+}
+
+func newUserOpSource(monitor *ChainMonitor, config UserOpConfig) *userOpSource {
+	s := &userOpSource{monitor: monitor, topic: config.Topic, poll: config.Poll}
+	for _, url := range monitor.options.BundlerURLs {
+		registerSecrets(url)
+		s.bundlers = append(s.bundlers, newRPCClient(url, 10*time.Second))
+	}
+	return s
+}
+
+// startUserOps polls the bundlers when <CHAIN>_BUNDLER_URLS is set
+func (cm *ChainMonitor) startUserOps() {
+	if cm.userOps != nil {
+		go cm.userOps.run()
+	}
+}
+
+// run polls every bundler until the monitor stops
+func (s *userOpSource) run() {
+	cm := s.monitor
+	ticker := time.NewTicker(s.poll)
+	defer ticker.Stop()
+
+	seen := make(map[string]bool)
+	for {
+		current := make(map[string]bool, len(seen))
+		failed := false
+		for i, bundler := range s.bundlers {
+			if err := s.pollBundler(cm.ctx, bundler, cm.options.BundlerURLs[i], seen, current); err != nil && cm.ctx.Err() == nil {
+				failed = true
+				cm.logger.Warn("failed to read bundler mempool", "endpoint", displayEndpoint(cm.options.BundlerURLs[i]), "error", err)
+			}
+		}
+		// Operations still pending stay seen; bundled or dropped ones are
+		// forgotten, unless a bundler that may still hold them failed to answer
+		if failed {
+			for key := range seen {
+				current[key] = true
+			}
+		}
+		seen = current
+
+		select {
+		case <-cm.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollBundler publishes the user operations in a bundler's mempool that are not in seen
+func (s *userOpSource) pollBundler(ctx context.Context, bundler *rpcClient, url string, seen, current map[string]bool) error {
+	cm := s.monitor
+	var supported []string
+	if err := bundler.Call(ctx, "eth_supportedEntryPoints", []interface{}{}, &supported); err != nil {
+		return err
+	}
+
+	for _, entryPoint := range supported {
+		entryPoint = strings.ToLower(entryPoint)
+		var ops []rpcUserOp
+		if err := bundler.Call(ctx, "debug_bundler_dumpMempool", []interface{}{entryPoint}, &ops); err != nil {
+			return err
+		}
+		for _, raw := range ops {
+			op := decodeUserOp(raw, entryPoint, cm.chainID)
+			key := op.Hash
+			if key == "" {
+				key = op.Sender + ":" + op.Nonce
+			}
+			key = entryPoint + ":" + key
+			if current[key] {
+				continue
+			}
+			current[key] = true
+			if seen[key] {
+				continue
+			}
+
+			op.Chain = cm.chainName
+			op.ChainID = cm.chainID
+			op.Bundler = endpointLabel(url)
+			op.Status = "pending"
+			op.DetectedAt = time.Now()
+			s.publish(op)
+		}
+	}
+	return nil
+}
+
+// publish produces a user operation to the userops topic
+func (s *userOpSource) publish(op UserOperation) {
+	cm := s.monitor
+	version := op.EntryPointVersion
+	if version == "" {
+		version = "unknown"
+	}
+
+	data, err := json.Marshal(op)
+	if err != nil {
+		cm.logger.Error("failed to marshal user operation", "sender", op.Sender, "error", err)
+		return
+	}
+
+	key := op.Hash
+	if key == "" {
+		key = op.Sender
+	}
+	topic := expandTopic(s.topic, op.Chain, op.ChainID, cm.family)
+	headers := map[string]string{
+		"chain_id":   fmt.Sprintf("%d", op.ChainID),
+		"chain_name": op.Chain,
+		"format":     FormatJSON,
+	}
+	if err := cm.sink.Publish(cm.ctx, topic, []byte(key), data, headers); err != nil {
+		userOps.WithLabelValues(cm.chainName, version, "failed").Inc()
+		cm.logger.Warn("failed to publish user operation", "userop_hash", op.Hash, "error", err)
+		return
+	}
+	userOps.WithLabelValues(cm.chainName, version, "success").Inc()
+}
+
+// decodeUserOp normalises a bundler's user operation, computing its hash
+// for the canonical EntryPoints
+func decodeUserOp(raw rpcUserOp, entryPoint string, chainID int64) UserOperation {
+	op := UserOperation{
+		EntryPoint:           entryPoint,
+		EntryPointVersion:    entryPoints[entryPoint],
+		Sender:               strings.ToLower(raw.Sender),
+		Nonce:                hexToBig(raw.Nonce).String(),
+		CallData:             strings.ToLower(raw.CallData),
+		CallGasLimit:         hexToBig(raw.CallGasLimit).String(),
+		VerificationGasLimit: hexToBig(raw.VerificationGasLimit).String(),
+		PreVerificationGas:   hexToBig(raw.PreVerificationGas).String(),
+		MaxFeePerGas:         hexToBig(raw.MaxFeePerGas).String(),
+		MaxPriorityFeePerGas: hexToBig(raw.MaxPriorityFeePerGas).String(),
+	}
+
+	// v0.7 and later split initCode and paymasterAndData into their parts
+	initCode := common.FromHex(raw.InitCode)
+	if raw.Factory != "" {
+		initCode = append(common.FromHex(raw.Factory), common.FromHex(raw.FactoryData)...)
+	}
+	paymasterAndData := common.FromHex(raw.PaymasterAndData)
+	if raw.Paymaster != "" {
+		paymasterAndData = common.FromHex(raw.Paymaster)
+		paymasterAndData = append(paymasterAndData, common.LeftPadBytes(hexToBig(raw.PaymasterVerifyGas).Bytes(), 16)...)
+		paymasterAndData = append(paymasterAndData, common.LeftPadBytes(hexToBig(raw.PaymasterPostOpGas).Bytes(), 16)...)
+		paymasterAndData = append(paymasterAndData, common.FromHex(raw.PaymasterData)...)
+	}
+	if len(initCode) >= common.AddressLength {
+		op.Factory = strings.ToLower(common.BytesToAddress(initCode[:common.AddressLength]).Hex())
+	}
+	if len(paymasterAndData) >= common.AddressLength {
+		op.Paymaster = strings.ToLower(common.BytesToAddress(paymasterAndData[:common.AddressLength]).Hex())
+	}
+
+	op.Selector, op.Targets, op.Value = decodeAccountCall(op.CallData)
+	if hash := userOpHash(raw, op.EntryPointVersion, initCode, paymasterAndData, entryPoint, chainID); hash != nil {
+		op.Hash = "0x" + common.Bytes2Hex(hash)
+	}
+	return op
+}
+
+// userOpHash computes the hash the EntryPoint assigns a user operation, as
+// returned by getUserOpHash, or nil for EntryPoints of unknown version
+func userOpHash(raw rpcUserOp, version string, initCode, paymasterAndData []byte, entryPoint string, chainID int64) []byte {
+	word := func(n *big.Int) []byte {
+		return common.LeftPadBytes(n.Bytes(), 32)
+	}
+	// pack128 packs two 128-bit values into one word, high first
+	pack128 := func(high, low string) []byte {
+		return append(common.LeftPadBytes(hexToBig(high).Bytes(), 16), common.LeftPadBytes(hexToBig(low).Bytes(), 16)...)
+	}
+
+	var packed []byte
+	switch version {
+	case EntryPointV06:
+		packed = concatBytes(
+			common.LeftPadBytes(common.FromHex(raw.Sender), 32),
+			word(hexToBig(raw.Nonce)),
+			crypto.Keccak256(initCode),
+			crypto.Keccak256(common.FromHex(raw.CallData)),
+			word(hexToBig(raw.CallGasLimit)),
+			word(hexToBig(raw.VerificationGasLimit)),
+			word(hexToBig(raw.PreVerificationGas)),
+			word(hexToBig(raw.MaxFeePerGas)),
+			word(hexToBig(raw.MaxPriorityFeePerGas)),
+			crypto.Keccak256(paymasterAndData),
+		)
+	case EntryPointV07, EntryPointV08:
+		packed = concatBytes(
+			common.LeftPadBytes(common.FromHex(raw.Sender), 32),
+			word(hexToBig(raw.Nonce)),
+			crypto.Keccak256(initCode),
+			crypto.Keccak256(common.FromHex(raw.CallData)),
+			pack128(raw.VerificationGasLimit, raw.CallGasLimit),
+			word(hexToBig(raw.PreVerificationGas)),
+			pack128(raw.MaxPriorityFeePerGas, raw.MaxFeePerGas),
+			crypto.Keccak256(paymasterAndData),
+		)
+	default:
+		return nil
+	}
+
+	entryPointWord := common.LeftPadBytes(common.FromHex(entryPoint), 32)
+	chainIDWord := word(big.NewInt(chainID))
+	if version != EntryPointV08 {
+		return crypto.Keccak256(crypto.Keccak256(packed), entryPointWord, chainIDWord)
+	}
+
+	domain := crypto.Keccak256(
+		eip712DomainTypeHash,
+		crypto.Keccak256([]byte("ERC4337")),
+		crypto.Keccak256([]byte("1")),
+		chainIDWord,
+		entryPointWord,
+	)
+	return crypto.Keccak256([]byte{0x19, 0x01}, domain, crypto.Keccak256(packedUserOpTypeHash, packed))
+}
+
+// decodeAccountCall reads the selector of a smart account call and, for
+// execute and executeBatch, the contracts called and the value sent
+func decodeAccountCall(callData string) (selector string, targets []string, value string) {
+	selector, args, ok := splitCalldata(callData)
+	if !ok {
+		return "", nil, ""
+	}
+
+	switch selector {
+	case accountExecuteSelector:
+		if len(args) >= 2 {
+			targets = []string{abiAddress(args[0])}
+			value = abiUint(args[1])
+		}
+	case accountExecuteBatchSelector:
+		// The first argument is the offset of the address array, in bytes
+		if len(args) < 1 {
+			break
+		}
+		offset, err := strconv.ParseUint(strings.TrimLeft(args[0], "0"), 16, 32)
+		if err != nil || offset%32 != 0 || int(offset/32) >= len(args) {
+			break
+		}
+		start := int(offset / 32)
+		count := int(hexToUint64(args[start]))
+		if count < 0 || count > len(args)-start-1 {
+			break
+		}
+		for _, word := range args[start+1 : start+1+count] {
+			targets = append(targets, abiAddress(word))
+		}
+	}
+	return "0x" + selector, targets, value
+}
+
+// concatBytes joins byte slices
+func concatBytes(parts ...[]byte) []byte {
+	var out []byte
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
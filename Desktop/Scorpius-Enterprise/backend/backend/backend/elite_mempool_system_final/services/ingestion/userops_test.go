@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/hex"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeAccountCall(t *testing.T) {
+	target := "0x1f9840a85d5af5bf1d1762f925bdaddc4201f984"
+
+	tests := []struct {
+		name         string
+		data         []byte
+		wantSelector string
+		wantTargets  []string
+		wantValue    string
+	}{
+		{
+			name:         "execute",
+			data:         abiCall(accountExecuteSelector, abiWords(recipient, 1000, 0x60, 0)),
+			wantSelector: "0x" + accountExecuteSelector,
+			wantTargets:  []string{recipient},
+			wantValue:    "1000",
+		},
+		{
+			name:         "execute batch",
+			data:         abiCall(accountExecuteBatchSelector, abiWords(0x20, 2, recipient, target)),
+			wantSelector: "0x" + accountExecuteBatchSelector,
+			wantTargets:  []string{recipient, target},
+		},
+		{
+			name:         "execute batch with a count past the calldata",
+			data:         abiCall(accountExecuteBatchSelector, abiWords(0x20, 3, recipient)),
+			wantSelector: "0x" + accountExecuteBatchSelector,
+		},
+		{
+			name:         "execute batch with a count that overflows the bounds check",
+			data:         abiCall(accountExecuteBatchSelector, abiWords(0x20, math.MaxInt64)),
+			wantSelector: "0x" + accountExecuteBatchSelector,
+		},
+		{
+			name: "execute batch with a count that wraps negative",
+			data: abiCall(accountExecuteBatchSelector, abiWords(0x20),
+				[]byte(strings.Repeat("\xff", 32))),
+			wantSelector: "0x" + accountExecuteBatchSelector,
+		},
+		{
+			name: "short calldata",
+			data: []byte{0x18, 0xdf},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, targets, value := decodeAccountCall("0x" + hex.EncodeToString(tt.data))
+			if selector != tt.wantSelector {
+				t.Errorf("selector = %q, want %q", selector, tt.wantSelector)
+			}
+			if !reflect.DeepEqual(targets, tt.wantTargets) {
+				t.Errorf("targets = %v, want %v", targets, tt.wantTargets)
+			}
+			if value != tt.wantValue {
+				t.Errorf("value = %q, want %q", value, tt.wantValue)
+			}
+		})
+	}
+}
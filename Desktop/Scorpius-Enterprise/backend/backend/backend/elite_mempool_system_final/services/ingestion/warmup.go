@@ -0,0 +1,70 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	chainWarmup = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_chain_warmup",
+			Help: "Whether a chain is in its cold-start warmup window (1) or not (0)",
+		},
+		[]string{"chain"},
+	)
+
+	alertsSuppressed = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_alerts_suppressed_total",
+			Help: "Transaction alerts suppressed during warmup",
+		},
+		[]string{"chain", "category"},
+	)
+)
+
+// beginWarmup opens the warmup window on the first successful subscription.
+// The initial mempool snapshot a node replays on subscribe is stale, so
+// transaction-derived alerts are held back until it has drained.
+func (cm *ChainMonitor) beginWarmup() {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if !cm.warmupUntil.IsZero() {
+		return
+	}
+
+	cm.warmupUntil = time.Now().Add(cm.options.WarmupDuration)
+	if cm.options.WarmupDuration <= 0 {
+		return
+	}
+
+	chainWarmup.WithLabelValues(cm.chainName).Set(1)
+	cm.logger.Info("in warmup, suppressing transaction alerts", "duration", cm.options.WarmupDuration)
+
+	time.AfterFunc(cm.options.WarmupDuration, func() {
+		chainWarmup.WithLabelValues(cm.chainName).Set(0)
+		cm.logger.Info("warmup complete")
+	})
+}
+
+// inWarmup reports whether transaction alerts are currently suppressed
+func (cm *ChainMonitor) inWarmup() bool {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return cm.warmupUntil.IsZero() || time.Now().Before(cm.warmupUntil)
+}
+
+// raiseTxAlert raises an alert derived from tx, unless tx was delivered while
+// its chain was warming up
+func raiseTxAlert(alerter *Alerter, tx *Transaction, alert Alert) {
+	if tx.warmup {
+		alertsSuppressed.WithLabelValues(tx.Chain, alert.Category).Inc()
+		return
+	}
+
+	alerter.Raise(alert)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// alchemyFilterLimit is the most addresses alchemy_pendingTransactions
+// accepts in each of fromAddress and toAddress
+const alchemyFilterLimit = 1000
+
+var subscriptionFilterAddresses = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "scorpius_subscription_filter_addresses",
+		Help: "Watched addresses the pending transaction subscription is filtered on, 0 when unfiltered",
+	},
+	[]string{"chain"},
+)
+
+// watchlistFilter narrows a chain's pending transaction subscription to
+// transactions from or to a watched address, so the provider drops the rest
+// before they cross the wire. Only Alchemy filters pending transactions
+// server-side, through alchemy_pendingTransactions; endpoints subscribed in
+// any other mode, QuickNode's included, keep streaming everything.
+//
+// The filter follows the watchlist. When its addresses change, the new
+// subscription is opened before the old one is cancelled, so nothing is
+// missed in between; the overlap is deduplicated like any repeat. More
+// addresses than Alchemy accepts fall back to the unfiltered subscription.
+type watchlistFilter struct {
+	monitor   *ChainMonitor
+	watchlist *Watchlist
+	refresh   time.Duration
+
+	mu        sync.Mutex
+	addresses []string
+	// requestID is the JSON-RPC id of the latest subscribe request, and
+	// current the subscription it opened
+	requestID int
+	current   string
+	stale     chan string
+}
+
+func newWatchlistFilter(monitor *ChainMonitor, watchlist *Watchlist, refresh time.Duration) *watchlistFilter {
+	return &watchlistFilter{monitor: monitor, watchlist: watchlist, refresh: refresh}
+}
+
+// begin returns the params of a new connection's subscription, or nil while
+// no address is watched. The subscribe request must use id 1.
+func (f *watchlistFilter) begin() []interface{} {
+	addresses := f.watchlist.evmAddresses()
+
+	f.mu.Lock()
+	f.addresses = addresses
+	f.requestID = 1
+	f.current = ""
+	f.stale = make(chan string, 16)
+	f.mu.Unlock()
+
+	if len(addresses) == 0 {
+		subscriptionFilterAddresses.WithLabelValues(f.monitor.chainName).Set(0)
+		return nil
+	}
+	return f.params(addresses)
+}
+
+// params returns the alchemy_pendingTransactions params filtering on addresses
+func (f *watchlistFilter) params(addresses []string) []interface{} {
+	if len(addresses) > alchemyFilterLimit {
+		f.monitor.logger.Warn("too many watched addresses to filter server-side, subscribing unfiltered", "addresses", len(addresses), "limit", alchemyFilterLimit)
+		subscriptionFilterAddresses.WithLabelValues(f.monitor.chainName).Set(0)
+		return subscriptionParams(SubscriptionAlchemy)
+	}
+	subscriptionFilterAddresses.WithLabelValues(f.monitor.chainName).Set(float64(len(addresses)))
+	return []interface{}{"alchemy_pendingTransactions", map[string]interface{}{
+		"fromAddress": addresses,
+		"toAddress":   addresses,
+		"hashesOnly":  false,
+	}}
+}
+
+// handleResponse records the subscription a subscribe request opened. The
+// one it replaces, or one opened by a request since superseded, is queued
+// for cancelling.
+func (f *watchlistFilter) handleResponse(msg *rpcMessage) {
+	id, err := strconv.Atoi(string(msg.ID))
+	if err != nil || msg.Error != nil {
+		if err == nil {
+			f.monitor.logger.Warn("filtered subscription rejected", "error", msg.Error)
+		}
+		return
+	}
+	var subscription string
+	if err := wireJSON.Unmarshal(msg.Result, &subscription); err != nil {
+		return
+	}
+
+	f.mu.Lock()
+	if id < 1 || id > f.requestID {
+		f.mu.Unlock()
+		return
+	}
+	stale := subscription
+	if id == f.requestID {
+		stale, f.current = f.current, subscription
+	}
+	queue := f.stale
+	f.mu.Unlock()
+
+	if stale != "" {
+		select {
+		case queue <- stale:
+		default:
+		}
+	}
+}
+
+// follow resubscribes conn whenever the watched addresses change, and
+// cancels replaced subscriptions, until the returned function is called. It
+// is conn's only writer once the first subscription is sent.
+func (f *watchlistFilter) follow(conn *websocket.Conn) func() {
+	f.mu.Lock()
+	stale := f.stale
+	f.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(f.refresh)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-f.monitor.ctx.Done():
+				return
+			case subscription := <-stale:
+				f.unsubscribe(conn, subscription)
+			case <-ticker.C:
+				addresses := f.watchlist.evmAddresses()
+				f.mu.Lock()
+				if slices.Equal(addresses, f.addresses) {
+					f.mu.Unlock()
+					continue
+				}
+				f.addresses = addresses
+				f.requestID++
+				requestID, current := f.requestID, f.current
+				if len(addresses) == 0 {
+					f.current = ""
+				}
+				f.mu.Unlock()
+
+				if len(addresses) == 0 {
+					subscriptionFilterAddresses.WithLabelValues(f.monitor.chainName).Set(0)
+					if current != "" {
+						f.unsubscribe(conn, current)
+					}
+					continue
+				}
+				f.monitor.logger.Info("watchlist changed, resubscribing", "addresses", len(addresses))
+				if err := conn.WriteJSON(map[string]interface{}{
+					"jsonrpc": "2.0",
+					"id":      requestID,
+					"method":  "eth_subscribe",
+					"params":  f.params(addresses),
+				}); err != nil {
+					f.monitor.logger.Warn("failed to resubscribe", "error", err)
+					return
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// unsubscribe cancels a replaced subscription
+func (f *watchlistFilter) unsubscribe(conn *websocket.Conn, subscription string) {
+	if err := conn.WriteJSON(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      "watchlist_unsubscribe",
+		"method":  "eth_unsubscribe",
+		"params":  []interface{}{subscription},
+	}); err != nil {
+		f.monitor.logger.Warn("failed to cancel replaced subscription", "error", err)
+	}
+}
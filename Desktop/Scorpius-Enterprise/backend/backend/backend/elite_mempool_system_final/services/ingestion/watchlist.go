@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+)
+
+// watchlistTag marks transactions sent from or to a watched address
+const watchlistTag = "watchlist"
+
+// watchlistConfigSource names addresses listed in WATCHLIST_ADDRESSES
+const watchlistConfigSource = "config"
+
+var (
+	watchlistMatches = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scorpius_watchlist_matches_total",
+			Help: "Transactions matching a watched address, by list",
+		},
+		[]string{"chain", "list"},
+	)
+
+	watchlistSize = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "scorpius_watchlist_addresses",
+			Help: "Addresses currently watched, by list",
+		},
+		[]string{"list"},
+	)
+)
+
+// WatchlistConfig configures address watching
+type WatchlistConfig struct {
+	Addresses []string
+	Sets      []string
+	Topic     string
+	Refresh   time.Duration
+}
+
+// loadWatchlistConfig reads WATCHLIST_* settings
+func loadWatchlistConfig() WatchlistConfig {
+	return WatchlistConfig{
+		Addresses: splitNonEmpty(getEnv("WATCHLIST_ADDRESSES")),
+		Sets:      splitNonEmpty(getEnv("WATCHLIST_SETS")),
+		Topic:     getEnvOrDefault("WATCHLIST_TOPIC", "tx_watchlist"),
+		Refresh:   getEnvDuration("WATCHLIST_REFRESH", 10*time.Second),
+	}
+}
+
+// Enabled reports whether any addresses or Redis sets are configured
+func (c WatchlistConfig) Enabled() bool {
+	return len(c.Addresses) > 0 || len(c.Sets) > 0
+}
+
+// Watchlist tags transactions whose sender or recipient is watched.
+//
+// Addresses come from WATCHLIST_ADDRESSES and from Redis sets named in
+// WATCHLIST_SETS. The sets are reloaded every refresh interval, so any
+// instance (or an operator with redis-cli) can change them at runtime; each
+// set's key is reported as the list a match came from.
+type Watchlist struct {
+	redis     *redis.Client
+	sets      []string
+	static    map[string]string
+	mu        sync.RWMutex
+	addresses map[string]string
+}
+
+// NewWatchlist creates a watchlist from config; call Run to load the Redis sets
+func NewWatchlist(redisClient *redis.Client, config WatchlistConfig) *Watchlist {
+	w := &Watchlist{
+		redis:     redisClient,
+		sets:      config.Sets,
+		static:    make(map[string]string),
+		addresses: make(map[string]string),
+	}
+	for _, address := range config.Addresses {
+		w.static[normalizeAddress(address)] = watchlistConfigSource
+	}
+	for address, list := range w.static {
+		w.addresses[address] = list
+	}
+	watchlistSize.WithLabelValues(watchlistConfigSource).Set(float64(len(w.static)))
+	return w
+}
+
+// normalizeAddress lowercases hex addresses; other encodings, such as
+// base58, are case-sensitive and kept as is
+func normalizeAddress(address string) string {
+	address = strings.TrimSpace(address)
+	if strings.HasPrefix(address, "0x") || strings.HasPrefix(address, "0X") {
+		return strings.ToLower(address)
+	}
+	return address
+}
+
+// Run reloads the Redis sets until ctx is cancelled
+func (w *Watchlist) Run(ctx context.Context, interval time.Duration) {
+	if len(w.sets) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		w.refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh rebuilds the address index from config and the Redis sets. A set
+// that fails to load keeps its previous members.
+func (w *Watchlist) refresh(ctx context.Context) {
+	addresses := make(map[string]string, len(w.static))
+	for address, list := range w.static {
+		addresses[address] = list
+	}
+
+	w.mu.RLock()
+	previous := w.addresses
+	w.mu.RUnlock()
+
+	for _, set := range w.sets {
+		members, err := w.redis.SMembers(ctx, set).Result()
+		if err != nil {
+			slog.Warn("failed to load watchlist", "list", set, "error", err)
+			for address, list := range previous {
+				if list == set {
+					addresses[address] = list
+				}
+			}
+			continue
+		}
+
+		for _, member := range members {
+			addresses[normalizeAddress(member)] = set
+		}
+		watchlistSize.WithLabelValues(set).Set(float64(len(members)))
+	}
+
+	w.mu.Lock()
+	w.addresses = addresses
+	w.mu.Unlock()
+}
+
+// hasSet reports whether set is one of the configured Redis sets
+func (w *Watchlist) hasSet(set string) bool {
+	return containsString(w.sets, set)
+}
+
+// Add stores addresses in a configured Redis set and watches them immediately
+func (w *Watchlist) Add(ctx context.Context, set string, addresses []string) error {
+	members := make([]interface{}, len(addresses))
+	for i, address := range addresses {
+		members[i] = normalizeAddress(address)
+	}
+	if err := w.redis.SAdd(ctx, set, members...).Err(); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	for _, member := range members {
+		w.addresses[member.(string)] = set
+	}
+	w.mu.Unlock()
+	return nil
+}
+
+// Remove deletes an address from a configured Redis set and stops watching it
+func (w *Watchlist) Remove(ctx context.Context, set, address string) error {
+	address = normalizeAddress(address)
+	if err := w.redis.SRem(ctx, set, address).Err(); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	if w.addresses[address] == set {
+		delete(w.addresses, address)
+		if list, ok := w.static[address]; ok {
+			w.addresses[address] = list
+		}
+	}
+	w.mu.Unlock()
+	return nil
+}
+
+// lookup returns the list watching address, if any
+func (w *Watchlist) lookup(address string) (string, bool) {
+	if address == "" {
+		return "", false
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	list, ok := w.addresses[normalizeAddress(address)]
+	return list, ok
+}
+
+// evmAddresses returns the watched EVM addresses, sorted
+func (w *Watchlist) evmAddresses() []string {
+	w.mu.RLock()
+	addresses := make([]string, 0, len(w.addresses))
+	for address := range w.addresses {
+		if strings.HasPrefix(address, "0x") && common.IsHexAddress(address) {
+			addresses = append(addresses, address)
+		}
+	}
+	w.mu.RUnlock()
+
+	sort.Strings(addresses)
+	return addresses
+}
+
+// Name returns the enricher name
+func (w *Watchlist) Name() string {
+	return watchlistTag
+}
+
+// Enrich tags tx once per watched side; the topic router then also sends it
+// to the watchlist topic
+func (w *Watchlist) Enrich(tx *Transaction) {
+	for _, side := range []struct{ field, address string }{{"from", tx.From}, {"to", tx.To}} {
+		list, ok := w.lookup(side.address)
+		if !ok {
+			continue
+		}
+		watchlistMatches.WithLabelValues(tx.Chain, list).Inc()
+		tx.Tags = append(tx.Tags, TxTag{
+			Tag:       watchlistTag,
+			Source:    list,
+			Note:      side.field + " " + side.address,
+			CreatedAt: time.Now(),
+		})
+	}
+}
+
+// hasTag reports whether tx carries the named tag
+func hasTag(tx *Transaction, tag string) bool {
+	for _, t := range tx.Tags {
+		if t.Tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// watchlistRequest is the body accepted by the watchlist API
+type watchlistRequest struct {
+	Set       string   `json:"set"`
+	Addresses []string `json:"addresses"`
+}
+
+// registerWatchlistAPI mounts the endpoints for viewing and editing the watched Redis sets
+func (is *IngestionService) registerWatchlistAPI(mux *http.ServeMux) {
+	mux.HandleFunc("/api/watchlist", func(w http.ResponseWriter, r *http.Request) {
+		if is.watchlist == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "watchlist is not enabled"})
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			lists := make(map[string][]string)
+			is.watchlist.mu.RLock()
+			for address, list := range is.watchlist.addresses {
+				lists[list] = append(lists[list], address)
+			}
+			is.watchlist.mu.RUnlock()
+			writeJSON(w, http.StatusOK, map[string]interface{}{"lists": lists})
+
+		case http.MethodPost:
+			var req watchlistRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+				return
+			}
+			if !is.watchlist.hasSet(req.Set) || len(req.Addresses) == 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "a configured set and at least one address are required"})
+				return
+			}
+			if err := is.watchlist.Add(r.Context(), req.Set, req.Addresses); err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusCreated, map[string]string{"status": "added"})
+
+		case http.MethodDelete:
+			query := r.URL.Query()
+			if !is.watchlist.hasSet(query.Get("set")) || query.Get("address") == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "a configured set and address are required"})
+				return
+			}
+			if err := is.watchlist.Remove(r.Context(), query.Get("set"), query.Get("address")); err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]string{"status": "removed"})
+
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	})
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Tags added to large stablecoin transfers
+const (
+	whaleTransferTag   = "whale_transfer"
+	exchangeDepositTag = "exchange_deposit"
+)
+
+var whaleTransfers = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_whale_transfers_total",
+		Help: "Pending stablecoin transfers above the whale threshold, by token",
+	},
+	[]string{"chain", "symbol"},
+)
+
+// stablecoin is a token the whale monitor watches
+type stablecoin struct {
+	symbol   string
+	decimals int
+}
+
+// defaultStablecoins are watched on chains without <CHAIN>_STABLECOINS, by chain ID
+var defaultStablecoins = map[int64]map[string]stablecoin{
+	1: {
+		"0xdac17f958d2ee523a2206206994597c13d831ec7": {symbol: "USDT", decimals: 6},
+		"0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48": {symbol: "USDC", decimals: 6},
+		"0x6b175474e89094c44da98b954eedeac495271d0f": {symbol: "DAI", decimals: 18},
+	},
+	42161: {
+		"0xfd086bc7cd5c481dcc9c85ebe478a1c0b69fcbb9": {symbol: "USDT", decimals: 6},
+		"0xaf88d065e77c8cc2239327c5edb3a432268e5831": {symbol: "USDC", decimals: 6},
+		"0xda10009cbd5d07dd0cecc66161fc93d7c9000da1": {symbol: "DAI", decimals: 18},
+	},
+	10: {
+		"0x94b008aa00579c1307b0ef2c499ad98a8ce58e58": {symbol: "USDT", decimals: 6},
+		"0x0b2c639c533813f4aa9d7837caf62653d097ff85": {symbol: "USDC", decimals: 6},
+		"0xda10009cbd5d07dd0cecc66161fc93d7c9000da1": {symbol: "DAI", decimals: 18},
+	},
+	8453: {
+		"0x833589fcd6edb6e08f4c7c32d4f71b54bda02913": {symbol: "USDC", decimals: 6},
+		"0x50c5725949a6f0c72e6c4a641f24049a917db0cb": {symbol: "DAI", decimals: 18},
+	},
+	137: {
+		"0xc2132d05d31c914a87c6611c10748aeb04b58e8f": {symbol: "USDT", decimals: 6},
+		"0x3c499c542cef5e3811e1192ce70d8cc03d5c3359": {symbol: "USDC", decimals: 6},
+		"0x8f3cf7ad23cd3cadbd9735aff958023239c6a063": {symbol: "DAI", decimals: 18},
+	},
+	56: {
+		"0x55d398326f99059ff775485246999027b3197955": {symbol: "USDT", decimals: 18},
+		"0x8ac76a51cc950d9822d68b83fe1ad97b32cd580d": {symbol: "USDC", decimals: 18},
+		"0x1af3f329e8be154074d8769d1ffa4ee058b1dbc3": {symbol: "DAI", decimals: 18},
+	},
+	43114: {
+		"0x9702230a8ea53601f5cd2dc00fdbc13d4df4a8c7": {symbol: "USDT", decimals: 6},
+		"0xb97ef9ef8734c71904d8002f8b6bc66dd9c48a6e": {symbol: "USDC", decimals: 6},
+	},
+}
+
+// WhaleConfig configures the large stablecoin transfer monitor
+type WhaleConfig struct {
+	Enabled bool
+	Topic   string
+	// Threshold is the smallest flagged transfer, in whole tokens, for
+	// symbols without their own entry in Thresholds
+	Threshold  float64
+	Thresholds map[string]string
+	// ExchangeFiles list exchange deposit addresses, one
+	// "<address> <exchange>" per line
+	ExchangeFiles []string
+}
+
+// loadWhaleConfig reads WHALE_* settings
+func loadWhaleConfig() WhaleConfig {
+	return WhaleConfig{
+		Enabled:       getEnvBool("WHALE_ALERTS", false),
+		Topic:         getEnvOrDefault("WHALE_TOPIC", "whale_alerts"),
+		Threshold:     getEnvFloat("WHALE_THRESHOLD", 1000000),
+		Thresholds:    parseKeyValues(getEnv("WHALE_THRESHOLDS")),
+		ExchangeFiles: splitNonEmpty(getEnv("WHALE_EXCHANGE_FILES")),
+	}
+}
+
+// WhaleTransfer is a pending stablecoin transfer above its threshold.
+// Amount is in base units and Value in whole tokens.
+type WhaleTransfer struct {
+	Chain        string    `json:"chain"`
+	ChainID      int64     `json:"chain_id"`
+	Hash         string    `json:"hash"`
+	Token        string    `json:"token"`
+	Symbol       string    `json:"symbol"`
+	Method       string    `json:"method"`
+	From         string    `json:"from"`
+	To           string    `json:"to"`
+	Amount       string    `json:"amount"`
+	Value        float64   `json:"value"`
+	FromExchange string    `json:"from_exchange,omitempty"`
+	ToExchange   string    `json:"to_exchange,omitempty"`
+	Status       string    `json:"status"`
+	DetectedAt   time.Time `json:"detected_at"`
+}
+
+// WhaleMonitor flags pending transfers of USDT, USDC and DAI, or each
+// chain's <CHAIN>_STABLECOINS (SYMBOL=address[:decimals]), at or above the
+// whale threshold. Flagged transactions are tagged, published to the whale
+// topic and raised as alerts; transfers to a listed exchange deposit
+// address are also tagged as deposits to that exchange.
+type WhaleMonitor struct {
+	sinks      *chainSinks
+	alerter    *Alerter
+	topic      string
+	threshold  float64
+	thresholds map[string]float64
+	// tokens maps a chain name to its watched stablecoins by address
+	tokens    map[string]map[string]stablecoin
+	exchanges map[string]string
+}
+
+// NewWhaleMonitor loads the exchange files and creates a monitor publishing to sinks
+func NewWhaleMonitor(sinks *chainSinks, alerter *Alerter, config WhaleConfig, chains map[string]ChainOptions) (*WhaleMonitor, error) {
+	thresholds := make(map[string]float64, len(config.Thresholds))
+	for symbol, value := range config.Thresholds {
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid whale threshold for %s: %q", symbol, value)
+		}
+		thresholds[strings.ToUpper(symbol)] = threshold
+	}
+
+	tokens := make(map[string]map[string]stablecoin)
+	for chainName, options := range chains {
+		if len(options.Stablecoins) == 0 {
+			if defaults, ok := defaultStablecoins[chainRegistry[chainName].ChainID]; ok && chainRegistry[chainName].Family == FamilyEVM {
+				tokens[chainName] = defaults
+			}
+			continue
+		}
+		watched := make(map[string]stablecoin, len(options.Stablecoins))
+		for symbol, value := range options.Stablecoins {
+			address, coin, err := parseStablecoin(symbol, value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", chainName, err)
+			}
+			watched[address] = coin
+		}
+		tokens[chainName] = watched
+	}
+
+	exchanges := make(map[string]string)
+	for _, path := range config.ExchangeFiles {
+		if err := loadLabelFile(path, "exchange addresses", exchanges); err != nil {
+			return nil, err
+		}
+	}
+	return &WhaleMonitor{
+		sinks:      sinks,
+		alerter:    alerter,
+		topic:      config.Topic,
+		threshold:  config.Threshold,
+		thresholds: thresholds,
+		tokens:     tokens,
+		exchanges:  exchanges,
+	}, nil
+}
+
+// parseStablecoin parses a <CHAIN>_STABLECOINS entry, address[:decimals]
+// with 18 decimals when unset
+func parseStablecoin(symbol, value string) (string, stablecoin, error) {
+	address, decimals, hasDecimals := strings.Cut(value, ":")
+	coin := stablecoin{symbol: strings.ToUpper(symbol), decimals: 18}
+	if hasDecimals {
+		n, err := strconv.Atoi(decimals)
+		if err != nil || n < 0 || n > 36 {
+			return "", coin, fmt.Errorf("invalid decimals for %s: %q", symbol, decimals)
+		}
+		coin.decimals = n
+	}
+	if !common.IsHexAddress(address) {
+		return "", coin, fmt.Errorf("invalid address for %s: %q", symbol, address)
+	}
+	return strings.ToLower(address), coin, nil
+}
+
+// Name returns the enricher name
+func (w *WhaleMonitor) Name() string {
+	return "whales"
+}
+
+// Enrich flags pending stablecoin transfers at or above the threshold
+func (w *WhaleMonitor) Enrich(tx *Transaction) {
+	if tx.ChainFamily != FamilyEVM || tx.Canary || tx.Status != "pending" {
+		return
+	}
+	token := strings.ToLower(tx.To)
+	coin, ok := w.tokens[tx.Chain][token]
+	if !ok {
+		return
+	}
+	transfer := tx.TokenTransfer
+	if transfer == nil {
+		transfer = decodeTokenTransfer(tx)
+	}
+	if transfer == nil || transfer.Method == "approve" || transfer.Amount == "" {
+		return
+	}
+
+	amount, ok := new(big.Int).SetString(transfer.Amount, 10)
+	if !ok {
+		return
+	}
+	value, _ := new(big.Float).Quo(new(big.Float).SetInt(amount), new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(coin.decimals)), nil))).Float64()
+	threshold, ok := w.thresholds[coin.symbol]
+	if !ok {
+		threshold = w.threshold
+	}
+	if value < threshold {
+		return
+	}
+
+	event := WhaleTransfer{
+		Chain:      tx.Chain,
+		ChainID:    tx.ChainID,
+		Hash:       tx.Hash,
+		Token:      token,
+		Symbol:     coin.symbol,
+		Method:     transfer.Method,
+		From:       strings.ToLower(transfer.From),
+		To:         strings.ToLower(transfer.To),
+		Amount:     transfer.Amount,
+		Value:      value,
+		Status:     tx.Status,
+		DetectedAt: time.Now(),
+	}
+	event.FromExchange = w.exchanges[event.From]
+	event.ToExchange = w.exchanges[event.To]
+	whaleTransfers.WithLabelValues(tx.Chain, coin.symbol).Inc()
+
+	tx.Tags = append(tx.Tags, TxTag{
+		Tag:       whaleTransferTag,
+		Note:      fmt.Sprintf("%.0f %s", value, coin.symbol),
+		CreatedAt: event.DetectedAt,
+	})
+	destination := event.To
+	if event.ToExchange != "" {
+		tx.Tags = append(tx.Tags, TxTag{Tag: exchangeDepositTag, Source: event.ToExchange, CreatedAt: event.DetectedAt})
+		destination = fmt.Sprintf("%s (%s deposit)", event.To, event.ToExchange)
+	}
+	raiseTxAlert(w.alerter, tx, Alert{
+		Chain:    tx.Chain,
+		Category: "whale",
+		Severity: SeverityInfo,
+		Message:  fmt.Sprintf("%.0f %s moving from %s to %s (tx %s)", value, coin.symbol, event.From, destination, tx.Hash),
+	})
+
+	w.publish(event, tx.ChainFamily)
+}
+
+// publish produces a whale transfer to the whale topic
+func (w *WhaleMonitor) publish(event WhaleTransfer, family string) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("failed to marshal whale transfer", "chain", event.Chain, "tx_hash", event.Hash, "error", err)
+		return
+	}
+
+	topic := expandTopic(w.topic, event.Chain, event.ChainID, family)
+	headers := map[string]string{
+		"chain_id":   fmt.Sprintf("%d", event.ChainID),
+		"chain_name": event.Chain,
+		"symbol":     event.Symbol,
+		"format":     FormatJSON,
+	}
+	if err := w.sinks.Publish(context.Background(), event.Chain, topic, []byte(event.Hash), data, headers); err != nil {
+		slog.Warn("failed to publish whale transfer", "chain", event.Chain, "tx_hash", event.Hash, "error", err)
+	}
+}
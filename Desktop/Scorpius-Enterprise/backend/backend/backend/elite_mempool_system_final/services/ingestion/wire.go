@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/mailru/easyjson"
+	"github.com/mailru/easyjson/jlexer"
+)
+
+//go:generate ./gen_wire.sh
+
+// wireJSON decodes the payloads each protocol nests in a frame. It is a
+// drop-in for encoding/json that avoids most of the reflection cost per
+// message. Frames and pending transactions, decoded for every message, use
+// the easyjson codecs instead.
+var wireJSON = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// wireLexers recycles the lexers decodeWire hands to codecs, which would
+// otherwise escape to the heap through the Unmarshaler interface
+var wireLexers = sync.Pool{
+	New: func() interface{} { return new(jlexer.Lexer) },
+}
+
+// decodeWire decodes a hot-path value with its easyjson codec. Raw fields
+// and nocopy strings are sub-slices of data, so data must not be reused
+// afterwards.
+func decodeWire(data []byte, v easyjson.Unmarshaler) error {
+	in := wireLexers.Get().(*jlexer.Lexer)
+	*in = jlexer.Lexer{Data: data}
+	v.UnmarshalEasyJSON(in)
+	err := in.Error()
+	*in = jlexer.Lexer{}
+	wireLexers.Put(in)
+	return err
+}
+
+// rpcMessage is a JSON-RPC response or subscription notification. Results
+// stay raw so each protocol decodes only the shape it expects
+type rpcMessage struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params *rpcParams      `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *rpcError       `json:"error,omitempty"`
+
+	// params backs Params, so decoding a notification does not allocate it
+	params rpcParams
+}
+
+// UnmarshalEasyJSON decodes a frame in one pass, keeping raw values and the
+// method as sub-slices of the frame rather than copies
+func (m *rpcMessage) UnmarshalEasyJSON(in *jlexer.Lexer) {
+	if in.IsNull() {
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "id":
+			m.ID = in.Raw()
+		case "method":
+			m.Method = in.UnsafeString()
+		case "params":
+			if in.IsNull() {
+				in.Skip()
+				break
+			}
+			m.params = rpcParams{}
+			m.Params = &m.params
+			m.Params.UnmarshalEasyJSON(in)
+		case "result":
+			m.Result = in.Raw()
+		case "error":
+			if data := in.Raw(); !isJSONNull(data) {
+				m.Error = new(rpcError)
+				in.AddError(wireJSON.Unmarshal(data, m.Error))
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+}
+
+// UnmarshalJSON implements json.Unmarshaler with the easyjson decoder. The
+// caller may reuse data, so the raw values are taken from a copy.
+func (m *rpcMessage) UnmarshalJSON(data []byte) error {
+	return decodeWire(append([]byte(nil), data...), m)
+}
+
+// rpcParams carries the payload of a subscription notification. Raw keeps
+// the whole params object for protocols that do not nest the payload in
+// result.
+type rpcParams struct {
+	Result json.RawMessage `json:"result"`
+	Raw    json.RawMessage `json:"-"`
+}
+
+// UnmarshalEasyJSON finds result while scanning params once. Both Result
+// and Raw are sub-slices of the frame.
+func (p *rpcParams) UnmarshalEasyJSON(in *jlexer.Lexer) {
+	if in.IsNull() {
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	start := in.GetPos() - 1
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if key == "result" {
+			p.Result = in.Raw()
+		} else {
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if in.Ok() {
+		p.Raw = in.Data[start:in.GetPos()]
+	}
+}
+
+// UnmarshalJSON implements json.Unmarshaler with the easyjson decoder. The
+// caller may reuse data, so the raw values are taken from a copy.
+func (p *rpcParams) UnmarshalJSON(data []byte) error {
+	return decodeWire(append([]byte(nil), data...), p)
+}
+
+// rpcTransaction is an eth_subscribe newPendingTransactions / eth_getTransactionByHash payload.
+// Strings are decoded without copying (nocopy), so they share the frame's
+// memory the way Raw does.
+//
+//easyjson:json
+type rpcTransaction struct {
+	Hash                 string           `json:"hash,nocopy"`
+	From                 string           `json:"from,nocopy"`
+	To                   string           `json:"to,nocopy"`
+	Value                string           `json:"value,nocopy"`
+	Gas                  string           `json:"gas,nocopy"`
+	GasPrice             string           `json:"gasPrice,nocopy"`
+	Type                 string           `json:"type,nocopy"`
+	MaxFeePerGas         string           `json:"maxFeePerGas,nocopy"`
+	MaxPriorityFeePerGas string           `json:"maxPriorityFeePerGas,nocopy"`
+	MaxFeePerBlobGas     string           `json:"maxFeePerBlobGas,nocopy"`
+	BlobVersionedHashes  []string         `json:"blobVersionedHashes,nocopy"`
+	AccessList           []rpcAccessTuple `json:"accessList"`
+	Input                string           `json:"input,nocopy"`
+	Nonce                string           `json:"nonce,nocopy"`
+}
+
+// decode decodes a pending transaction from data. Calling the codec directly
+// rather than through decodeWire keeps t and the lexer off the heap.
+func (t *rpcTransaction) decode(data []byte) error {
+	in := jlexer.Lexer{Data: data}
+	t.UnmarshalEasyJSON(&in)
+	return in.Error()
+}
+
+// rpcAccessTuple is an EIP-2930 access list entry as returned over JSON-RPC
+//
+//easyjson:json
+type rpcAccessTuple struct {
+	Address     string   `json:"address,nocopy"`
+	StorageKeys []string `json:"storageKeys,nocopy"`
+}
+
+// isJSONNull reports whether a raw value is absent or JSON null
+func isJSONNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
@@ -0,0 +1,345 @@
+// Code generated by easyjson for marshaling/unmarshaling. DO NOT EDIT.
+
+package main
+
+import (
+	json "encoding/json"
+	easyjson "github.com/mailru/easyjson"
+	jlexer "github.com/mailru/easyjson/jlexer"
+	jwriter "github.com/mailru/easyjson/jwriter"
+)
+
+// suppress unused package warning
+var (
+	_ *json.RawMessage
+	_ *jlexer.Lexer
+	_ *jwriter.Writer
+	_ easyjson.Marshaler
+)
+
+func easyjsonF4688553DecodeScorpiusIngestionWiregenHscZ7d(in *jlexer.Lexer, out *rpcTransaction) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "hash":
+			out.Hash = string(in.UnsafeString())
+		case "from":
+			out.From = string(in.UnsafeString())
+		case "to":
+			out.To = string(in.UnsafeString())
+		case "value":
+			out.Value = string(in.UnsafeString())
+		case "gas":
+			out.Gas = string(in.UnsafeString())
+		case "gasPrice":
+			out.GasPrice = string(in.UnsafeString())
+		case "type":
+			out.Type = string(in.UnsafeString())
+		case "maxFeePerGas":
+			out.MaxFeePerGas = string(in.UnsafeString())
+		case "maxPriorityFeePerGas":
+			out.MaxPriorityFeePerGas = string(in.UnsafeString())
+		case "maxFeePerBlobGas":
+			out.MaxFeePerBlobGas = string(in.UnsafeString())
+		case "blobVersionedHashes":
+			if in.IsNull() {
+				in.Skip()
+				out.BlobVersionedHashes = nil
+			} else {
+				in.Delim('[')
+				if out.BlobVersionedHashes == nil {
+					if !in.IsDelim(']') {
+						out.BlobVersionedHashes = make([]string, 0, 4)
+					} else {
+						out.BlobVersionedHashes = []string{}
+					}
+				} else {
+					out.BlobVersionedHashes = (out.BlobVersionedHashes)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v1 string
+					v1 = string(in.UnsafeString())
+					out.BlobVersionedHashes = append(out.BlobVersionedHashes, v1)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "accessList":
+			if in.IsNull() {
+				in.Skip()
+				out.AccessList = nil
+			} else {
+				in.Delim('[')
+				if out.AccessList == nil {
+					if !in.IsDelim(']') {
+						out.AccessList = make([]rpcAccessTuple, 0, 1)
+					} else {
+						out.AccessList = []rpcAccessTuple{}
+					}
+				} else {
+					out.AccessList = (out.AccessList)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v2 rpcAccessTuple
+					(v2).UnmarshalEasyJSON(in)
+					out.AccessList = append(out.AccessList, v2)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "input":
+			out.Input = string(in.UnsafeString())
+		case "nonce":
+			out.Nonce = string(in.UnsafeString())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonF4688553EncodeScorpiusIngestionWiregenHscZ7d(out *jwriter.Writer, in rpcTransaction) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"hash\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Hash))
+	}
+	{
+		const prefix string = ",\"from\":"
+		out.RawString(prefix)
+		out.String(string(in.From))
+	}
+	{
+		const prefix string = ",\"to\":"
+		out.RawString(prefix)
+		out.String(string(in.To))
+	}
+	{
+		const prefix string = ",\"value\":"
+		out.RawString(prefix)
+		out.String(string(in.Value))
+	}
+	{
+		const prefix string = ",\"gas\":"
+		out.RawString(prefix)
+		out.String(string(in.Gas))
+	}
+	{
+		const prefix string = ",\"gasPrice\":"
+		out.RawString(prefix)
+		out.String(string(in.GasPrice))
+	}
+	{
+		const prefix string = ",\"type\":"
+		out.RawString(prefix)
+		out.String(string(in.Type))
+	}
+	{
+		const prefix string = ",\"maxFeePerGas\":"
+		out.RawString(prefix)
+		out.String(string(in.MaxFeePerGas))
+	}
+	{
+		const prefix string = ",\"maxPriorityFeePerGas\":"
+		out.RawString(prefix)
+		out.String(string(in.MaxPriorityFeePerGas))
+	}
+	{
+		const prefix string = ",\"maxFeePerBlobGas\":"
+		out.RawString(prefix)
+		out.String(string(in.MaxFeePerBlobGas))
+	}
+	{
+		const prefix string = ",\"blobVersionedHashes\":"
+		out.RawString(prefix)
+		if in.BlobVersionedHashes == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v3, v4 := range in.BlobVersionedHashes {
+				if v3 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v4))
+			}
+			out.RawByte(']')
+		}
+	}
+	{
+		const prefix string = ",\"accessList\":"
+		out.RawString(prefix)
+		if in.AccessList == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v5, v6 := range in.AccessList {
+				if v5 > 0 {
+					out.RawByte(',')
+				}
+				(v6).MarshalEasyJSON(out)
+			}
+			out.RawByte(']')
+		}
+	}
+	{
+		const prefix string = ",\"input\":"
+		out.RawString(prefix)
+		out.String(string(in.Input))
+	}
+	{
+		const prefix string = ",\"nonce\":"
+		out.RawString(prefix)
+		out.String(string(in.Nonce))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v rpcTransaction) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonF4688553EncodeScorpiusIngestionWiregenHscZ7d(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v rpcTransaction) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonF4688553EncodeScorpiusIngestionWiregenHscZ7d(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *rpcTransaction) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonF4688553DecodeScorpiusIngestionWiregenHscZ7d(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *rpcTransaction) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonF4688553DecodeScorpiusIngestionWiregenHscZ7d(l, v)
+}
+func easyjsonF4688553DecodeScorpiusIngestionWiregenHscZ7d1(in *jlexer.Lexer, out *rpcAccessTuple) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "address":
+			out.Address = string(in.UnsafeString())
+		case "storageKeys":
+			if in.IsNull() {
+				in.Skip()
+				out.StorageKeys = nil
+			} else {
+				in.Delim('[')
+				if out.StorageKeys == nil {
+					if !in.IsDelim(']') {
+						out.StorageKeys = make([]string, 0, 4)
+					} else {
+						out.StorageKeys = []string{}
+					}
+				} else {
+					out.StorageKeys = (out.StorageKeys)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v7 string
+					v7 = string(in.UnsafeString())
+					out.StorageKeys = append(out.StorageKeys, v7)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonF4688553EncodeScorpiusIngestionWiregenHscZ7d1(out *jwriter.Writer, in rpcAccessTuple) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"address\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Address))
+	}
+	{
+		const prefix string = ",\"storageKeys\":"
+		out.RawString(prefix)
+		if in.StorageKeys == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v8, v9 := range in.StorageKeys {
+				if v8 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v9))
+			}
+			out.RawByte(']')
+		}
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v rpcAccessTuple) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonF4688553EncodeScorpiusIngestionWiregenHscZ7d1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v rpcAccessTuple) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonF4688553EncodeScorpiusIngestionWiregenHscZ7d1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *rpcAccessTuple) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonF4688553DecodeScorpiusIngestionWiregenHscZ7d1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *rpcAccessTuple) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonF4688553DecodeScorpiusIngestionWiregenHscZ7d1(l, v)
+}
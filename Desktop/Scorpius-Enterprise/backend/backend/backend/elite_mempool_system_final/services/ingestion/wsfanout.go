@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+)
+
+// wsTransport labels WebSocket subscribers in the stream metrics
+const wsTransport = "websocket"
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPingInterval = 30 * time.Second
+)
+
+var streamRateLimited = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "scorpius_stream_rate_limited_total",
+		Help: "Transactions not delivered to a streaming client because it exceeded its rate limit, by transport",
+	},
+	[]string{"transport"},
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 16384,
+	// Clients authenticate with a token, which browsers can only pass in the
+	// query string, so any origin may connect
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// authenticate returns the name of the token presented with r, as a bearer
+// token or a token query parameter. Without configured tokens every client
+// is admitted as "anonymous".
+func authenticate(r *http.Request, tokens map[string]string) (string, bool) {
+	if len(tokens) == 0 {
+		return "anonymous", true
+	}
+
+	presented := r.URL.Query().Get("token")
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		presented = strings.TrimPrefix(header, "Bearer ")
+	}
+	if presented == "" {
+		return "", false
+	}
+	for name, token := range tokens {
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// streamClient names the client of a stream request: the principal the
+// admin server authenticated or, without API authentication, the holder of
+// a STREAM_TOKENS token
+func streamClient(r *http.Request, tokens map[string]string) (string, bool) {
+	if principal, ok := principalFrom(r.Context()); ok {
+		return principal.Name, true
+	}
+	return authenticate(r, tokens)
+}
+
+// streamRateLimit is the client's requested rate, if lower than the server's limit
+func streamRateLimit(r *http.Request, limit float64) float64 {
+	if requested, err := strconv.ParseFloat(r.URL.Query().Get("rate"), 64); err == nil && requested > 0 && (limit <= 0 || requested < limit) {
+		return requested
+	}
+	return limit
+}
+
+// registerWebSocketFanout mounts /ws/txs, which streams published transactions
+// as JSON text messages to dashboards and bots.
+//
+// Query parameters: chain (comma-separated, default all), filter (an
+// expression in the FILTER_EXPR language), rate (transactions per second,
+// capped at STREAM_RATE_LIMIT) and token (or an Authorization: Bearer
+// header) when STREAM_TOKENS is set. Tenants are limited to their chains
+// and share their tenant's rate limit. Transactions beyond a rate limit or
+// a full buffer are skipped, not queued.
+func (is *IngestionService) registerWebSocketFanout(mux *http.ServeMux) {
+	mux.HandleFunc("/ws/txs", func(w http.ResponseWriter, r *http.Request) {
+		if !is.config.Streams.WebSocket {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "WebSocket streaming is not enabled"})
+			return
+		}
+		client, ok := streamClient(r, is.config.Streams.Tokens)
+		if !ok {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid token"})
+			return
+		}
+
+		query := r.URL.Query()
+		tenant, chains, quotaErr := is.openTenantStream(r.Context(), wsTransport, splitNonEmpty(query.Get("chain")))
+		if quotaErr != nil {
+			writeJSON(w, quotaErr.HTTPStatus(), map[string]string{"error": quotaErr.Error()})
+			return
+		}
+		defer tenant.Close()
+		match, err := txMatcher(chains, query.Get("filter"), "websocket")
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		limit := rate.Inf
+		if perSecond := streamRateLimit(r, is.config.Streams.RateLimit); perSecond > 0 {
+			limit = rate.Limit(perSecond)
+		}
+
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// The upgrader has already written the error response
+			return
+		}
+		defer conn.Close()
+
+		sub := is.hub.Subscribe(wsTransport, is.config.Streams.Buffer, match)
+		defer is.hub.Unsubscribe(sub)
+		slog.Info("websocket client connected", "client", client, "remote", r.RemoteAddr)
+
+		// Read only to notice the client leaving; clients send nothing else
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+
+		limiter := rate.NewLimiter(limit, is.config.Streams.RateBurst)
+		ping := time.NewTicker(wsPingInterval)
+		defer ping.Stop()
+
+		for {
+			select {
+			case <-closed:
+				slog.Info("websocket client disconnected", "client", client, "remote", r.RemoteAddr)
+				return
+			case <-is.ctx.Done():
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "shutting down"), time.Now().Add(time.Second))
+				return
+			case <-ping.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+					return
+				}
+			case tx := <-sub.C:
+				if !limiter.Allow() {
+					streamRateLimited.WithLabelValues(wsTransport).Inc()
+					continue
+				}
+				if !tenant.Allow() {
+					continue
+				}
+				data, err := json.Marshal(tx)
+				if err != nil {
+					slog.Error("failed to marshal transaction for websocket client", "tx_hash", tx.Hash, "error", err)
+					continue
+				}
+				conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+				if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+					slog.Debug("websocket write failed", "client", client, "error", err)
+					return
+				}
+				tenant.Delivered(len(data))
+			}
+		}
+	})
+}